@@ -0,0 +1,144 @@
+package scuttlebutt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Access log formats.
+const (
+	// AccessLogCommon is the Apache Common Log Format.
+	AccessLogCommon = "common"
+
+	// AccessLogCombined is the Common Log Format with the referer & user agent.
+	AccessLogCombined = "combined"
+
+	// AccessLogJSON writes one JSON object per request.
+	AccessLogJSON = "json"
+)
+
+// ValidAccessLogFormat returns true if format is a known access log format.
+func ValidAccessLogFormat(format string) bool {
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// AccessLogHandler wraps a handler and writes a line for each request with
+// its method, path, status, latency, and response size.
+type AccessLogHandler struct {
+	mu sync.Mutex
+
+	Handler http.Handler
+	Output  io.Writer
+
+	// Log line format. Defaults to AccessLogCommon.
+	Format string
+
+	// Returns the current time. Used for testing.
+	Now func() time.Time
+}
+
+// NewAccessLogHandler returns a new instance of AccessLogHandler.
+func NewAccessLogHandler(h http.Handler, w io.Writer, format string) *AccessLogHandler {
+	if format == "" {
+		format = AccessLogCommon
+	}
+	return &AccessLogHandler{Handler: h, Output: w, Format: format, Now: time.Now}
+}
+
+func (h *AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := h.Now()
+	lw := &accessLogResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	h.Handler.ServeHTTP(lw, r)
+	latency := h.Now().Sub(t)
+
+	// Build log line.
+	var line string
+	switch h.Format {
+	case AccessLogJSON:
+		buf, _ := json.Marshal(&accessLogEntry{
+			Time:      t.UTC(),
+			RemoteIP:  remoteIP(r),
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Status:    lw.status,
+			Bytes:     lw.n,
+			LatencyMS: float64(latency) / float64(time.Millisecond),
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+		line = string(buf) + "\n"
+	case AccessLogCombined:
+		line = fmt.Sprintf("%s - - [%s] %q %d %d %q %q %s\n",
+			remoteIP(r), t.Format("02/Jan/2006:15:04:05 -0700"), r.Method+" "+r.URL.RequestURI()+" "+r.Proto,
+			lw.status, lw.n, r.Referer(), r.UserAgent(), latency)
+	default:
+		line = fmt.Sprintf("%s - - [%s] %q %d %d %s\n",
+			remoteIP(r), t.Format("02/Jan/2006:15:04:05 -0700"), r.Method+" "+r.URL.RequestURI()+" "+r.Proto,
+			lw.status, lw.n, latency)
+	}
+
+	// Write as a single call so lines from concurrent requests do not interleave.
+	h.mu.Lock()
+	io.WriteString(h.Output, line)
+	h.mu.Unlock()
+}
+
+// accessLogEntry is the JSON representation of an access log line.
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RemoteIP  string    `json:"remote_ip"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// accessLogResponseWriter records the status & size of a response.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	n           int64
+	wroteHeader bool
+}
+
+func (w *accessLogResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client, if supported by the underlying writer.
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// remoteIP returns the IP address of the client.
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,45 @@
+package scuttlebutt_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure requests are logged in each format.
+func TestAccessLogHandler_ServeHTTP(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		line   string
+	}{
+		{scuttlebutt.AccessLogCommon, `192.0.2.1 - - [01/Jan/2000:00:00:00 +0000] "GET /top?n=1 HTTP/1.1" 404 5 250ms` + "\n"},
+		{scuttlebutt.AccessLogCombined, `192.0.2.1 - - [01/Jan/2000:00:00:00 +0000] "GET /top?n=1 HTTP/1.1" 404 5 "http://example.com" "test" 250ms` + "\n"},
+		{scuttlebutt.AccessLogJSON, `{"time":"2000-01-01T00:00:00Z","remote_ip":"192.0.2.1","method":"GET","path":"/top?n=1","status":404,"bytes":5,"latency_ms":250,"referer":"http://example.com","user_agent":"test"}` + "\n"},
+	} {
+		var buf bytes.Buffer
+		h := scuttlebutt.NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("nope\n"))
+		}), &buf, tt.format)
+
+		// Advance the clock between the start & end of the request.
+		now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		h.Now = func() time.Time {
+			t := now
+			now = now.Add(250 * time.Millisecond)
+			return t
+		}
+
+		r := httptest.NewRequest("GET", "/top?n=1", nil)
+		r.Header.Set("Referer", "http://example.com")
+		r.Header.Set("User-Agent", "test")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if buf.String() != tt.line {
+			t.Errorf("%s: unexpected line: %s", tt.format, buf.String())
+		}
+	}
+}
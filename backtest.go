@@ -0,0 +1,139 @@
+package scuttlebutt
+
+import (
+	"path"
+	"sort"
+	"time"
+)
+
+// Backtest replays stored mention history through the ranking, thresholds,
+// and account schedules to report which repositories would have been
+// notified over a time range. This allows scoring and schedule changes to
+// be evaluated before they are deployed.
+//
+// Only mentions with a known time are replayed. Routing rules, moderation,
+// and refreshed star counts are not simulated.
+type Backtest struct {
+	Accounts   []*Account
+	Thresholds Thresholds
+
+	// Normalizes repository & account languages.
+	LanguageAliases LanguageAliases
+
+	// Weight of each mention by message source when ranking.
+	SourceWeights map[string]float64
+
+	// Time between notification checks.
+	// Defaults to DefaultNotifyCheckInterval if zero.
+	CheckInterval time.Duration
+}
+
+// BacktestPick represents a repository that would have been notified.
+type BacktestPick struct {
+	Time         time.Time
+	Username     string
+	RepositoryID string
+
+	// Ranked mentions of the repository at the time of the pick.
+	Mentions int
+}
+
+// Run replays the mentions of repos between start and end and returns the
+// picks in time order. Each repository is picked at most once and the
+// notified flag of the repositories is ignored.
+func (b *Backtest) Run(repos []*Repository, start, end time.Time) []*BacktestPick {
+	interval := b.CheckInterval
+	if interval <= 0 {
+		interval = DefaultNotifyCheckInterval
+	}
+
+	// Sort each repository's timed messages so mentions at a point in time
+	// are a prefix of the messages.
+	candidates := make([]*Repository, 0, len(repos))
+	for _, r := range repos {
+		other := *r
+		other.Language = b.LanguageAliases.Normalize(r.Language)
+		other.Messages = nil
+		for _, m := range r.Messages {
+			if !m.Time.IsZero() {
+				other.Messages = append(other.Messages, m)
+			}
+		}
+		sort.Sort(messagesByTime(other.Messages))
+		candidates = append(candidates, &other)
+	}
+
+	var picks []*BacktestPick
+	picked := make(map[string]bool)
+	last := make(map[string]time.Time)
+	for now := start; !now.After(end); now = now.Add(interval) {
+		for _, acc := range b.Accounts {
+			if !acc.Notifier.Due(now, last[acc.Username]) {
+				continue
+			}
+
+			// Rank unpicked repositories that meet the thresholds by the
+			// mentions known at the time.
+			var a []*Repository
+			for _, r := range candidates {
+				if picked[r.ID] || !b.match(acc, r) {
+					continue
+				}
+				if r = r.at(now); len(r.Messages) > 0 && b.Thresholds.Check(r) == "" {
+					a = append(a, r)
+				}
+			}
+			sort.Stable(repositoriesByScore{a, b.SourceWeights})
+
+			// Digest accounts post the eligible repositories from their top
+			// repositories. Others only post their top repository.
+			n := 1
+			if acc.DigestN > 0 {
+				n = acc.DigestN
+			}
+			if len(a) > n {
+				a = a[:n]
+			}
+
+			for _, r := range a {
+				picked[r.ID], last[acc.Username] = true, now
+				picks = append(picks, &BacktestPick{
+					Time:         now,
+					Username:     acc.Username,
+					RepositoryID: r.ID,
+					Mentions:     r.RankedMentions(),
+				})
+			}
+		}
+	}
+	return picks
+}
+
+// match returns true if r is posted about by the account's pattern, topic,
+// or language.
+func (b *Backtest) match(acc *Account, r *Repository) bool {
+	switch {
+	case acc.Pattern != nil:
+		return acc.Pattern.MatchString(path.Base(r.ID)) || acc.Pattern.MatchString(r.Description)
+	case acc.Topic != "":
+		return r.HasTopic(acc.Topic)
+	default:
+		return r.Language == b.LanguageAliases.Normalize(acc.Language)
+	}
+}
+
+// at returns a copy of r with only the messages posted by t. Messages must
+// be sorted by time.
+func (r *Repository) at(t time.Time) *Repository {
+	i := sort.Search(len(r.Messages), func(i int) bool { return r.Messages[i].Time.After(t) })
+	other := *r
+	other.Messages = r.Messages[:i]
+	return &other
+}
+
+// messagesByTime sorts messages by time, oldest first.
+type messagesByTime []*Message
+
+func (p messagesByTime) Len() int           { return len(p) }
+func (p messagesByTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p messagesByTime) Less(i, j int) bool { return p[i].Time.Before(p[j].Time) }
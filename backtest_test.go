@@ -0,0 +1,56 @@
+package scuttlebutt_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Ensure a backtest only ranks mentions known at each check and picks each
+// repository once.
+func TestBacktest_Run(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	repos := []*scuttlebutt.Repository{
+		{ID: "github.com/a/early", Language: "Go", Messages: []*scuttlebutt.Message{
+			{ID: 1, Time: at(0)},
+		}},
+		{ID: "github.com/b/late", Language: "golang", Messages: []*scuttlebutt.Message{
+			{ID: 2, Time: at(1)}, {ID: 3, Time: at(1)}, {ID: 4, Time: at(2)},
+		}},
+		{ID: "github.com/c/ruby", Language: "Ruby", Messages: []*scuttlebutt.Message{
+			{ID: 5, Time: at(0)}, {ID: 6, Time: at(0)},
+		}},
+		{ID: "github.com/d/untimed", Language: "Go", Messages: []*scuttlebutt.Message{
+			{ID: 7}, {ID: 8}, {ID: 9}, {ID: 10},
+		}},
+	}
+
+	b := &scuttlebutt.Backtest{
+		Accounts: []*scuttlebutt.Account{
+			{Username: "oss_go", Language: "Go", Notifier: &IntervalNotifier{Interval: 2 * time.Hour}},
+		},
+		LanguageAliases: scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases),
+		CheckInterval:   time.Hour,
+	}
+	picks := b.Run(repos, start, at(4))
+	if exp := []*scuttlebutt.BacktestPick{
+		{Time: at(0), Username: "oss_go", RepositoryID: "github.com/a/early", Mentions: 1},
+		{Time: at(2), Username: "oss_go", RepositoryID: "github.com/b/late", Mentions: 3},
+	}; !reflect.DeepEqual(picks, exp) {
+		t.Fatalf("unexpected picks: %s", spew.Sdump(picks))
+	}
+
+	// Thresholds are checked against the mentions at the time of the check.
+	b.Thresholds.MinMentions = 2
+	picks = b.Run(repos, start, at(4))
+	if exp := []*scuttlebutt.BacktestPick{
+		{Time: at(1), Username: "oss_go", RepositoryID: "github.com/b/late", Mentions: 2},
+	}; !reflect.DeepEqual(picks, exp) {
+		t.Fatalf("unexpected picks: %s", spew.Sdump(picks))
+	}
+}
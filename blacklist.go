@@ -0,0 +1,60 @@
+package scuttlebutt
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// blacklistRegexps caches compiled regular expression patterns by pattern
+// since the blacklist is matched against every ranked & ingested repository.
+// Invalid patterns are cached as nil.
+var blacklistRegexps sync.Map
+
+// MatchBlacklist returns true if a repository ID matches a blacklist pattern.
+//
+// Patterns wrapped in slashes, such as "/awesome-.*/", are regular
+// expressions. All other patterns are exact repository IDs or path.Match
+// globs, such as "github.com/*/awesome-*". Matching is case insensitive.
+func MatchBlacklist(pattern, repositoryID string) bool {
+	if re := blacklistRegexp(pattern); re != nil {
+		return re.MatchString(repositoryID)
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repositoryID))
+	return ok
+}
+
+// ValidBlacklistPattern returns true if pattern is a valid blacklist pattern.
+func ValidBlacklistPattern(pattern string) bool {
+	if isBlacklistRegexp(pattern) {
+		return blacklistRegexp(pattern) != nil
+	} else if pattern == "" {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// isBlacklistRegexp returns true if pattern is wrapped in slashes.
+func isBlacklistRegexp(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// blacklistRegexp returns the compiled regular expression for pattern.
+// Returns nil if pattern is not a valid regular expression pattern.
+// Patterns are only compiled once.
+func blacklistRegexp(pattern string) *regexp.Regexp {
+	if !isBlacklistRegexp(pattern) {
+		return nil
+	} else if v, ok := blacklistRegexps.Load(pattern); ok {
+		return v.(*regexp.Regexp)
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+	if err != nil {
+		re = nil
+	}
+	blacklistRegexps.Store(pattern, re)
+	return re
+}
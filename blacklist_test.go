@@ -0,0 +1,35 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure blacklist patterns match exact IDs, globs, and regular expressions.
+func TestMatchBlacklist(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		id      string
+		match   bool
+	}{
+		{pattern: "github.com/user/repo", id: "github.com/user/repo", match: true},
+		{pattern: "github.com/user/repo", id: "github.com/User/Repo", match: true},
+		{pattern: "github.com/user/repo", id: "github.com/user/repo2", match: false},
+		{pattern: "github.com/*/awesome-*", id: "github.com/user/awesome-go", match: true},
+		{pattern: "github.com/*/awesome-*", id: "github.com/user/go-awesome", match: false},
+		{pattern: "/awesome/", id: "github.com/user/go-awesome", match: true},
+		{pattern: "/^github.com/spam[0-9]+//", id: "github.com/spam42/repo", match: true},
+		{pattern: "/^github.com/spam[0-9]+//", id: "github.com/user/spam42", match: false},
+	} {
+		if match := scuttlebutt.MatchBlacklist(tt.pattern, tt.id); match != tt.match {
+			t.Errorf("%s %s: unexpected match: %v", tt.pattern, tt.id, match)
+		}
+	}
+
+	for _, pattern := range []string{"", "github.com/[", "/(/"} {
+		if scuttlebutt.ValidBlacklistPattern(pattern) {
+			t.Errorf("%q: expected invalid pattern", pattern)
+		}
+	}
+}
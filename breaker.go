@@ -0,0 +1,175 @@
+package scuttlebutt
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerTimeout is the default maximum time for a request sent
+	// through a circuit breaker.
+	DefaultBreakerTimeout = 30 * time.Second
+
+	// DefaultBreakerFailureThreshold is the default number of consecutive
+	// failures that open a circuit.
+	DefaultBreakerFailureThreshold = 5
+
+	// DefaultBreakerResetTimeout is the default time a circuit stays open
+	// before probe requests are allowed.
+	DefaultBreakerResetTimeout = 30 * time.Second
+
+	// DefaultBreakerHalfOpenProbes is the default number of successful probe
+	// requests required to close a circuit.
+	DefaultBreakerHalfOpenProbes = 1
+)
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned when a request is rejected by an open circuit.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// breakerVars publishes the state of each circuit breaker as expvars.
+var breakerVars = expvar.NewMap("circuit_breakers")
+
+// CircuitBreaker is an http.RoundTripper that stops sending requests to an
+// upstream API after consecutive failures. Transport errors, timeouts, and
+// 5xx responses are failures. Once the reset timeout has passed, up to
+// HalfOpenProbes requests are let through and the circuit closes once they
+// all succeed or reopens on the first failure.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+
+	// Name of the upstream, used to publish the breaker's state.
+	Name string
+
+	// Maximum time for each request, including reading the response body.
+	Timeout time.Duration
+
+	// Consecutive failures that open the circuit.
+	FailureThreshold int
+
+	// Time the circuit stays open before probe requests are allowed.
+	ResetTimeout time.Duration
+
+	// Number of successful probes required to close the circuit.
+	HalfOpenProbes int
+
+	// Underlying transport. Uses http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// Returns the current time. Overridable for testing.
+	Now func() time.Time
+}
+
+// NewCircuitBreaker returns a new closed CircuitBreaker with default
+// settings. The breaker's state is published under name.
+func NewCircuitBreaker(name string, transport http.RoundTripper) *CircuitBreaker {
+	b := &CircuitBreaker{
+		state:            BreakerClosed,
+		Name:             name,
+		Timeout:          DefaultBreakerTimeout,
+		FailureThreshold: DefaultBreakerFailureThreshold,
+		ResetTimeout:     DefaultBreakerResetTimeout,
+		HalfOpenProbes:   DefaultBreakerHalfOpenProbes,
+		Transport:        transport,
+		Now:              time.Now,
+	}
+	breakerVars.Set(name, expvar.Func(func() interface{} { return b.State() }))
+	return b
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.Now().Sub(b.openedAt) >= b.ResetTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// RoundTrip sends req unless the circuit is open.
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	rt := b.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := roundTripTimeout(rt, req, b.Timeout)
+
+	// Requests cancelled by the caller do not count against the upstream.
+	if err != nil && req.Context().Err() != nil {
+		b.release()
+		return nil, err
+	}
+	b.record(err == nil && resp.StatusCode < 500)
+	return resp, err
+}
+
+// allow returns ErrCircuitOpen if a request cannot be sent. Moves an open
+// circuit to half-open once the reset timeout has passed.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.Now().Sub(b.openedAt) < b.ResetTimeout {
+			return ErrCircuitOpen
+		}
+		b.state, b.probes, b.successes = BreakerHalfOpen, 0, 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.HalfOpenProbes {
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// release returns a probe slot without recording a result.
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// record updates the circuit with the result of a request.
+func (b *CircuitBreaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if ok {
+			b.failures = 0
+		} else if b.failures++; b.failures >= b.FailureThreshold {
+			b.state, b.openedAt = BreakerOpen, b.Now()
+		}
+	case BreakerHalfOpen:
+		if !ok {
+			b.state, b.openedAt = BreakerOpen, b.Now()
+		} else if b.successes++; b.successes >= b.HalfOpenProbes {
+			b.state, b.failures = BreakerClosed, 0
+		}
+	}
+}
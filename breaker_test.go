@@ -0,0 +1,88 @@
+package scuttlebutt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the circuit opens after consecutive failures and closes once a
+// probe succeeds after the reset timeout.
+func TestCircuitBreaker_RoundTrip(t *testing.T) {
+	status := http.StatusInternalServerError
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := scuttlebutt.NewCircuitBreaker("test", nil)
+	b.FailureThreshold, b.ResetTimeout = 2, time.Minute
+	b.Now = func() time.Time { return now }
+	client := &http.Client{Transport: b}
+
+	get := func() error {
+		resp, err := client.Get(s.URL)
+		if err != nil {
+			return err.(*url.Error).Err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	// Open the circuit with consecutive server errors.
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := get(); err != scuttlebutt.ErrCircuitOpen {
+		t.Fatalf("unexpected error: %v", err)
+	} else if requests != 2 {
+		t.Fatalf("unexpected request count: %d", requests)
+	} else if state := b.State(); state != scuttlebutt.BreakerOpen {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// A failed probe reopens the circuit.
+	now = now.Add(time.Minute)
+	if state := b.State(); state != scuttlebutt.BreakerHalfOpen {
+		t.Fatalf("unexpected state: %s", state)
+	} else if err := get(); err != nil {
+		t.Fatal(err)
+	} else if err := get(); err != scuttlebutt.ErrCircuitOpen {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A successful probe closes the circuit.
+	now, status = now.Add(time.Minute), http.StatusOK
+	if err := get(); err != nil {
+		t.Fatal(err)
+	} else if state := b.State(); state != scuttlebutt.BreakerClosed {
+		t.Fatalf("unexpected state: %s", state)
+	} else if requests != 4 {
+		t.Fatalf("unexpected request count: %d", requests)
+	}
+}
+
+// Ensure slow requests time out and count as failures.
+func TestCircuitBreaker_RoundTrip_Timeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	b := scuttlebutt.NewCircuitBreaker("test", nil)
+	b.Timeout, b.FailureThreshold = 50*time.Millisecond, 1
+	if _, err := (&http.Client{Transport: b}).Get(s.URL); err == nil {
+		t.Fatal("expected timeout")
+	} else if state := b.State(); state != scuttlebutt.BreakerOpen {
+		t.Fatalf("unexpected state: %s", state)
+	}
+}
@@ -0,0 +1,473 @@
+// Package client implements a Go client for the scuttlebutt JSON API
+// described at /openapi.json.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default time allowed for a request.
+const DefaultTimeout = 10 * time.Second
+
+// ErrNotFound is returned when a repository does not exist.
+var ErrNotFound = errors.New("not found")
+
+// TopRepository is the top unnotified repository for a language or topic key.
+type TopRepository struct {
+	Key         string   `json:"key"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+}
+
+// RankedRepository is a repository ranked by score across all languages.
+type RankedRepository struct {
+	Rank        int      `json:"rank"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+	Score       float64  `json:"score"`
+}
+
+// RecentRepository is a repository ranked by its mentions within a window.
+type RecentRepository struct {
+	Rank        int      `json:"rank"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+	Boost       int      `json:"boost,omitempty"`
+}
+
+// Repository is a repository with its most recent messages. Mentions is the
+// total number of messages, including those not returned.
+type Repository struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	URL         string         `json:"url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Topics      []string       `json:"topics,omitempty"`
+	Labels      []string       `json:"labels,omitempty"`
+	Stars       int            `json:"stars"`
+	Forks       int            `json:"forks"`
+	Notified    bool           `json:"notified"`
+	Fork        bool           `json:"fork,omitempty"`
+	Archived    bool           `json:"archived,omitempty"`
+	Disabled    bool           `json:"disabled,omitempty"`
+	License     string         `json:"license,omitempty"`
+	Boost       int            `json:"boost,omitempty"`
+	Mentions    int            `json:"mentions"`
+	Sources     map[string]int `json:"sources,omitempty"`
+	Messages    []*Message     `json:"messages"`
+}
+
+// Message is a message that mentions a repository.
+type Message struct {
+	ID   uint64 `json:"id,string"`
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+}
+
+// MessageAdded is a newly saved message streamed from the server.
+type MessageAdded struct {
+	ID           uint64 `json:"id,string"`
+	RepositoryID string `json:"repository_id"`
+	Language     string `json:"language,omitempty"`
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	Author       string `json:"author,omitempty"`
+}
+
+// Notification is a notification attempt from the audit log.
+type Notification struct {
+	ID           uint64      `json:"id,string"`
+	Username     string      `json:"username"`
+	RepositoryID string      `json:"repository_id,omitempty"`
+	Text         string      `json:"text"`
+	MessageID    uint64      `json:"message_id,string,omitempty"`
+	URL          string      `json:"url,omitempty"`
+	Time         time.Time   `json:"time"`
+	Success      bool        `json:"success"`
+	Error        string      `json:"error,omitempty"`
+	Template     string      `json:"template,omitempty"`
+	Engagement   *Engagement `json:"engagement,omitempty"`
+}
+
+// TemplateStats is the engagement with an account's template variant.
+// Averages are over the measured notifications.
+type TemplateStats struct {
+	Username       string  `json:"username"`
+	Template       string  `json:"template"`
+	Notifications  int     `json:"notifications"`
+	Measured       int     `json:"measured"`
+	Likes          int     `json:"likes"`
+	Retweets       int     `json:"retweets"`
+	Impressions    int     `json:"impressions"`
+	AvgLikes       float64 `json:"avg_likes"`
+	AvgRetweets    float64 `json:"avg_retweets"`
+	AvgImpressions float64 `json:"avg_impressions"`
+}
+
+// Engagement is the engagement counts of a sent message.
+type Engagement struct {
+	Likes       int       `json:"likes"`
+	Retweets    int       `json:"retweets"`
+	Impressions int       `json:"impressions"`
+	Time        time.Time `json:"time"`
+}
+
+// Language is the repository & mention counts for a language.
+type Language struct {
+	Language       string   `json:"language"`
+	Repositories   int      `json:"repositories"`
+	Mentions       int      `json:"mentions"`
+	RecentMentions int      `json:"recent_mentions"`
+	HasAccount     bool     `json:"has_account"`
+	Accounts       []string `json:"accounts,omitempty"`
+}
+
+// SearchResult is a repository matching a search query.
+type SearchResult struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+	Language    string     `json:"language"`
+	Notified    bool       `json:"notified"`
+	Mentions    int        `json:"mentions"`
+	Matched     bool       `json:"matched"`
+	Messages    []*Message `json:"messages"`
+}
+
+// MentionTimeSeries is a repository's mentions counted per UTC day.
+type MentionTimeSeries struct {
+	RepositoryID string           `json:"repository_id"`
+	Days         []*DailyMentions `json:"days"`
+	Undated      int              `json:"undated,omitempty"`
+}
+
+// DailyMentions is the number of mentions posted on a UTC day.
+type DailyMentions struct {
+	Date     time.Time `json:"date"`
+	Mentions int       `json:"mentions"`
+}
+
+// Snapshot is a day's ranking of the top repositories per language.
+type Snapshot struct {
+	Date      time.Time                   `json:"date"`
+	Languages map[string][]*SnapshotEntry `json:"languages"`
+}
+
+// SnapshotEntry is a ranked repository within a snapshot.
+type SnapshotEntry struct {
+	Rank         int    `json:"rank"`
+	RepositoryID string `json:"repository_id"`
+	Mentions     int    `json:"mentions"`
+}
+
+// ShortURL is a shortened repository URL.
+type ShortURL struct {
+	Short string `json:"short"`
+	Long  string `json:"long"`
+}
+
+// Client queries a scuttlebutt server.
+type Client struct {
+	// Base URL of the server, such as "http://localhost:8080".
+	URL string
+
+	HTTPClient *http.Client
+}
+
+// New returns a new instance of Client for the server at url.
+func New(url string) *Client {
+	return &Client{
+		URL:        url,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Ping returns an error if the server or its store is not working.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.get(ctx, "/ping", nil, nil)
+}
+
+// TopRepositories returns the top unnotified repository for each language &
+// topic, sorted by key.
+func (c *Client) TopRepositories(ctx context.Context) ([]*TopRepository, error) {
+	var a []*TopRepository
+	if err := c.get(ctx, "/api/v1/top", nil, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// TopRepositoriesOverall returns up to n of the top repositories across all
+// languages. The server default is used if n is zero.
+func (c *Client) TopRepositoriesOverall(ctx context.Context, n int) ([]*RankedRepository, error) {
+	q := url.Values{}
+	if n > 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+
+	var a []*RankedRepository
+	if err := c.get(ctx, "/api/v1/top/overall", q, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// RecentTopRepositories returns up to n of the top unnotified repositories
+// by their mentions within window. Only repositories in language are
+// returned if it is set. Server defaults are used for zero values.
+func (c *Client) RecentTopRepositories(ctx context.Context, language string, n int, window time.Duration) ([]*RecentRepository, error) {
+	q := url.Values{}
+	if language != "" {
+		q.Set("language", language)
+	}
+	if n > 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+	if window > 0 {
+		q.Set("window", window.String())
+	}
+
+	var a []*RecentRepository
+	if err := c.get(ctx, "/api/v1/top/recent", q, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Repository returns a repository by ID with up to n of its most recent
+// messages. Returns ErrNotFound if the repository does not exist.
+func (c *Client) Repository(ctx context.Context, id string, n int) (*Repository, error) {
+	q := url.Values{"messages": {strconv.Itoa(n)}}
+
+	var r Repository
+	if err := c.get(ctx, "/api/v1/repositories/"+id, q, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Languages returns the repository & mention counts for each language seen,
+// most mentioned first.
+func (c *Client) Languages(ctx context.Context) ([]*Language, error) {
+	var a []*Language
+	if err := c.get(ctx, "/languages", nil, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// TemplateStats returns the engagement with each account's template variants.
+func (c *Client) TemplateStats(ctx context.Context) ([]*TemplateStats, error) {
+	var a []*TemplateStats
+	if err := c.get(ctx, "/notifications/templates", nil, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Search returns up to n repositories whose ID, description, or messages
+// contain every term in q. The server default is used if n is zero.
+func (c *Client) Search(ctx context.Context, q string, n int) ([]*SearchResult, error) {
+	v := url.Values{"q": {q}}
+	if n > 0 {
+		v.Set("n", strconv.Itoa(n))
+	}
+
+	var a []*SearchResult
+	if err := c.get(ctx, "/search", v, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// MentionTimeSeries returns a repository's mentions per day over the given
+// number of days, oldest first. The server default is used if days is zero.
+// Returns ErrNotFound if the repository does not exist.
+func (c *Client) MentionTimeSeries(ctx context.Context, id string, days int) (*MentionTimeSeries, error) {
+	q := url.Values{}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+
+	var ts MentionTimeSeries
+	if err := c.get(ctx, "/repositories/"+id+"/timeseries", q, &ts); err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// Notifications returns up to n of the most recent notification attempts,
+// newest first. The server default is used if n is zero.
+func (c *Client) Notifications(ctx context.Context, n int) ([]*Notification, error) {
+	q := url.Values{}
+	if n > 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+
+	var a []*Notification
+	if err := c.get(ctx, "/notifications", q, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// History returns the daily snapshots recorded over the last days, oldest first.
+// Snapshots only include the language's ranking if language is set. The
+// server default is used if days is zero.
+func (c *Client) History(ctx context.Context, language string, days int) ([]*Snapshot, error) {
+	q := url.Values{}
+	if language != "" {
+		q.Set("language", language)
+	}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+
+	var a []*Snapshot
+	if err := c.get(ctx, "/history", q, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ShortURLs returns all shortened repository URLs.
+func (c *Client) ShortURLs(ctx context.Context) ([]*ShortURL, error) {
+	var a []*ShortURL
+	if err := c.get(ctx, "/api/v1/short_urls", nil, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// StreamMessages calls fn with each message saved by the server until ctx is
+// done, fn returns an error, or the server closes the stream. Only messages
+// for repositories in language are streamed if it is set. The client
+// timeout does not apply to the stream.
+func (c *Client) StreamMessages(ctx context.Context, language string, fn func(m *MessageAdded) error) error {
+	u := strings.TrimSuffix(c.URL, "/") + "/messages/stream"
+	if language != "" {
+		u += "?" + url.Values{"language": {language}}.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Decode the data of each event. Events end with a blank line.
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		} else if line != "" || len(data) == 0 {
+			continue
+		}
+
+		var m MessageAdded
+		if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &m); err != nil {
+			return fmt.Errorf("decode: %s", err)
+		} else if err := fn(&m); err != nil {
+			return err
+		}
+		data = nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+// get sends a GET request to path and decodes the JSON response into v.
+// The response body is ignored if v is nil.
+func (c *Client) get(ctx context.Context, path string, q url.Values, v interface{}) error {
+	u := strings.TrimSuffix(c.URL, "/") + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if v == nil {
+		return nil
+	} else if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode: %s", err)
+	}
+	return nil
+}
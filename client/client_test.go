@@ -0,0 +1,96 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/client"
+)
+
+// Ensure the top repositories are decoded.
+func TestClient_TopRepositories(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/top" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`[{"key":"go","id":"github.com/user/repo","name":"repo","url":"https://github.com/user/repo","description":"foo","language":"Go","stars":10,"forks":2,"mentions":3}]`))
+	}))
+	defer s.Close()
+
+	a, err := client.New(s.URL).TopRepositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []*client.TopRepository{{
+		Key:         "go",
+		ID:          "github.com/user/repo",
+		Name:        "repo",
+		URL:         "https://github.com/user/repo",
+		Description: "foo",
+		Language:    "Go",
+		Stars:       10,
+		Forks:       2,
+		Mentions:    3,
+	}}) {
+		t.Fatalf("unexpected repositories: %#v", a)
+	}
+}
+
+// Ensure a missing repository returns ErrNotFound.
+func TestClient_Repository_ErrNotFound(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repositories/github.com/user/nope" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		} else if v := r.URL.Query().Get("messages"); v != "5" {
+			t.Errorf("unexpected messages: %s", v)
+		}
+		http.NotFound(w, r)
+	}))
+	defer s.Close()
+
+	if _, err := client.New(s.URL).Repository(context.Background(), "github.com/user/nope", 5); err != client.ErrNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure unexpected statuses return the server's error message.
+func TestClient_TopRepositoriesOverall_Error(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid n", http.StatusBadRequest)
+	}))
+	defer s.Close()
+
+	if _, err := client.New(s.URL).TopRepositoriesOverall(context.Background(), 1000); err == nil || err.Error() != "unexpected status: 400: invalid n" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure streamed messages are decoded from server-sent events.
+func TestClient_StreamMessages(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages/stream" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		} else if v := r.URL.Query().Get("language"); v != "go" {
+			t.Errorf("unexpected language: %s", v)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_added\nid: 1\ndata: {\"id\":\"1\",\"repository_id\":\"github.com/user/repo\",\"language\":\"Go\",\"text\":\"foo\",\"author\":\"bob\"}\n\n"))
+		w.Write([]byte("event: message_added\nid: 2\ndata: {\"id\":\"2\",\"repository_id\":\"github.com/user/repo\",\"text\":\"bar\"}\n\n"))
+	}))
+	defer s.Close()
+
+	var a []*client.MessageAdded
+	if err := client.New(s.URL).StreamMessages(context.Background(), "go", func(m *client.MessageAdded) error {
+		a = append(a, m)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []*client.MessageAdded{
+		{ID: 1, RepositoryID: "github.com/user/repo", Language: "Go", Text: "foo", Author: "bob"},
+		{ID: 2, RepositoryID: "github.com/user/repo", Text: "bar"},
+	}) {
+		t.Fatalf("unexpected messages: %#v", a)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kurrik/oauth1a"
+)
+
+// Twitter OAuth 1.0a endpoints.
+const (
+	DefaultTwitterRequestURL   = "https://api.twitter.com/oauth/request_token"
+	DefaultTwitterAuthorizeURL = "https://api.twitter.com/oauth/authorize"
+	DefaultTwitterAccessURL    = "https://api.twitter.com/oauth/access_token"
+)
+
+// TwitterAuthCommand represents a command for obtaining an account's access
+// token & secret using Twitter's PIN-based OAuth flow.
+type TwitterAuthCommand struct {
+	// Optional config path. The consumer key & secret are read from the
+	// config's [twitter] section unless overridden.
+	ConfigPath string
+
+	// Application consumer key & secret.
+	Key    string
+	Secret string
+
+	// If true, the account is appended to the config file.
+	Write bool
+
+	// OAuth endpoints & the client used to call them.
+	RequestURL   string
+	AuthorizeURL string
+	AccessURL    string
+	HTTPClient   *http.Client
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewTwitterAuthCommand returns a new instance of TwitterAuthCommand.
+func NewTwitterAuthCommand() *TwitterAuthCommand {
+	return &TwitterAuthCommand{
+		RequestURL:   DefaultTwitterRequestURL,
+		AuthorizeURL: DefaultTwitterAuthorizeURL,
+		AccessURL:    DefaultTwitterAccessURL,
+		HTTPClient:   http.DefaultClient,
+
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *TwitterAuthCommand) ParseFlags(args []string) error {
+	var key, secret string
+	fs := flag.NewFlagSet("scuttlebuttd-auth-twitter", flag.ContinueOnError)
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path")
+	fs.StringVar(&key, "key", "", "twitter consumer key override")
+	fs.StringVar(&secret, "secret", "", "twitter consumer secret override")
+	fs.BoolVar(&cmd.Write, "w", false, "append the account to the config file")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Read consumer credentials from the config, if specified.
+	if cmd.ConfigPath != "" {
+		c, err := ParseConfigFile(cmd.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("parse config file: %s", err)
+		}
+		cmd.Key, cmd.Secret = c.Twitter.Key, c.Twitter.Secret
+	}
+	if key != "" {
+		cmd.Key = key
+	}
+	if secret != "" {
+		cmd.Secret = secret
+	}
+
+	// Validate options.
+	if cmd.Key == "" || cmd.Secret == "" {
+		return errors.New("twitter consumer key & secret required")
+	} else if cmd.Write && cmd.ConfigPath == "" {
+		return errors.New("config path required to write account")
+	}
+
+	return nil
+}
+
+// Run authorizes an account and prints its access token & secret as a
+// config section. The account is appended to the config file if enabled.
+func (cmd *TwitterAuthCommand) Run() error {
+	service := &oauth1a.Service{
+		RequestURL:   cmd.RequestURL,
+		AuthorizeURL: cmd.AuthorizeURL,
+		AccessURL:    cmd.AccessURL,
+		ClientConfig: &oauth1a.ClientConfig{
+			ConsumerKey:    cmd.Key,
+			ConsumerSecret: cmd.Secret,
+			CallbackURL:    "oob",
+		},
+		Signer: new(oauth1a.HmacSha1Signer),
+	}
+
+	// Obtain a request token and ask the user to authorize it.
+	var user oauth1a.UserConfig
+	if err := user.GetRequestToken(service, cmd.HTTPClient); err != nil {
+		return fmt.Errorf("request token: %s", err)
+	}
+	u, err := user.GetAuthorizeURL(service)
+	if err != nil {
+		return fmt.Errorf("authorize url: %s", err)
+	}
+	fmt.Fprintf(cmd.Stdout, "Sign in as the account, open the following URL, and authorize the app:\n\n%s\n\nPIN: ", u)
+
+	// Read the PIN shown after authorizing.
+	scanner := bufio.NewScanner(cmd.Stdin)
+	scanner.Scan()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read pin: %s", err)
+	}
+	pin := strings.TrimSpace(scanner.Text())
+	if pin == "" {
+		return errors.New("pin required")
+	}
+
+	// Exchange the request token & PIN for an access token.
+	if err := user.GetAccessToken(user.RequestTokenKey, pin, service, cmd.HTTPClient); err != nil {
+		return fmt.Errorf("access token: %s", err)
+	}
+	section := fmt.Sprintf("[[account]]\nusername = %s\nkey = %s\nsecret = %s\n",
+		strconv.Quote(user.AccessValues.Get("screen_name")),
+		strconv.Quote(user.AccessTokenKey),
+		strconv.Quote(user.AccessTokenSecret),
+	)
+
+	if !cmd.Write {
+		fmt.Fprintf(cmd.Stdout, "\n%s", section)
+		return nil
+	}
+	if err := cmd.appendAccount(user.AccessValues.Get("screen_name"), section); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.Stdout, "\nadded @%s to %s, set its language, topic, or pattern before starting\n", user.AccessValues.Get("screen_name"), cmd.ConfigPath)
+	return nil
+}
+
+// appendAccount appends an account section to the config file. Returns an
+// error if the account is already configured.
+func (cmd *TwitterAuthCommand) appendAccount(username, section string) error {
+	c, err := ParseConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+	for _, acc := range c.Accounts {
+		if strings.EqualFold(acc.Username, username) {
+			return fmt.Errorf("account already configured: %s", username)
+		}
+	}
+
+	f, err := os.OpenFile(cmd.ConfigPath, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "\n%s", section); err != nil {
+		return err
+	}
+	return f.Close()
+}
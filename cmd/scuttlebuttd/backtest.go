@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// BacktestCommand represents a command for replaying stored mentions under
+// a candidate config and reporting which repositories would have been
+// notified, and when, without sending anything.
+type BacktestCommand struct {
+	// Data directory & candidate config path.
+	DataDir    string
+	ConfigPath string
+
+	// Time range to replay. Defaults to the last week.
+	Since time.Time
+	Until time.Time
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Returns the current time. Overridable for testing.
+	Now func() time.Time
+}
+
+// NewBacktestCommand returns a new instance of BacktestCommand.
+func NewBacktestCommand() *BacktestCommand {
+	return &BacktestCommand{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Now:    time.Now,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *BacktestCommand) ParseFlags(args []string) error {
+	var since, until string
+	fs := flag.NewFlagSet("scuttlebuttd-backtest", flag.ContinueOnError)
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "candidate config path")
+	fs.StringVar(&since, "since", "", "replay mentions from date")
+	fs.StringVar(&until, "until", "", "replay mentions until date")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.DataDir == "" {
+		return errors.New("data directory required")
+	} else if cmd.ConfigPath == "" {
+		return errors.New("config path required")
+	}
+
+	cmd.Until = cmd.Now().UTC()
+	if until != "" {
+		t, err := scuttlebutt.ParseDate(until)
+		if err != nil {
+			return fmt.Errorf("invalid until date: %s", until)
+		}
+		cmd.Until = t
+	}
+
+	cmd.Since = cmd.Until.AddDate(0, 0, -7)
+	if since != "" {
+		t, err := scuttlebutt.ParseDate(since)
+		if err != nil {
+			return fmt.Errorf("invalid since date: %s", since)
+		}
+		cmd.Since = t
+	}
+	if cmd.Since.After(cmd.Until) {
+		return errors.New("since date must be before until date")
+	}
+
+	return nil
+}
+
+// Run replays the stored mentions and prints each pick.
+func (cmd *BacktestCommand) Run() error {
+	c, err := ParseConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+
+	// Open the store without modifying it.
+	store := c.NewStore(cmd.DataDir)
+	store.ReadOnly = true
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	b, err := cmd.backtest(c, store.LanguageAliases)
+	if err != nil {
+		return err
+	}
+
+	// Replay repositories that the daemon would rank.
+	repos, err := store.Repositories()
+	if err != nil {
+		return fmt.Errorf("repositories: %s", err)
+	}
+	a := repos[:0]
+	for _, r := range repos {
+		if store.Exclusion(r) == "" {
+			a = append(a, r)
+		}
+	}
+
+	picks := b.Run(a, cmd.Since, cmd.Until)
+	for _, p := range picks {
+		fmt.Fprintf(cmd.Stdout, "%s\t%s\t%s\t%d\n", p.Time.Format(time.RFC3339), p.Username, p.RepositoryID, p.Mentions)
+	}
+	fmt.Fprintf(cmd.Stderr, "%d picks\n", len(picks))
+	return nil
+}
+
+// backtest returns a backtest of the config's accounts, intervals, schedules,
+// and thresholds.
+func (cmd *BacktestCommand) backtest(c *Config, aliases scuttlebutt.LanguageAliases) (*scuttlebutt.Backtest, error) {
+	b := &scuttlebutt.Backtest{
+		Thresholds:      c.NewThresholds(),
+		LanguageAliases: aliases,
+		SourceWeights:   c.SourceWeights,
+		CheckInterval:   time.Duration(c.NotifyCheckInterval),
+	}
+
+	for _, acc := range c.Accounts {
+		n := twitter.NewNotifier()
+		n.Username = acc.Username
+		n.Interval = scuttlebutt.DefaultNotifyInterval
+		if acc.NotifyInterval > 0 {
+			n.Interval = time.Duration(acc.NotifyInterval)
+		} else if c.NotifyInterval > 0 {
+			n.Interval = time.Duration(c.NotifyInterval)
+		}
+
+		re, err := acc.Regexp()
+		if err != nil {
+			return nil, fmt.Errorf("account pattern: username=%s, err=%s", acc.Username, err)
+		}
+		if n.Schedule, err = acc.Schedule(); err != nil {
+			return nil, fmt.Errorf("account schedule: username=%s, err=%s", acc.Username, err)
+		}
+
+		b.Accounts = append(b.Accounts, &scuttlebutt.Account{
+			Username: acc.Username,
+			Language: aliases.Normalize(acc.Language),
+			Topic:    acc.Topic,
+			Pattern:  re,
+			DigestN:  acc.Digest,
+			Notifier: n,
+		})
+	}
+	return b, nil
+}
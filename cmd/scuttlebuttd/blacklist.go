@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Blacklist command actions.
+const (
+	BlacklistAdd    = "add"
+	BlacklistRemove = "remove"
+	BlacklistList   = "list"
+)
+
+// BlacklistCommand represents a command for adding, removing, & listing
+// blacklisted repository IDs and patterns. Changes are sent to the running
+// daemon's admin API unless a data directory is specified, in which case
+// the store is changed directly and the daemon must be stopped. The admin
+// token defaults to the SCUTTLEBUTT_ADMIN_TOKEN environment variable.
+type BlacklistCommand struct {
+	// Action to perform & the repository ID or pattern it applies to.
+	Action  string
+	Pattern string
+
+	// Base URL of the running daemon & the token its admin API requires.
+	URL   string
+	Token string
+
+	// Data directory & optional config path. If a data directory is set,
+	// the store is used instead of the daemon and is opened with the
+	// config's storage settings, such as a separate message file.
+	DataDir    string
+	ConfigPath string
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewBlacklistCommand returns a new instance of BlacklistCommand.
+func NewBlacklistCommand(action string) *BlacklistCommand {
+	return &BlacklistCommand{
+		Action: action,
+		URL:    DefaultURL,
+		Token:  os.Getenv("SCUTTLEBUTT_ADMIN_TOKEN"),
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *BlacklistCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-blacklist-"+cmd.Action, flag.ContinueOnError)
+	fs.StringVar(&cmd.URL, "url", cmd.URL, "daemon URL")
+	fs.StringVar(&cmd.Token, "token", cmd.Token, "daemon admin token")
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory, used instead of the daemon")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path, used with the data directory")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	switch cmd.Action {
+	case BlacklistAdd, BlacklistRemove:
+		if fs.NArg() != 1 {
+			return errors.New("repository or pattern required")
+		} else if cmd.Pattern = fs.Arg(0); !scuttlebutt.ValidBlacklistPattern(cmd.Pattern) {
+			return scuttlebutt.ErrInvalidBlacklistPattern
+		}
+	case BlacklistList:
+		if fs.NArg() != 0 {
+			return errors.New("unexpected arguments")
+		}
+	default:
+		return fmt.Errorf("unknown blacklist action: %q", cmd.Action)
+	}
+
+	return nil
+}
+
+// Run performs the action against the store or the running daemon.
+func (cmd *BlacklistCommand) Run() error {
+	if cmd.DataDir != "" {
+		return cmd.runStore()
+	}
+	return cmd.runAPI()
+}
+
+// runStore performs the action directly against the store.
+func (cmd *BlacklistCommand) runStore() error {
+	c, err := parseOptionalConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+
+	store := c.NewStore(cmd.DataDir)
+	store.ReadOnly = store.ReadOnly || cmd.Action == BlacklistList
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	switch cmd.Action {
+	case BlacklistAdd:
+		if err := store.AddBlacklist(cmd.Pattern); err != nil {
+			return fmt.Errorf("add blacklist: %s", err)
+		}
+	case BlacklistRemove:
+		if err := store.RemoveBlacklist(cmd.Pattern); err != nil {
+			return fmt.Errorf("remove blacklist: %s", err)
+		}
+	default:
+		a, err := store.Blacklist()
+		if err != nil {
+			return fmt.Errorf("blacklist: %s", err)
+		}
+		cmd.print(a)
+		return nil
+	}
+	fmt.Fprintln(cmd.Stdout, "ok")
+	return nil
+}
+
+// runAPI performs the action through the daemon's admin API.
+func (cmd *BlacklistCommand) runAPI() error {
+	method, q := "GET", url.Values{}
+	switch cmd.Action {
+	case BlacklistAdd:
+		method, q = "POST", url.Values{"pattern": {cmd.Pattern}}
+	case BlacklistRemove:
+		method, q = "DELETE", url.Values{"pattern": {cmd.Pattern}}
+	}
+
+	u := strings.TrimSuffix(cmd.URL, "/") + "/admin/blacklist"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cmd.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if cmd.Action != BlacklistList {
+		fmt.Fprintln(cmd.Stdout, strings.TrimSpace(string(body)))
+		return nil
+	}
+
+	var a []string
+	if err := json.Unmarshal(body, &a); err != nil {
+		return fmt.Errorf("decode: %s", err)
+	}
+	cmd.print(a)
+	return nil
+}
+
+// print writes each blacklisted repository ID or pattern on its own line.
+func (cmd *BlacklistCommand) print(a []string) {
+	for _, pattern := range a {
+		fmt.Fprintln(cmd.Stdout, pattern)
+	}
+}
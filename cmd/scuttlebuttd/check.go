@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// ErrConfigInvalid is returned when the config check finds problems.
+var ErrConfigInvalid = errors.New("config invalid")
+
+// ConfigCheckCommand represents a command for validating a config file
+// and, optionally, verifying its credentials against the remote APIs.
+type ConfigCheckCommand struct {
+	ConfigPath string
+
+	// If true, credentials are verified against Twitter & GitHub.
+	Live bool
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewConfigCheckCommand returns a new instance of ConfigCheckCommand.
+func NewConfigCheckCommand() *ConfigCheckCommand {
+	return &ConfigCheckCommand{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *ConfigCheckCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-config-check", flag.ContinueOnError)
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path")
+	fs.BoolVar(&cmd.Live, "live", false, "verify credentials with remote APIs")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.ConfigPath == "" {
+		return errors.New("config path required")
+	}
+
+	return nil
+}
+
+// Run validates the config file and prints each problem found.
+// Returns ErrConfigInvalid if any problems were found.
+func (cmd *ConfigCheckCommand) Run() error {
+	c, md, err := decodeConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+
+	// Collect schema problems.
+	var problems []error
+	for _, key := range md.Undecoded() {
+		problems = append(problems, fmt.Errorf("unknown key: %s", key))
+	}
+	problems = append(problems, c.Validate()...)
+
+	// Only verify credentials if the config is structurally valid.
+	if cmd.Live && len(problems) == 0 {
+		problems = append(problems, cmd.verify(c)...)
+	}
+
+	// Report problems.
+	for _, err := range problems {
+		fmt.Fprintln(cmd.Stdout, err)
+	}
+	if len(problems) > 0 {
+		return ErrConfigInvalid
+	}
+
+	fmt.Fprintln(cmd.Stdout, "ok")
+	return nil
+}
+
+// verify checks the credentials in c against Twitter & GitHub.
+func (cmd *ConfigCheckCommand) verify(c *Config) []error {
+	var a []error
+
+	// Send requests through the configured proxy & settings.
+	transport, err := scuttlebutt.NewHTTPTransport(c.HTTPOptions())
+	if err != nil {
+		return []error{fmt.Errorf("http: %s", err)}
+	}
+
+	// Verify application credentials via the search rate limit endpoint.
+	p := twitter.NewPoller()
+	p.Client = c.NewTwitterClient(transport)
+	if err := p.VerifyCredentials(); err != nil {
+		a = append(a, fmt.Errorf("twitter: %s", err))
+	}
+
+	// Verify each account's tokens. OAuth 2.0 accounts are skipped since
+	// refreshing their token would invalidate the daemon's refresh token.
+	for _, acc := range c.Accounts {
+		if acc.RefreshToken != "" {
+			continue
+		}
+
+		n := twitter.NewNotifier()
+		n.Username = acc.Username
+		n.Client = c.NewAccountClient(acc, nil, transport)
+		if err := n.VerifyCredentials(); err != nil {
+			a = append(a, fmt.Errorf("account(%s): %s", acc.Username, err))
+		}
+	}
+
+	// Verify GitHub credentials.
+	if s, err := c.NewGitHubStore("", transport); err != nil {
+		a = append(a, fmt.Errorf("github: %s", err))
+	} else if remaining, limit, err := s.RateLimit(); err != nil {
+		a = append(a, fmt.Errorf("github: %s", err))
+	} else {
+		fmt.Fprintf(cmd.Stdout, "github: %d/%d requests remaining\n", remaining, limit)
+	}
+
+	return a
+}
@@ -0,0 +1,1203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/gitea"
+	"github.com/benbjohnson/scuttlebutt/github"
+	"github.com/benbjohnson/scuttlebutt/gitlab"
+	"github.com/benbjohnson/scuttlebutt/shortener"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+	"github.com/burntsushi/toml"
+	"github.com/kurrik/oauth1a"
+	"github.com/kurrik/twittergo"
+)
+
+// Config represents the configuration.
+type Config struct {
+	// Time between Twitter polling, account notifications, and notification
+	// checks. Defaults are used when not set.
+	PollInterval        Duration `toml:"poll_interval"`
+	NotifyInterval      Duration `toml:"notify_interval"`
+	NotifyCheckInterval Duration `toml:"notify_check_interval"`
+
+	// Maximum time between polls while backing off from poll errors.
+	MaxPollBackoff Duration `toml:"max_poll_backoff"`
+
+	// Time before a repository or identical notification text can be
+	// featured again by any account. Defaults are used when not set.
+	FeaturedWindow Duration `toml:"featured_window"`
+
+	// Additional language aliases, such as golang = "Go". Aliases are
+	// merged over the defaults and applied to repositories, accounts, and
+	// routing rules so mentions are not split across variant labels.
+	LanguageAliases map[string]string `toml:"language_aliases"`
+
+	// Weight of a mention by message source, such as twitter = 1.0 and
+	// hn = 2.0, when scoring repositories overall. Pushed messages use the
+	// source name they were pushed with. Unlisted sources have a weight of 1.
+	SourceWeights map[string]float64 `toml:"source_weights"`
+
+	// Repositories whose owners have asked not to be featured, such as
+	// "owner" or "owner/repo". These are never fetched, ranked, or notified
+	// and matching data is purged on startup.
+	OptOuts []string `toml:"opt_outs"`
+
+	// Minimum activity required before a repository is notified.
+	// Zero values disable a threshold. Repositories can also be required to
+	// have a description and one of the allowed license keys, such as "mit"
+	// or "apache-2.0".
+	Thresholds struct {
+		MinMentions        int      `toml:"min_mentions"`
+		MinAuthors         int      `toml:"min_authors"`
+		MinStars           int      `toml:"min_stars"`
+		RequireDescription bool     `toml:"require_description"`
+		AllowedLicenses    []string `toml:"allowed_licenses"`
+	} `toml:"thresholds"`
+
+	Twitter struct {
+		Key    string `toml:"key"`
+		Secret string `toml:"secret"`
+
+		// OAuth 2.0 client ID & secret, required by accounts that
+		// authenticate with a refresh token. The secret is only set for
+		// confidential clients.
+		ClientID     string `toml:"client_id"`
+		ClientSecret string `toml:"client_secret"`
+
+		// Time between searches. Defaults to the global poll_interval.
+		PollInterval Duration `toml:"poll_interval"`
+
+		// Search queries, such as "github.com -filter:retweets".
+		// Defaults to searching for links to the remote hosts.
+		Queries []string `toml:"queries"`
+
+		// Retweet & quote tweet handling. Collapsed retweets count once
+		// toward the original tweet's mention. See twitter.Poller.
+		ExcludeRetweets  bool `toml:"exclude_retweets"`
+		CollapseRetweets bool `toml:"collapse_retweets"`
+		IncludeQuotes    bool `toml:"include_quotes"`
+
+		// Maximum search result pages read per query on each poll.
+		// Defaults to twitter.DefaultMaxPages.
+		MaxPages int `toml:"max_pages"`
+
+		// If true, shortened links in tweets are followed before
+		// extracting repositories. Shortener hosts default to
+		// twitter.DefaultShortenerHosts.
+		ResolveURLs    bool     `toml:"resolve_urls"`
+		ShortenerHosts []string `toml:"shortener_hosts"`
+	} `toml:"twitter"`
+
+	GitHub struct {
+		Token string `toml:"token"`
+
+		// Authenticates as a GitHub App installation instead of with a
+		// token. The private key is the PEM file from the app's settings.
+		AppID          int64  `toml:"app_id"`
+		InstallationID int64  `toml:"installation_id"`
+		PrivateKeyPath string `toml:"private_key_path"`
+
+		// Maximum new repository lookups per poll cycle. Zero is unlimited.
+		LookupLimit int `toml:"lookup_limit"`
+
+		// Directory for caching API responses, which are revalidated with
+		// conditional requests that do not count against the rate limit.
+		// Relative paths are within the data directory. Disabled if blank.
+		CacheDir string `toml:"cache_dir"`
+
+		// Retrieves new repositories in batches using the GraphQL API
+		// instead of one REST request per repository. The cache
+		// directory is not used for GraphQL requests.
+		GraphQL bool `toml:"graphql"`
+	} `toml:"github"`
+
+	// Periodically refetches repository metadata, such as languages that
+	// were unknown when a repository was first seen. Disabled if the
+	// interval is not set. Defaults are used for the age & limit.
+	Refresh struct {
+		Interval Duration `toml:"interval"`
+		Age      Duration `toml:"age"`
+		Limit    int      `toml:"limit"`
+	} `toml:"refresh"`
+
+	// Persistent queue for messages that failed with a transient error,
+	// such as a network error or an exhausted quota. Failed messages are
+	// retried in memory if disabled. Defaults are used if not set.
+	Retry struct {
+		Disabled    bool     `toml:"disabled"`
+		Interval    Duration `toml:"interval"`
+		Limit       int      `toml:"limit"`
+		MaxAttempts int      `toml:"max_attempts"`
+	} `toml:"retry"`
+
+	// Periodically fetches like, retweet, & impression counts for recently
+	// sent notifications. Disabled if the interval is not set. The default
+	// window is used if not set.
+	Engagement struct {
+		Interval Duration `toml:"interval"`
+		Window   Duration `toml:"window"`
+	} `toml:"engagement"`
+
+	// Daily snapshots of the top repositories per language, served by the
+	// history API. Defaults are used for the interval & count if not set.
+	Snapshot struct {
+		Disabled bool     `toml:"disabled"`
+		Interval Duration `toml:"interval"`
+		N        int      `toml:"n"`
+	} `toml:"snapshot"`
+
+	// Ingestion pipeline between pollers & the store. Polled messages are
+	// saved during the poll if disabled. Defaults are used for the queue
+	// size, batch size, & batch delay if not set.
+	Pipeline struct {
+		Disabled   bool     `toml:"disabled"`
+		QueueSize  int      `toml:"queue_size"`
+		BatchSize  int      `toml:"batch_size"`
+		BatchDelay Duration `toml:"batch_delay"`
+	} `toml:"pipeline"`
+
+	// Optional URL shortener applied to repository URLs in notifications.
+	// The provider is either "bitly" or "yourls". YOURLS requires the URL of
+	// its API endpoint and uses the token as its signature.
+	Shortener struct {
+		Provider string `toml:"provider"`
+		URL      string `toml:"url"`
+		Token    string `toml:"token"`
+	} `toml:"shortener"`
+
+	// HTTP access logging. Lines are written to the path, relative to the
+	// data directory, or to stderr if no path is set. The format is
+	// "common", "combined", or "json".
+	AccessLog struct {
+		Enabled bool   `toml:"enabled"`
+		Path    string `toml:"path"`
+		Format  string `toml:"format"`
+	} `toml:"access_log"`
+
+	// If true, HTTP responses are not gzip encoded for clients that accept it.
+	DisableCompression bool `toml:"disable_compression"`
+
+	// Optional bind address of the gRPC API, such as ":9090". AddMessage calls
+	// must authenticate with the ingest or admin token.
+	GRPCAddr string `toml:"grpc_addr"`
+
+	// Optional OpenTelemetry tracing. Spans are exported to the OTLP/HTTP
+	// traces endpoint, such as "http://localhost:4318/v1/traces".
+	Tracing struct {
+		Endpoint    string `toml:"endpoint"`
+		ServiceName string `toml:"service_name"`
+	} `toml:"tracing"`
+
+	// Optional statsd agent that pipeline metrics are sent to, such as
+	// "localhost:8125". Tags use the DogStatsD format, such as "env:prod".
+	Statsd struct {
+		Addr   string   `toml:"addr"`
+		Prefix string   `toml:"prefix"`
+		Tags   []string `toml:"tags"`
+	} `toml:"statsd"`
+
+	// Optional Sentry project that poll, notify, & store errors are
+	// reported to.
+	Sentry struct {
+		DSN         string `toml:"dsn"`
+		Environment string `toml:"environment"`
+	} `toml:"sentry"`
+
+	// Optional endpoint that external scrapers push messages to at
+	// POST /ingest. Requests authenticate with the token as a bearer token.
+	// The endpoint is disabled if no token is set.
+	Ingest struct {
+		Token string `toml:"token"`
+	} `toml:"ingest"`
+
+	// Optional token that requests to the /admin endpoints authenticate
+	// with as a bearer token. The admin endpoints are disabled if no token
+	// is set.
+	Admin struct {
+		Token string `toml:"token"`
+	} `toml:"admin"`
+
+	// Optional webhook posted to when a notification is sent. The body is
+	// signed with the secret, if set.
+	Webhook struct {
+		URL    string `toml:"url"`
+		Secret string `toml:"secret"`
+	} `toml:"webhook"`
+
+	// Optional leader election between replicas so only the leader polls,
+	// notifies, & refreshes. The mode is "file", which locks a file at the
+	// path relative to the data directory, or "consul", which locks a key
+	// through the Consul agent at addr. The TTL is the time before a failed
+	// leader's Consul session expires.
+	Election struct {
+		Mode  string   `toml:"mode"`
+		Path  string   `toml:"path"`
+		Addr  string   `toml:"addr"`
+		Key   string   `toml:"key"`
+		TTL   Duration `toml:"ttl"`
+		Token string   `toml:"token"`
+	} `toml:"election"`
+
+	// Outbound HTTP settings shared by the Twitter, GitHub, and other
+	// remote clients. The proxy may use an http, https, or socks5 scheme
+	// and is read from the environment if blank.
+	HTTP struct {
+		Proxy               string   `toml:"proxy"`
+		UserAgent           string   `toml:"user_agent"`
+		Timeout             Duration `toml:"timeout"`
+		MaxIdleConns        int      `toml:"max_idle_conns"`
+		MaxIdleConnsPerHost int      `toml:"max_idle_conns_per_host"`
+		MaxConnsPerHost     int      `toml:"max_conns_per_host"`
+		IdleConnTimeout     Duration `toml:"idle_conn_timeout"`
+	} `toml:"http"`
+
+	// Circuit breakers wrapping the Twitter, GitHub, and other remote API
+	// clients. Each upstream stops receiving requests after consecutive
+	// failures until the reset timeout passes. Defaults are used if zero.
+	CircuitBreaker struct {
+		Disabled         bool     `toml:"disabled"`
+		Timeout          Duration `toml:"timeout"`
+		FailureThreshold int      `toml:"failure_threshold"`
+		ResetTimeout     Duration `toml:"reset_timeout"`
+		HalfOpenProbes   int      `toml:"half_open_probes"`
+	} `toml:"circuit_breaker"`
+
+	// Bolt database file settings. The open timeout uses the store default
+	// if not set. Disabling syncs trades durability after a crash for write
+	// throughput. Read-only databases must already be initialized.
+	Storage struct {
+		Timeout    Duration `toml:"timeout"`
+		NoSync     bool     `toml:"no_sync"`
+		NoGrowSync bool     `toml:"no_grow_sync"`
+		ReadOnly   bool     `toml:"read_only"`
+	} `toml:"storage"`
+
+	// Local data store settings.
+	Store struct {
+		// Coalesces concurrent writes into fewer transactions. Ignored if
+		// message_path is set.
+		Batch         bool     `toml:"batch"`
+		MaxBatchSize  int      `toml:"max_batch_size"`
+		MaxBatchDelay Duration `toml:"max_batch_delay"`
+
+		// Separate file for message data, such as on faster local disk.
+		// Relative paths are within the data directory.
+		MessagePath string `toml:"message_path"`
+
+		// Includes forks, archived, or disabled repositories in rankings.
+		IncludeForks    bool `toml:"include_forks"`
+		IncludeArchived bool `toml:"include_archived"`
+		IncludeDisabled bool `toml:"include_disabled"`
+
+		// Time that repositories missing from GitHub are cached before
+		// being looked up again. Uses the store default if not set.
+		NotFoundTTL Duration `toml:"not_found_ttl"`
+
+		// Number of repositories looked up on GitHub at the same time.
+		// Uses the store default if not set.
+		LookupConcurrency int `toml:"lookup_concurrency"`
+	} `toml:"store"`
+
+	// Heuristics for dropping mentions that are likely spam.
+	Spam struct {
+		// Drops mentions by the repository's owner.
+		DropSelfPromotion bool `toml:"drop_self_promotion"`
+
+		// Maximum mentions of a repository counted per author.
+		MaxAuthorMentions int `toml:"max_author_mentions"`
+
+		// Regular expressions matched against author screen names.
+		BotPatterns []string `toml:"bot_patterns"`
+
+		// Drops mentions by accounts younger than the minimum days or
+		// with fewer than the minimum followers. Disabled if zero.
+		MinAuthorAgeDays   int `toml:"min_author_age_days"`
+		MinAuthorFollowers int `toml:"min_author_followers"`
+
+		// Counts repeated mentions of a repository by the same author or
+		// with the same text within the window once. Disabled if zero.
+		DuplicateWindow Duration `toml:"duplicate_window"`
+
+		// Drops mentions scoring below min_sentiment, from -1 (negative)
+		// to 1 (positive), using a word lexicon. The minimum defaults to
+		// DefaultMinSentiment if zero. Words are added to the lexicon's
+		// defaults.
+		Sentiment     bool     `toml:"sentiment"`
+		MinSentiment  float64  `toml:"min_sentiment"`
+		PositiveWords []string `toml:"positive_words"`
+		NegativeWords []string `toml:"negative_words"`
+	} `toml:"spam"`
+
+	// Blocklist applied to repository names and descriptions before
+	// posting. Blocked repositories are flagged for review at /flagged.
+	ContentFilter struct {
+		// Words matched case insensitively against whole words.
+		Words []string `toml:"words"`
+
+		// Regular expressions matched against names and descriptions.
+		Patterns []string `toml:"patterns"`
+	} `toml:"content_filter"`
+
+	Accounts []*Account `toml:"account"`
+
+	// Additional hosts whose repositories are tracked, such as GitLab,
+	// Gitea/Codeberg, or GitHub Enterprise instances.
+	Remotes []*Remote `toml:"remote"`
+
+	// Routing rules. Accounts not targeted by a rule use their language.
+	Rules []*Rule `toml:"rule"`
+
+	// Hashtags appended to notifications.
+	Hashtags struct {
+		Enabled bool `toml:"enabled"`
+
+		// Hashtags by language. Other languages use the language name.
+		Languages map[string][]string `toml:"languages"`
+	} `toml:"hashtags"`
+}
+
+// Validate returns a list of problems with the configuration.
+func (c *Config) Validate() []error {
+	var a []error
+	if c.PollInterval < 0 {
+		a = append(a, errors.New("poll_interval must not be negative"))
+	}
+	if c.MaxPollBackoff < 0 {
+		a = append(a, errors.New("max_poll_backoff must not be negative"))
+	}
+	if c.NotifyInterval < 0 {
+		a = append(a, errors.New("notify_interval must not be negative"))
+	}
+	if c.NotifyCheckInterval < 0 {
+		a = append(a, errors.New("notify_check_interval must not be negative"))
+	}
+	if c.FeaturedWindow < 0 {
+		a = append(a, errors.New("featured_window must not be negative"))
+	}
+	if c.Thresholds.MinMentions < 0 || c.Thresholds.MinAuthors < 0 || c.Thresholds.MinStars < 0 {
+		a = append(a, errors.New("thresholds: minimums must not be negative"))
+	}
+	for _, license := range c.Thresholds.AllowedLicenses {
+		if strings.TrimSpace(license) == "" {
+			a = append(a, errors.New("thresholds: allowed_licenses must not contain blank keys"))
+			break
+		}
+	}
+	if c.Twitter.Key == "" {
+		a = append(a, errors.New("twitter: key required"))
+	}
+	if c.Twitter.Secret == "" {
+		a = append(a, errors.New("twitter: secret required"))
+	}
+	if c.Twitter.PollInterval < 0 {
+		a = append(a, errors.New("twitter: poll_interval must not be negative"))
+	}
+	if f := c.AccessLog.Format; f != "" && !scuttlebutt.ValidAccessLogFormat(f) {
+		a = append(a, fmt.Errorf("access_log: invalid format: %s", f))
+	}
+	if c.GitHub.AppID != 0 {
+		if c.GitHub.InstallationID == 0 {
+			a = append(a, errors.New("github: installation_id required for app"))
+		}
+		if c.GitHub.PrivateKeyPath == "" {
+			a = append(a, errors.New("github: private_key_path required for app"))
+		}
+	} else if c.GitHub.Token == "" {
+		a = append(a, errors.New("github: token required"))
+	}
+	if c.GitHub.LookupLimit < 0 {
+		a = append(a, errors.New("github: lookup_limit must not be negative"))
+	}
+	if c.Refresh.Interval < 0 || c.Refresh.Age < 0 || c.Refresh.Limit < 0 {
+		a = append(a, errors.New("refresh: interval, age, and limit must not be negative"))
+	}
+	if c.Retry.Interval < 0 || c.Retry.Limit < 0 || c.Retry.MaxAttempts < 0 {
+		a = append(a, errors.New("retry: interval, limit, and max_attempts must not be negative"))
+	}
+	if c.Engagement.Interval < 0 || c.Engagement.Window < 0 {
+		a = append(a, errors.New("engagement: interval and window must not be negative"))
+	}
+	if c.Snapshot.Interval < 0 || c.Snapshot.N < 0 {
+		a = append(a, errors.New("snapshot: interval and n must not be negative"))
+	}
+	if c.Pipeline.QueueSize < 0 || c.Pipeline.BatchSize < 0 || c.Pipeline.BatchDelay < 0 {
+		a = append(a, errors.New("pipeline: queue_size, batch_size, and batch_delay must not be negative"))
+	}
+	switch c.Shortener.Provider {
+	case "":
+	case "bitly", "yourls":
+		if c.Shortener.Token == "" {
+			a = append(a, errors.New("shortener: token required"))
+		}
+		if c.Shortener.Provider == "yourls" && c.Shortener.URL == "" {
+			a = append(a, errors.New("shortener: url required"))
+		}
+	default:
+		a = append(a, fmt.Errorf("shortener: unknown provider: %s", c.Shortener.Provider))
+	}
+	switch c.Election.Mode {
+	case "", "file":
+	case "consul":
+		if c.Election.Key == "" {
+			a = append(a, errors.New("election: key required"))
+		}
+		if c.Election.TTL != 0 && (c.Election.TTL < Duration(10*time.Second) || c.Election.TTL > Duration(24*time.Hour)) {
+			a = append(a, errors.New("election: ttl must be between 10s and 24h"))
+		}
+	default:
+		a = append(a, fmt.Errorf("election: unknown mode: %s", c.Election.Mode))
+	}
+	if c.HTTP.Timeout < 0 || c.HTTP.IdleConnTimeout < 0 {
+		a = append(a, errors.New("http: timeout and idle_conn_timeout must not be negative"))
+	}
+	if c.HTTP.MaxIdleConns < 0 || c.HTTP.MaxIdleConnsPerHost < 0 || c.HTTP.MaxConnsPerHost < 0 {
+		a = append(a, errors.New("http: connection limits must not be negative"))
+	}
+	if _, err := scuttlebutt.NewHTTPTransport(c.HTTPOptions()); err != nil {
+		a = append(a, fmt.Errorf("http: %s", err))
+	}
+	if cb := c.CircuitBreaker; cb.Timeout < 0 || cb.FailureThreshold < 0 || cb.ResetTimeout < 0 || cb.HalfOpenProbes < 0 {
+		a = append(a, errors.New("circuit_breaker: timeout, failure_threshold, reset_timeout, and half_open_probes must not be negative"))
+	}
+	if c.Storage.Timeout < 0 {
+		a = append(a, errors.New("storage: timeout must not be negative"))
+	}
+	if c.Store.MaxBatchSize < 0 {
+		a = append(a, errors.New("store: max_batch_size must not be negative"))
+	}
+	if c.Store.MaxBatchDelay < 0 {
+		a = append(a, errors.New("store: max_batch_delay must not be negative"))
+	}
+	if c.Store.NotFoundTTL < 0 {
+		a = append(a, errors.New("store: not_found_ttl must not be negative"))
+	}
+	if c.Store.LookupConcurrency < 0 {
+		a = append(a, errors.New("store: lookup_concurrency must not be negative"))
+	}
+	if c.Spam.MaxAuthorMentions < 0 {
+		a = append(a, errors.New("spam: max_author_mentions must not be negative"))
+	}
+	if c.Spam.MinAuthorAgeDays < 0 || c.Spam.MinAuthorFollowers < 0 {
+		a = append(a, errors.New("spam: author minimums must not be negative"))
+	}
+	for source, w := range c.SourceWeights {
+		if w < 0 {
+			a = append(a, fmt.Errorf("source_weights: %s must not be negative", source))
+		}
+	}
+	if c.Spam.DuplicateWindow < 0 {
+		a = append(a, errors.New("spam: duplicate_window must not be negative"))
+	}
+	if c.Spam.MinSentiment < -1 || c.Spam.MinSentiment > 1 {
+		a = append(a, errors.New("spam: min_sentiment must be between -1 and 1"))
+	}
+	if _, err := c.SpamFilter(); err != nil {
+		a = append(a, fmt.Errorf("spam: %s", err))
+	}
+	if _, err := c.NewContentFilter(); err != nil {
+		a = append(a, fmt.Errorf("content_filter: %s", err))
+	}
+	for _, pattern := range c.OptOuts {
+		if !scuttlebutt.ValidOptOutPattern(pattern) {
+			a = append(a, fmt.Errorf("opt_outs: invalid pattern: %q", pattern))
+		}
+	}
+
+	usernames := make(map[string]bool)
+	for i, acc := range c.Accounts {
+		for _, err := range acc.Validate() {
+			a = append(a, fmt.Errorf("account[%d]: %s", i, err))
+		}
+
+		if acc.RefreshToken != "" && c.Twitter.ClientID == "" {
+			a = append(a, fmt.Errorf("account[%d]: twitter client_id required for refresh_token", i))
+		}
+
+		// Ensure usernames are only used once.
+		if acc.Username != "" && usernames[acc.Username] {
+			a = append(a, fmt.Errorf("account[%d]: duplicate username: %s", i, acc.Username))
+		}
+		usernames[acc.Username] = true
+	}
+
+	hosts := map[string]bool{"github.com": true}
+	for i, r := range c.Remotes {
+		for _, err := range r.Validate() {
+			a = append(a, fmt.Errorf("remote[%d]: %s", i, err))
+		}
+
+		// Ensure hosts are only configured once.
+		if r.Host != "" && hosts[strings.ToLower(r.Host)] {
+			a = append(a, fmt.Errorf("remote[%d]: duplicate host: %s", i, r.Host))
+		}
+		hosts[strings.ToLower(r.Host)] = true
+	}
+
+	for i, rule := range c.Rules {
+		if len(rule.Accounts) == 0 {
+			a = append(a, fmt.Errorf("rule[%d]: accounts required", i))
+		}
+		for _, username := range rule.Accounts {
+			if !usernames[username] {
+				a = append(a, fmt.Errorf("rule[%d]: unknown account: %s", i, username))
+			}
+		}
+	}
+
+	return a
+}
+
+// Languages returns the language normalization table, including defaults.
+func (c *Config) Languages() scuttlebutt.LanguageAliases {
+	return scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases, c.LanguageAliases)
+}
+
+// Router returns a router for the configured rules.
+// Rule languages are normalized.
+func (c *Config) Router() *scuttlebutt.Router {
+	aliases := c.Languages()
+
+	rt := &scuttlebutt.Router{}
+	for _, rule := range c.Rules {
+		var languages []string
+		for _, lang := range rule.Languages {
+			languages = append(languages, aliases.Normalize(lang))
+		}
+
+		rt.Rules = append(rt.Rules, &scuttlebutt.Rule{
+			Name:        rule.Name,
+			Priority:    rule.Priority,
+			Languages:   languages,
+			Tags:        rule.Tags,
+			MinStars:    rule.MinStars,
+			MinMentions: rule.MinMentions,
+			MinScore:    rule.MinScore,
+			Targets:     rule.Accounts,
+		})
+	}
+	return rt
+}
+
+// SpamFilter returns a spam filter for the configured heuristics.
+// Returns nil if no heuristics are enabled.
+func (c *Config) SpamFilter() (*scuttlebutt.SpamFilter, error) {
+	if !c.Spam.DropSelfPromotion && c.Spam.MaxAuthorMentions <= 0 && len(c.Spam.BotPatterns) == 0 && !c.Spam.Sentiment &&
+		c.Spam.MinAuthorAgeDays <= 0 && c.Spam.MinAuthorFollowers <= 0 && c.Spam.DuplicateWindow <= 0 {
+		return nil, nil
+	}
+
+	f := &scuttlebutt.SpamFilter{
+		DropSelfPromotion:  c.Spam.DropSelfPromotion,
+		MaxAuthorMentions:  c.Spam.MaxAuthorMentions,
+		MinAuthorAge:       time.Duration(c.Spam.MinAuthorAgeDays) * 24 * time.Hour,
+		MinAuthorFollowers: c.Spam.MinAuthorFollowers,
+		DuplicateWindow:    time.Duration(c.Spam.DuplicateWindow),
+	}
+	if c.Spam.Sentiment {
+		f.Sentiment = scuttlebutt.NewLexicon(
+			append(append([]string(nil), scuttlebutt.DefaultPositiveWords...), c.Spam.PositiveWords...),
+			append(append([]string(nil), scuttlebutt.DefaultNegativeWords...), c.Spam.NegativeWords...),
+		)
+		f.MinSentiment = scuttlebutt.DefaultMinSentiment
+		if c.Spam.MinSentiment != 0 {
+			f.MinSentiment = c.Spam.MinSentiment
+		}
+	}
+	for _, pattern := range c.Spam.BotPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bot pattern: %s", err)
+		}
+		f.BotPatterns = append(f.BotPatterns, re)
+	}
+	return f, nil
+}
+
+// NewThresholds returns the configured notification thresholds.
+func (c *Config) NewThresholds() scuttlebutt.Thresholds {
+	return scuttlebutt.Thresholds{
+		MinMentions:        c.Thresholds.MinMentions,
+		MinAuthors:         c.Thresholds.MinAuthors,
+		MinStars:           c.Thresholds.MinStars,
+		RequireDescription: c.Thresholds.RequireDescription,
+		AllowedLicenses:    c.Thresholds.AllowedLicenses,
+	}
+}
+
+// NewContentFilter returns a content filter for the configured blocklist.
+// Returns nil if no words or patterns are configured.
+func (c *Config) NewContentFilter() (*scuttlebutt.ContentFilter, error) {
+	if len(c.ContentFilter.Words) == 0 && len(c.ContentFilter.Patterns) == 0 {
+		return nil, nil
+	}
+
+	f := &scuttlebutt.ContentFilter{Words: c.ContentFilter.Words}
+	for _, pattern := range c.ContentFilter.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %s", err)
+		}
+		f.Patterns = append(f.Patterns, re)
+	}
+	return f, nil
+}
+
+// HTTPOptions returns the settings for outbound HTTP clients.
+func (c *Config) HTTPOptions() scuttlebutt.HTTPOptions {
+	return scuttlebutt.HTTPOptions{
+		Proxy:               c.HTTP.Proxy,
+		UserAgent:           c.HTTP.UserAgent,
+		Timeout:             time.Duration(c.HTTP.Timeout),
+		MaxIdleConns:        c.HTTP.MaxIdleConns,
+		MaxIdleConnsPerHost: c.HTTP.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     c.HTTP.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(c.HTTP.IdleConnTimeout),
+	}
+}
+
+// NewCircuitBreaker wraps transport in a circuit breaker for the named
+// upstream. Returns transport if circuit breakers are disabled.
+func (c *Config) NewCircuitBreaker(name string, transport http.RoundTripper) http.RoundTripper {
+	cb := c.CircuitBreaker
+	if cb.Disabled {
+		return transport
+	}
+
+	b := scuttlebutt.NewCircuitBreaker(name, transport)
+	if cb.Timeout > 0 {
+		b.Timeout = time.Duration(cb.Timeout)
+	}
+	if cb.FailureThreshold > 0 {
+		b.FailureThreshold = cb.FailureThreshold
+	}
+	if cb.ResetTimeout > 0 {
+		b.ResetTimeout = time.Duration(cb.ResetTimeout)
+	}
+	if cb.HalfOpenProbes > 0 {
+		b.HalfOpenProbes = cb.HalfOpenProbes
+	}
+	return b
+}
+
+// NewGitHubStore returns the GitHub remote store, authenticated as an app
+// if one is configured. Responses are cached in cacheDir, if specified.
+// Requests are sent through transport, or the default transport if nil.
+func (c *Config) NewGitHubStore(cacheDir string, transport http.RoundTripper) (*github.Store, error) {
+	if c.GitHub.AppID == 0 {
+		return github.NewStoreWithTransport(c.GitHub.Token, cacheDir, transport), nil
+	}
+
+	t, err := c.newGitHubAppTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	return github.NewAppStore(t, cacheDir), nil
+}
+
+// NewGitHubGraphQLStore returns the GitHub GraphQL remote store,
+// authenticated as an app if one is configured. Requests are sent through
+// transport, or the default transport if nil.
+func (c *Config) NewGitHubGraphQLStore(transport http.RoundTripper) (*github.GraphQLStore, error) {
+	if c.GitHub.AppID == 0 {
+		s := github.NewGraphQLStore(c.GitHub.Token)
+		s.HTTPClient = &http.Client{Transport: transport}
+		return s, nil
+	}
+
+	t, err := c.newGitHubAppTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	s := github.NewGraphQLStore("")
+	s.HTTPClient = &http.Client{Transport: t}
+	return s, nil
+}
+
+// newGitHubAppTransport returns a transport authenticated as the app that
+// sends requests through transport.
+func (c *Config) newGitHubAppTransport(transport http.RoundTripper) (*github.AppTransport, error) {
+	key, err := ioutil.ReadFile(c.GitHub.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	t, err := github.NewAppTransport(c.GitHub.AppID, c.GitHub.InstallationID, key)
+	if err != nil {
+		return nil, err
+	}
+	t.Transport = transport
+	return t, nil
+}
+
+// NewTwitterClient returns an application-only Twitter client that sends
+// requests through transport, or the default transport if nil.
+func (c *Config) NewTwitterClient(transport http.RoundTripper) *twittergo.Client {
+	client := twittergo.NewClient(&oauth1a.ClientConfig{
+		ConsumerKey:    c.Twitter.Key,
+		ConsumerSecret: c.Twitter.Secret,
+	}, nil)
+	if transport != nil {
+		client.HttpClient = &http.Client{Transport: transport}
+	}
+	return client
+}
+
+// NewAccountClient returns the Twitter client for an account. Accounts with
+// a refresh token use OAuth 2.0 and keep refreshed tokens in store.
+// Requests are sent through transport, or the default transport if nil.
+func (c *Config) NewAccountClient(acc *Account, store *scuttlebutt.Store, transport http.RoundTripper) interface {
+	SendRequest(*http.Request) (*twittergo.APIResponse, error)
+} {
+	if acc.RefreshToken == "" {
+		client := c.NewTwitterClient(transport)
+		client.SetUser(oauth1a.NewAuthorizedConfig(acc.Key, acc.Secret))
+		return client
+	}
+
+	client := twitter.NewOAuth2Client()
+	client.Username = acc.Username
+	client.ClientID = c.Twitter.ClientID
+	client.ClientSecret = c.Twitter.ClientSecret
+	client.RefreshToken = acc.RefreshToken
+	if store != nil {
+		client.Store = store
+	}
+	if transport != nil {
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+	return client
+}
+
+// NewShortener returns the configured URL shortener.
+// Returns nil if no provider is configured.
+func (c *Config) NewShortener() scuttlebutt.Shortener {
+	switch c.Shortener.Provider {
+	case "bitly":
+		s := shortener.NewBitly(c.Shortener.Token)
+		if c.Shortener.URL != "" {
+			s.URL = c.Shortener.URL
+		}
+		return s
+	case "yourls":
+		return shortener.NewYOURLS(c.Shortener.URL, c.Shortener.Token)
+	default:
+		return nil
+	}
+}
+
+// NewStore returns a store in dataDir with the configured storage, message
+// path, language aliases, ranking, and opt-out settings. Relative message
+// paths are within dataDir. The store is not opened.
+func (c *Config) NewStore(dataDir string) *scuttlebutt.Store {
+	s := scuttlebutt.NewStore(filepath.Join(dataDir, "db"))
+	if timeout := c.Storage.Timeout; timeout > 0 {
+		s.OpenTimeout = time.Duration(timeout)
+	}
+	s.NoSync = c.Storage.NoSync
+	s.NoGrowSync = c.Storage.NoGrowSync
+	s.ReadOnly = c.Storage.ReadOnly
+
+	if path := c.Store.MessagePath; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dataDir, path)
+		}
+		s.MessagePath = path
+	}
+	s.IncludeForks = c.Store.IncludeForks
+	s.IncludeArchived = c.Store.IncludeArchived
+	s.IncludeDisabled = c.Store.IncludeDisabled
+	s.LanguageAliases = c.Languages()
+	s.OptOutPatterns = c.OptOuts
+	s.SourceWeights = c.SourceWeights
+
+	thresholds := c.NewThresholds()
+	s.Thresholds = &thresholds
+	return s
+}
+
+// ParseConfigFile parses the contents of path into a Config.
+func ParseConfigFile(path string) (*Config, error) {
+	c, _, err := decodeConfigFile(path)
+	return c, err
+}
+
+// parseOptionalConfigFile parses the contents of path into a Config. Returns
+// the default config if path is blank.
+func parseOptionalConfigFile(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+	return ParseConfigFile(path)
+}
+
+// decodeConfigFile parses the contents of path into a Config.
+// Environment variables are interpolated into the file and then applied as
+// overrides. Also returns the TOML metadata so callers can inspect undecoded keys.
+func decodeConfigFile(path string) (*Config, toml.MetaData, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, toml.MetaData{}, err
+	}
+
+	// Replace ${VAR} references with environment values.
+	data, err := interpolateEnv(string(buf), os.Getenv)
+	if err != nil {
+		return nil, toml.MetaData{}, err
+	}
+
+	c := &Config{}
+	md, err := toml.Decode(data, &c)
+	if err != nil {
+		return nil, md, err
+	}
+	c.ApplyEnv(os.Getenv)
+
+	return c, md, nil
+}
+
+// ApplyEnv overrides secrets with values from environment variables, if set.
+//
+// Recognized variables are SCUTTLEBUTT_TWITTER_KEY, SCUTTLEBUTT_TWITTER_SECRET,
+// SCUTTLEBUTT_TWITTER_CLIENT_SECRET, SCUTTLEBUTT_GITHUB_TOKEN, SCUTTLEBUTT_SHORTENER_TOKEN,
+// SCUTTLEBUTT_ADMIN_TOKEN, and SCUTTLEBUTT_ACCOUNT_<USERNAME>_KEY, SCUTTLEBUTT_ACCOUNT_<USERNAME>_SECRET, and
+// SCUTTLEBUTT_ACCOUNT_<USERNAME>_REFRESH_TOKEN for each account.
+func (c *Config) ApplyEnv(getenv func(string) string) {
+	setenv(&c.Twitter.Key, getenv("SCUTTLEBUTT_TWITTER_KEY"))
+	setenv(&c.Twitter.Secret, getenv("SCUTTLEBUTT_TWITTER_SECRET"))
+	setenv(&c.Twitter.ClientSecret, getenv("SCUTTLEBUTT_TWITTER_CLIENT_SECRET"))
+	setenv(&c.GitHub.Token, getenv("SCUTTLEBUTT_GITHUB_TOKEN"))
+	setenv(&c.Shortener.Token, getenv("SCUTTLEBUTT_SHORTENER_TOKEN"))
+	setenv(&c.Admin.Token, getenv("SCUTTLEBUTT_ADMIN_TOKEN"))
+
+	for _, acc := range c.Accounts {
+		prefix := "SCUTTLEBUTT_ACCOUNT_" + envName(acc.Username) + "_"
+		setenv(&acc.Key, getenv(prefix+"KEY"))
+		setenv(&acc.Secret, getenv(prefix+"SECRET"))
+		setenv(&acc.RefreshToken, getenv(prefix+"REFRESH_TOKEN"))
+	}
+}
+
+// setenv sets *dst to value if value is not blank.
+func setenv(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// envName converts s to an uppercase environment variable name segment.
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// envRegex matches ${VAR} references in a config file.
+var envRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolateEnv replaces ${VAR} references in s with values from getenv.
+// Returns an error if a referenced variable is not set.
+func interpolateEnv(s string, getenv func(string) string) (string, error) {
+	var err error
+	s = envRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRegex.FindStringSubmatch(ref)[1]
+		value := getenv(name)
+		if value == "" && err == nil {
+			err = fmt.Errorf("environment variable not set: %s", name)
+		}
+		return value
+	})
+	return s, err
+}
+
+// Remote represents a host whose repositories are tracked.
+type Remote struct {
+	// Host in repository links, such as "gitlab.com".
+	Host string `toml:"host"`
+
+	// Type of API: "github" (Enterprise), "gitlab", or "gitea".
+	Type string `toml:"type"`
+
+	// Base URL of the API. Defaults to "https://<host>" for GitLab & Gitea
+	// and to "https://<host>/api/v3/" for GitHub Enterprise.
+	URL string `toml:"url"`
+
+	// Optional API token.
+	Token string `toml:"token"`
+}
+
+// Validate returns a list of problems with the remote configuration.
+func (r *Remote) Validate() []error {
+	var a []error
+	if r.Host == "" {
+		a = append(a, errors.New("host required"))
+	} else if strings.Contains(r.Host, "/") {
+		a = append(a, fmt.Errorf("invalid host: %s", r.Host))
+	}
+	switch r.Type {
+	case "github", "gitlab", "gitea":
+	case "":
+		a = append(a, errors.New("type required"))
+	default:
+		a = append(a, fmt.Errorf("invalid type: %s", r.Type))
+	}
+	return a
+}
+
+// NewStore returns the remote store for the host. GitHub Enterprise
+// responses are cached in cacheDir, if specified. Requests are sent through
+// transport, or the default transport if nil.
+func (r *Remote) NewStore(cacheDir string, transport http.RoundTripper) (scuttlebutt.RemoteStore, error) {
+	u := r.URL
+	switch r.Type {
+	case "github":
+		if u == "" {
+			u = "https://" + r.Host + "/api/v3/"
+		}
+		return github.NewEnterpriseStore(r.Token, u, cacheDir, transport)
+	case "gitlab":
+		if u == "" {
+			u = "https://" + r.Host
+		}
+		s := gitlab.NewStore(u, r.Token)
+		s.HTTPClient = &http.Client{Transport: transport}
+		return s, nil
+	case "gitea":
+		if u == "" {
+			u = "https://" + r.Host
+		}
+		s := gitea.NewStore(u, r.Token)
+		s.HTTPClient = &http.Client{Transport: transport}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("invalid type: %s", r.Type)
+	}
+}
+
+// Account represents a Twitter account that tweets occassional trending repos.
+type Account struct {
+	Username string `toml:"username"`
+	Language string `toml:"language"`
+	Key      string `toml:"key"`
+	Secret   string `toml:"secret"`
+
+	// Optional OAuth 2.0 refresh token. If set, the account authenticates
+	// with OAuth 2.0 instead of the key & secret. Refreshed tokens are kept
+	// in the store so this is only used until the first refresh.
+	RefreshToken string `toml:"refresh_token"`
+
+	// Optional GitHub topic, such as "machine-learning". Topic accounts
+	// post repositories tagged with the topic and must not set a language.
+	Topic string `toml:"topic"`
+
+	// Optional regular expression matched against repository names and
+	// descriptions, such as "(?i)kubernetes|k8s". Pattern accounts post the
+	// top matching repositories and must not set a language or topic.
+	Pattern string `toml:"pattern"`
+
+	// Name of what the account posts about, used in digest headers.
+	// Required for pattern accounts that post digests.
+	Label string `toml:"label"`
+
+	// Overrides the global notify interval for this account, if set.
+	NotifyInterval Duration `toml:"notify_interval"`
+
+	// Optional schedule restricting when the account notifies. The cron
+	// expression replaces the notify interval and windows are "HH:MM-HH:MM"
+	// ranges. Both are evaluated in the timezone, which defaults to UTC.
+	Cron     string   `toml:"cron"`
+	Windows  []string `toml:"windows"`
+	Timezone string   `toml:"timezone"`
+
+	// Optional text/template for notification text.
+	Template string `toml:"template"`
+
+	// Optional weighted templates compared using notification engagement.
+	// Each repository is posted with one variant. Replaces the template.
+	Variants []*TemplateVariant `toml:"variants"`
+
+	// If true, the repository's social preview image is attached.
+	Media bool `toml:"media"`
+
+	// Number of repositories posted together as a digest, such as a daily
+	// roundup. One repository is posted at a time if zero.
+	Digest int `toml:"digest"`
+
+	// If true, notifications are queued at /pending and are only sent
+	// after being approved.
+	Moderated bool `toml:"moderated"`
+
+	Client *twittergo.Client `toml:"-"`
+}
+
+// Validate returns a list of problems with the account configuration.
+func (acc *Account) Validate() []error {
+	var a []error
+	if acc.Username == "" {
+		a = append(a, errors.New("username required"))
+	}
+	if n := acc.sourceN(); n == 0 {
+		a = append(a, errors.New("language, topic, or pattern required"))
+	} else if n > 1 {
+		a = append(a, errors.New("language, topic, and pattern are mutually exclusive"))
+	}
+	if _, err := acc.Regexp(); err != nil {
+		a = append(a, err)
+	} else if acc.Pattern != "" && acc.Digest > 0 && acc.Label == "" {
+		a = append(a, errors.New("label required for pattern digests"))
+	}
+	if acc.RefreshToken == "" {
+		if acc.Key == "" {
+			a = append(a, errors.New("key required"))
+		}
+		if acc.Secret == "" {
+			a = append(a, errors.New("secret required"))
+		}
+	} else if acc.Key != "" || acc.Secret != "" {
+		a = append(a, errors.New("key & secret must not be set with refresh_token"))
+	}
+	if acc.NotifyInterval < 0 {
+		a = append(a, errors.New("notify_interval must not be negative"))
+	}
+	if acc.Digest < 0 || acc.Digest > twitter.MaxDigestN {
+		a = append(a, fmt.Errorf("digest must be between 0 and %d", twitter.MaxDigestN))
+	}
+	if _, err := acc.Schedule(); err != nil {
+		a = append(a, err)
+	}
+	if acc.Template != "" {
+		if _, err := twitter.ParseTemplate(acc.Template); err != nil {
+			a = append(a, fmt.Errorf("invalid template: %s", err))
+		}
+	}
+	if len(acc.Variants) > 0 && acc.Template != "" {
+		a = append(a, errors.New("template and variants are mutually exclusive"))
+	}
+	names := make(map[string]bool)
+	for _, v := range acc.Variants {
+		if v.Name == "" {
+			a = append(a, errors.New("variant name required"))
+		} else if names[v.Name] {
+			a = append(a, fmt.Errorf("duplicate variant: %s", v.Name))
+		}
+		names[v.Name] = true
+
+		if v.Weight <= 0 {
+			a = append(a, fmt.Errorf("variant weight must be positive: %s", v.Name))
+		}
+		if _, err := twitter.ParseTemplate(v.Template); err != nil {
+			a = append(a, fmt.Errorf("invalid variant template: name=%s, err=%s", v.Name, err))
+		}
+	}
+	return a
+}
+
+// TemplateVariant represents a named notification template and its share
+// of notifications relative to the account's other variants.
+type TemplateVariant struct {
+	Name     string `toml:"name"`
+	Weight   int    `toml:"weight"`
+	Template string `toml:"template"`
+}
+
+// sourceN returns the number of repository sources set on the account.
+func (acc *Account) sourceN() (n int) {
+	for _, v := range []string{acc.Language, acc.Topic, acc.Pattern} {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Regexp returns the compiled pattern for the account.
+// Returns nil if the account does not specify a pattern.
+func (acc *Account) Regexp() (*regexp.Regexp, error) {
+	if acc.Pattern == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(acc.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s", err)
+	}
+	return re, nil
+}
+
+// Schedule returns the parsed schedule for the account.
+// Returns nil if the account does not specify a schedule.
+func (acc *Account) Schedule() (*scuttlebutt.Schedule, error) {
+	if acc.Cron == "" && len(acc.Windows) == 0 && acc.Timezone == "" {
+		return nil, nil
+	}
+
+	s := &scuttlebutt.Schedule{Location: time.UTC}
+
+	// Parse timezone.
+	if acc.Timezone != "" {
+		loc, err := time.LoadLocation(acc.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %s", acc.Timezone)
+		}
+		s.Location = loc
+	}
+
+	// Parse cron expression.
+	if acc.Cron != "" {
+		c, err := scuttlebutt.ParseCron(acc.Cron)
+		if err != nil {
+			return nil, err
+		}
+		s.Cron = c
+	}
+
+	// Parse windows.
+	for _, str := range acc.Windows {
+		w, err := scuttlebutt.ParseTimeWindow(str)
+		if err != nil {
+			return nil, err
+		}
+		s.Windows = append(s.Windows, w)
+	}
+
+	return s, nil
+}
+
+// Rule represents a rule routing matching repositories to accounts.
+type Rule struct {
+	Name        string   `toml:"name"`
+	Priority    int      `toml:"priority"`
+	Languages   []string `toml:"languages"`
+	Tags        []string `toml:"tags"`
+	MinStars    int      `toml:"min_stars"`
+	MinMentions int      `toml:"min_mentions"`
+	MinScore    float64  `toml:"min_score"`
+	Accounts    []string `toml:"accounts"`
+}
+
+// Duration is a helper type for unmarshaling durations in TOML.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+const (
+	// DefaultDemoRepositoryN is the default number of synthetic repositories.
+	DefaultDemoRepositoryN = 50
+
+	// DefaultDemoPollInterval is the default time between synthetic mentions.
+	DefaultDemoPollInterval = 1 * time.Second
+
+	// DefaultDemoNotifyInterval is the default time between demo notifications.
+	DefaultDemoNotifyInterval = 1 * time.Minute
+)
+
+// demoLanguages are the languages of synthetic repositories & demo accounts.
+var demoLanguages = []string{"go", "javascript", "python", "ruby", "rust"}
+
+// DemoCommand represents a command for running the daemon against synthetic
+// data with fake Twitter & GitHub backends. No credentials are required.
+type DemoCommand struct {
+	store  *scuttlebutt.Store
+	daemon *scuttlebutt.Daemon
+	tmpDir string
+
+	// HTTP bind address.
+	Addr string
+
+	// Data directory. Uses a temporary directory if blank.
+	DataDir string
+
+	// Seed for generating synthetic data.
+	Seed int64
+
+	// Number of synthetic repositories.
+	RepositoryN int
+
+	// Time between synthetic mentions and between demo notifications.
+	PollInterval   time.Duration
+	NotifyInterval time.Duration
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewDemoCommand returns a new instance of DemoCommand.
+func NewDemoCommand() *DemoCommand {
+	return &DemoCommand{
+		Addr:           DefaultAddr,
+		Seed:           time.Now().UnixNano(),
+		RepositoryN:    DefaultDemoRepositoryN,
+		PollInterval:   DefaultDemoPollInterval,
+		NotifyInterval: DefaultDemoNotifyInterval,
+
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *DemoCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-demo", flag.ContinueOnError)
+	fs.StringVar(&cmd.Addr, "addr", DefaultAddr, "HTTP port")
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory (default: temporary)")
+	fs.Int64Var(&cmd.Seed, "seed", cmd.Seed, "random seed")
+	fs.IntVar(&cmd.RepositoryN, "repos", DefaultDemoRepositoryN, "number of synthetic repositories")
+	fs.DurationVar(&cmd.PollInterval, "poll-interval", DefaultDemoPollInterval, "time between synthetic mentions")
+	fs.DurationVar(&cmd.NotifyInterval, "notify-interval", DefaultDemoNotifyInterval, "time between demo notifications")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.RepositoryN <= 0 {
+		return errors.New("repository count must be positive")
+	} else if cmd.PollInterval <= 0 {
+		return errors.New("poll interval must be positive")
+	} else if cmd.NotifyInterval <= 0 {
+		return errors.New("notify interval must be positive")
+	}
+
+	return nil
+}
+
+// Run starts the demo and waits for an interrupt signal.
+func (cmd *DemoCommand) Run() error {
+	if err := cmd.Open(); err != nil {
+		return err
+	}
+	defer cmd.Close()
+
+	fmt.Fprintf(cmd.Stdout, "demo running on http://%s (seed=%d), press ctrl-c to exit\n", cmd.ListenerAddr(), cmd.Seed)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+	return nil
+}
+
+// Open generates the synthetic dataset and starts the daemon.
+func (cmd *DemoCommand) Open() error {
+	// Use a temporary data directory, if one is not specified.
+	dataDir := cmd.DataDir
+	if dataDir == "" {
+		dir, err := ioutil.TempDir("", "scuttlebutt-demo-")
+		if err != nil {
+			return err
+		}
+		cmd.tmpDir, dataDir = dir, dir
+	} else if err := os.MkdirAll(dataDir, 0777); err != nil {
+		return err
+	}
+
+	// Generate synthetic repositories.
+	data := newDemoData(cmd.Seed, cmd.RepositoryN)
+
+	// Open data store backed by the fake GitHub store.
+	cmd.store = scuttlebutt.NewStore(filepath.Join(dataDir, "db"))
+	cmd.store.RemoteStore = data
+	if err := cmd.store.Open(); err != nil {
+		cmd.Close()
+		return fmt.Errorf("open store: %s", err)
+	}
+
+	// Initialize daemon with fake Twitter poller & notifiers.
+	d := scuttlebutt.NewDaemon()
+	d.Store = cmd.store
+	d.Poller = data
+	d.Addr = cmd.Addr
+	d.PollInterval = cmd.PollInterval
+	d.NotifyCheckInterval = cmd.PollInterval
+	d.LogOutput = cmd.Stderr
+	for _, lang := range demoLanguages {
+		n := &demoNotifier{
+			username: "demo_" + lang,
+			interval: cmd.NotifyInterval,
+			w:        cmd.Stdout,
+		}
+		d.Accounts = append(d.Accounts, &scuttlebutt.Account{
+			Username: n.username,
+			Language: lang,
+			Notifier: n,
+		})
+	}
+
+	if err := d.Start(context.Background()); err != nil {
+		cmd.Close()
+		return err
+	}
+	cmd.daemon = d
+
+	return nil
+}
+
+// Close stops the daemon and removes any temporary data.
+func (cmd *DemoCommand) Close() error {
+	if cmd.daemon != nil {
+		cmd.daemon.Close()
+	}
+	if cmd.store != nil {
+		cmd.store.Close()
+	}
+	if cmd.tmpDir != "" {
+		os.RemoveAll(cmd.tmpDir)
+		cmd.tmpDir = ""
+	}
+	return nil
+}
+
+// ListenerAddr returns the address the demo is serving HTTP on.
+func (cmd *DemoCommand) ListenerAddr() string {
+	if cmd.daemon == nil || cmd.daemon.ListenerAddr() == nil {
+		return ""
+	}
+	return cmd.daemon.ListenerAddr().String()
+}
+
+// demoData generates synthetic repositories & mentions. It acts as both
+// the remote repository store and the message poller.
+type demoData struct {
+	mu     sync.Mutex
+	rand   *rand.Rand
+	repos  []*scuttlebutt.Repository
+	byID   map[string]*scuttlebutt.Repository
+	lastID uint64
+}
+
+// newDemoData returns n synthetic repositories generated from seed.
+func newDemoData(seed int64, n int) *demoData {
+	var (
+		owners = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"}
+		adjs   = []string{"fast", "tiny", "lazy", "shiny", "quiet", "brave", "fuzzy", "simple"}
+		nouns  = []string{"cache", "router", "parser", "queue", "server", "logger", "db", "crawler"}
+	)
+
+	data := &demoData{
+		rand: rand.New(rand.NewSource(seed)),
+		byID: make(map[string]*scuttlebutt.Repository),
+	}
+	for len(data.repos) < n {
+		owner := owners[data.rand.Intn(len(owners))]
+		adj, noun := adjs[data.rand.Intn(len(adjs))], nouns[data.rand.Intn(len(nouns))]
+		id := fmt.Sprintf("github.com/%s/%s-%s", owner, adj, noun)
+		if data.byID[id] != nil {
+			id = fmt.Sprintf("%s-%d", id, len(data.repos))
+		}
+
+		r := &scuttlebutt.Repository{
+			ID:          id,
+			Description: fmt.Sprintf("A %s %s.", adj, noun),
+			Language:    demoLanguages[data.rand.Intn(len(demoLanguages))],
+			Stars:       data.rand.Intn(5000),
+		}
+		r.Forks = r.Stars / (data.rand.Intn(10) + 5)
+		data.repos = append(data.repos, r)
+		data.byID[id] = r
+	}
+	return data
+}
+
+// Repository returns a copy of a synthetic repository.
+// Stars grow slightly on each lookup.
+func (data *demoData) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	r := data.byID[id]
+	if r == nil {
+		return nil, nil
+	}
+	r.Stars += data.rand.Intn(10)
+
+	other := *r
+	return &other, nil
+}
+
+// Poll returns a few synthetic mentions. Earlier repositories are mentioned
+// more frequently. Message IDs encode the current time like tweet IDs.
+func (data *demoData) Poll(ctx context.Context, sinceID uint64) ([]*scuttlebutt.Message, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	id := uint64(time.Now().UnixNano()/int64(time.Millisecond)-1288834974657) << 22
+	if id <= data.lastID || id <= sinceID {
+		id = maxUint64(data.lastID, sinceID) + 1
+	}
+
+	var a []*scuttlebutt.Message
+	for i, n := 0, data.rand.Intn(4); i < n; i++ {
+		// Skew selection towards the start of the list.
+		r := data.repos[data.rand.Intn(data.rand.Intn(len(data.repos))+1)]
+		a = append(a, &scuttlebutt.Message{
+			ID:           id,
+			Text:         fmt.Sprintf("check out %s #%s", r.URL(), r.Language),
+			RepositoryID: r.ID,
+			Time:         time.Now().UTC(),
+		})
+		data.lastID, id = id, id+1
+	}
+	return a, nil
+}
+
+// maxUint64 returns the larger of a & b.
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// demoNotifier is a notifier that writes notifications to a writer.
+type demoNotifier struct {
+	mu       sync.Mutex
+	username string
+	interval time.Duration
+	last     time.Time
+	lastID   uint64
+	w        io.Writer
+}
+
+// LastTweetTime returns the time of the last notification.
+func (n *demoNotifier) LastTweetTime() (time.Time, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.last, nil
+}
+
+// Due returns true if the interval has passed since the last notification.
+func (n *demoNotifier) Due(now, last time.Time) bool {
+	return last.IsZero() || now.Sub(last) >= n.interval
+}
+
+// Notify writes the notification text for r.
+func (n *demoNotifier) Notify(ctx context.Context, r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+	text, err := n.Text(r)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.last = time.Now()
+	n.lastID++
+	fmt.Fprintf(n.w, "@%s: %s\n", n.username, text)
+
+	return &scuttlebutt.Message{ID: n.lastID, Text: text, RepositoryID: r.ID}, nil
+}
+
+// Text returns the standard notification text for r.
+func (n *demoNotifier) Text(r *scuttlebutt.Repository) (string, error) {
+	return twitter.NotifyText(r), nil
+}
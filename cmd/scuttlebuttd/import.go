@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/github"
+)
+
+// DefaultImportBatchSize is the default number of repositories per import transaction.
+const DefaultImportBatchSize = 1000
+
+// ImportCommand represents a command for bulk importing repository metadata
+// from a GHArchive CSV export into the local store.
+type ImportCommand struct {
+	// Data directory and the CSV path to import. The optional config's
+	// storage settings, such as a separate message file, are used to open
+	// the store.
+	DataDir    string
+	ConfigPath string
+	Path       string
+
+	// Number of repositories written per transaction.
+	BatchSize int
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewImportCommand returns a new instance of ImportCommand.
+func NewImportCommand() *ImportCommand {
+	return &ImportCommand{
+		BatchSize: DefaultImportBatchSize,
+
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *ImportCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-import", flag.ContinueOnError)
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path")
+	fs.IntVar(&cmd.BatchSize, "batch-size", DefaultImportBatchSize, "repositories per transaction")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.DataDir == "" {
+		return errors.New("data directory required")
+	} else if fs.NArg() == 0 {
+		return errors.New("path required")
+	} else if cmd.BatchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	cmd.Path = fs.Arg(0)
+
+	return nil
+}
+
+// Run imports the CSV file into the store.
+func (cmd *ImportCommand) Run() error {
+	// Open input file. A path of "-" reads from stdin.
+	var r io.Reader = cmd.Stdin
+	if cmd.Path != "-" {
+		f, err := os.Open(cmd.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Create base directory, if not exists.
+	if err := os.MkdirAll(cmd.DataDir, 0777); err != nil {
+		return err
+	}
+
+	// Open data store.
+	c, err := parseOptionalConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+	store := c.NewStore(cmd.DataDir)
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	// Read repositories and write them out in batches. Malformed rows are
+	// logged and skipped.
+	var total, created, skipped int
+	ar := github.NewArchiveReader(r)
+	batch := make([]*scuttlebutt.Repository, 0, cmd.BatchSize)
+	for {
+		repo, err := ar.Read()
+		if _, ok := err.(*github.ArchiveRowError); ok {
+			fmt.Fprintf(cmd.Stderr, "skipping row: %s\n", err)
+			skipped++
+			continue
+		} else if err != nil && err != io.EOF {
+			return fmt.Errorf("read: %s", err)
+		}
+		if repo != nil {
+			batch = append(batch, repo)
+		}
+
+		// Flush batch when full or at the end of the file.
+		if len(batch) == cmd.BatchSize || (err == io.EOF && len(batch) > 0) {
+			n, err := store.ImportRepositories(batch)
+			if err != nil {
+				return fmt.Errorf("import: %s", err)
+			}
+			total, created = total+len(batch), created+n
+			batch = batch[:0]
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	fmt.Fprintf(cmd.Stdout, "imported %d repositories (%d new, %d skipped)\n", total, created, skipped)
+	return nil
+}
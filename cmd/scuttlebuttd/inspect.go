@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// InspectCommand represents a command for printing everything stored about
+// a single repository, including each of its messages and its current score.
+type InspectCommand struct {
+	// Data directory & optional config path. The config's storage settings,
+	// such as a separate message file, are used to open the store.
+	DataDir    string
+	ConfigPath string
+
+	// ID of the repository, such as "github.com/user/repo".
+	RepositoryID string
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewInspectCommand returns a new instance of InspectCommand.
+func NewInspectCommand() *InspectCommand {
+	return &InspectCommand{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *InspectCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-inspect", flag.ContinueOnError)
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.DataDir == "" {
+		return errors.New("data directory required")
+	} else if fs.NArg() != 1 {
+		return errors.New("repository required")
+	}
+	cmd.RepositoryID = strings.TrimPrefix(strings.TrimPrefix(fs.Arg(0), "https://"), "http://")
+
+	return nil
+}
+
+// Run prints the repository.
+func (cmd *InspectCommand) Run() error {
+	c, err := parseOptionalConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+
+	store := c.NewStore(cmd.DataDir)
+	store.ReadOnly = true
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	r, err := store.Repository(cmd.RepositoryID)
+	if err != nil {
+		return fmt.Errorf("repository: %s", err)
+	} else if r == nil {
+		return scuttlebutt.ErrRepositoryNotFound
+	}
+
+	// Find the repository's overall rank & score.
+	ranked, err := store.TopRepositoriesOverall(-1)
+	if err != nil {
+		return fmt.Errorf("top repositories overall: %s", err)
+	}
+	rank, score := "-", "-"
+	for i, other := range ranked {
+		if other.ID == r.ID {
+			rank, score = fmt.Sprint(i+1), fmt.Sprintf("%.4f", other.Score)
+			break
+		}
+	}
+
+	exclusion := store.Exclusion(r)
+	if exclusion == "" {
+		exclusion = "-"
+	}
+
+	tw := tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", r.ID)
+	fmt.Fprintf(tw, "URL:\t%s\n", r.URL())
+	fmt.Fprintf(tw, "Description:\t%s\n", r.Description)
+	fmt.Fprintf(tw, "Language:\t%s\n", r.Language)
+	fmt.Fprintf(tw, "License:\t%s\n", blank(r.License))
+	fmt.Fprintf(tw, "Topics:\t%s\n", strings.Join(r.Topics, ", "))
+	fmt.Fprintf(tw, "Labels:\t%s\n", blank(strings.Join(r.Labels, ", ")))
+	fmt.Fprintf(tw, "Stars:\t%d\n", r.Stars)
+	fmt.Fprintf(tw, "Forks:\t%d\n", r.Forks)
+	fmt.Fprintf(tw, "Fork:\t%t\n", r.Fork)
+	fmt.Fprintf(tw, "Archived:\t%t\n", r.Archived)
+	fmt.Fprintf(tw, "Disabled:\t%t\n", r.Disabled)
+	fmt.Fprintf(tw, "Notified:\t%t\n", r.Notified)
+	fmt.Fprintf(tw, "Excluded:\t%s\n", exclusion)
+	fmt.Fprintf(tw, "Mentions:\t%d\n", len(r.Messages))
+	fmt.Fprintf(tw, "Sources:\t%s\n", formatSources(r.SourceMentions()))
+	fmt.Fprintf(tw, "Authors:\t%d\n", r.AuthorN())
+	fmt.Fprintf(tw, "Boost:\t%d\n", r.Boost)
+	fmt.Fprintf(tw, "Ranked Mentions:\t%d\n", r.RankedMentions())
+	fmt.Fprintf(tw, "Overall Rank:\t%s\n", rank)
+	fmt.Fprintf(tw, "Overall Score:\t%s\n", score)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Print each message.
+	fmt.Fprintln(cmd.Stdout, "")
+	tw = tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tAUTHOR\tURL\tTEXT")
+	for _, m := range r.Messages {
+		t := "-"
+		if !m.Time.IsZero() {
+			t = m.Time.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", m.ID, t, blank(m.Author), blank(m.URL), strings.Join(strings.Fields(m.Text), " "))
+	}
+	return tw.Flush()
+}
+
+// formatSources returns message counts by source, such as "hn=1, twitter=3",
+// ordered by source name. Returns "-" if there are no messages.
+func formatSources(m map[string]int) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	a := make([]string, len(names))
+	for i, name := range names {
+		a[i] = fmt.Sprintf("%s=%d", name, m[name])
+	}
+	return blank(strings.Join(a, ", "))
+}
+
+// blank returns "-" if s is blank. Otherwise returns s.
+func blank(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,41 +1,43 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
 	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/election"
+	"github.com/benbjohnson/scuttlebutt/events"
 	"github.com/benbjohnson/scuttlebutt/github"
+	"github.com/benbjohnson/scuttlebutt/sentry"
+	"github.com/benbjohnson/scuttlebutt/statsd"
+	"github.com/benbjohnson/scuttlebutt/tracing"
 	"github.com/benbjohnson/scuttlebutt/twitter"
-	"github.com/burntsushi/toml"
-	"github.com/kurrik/oauth1a"
-	"github.com/kurrik/twittergo"
+	"github.com/benbjohnson/scuttlebutt/webhook"
 )
 
-const (
-	// DefaultPollInterval is the default time between Twitter polling.
-	DefaultPollInterval = 30 * time.Second
-
-	// DefaultNotifyInterval is the default time between individual account notifications.
-	DefaultNotifyInterval = 4 * time.Hour
-
-	// DefaultNotifyCheckInterval is the default time between notification checks.
-	DefaultNotifyCheckInterval = 30 * time.Minute
-
-	// DefaultAddr is the default HTTP bind address.
-	DefaultAddr = ":5050"
-)
+// DefaultAddr is the default HTTP bind address.
+const DefaultAddr = ":5050"
 
 func main() {
+	// Execute subcommand, if specified.
+	if cmd, args := subcommand(os.Args[1:]); cmd != nil {
+		if err := cmd.ParseFlags(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		} else if err := cmd.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	m := NewMain()
 
 	// Parse command line flags.
@@ -54,20 +56,72 @@ func main() {
 	<-(chan struct{})(nil)
 }
 
+// Command represents a subcommand of the program.
+type Command interface {
+	ParseFlags(args []string) error
+	Run() error
+}
+
+// subcommand returns the subcommand specified by args and its remaining args.
+// Returns a nil command if args does not specify a subcommand.
+func subcommand(args []string) (Command, []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	switch args[0] {
+	case "import":
+		return NewImportCommand(), args[1:]
+	case "demo":
+		return NewDemoCommand(), args[1:]
+	case "reset-notified":
+		return NewResetNotifiedCommand(), args[1:]
+	case "backtest":
+		return NewBacktestCommand(), args[1:]
+	case "tail":
+		return NewTailCommand(), args[1:]
+	case "top":
+		return NewTopCommand(), args[1:]
+	case "inspect":
+		return NewInspectCommand(), args[1:]
+	case "blacklist":
+		if len(args) > 1 {
+			return NewBlacklistCommand(args[1]), args[2:]
+		}
+	case "config":
+		if len(args) > 1 && args[1] == "check" {
+			return NewConfigCheckCommand(), args[2:]
+		}
+	case "auth":
+		if len(args) > 1 && args[1] == "twitter" {
+			return NewTwitterAuthCommand(), args[2:]
+		}
+	}
+	return nil, nil
+}
+
 // Main represents the main program execution.
 type Main struct {
-	// Data store
-	store     *scuttlebutt.Store
-	poller    *twitter.Poller
-	notifiers []*twitter.Notifier
+	// Data store, background process, & the bus their events are
+	// published to.
+	store  *scuttlebutt.Store
+	daemon *scuttlebutt.Daemon
+	events *events.Bus
 
-	// HTTP interface
-	Listener net.Listener
-	Handler  http.Handler
+	// Event subscriptions for integrations. Closed on shutdown.
+	subscriptions []*events.Subscription
 
-	// Close management
-	wg      sync.WaitGroup
-	closing chan struct{}
+	// Access log file, if logging to a path.
+	accessLog *os.File
+
+	// Span exporter, if tracing is enabled.
+	tracer *tracing.Tracer
+
+	// Metrics client, if a statsd agent is configured.
+	statsd *statsd.Client
+
+	// Error reporter, if a Sentry DSN is configured.
+	reporter *sentry.Reporter
 
 	// HTTP bind address
 	Addr string
@@ -88,6 +142,14 @@ type Main struct {
 	// Time between checking if notification interval has passed.
 	NotifyCheckInterval time.Duration
 
+	// Time before a repository or identical text can be featured again.
+	FeaturedWindow time.Duration
+
+	// Maximum number of new repositories looked up remotely per poll cycle.
+	// Remaining messages are saved to the retry queue, if enabled, or
+	// deferred to the next cycle. Zero is unlimited.
+	LookupLimit int
+
 	// Input/output streams
 	Stdin  io.Reader
 	Stdout io.Writer
@@ -97,9 +159,12 @@ type Main struct {
 // NewMain returns a new instance of Main.
 func NewMain() *Main {
 	return &Main{
-		PollInterval:        DefaultPollInterval,
-		NotifyInterval:      DefaultNotifyInterval,
-		NotifyCheckInterval: DefaultNotifyCheckInterval,
+		PollInterval:        scuttlebutt.DefaultPollInterval,
+		NotifyInterval:      scuttlebutt.DefaultNotifyInterval,
+		NotifyCheckInterval: scuttlebutt.DefaultNotifyCheckInterval,
+		FeaturedWindow:      scuttlebutt.DefaultFeaturedWindow,
+
+		events: events.NewBus(),
 
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
@@ -109,8 +174,6 @@ func NewMain() *Main {
 
 // Run executes the program.
 func (m *Main) Run() error {
-	logger := log.New(m.Stderr, "", log.LstdFlags)
-
 	// Validate options.
 	if m.DataDir == "" {
 		return errors.New("data directory required")
@@ -122,70 +185,390 @@ func (m *Main) Run() error {
 	}
 
 	// Open data store.
-	m.store = scuttlebutt.NewStore(filepath.Join(m.DataDir, "db"))
-	m.store.RemoteStore = github.NewStore(m.Config.GitHub.Token)
+	m.store = m.Config.NewStore(m.DataDir)
+
+	// Create the transport shared by outbound clients.
+	transport, err := scuttlebutt.NewHTTPTransport(m.Config.HTTPOptions())
+	if err != nil {
+		return fmt.Errorf("http: %s", err)
+	}
+	githubTransport := m.Config.NewCircuitBreaker("github", transport)
+	twitterTransport := m.Config.NewCircuitBreaker("twitter", transport)
+
+	cacheDir := m.Config.GitHub.CacheDir
+	if cacheDir != "" && !filepath.IsAbs(cacheDir) {
+		cacheDir = filepath.Join(m.DataDir, cacheDir)
+	}
+	if m.Config.GitHub.GraphQL {
+		s, err := m.Config.NewGitHubGraphQLStore(githubTransport)
+		if err != nil {
+			return fmt.Errorf("github: %s", err)
+		}
+		m.store.RemoteStore = s
+	} else {
+		s, err := m.Config.NewGitHubStore(cacheDir, githubTransport)
+		if err != nil {
+			return fmt.Errorf("github: %s", err)
+		}
+		m.store.RemoteStore = s
+	}
+
+	// Route lookups by host if additional remotes are configured.
+	hosts := twitter.DefaultHosts
+	if len(m.Config.Remotes) > 0 {
+		mux := scuttlebutt.NewRemoteStoreMux()
+		mux.Handle("github.com", m.store.RemoteStore)
+		for _, r := range m.Config.Remotes {
+			s, err := r.NewStore(cacheDir, m.Config.NewCircuitBreaker(r.Host, transport))
+			if err != nil {
+				return fmt.Errorf("remote store: host=%s, err=%s", r.Host, err)
+			}
+			mux.Handle(r.Host, s)
+		}
+		m.store.RemoteStore = mux
+		hosts = mux.Hosts()
+	}
+	m.store.Batch = m.Config.Store.Batch
+	m.store.MaxBatchSize = m.Config.Store.MaxBatchSize
+	m.store.MaxBatchDelay = time.Duration(m.Config.Store.MaxBatchDelay)
+	if ttl := m.Config.Store.NotFoundTTL; ttl > 0 {
+		m.store.NotFoundTTL = time.Duration(ttl)
+	}
+	if n := m.Config.Store.LookupConcurrency; n > 0 {
+		m.store.LookupConcurrency = n
+	}
+	spam, err := m.Config.SpamFilter()
+	if err != nil {
+		return fmt.Errorf("spam filter: %s", err)
+	}
+	m.store.SpamFilter = spam
+	m.store.Events = m.events
+	if c := m.Config.Tracing; c.Endpoint != "" {
+		m.tracer = tracing.NewTracer(c.Endpoint)
+		if c.ServiceName != "" {
+			m.tracer.ServiceName = c.ServiceName
+		}
+		m.tracer.LogOutput = m.Stderr
+		m.tracer.Open()
+		m.store.Tracer = m.tracer
+	}
 	if err := m.store.Open(); err != nil {
 		return fmt.Errorf("open store: %s", err)
 	}
 
+	// Remove data for repositories whose owners have opted out. Read-only
+	// stores are purged once they are next opened for writing.
+	if !m.store.ReadOnly {
+		if n, err := m.store.PurgeOptedOut(); err != nil {
+			m.store.Close()
+			return fmt.Errorf("purge opted out: %s", err)
+		} else if n > 0 {
+			fmt.Fprintf(m.Stdout, "purged %d opted out repositories\n", n)
+		}
+	}
+
+	// Send metrics to a statsd agent, if configured.
+	if c := m.Config.Statsd; c.Addr != "" {
+		client := statsd.NewClient(c.Addr)
+		if c.Prefix != "" {
+			client.Prefix = c.Prefix
+		}
+		client.Tags = c.Tags
+		client.LogOutput = m.Stderr
+		if err := client.Open(); err != nil {
+			m.store.Close()
+			return fmt.Errorf("open statsd: %s", err)
+		}
+		m.statsd = client
+	}
+
+	// Report errors to Sentry, if configured.
+	if c := m.Config.Sentry; c.DSN != "" {
+		reporter, err := sentry.NewReporter(c.DSN)
+		if err != nil {
+			m.store.Close()
+			return fmt.Errorf("sentry: %s", err)
+		}
+		reporter.Environment = c.Environment
+		reporter.LogOutput = m.Stderr
+		reporter.Open()
+		m.reporter = reporter
+	}
+
+	// Initialize daemon.
+	d := scuttlebutt.NewDaemon()
+	d.Store = m.store
+	d.ReadOnly = m.store.ReadOnly
+	d.Events = m.events
+	d.Tracer = m.tracer
+	d.Statsd = m.statsd
+	if m.reporter != nil {
+		d.ErrorReporter = m.reporter
+	}
+	d.Addr = m.Addr
+	d.GRPCAddr = m.Config.GRPCAddr
+	d.IngestToken = m.Config.Ingest.Token
+	d.AdminToken = m.Config.Admin.Token
+	d.IngestRepositoryID = func(rawurl string) string { return twitter.ExtractRepositoryID(rawurl, hosts) }
+	d.PollInterval = m.PollInterval
+	if backoff := m.Config.MaxPollBackoff; backoff > 0 {
+		d.MaxPollBackoff = time.Duration(backoff)
+	}
+	d.NotifyCheckInterval = m.NotifyCheckInterval
+	d.LookupLimit = m.LookupLimit
+	d.FeaturedWindow = m.FeaturedWindow
+	d.RefreshInterval = time.Duration(m.Config.Refresh.Interval)
+	if age := m.Config.Refresh.Age; age > 0 {
+		d.RefreshAge = time.Duration(age)
+	}
+	if limit := m.Config.Refresh.Limit; limit > 0 {
+		d.RefreshLimit = limit
+	}
+	if d.ContentFilter, err = m.Config.NewContentFilter(); err != nil {
+		m.store.Close()
+		return fmt.Errorf("content filter: %s", err)
+	}
+	d.Thresholds = m.Config.NewThresholds()
+	d.LogOutput = m.Stderr
+	if c := m.Config.Retry; !c.Disabled {
+		d.RetryInterval = scuttlebutt.DefaultRetryInterval
+		if c.Interval > 0 {
+			d.RetryInterval = time.Duration(c.Interval)
+		}
+		if c.Limit > 0 {
+			d.RetryLimit = c.Limit
+		}
+		if c.MaxAttempts > 0 {
+			d.MaxRetryAttempts = c.MaxAttempts
+		}
+	}
+	d.EngagementInterval = time.Duration(m.Config.Engagement.Interval)
+	if window := m.Config.Engagement.Window; window > 0 {
+		d.EngagementWindow = time.Duration(window)
+	}
+	if c := m.Config.Snapshot; !c.Disabled {
+		d.SnapshotInterval = scuttlebutt.DefaultSnapshotInterval
+		if c.Interval > 0 {
+			d.SnapshotInterval = time.Duration(c.Interval)
+		}
+		if c.N > 0 {
+			d.SnapshotN = c.N
+		}
+	}
+	switch c := m.Config.Election; c.Mode {
+	case "file":
+		path := c.Path
+		if path == "" {
+			path = "leader.lock"
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(m.DataDir, path)
+		}
+		d.Elector = election.NewFileLock(path)
+	case "consul":
+		elector := election.NewConsul(c.Addr, c.Key)
+		if c.TTL > 0 {
+			elector.TTL = time.Duration(c.TTL)
+		}
+		elector.Token = c.Token
+		d.Elector = elector
+	}
+	if c := m.Config.Pipeline; !c.Disabled {
+		p := scuttlebutt.NewPipeline(m.store)
+		if c.QueueSize > 0 {
+			p.QueueSize = c.QueueSize
+		}
+		if c.BatchSize > 0 {
+			p.BatchSize = c.BatchSize
+		}
+		if c.BatchDelay > 0 {
+			p.BatchDelay = time.Duration(c.BatchDelay)
+		}
+		p.Statsd = m.statsd
+		p.LogOutput = m.Stderr
+		d.Pipeline = p
+	}
+
 	// Initialize poller.
-	m.poller = twitter.NewPoller()
-	m.poller.Client = twittergo.NewClient(&oauth1a.ClientConfig{
-		ConsumerKey:    m.Config.Twitter.Key,
-		ConsumerSecret: m.Config.Twitter.Secret,
-	}, nil)
+	poller := twitter.NewPoller()
+	poller.Hosts = hosts
+	poller.Queries = m.Config.Twitter.Queries
+	poller.ExcludeRetweets = m.Config.Twitter.ExcludeRetweets
+	poller.CollapseRetweets = m.Config.Twitter.CollapseRetweets
+	poller.IncludeQuotes = m.Config.Twitter.IncludeQuotes
+	if m.Config.Twitter.MaxPages > 0 {
+		poller.MaxPages = m.Config.Twitter.MaxPages
+	}
+	poller.Client = m.Config.NewTwitterClient(twitterTransport)
+	if m.Config.Twitter.ResolveURLs {
+		resolver := twitter.NewURLResolver()
+		resolver.Client.Transport = transport
+		if len(m.Config.Twitter.ShortenerHosts) > 0 {
+			resolver.Hosts = m.Config.Twitter.ShortenerHosts
+		}
+		poller.Resolver = resolver
+	}
+	d.Sources = append(d.Sources, &scuttlebutt.Source{
+		Name:         twitter.SourceName,
+		Poller:       poller,
+		PollInterval: time.Duration(m.Config.Twitter.PollInterval),
+	})
+
+	// Initialize routing rules.
+	d.Router = m.Config.Router()
+
+	// Initialize URL shortener, if configured. Short URLs are saved in the store.
+	var sh scuttlebutt.Shortener
+	if provider := m.Config.NewShortener(); provider != nil {
+		sh = &scuttlebutt.StoreShortener{Store: m.store, Shortener: provider}
+	}
 
 	// Initialize notifiers for each account
+	previews := github.NewPreviewSource()
+	previews.HTTPClient = &http.Client{Transport: transport}
 	for _, acc := range m.Config.Accounts {
-		client := twittergo.NewClient(
-			&oauth1a.ClientConfig{
-				ConsumerKey:    m.Config.Twitter.Key,
-				ConsumerSecret: m.Config.Twitter.Secret,
-			},
-			oauth1a.NewAuthorizedConfig(acc.Key, acc.Secret),
-		)
+		client := m.Config.NewAccountClient(acc, m.store, twitterTransport)
 
 		n := twitter.NewNotifier()
 		n.Username = acc.Username
-		n.Language = acc.Language
+		n.Language = m.store.LanguageAliases.Normalize(acc.Language)
+		n.Topic = acc.Topic
+		n.Label = acc.Label
+		n.Interval = m.NotifyInterval
+		n.Shortener = sh
 		n.Client = client
 
-		m.notifiers = append(m.notifiers, n)
+		// Override interval, if set on the account.
+		if acc.NotifyInterval > 0 {
+			n.Interval = time.Duration(acc.NotifyInterval)
+		}
+
+		// Compile pattern, if set on the account.
+		re, err := acc.Regexp()
+		if err != nil {
+			m.store.Close()
+			return fmt.Errorf("account pattern: username=%s, err=%s", acc.Username, err)
+		}
+
+		// Attach schedule, if set on the account.
+		sched, err := acc.Schedule()
+		if err != nil {
+			m.store.Close()
+			return fmt.Errorf("account schedule: username=%s, err=%s", acc.Username, err)
+		}
+		n.Schedule = sched
+
+		// Enable hashtags, if configured.
+		if m.Config.Hashtags.Enabled {
+			n.Hashtags = twitter.Hashtags(m.Config.Hashtags.Languages)
+			if n.Hashtags == nil {
+				n.Hashtags = twitter.Hashtags{}
+			}
+		}
+
+		// Attach social preview images, if enabled on the account.
+		if acc.Media {
+			n.Images = previews
+		}
+
+		// Parse template, if set on the account.
+		if acc.Template != "" {
+			tmpl, err := twitter.ParseTemplate(acc.Template)
+			if err != nil {
+				m.store.Close()
+				return fmt.Errorf("account template: username=%s, err=%s", acc.Username, err)
+			}
+			n.Template = tmpl
+		}
+
+		// Parse template variants, if set on the account.
+		for _, v := range acc.Variants {
+			tmpl, err := twitter.ParseTemplate(v.Template)
+			if err != nil {
+				m.store.Close()
+				return fmt.Errorf("account variant: username=%s, name=%s, err=%s", acc.Username, v.Name, err)
+			}
+			n.Variants = append(n.Variants, &twitter.TemplateVariant{Name: v.Name, Weight: v.Weight, Template: tmpl})
+		}
+
+		d.Accounts = append(d.Accounts, &scuttlebutt.Account{
+			Username:  n.Username,
+			Language:  n.Language,
+			Topic:     n.Topic,
+			Pattern:   re,
+			Notifier:  n,
+			DigestN:   acc.Digest,
+			Moderated: acc.Moderated,
+		})
 	}
 
-	// Open HTTP listener.
-	ln, err := net.Listen("tcp", m.Addr)
-	if err != nil {
-		return err
+	d.DisableCompression = m.Config.DisableCompression
+
+	// Log HTTP requests, if enabled.
+	if c := m.Config.AccessLog; c.Enabled {
+		d.AccessLog, d.AccessLogFormat = m.Stderr, c.Format
+		if path := c.Path; path != "" {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(m.DataDir, path)
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+			if err != nil {
+				m.store.Close()
+				return fmt.Errorf("open access log: %s", err)
+			}
+			m.accessLog, d.AccessLog = f, f
+		}
 	}
-	m.Listener = ln
-	m.Handler = &scuttlebutt.Handler{Store: m.store}
 
-	// Run HTTP server is separate goroutine.
-	logger.Printf("Listening on http://localhost%s", m.Addr)
-	go http.Serve(m.Listener, m.Handler)
+	// Post sent notifications to the webhook, if configured.
+	if u := m.Config.Webhook.URL; u != "" {
+		w := webhook.New(u)
+		w.Secret = m.Config.Webhook.Secret
+		w.LogOutput = m.Stderr
+		sub := m.events.Subscribe(events.TypeNotificationSent)
+		m.subscriptions = append(m.subscriptions, sub)
+		go w.Run(sub)
+	}
 
-	// Create a poller & notify monitor.
-	m.wg.Add(2)
-	go m.runPoller()
-	go m.runNotifier()
+	// Start polling, notifying, and serving HTTP.
+	if err := d.Start(context.Background()); err != nil {
+		m.store.Close()
+		return err
+	}
+	m.daemon = d
 
 	return nil
 }
 
 // Close shuts down the program and all goroutines.
-// Calling close twice will cause a panic.
+// Calling Close multiple times is safe.
 func (m *Main) Close() error {
-	// Close HTTP listener.
-	if m.Listener != nil {
-		m.Listener.Close()
-		m.Listener = nil
+	if m.daemon != nil {
+		m.daemon.Close()
+	}
+	for _, sub := range m.subscriptions {
+		sub.Close()
+	}
+	m.subscriptions = nil
+	if m.accessLog != nil {
+		m.accessLog.Close()
+		m.accessLog = nil
+	}
+	if m.store != nil {
+		m.store.Close()
+	}
+	if m.tracer != nil {
+		m.tracer.Close()
+		m.tracer = nil
+	}
+	if m.statsd != nil {
+		m.statsd.Close()
+		m.statsd = nil
+	}
+	if m.reporter != nil {
+		m.reporter.Close()
+		m.reporter = nil
 	}
-
-	// Notify goroutines of closing.
-	close(m.closing)
-	m.wg.Wait()
-
 	return nil
 }
 
@@ -195,7 +578,11 @@ func (m *Main) ParseFlags(args []string) error {
 	fs := flag.NewFlagSet("scuttlebuttd", flag.ContinueOnError)
 	fs.StringVar(&m.DataDir, "d", "", "data directory")
 	fs.StringVar(&m.ConfigPath, "c", "", "config path")
-	fs.StringVar(&m.Addr, "addr", ":5050", "HTTP port")
+	fs.StringVar(&m.Addr, "addr", DefaultAddr, "HTTP port")
+	twitterKey := fs.String("twitter-key", "", "twitter consumer key override")
+	twitterSecret := fs.String("twitter-secret", "", "twitter consumer secret override")
+	githubToken := fs.String("github-token", "", "github token override")
+	readOnly := fs.Bool("read-only", false, "open the database read-only and only serve the HTTP API")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -211,176 +598,29 @@ func (m *Main) ParseFlags(args []string) error {
 		return fmt.Errorf("parse config file: %s", err)
 	}
 
-	// Copy config to program.
-	m.Config = c
-
-	return nil
-}
-
-// runPoller periodically searches for messages mentioning repositories.
-func (m *Main) runPoller() {
-	defer m.wg.Done()
-
-	// Setup logging.
-	logger := log.New(m.Stderr, "[poller] ", log.LstdFlags)
-
-	var sinceID uint64
-	for {
-		if err := m.poll(&sinceID); err != nil {
-			logger.Printf("poll error: %s", err)
-		}
-
-		// Wait for next interval or for shutdown signal.
-		select {
-		case <-time.After(m.PollInterval):
-		case <-m.closing:
-			return
-		}
-	}
-}
-
-// poll retrieves messages since a given ID.
-// The sinceID is updated if any messages are retrieved.
-func (m *Main) poll(sinceID *uint64) error {
-	// Retrieve messages from twitter.
-	messages, err := m.poller.Poll(*sinceID)
-	if err != nil {
-		return fmt.Errorf("poll: %s", err)
+	// Apply command line overrides.
+	setenv(&c.Twitter.Key, *twitterKey)
+	setenv(&c.Twitter.Secret, *twitterSecret)
+	setenv(&c.GitHub.Token, *githubToken)
+	if *readOnly {
+		c.Storage.ReadOnly = true
 	}
 
-	// Save messages to store.
-	for _, message := range messages {
-		if err := m.store.AddMessage(message); err == scuttlebutt.ErrRepositoryNotFound {
-			// nop
-		} else if err != nil {
-			return fmt.Errorf("add message: %s", err)
-		}
-
-		// Update the highest "since id".
-		if message.ID > *sinceID {
-			*sinceID = message.ID
-		}
-	}
-
-	return nil
-}
-
-// runNotifier periodically searches for messages mentioning repositories.
-func (m *Main) runNotifier() {
-	defer m.wg.Done()
-
-	// Setup logging.
-	logger := log.New(m.Stderr, "[notifier] ", log.LstdFlags)
-
-	for {
-		// Attempt to notify accounts with new repos!
-		if err := m.notify(); err != nil {
-			logger.Printf("notify error: %s", err)
-		}
-
-		// Wait for next interval or for shutdown signal.
-		select {
-		case <-time.After(m.NotifyCheckInterval):
-		case <-m.closing:
-			return
-		}
+	// Copy config to program.
+	m.Config = c
+	m.LookupLimit = c.GitHub.LookupLimit
+	if c.PollInterval > 0 {
+		m.PollInterval = time.Duration(c.PollInterval)
 	}
-}
-
-// notify sends a message to each account if enough time has elapsed.
-func (m *Main) notify() error {
-	// Setup logging.
-	logger := log.New(m.Stderr, "[notifier] ", log.LstdFlags)
-
-	// Retrieve top repositories by language.
-	repos, err := m.store.TopRepositories()
-	if err != nil {
-		return fmt.Errorf("top repositories: %s", err)
+	if c.NotifyInterval > 0 {
+		m.NotifyInterval = time.Duration(c.NotifyInterval)
 	}
-
-	// Iterate over each account.
-	for _, n := range m.notifiers {
-		// Retrieve last tweet time.
-		lastTweetTime, err := n.LastTweetTime()
-		if err != nil {
-			logger.Printf("last tweet time error: username=%s, err=%s", n.Username, err)
-			continue
-		}
-
-		// Skip notifier if last tweet time is within interval.
-		if !lastTweetTime.IsZero() && time.Since(lastTweetTime) < DefaultNotifyInterval {
-			continue
-		}
-
-		// Retrieve top language for the repository.
-		r := repos[n.Language]
-		if r == nil {
-			continue
-		}
-
-		// Attempt to send message to account.
-		if _, err := n.Notify(r); err == twitter.ErrTweetTooLong {
-			// NOTE: if the text contains multiple URL-looking words then it can
-			// go over 140 characters. There's not an easy way to get around it
-			// so we just mark the repo as notified so we can move on.
-			logger.Printf("tweet too long error: username=%s, repo=%s", n.Username, r.ID)
-		} else if err != nil {
-			logger.Printf("notify error: username=%s, repo=%s, text=%q, err=%s", n.Username, r.ID, twitter.NotifyText(r), err)
-			continue
-		}
-		// logger.Printf("NOTIFY: username=%s, repo=%s", n.Username, r.ID)
-
-		// Mark repository as notified.
-		if err := m.store.MarkNotified(r.ID); err != nil {
-			logger.Printf("mark notified error: username=%s, repo=%s, err=%s", r.ID, err)
-			continue
-		}
+	if c.NotifyCheckInterval > 0 {
+		m.NotifyCheckInterval = time.Duration(c.NotifyCheckInterval)
 	}
-
-	return nil
-}
-
-// Config represents the configuration.
-type Config struct {
-	Twitter struct {
-		Key    string `toml:"key"`
-		Secret string `toml:"secret"`
-	} `toml:"twitter"`
-
-	GitHub struct {
-		Token string `toml:"token"`
-	} `toml:"github"`
-
-	Accounts []*Account `toml:"account"`
-}
-
-// ParseConfigFile parses the contents of path into a Config.
-func ParseConfigFile(path string) (*Config, error) {
-	c := &Config{}
-	if _, err := toml.DecodeFile(path, &c); err != nil {
-		return nil, err
+	if c.FeaturedWindow > 0 {
+		m.FeaturedWindow = time.Duration(c.FeaturedWindow)
 	}
-	return c, nil
-}
-
-// Account represents a Twitter account that tweets occassional trending repos.
-type Account struct {
-	Username string `toml:"username"`
-	Language string `toml:"language"`
-	Key      string `toml:"key"`
-	Secret   string `toml:"secret"`
-
-	Client *twittergo.Client `toml:"-"`
-}
-
-// Duration is a helper type for unmarshaling durations in TOML.
-type Duration time.Duration
 
-func (d *Duration) UnmarshalText(text []byte) error {
-	duration, err := time.ParseDuration(string(text))
-	if err != nil {
-		return err
-	}
-	*d = Duration(duration)
 	return nil
 }
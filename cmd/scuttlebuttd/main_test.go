@@ -2,12 +2,20 @@ package main_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/benbjohnson/scuttlebutt"
 	main "github.com/benbjohnson/scuttlebutt/cmd/scuttlebuttd"
 	"github.com/burntsushi/toml"
 	"github.com/davecgh/go-spew/spew"
@@ -106,6 +114,42 @@ username = "github_js"
 		t.Fatalf("unexpected config path: %s", m.ConfigPath)
 	} else if m.Config.Twitter.Key != "XXX" {
 		t.Fatalf("unexpected twitter key: %s", m.Config.Twitter.Key)
+	} else if m.PollInterval != scuttlebutt.DefaultPollInterval {
+		t.Fatalf("unexpected poll interval: %s", m.PollInterval)
+	}
+}
+
+// Ensure intervals can be set from the config file.
+func TestMain_ParseFlags_Intervals(t *testing.T) {
+	f, _ := ioutil.TempFile("", "scuttlebuttd-")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// Write config file.
+	if err := ioutil.WriteFile(f.Name(), []byte(`
+poll_interval = "1m"
+notify_interval = "2h"
+notify_check_interval = "10m"
+
+[[account]]
+username = "github_js"
+notify_interval = "24h"
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse flags and config.
+	m := NewMain()
+	if err := m.ParseFlags([]string{"-c", f.Name()}); err != nil {
+		t.Fatal(err)
+	} else if m.PollInterval != 1*time.Minute {
+		t.Fatalf("unexpected poll interval: %s", m.PollInterval)
+	} else if m.NotifyInterval != 2*time.Hour {
+		t.Fatalf("unexpected notify interval: %s", m.NotifyInterval)
+	} else if m.NotifyCheckInterval != 10*time.Minute {
+		t.Fatalf("unexpected notify check interval: %s", m.NotifyCheckInterval)
+	} else if d := time.Duration(m.Config.Accounts[0].NotifyInterval); d != 24*time.Hour {
+		t.Fatalf("unexpected account notify interval: %s", d)
 	}
 }
 
@@ -130,3 +174,613 @@ func NewMain() *Main {
 
 	return m
 }
+
+// Ensure the config check command reports unknown keys and missing fields.
+func TestConfigCheckCommand_Run(t *testing.T) {
+	f, _ := ioutil.TempFile("", "scuttlebuttd-")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// Write config file with a typo'd key and a missing account secret.
+	if err := ioutil.WriteFile(f.Name(), []byte(`
+[twitter]
+key = "XXX"
+secret = "YYY"
+
+[github]
+tokn = "ZZZ"
+
+[[account]]
+username = "github_js"
+language = "javascript"
+key = "ABC"
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run check and verify output.
+	var stdout bytes.Buffer
+	cmd := main.NewConfigCheckCommand()
+	cmd.Stdout = &stdout
+	if err := cmd.ParseFlags([]string{"-c", f.Name()}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != main.ErrConfigInvalid {
+		t.Fatalf("unexpected error: %s", err)
+	} else if s := stdout.String(); s != "unknown key: github.tokn\ngithub: token required\naccount[0]: secret required\n" {
+		t.Fatalf("unexpected output: %s", s)
+	}
+}
+
+// Ensure the blacklist command manages patterns directly in the store.
+func TestBlacklistCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(action string, args ...string) string {
+		var stdout bytes.Buffer
+		cmd := main.NewBlacklistCommand(action)
+		cmd.Stdout = &stdout
+		if err := cmd.ParseFlags(append([]string{"-d", dir}, args...)); err != nil {
+			t.Fatal(err)
+		} else if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return stdout.String()
+	}
+
+	run("add", "github.com/user/spam")
+	run("add", "/awesome-.*/")
+	if s := run("list"); s != "/awesome-.*/\ngithub.com/user/spam\n" {
+		t.Fatalf("unexpected output: %q", s)
+	}
+	run("remove", "github.com/user/spam")
+	if s := run("list"); s != "/awesome-.*/\n" {
+		t.Fatalf("unexpected output: %q", s)
+	}
+
+	// Invalid patterns & actions are rejected.
+	if err := main.NewBlacklistCommand("add").ParseFlags([]string{"/[/"}); err != scuttlebutt.ErrInvalidBlacklistPattern {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := main.NewBlacklistCommand("clear").ParseFlags(nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// Ensure the blacklist command authenticates with the daemon's admin API.
+func TestBlacklistCommand_Run_API(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		} else if r.Method != "POST" || r.URL.Path != "/admin/blacklist" || r.FormValue("pattern") != "github.com/user/spam" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		io.WriteString(w, "ok\n")
+	}))
+	defer s.Close()
+
+	var stdout bytes.Buffer
+	cmd := main.NewBlacklistCommand("add")
+	cmd.Stdout = &stdout
+	if err := cmd.ParseFlags([]string{"-url", s.URL, "-token", "admin", "github.com/user/spam"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if stdout.String() != "ok\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	cmd = main.NewBlacklistCommand("add")
+	cmd.Stdout = ioutil.Discard
+	if err := cmd.ParseFlags([]string{"-url", s.URL, "-token", "nope", "github.com/user/spam"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err == nil || err.Error() != "unexpected status: 401: unauthorized" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the inspect command prints a repository and its messages.
+func TestInspectCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seed the store with a repository & message.
+	s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/repo", Description: "foo", Language: "golang", Stars: 10}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 100, RepositoryID: "github.com/user/repo", Text: "check\nthis out", Author: "bob", Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := main.NewInspectCommand()
+	cmd.Stdout = &stdout
+	if err := cmd.ParseFlags([]string{"-d", dir, "https://github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"ID:               github.com/user/repo\n",
+		"Language:         golang\n",
+		"Notified:         false\n",
+		"Mentions:         1\n",
+		"Overall Rank:     1\n",
+		"100  2000-01-01T00:00:00Z  bob     -    check this out\n",
+	} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Fatalf("expected %q in output:\n%s", line, stdout.String())
+		}
+	}
+}
+
+// Ensure the inspect command reads messages from the configured message file.
+func TestInspectCommand_Run_MessagePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seed a store with messages in a separate file.
+	configPath := filepath.Join(dir, "scuttlebutt.toml")
+	if err := ioutil.WriteFile(configPath, []byte("[store]\nmessage_path = \"messages.db\"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+	s.MessagePath = filepath.Join(dir, "messages.db")
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/repo", Language: "go"}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 100, RepositoryID: "github.com/user/repo", Text: "hello", Author: "bob"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := main.NewInspectCommand()
+	cmd.Stdout = &stdout
+	if err := cmd.ParseFlags([]string{"-d", dir, "-c", configPath, "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(stdout.String(), "Mentions:         1\n") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+// Ensure the top command ranks messages from the configured message file
+// when the daemon is unavailable.
+func TestTopCommand_Run_MessagePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seed a store with messages in a separate file.
+	configPath := filepath.Join(dir, "scuttlebutt.toml")
+	if err := ioutil.WriteFile(configPath, []byte("[store]\nmessage_path = \"messages.db\"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+	s.MessagePath = filepath.Join(dir, "messages.db")
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/repo", Language: "go", Stars: 10}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 100, RepositoryID: "github.com/user/repo", Text: "hello", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Use the URL of a server that is no longer running.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	var stdout bytes.Buffer
+	cmd := main.NewTopCommand()
+	cmd.Stdout, cmd.Stderr = &stdout, ioutil.Discard
+	if err := cmd.ParseFlags([]string{"-url", srv.URL, "-d", dir, "-c", configPath}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(stdout.String(), "1     github.com/user/repo  Go        1         10\n") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+// Ensure the backtest command replays messages from the configured message file.
+func TestBacktestCommand_Run_MessagePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seed a store with messages in a separate file.
+	configPath := filepath.Join(dir, "scuttlebutt.toml")
+	if err := ioutil.WriteFile(configPath, []byte("[store]\nmessage_path = \"messages.db\"\n\n[[account]]\nusername = \"oss_go\"\nlanguage = \"go\"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+	s.MessagePath = filepath.Join(dir, "messages.db")
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/repo", Language: "go"}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 100, RepositoryID: "github.com/user/repo", Text: "hello", Time: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := main.NewBacktestCommand()
+	cmd.Stdout, cmd.Stderr = &stdout, ioutil.Discard
+	if err := cmd.ParseFlags([]string{"-d", dir, "-c", configPath, "-since", "2000-01-01", "-until", "2000-01-02"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(stdout.String(), "\toss_go\tgithub.com/user/repo\t1\n") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+// Ensure the reset notified command keeps messages in the configured message file.
+func TestResetNotifiedCommand_Run_MessagePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Seed a store with a notified repository & messages in a separate file.
+	configPath := filepath.Join(dir, "scuttlebutt.toml")
+	if err := ioutil.WriteFile(configPath, []byte("[store]\nmessage_path = \"messages.db\"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	open := func() *scuttlebutt.Store {
+		s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+		s.MessagePath = filepath.Join(dir, "messages.db")
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	s := open()
+	if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/repo", Language: "go"}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 100, RepositoryID: "github.com/user/repo", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.MarkNotified("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := main.NewResetNotifiedCommand()
+	cmd.Stdout = &stdout
+	if err := cmd.ParseFlags([]string{"-d", dir, "-c", configPath}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if stdout.String() != "reset 1 repositories\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	s = open()
+	defer s.Close()
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if r.Notified || len(r.Messages) != 1 {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+}
+
+// Ensure the import command skips malformed rows and reports them.
+func TestImportCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scuttlebuttd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stdout, stderr bytes.Buffer
+	cmd := main.NewImportCommand()
+	cmd.Stdin = strings.NewReader("repo_name,language,stars\nuser/Repo1,Go,1\nbad,Go,1\nuser/repo2,Go,2\n")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.ParseFlags([]string{"-d", dir, "-"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if stdout.String() != "imported 2 repositories (2 new, 1 skipped)\n" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	} else if stderr.String() != "skipping row: line 3: invalid repo name: \"bad\"\n" {
+		t.Fatalf("unexpected error output: %q", stderr.String())
+	}
+
+	s := scuttlebutt.NewStore(filepath.Join(dir, "db"))
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if r, err := s.Repository("github.com/user/repo1"); err != nil {
+		t.Fatal(err)
+	} else if r == nil || r.ID != "github.com/user/Repo1" {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+}
+
+// Ensure the twitter auth command exchanges a PIN for an access token and
+// appends the account to the config file.
+func TestTwitterAuthCommand_Run(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), `oauth_consumer_key="XXX"`) {
+			http.Error(w, "invalid consumer", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/request_token":
+			io.WriteString(w, "oauth_token=REQ&oauth_token_secret=REQSECRET&oauth_callback_confirmed=true")
+		case "/access_token":
+			if r.FormValue("oauth_verifier") != "1234" {
+				http.Error(w, "invalid pin", http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, "oauth_token=ABC&oauth_token_secret=123&screen_name=github_go")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f, _ := ioutil.TempFile("", "scuttlebuttd-")
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := ioutil.WriteFile(f.Name(), []byte("[twitter]\nkey = \"XXX\"\nsecret = \"YYY\"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := main.NewTwitterAuthCommand()
+	cmd.RequestURL, cmd.AuthorizeURL, cmd.AccessURL = srv.URL+"/request_token", srv.URL+"/authorize", srv.URL+"/access_token"
+	cmd.Stdin, cmd.Stdout = strings.NewReader("1234\n"), &stdout
+	if err := cmd.ParseFlags([]string{"-c", f.Name(), "-w"}); err != nil {
+		t.Fatal(err)
+	} else if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(stdout.String(), srv.URL+"/authorize?oauth_token=REQ") {
+		t.Fatalf("unexpected output: %s", stdout.String())
+	}
+
+	// Verify the account was appended and cannot be added twice.
+	if c, err := main.ParseConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	} else if len(c.Accounts) != 1 || c.Accounts[0].Username != "github_go" || c.Accounts[0].Key != "ABC" || c.Accounts[0].Secret != "123" {
+		t.Fatalf("unexpected accounts: %s", spew.Sdump(c.Accounts))
+	}
+	cmd.Stdin = strings.NewReader("1234\n")
+	if err := cmd.Run(); err == nil || err.Error() != "account already configured: github_go" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure environment variables are interpolated and override config values.
+func TestParseConfigFile_Env(t *testing.T) {
+	f, _ := ioutil.TempFile("", "scuttlebuttd-")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`
+[twitter]
+key = "${SCUTTLEBUTT_TEST_KEY}"
+secret = "YYY"
+
+[github]
+token = "ZZZ"
+
+[[account]]
+username = "github.js"
+key = "ABC"
+secret = "123"
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set environment.
+	os.Setenv("SCUTTLEBUTT_TEST_KEY", "XXX")
+	os.Setenv("SCUTTLEBUTT_GITHUB_TOKEN", "TOKEN")
+	os.Setenv("SCUTTLEBUTT_ACCOUNT_GITHUB_JS_SECRET", "456")
+	defer os.Unsetenv("SCUTTLEBUTT_TEST_KEY")
+	defer os.Unsetenv("SCUTTLEBUTT_GITHUB_TOKEN")
+	defer os.Unsetenv("SCUTTLEBUTT_ACCOUNT_GITHUB_JS_SECRET")
+
+	if c, err := main.ParseConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	} else if c.Twitter.Key != "XXX" {
+		t.Fatalf("unexpected twitter key: %s", c.Twitter.Key)
+	} else if c.GitHub.Token != "TOKEN" {
+		t.Fatalf("unexpected github token: %s", c.GitHub.Token)
+	} else if c.Accounts[0].Key != "ABC" {
+		t.Fatalf("unexpected account key: %s", c.Accounts[0].Key)
+	} else if c.Accounts[0].Secret != "456" {
+		t.Fatalf("unexpected account secret: %s", c.Accounts[0].Secret)
+	}
+
+	// Unset variables referenced by the file should return an error.
+	os.Unsetenv("SCUTTLEBUTT_TEST_KEY")
+	if _, err := main.ParseConfigFile(f.Name()); err == nil || err.Error() != `environment variable not set: SCUTTLEBUTT_TEST_KEY` {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// Ensure routing rules are decoded and validated against accounts.
+func TestConfig_Rules(t *testing.T) {
+	c := &main.Config{}
+	if _, err := toml.Decode(`
+[[account]]
+username = "oss_go"
+
+[[rule]]
+name = "popular"
+priority = 10
+languages = ["go"]
+min_stars = 100
+accounts = ["oss_go", "oss_nope"]
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify router conversion.
+	rt := c.Router()
+	if len(rt.Rules) != 1 {
+		t.Fatalf("unexpected rule count: %d", len(rt.Rules))
+	} else if r := rt.Rules[0]; r.Name != "popular" || r.Priority != 10 || r.MinStars != 100 || !reflect.DeepEqual(r.Targets, []string{"oss_go", "oss_nope"}) {
+		t.Fatalf("unexpected rule: %s", spew.Sdump(r))
+	}
+
+	// Verify unknown accounts are reported.
+	var found bool
+	for _, err := range c.Validate() {
+		if err.Error() == "rule[0]: unknown account: oss_nope" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected unknown account error")
+	}
+}
+
+// Ensure accounts require exactly one valid repository source.
+func TestAccount_Validate_Source(t *testing.T) {
+	for _, tt := range []struct {
+		acc main.Account
+		err string
+	}{
+		{acc: main.Account{}, err: "language, topic, or pattern required"},
+		{acc: main.Account{Language: "go", Topic: "cli"}, err: "language, topic, and pattern are mutually exclusive"},
+		{acc: main.Account{Pattern: "(k8s"}, err: "invalid pattern: error parsing regexp: missing closing ): `(k8s`"},
+		{acc: main.Account{Pattern: "k8s", Digest: 3}, err: "label required for pattern digests"},
+		{acc: main.Account{Key: "k", RefreshToken: "r"}, err: "key & secret must not be set with refresh_token"},
+	} {
+		var found bool
+		for _, err := range tt.acc.Validate() {
+			if err.Error() == tt.err {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected error: %s", tt.err)
+		}
+	}
+
+	// Verify a pattern account is valid on its own.
+	acc := main.Account{Username: "TrendingK8s", Key: "k", Secret: "s", Pattern: "(?i)kubernetes|k8s"}
+	if a := acc.Validate(); len(a) != 0 {
+		t.Fatalf("unexpected errors: %v", a)
+	}
+
+	// Verify an OAuth 2.0 account does not require a key & secret.
+	acc = main.Account{Username: "oss_go", RefreshToken: "r", Language: "go"}
+	if a := acc.Validate(); len(a) != 0 {
+		t.Fatalf("unexpected errors: %v", a)
+	}
+}
+
+// Ensure template variants are validated.
+func TestAccount_Validate_Variants(t *testing.T) {
+	for _, tt := range []struct {
+		acc main.Account
+		err string
+	}{
+		{acc: main.Account{Template: "{{.Name}}", Variants: []*main.TemplateVariant{{Name: "a", Weight: 1, Template: "{{.Name}}"}}}, err: "template and variants are mutually exclusive"},
+		{acc: main.Account{Variants: []*main.TemplateVariant{{Weight: 1, Template: "{{.Name}}"}}}, err: "variant name required"},
+		{acc: main.Account{Variants: []*main.TemplateVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}}, err: "duplicate variant: a"},
+		{acc: main.Account{Variants: []*main.TemplateVariant{{Name: "a"}}}, err: "variant weight must be positive: a"},
+		{acc: main.Account{Variants: []*main.TemplateVariant{{Name: "a", Weight: 1, Template: "{{.Nope}}"}}}, err: `invalid variant template: name=a, err=template: notify:1:2: executing "notify" at <.Nope>: can't evaluate field Nope in type *twitter.TemplateData`},
+	} {
+		var found bool
+		for _, err := range tt.acc.Validate() {
+			if err.Error() == tt.err {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected error: %s, got %v", tt.err, tt.acc.Validate())
+		}
+	}
+}
+
+// Ensure remotes require a host and a known type.
+func TestRemote_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		r   main.Remote
+		err string
+	}{
+		{r: main.Remote{Type: "gitlab"}, err: "host required"},
+		{r: main.Remote{Host: "https://gitlab.com", Type: "gitlab"}, err: "invalid host: https://gitlab.com"},
+		{r: main.Remote{Host: "gitlab.com"}, err: "type required"},
+		{r: main.Remote{Host: "bitbucket.org", Type: "bitbucket"}, err: "invalid type: bitbucket"},
+	} {
+		if a := tt.r.Validate(); len(a) != 1 || a[0].Error() != tt.err {
+			t.Errorf("%s: unexpected errors: %v", tt.err, a)
+		}
+	}
+
+	// Verify stores can be created for each type.
+	for _, typ := range []string{"github", "gitlab", "gitea"} {
+		r := main.Remote{Host: "git.example.com", Type: typ}
+		if a := r.Validate(); len(a) != 0 {
+			t.Fatalf("unexpected errors: %v", a)
+		} else if _, err := r.NewStore("", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Ensure the demo command serves synthetic data without credentials.
+func TestDemoCommand_Open(t *testing.T) {
+	cmd := main.NewDemoCommand()
+	cmd.Addr = "127.0.0.1:0"
+	cmd.Seed = 1
+	cmd.PollInterval = 10 * time.Millisecond
+	cmd.Stdout, cmd.Stderr = ioutil.Discard, ioutil.Discard
+	if err := cmd.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Close()
+
+	// Wait for synthetic mentions to show up in the API.
+	for i := 0; ; i++ {
+		resp, err := http.Get("http://" + cmd.ListenerAddr() + "/api/v1/top/overall")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var a []json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&a)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if len(a) > 0 {
+			break
+		} else if i == 100 {
+			t.Fatal("timeout waiting for synthetic data")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// ResetNotifiedCommand represents a command for clearing the notified flag on
+// repositories in bulk, such as after a run that marked repositories as
+// notified without sending any notifications.
+type ResetNotifiedCommand struct {
+	// Data directory & optional config path. The config's storage settings,
+	// such as a separate message file, are used to open the store.
+	DataDir    string
+	ConfigPath string
+
+	// Only reset repositories in this language, if set.
+	Language string
+
+	// Only reset repositories notified before this time, if set.
+	Before time.Time
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewResetNotifiedCommand returns a new instance of ResetNotifiedCommand.
+func NewResetNotifiedCommand() *ResetNotifiedCommand {
+	return &ResetNotifiedCommand{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *ResetNotifiedCommand) ParseFlags(args []string) error {
+	var before string
+	fs := flag.NewFlagSet("scuttlebuttd-reset-notified", flag.ContinueOnError)
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path")
+	fs.StringVar(&cmd.Language, "language", "", "only reset repositories in language")
+	fs.StringVar(&before, "before", "", "only reset repositories notified before date")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.DataDir == "" {
+		return errors.New("data directory required")
+	}
+	if before != "" {
+		t, err := scuttlebutt.ParseDate(before)
+		if err != nil {
+			return fmt.Errorf("invalid before date: %s", before)
+		}
+		cmd.Before = t
+	}
+
+	return nil
+}
+
+// Run clears the notified flag on matching repositories.
+func (cmd *ResetNotifiedCommand) Run() error {
+	c, err := parseOptionalConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("parse config file: %s", err)
+	}
+
+	store := c.NewStore(cmd.DataDir)
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	n, err := store.ResetNotified(cmd.Language, cmd.Before)
+	if err != nil {
+		return fmt.Errorf("reset notified: %s", err)
+	}
+
+	fmt.Fprintf(cmd.Stdout, "reset %d repositories\n", n)
+	return nil
+}
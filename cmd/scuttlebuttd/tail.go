@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt/client"
+)
+
+// DefaultURL is the default URL of the running daemon.
+const DefaultURL = "http://localhost" + DefaultAddr
+
+// TailCommand represents a command for streaming newly ingested mentions
+// from a running daemon to the terminal.
+type TailCommand struct {
+	// Base URL of the running daemon.
+	URL string
+
+	// Only stream mentions of repositories in this language, if set.
+	Language string
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewTailCommand returns a new instance of TailCommand.
+func NewTailCommand() *TailCommand {
+	return &TailCommand{
+		URL:    DefaultURL,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *TailCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-tail", flag.ContinueOnError)
+	fs.StringVar(&cmd.URL, "url", cmd.URL, "daemon URL")
+	fs.StringVar(&cmd.Language, "language", "", "only stream mentions in language")
+	fs.SetOutput(cmd.Stderr)
+	return fs.Parse(args)
+}
+
+// Run prints each mention until the daemon closes the stream.
+func (cmd *TailCommand) Run() error {
+	return cmd.tail(context.Background())
+}
+
+func (cmd *TailCommand) tail(ctx context.Context) error {
+	err := client.New(cmd.URL).StreamMessages(ctx, cmd.Language, func(m *client.MessageAdded) error {
+		language, author := m.Language, m.Author
+		if language == "" {
+			language = "-"
+		}
+		if author == "" {
+			author = "-"
+		} else {
+			author = "@" + author
+		}
+		_, err := fmt.Fprintf(cmd.Stdout, "%s\t%s\t%s\t%s\n", m.RepositoryID, language, author, strings.Join(strings.Fields(m.Text), " "))
+		return err
+	})
+	if err == client.ErrNotFound {
+		return fmt.Errorf("message stream not available: %s", cmd.URL)
+	}
+	return err
+}
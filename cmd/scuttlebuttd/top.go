@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/client"
+)
+
+// TopCommand represents a command for printing the top repositories by
+// recent mentions. The running daemon is queried, if available. Otherwise
+// the store in the data directory is opened read-only.
+type TopCommand struct {
+	// Base URL of the running daemon.
+	URL string
+
+	// Data directory & optional config path used if the daemon cannot be
+	// reached. The config's storage settings are used to open the store.
+	DataDir    string
+	ConfigPath string
+
+	// Only rank repositories in this language, if set.
+	Language string
+
+	// Number of repositories & the time over which mentions are counted.
+	N      int
+	Window time.Duration
+
+	// Input/output streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewTopCommand returns a new instance of TopCommand.
+func NewTopCommand() *TopCommand {
+	return &TopCommand{
+		URL:    DefaultURL,
+		N:      10,
+		Window: scuttlebutt.DefaultRecentWindow,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// ParseFlags parses the command line flags.
+func (cmd *TopCommand) ParseFlags(args []string) error {
+	fs := flag.NewFlagSet("scuttlebuttd-top", flag.ContinueOnError)
+	fs.StringVar(&cmd.URL, "url", cmd.URL, "daemon URL")
+	fs.StringVar(&cmd.DataDir, "d", "", "data directory, used if the daemon is down")
+	fs.StringVar(&cmd.ConfigPath, "c", "", "config path, used if the daemon is down")
+	fs.StringVar(&cmd.Language, "language", "", "only rank repositories in language")
+	fs.IntVar(&cmd.N, "n", cmd.N, "number of repositories")
+	fs.DurationVar(&cmd.Window, "window", cmd.Window, "time over which mentions are counted")
+	fs.SetOutput(cmd.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Validate options.
+	if cmd.N <= 0 || cmd.N > scuttlebutt.MaxTopOverallN {
+		return fmt.Errorf("n must be between 1 and %d", scuttlebutt.MaxTopOverallN)
+	} else if cmd.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	return nil
+}
+
+// Run prints the ranked repositories as a table.
+func (cmd *TopCommand) Run() error {
+	a, err := client.New(cmd.URL).RecentTopRepositories(context.Background(), cmd.Language, cmd.N, cmd.Window)
+	if _, ok := err.(*url.Error); ok && cmd.DataDir != "" {
+		fmt.Fprintf(cmd.Stderr, "daemon unavailable, reading %s: %s\n", cmd.DataDir, err)
+		a, err = cmd.query()
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tREPOSITORY\tLANGUAGE\tMENTIONS\tSTARS")
+	for _, r := range a {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\n", r.Rank, r.ID, r.Language, r.Mentions, r.Stars)
+	}
+	return tw.Flush()
+}
+
+// query ranks the repositories from the store in the data directory.
+func (cmd *TopCommand) query() ([]*client.RecentRepository, error) {
+	c, err := parseOptionalConfigFile(cmd.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file: %s", err)
+	}
+
+	store := c.NewStore(cmd.DataDir)
+	store.ReadOnly = true
+	if err := store.Open(); err != nil {
+		return nil, fmt.Errorf("open store: %s", err)
+	}
+	defer store.Close()
+
+	repos, err := store.RecentTopRepositories(cmd.Language, time.Now().Add(-cmd.Window), cmd.N)
+	if err != nil {
+		return nil, fmt.Errorf("top repositories: %s", err)
+	}
+
+	a := make([]*client.RecentRepository, len(repos))
+	for i, r := range repos {
+		a[i] = &client.RecentRepository{
+			Rank:     i + 1,
+			ID:       r.ID,
+			Language: store.LanguageAliases.Normalize(r.Language),
+			Stars:    r.Stars,
+			Mentions: len(r.Messages),
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,45 @@
+package scuttlebutt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ContentFilter represents a blocklist applied to repository names and
+// descriptions before they are posted. Matching repositories are flagged
+// for manual review instead of being notified.
+type ContentFilter struct {
+	// Words matched case insensitively against whole words.
+	Words []string
+
+	// Patterns matched against the name and the description.
+	Patterns []*regexp.Regexp
+}
+
+// Check returns the reason r is blocked by the filter.
+// Returns a blank string if r can be posted.
+func (f *ContentFilter) Check(r *Repository) string {
+	for _, field := range []struct{ name, value string }{
+		{"name", r.Name()},
+		{"description", r.Description},
+	} {
+		// Split on anything that is not a letter or digit so that words
+		// separated by dashes or underscores in names are matched.
+		for _, word := range strings.FieldsFunc(field.value, func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		}) {
+			if containsFold(f.Words, word) {
+				return fmt.Sprintf("blocked word in %s: %s", field.name, word)
+			}
+		}
+
+		for _, re := range f.Patterns {
+			if re.MatchString(field.value) {
+				return fmt.Sprintf("blocked pattern in %s: %s", field.name, re)
+			}
+		}
+	}
+	return ""
+}
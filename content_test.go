@@ -0,0 +1,32 @@
+package scuttlebutt_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the content filter matches words and patterns in names & descriptions.
+func TestContentFilter_Check(t *testing.T) {
+	f := &scuttlebutt.ContentFilter{
+		Words:    []string{"darn"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`(?i)h[e3]ck`)},
+	}
+	for i, tt := range []struct {
+		id          string
+		description string
+		reason      string
+	}{
+		{id: "github.com/user/tool", description: "A useful tool"},
+		{id: "github.com/user/darned", description: "Whole words only"},
+		{id: "github.com/user/darn-it", reason: "blocked word in name: darn"},
+		{id: "github.com/user/tool", description: "Darn.", reason: "blocked word in description: Darn"},
+		{id: "github.com/user/tool", description: "What the H3CK", reason: "blocked pattern in description: (?i)h[e3]ck"},
+	} {
+		r := &scuttlebutt.Repository{ID: tt.id, Description: tt.description}
+		if reason := f.Check(r); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+}
@@ -0,0 +1,2262 @@
+package scuttlebutt
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/events"
+	"github.com/benbjohnson/scuttlebutt/statsd"
+	"github.com/benbjohnson/scuttlebutt/tracing"
+)
+
+const (
+	// DefaultPollInterval is the default time between Twitter polling.
+	DefaultPollInterval = 30 * time.Second
+
+	// DefaultMaxPollBackoff is the default maximum time between polls after
+	// repeated poll errors.
+	DefaultMaxPollBackoff = 15 * time.Minute
+
+	// DefaultNotifyInterval is the default time between individual account notifications.
+	DefaultNotifyInterval = 4 * time.Hour
+
+	// DefaultNotifyCheckInterval is the default time between notification checks.
+	DefaultNotifyCheckInterval = 30 * time.Minute
+
+	// DefaultMaxDeferred is the maximum number of messages held in memory
+	// for a later poll cycle when the retry queue is disabled. Messages
+	// deferred beyond it are dropped.
+	DefaultMaxDeferred = 10000
+
+	// DefaultMaxMessageFailures is the number of times a message can fail
+	// ingestion before it is quarantined.
+	DefaultMaxMessageFailures = 3
+
+	// DefaultMaxQuarantined is the number of quarantined messages retained.
+	DefaultMaxQuarantined = 100
+
+	// DefaultFeaturedWindow is the default time before a repository or an
+	// identical notification text can be featured again.
+	DefaultFeaturedWindow = 24 * time.Hour
+
+	// DefaultRefreshAge is the default age at which repository metadata is
+	// fetched again by the refresher.
+	DefaultRefreshAge = 7 * 24 * time.Hour
+
+	// DefaultElectionRetryInterval is the default time between campaigns
+	// after an election error.
+	DefaultElectionRetryInterval = 10 * time.Second
+
+	// DefaultRetryInterval is the default time between retry cycles.
+	DefaultRetryInterval = time.Minute
+
+	// DefaultSnapshotInterval is the default time between checking whether
+	// the day's snapshot has been recorded.
+	DefaultSnapshotInterval = time.Hour
+
+	// DefaultSnapshotN is the default number of repositories per language
+	// recorded in each daily snapshot.
+	DefaultSnapshotN = 10
+
+	// DefaultEngagementWindow is the default age of notifications whose
+	// engagement counts are still fetched.
+	DefaultEngagementWindow = 7 * 24 * time.Hour
+
+	// DefaultRetryLimit is the default number of messages retried per cycle.
+	DefaultRetryLimit = 100
+
+	// DefaultMaxRetryAttempts is the default number of failed attempts
+	// before a message in the retry queue is quarantined.
+	DefaultMaxRetryAttempts = 10
+
+	// DefaultRefreshLimit is the default number of repositories refreshed
+	// per refresh cycle.
+	DefaultRefreshLimit = 50
+)
+
+var (
+	// ErrDaemonRunning is returned when starting a daemon that is already running.
+	ErrDaemonRunning = errors.New("daemon already running")
+
+	// ErrNotificationTooLong is returned by a notifier when the notification
+	// text exceeds the maximum length allowed by the service.
+	ErrNotificationTooLong = errors.New("notification too long")
+
+	// ErrAccountNotFound is returned when an account does not exist.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrNotLeader is returned when notifying from a daemon that is not
+	// running the notifier.
+	ErrNotLeader = errors.New("not leader")
+
+	// ErrNotEligible is returned when forcing a notification for a repository
+	// that is excluded, below the thresholds, or recently featured.
+	ErrNotEligible = errors.New("repository not eligible")
+
+	// ErrLookupLimit is recorded for messages deferred to the retry queue
+	// because the lookup limit was reached during a poll cycle.
+	ErrLookupLimit = errors.New("lookup limit reached")
+)
+
+// Poller represents a source of messages mentioning repositories.
+type Poller interface {
+	Poll(ctx context.Context, sinceID uint64) ([]*Message, error)
+}
+
+// Notifier represents an account that posts notifications about repositories.
+type Notifier interface {
+	// Returns the time of the last notification. Zero if none have been sent.
+	// Only used when the store has no notification time for the account.
+	LastTweetTime() (time.Time, error)
+
+	// Returns true if a notification can be sent given the last notification time.
+	Due(now, last time.Time) bool
+
+	// Posts a notification about r. Returns the posted message.
+	Notify(ctx context.Context, r *Repository) (*Message, error)
+
+	// Returns the notification text for r.
+	Text(r *Repository) (string, error)
+}
+
+// Account represents a notifier and the routing information for it.
+type Account struct {
+	Username string
+	Language string
+	Notifier Notifier
+
+	// Optional GitHub topic. If set, the account is notified of the top
+	// repositories tagged with the topic instead of the language.
+	Topic string
+
+	// Optional pattern matched against repository names & descriptions.
+	// If set, the account is notified of the top matching repositories
+	// instead of the language, such as anything mentioning "kubernetes".
+	Pattern *regexp.Regexp
+
+	// Number of repositories posted together as a digest. The notifier must
+	// implement DigestNotifier. One repository is posted at a time if zero.
+	DigestN int
+
+	// If true, candidate notifications are queued in the store and are
+	// only sent after being approved by a moderator.
+	Moderated bool
+}
+
+// Key returns the key of the account's repositories in TopRepositories().
+// Pattern accounts have no key and are queried from the store instead.
+func (acc *Account) Key() string {
+	if acc.Topic != "" {
+		return TopicKey(acc.Topic)
+	}
+	return acc.Language
+}
+
+// TextPoster represents a notifier that can post previously generated text.
+type TextPoster interface {
+	Post(ctx context.Context, r *Repository, text string) (*Message, error)
+}
+
+// DigestNotifier represents a notifier that can post several repositories
+// in a single notification.
+type DigestNotifier interface {
+	NotifyDigest(ctx context.Context, a []*Repository) ([]*Message, error)
+}
+
+// EngagementFetcher represents a notifier that can retrieve the engagement
+// counts of messages it sent. Messages that no longer exist are omitted.
+type EngagementFetcher interface {
+	Engagement(ctx context.Context, ids []uint64) (map[uint64]*Engagement, error)
+}
+
+// TemplateNamer represents a notifier that chooses between named template
+// variants. The name is recorded with each notification so the engagement of
+// each variant can be compared.
+type TemplateNamer interface {
+	TemplateName(repositoryID string) string
+}
+
+// RateLimitedNotifier represents a notifier that tracks its API quotas.
+// Quotas are persisted by the daemon so that throttled accounts are deferred
+// across restarts.
+type RateLimitedNotifier interface {
+	RateLimits() []*RateLimit
+	SetRateLimits(a []*RateLimit)
+}
+
+// ErrorReporter represents a service that receives errors the daemon logs
+// and continues past. Tags identify the operation, such as "op", "username",
+// and "repo".
+type ErrorReporter interface {
+	ReportError(err error, tags map[string]string)
+}
+
+// Elector elects a single leader among daemon replicas.
+type Elector interface {
+	// Campaign blocks until leadership is acquired or ctx is done. The
+	// returned channel is closed if leadership is later lost.
+	Campaign(ctx context.Context) (<-chan struct{}, error)
+
+	// Resign gives up leadership so another replica can be elected.
+	Resign() error
+}
+
+// Daemon represents a long running process that polls for messages, saves
+// them to the store, and periodically notifies accounts of top repositories.
+type Daemon struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing chan struct{}
+	cancel  context.CancelFunc
+	ln      net.Listener
+	grpcLn  net.Listener
+	leader  int32
+
+	// Ingestion state. Messages waiting on a later poll cycle are deferred
+	// and messages that repeatedly fail are quarantined.
+	imu        sync.Mutex
+	deferred   []*Message
+	droppedN   int
+	failures   map[uint64]int
+	quarantine []*QuarantinedMessage
+	errorN     map[string]int
+	spamN      map[SpamReason]int
+
+	// Health of each message source by name.
+	sourceStatus map[string]*SourceStatus
+
+	// Notification counts & most recent pick by account username.
+	nmu           sync.Mutex
+	accountStatus map[string]*AccountStatus
+	explanations  map[string]*Explanation
+
+	// Data store. Must be opened and closed by the caller.
+	Store *Store
+
+	// Source of messages & notification accounts.
+	Poller   Poller
+	Accounts []*Account
+
+	// Additional message sources. Each source is polled separately on its
+	// own interval.
+	Sources []*Source
+
+	// Routes repositories to accounts. Accounts not routed use their language.
+	Router *Router
+
+	// HTTP bind address. The HTTP server is not started if blank.
+	Addr    string
+	Handler *Handler
+
+	// Optional destination & format of the HTTP access log. Requests are
+	// not logged if the writer is nil.
+	AccessLog       io.Writer
+	AccessLogFormat string
+
+	// If true, responses are not gzip encoded for clients that accept it.
+	DisableCompression bool
+
+	// Token required to push messages to the HTTP ingestion endpoint. The
+	// endpoint is disabled if blank. Repository URLs are converted to IDs
+	// with IngestRepositoryID, if set, or ExtractRepositoryID otherwise.
+	IngestToken        string
+	IngestRepositoryID func(rawurl string) string
+
+	// Token required to call the HTTP admin endpoints. The endpoints are
+	// disabled if blank.
+	AdminToken string
+
+	// gRPC bind address. Calls are served over unencrypted HTTP/2. Writes
+	// must authenticate with IngestToken or AdminToken. The gRPC server is
+	// not started if blank.
+	GRPCAddr string
+
+	// Duration between polling for mentions.
+	PollInterval time.Duration
+
+	// Maximum time between polls while backing off from poll errors.
+	MaxPollBackoff time.Duration
+
+	// Time between checking if notification interval has passed.
+	NotifyCheckInterval time.Duration
+
+	// Maximum number of new repositories looked up remotely per poll cycle.
+	// Remaining messages are saved to the retry queue, if enabled, or
+	// deferred to the next cycle. Zero is unlimited.
+	LookupLimit int
+
+	// Time during which a repository or identical notification text cannot
+	// be featured again by any account. Disabled if zero.
+	FeaturedWindow time.Duration
+
+	// Time between refreshing stale repository metadata, such as languages
+	// that were unknown when a repository was first seen. Repositories
+	// fetched longer than RefreshAge ago are refreshed, up to RefreshLimit
+	// per cycle. The refresher is disabled if the interval is zero.
+	RefreshInterval time.Duration
+	RefreshAge      time.Duration
+	RefreshLimit    int
+
+	// Time between retrying messages that failed with a transient error,
+	// such as a network error or an exhausted quota. Failed messages are
+	// saved to the store's retry queue and up to RetryLimit are retried per
+	// cycle until MaxRetryAttempts is reached. If zero, failed messages are
+	// retried in memory on later polls.
+	RetryInterval    time.Duration
+	RetryLimit       int
+	MaxRetryAttempts int
+
+	// Time between fetching engagement counts for messages sent within
+	// EngagementWindow. Only notifiers that implement EngagementFetcher are
+	// queried. Disabled if the interval is zero.
+	EngagementInterval time.Duration
+	EngagementWindow   time.Duration
+
+	// Time between checking whether the day's snapshot of the top
+	// SnapshotN repositories per language has been recorded. Snapshots are
+	// recorded once per UTC day. Disabled if the interval is zero.
+	SnapshotInterval time.Duration
+	SnapshotN        int
+
+	// Optional blocklist for repository names & descriptions. Blocked
+	// repositories are flagged for review and are not ranked until approved.
+	ContentFilter *ContentFilter
+
+	// Minimum activity required before a picked repository is notified.
+	// Picks are checked again after they are refreshed. Set the store's
+	// Thresholds as well so that repositories below them are passed over
+	// for the next eligible repository instead of blocking the account.
+	// Approved notifications for moderated accounts are not checked.
+	Thresholds Thresholds
+
+	// Optional bus that completed polls & sent notifications are
+	// published to.
+	Events *events.Bus
+
+	// Optional tracer for poll cycles & notification sends.
+	Tracer *tracing.Tracer
+
+	// Optional statsd client for poll durations, API calls, queue depths,
+	// & rate limits.
+	Statsd *statsd.Client
+
+	// Optional reporter for poll, notify, & store errors.
+	ErrorReporter ErrorReporter
+
+	// Optional elector for running multiple replicas. Replicas serve the
+	// HTTP API but only the leader polls and notifies.
+	Elector Elector
+
+	// Time to wait after a failed campaign before campaigning again.
+	ElectionRetryInterval time.Duration
+
+	// If true, only the HTTP API is served. Pollers, the notifier, and
+	// other background jobs that write to the store are not started.
+	ReadOnly bool
+
+	// Optional pipeline that polled messages are enqueued on while the
+	// daemon is running. Messages are saved during the poll if nil.
+	Pipeline *Pipeline
+
+	// Destination for log output.
+	LogOutput io.Writer
+}
+
+// NewDaemon returns a new instance of Daemon with default settings.
+func NewDaemon() *Daemon {
+	return &Daemon{
+		PollInterval:          DefaultPollInterval,
+		MaxPollBackoff:        DefaultMaxPollBackoff,
+		NotifyCheckInterval:   DefaultNotifyCheckInterval,
+		FeaturedWindow:        DefaultFeaturedWindow,
+		RefreshAge:            DefaultRefreshAge,
+		RefreshLimit:          DefaultRefreshLimit,
+		RetryLimit:            DefaultRetryLimit,
+		ElectionRetryInterval: DefaultElectionRetryInterval,
+		MaxRetryAttempts:      DefaultMaxRetryAttempts,
+		SnapshotN:             DefaultSnapshotN,
+		EngagementWindow:      DefaultEngagementWindow,
+		LogOutput:             os.Stderr,
+	}
+}
+
+// Start opens the HTTP listener and begins polling and notifying in separate
+// goroutines. The daemon is stopped when ctx is done or Stop() is called.
+// A stopped daemon can be started again.
+func (d *Daemon) Start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closing != nil {
+		return ErrDaemonRunning
+	}
+
+	// Open HTTP listener, if an address is specified.
+	if d.Addr != "" {
+		ln, err := net.Listen("tcp", d.Addr)
+		if err != nil {
+			return err
+		}
+		d.ln = ln
+
+		if d.Handler == nil {
+			d.Handler = &Handler{
+				Store:          d.Store,
+				PollerStatus:   d.PollerStatus,
+				SourceStatus:   d.SourceStatus,
+				NotifierStatus: d.NotifierStatus,
+				Explain:        d.Explain,
+				ReadOnly:       d.ReadOnly,
+
+				ForceNotify: d.ForceNotify,
+				AdminToken:  d.AdminToken,
+
+				Ingest:             d.Ingest,
+				IngestToken:        d.IngestToken,
+				IngestRepositoryID: d.IngestRepositoryID,
+
+				Events: d.Events,
+			}
+		}
+
+		var h http.Handler = d.Handler
+		if !d.DisableCompression {
+			h = NewGzipHandler(h)
+		}
+		if d.AccessLog != nil {
+			h = NewAccessLogHandler(h, d.AccessLog, d.AccessLogFormat)
+		}
+
+		log.New(d.LogOutput, "", log.LstdFlags).Printf("Listening on http://%s", ln.Addr())
+		go http.Serve(ln, h)
+	}
+
+	// Open gRPC listener, if an address is specified.
+	if d.GRPCAddr != "" {
+		ln, err := net.Listen("tcp", d.GRPCAddr)
+		if err != nil {
+			if d.ln != nil {
+				d.ln.Close()
+				d.ln = nil
+			}
+			return err
+		}
+		d.grpcLn = ln
+
+		srv := &http.Server{
+			Handler: &GRPCServer{
+				Store:       d.Store,
+				ReadOnly:    d.ReadOnly,
+				IngestToken: d.IngestToken,
+				AdminToken:  d.AdminToken,
+			},
+			Protocols: new(http.Protocols),
+		}
+		srv.Protocols.SetUnencryptedHTTP2(true)
+
+		log.New(d.LogOutput, "", log.LstdFlags).Printf("Listening for gRPC on %s", ln.Addr())
+		go srv.Serve(ln)
+	}
+
+	// Stop the daemon when the context is done.
+	closing := make(chan struct{})
+	d.closing = closing
+	runCtx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.stopWhenDone(ctx, closing)
+
+	// Only the HTTP API is served while read-only. Replicas only poll &
+	// notify while they are the leader.
+	if d.ReadOnly {
+		return nil
+	} else if d.Elector != nil {
+		d.wg.Add(1)
+		go d.runElection(runCtx)
+		return nil
+	}
+
+	// In-flight API calls & scans are cancelled when the daemon is stopped.
+	d.startWorkers(runCtx, &d.wg)
+	return nil
+}
+
+// runElection campaigns for leadership and runs the background jobs while
+// this replica is the leader. Leadership is resigned when ctx is done.
+func (d *Daemon) runElection(ctx context.Context) {
+	defer d.wg.Done()
+
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[election] ", log.LstdFlags)
+
+	for {
+		lost, err := d.Elector.Campaign(ctx)
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			logger.Printf("campaign error: %s, retrying in %s", err, d.ElectionRetryInterval)
+			d.report("campaign", err)
+
+			select {
+			case <-time.After(d.ElectionRetryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// Run jobs until leadership is lost or the daemon is stopped.
+		logger.Printf("elected leader")
+		atomic.StoreInt32(&d.leader, 1)
+		termCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		d.startWorkers(termCtx, &wg)
+		select {
+		case <-lost:
+			logger.Printf("leadership lost")
+		case <-ctx.Done():
+		}
+		cancel()
+		wg.Wait()
+		atomic.StoreInt32(&d.leader, 0)
+
+		// Release any remaining hold on leadership before campaigning again.
+		if err := d.Elector.Resign(); err != nil {
+			logger.Printf("resign error: %s", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Leader returns true if the daemon is running its background jobs. Daemons
+// without an elector are always the leader once started.
+func (d *Daemon) Leader() bool {
+	if d.Elector == nil {
+		return d.Running() && !d.ReadOnly
+	}
+	return atomic.LoadInt32(&d.leader) == 1
+}
+
+// startWorkers starts the ingestion pipeline, pollers, notify monitor, and
+// other background jobs. Each job is added to wg and exits when ctx is done.
+func (d *Daemon) startWorkers(ctx context.Context, wg *sync.WaitGroup) {
+	// Start ingestion stages before the pollers that feed them. The stages
+	// are stopped once the pollers have exited.
+	var pollerWG sync.WaitGroup
+	if d.Pipeline != nil {
+		logger := log.New(d.LogOutput, "[poller] ", log.LstdFlags)
+		d.Pipeline.Handler = func(a []*Message, errs []error) { d.saved(logger, a, errs) }
+		if err := d.Pipeline.Open(); err != nil {
+			logger.Printf("open pipeline error: %s", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			pollerWG.Wait()
+			d.Pipeline.Close()
+		}()
+	}
+
+	for _, src := range d.sources() {
+		src := src
+		wg.Add(1)
+		pollerWG.Add(1)
+		go func() {
+			defer wg.Done()
+			defer pollerWG.Done()
+			d.runPoller(ctx, src)
+		}()
+	}
+
+	jobs := []func(context.Context){d.runNotifier}
+	if d.RefreshInterval > 0 {
+		jobs = append(jobs, d.runRefresher)
+	}
+	if d.RetryInterval > 0 {
+		jobs = append(jobs, d.runRetrier)
+	}
+	if d.SnapshotInterval > 0 {
+		jobs = append(jobs, d.runSnapshotter)
+	}
+	if d.EngagementInterval > 0 {
+		jobs = append(jobs, d.runEngagementFetcher)
+	}
+	for _, fn := range jobs {
+		fn := fn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+		}()
+	}
+}
+
+// stopWhenDone stops the daemon when ctx is done.
+func (d *Daemon) stopWhenDone(ctx context.Context, closing chan struct{}) {
+	select {
+	case <-ctx.Done():
+		d.Stop()
+	case <-closing:
+	}
+}
+
+// Stop shuts down the HTTP listener and waits for all goroutines to finish.
+// Stopping a daemon that is not running is a no-op.
+func (d *Daemon) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closing == nil {
+		return nil
+	}
+
+	// Close HTTP & gRPC listeners.
+	if d.ln != nil {
+		d.ln.Close()
+		d.ln = nil
+	}
+	if d.grpcLn != nil {
+		d.grpcLn.Close()
+		d.grpcLn = nil
+	}
+
+	// Notify goroutines of closing and wait for them to finish.
+	close(d.closing)
+	d.cancel()
+	d.wg.Wait()
+	d.closing, d.cancel = nil, nil
+
+	return nil
+}
+
+// Close stops the daemon. Calling Close multiple times is safe.
+func (d *Daemon) Close() error { return d.Stop() }
+
+// Running returns true if the daemon has been started and not yet stopped.
+func (d *Daemon) Running() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closing != nil
+}
+
+// ListenerAddr returns the address of the HTTP listener. Returns nil if not listening.
+func (d *Daemon) ListenerAddr() net.Addr {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ln == nil {
+		return nil
+	}
+	return d.ln.Addr()
+}
+
+// GRPCListenerAddr returns the address of the gRPC listener. Returns nil if
+// not listening.
+func (d *Daemon) GRPCListenerAddr() net.Addr {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.grpcLn == nil {
+		return nil
+	}
+	return d.grpcLn.Addr()
+}
+
+// DefaultSourceName is the name of the source for the daemon's Poller.
+const DefaultSourceName = "default"
+
+// Source represents a named source of messages with its own poll interval.
+type Source struct {
+	Name   string
+	Poller Poller
+
+	// Duration between polls. Uses the daemon's poll interval if zero.
+	PollInterval time.Duration
+}
+
+// sources returns the daemon's poller followed by any additional sources.
+func (d *Daemon) sources() []*Source {
+	var a []*Source
+	if d.Poller != nil {
+		a = append(a, d.defaultSource())
+	}
+	for _, src := range d.Sources {
+		other := *src
+		if other.PollInterval <= 0 {
+			other.PollInterval = d.PollInterval
+		}
+		a = append(a, &other)
+	}
+	return a
+}
+
+// defaultSource returns the source for the daemon's poller.
+func (d *Daemon) defaultSource() *Source {
+	return &Source{Name: DefaultSourceName, Poller: d.Poller, PollInterval: d.PollInterval}
+}
+
+// runPoller periodically searches a source for messages mentioning repositories.
+func (d *Daemon) runPoller(ctx context.Context, src *Source) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[poller] ", log.LstdFlags)
+
+	var sinceID uint64
+	for {
+		// Back off after errors until the rate limit resets or, otherwise,
+		// exponentially with each consecutive failure.
+		interval, err := d.PollSource(ctx, src, &sinceID)
+		if err != nil && ctx.Err() == nil {
+			logger.Printf("%s: poll error: %s, retrying in %s", src.Name, err, interval)
+			d.report("poll", err, "source", src.Name)
+		}
+
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pollVars publishes the health of each source as expvars.
+var pollVars = expvar.NewMap("poller")
+
+// PollSource polls a source and records its health. Returns the time to wait
+// before polling the source again.
+func (d *Daemon) PollSource(ctx context.Context, src *Source, sinceID *uint64) (time.Duration, error) {
+	_, interval, err := d.pollSource(ctx, src, sinceID)
+	return interval, err
+}
+
+// pollSource polls a source and records its health. Returns the number of
+// messages saved or enqueued and the time to wait before the next poll.
+func (d *Daemon) pollSource(ctx context.Context, src *Source, sinceID *uint64) (int, time.Duration, error) {
+	span := d.Tracer.Start("poll")
+	span.SetAttribute("source", src.Name)
+
+	t := time.Now()
+	n, err := d.poll(ctx, src, sinceID)
+
+	span.SetAttribute("messages", n)
+	span.SetError(err)
+	span.End()
+
+	tag := "source:" + src.Name
+	d.Statsd.Timing("poll.duration", time.Since(t), tag)
+	d.Statsd.Count("api.calls", 1, tag, "api:poll", statusTag(err))
+
+	e := &events.PollCompleted{Source: src.Name, MessageN: n, Duration: time.Since(t)}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	d.Events.Publish(e)
+
+	return n, d.recordPoll(src, n, err, time.Now()), err
+}
+
+// recordPoll updates the health of a source after a poll and returns the
+// time to wait before the next poll.
+func (d *Daemon) recordPoll(src *Source, n int, err error, now time.Time) time.Duration {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	if d.sourceStatus == nil {
+		d.sourceStatus = make(map[string]*SourceStatus)
+	}
+	status := d.sourceStatus[src.Name]
+	if status == nil {
+		status = &SourceStatus{Name: src.Name}
+		d.sourceStatus[src.Name] = status
+
+		name := src.Name
+		pollVars.Set(name, expvar.Func(func() interface{} { return d.sourceStatusByName(name) }))
+	}
+	status.PollInterval = src.PollInterval.String()
+	status.LastPollTime = timePtr(now)
+
+	// Reset the backoff after a successful poll.
+	if err == nil {
+		status.LastSuccessTime = timePtr(now)
+		status.FailureN, status.LastError, status.RetryAt = 0, "", nil
+		status.MessageN += n
+		return src.PollInterval
+	}
+
+	status.FailureN++
+	status.LastError = err.Error()
+	interval := PollBackoff(src.PollInterval, d.MaxPollBackoff, status.FailureN)
+	if e, ok := err.(*RateLimitError); ok && e.Reset.After(now) {
+		interval = e.Reset.Sub(now)
+	}
+	status.RetryAt = timePtr(now.Add(interval))
+	return interval
+}
+
+// SourceStatus returns the health of each source, sorted by name.
+func (d *Daemon) SourceStatus() []*SourceStatus {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	a := make([]*SourceStatus, 0, len(d.sourceStatus))
+	for _, status := range d.sourceStatus {
+		other := *status
+		a = append(a, &other)
+	}
+	sort.Sort(sourceStatusesByName(a))
+	return a
+}
+
+// sourceStatusByName returns a copy of the health of a source.
+func (d *Daemon) sourceStatusByName(name string) *SourceStatus {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+	if status := d.sourceStatus[name]; status != nil {
+		other := *status
+		return &other
+	}
+	return nil
+}
+
+// timePtr returns a pointer to t.
+func timePtr(t time.Time) *time.Time { return &t }
+
+// PollBackoff returns the time to wait after n consecutive poll failures. The
+// interval doubles with each failure, up to max, and is randomly reduced by
+// up to half to spread out retries.
+func PollBackoff(interval, max time.Duration, n int) time.Duration {
+	d := interval
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IngestSourcePrefix prefixes the source name of pushed messages so their
+// health is tracked separately from polled sources.
+const IngestSourcePrefix = "ingest:"
+
+// Ingest saves messages pushed from an external source, such as a scraper, in
+// the same way as polled messages. Returns the number of messages saved or
+// enqueued.
+func (d *Daemon) Ingest(ctx context.Context, source string, a []*Message) (int, error) {
+	if source == "" {
+		source = "default"
+	}
+	src := &Source{Name: IngestSourcePrefix + source, Poller: messagesPoller(a)}
+
+	var sinceID uint64
+	n, _, err := d.pollSource(ctx, src, &sinceID)
+	return n, err
+}
+
+// messagesPoller is a poller that returns a fixed set of messages.
+type messagesPoller []*Message
+
+func (p messagesPoller) Poll(ctx context.Context, sinceID uint64) ([]*Message, error) {
+	return p, nil
+}
+
+// Poll retrieves messages since a given ID and saves them to the store.
+// The sinceID is updated if any messages are retrieved. Messages that fail to
+// be saved are retried on later polls and are quarantined after repeated failures.
+func (d *Daemon) Poll(ctx context.Context, sinceID *uint64) error {
+	_, err := d.PollSource(ctx, d.defaultSource(), sinceID)
+	return err
+}
+
+// poll retrieves messages from a source and saves them to the store, or
+// enqueues them if the pipeline is open. Returns the number of messages saved
+// or enqueued.
+func (d *Daemon) poll(ctx context.Context, src *Source, sinceID *uint64) (int, error) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[poller] ", log.LstdFlags)
+
+	// Retrieve messages from poller.
+	poller := src.Poller
+	messages, err := poller.Poll(ctx, *sinceID)
+	if e, ok := err.(*RateLimitError); ok {
+		return 0, e
+	} else if err != nil {
+		return 0, fmt.Errorf("poll: %s", err)
+	}
+	stats.Add(StatMessagesFetched, int64(len(messages)))
+
+	// Record the source of each message. Pushed messages are recorded under
+	// the name of their external source, without the ingestion prefix.
+	for _, message := range messages {
+		if message.Source == "" {
+			message.Source = strings.TrimPrefix(src.Name, IngestSourcePrefix)
+		}
+	}
+
+	// Retry deferred messages before new ones. Pushed messages are saved on
+	// their own so the count reflects only the pushed batch.
+	if _, ok := poller.(messagesPoller); !ok {
+		d.imu.Lock()
+		messages, d.deferred = append(d.deferred, messages...), nil
+		d.imu.Unlock()
+	}
+
+	// Filter messages that can be saved during this cycle.
+	var lookupN int
+	pending := make([]*Message, 0, len(messages))
+	for _, message := range messages {
+		// Update the highest "since id".
+		if message.ID > *sinceID {
+			*sinceID = message.ID
+		}
+
+		// Defer messages for unknown repositories once the lookup limit is hit.
+		if d.LookupLimit > 0 {
+			if exists, err := d.Store.HasRepository(message.RepositoryID); err != nil {
+				d.fail(logger, message, err)
+				continue
+			} else if !exists && lookupN >= d.LookupLimit {
+				d.deferMessage(logger, message, ErrLookupLimit)
+				continue
+			} else if !exists {
+				lookupN++
+			}
+		}
+		pending = append(pending, message)
+	}
+
+	// Hand messages to the pipeline, blocking while its queue is full.
+	// Messages that could not be enqueued are retried on the next poll.
+	if d.Pipeline != nil && d.Pipeline.Opened() {
+		n, err := d.Pipeline.Enqueue(ctx, pending)
+		if err != nil {
+			for _, message := range pending[n:] {
+				d.deferMessage(logger, message, err)
+			}
+			return n, fmt.Errorf("enqueue: %s", err)
+		}
+		return n, nil
+	}
+
+	// Save messages to store in a single transaction.
+	return d.saved(logger, pending, d.Store.AddMessages(ctx, pending)), nil
+}
+
+// saved records the result of saving each message. Messages waiting on the
+// remote quota or that failed are retried later. Returns the number of
+// messages saved.
+func (d *Daemon) saved(logger *log.Logger, pending []*Message, errs []error) int {
+	var throttle *RateLimitError
+	var throttledN, savedN int
+	for i, message := range pending {
+		if err := errs[i]; err == ErrRepositoryNotFound || err == ErrOptedOut || err == ErrBlacklisted {
+			// nop
+		} else if e, ok := err.(*SpamError); ok {
+			d.spam(e.Reason)
+		} else if e, ok := err.(*RateLimitError); ok {
+			throttle = e
+			throttledN++
+			d.deferMessage(logger, message, err)
+			continue
+		} else if _, ok := err.(*RemoteError); ok && d.RetryInterval > 0 {
+			d.retry(logger, message, err)
+			continue
+		} else if err != nil {
+			d.fail(logger, message, err)
+			continue
+		} else {
+			savedN++
+		}
+
+		// Clear any previous failures.
+		d.imu.Lock()
+		delete(d.failures, message.ID)
+		d.imu.Unlock()
+	}
+
+	// Messages waiting on the remote quota are retried once it resets.
+	if throttle != nil {
+		logger.Printf("%s, deferred %d messages", throttle, throttledN)
+	}
+
+	return savedN
+}
+
+// runRefresher periodically refreshes stale repository metadata.
+func (d *Daemon) runRefresher(ctx context.Context) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[refresher] ", log.LstdFlags)
+
+	for {
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(d.RefreshInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		if err := d.Refresh(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("refresh error: %s", err)
+			d.report("refresh", err)
+		}
+	}
+}
+
+// Refresh fetches metadata for repositories that have not been fetched
+// within the refresh age and updates them in place. Refreshing stops early
+// if the remote quota is exhausted or ctx is done.
+func (d *Daemon) Refresh(ctx context.Context) error {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[refresher] ", log.LstdFlags)
+
+	ids, err := d.Store.StaleRepositories(time.Now().Add(-d.RefreshAge), d.RefreshLimit)
+	if err != nil {
+		return fmt.Errorf("stale repositories: %s", err)
+	}
+
+	var n int
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := d.Store.RefreshRepository(ctx, id); err == ErrRepositoryNotFound || err == ErrOptedOut {
+			continue
+		} else if e, ok := err.(*RateLimitError); ok {
+			logger.Printf("%s, refreshed %d of %d repositories", e, n, len(ids))
+			return nil
+		} else if err != nil {
+			logger.Printf("refresh repository error: repo=%s, err=%s", id, err)
+			d.report("refresh repository", err, "repo", id)
+			continue
+		}
+		n++
+	}
+	return nil
+}
+
+// runSnapshotter records a snapshot of the top repositories once per day.
+func (d *Daemon) runSnapshotter(ctx context.Context) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[snapshotter] ", log.LstdFlags)
+
+	for {
+		if err := d.Snapshot(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("snapshot error: %s", err)
+			d.report("snapshot", err)
+		}
+
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(d.SnapshotInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Snapshot records the top repositories per language for the current UTC
+// day, if they have not already been recorded.
+func (d *Daemon) Snapshot(ctx context.Context) error {
+	now := time.Now()
+	if ss, err := d.Store.Snapshot(now); err != nil {
+		return fmt.Errorf("snapshot: %s", err)
+	} else if ss != nil {
+		return nil
+	}
+
+	if _, err := d.Store.SaveSnapshot(now, d.SnapshotN); err != nil {
+		return fmt.Errorf("save snapshot: %s", err)
+	}
+	return nil
+}
+
+// runEngagementFetcher periodically fetches engagement counts for recently
+// sent notifications.
+func (d *Daemon) runEngagementFetcher(ctx context.Context) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[engagement] ", log.LstdFlags)
+
+	for {
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(d.EngagementInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		if err := d.FetchEngagement(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("fetch engagement error: %s", err)
+			d.report("fetch engagement", err)
+		}
+	}
+}
+
+// FetchEngagement retrieves the engagement counts of messages sent within
+// the engagement window and records them with their notification attempts.
+// Accounts whose notifiers cannot fetch engagement are skipped.
+func (d *Daemon) FetchEngagement(ctx context.Context) error {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[engagement] ", log.LstdFlags)
+
+	a, err := d.Store.RecentNotifications(time.Now().Add(-d.EngagementWindow))
+	if err != nil {
+		return fmt.Errorf("recent notifications: %s", err)
+	}
+
+	// Group sent messages by account.
+	byUsername := make(map[string]map[uint64][]*Notification)
+	for _, n := range a {
+		if !n.Success || n.MessageID == 0 {
+			continue
+		}
+		if byUsername[n.Username] == nil {
+			byUsername[n.Username] = make(map[uint64][]*Notification)
+		}
+		byUsername[n.Username][n.MessageID] = append(byUsername[n.Username][n.MessageID], n)
+	}
+
+	for _, acc := range d.Accounts {
+		byMessageID := byUsername[acc.Username]
+		fetcher, ok := acc.Notifier.(EngagementFetcher)
+		if !ok || len(byMessageID) == 0 {
+			continue
+		}
+
+		ids := make([]uint64, 0, len(byMessageID))
+		for id := range byMessageID {
+			ids = append(ids, id)
+		}
+		sort.Sort(uint64Slice(ids))
+
+		m, err := fetcher.Engagement(ctx, ids)
+		if err != nil {
+			logger.Printf("fetch engagement error: username=%s, err=%s", acc.Username, err)
+			d.report("fetch engagement", err, "username", acc.Username)
+		}
+
+		// Record any counts retrieved, even after an error.
+		now := time.Now().UTC()
+		for id, e := range m {
+			other := *e
+			other.Time = now
+			for _, n := range byMessageID[id] {
+				if err := d.Store.SetNotificationEngagement(n.ID, &other); err != nil {
+					return fmt.Errorf("set engagement: %s", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runRetrier periodically retries messages in the retry queue.
+func (d *Daemon) runRetrier(ctx context.Context) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[retrier] ", log.LstdFlags)
+
+	for {
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(d.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		if err := d.Retry(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("retry error: %s", err)
+			d.report("retry", err)
+		}
+	}
+}
+
+// Retry saves messages in the retry queue whose next attempt is due.
+// Messages that fail again remain queued until they are quarantined.
+func (d *Daemon) Retry(ctx context.Context) error {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[retrier] ", log.LstdFlags)
+
+	a, err := d.Store.DueRetries(time.Now(), d.RetryLimit)
+	if err != nil {
+		return fmt.Errorf("due retries: %s", err)
+	} else if len(a) == 0 {
+		return nil
+	}
+
+	messages := make([]*Message, len(a))
+	for i, rm := range a {
+		messages[i] = rm.Message
+	}
+
+	// Messages are removed from the queue unless they fail again.
+	var savedN int
+	errs := d.Store.AddMessages(ctx, messages)
+	for i, message := range messages {
+		if err := errs[i]; err == nil {
+			savedN++
+		} else if e, ok := err.(*SpamError); ok {
+			d.spam(e.Reason)
+		} else if err != ErrRepositoryNotFound && err != ErrOptedOut && err != ErrBlacklisted {
+			d.retry(logger, message, err)
+			continue
+		}
+
+		if err := d.Store.DeleteRetry(message.ID); err != nil {
+			return fmt.Errorf("delete retry: %s", err)
+		}
+	}
+
+	logger.Printf("retried %d messages, saved %d", len(messages), savedN)
+	return nil
+}
+
+// retry saves a failed message to the retry queue. Messages that have failed
+// too many times are quarantined instead.
+func (d *Daemon) retry(logger *log.Logger, message *Message, err error) {
+	rm, e := d.Store.AddRetry(message, err, time.Now())
+	if e != nil {
+		d.fail(logger, message, fmt.Errorf("add retry: %s", e))
+		return
+	} else if rm.Attempts < d.MaxRetryAttempts {
+		return
+	}
+
+	class := ErrorClass(err)
+	logger.Printf("retries exhausted: id=%d, repo=%s, class=%s, err=%s", message.ID, message.RepositoryID, class, err)
+	d.report("retry message", err, "repo", message.RepositoryID, "class", class)
+	if e := d.Store.DeleteRetry(message.ID); e != nil {
+		logger.Printf("delete retry error: id=%d, err=%s", message.ID, e)
+		return
+	}
+
+	d.imu.Lock()
+	defer d.imu.Unlock()
+	d.quarantineMessage(message, class, err, rm.Attempts)
+}
+
+// spam records a message dropped by the spam filter.
+func (d *Daemon) spam(reason SpamReason) {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+	if d.spamN == nil {
+		d.spamN = make(map[SpamReason]int)
+	}
+	d.spamN[reason]++
+}
+
+// deferMessage saves a message that could not be saved during this cycle
+// to the retry queue, if enabled, so it is not lost on restart. Otherwise the
+// message is held to be retried on the next poll.
+func (d *Daemon) deferMessage(logger *log.Logger, message *Message, err error) {
+	if d.RetryInterval > 0 {
+		d.retry(logger, message, err)
+		return
+	}
+
+	d.imu.Lock()
+	defer d.imu.Unlock()
+	d.holdMessage(logger, message)
+}
+
+// holdMessage adds a message to be retried on the next poll. The message is
+// dropped and counted if too many are already held. Must be called with imu held.
+func (d *Daemon) holdMessage(logger *log.Logger, message *Message) {
+	if len(d.deferred) >= DefaultMaxDeferred {
+		d.droppedN++
+		logger.Printf("deferred message dropped: id=%d, repo=%s, deferred=%d", message.ID, message.RepositoryID, len(d.deferred))
+		return
+	}
+	d.deferred = append(d.deferred, message)
+}
+
+// fail records an ingestion error for a message. The message is retried on the
+// next poll unless it has failed too many times, in which case it is quarantined.
+func (d *Daemon) fail(logger *log.Logger, message *Message, err error) {
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	class := ErrorClass(err)
+	logger.Printf("add message error: id=%d, repo=%s, class=%s, err=%s", message.ID, message.RepositoryID, class, err)
+	d.report("add message", err, "repo", message.RepositoryID, "class", class)
+
+	// Track error counts by class.
+	if d.errorN == nil {
+		d.errorN = make(map[string]int)
+	}
+	d.errorN[class]++
+
+	// Retry the message unless it has failed too many times.
+	if d.failures == nil {
+		d.failures = make(map[uint64]int)
+	}
+	d.failures[message.ID]++
+	if n := d.failures[message.ID]; n < DefaultMaxMessageFailures {
+		d.holdMessage(logger, message)
+		return
+	}
+
+	delete(d.failures, message.ID)
+	d.quarantineMessage(message, class, err, DefaultMaxMessageFailures)
+}
+
+// quarantineMessage records a message that is no longer retried and only
+// keeps the most recent ones. Must be called with imu held.
+func (d *Daemon) quarantineMessage(message *Message, class string, err error, failures int) {
+	d.quarantine = append(d.quarantine, &QuarantinedMessage{
+		Message:  message,
+		Class:    class,
+		Err:      err.Error(),
+		Failures: failures,
+	})
+	if len(d.quarantine) > DefaultMaxQuarantined {
+		d.quarantine = d.quarantine[len(d.quarantine)-DefaultMaxQuarantined:]
+	}
+}
+
+// PollerStatus returns diagnostic information about message ingestion.
+func (d *Daemon) PollerStatus() *PollerStatus {
+	// Read the retry queue size before locking ingestion state.
+	var retryN int
+	if d.Store != nil {
+		retryN, _ = d.Store.RetryN()
+	}
+
+	d.imu.Lock()
+	defer d.imu.Unlock()
+
+	status := &PollerStatus{
+		RetryN:      retryN,
+		Errors:      make(map[string]int),
+		Spam:        make(map[SpamReason]int),
+		DeferredN:   len(d.deferred),
+		DroppedN:    d.droppedN,
+		Quarantined: make([]*QuarantinedMessage, len(d.quarantine)),
+	}
+	for _, src := range d.sourceStatus {
+		if src.FailureN > status.FailureN {
+			status.FailureN, status.RetryAt = src.FailureN, src.RetryAt
+		}
+	}
+	for k, v := range d.errorN {
+		status.Errors[k] = v
+	}
+	for k, v := range d.spamN {
+		status.Spam[k] = v
+	}
+	copy(status.Quarantined, d.quarantine)
+
+	// Include queue depths & stage restarts if messages are pipelined.
+	if d.Pipeline != nil {
+		status.QueuedN, status.ResolvedN = d.Pipeline.Depth()
+		if m := d.Pipeline.Restarts(); len(m) > 0 {
+			status.Restarts = m
+		}
+	}
+
+	// Include malformed counts if the pollers track them.
+	for _, src := range d.sources() {
+		if p, ok := src.Poller.(interface {
+			MalformedN() uint64
+		}); ok {
+			status.MalformedN += p.MalformedN()
+		}
+	}
+	return status
+}
+
+// runNotifier periodically notifies accounts of top repositories.
+func (d *Daemon) runNotifier(ctx context.Context) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[notifier] ", log.LstdFlags)
+
+	for {
+		// Attempt to notify accounts with new repos!
+		if err := d.Notify(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("notify error: %s", err)
+			d.report("notify", err)
+		}
+
+		// Wait for next interval or for shutdown signal.
+		select {
+		case <-time.After(d.NotifyCheckInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Notify sends a message to each account if enough time has elapsed.
+func (d *Daemon) Notify(ctx context.Context) error {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[notifier] ", log.LstdFlags)
+
+	// Retrieve top repositories by language.
+	repos, err := d.Store.TopRepositories()
+	if err != nil {
+		return fmt.Errorf("top repositories: %s", err)
+	}
+
+	// Route repositories to accounts using rules, if any are configured.
+	var routed map[string]*Repository
+	if d.Router != nil && len(d.Router.Rules) > 0 {
+		ranked, err := d.Store.TopRepositoriesOverall(-1)
+		if err != nil {
+			return fmt.Errorf("top repositories overall: %s", err)
+		}
+		routed = d.Router.Route(ranked)
+	}
+
+	// Retrieve notifications awaiting approval for moderated accounts.
+	pending, err := d.Store.PendingNotifications()
+	if err != nil {
+		return fmt.Errorf("pending notifications: %s", err)
+	}
+	d.Statsd.Gauge("queue.pending_notifications", int64(len(pending)))
+
+	// Restore persisted API quotas and save any updates once done.
+	d.loadRateLimits(logger)
+	defer d.saveRateLimits(logger)
+
+	// Iterate over each account.
+	for _, acc := range d.Accounts {
+		n := acc.Notifier
+
+		// Queue candidates for approval and send approved notifications.
+		if acc.Moderated {
+			r := routed[acc.Username]
+			if r == nil {
+				if r, err = d.topRepository(acc, repos); err != nil {
+					logger.Printf("top repository error: username=%s, err=%s", acc.Username, err)
+					d.report("top repository", err, "username", acc.Username)
+				}
+			}
+			d.notifyModerated(ctx, logger, acc, pending, r)
+			continue
+		}
+
+		// Skip notifier if last tweet time is within interval or outside its schedule.
+		if !d.due(logger, acc) {
+			continue
+		}
+
+		// Post the top repositories for the account together, if enabled.
+		if acc.DigestN > 0 {
+			d.notifyDigest(ctx, logger, acc)
+			continue
+		}
+
+		// Use the routed repository or fall back to the top for the account.
+		r := routed[acc.Username]
+		if r == nil {
+			if r, err = d.topRepository(acc, repos); err != nil {
+				logger.Printf("top repository error: username=%s, err=%s", acc.Username, err)
+				d.report("top repository", err, "username", acc.Username)
+				d.skip(acc, SkipError)
+				continue
+			}
+		}
+		if r == nil {
+			d.skip(acc, SkipNoRepository)
+			continue
+		}
+
+		// Refresh star & fork counts before notifying. Use the cached
+		// repository if the remote store is unavailable.
+		if fresh, err := d.Store.RefreshRepository(ctx, r.ID); err != nil {
+			logger.Printf("refresh repository error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+			d.report("refresh repository", err, "username", acc.Username, "repo", r.ID)
+		} else {
+			r = fresh
+		}
+
+		// Skip the pick if it is excluded, blocked, or does not have enough activity.
+		if !d.eligible(logger, acc, r) {
+			continue
+		}
+
+		// Reserve the pick unless it or its text was recently featured.
+		text, err := n.Text(r)
+		if err != nil {
+			logger.Printf("text error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+			d.report("text", err, "username", acc.Username, "repo", r.ID)
+			d.skip(acc, SkipError)
+			continue
+		}
+		f := d.reserve(logger, acc, r.ID, text)
+		if f == nil {
+			continue
+		}
+
+		// Attempt to send message to account.
+		span := d.notifySpan(acc, r.ID)
+		m, err := n.Notify(ctx, r)
+		span.SetError(err)
+		span.End()
+		d.record(logger, acc, r.ID, text, templateName(acc, r.ID), m, err)
+		if err == ErrNotificationTooLong {
+			// NOTE: if the text contains multiple URL-looking words then it can
+			// go over the limit. There's not an easy way to get around it
+			// so we just mark the repo as notified so we can move on.
+			logger.Printf("tweet too long error: username=%s, repo=%s", acc.Username, r.ID)
+			d.release(logger, acc, f, "notification too long")
+		} else if err != nil {
+			logger.Printf("notify error: username=%s, repo=%s, text=%q, err=%s", acc.Username, r.ID, text, err)
+			d.report("notify", err, "username", acc.Username, "repo", r.ID)
+			d.release(logger, acc, f, "notify error: "+err.Error())
+			d.skip(acc, SkipError)
+			continue
+		} else {
+			d.explain(acc, r.ID, false, sentReason("sent", m))
+			d.notified(logger, acc)
+		}
+
+		// Mark repository as notified.
+		if err := d.Store.MarkNotified(r.ID); err != nil {
+			logger.Printf("mark notified error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+			d.report("mark notified", err, "username", acc.Username, "repo", r.ID)
+			continue
+		}
+	}
+
+	return nil
+}
+
+// ForceNotify immediately sends a repository from an account, such as for a
+// curated pick or to test a new account. The account's interval, schedule,
+// and moderation are bypassed but opted out, blacklisted, ineligible, and
+// recently featured repositories are rejected. Only the leader may notify.
+// The repository is marked as notified once sent.
+func (d *Daemon) ForceNotify(ctx context.Context, username, repositoryID string) (*Message, error) {
+	// Setup logging.
+	logger := log.New(d.LogOutput, "[notifier] ", log.LstdFlags)
+
+	if !d.Leader() {
+		return nil, ErrNotLeader
+	}
+
+	var acc *Account
+	for _, a := range d.Accounts {
+		if strings.EqualFold(a.Username, username) {
+			acc = a
+			break
+		}
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+
+	// Refresh star & fork counts before notifying, if possible.
+	r, err := d.Store.RefreshRepository(ctx, repositoryID)
+	if err == ErrOptedOut {
+		return nil, err
+	} else if err != nil {
+		if r, err = d.Store.Repository(repositoryID); err != nil {
+			return nil, err
+		} else if r == nil {
+			return nil, ErrRepositoryNotFound
+		}
+	}
+
+	// Apply the same checks as scheduled picks.
+	if blacklisted, err := d.Store.Blacklisted(r.ID); err != nil {
+		return nil, fmt.Errorf("blacklisted: %s", err)
+	} else if blacklisted {
+		return nil, ErrBlacklisted
+	} else if !d.eligible(logger, acc, r) {
+		return nil, ErrNotEligible
+	}
+
+	text, err := acc.Notifier.Text(r)
+	if err != nil {
+		return nil, fmt.Errorf("text: %s", err)
+	}
+	f := d.reserve(logger, acc, r.ID, text)
+	if f == nil {
+		return nil, ErrNotEligible
+	}
+
+	span := d.notifySpan(acc, r.ID)
+	m, err := acc.Notifier.Notify(ctx, r)
+	span.SetError(err)
+	span.End()
+	d.record(logger, acc, r.ID, text, templateName(acc, r.ID), m, err)
+	if err != nil {
+		d.release(logger, acc, f, "notify error: "+err.Error())
+		return nil, err
+	}
+	d.explain(acc, r.ID, false, sentReason("forced", m))
+	d.notified(logger, acc)
+
+	if err := d.Store.MarkNotified(r.ID); err != nil {
+		return m, fmt.Errorf("mark notified: %s", err)
+	}
+	return m, nil
+}
+
+// loadRateLimits sets the persisted API quotas on each account's notifier.
+func (d *Daemon) loadRateLimits(logger *log.Logger) {
+	for _, acc := range d.Accounts {
+		n, ok := acc.Notifier.(RateLimitedNotifier)
+		if !ok {
+			continue
+		}
+
+		a, err := d.Store.RateLimits(acc.Username)
+		if err != nil {
+			logger.Printf("rate limits error: username=%s, err=%s", acc.Username, err)
+			d.report("rate limits", err, "username", acc.Username)
+			continue
+		}
+		n.SetRateLimits(a)
+	}
+}
+
+// saveRateLimits persists the API quotas of each account's notifier.
+func (d *Daemon) saveRateLimits(logger *log.Logger) {
+	for _, acc := range d.Accounts {
+		n, ok := acc.Notifier.(RateLimitedNotifier)
+		if !ok {
+			continue
+		}
+
+		if err := d.Store.SaveRateLimits(acc.Username, n.RateLimits()); err != nil {
+			logger.Printf("save rate limits error: username=%s, err=%s", acc.Username, err)
+			d.report("save rate limits", err, "username", acc.Username)
+		}
+
+		for _, rl := range n.RateLimits() {
+			d.Statsd.Gauge("ratelimit.remaining", int64(rl.Remaining), "username:"+acc.Username, "resource:"+rl.Resource)
+		}
+	}
+}
+
+// throttled returns true if any of the account's API quotas are exhausted.
+func throttled(acc *Account, now time.Time) bool {
+	n, ok := acc.Notifier.(RateLimitedNotifier)
+	if !ok {
+		return false
+	}
+	for _, rl := range n.RateLimits() {
+		if rl.Throttled(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// due returns true if an account can notify now. Records a skip if not.
+func (d *Daemon) due(logger *log.Logger, acc *Account) bool {
+	// Defer until the account's API quota resets.
+	if throttled(acc, time.Now()) {
+		d.skip(acc, SkipRateLimited)
+		return false
+	}
+
+	// Retrieve last tweet time.
+	lastTweetTime, err := d.lastNotifyTime(acc)
+	if err != nil {
+		logger.Printf("last tweet time error: username=%s, err=%s", acc.Username, err)
+		d.report("last tweet time", err, "username", acc.Username)
+		d.skip(acc, SkipError)
+		return false
+	}
+
+	// Skip if last tweet time is within interval or outside the schedule.
+	if now := time.Now(); !acc.Notifier.Due(now, lastTweetTime) {
+		d.skip(acc, dueSkipReason(acc.Notifier, now, lastTweetTime))
+		return false
+	}
+	return true
+}
+
+// lastNotifyTime returns the time acc last notified. The store is the source
+// of truth. If no time is stored yet then the notifier is asked and the
+// result is saved.
+func (d *Daemon) lastNotifyTime(acc *Account) (time.Time, error) {
+	t, err := d.Store.LastNotifyTime(acc.Username)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("store: %s", err)
+	} else if !t.IsZero() {
+		return t, nil
+	}
+
+	t, err = acc.Notifier.LastTweetTime()
+	if err != nil || t.IsZero() {
+		return t, err
+	} else if err := d.Store.SetLastNotifyTime(acc.Username, t); err != nil {
+		return time.Time{}, fmt.Errorf("save: %s", err)
+	}
+	return t, nil
+}
+
+// notifyModerated queues a candidate repository for approval if the account
+// has nothing queued. An approved notification is sent once the account is
+// due, using the exact text that was approved.
+func (d *Daemon) notifyModerated(ctx context.Context, logger *log.Logger, acc *Account, pending []*PendingNotification, candidate *Repository) {
+	// Find the account's queued notification.
+	var p *PendingNotification
+	for _, item := range pending {
+		if item.Username == acc.Username {
+			p = item
+			break
+		}
+	}
+
+	// Queue the candidate if nothing is queued.
+	if p == nil {
+		if candidate == nil {
+			d.skip(acc, SkipNoRepository)
+			return
+		} else if !d.eligible(logger, acc, candidate) {
+			return
+		}
+
+		text, err := acc.Notifier.Text(candidate)
+		if err != nil {
+			logger.Printf("text error: username=%s, repo=%s, err=%s", acc.Username, candidate.ID, err)
+			d.report("text", err, "username", acc.Username, "repo", candidate.ID)
+			d.skip(acc, SkipError)
+			return
+		}
+
+		if err := d.Store.AddPendingNotification(&PendingNotification{
+			Username:     acc.Username,
+			RepositoryID: candidate.ID,
+			Text:         text,
+		}); err != nil {
+			logger.Printf("add pending notification error: username=%s, repo=%s, err=%s", acc.Username, candidate.ID, err)
+			d.report("add pending notification", err, "username", acc.Username, "repo", candidate.ID)
+			d.skip(acc, SkipError)
+			return
+		}
+		d.skip(acc, SkipPendingApproval)
+		return
+	}
+
+	// Wait for approval and for the account to be due.
+	if !p.Approved {
+		d.skip(acc, SkipPendingApproval)
+		return
+	} else if !d.due(logger, acc) {
+		return
+	}
+
+	// Retrieve repository. Drop the notification if it no longer exists.
+	r, err := d.Store.Repository(p.RepositoryID)
+	if err != nil {
+		logger.Printf("repository error: username=%s, repo=%s, err=%s", acc.Username, p.RepositoryID, err)
+		d.report("repository", err, "username", acc.Username, "repo", p.RepositoryID)
+		d.skip(acc, SkipError)
+		return
+	} else if r == nil {
+		d.dropPending(logger, acc, p)
+		d.skip(acc, SkipNoRepository)
+		return
+	}
+
+	// Drop the notification if the repository was blacklisted or became
+	// ineligible while it was waiting in the queue.
+	if blacklisted, err := d.Store.Blacklisted(r.ID); err != nil {
+		logger.Printf("blacklisted error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+		d.report("blacklisted", err, "username", acc.Username, "repo", r.ID)
+		d.skip(acc, SkipError)
+		return
+	} else if blacklisted {
+		d.explain(acc, r.ID, true, "excluded: blacklisted")
+		d.skip(acc, SkipExcluded)
+		d.dropPending(logger, acc, p)
+		return
+	} else if !d.eligible(logger, acc, r) {
+		d.dropPending(logger, acc, p)
+		return
+	}
+
+	// Reserve the pick unless it or its text was recently featured.
+	f := d.reserve(logger, acc, r.ID, p.Text)
+	if f == nil {
+		return
+	}
+
+	// Send the approved text, if the notifier supports it.
+	var m *Message
+	span := d.notifySpan(acc, r.ID)
+	if poster, ok := acc.Notifier.(TextPoster); ok {
+		m, err = poster.Post(ctx, r, p.Text)
+	} else {
+		m, err = acc.Notifier.Notify(ctx, r)
+	}
+	span.SetError(err)
+	span.End()
+	d.record(logger, acc, r.ID, p.Text, templateName(acc, r.ID), m, err)
+	if err != nil {
+		logger.Printf("notify error: username=%s, repo=%s, text=%q, err=%s", acc.Username, r.ID, p.Text, err)
+		d.report("notify", err, "username", acc.Username, "repo", r.ID)
+		d.release(logger, acc, f, "notify error: "+err.Error())
+		d.skip(acc, SkipError)
+		return
+	}
+	d.explain(acc, r.ID, false, sentReason("sent", m))
+
+	// Mark repository as notified and remove it from the queue.
+	if err := d.Store.MarkNotified(r.ID); err != nil {
+		logger.Printf("mark notified error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+		d.report("mark notified", err, "username", acc.Username, "repo", r.ID)
+	}
+	if err := d.Store.DeletePendingNotification(p.ID); err != nil {
+		logger.Printf("delete pending notification error: username=%s, id=%d, err=%s", acc.Username, p.ID, err)
+		d.report("delete pending notification", err, "username", acc.Username)
+	}
+	d.notified(logger, acc)
+}
+
+// dropPending removes an account's queued notification that can no longer be sent.
+func (d *Daemon) dropPending(logger *log.Logger, acc *Account, p *PendingNotification) {
+	if err := d.Store.DeletePendingNotification(p.ID); err != nil {
+		logger.Printf("delete pending notification error: username=%s, id=%d, err=%s", acc.Username, p.ID, err)
+		d.report("delete pending notification", err, "username", acc.Username)
+	}
+}
+
+// topRepository returns the top unnotified repository for an account from
+// repos, as returned by TopRepositories(). Pattern accounts are queried from
+// the store since they have no key in repos. Returns nil if none are found.
+func (d *Daemon) topRepository(acc *Account, repos map[string]*Repository) (*Repository, error) {
+	if acc.Pattern == nil {
+		return repos[acc.Key()], nil
+	}
+
+	a, err := d.topRepositories(acc, 1)
+	if err != nil || len(a) == 0 {
+		return nil, err
+	}
+	return a[0], nil
+}
+
+// topRepositories returns up to n unnotified repositories for an account's
+// pattern, topic, or language, ordered by mention count.
+func (d *Daemon) topRepositories(acc *Account, n int) ([]*Repository, error) {
+	switch {
+	case acc.Pattern != nil:
+		return d.Store.TopMatchingRepositories(acc.Pattern, n)
+	case acc.Topic != "":
+		return d.Store.TopTopicRepositories(acc.Topic, n)
+	default:
+		return d.Store.TopLanguageRepositories(acc.Language, n)
+	}
+}
+
+// notifyDigest sends the top unnotified repositories for an account's
+// pattern, topic, or language as a single digest. Routing rules do not apply to digests.
+func (d *Daemon) notifyDigest(ctx context.Context, logger *log.Logger, acc *Account) {
+	n, ok := acc.Notifier.(DigestNotifier)
+	if !ok {
+		logger.Printf("digest not supported: username=%s", acc.Username)
+		d.skip(acc, SkipError)
+		return
+	}
+
+	// Retrieve top repositories for the account.
+	a, err := d.topRepositories(acc, acc.DigestN)
+	if err != nil {
+		logger.Printf("top repositories error: username=%s, err=%s", acc.Username, err)
+		d.report("top repositories", err, "username", acc.Username)
+		d.skip(acc, SkipError)
+		return
+	} else if len(a) == 0 {
+		d.skip(acc, SkipNoRepository)
+		return
+	}
+
+	// Refresh star & fork counts, using cached repositories on error.
+	for i, r := range a {
+		if fresh, err := d.Store.RefreshRepository(ctx, r.ID); err != nil {
+			logger.Printf("refresh repository error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+			d.report("refresh repository", err, "username", acc.Username, "repo", r.ID)
+		} else {
+			a[i] = fresh
+		}
+	}
+
+	// Reserve each repository, dropping ones that were recently featured,
+	// are excluded or blocked, or do not have enough activity.
+	var features []*Feature
+	for _, r := range a {
+		if !d.eligible(logger, acc, r) {
+			continue
+		} else if f := d.reserve(logger, acc, r.ID, ""); f != nil {
+			a[len(features)] = r
+			features = append(features, f)
+		}
+	}
+	if a = a[:len(features)]; len(a) == 0 {
+		return
+	}
+
+	// Send digest and mark all repositories as notified.
+	span := d.notifySpan(acc, "")
+	span.SetAttribute("digest.size", len(a))
+	messages, err := n.NotifyDigest(ctx, a)
+	span.SetError(err)
+	span.End()
+	for _, m := range messages {
+		d.record(logger, acc, m.RepositoryID, m.Text, "", m, nil)
+	}
+	if err != nil {
+		// Record a failure for each repository that was not posted.
+		for _, r := range a {
+			if m := digestMessage(messages, r.ID); m == nil || m.RepositoryID != r.ID {
+				d.record(logger, acc, r.ID, "", "", nil, err)
+			}
+		}
+
+		// Link to the partially posted thread, if any, so it can be cleaned up.
+		var u string
+		if len(messages) > 0 {
+			u = messages[0].URL
+		}
+		logger.Printf("notify digest error: username=%s, n=%d, url=%s, err=%s", acc.Username, len(a), u, err)
+		d.report("notify digest", err, "username", acc.Username)
+		for _, f := range features {
+			d.release(logger, acc, f, "notify digest error: "+err.Error())
+		}
+		d.skip(acc, SkipError)
+		return
+	}
+	for _, r := range a {
+		d.explain(acc, r.ID, false, sentReason("sent in digest", digestMessage(messages, r.ID)))
+	}
+	for _, r := range a {
+		if err := d.Store.MarkNotified(r.ID); err != nil {
+			logger.Printf("mark notified error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+			d.report("mark notified", err, "username", acc.Username, "repo", r.ID)
+		}
+	}
+	d.notified(logger, acc)
+}
+
+// notifySpan starts a span for sending a notification to an account.
+func (d *Daemon) notifySpan(acc *Account, repositoryID string) *tracing.Span {
+	span := d.Tracer.Start("notify")
+	span.SetAttribute("username", acc.Username)
+	if repositoryID != "" {
+		span.SetAttribute("repository_id", repositoryID)
+	}
+	return span
+}
+
+// record adds a notification attempt to the audit log. The template is the
+// name of the template variant used for the text, if any.
+func (d *Daemon) record(logger *log.Logger, acc *Account, repositoryID, text, template string, m *Message, err error) {
+	n := &Notification{
+		Username:     acc.Username,
+		RepositoryID: repositoryID,
+		Text:         text,
+		Success:      err == nil,
+		Template:     template,
+	}
+	if m != nil {
+		n.MessageID, n.URL = m.ID, m.URL
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+
+	if err := d.Store.AddNotification(n); err != nil {
+		logger.Printf("add notification error: username=%s, repo=%s, err=%s", acc.Username, repositoryID, err)
+		d.report("add notification", err, "username", acc.Username, "repo", repositoryID)
+	}
+
+	d.Statsd.Count("api.calls", 1, "username:"+acc.Username, "api:notify", statusTag(err))
+
+	if !n.Success {
+		stats.Add(StatNotificationsFailed, 1)
+	} else {
+		stats.Add(StatNotificationsSent, 1)
+		d.Events.Publish(&events.NotificationSent{Username: n.Username, RepositoryID: n.RepositoryID, Text: n.Text, MessageID: n.MessageID, URL: n.URL})
+	}
+}
+
+// templateName returns the name of the template variant the account uses
+// for a repository. Returns a blank name if the notifier has no variants.
+func templateName(acc *Account, repositoryID string) string {
+	if n, ok := acc.Notifier.(TemplateNamer); ok {
+		return n.TemplateName(repositoryID)
+	}
+	return ""
+}
+
+// report sends an error to the error reporter, if one is set. The operation
+// is tagged as "op" along with pairs of additional tag keys & values.
+func (d *Daemon) report(op string, err error, kv ...string) {
+	if d.ErrorReporter == nil {
+		return
+	}
+
+	tags := map[string]string{"op": op}
+	for i := 0; i+1 < len(kv); i += 2 {
+		tags[kv[i]] = kv[i+1]
+	}
+	d.ErrorReporter.ReportError(err, tags)
+}
+
+// statusTag returns the statsd tag for the outcome of an API call.
+func statusTag(err error) string {
+	if err != nil {
+		return "status:error"
+	}
+	return "status:ok"
+}
+
+// digestMessage returns the digest message featuring a repository. Returns
+// the first message if the digest was posted as a single tweet.
+func digestMessage(a []*Message, repositoryID string) *Message {
+	for _, m := range a {
+		if m.RepositoryID == repositoryID {
+			return m
+		}
+	}
+	if len(a) > 0 {
+		return a[0]
+	}
+	return nil
+}
+
+// sentReason returns an explanation for a sent notification, including
+// a link to the message if it is known.
+func sentReason(reason string, m *Message) string {
+	if m != nil && m.URL != "" {
+		return reason + ": " + m.URL
+	}
+	return reason
+}
+
+// reserve reserves a repository & text to be featured by acc. Returns nil
+// and records an explanation if the pick is blocked because the repository or
+// an identical text was featured within the featured window.
+func (d *Daemon) reserve(logger *log.Logger, acc *Account, repositoryID, text string) *Feature {
+	f := &Feature{RepositoryID: repositoryID, Username: acc.Username, Text: text, Time: time.Now().UTC()}
+	if d.FeaturedWindow <= 0 {
+		return f
+	}
+
+	conflict, err := d.Store.ReserveFeature(f, f.Time.Add(-d.FeaturedWindow))
+	if err != nil {
+		logger.Printf("reserve feature error: username=%s, repo=%s, err=%s", acc.Username, repositoryID, err)
+		d.report("reserve feature", err, "username", acc.Username, "repo", repositoryID)
+		d.explain(acc, repositoryID, true, "reserve error: "+err.Error())
+		d.skip(acc, SkipError)
+		return nil
+	} else if conflict != nil {
+		ago := f.Time.Sub(conflict.Time).Truncate(time.Second)
+		if conflict.RepositoryID == repositoryID {
+			d.explain(acc, repositoryID, true, fmt.Sprintf("repository featured by @%s %s ago (window %s)", conflict.Username, ago, d.FeaturedWindow))
+		} else {
+			d.explain(acc, repositoryID, true, fmt.Sprintf("identical text featured by @%s for %s %s ago (window %s)", conflict.Username, conflict.RepositoryID, ago, d.FeaturedWindow))
+		}
+		d.skip(acc, SkipRecentlyFeatured)
+		return nil
+	}
+	return f
+}
+
+// eligible returns true if r is not excluded, passes the content filter, and
+// meets the daemon's thresholds. Otherwise the pick is explained and skipped.
+// Exclusion is checked again since a refreshed repository may have been
+// archived. Repositories blocked by the content filter are flagged for review.
+func (d *Daemon) eligible(logger *log.Logger, acc *Account, r *Repository) bool {
+	if reason := d.Store.Exclusion(r); reason != "" {
+		d.explain(acc, r.ID, true, "excluded: "+reason)
+		d.skip(acc, SkipExcluded)
+		return false
+	} else if !d.allowed(logger, acc, r) {
+		return false
+	} else if reason := d.Thresholds.Check(r); reason != "" {
+		d.explain(acc, r.ID, true, reason)
+		d.skip(acc, SkipBelowThreshold)
+		return false
+	}
+	return true
+}
+
+// allowed returns true if r passes the content filter or was approved after
+// being flagged. Otherwise the repository is flagged and the pick is skipped.
+func (d *Daemon) allowed(logger *log.Logger, acc *Account, r *Repository) bool {
+	if d.ContentFilter == nil {
+		return true
+	}
+	reason := d.ContentFilter.Check(r)
+	if reason == "" {
+		return true
+	}
+
+	// Allow repositories that a moderator has approved.
+	if f, err := d.Store.FlaggedRepository(r.ID); err != nil {
+		logger.Printf("flagged repository error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+		d.report("flagged repository", err, "username", acc.Username, "repo", r.ID)
+		d.skip(acc, SkipError)
+		return false
+	} else if f != nil && f.Approved {
+		return true
+	}
+
+	if err := d.Store.FlagRepository(&FlaggedRepository{RepositoryID: r.ID, Reason: reason}); err != nil {
+		logger.Printf("flag repository error: username=%s, repo=%s, err=%s", acc.Username, r.ID, err)
+		d.report("flag repository", err, "username", acc.Username, "repo", r.ID)
+	}
+	d.explain(acc, r.ID, true, "flagged for review: "+reason)
+	d.skip(acc, SkipFlagged)
+	return false
+}
+
+// release removes a reservation after a notification was not sent.
+func (d *Daemon) release(logger *log.Logger, acc *Account, f *Feature, reason string) {
+	d.explain(acc, f.RepositoryID, true, reason)
+	if d.FeaturedWindow <= 0 {
+		return
+	} else if err := d.Store.ReleaseFeature(f); err != nil {
+		logger.Printf("release feature error: username=%s, repo=%s, err=%s", acc.Username, f.RepositoryID, err)
+		d.report("release feature", err, "username", acc.Username, "repo", f.RepositoryID)
+	}
+}
+
+// explain records the outcome of an account's most recent pick.
+func (d *Daemon) explain(acc *Account, repositoryID string, blocked bool, reason string) {
+	d.nmu.Lock()
+	defer d.nmu.Unlock()
+
+	if d.explanations == nil {
+		d.explanations = make(map[string]*Explanation)
+	}
+	d.explanations[acc.Username] = &Explanation{
+		Username:     acc.Username,
+		RepositoryID: repositoryID,
+		Blocked:      blocked,
+		Reason:       reason,
+		Time:         time.Now().UTC(),
+	}
+}
+
+// Explain returns the outcome of each account's most recent pick.
+// Accounts that have not picked a repository are not included.
+func (d *Daemon) Explain() []*Explanation {
+	d.nmu.Lock()
+	defer d.nmu.Unlock()
+
+	var a []*Explanation
+	for _, acc := range d.Accounts {
+		if e := d.explanations[acc.Username]; e != nil {
+			other := *e
+			a = append(a, &other)
+		}
+	}
+	return a
+}
+
+// dueSkipReason returns the reason notifier n is not due. Notifiers can
+// report a more specific reason than the interval by implementing SkipReason().
+func dueSkipReason(n Notifier, now, last time.Time) SkipReason {
+	if n, ok := n.(interface {
+		SkipReason(now, last time.Time) SkipReason
+	}); ok {
+		if reason := n.SkipReason(now, last); reason != "" {
+			return reason
+		}
+	}
+	return SkipWithinInterval
+}
+
+// skip records that acc skipped a notification cycle.
+func (d *Daemon) skip(acc *Account, reason SkipReason) {
+	d.nmu.Lock()
+	defer d.nmu.Unlock()
+
+	now := time.Now()
+	status := d.account(acc)
+	status.Skips[reason]++
+	status.LastSkip, status.LastSkipTime = reason, &now
+}
+
+// notified records that acc sent a notification and saves the time it was sent.
+func (d *Daemon) notified(logger *log.Logger, acc *Account) {
+	if err := d.Store.SetLastNotifyTime(acc.Username, time.Now().UTC()); err != nil {
+		logger.Printf("set last notify time error: username=%s, err=%s", acc.Username, err)
+		d.report("set last notify time", err, "username", acc.Username)
+	}
+
+	d.nmu.Lock()
+	defer d.nmu.Unlock()
+
+	status := d.account(acc)
+	status.NotifiedN++
+	status.LastSkip, status.LastSkipTime = "", nil
+}
+
+// account returns the status for acc, creating it if necessary.
+// Must be called while holding nmu.
+func (d *Daemon) account(acc *Account) *AccountStatus {
+	if d.accountStatus == nil {
+		d.accountStatus = make(map[string]*AccountStatus)
+	}
+	status := d.accountStatus[acc.Username]
+	if status == nil {
+		status = &AccountStatus{Skips: make(map[SkipReason]int)}
+		d.accountStatus[acc.Username] = status
+	}
+	return status
+}
+
+// NotifierStatus returns notification & skip counts for each account.
+func (d *Daemon) NotifierStatus() *NotifierStatus {
+	d.nmu.Lock()
+	defer d.nmu.Unlock()
+
+	status := &NotifierStatus{Accounts: make([]*AccountStatus, 0, len(d.Accounts))}
+	for _, acc := range d.Accounts {
+		other := AccountStatus{Skips: make(map[SkipReason]int)}
+		if s := d.accountStatus[acc.Username]; s != nil {
+			other = *s
+			other.Skips = make(map[SkipReason]int, len(s.Skips))
+			for k, v := range s.Skips {
+				other.Skips[k] = v
+			}
+		}
+		other.Username, other.Language, other.Topic = acc.Username, acc.Language, acc.Topic
+		if acc.Pattern != nil {
+			other.Pattern = acc.Pattern.String()
+		}
+		status.Accounts = append(status.Accounts, &other)
+	}
+	return status
+}
+
+// uint64Slice sorts IDs in increasing order.
+type uint64Slice []uint64
+
+func (p uint64Slice) Len() int           { return len(p) }
+func (p uint64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p uint64Slice) Less(i, j int) bool { return p[i] < p[j] }
+
+// sourceStatusesByName sorts source statuses by name.
+type sourceStatusesByName []*SourceStatus
+
+func (p sourceStatusesByName) Len() int           { return len(p) }
+func (p sourceStatusesByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p sourceStatusesByName) Less(i, j int) bool { return p[i].Name < p[j].Name }
@@ -0,0 +1,1497 @@
+package scuttlebutt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/internal"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Ensure the daemon can be started, stopped, and restarted.
+func TestDaemon_StartStop(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := d.Start(context.Background()); err != nil {
+			t.Fatalf("%d. start: %s", i, err)
+		} else if !d.Running() {
+			t.Fatalf("%d. expected running", i)
+		}
+
+		// Verify HTTP server is running.
+		resp, err := http.Get("http://" + d.ListenerAddr().String() + "/ping")
+		if err != nil {
+			t.Fatalf("%d. ping: %s", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%d. unexpected status: %d", i, resp.StatusCode)
+		}
+
+		// Stop twice to verify idempotence.
+		if err := d.Stop(); err != nil {
+			t.Fatalf("%d. stop: %s", i, err)
+		} else if err := d.Stop(); err != nil {
+			t.Fatalf("%d. stop again: %s", i, err)
+		} else if d.Running() {
+			t.Fatalf("%d. expected stopped", i)
+		} else if d.ListenerAddr() != nil {
+			t.Fatalf("%d. expected listener closed", i)
+		}
+	}
+}
+
+// Ensure starting a running daemon returns an error.
+func TestDaemon_Start_ErrDaemonRunning(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if err := d.Start(context.Background()); err != scuttlebutt.ErrDaemonRunning {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// Ensure the daemon stops when its context is canceled.
+func TestDaemon_Start_ContextCanceled(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// Wait for the daemon to stop.
+	for i := 0; d.Running(); i++ {
+		if i > 100 {
+			t.Fatal("expected daemon to stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure a read-only daemon serves the HTTP API without polling and
+// rejects requests that write to the store.
+func TestDaemon_Start_ReadOnly(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.ReadOnly = true
+
+	polled := make(chan struct{}, 1)
+	d.Poller.PollFn = func(uint64) ([]*scuttlebutt.Message, error) {
+		polled <- struct{}{}
+		return nil, nil
+	}
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	resp, err := http.Post("http://"+d.ListenerAddr().String()+"/pending/1/approve", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	select {
+	case <-polled:
+		t.Fatal("unexpected poll")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Ensure pushed messages are saved and tracked as their own source.
+func TestDaemon_Ingest(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	if n, err := d.Ingest(context.Background(), "hn", []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo"},
+		{ID: 2, RepositoryID: "github.com/user/repo"},
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	if r, err := d.Store.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 2 {
+		t.Fatalf("unexpected message count: %d", len(r.Messages))
+	} else if a := d.SourceStatus(); len(a) != 1 || a[0].Name != "ingest:hn" || a[0].MessageN != 2 {
+		t.Fatalf("unexpected source status: %s", spew.Sdump(a))
+	} else if m, err := d.Store.RepositorySources("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, map[string]int{"hn": 2}) {
+		t.Fatalf("unexpected sources: %v", m)
+	}
+}
+
+// Ensure the gRPC API is served over unencrypted HTTP/2.
+func TestDaemon_Start_GRPC(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.GRPCAddr = "127.0.0.1:0"
+	if err := d.Store.AddBlacklist("github.com/spam/*"); err != nil {
+		t.Fatal(err)
+	} else if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	req, _ := http.NewRequest("POST", "http://"+d.GRPCListenerAddr().String()+"/internal.Scuttlebutt/Blacklist", bytes.NewReader(make([]byte, 5)))
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var pb internal.BlacklistResponse
+	if buf, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	} else if resp.ProtoMajor != 2 {
+		t.Fatalf("unexpected protocol: %s", resp.Proto)
+	} else if err := proto.Unmarshal(buf[5:], &pb); err != nil {
+		t.Fatal(err)
+	} else if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if !reflect.DeepEqual(pb.Patterns, []string{"github.com/spam/*"}) {
+		t.Fatalf("unexpected patterns: %v", pb.Patterns)
+	}
+}
+
+// Ensure only the elected leader polls and polling stops once leadership is lost.
+func TestDaemon_Start_Elector(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	elected, lost := make(chan struct{}), make(chan struct{})
+	resigned := make(chan struct{}, 2)
+	d.Elector = &Elector{
+		CampaignFn: func(ctx context.Context) (<-chan struct{}, error) {
+			select {
+			case <-elected:
+				elected = nil
+				return lost, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+		ResignFn: func() error {
+			resigned <- struct{}{}
+			return nil
+		},
+	}
+
+	polled := make(chan struct{}, 10)
+	d.Poller.PollFn = func(uint64) ([]*scuttlebutt.Message, error) {
+		polled <- struct{}{}
+		return nil, nil
+	}
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	// Followers do not poll.
+	select {
+	case <-polled:
+		t.Fatal("unexpected poll")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Polling starts once elected.
+	close(elected)
+	select {
+	case <-polled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	if !d.Leader() {
+		t.Fatal("expected leader")
+	}
+
+	// Polling stops & leadership is resigned once lost.
+	close(lost)
+	select {
+	case <-resigned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	if d.Leader() {
+		t.Fatal("unexpected leader")
+	}
+	for len(polled) > 0 {
+		<-polled
+	}
+	select {
+	case <-polled:
+		t.Fatal("unexpected poll")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Ensure messages that repeatedly fail are quarantined.
+func TestDaemon_Poll_Quarantine(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		if sinceID > 0 {
+			return nil, nil
+		}
+		return []*scuttlebutt.Message{{ID: 10, RepositoryID: "github.com/user/repo"}}, nil
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return nil, errors.New("marker")
+	}
+
+	// Poll until the message is quarantined.
+	var sinceID uint64
+	for i := 0; i < scuttlebutt.DefaultMaxMessageFailures; i++ {
+		if err := d.Poll(context.Background(), &sinceID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify status.
+	status := d.PollerStatus()
+	if sinceID != 10 {
+		t.Fatalf("unexpected since id: %d", sinceID)
+	} else if status.Errors[scuttlebutt.ErrorClassRemote] != 3 {
+		t.Fatalf("unexpected error counts: %v", status.Errors)
+	} else if status.DeferredN != 0 {
+		t.Fatalf("unexpected deferred count: %d", status.DeferredN)
+	} else if len(status.Quarantined) != 1 || status.Quarantined[0].Message.ID != 10 {
+		t.Fatalf("unexpected quarantine: %v", status.Quarantined)
+	}
+}
+
+// Ensure polled messages are enqueued on an open pipeline and saved by it.
+func TestDaemon_Poll_Pipeline(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		return []*scuttlebutt.Message{{ID: 10, RepositoryID: "github.com/user/repo"}}, nil
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	d.Pipeline = scuttlebutt.NewPipeline(d.Store.Store)
+	d.Pipeline.BatchDelay = time.Millisecond
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages are saved asynchronously so wait for the writer stage.
+	for i := 0; ; i++ {
+		if r, err := d.Store.Repository("github.com/user/repo"); err != nil {
+			t.Fatal(err)
+		} else if r != nil && len(r.Messages) == 1 {
+			break
+		} else if i == 100 {
+			t.Fatal("message not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure spam messages are dropped and counted without being retried.
+func TestDaemon_Poll_Spam(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Store.SpamFilter = &scuttlebutt.SpamFilter{DropSelfPromotion: true, MaxAuthorMentions: 1}
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		return []*scuttlebutt.Message{
+			{ID: 1, RepositoryID: "github.com/owner/repo", Author: "Owner"},
+			{ID: 2, RepositoryID: "github.com/owner/repo", Author: "fan"},
+			{ID: 3, RepositoryID: "github.com/owner/repo", Author: "fan"},
+			{ID: 4, RepositoryID: "github.com/owner/repo", Author: "other"},
+		}, nil
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify only genuine mentions were saved.
+	if r, err := d.Store.Repository("github.com/owner/repo"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 2 || r.Messages[0].ID != 2 || r.Messages[1].ID != 4 {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	}
+
+	// Verify spam was counted and not deferred.
+	status := d.PollerStatus()
+	if !reflect.DeepEqual(status.Spam, map[scuttlebutt.SpamReason]int{scuttlebutt.SpamSelfPromotion: 1, scuttlebutt.SpamRepeated: 1}) {
+		t.Fatalf("unexpected spam counts: %v", status.Spam)
+	} else if status.DeferredN != 0 || len(status.Errors) != 0 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	}
+}
+
+// Ensure transiently failed messages are persisted and saved once retried.
+func TestDaemon_Retry(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.RetryInterval = time.Hour
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		return []*scuttlebutt.Message{{ID: 1, RepositoryID: "github.com/user/repo"}}, nil
+	}
+
+	// Fail the first lookup with a quota that has already reset.
+	var lookupN int
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if lookupN++; lookupN == 1 {
+			return nil, &scuttlebutt.RateLimitError{Reset: time.Now()}
+		}
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if status := d.PollerStatus(); status.RetryN != 1 || status.DeferredN != 0 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	}
+
+	// Retry the message and verify it is saved and removed from the queue.
+	if err := d.Retry(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if r, err := d.Store.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if r == nil || len(r.Messages) != 1 {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if n, err := d.Store.RetryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected retry count: %d", n)
+	}
+}
+
+// Ensure messages over the lookup limit are saved to the retry queue
+// without counting as a failed attempt.
+func TestDaemon_Poll_LookupLimit(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.RetryInterval = time.Hour
+	d.LookupLimit = 1
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		if sinceID > 0 {
+			return nil, nil
+		}
+		return []*scuttlebutt.Message{
+			{ID: 1, RepositoryID: "github.com/user/repo1"},
+			{ID: 2, RepositoryID: "github.com/user/repo2"},
+			{ID: 3, RepositoryID: "github.com/user/repo3"},
+		}, nil
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	// Verify only the first repository is looked up and the rest are queued.
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if n, err := d.Store.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected repository count: %d", n)
+	} else if status := d.PollerStatus(); status.RetryN != 2 || status.DeferredN != 0 || status.DroppedN != 0 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	} else if a, err := d.Store.Retries(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].Attempts != 0 || a[0].Err != scuttlebutt.ErrLookupLimit.Error() {
+		t.Fatalf("unexpected retries: %s", spew.Sdump(a))
+	}
+
+	// Verify the queued messages are saved on the next retry.
+	if err := d.Retry(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if n, err := d.Store.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("unexpected repository count: %d", n)
+	} else if n, err := d.Store.RetryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected retry count: %d", n)
+	}
+}
+
+// Ensure messages are quarantined once their retries are exhausted.
+func TestDaemon_Retry_Exhausted(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.RetryInterval = time.Hour
+	d.MaxRetryAttempts = 1
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		return []*scuttlebutt.Message{{ID: 1, RepositoryID: "github.com/user/repo"}}, nil
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return nil, errors.New("marker")
+	}
+
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if status := d.PollerStatus(); status.RetryN != 0 || len(status.Quarantined) != 1 || status.Quarantined[0].Failures != 1 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	}
+}
+
+// Ensure lookups are deferred while the remote quota is exhausted and
+// resume once it resets.
+func TestDaemon_Poll_RateLimited(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Poller.PollFn = func(sinceID uint64) ([]*scuttlebutt.Message, error) {
+		if sinceID > 0 {
+			return nil, nil
+		}
+		return []*scuttlebutt.Message{
+			{ID: 1, RepositoryID: "github.com/user/repo1"},
+			{ID: 2, RepositoryID: "github.com/user/repo2"},
+		}, nil
+	}
+
+	// Exhaust the quota on the first lookup.
+	reset := time.Now().Add(time.Hour)
+	var lookupN int
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		return nil, &scuttlebutt.RateLimitError{Reset: reset}
+	}
+
+	var sinceID uint64
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if lookupN != 1 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	} else if status := d.PollerStatus(); status.DeferredN != 2 || len(status.Errors) != 0 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	}
+
+	// Verify the quota is persisted and no lookups are made before the reset.
+	if a, err := d.Store.RateLimits(scuttlebutt.RemoteRateLimitKey); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Remaining != 0 || !a[0].Reset.Equal(reset) {
+		t.Fatalf("unexpected rate limits: %s", spew.Sdump(a))
+	} else if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if lookupN != 1 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// Lookups resume once the quota resets.
+	if err := d.Store.SaveRateLimits(scuttlebutt.RemoteRateLimitKey, []*scuttlebutt.RateLimit{{Resource: "core", Reset: time.Now().Add(-time.Second)}}); err != nil {
+		t.Fatal(err)
+	}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+	if err := d.Poll(context.Background(), &sinceID); err != nil {
+		t.Fatal(err)
+	} else if n, err := d.Store.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected repository count: %d", n)
+	} else if status := d.PollerStatus(); status.DeferredN != 0 {
+		t.Fatalf("unexpected status: %s", spew.Sdump(status))
+	}
+}
+
+// Ensure each source tracks its health and backs off after failures.
+func TestDaemon_PollSource(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	var fail bool
+	var p Poller
+	p.PollFn = func(uint64) ([]*scuttlebutt.Message, error) {
+		if fail {
+			return nil, errors.New("marker")
+		}
+		return []*scuttlebutt.Message{{ID: 1, RepositoryID: "github.com/user/repo"}, {ID: 2, RepositoryID: "github.com/user/repo"}}, nil
+	}
+	src := &scuttlebutt.Source{Name: "other", Poller: &p, PollInterval: time.Minute}
+
+	var sinceID uint64
+	if interval, err := d.PollSource(context.Background(), src, &sinceID); err != nil {
+		t.Fatal(err)
+	} else if interval != time.Minute {
+		t.Fatalf("unexpected interval: %s", interval)
+	}
+
+	// Failures back off from the source's interval.
+	fail = true
+	if interval, err := d.PollSource(context.Background(), src, &sinceID); err == nil || err.Error() != "poll: marker" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if interval < 30*time.Second || interval > time.Minute {
+		t.Fatalf("unexpected interval: %s", interval)
+	}
+
+	if a := d.SourceStatus(); len(a) != 1 {
+		t.Fatalf("unexpected statuses: %s", spew.Sdump(a))
+	} else if a[0].Name != "other" || a[0].MessageN != 2 || a[0].FailureN != 1 || a[0].LastError != "poll: marker" || a[0].LastSuccessTime == nil || a[0].RetryAt == nil {
+		t.Fatalf("unexpected status: %s", spew.Sdump(a[0]))
+	}
+}
+
+// Ensure the poll backoff grows exponentially up to the maximum with jitter.
+func TestPollBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{1, 15 * time.Second, 30 * time.Second},
+		{2, 30 * time.Second, 1 * time.Minute},
+		{3, 1 * time.Minute, 2 * time.Minute},
+		{10, 5 * time.Minute, 10 * time.Minute},
+	} {
+		for i := 0; i < 100; i++ {
+			if d := scuttlebutt.PollBackoff(30*time.Second, 10*time.Minute, tt.n); d < tt.min || d > tt.max {
+				t.Fatalf("%d: unexpected backoff: %s", tt.n, d)
+			}
+		}
+	}
+}
+
+// Ensure engagement is fetched for recently sent messages & recorded.
+func TestDaemon_FetchEngagement(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	for _, n := range []*scuttlebutt.Notification{
+		{Username: "oss_go", MessageID: 100, Success: true, Time: time.Now().Add(-30 * 24 * time.Hour)},
+		{Username: "oss_go", MessageID: 200, Success: true},
+		{Username: "oss_go", Success: false, Error: "marker"},
+		{Username: "oss_rb", MessageID: 300, Success: true},
+	} {
+		if err := d.Store.AddNotification(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var requested []uint64
+	d.Accounts = []*scuttlebutt.Account{
+		{Username: "oss_go", Language: "go", Notifier: &EngagementNotifier{
+			EngagementFn: func(ids []uint64) (map[uint64]*scuttlebutt.Engagement, error) {
+				requested = ids
+				return map[uint64]*scuttlebutt.Engagement{200: {Likes: 1, Retweets: 2, Impressions: 3}}, nil
+			},
+		}},
+		{Username: "oss_rb", Language: "ruby", Notifier: &Notifier{}},
+	}
+
+	if err := d.FetchEngagement(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(requested, []uint64{200}) {
+		t.Fatalf("unexpected ids: %v", requested)
+	}
+
+	a, err := d.Store.Notifications(0)
+	if err != nil {
+		t.Fatal(err)
+	} else if e := a[2].Engagement; e == nil || e.Likes != 1 || e.Retweets != 2 || e.Impressions != 3 || e.Time.IsZero() {
+		t.Fatalf("unexpected engagement: %s", spew.Sdump(e))
+	} else if a[0].Engagement != nil || a[3].Engagement != nil {
+		t.Fatal("unexpected engagement")
+	}
+}
+
+// Ensure a snapshot is recorded once per day.
+func TestDaemon_Snapshot(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo1"}); err != nil {
+		t.Fatal(err)
+	} else if err := d.Snapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later snapshots on the same day do not replace the first.
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, Text: "A", RepositoryID: "github.com/user/repo2"}); err != nil {
+		t.Fatal(err)
+	} else if err := d.Snapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ss, err := d.Store.Snapshot(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if ss == nil || len(ss.Languages["go"]) != 1 {
+		t.Fatalf("unexpected snapshot: %s", spew.Sdump(ss))
+	}
+}
+
+// Ensure stale repository metadata is refreshed in place.
+func TestDaemon_Refresh(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.RefreshLimit = 1
+
+	// Import a repository without a language.
+	if _, err := d.Store.ImportRepositories([]*scuttlebutt.Repository{
+		{ID: "github.com/user/repo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lookupN int
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		return &scuttlebutt.Repository{ID: id, Language: "go", Description: "lorem"}, nil
+	}
+
+	// Refresh and verify the repository was updated.
+	if err := d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if r, err := d.Store.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if r.Language != "go" || r.Description != "lorem" {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+
+	// Recently refreshed repositories are not fetched again.
+	if err := d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if lookupN != 1 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+}
+
+// Ensure the daemon notifies accounts and marks repositories as notified.
+func TestDaemon_Notify(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add account that records its notifications.
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Notify twice. The second time should have nothing to send.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 1 || notified[0] != "github.com/user/repo" {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+
+	// Verify the second cycle was recorded as skipped.
+	status := d.NotifierStatus()
+	if len(status.Accounts) != 1 {
+		t.Fatalf("unexpected account count: %d", len(status.Accounts))
+	} else if acc := status.Accounts[0]; acc.Username != "oss_go" || acc.NotifiedN != 1 {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	} else if acc.LastSkip != scuttlebutt.SkipNoRepository || !reflect.DeepEqual(acc.Skips, map[scuttlebutt.SkipReason]int{scuttlebutt.SkipNoRepository: 1}) {
+		t.Fatalf("unexpected skips: %s", spew.Sdump(acc))
+	}
+}
+
+// Ensure a repository can be sent immediately from a chosen account.
+func TestDaemon_ForceNotify(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "rust"}, nil
+	}
+	if _, err := d.Store.AddRepository(context.Background(), "github.com/user/pick"); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []string
+	n := &IntervalNotifier{Interval: time.Hour}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{ID: 100, URL: "https://twitter.com/oss_go/status/100"}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+	d.FeaturedWindow = time.Hour
+
+	// Only the leader may notify.
+	if _, err := d.ForceNotify(context.Background(), "oss_go", "github.com/user/pick"); err != scuttlebutt.ErrNotLeader {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	// Language & interval checks are bypassed.
+	if m, err := d.ForceNotify(context.Background(), "OSS_GO", "github.com/user/pick"); err != nil {
+		t.Fatal(err)
+	} else if m.ID != 100 {
+		t.Fatalf("unexpected message: %s", spew.Sdump(m))
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/pick"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if r, err := d.Store.Repository("github.com/user/pick"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified {
+		t.Fatal("expected notified")
+	}
+
+	if _, err := d.ForceNotify(context.Background(), "nope", "github.com/user/pick"); err != scuttlebutt.ErrAccountNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Blacklisted & recently featured repositories are rejected.
+	if _, err := d.ForceNotify(context.Background(), "oss_go", "github.com/user/pick"); err != scuttlebutt.ErrNotEligible {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := d.Store.AddBlacklist("github.com/user/*"); err != nil {
+		t.Fatal(err)
+	} else if _, err := d.ForceNotify(context.Background(), "oss_go", "github.com/user/pick"); err != scuttlebutt.ErrBlacklisted {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure topic accounts are notified of repositories tagged with their topic.
+func TestDaemon_Notify_Topic(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Language: "python"}
+		if id == "github.com/user/ml" {
+			r.Topics = []string{"machine-learning"}
+		}
+		return r, nil
+	}
+	for i, id := range []string{"ml", "other", "other"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add topic account that records its notifications.
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_ml", Topic: "machine-learning", Notifier: n}}
+
+	// Notify and verify the less mentioned but tagged repository was sent.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/ml"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if acc := d.NotifierStatus().Accounts[0]; acc.Topic != "machine-learning" {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	}
+}
+
+// Ensure pattern accounts are notified of matching repositories.
+func TestDaemon_Notify_Pattern(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+	for i, id := range []string{"k8s-tools", "other", "other"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add pattern account that records its notifications.
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "TrendingK8s", Pattern: regexp.MustCompile(`(?i)kubernetes|k8s`), Notifier: n}}
+
+	// Notify twice. Only the matching repository should be sent.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/k8s-tools"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if acc := d.NotifierStatus().Accounts[0]; acc.Pattern != "(?i)kubernetes|k8s" || acc.LastSkip != scuttlebutt.SkipNoRepository {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	}
+}
+
+// Ensure repositories below the thresholds are not notified.
+func TestDaemon_Notify_Thresholds(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Thresholds = scuttlebutt.Thresholds{MinAuthors: 2}
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	for i, author := range []string{"user", "user"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/repo", Author: author}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add account that records its notifications.
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Verify the self-promoted repository is skipped.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 0 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if acc := d.NotifierStatus().Accounts[0]; acc.LastSkip != scuttlebutt.SkipBelowThreshold {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	} else if a := d.Explain(); len(a) != 1 || !a[0].Blocked || a[0].Reason != "1 authors below minimum of 2" {
+		t.Fatalf("unexpected explanations: %s", spew.Sdump(a))
+	}
+
+	// Add a mention from another author and verify it is sent.
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, RepositoryID: "github.com/user/repo", Author: "other"}); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 1 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+}
+
+// Ensure the next ranked repository is notified when the top repository is
+// below the thresholds.
+func TestDaemon_Notify_Thresholds_NextRanked(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.Thresholds = scuttlebutt.Thresholds{MinStars: 5}
+	d.Store.Thresholds = &d.Thresholds
+
+	stars := map[string]int{"github.com/user/popular": 1, "github.com/user/starred": 10}
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go", Stars: stars[id]}, nil
+	}
+	for i, id := range []string{"github.com/user/popular", "github.com/user/popular", "github.com/user/starred"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Verify the starred repository is sent even though it has fewer mentions.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/starred"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if acc := d.NotifierStatus().Accounts[0]; acc.NotifiedN != 1 || len(acc.Skips) != 0 {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	}
+}
+
+// Ensure repositories archived since they were ranked are not notified.
+func TestDaemon_Notify_Excluded(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	var archived bool
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go", Archived: archived}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+	archived = true
+
+	// Add account that records its notifications.
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		t.Fatalf("unexpected notification: %s", r.ID)
+		return nil, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Verify the refreshed repository is skipped.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if acc := d.NotifierStatus().Accounts[0]; acc.LastSkip != scuttlebutt.SkipExcluded {
+		t.Fatalf("unexpected account status: %s", spew.Sdump(acc))
+	} else if a := d.Explain(); len(a) != 1 || a[0].Reason != "excluded: archived" {
+		t.Fatalf("unexpected explanations: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure blocked repositories are flagged and only sent once approved.
+func TestDaemon_Notify_ContentFilter(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+	d.ContentFilter = &scuttlebutt.ContentFilter{Words: []string{"darn"}}
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Language: "go"}
+		if id == "github.com/user/blocked" {
+			r.Description = "A DARN good tool"
+		}
+		return r, nil
+	}
+	for i, id := range []string{"blocked", "blocked", "other"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add account that records its notifications.
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Verify the blocked repository is flagged instead of sent.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 0 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if f, err := d.Store.FlaggedRepository("github.com/user/blocked"); err != nil {
+		t.Fatal(err)
+	} else if f == nil || f.Reason != "blocked word in description: DARN" {
+		t.Fatalf("unexpected flag: %s", spew.Sdump(f))
+	}
+
+	// The next cycle skips the flagged repository.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/other"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+
+	// Approve the flagged repository and verify it is sent.
+	if err := d.Store.ApproveFlaggedRepository("github.com/user/blocked"); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/other", "github.com/user/blocked"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+}
+
+// Ensure the daemon posts the top repositories as a digest.
+func TestDaemon_Notify_Digest(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	for i, id := range []string{"a", "b", "b", "c", "c", "c"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add digest account that records its notifications.
+	var notified []string
+	n := &DigestNotifier{}
+	n.NotifyDigestFn = func(a []*scuttlebutt.Repository) ([]*scuttlebutt.Message, error) {
+		for _, r := range a {
+			notified = append(notified, r.ID)
+		}
+		return nil, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n, DigestN: 2}}
+
+	// Notify and verify the top two repositories were sent and marked.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/c", "github.com/user/b"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if a, err := d.Store.TopLanguageRepositories("go", 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].ID != "github.com/user/a" {
+		t.Fatalf("unexpected remaining repositories: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure moderated accounts only send approved notifications.
+func TestDaemon_Notify_Moderated(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n, Moderated: true}}
+
+	// Notify twice. A single candidate should be queued and nothing sent.
+	for i := 0; i < 2; i++ {
+		if err := d.Notify(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := d.Store.PendingNotifications()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].RepositoryID != "github.com/user/repo" || a[0].Text != "github.com/user/repo" {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	} else if len(notified) != 0 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+
+	// Approve and notify again.
+	if err := d.Store.ApprovePendingNotification(a[0].ID); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/repo"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if a, err := d.Store.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure approved notifications are dropped if the repository was blacklisted
+// while waiting in the queue.
+func TestDaemon_Notify_Moderated_Blacklisted(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []string
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n, Moderated: true}}
+
+	// Queue the candidate, then blacklist & approve it.
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	a, err := d.Store.PendingNotifications()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	} else if err := d.Store.AddBlacklist("github.com/user/*"); err != nil {
+		t.Fatal(err)
+	} else if err := d.Store.ApprovePendingNotification(a[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 0 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if a, err := d.Store.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure that identical text is not featured by two accounts within the window.
+func TestDaemon_Notify_RecentlyFeatured(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/js" {
+			return &scuttlebutt.Repository{ID: id, Language: "javascript"}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	for i, id := range []string{"github.com/user/go", "github.com/user/js"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var notified []string
+	n := &Notifier{}
+	n.TextFn = func(r *scuttlebutt.Repository) (string, error) { return "same text", nil }
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{
+		{Username: "oss_go", Language: "go", Notifier: n},
+		{Username: "oss_js", Language: "javascript", Notifier: n},
+	}
+
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/go"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+
+	// Verify the blocked pick is explained.
+	a := d.Explain()
+	if len(a) != 2 {
+		t.Fatalf("unexpected explanations: %s", spew.Sdump(a))
+	} else if a[0].Username != "oss_go" || a[0].Blocked || a[0].Reason != "sent" {
+		t.Fatalf("unexpected explanation: %s", spew.Sdump(a[0]))
+	} else if a[1].Username != "oss_js" || !a[1].Blocked || !strings.HasPrefix(a[1].Reason, "identical text featured by @oss_go for github.com/user/go") {
+		t.Fatalf("unexpected explanation: %s", spew.Sdump(a[1]))
+	} else if st := d.NotifierStatus(); st.Accounts[1].LastSkip != scuttlebutt.SkipRecentlyFeatured {
+		t.Fatalf("unexpected skip: %s", st.Accounts[1].LastSkip)
+	}
+}
+
+// Ensure that successful and failed notifications are recorded in the audit log.
+func TestDaemon_Notify_AuditLog(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the first attempt and succeed on the second.
+	var calls int
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		if calls++; calls == 1 {
+			return nil, errors.New("marker")
+		}
+		return &scuttlebutt.Message{ID: 100, Text: r.ID, RepositoryID: r.ID, URL: "https://twitter.com/oss_go/status/100"}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	for i := 0; i < 2; i++ {
+		if err := d.Notify(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := d.Store.Notifications(0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected notifications: %s", spew.Sdump(a))
+	} else if n := a[0]; !n.Success || n.MessageID != 100 || n.URL != "https://twitter.com/oss_go/status/100" || n.Text != "github.com/user/repo" || n.Username != "oss_go" {
+		t.Fatalf("unexpected success: %s", spew.Sdump(n))
+	} else if n := a[1]; n.Success || n.Error != "marker" || n.RepositoryID != "github.com/user/repo" {
+		t.Fatalf("unexpected failure: %s", spew.Sdump(n))
+	}
+}
+
+// Ensure that failed notifications are sent to the error reporter.
+func TestDaemon_Notify_ErrorReporter(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Notifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		return nil, errors.New("marker")
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	var tags []map[string]string
+	d.ErrorReporter = ErrorReporterFunc(func(err error, m map[string]string) {
+		if err.Error() != "marker" {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		tags = append(tags, m)
+	})
+
+	if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(tags) != 1 {
+		t.Fatalf("unexpected reports: %s", spew.Sdump(tags))
+	} else if m := tags[0]; m["op"] != "notify" || m["username"] != "oss_go" || m["repo"] != "github.com/user/repo" {
+		t.Fatalf("unexpected tags: %s", spew.Sdump(m))
+	}
+}
+
+// Ensure that accounts with an exhausted, persisted API quota are deferred.
+func TestDaemon_Notify_RateLimited(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []string
+	n := &RateLimitedNotifier{}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// Persist an exhausted quota.
+	limits := []*scuttlebutt.RateLimit{{Resource: "update", Limit: 10, Reset: time.Now().Add(time.Hour).UTC()}}
+	if err := d.Store.SaveRateLimits("oss_go", limits); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 0 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if st := d.NotifierStatus(); st.Accounts[0].LastSkip != scuttlebutt.SkipRateLimited {
+		t.Fatalf("unexpected skip: %s", st.Accounts[0].LastSkip)
+	}
+
+	// Notify once the quota resets.
+	limits[0].Reset = time.Now().Add(-time.Minute).UTC()
+	if err := d.Store.SaveRateLimits("oss_go", limits); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(notified, []string{"github.com/user/repo"}) {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+}
+
+// Ensure that the last notification time is persisted and used over the notifier.
+func TestDaemon_Notify_LastNotifyTime(t *testing.T) {
+	d := OpenDaemon()
+	defer d.Close()
+
+	d.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	for i, id := range []string{"github.com/user/a", "github.com/user/b"} {
+		if err := d.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Notifier is due once an hour has passed since the last notification.
+	var notified []string
+	n := &IntervalNotifier{Interval: time.Hour}
+	n.NotifyFn = func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+		notified = append(notified, r.ID)
+		return &scuttlebutt.Message{}, nil
+	}
+	d.Accounts = []*scuttlebutt.Account{{Username: "oss_go", Language: "go", Notifier: n}}
+
+	// The first notification saves its time so the second cycle is not due,
+	// even though the notifier itself never reports a last tweet time.
+	for i := 0; i < 2; i++ {
+		if err := d.Notify(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(notified) != 1 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	} else if last, err := d.Store.LastNotifyTime("oss_go"); err != nil {
+		t.Fatal(err)
+	} else if time.Since(last) > time.Minute {
+		t.Fatalf("unexpected last notify time: %s", last)
+	}
+
+	// Notify again once the stored time is outside the interval.
+	if err := d.Store.SetLastNotifyTime("oss_go", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if err := d.Notify(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 2 {
+		t.Fatalf("unexpected notifications: %v", notified)
+	}
+}
+
+// Daemon represents a test wrapper for scuttlebutt.Daemon.
+type Daemon struct {
+	*scuttlebutt.Daemon
+	Store  *Store
+	Poller Poller
+}
+
+// OpenDaemon returns a new daemon with an open store and mock poller.
+// The daemon listens on a random port and is not started.
+func OpenDaemon() *Daemon {
+	d := &Daemon{Daemon: scuttlebutt.NewDaemon(), Store: OpenStore()}
+	d.Daemon.Store = d.Store.Store
+	d.Daemon.Poller = &d.Poller
+	d.Daemon.Addr = "127.0.0.1:0"
+	d.Daemon.LogOutput = ioutil.Discard
+	d.Poller.PollFn = func(uint64) ([]*scuttlebutt.Message, error) { return nil, nil }
+	return d
+}
+
+// Close stops the daemon and closes the store.
+func (d *Daemon) Close() error {
+	d.Daemon.Close()
+	return d.Store.Close()
+}
+
+// Poller represents a mock implementation of scuttlebutt.Poller.
+type Poller struct {
+	PollFn func(sinceID uint64) ([]*scuttlebutt.Message, error)
+}
+
+func (p *Poller) Poll(ctx context.Context, sinceID uint64) ([]*scuttlebutt.Message, error) {
+	return p.PollFn(sinceID)
+}
+
+// Notifier represents a mock implementation of scuttlebutt.Notifier.
+// It is always due and has never sent a notification. The text defaults
+// to the repository ID unless TextFn is set.
+type Notifier struct {
+	NotifyFn func(r *scuttlebutt.Repository) (*scuttlebutt.Message, error)
+	TextFn   func(r *scuttlebutt.Repository) (string, error)
+}
+
+func (n *Notifier) LastTweetTime() (time.Time, error) { return time.Time{}, nil }
+func (n *Notifier) Due(now, last time.Time) bool      { return true }
+func (n *Notifier) Text(r *scuttlebutt.Repository) (string, error) {
+	if n.TextFn != nil {
+		return n.TextFn(r)
+	}
+	return r.ID, nil
+}
+func (n *Notifier) Notify(ctx context.Context, r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+	return n.NotifyFn(r)
+}
+
+// DigestNotifier represents a mock notifier that supports digests.
+type DigestNotifier struct {
+	Notifier
+	NotifyDigestFn func(a []*scuttlebutt.Repository) ([]*scuttlebutt.Message, error)
+}
+
+func (n *DigestNotifier) NotifyDigest(ctx context.Context, a []*scuttlebutt.Repository) ([]*scuttlebutt.Message, error) {
+	return n.NotifyDigestFn(a)
+}
+
+// RateLimitedNotifier represents a mock notifier that tracks API quotas.
+type RateLimitedNotifier struct {
+	Notifier
+	Limits []*scuttlebutt.RateLimit
+}
+
+func (n *RateLimitedNotifier) RateLimits() []*scuttlebutt.RateLimit     { return n.Limits }
+func (n *RateLimitedNotifier) SetRateLimits(a []*scuttlebutt.RateLimit) { n.Limits = a }
+
+// EngagementNotifier represents a mock notifier that fetches engagement.
+type EngagementNotifier struct {
+	Notifier
+	EngagementFn func(ids []uint64) (map[uint64]*scuttlebutt.Engagement, error)
+}
+
+func (n *EngagementNotifier) Engagement(ctx context.Context, ids []uint64) (map[uint64]*scuttlebutt.Engagement, error) {
+	return n.EngagementFn(ids)
+}
+
+// ErrorReporterFunc implements scuttlebutt.ErrorReporter with a function.
+type ErrorReporterFunc func(err error, tags map[string]string)
+
+func (fn ErrorReporterFunc) ReportError(err error, tags map[string]string) { fn(err, tags) }
+
+// IntervalNotifier represents a mock notifier that is due after an interval.
+type IntervalNotifier struct {
+	Notifier
+	Interval time.Duration
+}
+
+func (n *IntervalNotifier) Due(now, last time.Time) bool { return now.Sub(last) >= n.Interval }
+
+// Elector represents a mock implementation of scuttlebutt.Elector.
+type Elector struct {
+	CampaignFn func(ctx context.Context) (<-chan struct{}, error)
+	ResignFn   func() error
+}
+
+func (e *Elector) Campaign(ctx context.Context) (<-chan struct{}, error) { return e.CampaignFn(ctx) }
+func (e *Elector) Resign() error                                         { return e.ResignFn() }
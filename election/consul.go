@@ -0,0 +1,188 @@
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConsulAddr is the default address of the Consul agent.
+	DefaultConsulAddr = "http://127.0.0.1:8500"
+
+	// DefaultSessionTTL is the default time a session lives without being
+	// renewed. Leadership fails over after roughly this long if the leader
+	// stops renewing its session.
+	DefaultSessionTTL = 15 * time.Second
+)
+
+// Consul elects the replica whose session holds the lock on a key in
+// Consul's KV store. The session is renewed in the background and
+// leadership is lost if it cannot be renewed.
+type Consul struct {
+	mu      sync.Mutex
+	session string
+	closing chan struct{}
+	done    chan struct{}
+
+	// Address of the Consul HTTP API & key that is locked.
+	Addr string
+	Key  string
+
+	// Time a session lives without being renewed.
+	TTL time.Duration
+
+	// Optional ACL token.
+	Token string
+
+	// Time between attempts to acquire the lock while it is held.
+	RetryInterval time.Duration
+
+	HTTPClient *http.Client
+}
+
+// NewConsul returns a new instance of Consul that locks key.
+func NewConsul(addr, key string) *Consul {
+	if addr == "" {
+		addr = DefaultConsulAddr
+	}
+	return &Consul{
+		Addr:          addr,
+		Key:           key,
+		TTL:           DefaultSessionTTL,
+		RetryInterval: DefaultLockRetryInterval,
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Campaign creates a session and blocks until it acquires the lock or ctx is
+// done. The returned channel is closed if the session cannot be renewed.
+func (c *Consul) Campaign(ctx context.Context) (<-chan struct{}, error) {
+	var v struct{ ID string }
+	if err := c.do(ctx, "PUT", "/v1/session/create", map[string]string{
+		"Name":     "scuttlebutt",
+		"TTL":      c.TTL.String(),
+		"Behavior": "release",
+	}, &v); err != nil {
+		return nil, fmt.Errorf("create session: %s", err)
+	}
+
+	// Attempt to acquire the lock until it is free.
+	for {
+		var acquired bool
+		if err := c.do(ctx, "PUT", "/v1/kv/"+c.Key+"?acquire="+url.QueryEscape(v.ID), nil, &acquired); err != nil {
+			c.destroy(v.ID)
+			return nil, fmt.Errorf("acquire: %s", err)
+		} else if acquired {
+			break
+		}
+
+		// Renew the session while waiting so it does not expire.
+		select {
+		case <-time.After(c.RetryInterval):
+			if err := c.renew(ctx, v.ID); err != nil {
+				c.destroy(v.ID)
+				return nil, fmt.Errorf("renew session: %s", err)
+			}
+		case <-ctx.Done():
+			c.destroy(v.ID)
+			return nil, ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = v.ID
+	c.closing, c.done = make(chan struct{}), make(chan struct{})
+	lost := make(chan struct{})
+	go c.keepalive(v.ID, c.closing, c.done, lost)
+	return lost, nil
+}
+
+// keepalive renews the session at half its TTL until closing is closed.
+// Closes lost if the session cannot be renewed.
+func (c *Consul) keepalive(id string, closing, done, lost chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.TTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closing:
+			return
+		case <-ticker.C:
+			if err := c.renew(context.Background(), id); err != nil {
+				close(lost)
+				return
+			}
+		}
+	}
+}
+
+// Resign releases the lock and destroys the session.
+func (c *Consul) Resign() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == "" {
+		return nil
+	}
+	close(c.closing)
+	<-c.done
+
+	var released bool
+	err := c.do(context.Background(), "PUT", "/v1/kv/"+c.Key+"?release="+url.QueryEscape(c.session), nil, &released)
+	if e := c.destroy(c.session); e != nil && err == nil {
+		err = e
+	}
+	c.session = ""
+	return err
+}
+
+// renew extends the session's TTL.
+func (c *Consul) renew(ctx context.Context, id string) error {
+	return c.do(ctx, "PUT", "/v1/session/renew/"+id, nil, nil)
+}
+
+// destroy removes a session, releasing any lock it holds.
+func (c *Consul) destroy(id string) error {
+	return c.do(context.Background(), "PUT", "/v1/session/destroy/"+id, nil, nil)
+}
+
+// do sends a request to the Consul API and decodes the JSON response into v.
+func (c *Consul) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Addr, "/")+path, &buf)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	} else if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,79 @@
+package election_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/election"
+)
+
+// Ensure a session is created, the key acquired, and leadership is lost
+// when the session can no longer be renewed.
+func TestConsul_Campaign(t *testing.T) {
+	var mu sync.Mutex
+	var acquireN int
+	var paths []string
+	renewed := true
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/v1/session/create":
+			w.Write([]byte(`{"ID":"SESSIONID"}`))
+		case "/v1/kv/scuttlebutt/leader":
+			if r.URL.Query().Get("acquire") == "SESSIONID" {
+				acquireN++
+				if acquireN == 1 {
+					w.Write([]byte(`false`))
+					return
+				}
+			}
+			w.Write([]byte(`true`))
+		case "/v1/session/renew/SESSIONID":
+			if !renewed {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`[]`))
+		case "/v1/session/destroy/SESSIONID":
+			w.Write([]byte(`true`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer s.Close()
+
+	c := election.NewConsul(s.URL, "scuttlebutt/leader")
+	c.TTL, c.RetryInterval = 20*time.Millisecond, time.Millisecond
+	lost, err := c.Campaign(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leadership is lost once the session expires.
+	mu.Lock()
+	renewed = false
+	mu.Unlock()
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	if err := c.Resign(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if acquireN != 2 {
+		t.Fatalf("unexpected acquire count: %d", acquireN)
+	} else if paths[len(paths)-1] != "/v1/session/destroy/SESSIONID" {
+		t.Fatalf("unexpected last path: %s", paths[len(paths)-1])
+	}
+}
@@ -0,0 +1,8 @@
+// Package election implements leader election so that only one of several
+// scuttlebuttd replicas polls and notifies at a time.
+package election
+
+import "errors"
+
+// ErrNotSupported is returned when an elector cannot run on this platform.
+var ErrNotSupported = errors.New("election not supported on this platform")
@@ -0,0 +1,78 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultLockRetryInterval is the default time between attempts to acquire
+// a held lock.
+const DefaultLockRetryInterval = time.Second
+
+// FileLock elects the replica holding an exclusive lock on a file. Replicas
+// must share the file, such as on the same host or a shared volume that
+// supports locking. Leadership is held until Resign() or the process exits.
+type FileLock struct {
+	mu sync.Mutex
+	f  *os.File
+
+	// Path to the lock file. Created if it does not exist.
+	Path string
+
+	// Time between attempts to acquire the lock while it is held.
+	RetryInterval time.Duration
+}
+
+// NewFileLock returns a new instance of FileLock for path.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{Path: path, RetryInterval: DefaultLockRetryInterval}
+}
+
+// Campaign blocks until the lock is acquired or ctx is done. The returned
+// channel is never closed since the lock is only lost if the file is.
+func (l *FileLock) Campaign(ctx context.Context) (<-chan struct{}, error) {
+	f, err := os.OpenFile(l.Path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if ok, err := tryLock(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock: %s", err)
+		} else if ok {
+			break
+		}
+
+		select {
+		case <-time.After(l.RetryInterval):
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	l.f = f
+	l.mu.Unlock()
+	return make(chan struct{}), nil
+}
+
+// Resign releases the lock.
+func (l *FileLock) Resign() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	err := unlock(l.f)
+	if e := l.f.Close(); e != nil && err == nil {
+		err = e
+	}
+	l.f = nil
+	return err
+}
@@ -0,0 +1,45 @@
+package election_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/election"
+)
+
+// Ensure a second replica is only elected after the leader resigns.
+func TestFileLock_Campaign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "election-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "leader.lock")
+
+	l0, l1 := election.NewFileLock(path), election.NewFileLock(path)
+	l1.RetryInterval = time.Millisecond
+	if _, err := l0.Campaign(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Campaign is cancelled while the lock is held.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l1.Campaign(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Campaign succeeds once the leader resigns.
+	if err := l0.Resign(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l1.Campaign(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if err := l1.Resign(); err != nil {
+		t.Fatal(err)
+	}
+}
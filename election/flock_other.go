@@ -0,0 +1,12 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package election
+
+import "os"
+
+// tryLock is not supported on this platform.
+func tryLock(f *os.File) (bool, error) { return false, ErrNotSupported }
+
+// unlock is not supported on this platform.
+func unlock(f *os.File) error { return ErrNotSupported }
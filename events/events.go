@@ -0,0 +1,159 @@
+// Package events implements a lightweight publish/subscribe bus so that
+// sinks, metrics, and integrations can observe activity without coupling to
+// the store, poller, or notifiers.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBufferSize is the default number of events buffered per subscription.
+const DefaultBufferSize = 100
+
+// Event types.
+const (
+	TypeMessageAdded      = "message_added"
+	TypeRepositoryCreated = "repository_created"
+	TypeNotificationSent  = "notification_sent"
+	TypePollCompleted     = "poll_completed"
+)
+
+// Event represents an event published on the bus.
+type Event interface {
+	// Type returns the event type, such as TypeMessageAdded.
+	Type() string
+}
+
+// MessageAdded is published when a message is saved to a repository.
+type MessageAdded struct {
+	ID           uint64 `json:"id,string"`
+	RepositoryID string `json:"repository_id"`
+	Language     string `json:"language,omitempty"`
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	Author       string `json:"author,omitempty"`
+}
+
+// Type returns TypeMessageAdded.
+func (e *MessageAdded) Type() string { return TypeMessageAdded }
+
+// RepositoryCreated is published when a repository is first saved.
+type RepositoryCreated struct {
+	RepositoryID string `json:"repository_id"`
+	Description  string `json:"description,omitempty"`
+	Language     string `json:"language,omitempty"`
+}
+
+// Type returns TypeRepositoryCreated.
+func (e *RepositoryCreated) Type() string { return TypeRepositoryCreated }
+
+// NotificationSent is published when an account posts a notification.
+type NotificationSent struct {
+	Username     string `json:"username"`
+	RepositoryID string `json:"repository_id,omitempty"`
+	Text         string `json:"text"`
+	MessageID    uint64 `json:"message_id,string,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// Type returns TypeNotificationSent.
+func (e *NotificationSent) Type() string { return TypeNotificationSent }
+
+// PollCompleted is published after each poll of a message source.
+type PollCompleted struct {
+	Source   string        `json:"source"`
+	MessageN int           `json:"messages"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Type returns TypePollCompleted.
+func (e *PollCompleted) Type() string { return TypePollCompleted }
+
+// Bus delivers published events to subscribers. Publishing never blocks;
+// events are dropped for subscribers whose buffers are full. A nil bus
+// discards all events.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus returns a new instance of Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to all subscribers of its type.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !sub.accepts(e.Type()) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			atomic.AddUint64(&sub.droppedN, 1)
+		}
+	}
+}
+
+// Subscribe returns a new subscription to events of the given types. All
+// events are delivered if no types are specified.
+func (b *Bus) Subscribe(types ...string) *Subscription {
+	sub := &Subscription{bus: b, c: make(chan Event, DefaultBufferSize)}
+	if len(types) > 0 {
+		sub.types = make(map[string]struct{}, len(types))
+		for _, typ := range types {
+			sub.types[typ] = struct{}{}
+		}
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Subscription represents a subscriber's stream of events.
+type Subscription struct {
+	once     sync.Once
+	droppedN uint64
+	bus      *Bus
+	types    map[string]struct{}
+	c        chan Event
+}
+
+// C returns the channel that events are delivered on. The channel is closed
+// when the subscription is closed.
+func (sub *Subscription) C() <-chan Event { return sub.c }
+
+// DroppedN returns the number of events dropped because the buffer was full.
+func (sub *Subscription) DroppedN() uint64 { return atomic.LoadUint64(&sub.droppedN) }
+
+// Close removes the subscription from the bus and closes its channel.
+func (sub *Subscription) Close() error {
+	sub.once.Do(func() {
+		sub.bus.mu.Lock()
+		delete(sub.bus.subs, sub)
+		sub.bus.mu.Unlock()
+		close(sub.c)
+	})
+	return nil
+}
+
+// accepts returns true if the subscription receives events of typ.
+func (sub *Subscription) accepts(typ string) bool {
+	if sub.types == nil {
+		return true
+	}
+	_, ok := sub.types[typ]
+	return ok
+}
@@ -0,0 +1,54 @@
+package events_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/events"
+)
+
+// Ensure subscribers receive events of their types.
+func TestBus_Publish(t *testing.T) {
+	b := events.NewBus()
+	all := b.Subscribe()
+	defer all.Close()
+	polls := b.Subscribe(events.TypePollCompleted)
+	defer polls.Close()
+
+	b.Publish(&events.MessageAdded{ID: 1, RepositoryID: "github.com/user/repo"})
+	b.Publish(&events.PollCompleted{Source: "twitter", MessageN: 1})
+
+	if e := <-all.C(); !reflect.DeepEqual(e, &events.MessageAdded{ID: 1, RepositoryID: "github.com/user/repo"}) {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if e := <-all.C(); e.Type() != events.TypePollCompleted {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if e := <-polls.C(); !reflect.DeepEqual(e, &events.PollCompleted{Source: "twitter", MessageN: 1}) {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+}
+
+// Ensure publishing drops events instead of blocking on full subscribers.
+func TestBus_Publish_Full(t *testing.T) {
+	b := events.NewBus()
+	sub := b.Subscribe()
+	defer sub.Close()
+
+	for i := 0; i < events.DefaultBufferSize+5; i++ {
+		b.Publish(&events.MessageAdded{ID: uint64(i)})
+	}
+	if n := sub.DroppedN(); n != 5 {
+		t.Fatalf("unexpected dropped count: %d", n)
+	}
+}
+
+// Ensure closed subscriptions no longer receive events.
+func TestSubscription_Close(t *testing.T) {
+	b := events.NewBus()
+	sub := b.Subscribe()
+	sub.Close()
+	b.Publish(&events.MessageAdded{ID: 1})
+
+	if _, ok := <-sub.C(); ok {
+		t.Fatal("expected closed channel")
+	}
+}
@@ -0,0 +1,123 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// ErrInvalidRepositoryID is returned when the repository ID does not conform
+// to a 3-segment host/owner/repository path.
+var ErrInvalidRepositoryID = errors.New("invalid repository id")
+
+// Store represents a Gitea instance, such as Codeberg, as a data store.
+type Store struct {
+	token string
+
+	// Base URL of the Gitea instance, such as "https://codeberg.org".
+	URL string
+
+	// Client used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewStore returns a new instance of Store for a Gitea instance.
+// Public repositories can be retrieved without a token.
+func NewStore(baseURL, token string) *Store {
+	return &Store{
+		token:      token,
+		URL:        strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Repository returns a repository by ID. Returns nil if it does not exist.
+func (s *Store) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
+	// Parse repository ID.
+	segments := strings.Split(id, "/")
+	if len(segments) != 3 {
+		return nil, ErrInvalidRepositoryID
+	}
+	path := "repos/" + url.PathEscape(segments[1]) + "/" + url.PathEscape(segments[2])
+
+	// Retrieve repository data.
+	var repo struct {
+		Description string `json:"description"`
+		Language    string `json:"language"`
+		StarsCount  int    `json:"stars_count"`
+		ForksCount  int    `json:"forks_count"`
+		Fork        bool   `json:"fork"`
+		Archived    bool   `json:"archived"`
+
+		// SPDX identifiers of the detected licenses, such as "MIT".
+		// Only reported by Gitea 1.23 and later.
+		Licenses []string `json:"licenses"`
+	}
+	if ok, err := s.get(ctx, path, &repo); err != nil {
+		return nil, fmt.Errorf("get repository: %s", err)
+	} else if !ok {
+		return nil, nil
+	}
+
+	r := &scuttlebutt.Repository{
+		ID:          id,
+		Description: repo.Description,
+		Language:    repo.Language,
+		Stars:       repo.StarsCount,
+		Forks:       repo.ForksCount,
+		Fork:        repo.Fork,
+		Archived:    repo.Archived,
+	}
+	if len(repo.Licenses) > 0 {
+		r.License = strings.ToLower(repo.Licenses[0])
+	}
+
+	// Retrieve topics.
+	var topics struct {
+		Topics []string `json:"topics"`
+	}
+	if _, err := s.get(ctx, path+"/topics", &topics); err != nil {
+		return nil, fmt.Errorf("get topics: %s", err)
+	}
+	r.Topics = topics.Topics
+
+	return r, nil
+}
+
+// get retrieves an API path and decodes the response into v.
+// Returns false if the resource does not exist.
+func (s *Store) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", s.URL+"/api/v1/"+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	if s.token != "" {
+		req.Header.Set("Authorization", "token "+s.token)
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package gitea_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/gitea"
+)
+
+// Ensure a repository can be retrieved from a Gitea instance.
+func TestStore_Repository(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token TOKEN" {
+			t.Fatalf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/api/v1/repos/user/proj":
+			w.Write([]byte(`{"description":"lorem","language":"Go","stars_count":10,"forks_count":2,"fork":true,"licenses":["Apache-2.0"]}`))
+		case "/api/v1/repos/user/proj/topics":
+			w.Write([]byte(`{"topics":["cli"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	store := gitea.NewStore(s.URL, "TOKEN")
+	if r, err := store.Repository(context.Background(), "codeberg.org/user/proj"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r, &scuttlebutt.Repository{
+		ID:          "codeberg.org/user/proj",
+		Description: "lorem",
+		Language:    "Go",
+		Stars:       10,
+		Forks:       2,
+		Topics:      []string{"cli"},
+		License:     "apache-2.0",
+		Fork:        true,
+	}) {
+		t.Fatalf("unexpected repository: %#v", r)
+	}
+
+	// Missing repositories return nil.
+	if r, err := store.Repository(context.Background(), "codeberg.org/user/nope"); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Fatalf("unexpected repository: %#v", r)
+	}
+}
@@ -0,0 +1,195 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the GitHub REST API root.
+const DefaultBaseURL = "https://api.github.com/"
+
+// ErrInvalidPrivateKey is returned when a GitHub App private key cannot be parsed.
+var ErrInvalidPrivateKey = errors.New("invalid private key")
+
+// AppTransport is an http.RoundTripper that authenticates requests as a
+// GitHub App installation. Installation tokens are requested using a JWT
+// signed with the app's private key and are refreshed before they expire.
+type AppTransport struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+
+	// API root used to request installation tokens. Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Underlying transport. Uses http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// NewAppTransport returns a new instance of AppTransport with a PEM encoded
+// private key, as downloaded from the app's settings.
+func NewAppTransport(appID, installationID int64, privateKey []byte) (*AppTransport, error) {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &AppTransport{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     key,
+		BaseURL:        DefaultBaseURL,
+	}, nil
+}
+
+// RoundTrip executes a request using the installation token.
+func (t *AppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the request so the caller's headers are not modified.
+	other := *req
+	other.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		other.Header[k] = v
+	}
+	other.Header.Set("Authorization", "token "+token)
+
+	return t.transport().RoundTrip(&other)
+}
+
+// Token returns the current installation token. A new token is requested
+// if there is none or if it expires within a minute.
+func (t *AppTransport) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Add(time.Minute).Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	token, expiresAt, err := t.requestToken()
+	if err != nil {
+		return "", fmt.Errorf("installation token: %s", err)
+	}
+	t.token, t.expiresAt = token, expiresAt
+	return token, nil
+}
+
+// requestToken requests a new installation token from GitHub.
+func (t *AppTransport) requestToken() (string, time.Time, error) {
+	jwt, err := t.jwt(time.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	baseURL := t.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	u := strings.TrimSuffix(baseURL, "/") + "/app/installations/" + strconv.FormatInt(t.InstallationID, 10) + "/access_tokens"
+
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var v struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", time.Time{}, err
+	}
+	return v.Token, v.ExpiresAt, nil
+}
+
+// jwt returns a JSON Web Token identifying the app, signed with its private
+// key. The issued time is backdated to allow for clock drift.
+func (t *AppTransport) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": t.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	sum := sha256.Sum256(buf.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, t.PrivateKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+
+	return buf.String(), nil
+}
+
+// transport returns the underlying transport.
+func (t *AppTransport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #1 or PKCS #8 RSA private key.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	} else if key, ok := key.(*rsa.PrivateKey); ok {
+		return key, nil
+	}
+	return nil, ErrInvalidPrivateKey
+}
@@ -0,0 +1,80 @@
+package github_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/github"
+)
+
+// Ensure requests are authenticated with a cached installation token.
+func TestAppTransport_RoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var tokenN int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			tokenN++
+
+			// Verify the JWT is signed by the app's key.
+			parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+			if len(parts) != 3 {
+				t.Fatalf("unexpected authorization: %s", r.Header.Get("Authorization"))
+			}
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+				t.Fatal(err)
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token":"T1","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		default:
+			if r.Header.Get("Authorization") != "token T1" {
+				t.Fatalf("unexpected authorization: %s", r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer s.Close()
+
+	tr, err := github.NewAppTransport(1, 42, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.BaseURL = s.URL
+
+	client := &http.Client{Transport: tr}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(s.URL + "/repos/user/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if tokenN != 1 {
+		t.Fatalf("unexpected token request count: %d", tokenN)
+	}
+}
+
+// Ensure malformed private keys are rejected.
+func TestParsePrivateKey_Invalid(t *testing.T) {
+	if _, err := github.ParsePrivateKey([]byte("not a key")); err != github.ErrInvalidPrivateKey {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
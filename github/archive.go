@@ -0,0 +1,142 @@
+package github
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// ArchiveReader reads repository metadata from a CSV export of the
+// GHArchive BigQuery dataset.
+//
+// The first row must be a header. The "repo_name" column (or "name" / "id")
+// is required and holds either "username/repository" or a full URL. The
+// "language", "description", and "stars" (or "stargazers_count" /
+// "watch_count") columns are optional.
+type ArchiveReader struct {
+	r      *csv.Reader
+	header map[string]int
+}
+
+// ArchiveRowError is returned by ArchiveReader.Read for a malformed row.
+// Reading can continue with the next row.
+type ArchiveRowError struct {
+	Line int
+	Err  error
+}
+
+func (e *ArchiveRowError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Err) }
+
+// NewArchiveReader returns a new instance of ArchiveReader.
+func NewArchiveReader(r io.Reader) *ArchiveReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return &ArchiveReader{r: cr}
+}
+
+// Read returns the next repository in the archive. Returns an
+// *ArchiveRowError if the row is malformed and io.EOF when there are no more
+// repositories.
+func (r *ArchiveReader) Read() (*scuttlebutt.Repository, error) {
+	// Read the header on the first call.
+	if r.header == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := r.r.Read()
+	if e, ok := err.(*csv.ParseError); ok {
+		return nil, &ArchiveRowError{Line: e.Line, Err: e.Err}
+	} else if err != nil {
+		return nil, err
+	}
+	line, _ := r.r.FieldPos(0)
+
+	// Parse the repository ID.
+	id, err := archiveRepositoryID(r.field(record, "repo_name", "name", "id"))
+	if err != nil {
+		return nil, &ArchiveRowError{Line: line, Err: err}
+	}
+
+	repo := &scuttlebutt.Repository{
+		ID:          id,
+		Language:    r.field(record, "language"),
+		Description: r.field(record, "description"),
+	}
+
+	// Parse star count, if available.
+	if s := r.field(record, "stars", "stargazers_count", "watch_count"); s != "" {
+		if repo.Stars, err = strconv.Atoi(s); err != nil {
+			return nil, &ArchiveRowError{Line: line, Err: fmt.Errorf("invalid stars: %s: %q", id, s)}
+		}
+	}
+
+	return repo, nil
+}
+
+// readHeader reads the first row and maps column names to indexes.
+func (r *ArchiveReader) readHeader() error {
+	record, err := r.r.Read()
+	if err != nil {
+		return err
+	}
+
+	r.header = make(map[string]int)
+	for i, name := range record {
+		r.header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	// Ensure there is a repository name column.
+	if r.index("repo_name", "name", "id") == -1 {
+		return fmt.Errorf("missing repo_name column")
+	}
+	return nil
+}
+
+// index returns the column index of the first matching column name.
+// Returns -1 if no columns match.
+func (r *ArchiveReader) index(names ...string) int {
+	for _, name := range names {
+		if i, ok := r.header[name]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// field returns the value of the first matching column name in a record.
+func (r *ArchiveReader) field(record []string, names ...string) string {
+	if i := r.index(names...); i >= 0 && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// archiveRepositoryID converts an archive repository name to a repository ID.
+// The owner & name keep their case so that imported repositories are stored
+// under the same IDs as mentioned ones.
+func archiveRepositoryID(name string) (string, error) {
+	name = trimPrefixFold(name, "https://")
+	name = trimPrefixFold(name, "http://")
+	name = trimPrefixFold(name, "api.github.com/repos/")
+	name = trimPrefixFold(name, "github.com/")
+
+	segments := strings.Split(name, "/")
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return "", fmt.Errorf("invalid repo name: %q", name)
+	}
+	return "github.com/" + segments[0] + "/" + segments[1], nil
+}
+
+// trimPrefixFold returns s without prefix, ignoring case.
+func trimPrefixFold(s, prefix string) string {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):]
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package github_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/github"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Ensure the archive reader can parse a BigQuery CSV export.
+func TestArchiveReader_Read(t *testing.T) {
+	r := github.NewArchiveReader(strings.NewReader(`repo_name,language,stars,description
+benbjohnson/Proj,Go,100,"my awesome, project"
+not-a-repo,Go,1,
+user/bad,Go,lots,
+HTTPS://GitHub.com/User/Repo,JavaScript,,
+`))
+
+	var a []*scuttlebutt.Repository
+	var errs []string
+	for {
+		repo, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if _, ok := err.(*github.ArchiveRowError); ok {
+			errs = append(errs, err.Error())
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		a = append(a, repo)
+	}
+
+	if !reflect.DeepEqual(a, []*scuttlebutt.Repository{
+		{ID: "github.com/benbjohnson/Proj", Language: "Go", Stars: 100, Description: "my awesome, project"},
+		{ID: "github.com/User/Repo", Language: "JavaScript"},
+	}) {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(a))
+	} else if !reflect.DeepEqual(errs, []string{
+		`line 3: invalid repo name: "not-a-repo"`,
+		`line 4: invalid stars: github.com/user/bad: "lots"`,
+	}) {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+}
+
+// Ensure the archive reader returns an error without a repository column.
+func TestArchiveReader_Read_ErrMissingRepoName(t *testing.T) {
+	r := github.NewArchiveReader(strings.NewReader("language,stars\nGo,100\n"))
+	if _, err := r.Read(); err == nil || err.Error() != `missing repo_name column` {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,159 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheTransport is an http.RoundTripper that caches GET responses on disk
+// and revalidates them with conditional requests. GitHub does not count
+// "304 Not Modified" responses against the rate limit so cached responses
+// are returned without spending quota.
+type CacheTransport struct {
+	// Directory where responses are cached.
+	Dir string
+
+	// Underlying transport. Uses http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// cacheEntry represents a cached response.
+type cacheEntry struct {
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// RoundTrip executes a request. Cached responses are revalidated and are
+// returned with a 200 status if they have not been modified.
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := t.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if req.Method != "GET" {
+		return rt.RoundTrip(req)
+	}
+
+	// Add validators from the cached response, if available.
+	path := t.path(req)
+	entry := readCacheEntry(path)
+	if entry != nil {
+		other := *req
+		other.Header = make(http.Header, len(req.Header)+2)
+		for k, v := range req.Header {
+			other.Header[k] = v
+		}
+		if entry.ETag != "" {
+			other.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			other.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+		req = &other
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if entry == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		return entry.response(req, resp.Header), nil
+
+	case http.StatusOK:
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag == "" && lastModified == "" {
+			return resp, nil
+		}
+
+		// Read the body so it can be both cached and returned.
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// Failing to cache a response does not fail the request.
+		writeCacheEntry(path, &cacheEntry{ETag: etag, LastModified: lastModified, Header: resp.Header, Body: body})
+	}
+	return resp, nil
+}
+
+// path returns the cache file path for a request. Responses vary by the
+// requested media type so the Accept header is part of the key.
+func (t *CacheTransport) path(req *http.Request) string {
+	h := sha1.Sum([]byte(req.URL.String() + "\n" + req.Header.Get("Accept")))
+	return filepath.Join(t.Dir, hex.EncodeToString(h[:]))
+}
+
+// response returns a response for the cached entry. Rate limit headers are
+// copied from the revalidation response so the client sees current quota.
+func (e *cacheEntry) response(req *http.Request, header http.Header) *http.Response {
+	h := make(http.Header, len(e.Header))
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	for k, v := range header {
+		if strings.HasPrefix(k, "X-Ratelimit-") {
+			h[k] = v
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// readCacheEntry reads a cached response. Returns nil if the entry does not
+// exist or cannot be read.
+func readCacheEntry(path string) *cacheEntry {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// writeCacheEntry atomically writes a cached response.
+func writeCacheEntry(path string, entry *cacheEntry) error {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	} else if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
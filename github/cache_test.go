@@ -0,0 +1,58 @@
+package github_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/github"
+)
+
+// Ensure cached responses are revalidated and returned when not modified.
+func TestCacheTransport_RoundTrip(t *testing.T) {
+	var requestN int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestN++
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("X-RateLimit-Remaining", "9")
+		w.Write([]byte(`{"name":"repo"}`))
+	}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "scuttlebutt-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client := &http.Client{Transport: &github.CacheTransport{Dir: dir}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(s.URL + "/repos/user/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%d. unexpected status: %d", i, resp.StatusCode)
+		} else if string(body) != `{"name":"repo"}` {
+			t.Fatalf("%d. unexpected body: %s", i, body)
+		}
+
+		// The revalidated response reports the current quota.
+		if i == 1 && resp.Header.Get("X-RateLimit-Remaining") != "10" {
+			t.Fatalf("unexpected remaining: %s", resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+	if requestN != 2 {
+		t.Fatalf("unexpected request count: %d", requestN)
+	}
+}
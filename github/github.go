@@ -1,10 +1,14 @@
 package github
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"github.com/benbjohnson/scuttlebutt"
@@ -17,24 +21,97 @@ var (
 	ErrInvalidRepositoryID = errors.New("invalid repository id")
 )
 
-// Store represents GitHub as a data store.
+// TopicsMediaType is the media type required to retrieve repository topics.
+const TopicsMediaType = "application/vnd.github.mercy-preview+json"
+
+// Store represents GitHub as a data store. It is safe for concurrent use.
 type Store struct {
-	client *github.Client
+	mu   sync.Mutex
+	rate github.Rate
+
+	httpClient *http.Client
+	baseURL    *url.URL
 }
 
 // NewStore returns a new instance of Store.
 func NewStore(token string) *Store {
-	return &Store{
-		client: github.NewClient(
-			(&oauth.Transport{
-				Token: &oauth.Token{AccessToken: token},
-			}).Client(),
-		),
+	return NewStoreWithCache(token, "")
+}
+
+// NewStoreWithCache returns a new instance of Store that caches responses in
+// dir and revalidates them with conditional requests. Responses are not
+// cached if dir is blank.
+func NewStoreWithCache(token, dir string) *Store {
+	return NewStoreWithTransport(token, dir, nil)
+}
+
+// NewStoreWithTransport returns a new instance of Store that sends requests
+// through base, or http.DefaultTransport if nil. Responses are cached in dir,
+// if specified.
+func NewStoreWithTransport(token, dir string, base http.RoundTripper) *Store {
+	t := &oauth.Transport{Token: &oauth.Token{AccessToken: token}, Transport: base}
+	if dir != "" {
+		t.Transport = &CacheTransport{Dir: dir, Transport: base}
+	}
+	return &Store{httpClient: t.Client()}
+}
+
+// NewAppStore returns a new instance of Store authenticated as a GitHub App
+// installation. Responses are cached in dir, if specified.
+func NewAppStore(t *AppTransport, dir string) *Store {
+	if dir != "" {
+		t.Transport = &CacheTransport{Dir: dir, Transport: t.Transport}
+	}
+	return &Store{httpClient: &http.Client{Transport: t}}
+}
+
+// NewEnterpriseStore returns a new instance of Store for a GitHub Enterprise
+// host. The base URL is the host's API root, such as
+// "https://github.example.com/api/v3/". Requests are sent through transport,
+// or http.DefaultTransport if nil.
+func NewEnterpriseStore(token, baseURL, dir string, transport http.RoundTripper) (*Store, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	} else if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	s := NewStoreWithTransport(token, dir, transport)
+	s.baseURL = u
+	return s, nil
+}
+
+// RateLimit returns the remaining and total requests for the current token.
+func (s *Store) RateLimit() (remaining, limit int, err error) {
+	rate, _, err := s.newClient().RateLimit()
+	if err != nil {
+		return 0, 0, fmt.Errorf("rate limit: %s", err)
 	}
+	return rate.Remaining, rate.Limit, nil
 }
 
-// Repository returns a repository by ID.
-func (s *Store) Repository(id string) (*scuttlebutt.Repository, error) {
+// RateLimits returns the API quota reported by the most recent response.
+// Returns nil if no requests have been made.
+func (s *Store) RateLimits() []*scuttlebutt.RateLimit {
+	rate := s.lastRate()
+	if rate.Limit == 0 {
+		return nil
+	}
+	return []*scuttlebutt.RateLimit{{
+		Resource:  ResourceCore,
+		Limit:     rate.Limit,
+		Remaining: rate.Remaining,
+		Reset:     rate.Reset.Time.UTC(),
+	}}
+}
+
+// ResourceCore is the rate limit resource for repository requests.
+const ResourceCore = "core"
+
+// Repository returns a repository by ID. Returns a *scuttlebutt.RateLimitError
+// without making a request if the API quota is exhausted.
+func (s *Store) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
 	// Parse repository ID.
 	segments := strings.Split(id, "/")
 	if len(segments) != 3 {
@@ -42,22 +119,142 @@ func (s *Store) Repository(id string) (*scuttlebutt.Repository, error) {
 	}
 	username, name := segments[1], segments[2]
 
+	// Wait until the quota resets once it is exhausted.
+	if err := s.rateLimitError(); err != nil {
+		return nil, err
+	}
+
 	// Retrieve repository data from GitHub.
-	repo, _, err := s.client.Repositories.Get(username, name)
+	repo, err := s.repository(ctx, username, name)
 	if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == http.StatusNotFound {
 		return nil, nil
+	} else if e := s.rateLimitError(); err != nil && e != nil {
+		return nil, e
 	} else if err != nil {
 		return nil, fmt.Errorf("get repository: %s", err)
 	}
 
-	// Create repository.
+	// Create repository. The full name is used for the canonical casing of
+	// the ID since names are matched case-insensitively.
 	r := &scuttlebutt.Repository{ID: id}
+	if repo.FullName != nil && strings.EqualFold(*repo.FullName, username+"/"+name) {
+		r.ID = segments[0] + "/" + *repo.FullName
+	}
+	if repo.Fork != nil {
+		r.Fork = *repo.Fork
+	}
+	if repo.Archived != nil {
+		r.Archived = *repo.Archived
+	}
+	if repo.Disabled != nil {
+		r.Disabled = *repo.Disabled
+	}
 	if repo.Language != nil {
 		r.Language = *repo.Language
 	}
 	if repo.Description != nil {
 		r.Description = *repo.Description
 	}
+	if repo.StargazersCount != nil {
+		r.Stars = *repo.StargazersCount
+	}
+	if repo.ForksCount != nil {
+		r.Forks = *repo.ForksCount
+	}
+	if repo.License != nil && repo.License.Key != nil {
+		r.License = *repo.License.Key
+	}
+
+	// Retrieve topics.
+	topics, err := s.topics(ctx, username, name)
+	if e := s.rateLimitError(); err != nil && e != nil {
+		return nil, e
+	} else if err != nil {
+		return nil, fmt.Errorf("get topics: %s", err)
+	}
+	r.Topics = topics
 
 	return r, nil
 }
+
+// rateLimitError returns an error if the last response exhausted the quota.
+func (s *Store) rateLimitError() error {
+	rate := s.lastRate()
+	if rate.Limit > 0 && rate.Remaining <= 0 && time.Now().Before(rate.Reset.Time) {
+		return &scuttlebutt.RateLimitError{Reset: rate.Reset.Time.UTC()}
+	}
+	return nil
+}
+
+// lastRate returns the quota reported by the most recent response.
+func (s *Store) lastRate() github.Rate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
+
+// newClient returns a client for a single request. The vendored client
+// records the quota on itself without locking so clients are not shared
+// between concurrent lookups.
+func (s *Store) newClient() *github.Client {
+	c := github.NewClient(s.httpClient)
+	if s.baseURL != nil {
+		c.BaseURL = s.baseURL
+	}
+	return c
+}
+
+// do sends a request and records the quota reported by the response.
+func (s *Store) do(c *github.Client, req *http.Request, v interface{}) error {
+	resp, err := c.Do(req, v)
+	if resp != nil && resp.Rate.Limit > 0 {
+		s.mu.Lock()
+		s.rate = resp.Rate
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// repository represents a repository with fields added to the API after
+// the vendored client was released.
+type repository struct {
+	github.Repository
+	Archived *bool `json:"archived"`
+	Disabled *bool `json:"disabled"`
+}
+
+// repository retrieves a repository's data from GitHub.
+func (s *Store) repository(ctx context.Context, username, name string) (*repository, error) {
+	c := s.newClient()
+	req, err := c.NewRequest("GET", "repos/"+username+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	var repo repository
+	if err := s.do(c, req, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// topics returns the topics for a repository. The vendored client predates
+// the topics API so the request is built manually.
+func (s *Store) topics(ctx context.Context, username, name string) ([]string, error) {
+	c := s.newClient()
+	req, err := c.NewRequest("GET", "repos/"+username+"/"+name+"/topics", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", TopicsMediaType)
+
+	var v struct {
+		Names []string `json:"names"`
+	}
+	if err := s.do(c, req, &v); err != nil {
+		return nil, err
+	}
+	return v.Names, nil
+}
@@ -0,0 +1,214 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// DefaultGraphQLURL is the GitHub GraphQL API endpoint.
+const DefaultGraphQLURL = "https://api.github.com/graphql"
+
+// MaxGraphQLBatchSize is the maximum number of repositories per request.
+const MaxGraphQLBatchSize = 100
+
+// GraphQLStore represents GitHub as a data store using the GraphQL API.
+// Multiple repositories are retrieved in a single request.
+type GraphQLStore struct {
+	token string
+
+	// GraphQL API endpoint. Defaults to DefaultGraphQLURL.
+	URL string
+
+	// Client used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewGraphQLStore returns a new instance of GraphQLStore. The token may be
+// blank if HTTPClient authenticates requests, such as with an AppTransport.
+func NewGraphQLStore(token string) *GraphQLStore {
+	return &GraphQLStore{
+		token:      token,
+		URL:        DefaultGraphQLURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Repository returns a repository by ID. Returns nil if it does not exist.
+func (s *GraphQLStore) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
+	m, err := s.Repositories(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return m[id], nil
+}
+
+// Repositories returns repositories by ID. Repositories are retrieved in
+// batches of up to MaxGraphQLBatchSize. Missing repositories are not
+// included in the returned map.
+func (s *GraphQLStore) Repositories(ctx context.Context, ids []string) (map[string]*scuttlebutt.Repository, error) {
+	m := make(map[string]*scuttlebutt.Repository, len(ids))
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > MaxGraphQLBatchSize {
+			n = MaxGraphQLBatchSize
+		}
+		if err := s.repositories(ctx, ids[:n], m); err != nil {
+			return nil, err
+		}
+		ids = ids[n:]
+	}
+	return m, nil
+}
+
+// repositories retrieves a single batch of repositories into m.
+func (s *GraphQLStore) repositories(ctx context.Context, ids []string, m map[string]*scuttlebutt.Repository) error {
+	// Build a query with an aliased field for each repository.
+	var buf bytes.Buffer
+	buf.WriteString("query {")
+	for i, id := range ids {
+		segments := strings.Split(id, "/")
+		if len(segments) != 3 {
+			return ErrInvalidRepositoryID
+		}
+		fmt.Fprintf(&buf, " r%d: repository(owner: %s, name: %s) { ...repo }", i, strconv.Quote(segments[1]), strconv.Quote(segments[2]))
+	}
+	buf.WriteString(" }\n" + graphQLRepositoryFragment)
+
+	body, err := json.Marshal(map[string]string{"query": buf.String()})
+	if err != nil {
+		return err
+	}
+
+	// Send request.
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("graphql: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Return a rate limit error if the quota is exhausted.
+	if err := graphQLRateLimitError(resp); err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("graphql: unexpected status: %d", resp.StatusCode)
+	}
+
+	// Parse the response.
+	var v struct {
+		Data   map[string]*graphQLRepository `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return fmt.Errorf("graphql: decode: %s", err)
+	}
+
+	// Missing repositories are returned as errors alongside the other data.
+	for _, e := range v.Errors {
+		if e.Type != "NOT_FOUND" {
+			return fmt.Errorf("graphql: %s", e.Message)
+		}
+	}
+
+	for i, id := range ids {
+		if repo := v.Data["r"+strconv.Itoa(i)]; repo != nil {
+			m[id] = repo.repository(id)
+		}
+	}
+	return nil
+}
+
+// graphQLRateLimitError returns an error if the response exhausted the quota.
+func graphQLRateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	} else if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	return &scuttlebutt.RateLimitError{Reset: time.Unix(reset, 0).UTC()}
+}
+
+// graphQLRepositoryFragment selects the fields used by graphQLRepository.
+const graphQLRepositoryFragment = `fragment repo on Repository {
+	nameWithOwner
+	description
+	primaryLanguage { name }
+	stargazerCount
+	forkCount
+	isFork
+	isArchived
+	isDisabled
+	licenseInfo { key }
+	repositoryTopics(first: 20) { nodes { topic { name } } }
+}`
+
+// graphQLRepository represents a repository returned by the GraphQL API.
+type graphQLRepository struct {
+	NameWithOwner   string `json:"nameWithOwner"`
+	Description     string `json:"description"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	StargazerCount   int  `json:"stargazerCount"`
+	ForkCount        int  `json:"forkCount"`
+	IsFork           bool `json:"isFork"`
+	IsArchived       bool `json:"isArchived"`
+	IsDisabled       bool `json:"isDisabled"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	LicenseInfo *struct {
+		Key string `json:"key"`
+	} `json:"licenseInfo"`
+}
+
+// repository converts the GraphQL representation to a repository.
+func (r *graphQLRepository) repository(id string) *scuttlebutt.Repository {
+	repo := &scuttlebutt.Repository{
+		ID:          id,
+		Description: r.Description,
+		Stars:       r.StargazerCount,
+		Forks:       r.ForkCount,
+		Fork:        r.IsFork,
+		Archived:    r.IsArchived,
+		Disabled:    r.IsDisabled,
+	}
+	if i := strings.Index(id, "/"); i >= 0 && strings.EqualFold(r.NameWithOwner, id[i+1:]) {
+		repo.ID = id[:i+1] + r.NameWithOwner
+	}
+	if r.PrimaryLanguage != nil {
+		repo.Language = r.PrimaryLanguage.Name
+	}
+	if r.LicenseInfo != nil {
+		repo.License = r.LicenseInfo.Key
+	}
+	for _, node := range r.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	return repo
+}
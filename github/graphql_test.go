@@ -0,0 +1,92 @@
+package github_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/github"
+)
+
+// Ensure repositories are retrieved in a single GraphQL request.
+func TestGraphQLStore_Repositories(t *testing.T) {
+	var requestN int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestN++
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		} else if r.Header.Get("Authorization") != "Bearer TOKEN" {
+			t.Fatalf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		} else if !strings.Contains(req.Query, `r0: repository(owner: "user", name: "repo")`) || !strings.Contains(req.Query, `r1: repository(owner: "user", name: "nope")`) {
+			t.Fatalf("unexpected query: %s", req.Query)
+		}
+
+		w.Write([]byte(`{
+			"data": {
+				"r0": {
+					"nameWithOwner": "User/Repo",
+					"description": "lorem",
+					"primaryLanguage": {"name": "Go"},
+					"stargazerCount": 10,
+					"forkCount": 2,
+					"isArchived": true,
+					"licenseInfo": {"key": "mit"},
+					"repositoryTopics": {"nodes": [{"topic": {"name": "cli"}}]}
+				},
+				"r1": null
+			},
+			"errors": [{"type": "NOT_FOUND", "path": ["r1"], "message": "not found"}]
+		}`))
+	}))
+	defer s.Close()
+
+	store := github.NewGraphQLStore("TOKEN")
+	store.URL = s.URL
+
+	m, err := store.Repositories(context.Background(), []string{"github.com/user/repo", "github.com/user/nope"})
+	if err != nil {
+		t.Fatal(err)
+	} else if requestN != 1 {
+		t.Fatalf("unexpected request count: %d", requestN)
+	} else if !reflect.DeepEqual(m, map[string]*scuttlebutt.Repository{
+		"github.com/user/repo": {
+			ID:          "github.com/User/Repo",
+			Description: "lorem",
+			Language:    "Go",
+			Stars:       10,
+			Forks:       2,
+			Topics:      []string{"cli"},
+			Archived:    true,
+			License:     "mit",
+		},
+	}) {
+		t.Fatalf("unexpected repositories: %#v", m)
+	}
+}
+
+// Ensure an exhausted quota returns a rate limit error.
+func TestGraphQLStore_Repositories_RateLimited(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "946684800")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	store := github.NewGraphQLStore("TOKEN")
+	store.URL = s.URL
+
+	if _, err := store.Repository(context.Background(), "github.com/user/repo"); err == nil {
+		t.Fatal("expected error")
+	} else if e, ok := err.(*scuttlebutt.RateLimitError); !ok || e.Reset.Unix() != 946684800 {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+const (
+	// DefaultPreviewBaseURL is the base URL for repository pages.
+	DefaultPreviewBaseURL = "https://github.com"
+
+	// MaxPreviewSize is the largest preview image that will be downloaded.
+	MaxPreviewSize = 5 << 20
+)
+
+// ErrPreviewTooLarge is returned when a preview image exceeds MaxPreviewSize.
+var ErrPreviewTooLarge = errors.New("preview image too large")
+
+// PreviewSource retrieves a repository's social preview image. If the owner
+// has not uploaded a custom image then GitHub renders a card with the
+// repository's name, description & language.
+type PreviewSource struct {
+	// Base URL of repository pages.
+	BaseURL string
+
+	HTTPClient *http.Client
+}
+
+// NewPreviewSource returns a new instance of PreviewSource.
+func NewPreviewSource() *PreviewSource {
+	return &PreviewSource{
+		BaseURL:    DefaultPreviewBaseURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// ogImageRegex matches the Open Graph image tag in a repository page.
+var ogImageRegex = regexp.MustCompile(`<meta[^>]+property="og:image"[^>]+content="([^"]+)"`)
+
+// Image returns the social preview image for a repository.
+// Returns nil if the page has no preview image.
+func (s *PreviewSource) Image(r *scuttlebutt.Repository) ([]byte, error) {
+	// Parse repository ID.
+	segments := strings.Split(r.ID, "/")
+	if len(segments) != 3 {
+		return nil, ErrInvalidRepositoryID
+	}
+
+	// Find the Open Graph image in the repository page.
+	page, _, err := s.get(s.BaseURL + "/" + segments[1] + "/" + segments[2])
+	if err != nil {
+		return nil, fmt.Errorf("page: %s", err)
+	}
+	m := ogImageRegex.FindSubmatch(page)
+	if m == nil {
+		return nil, nil
+	}
+
+	// Download the image.
+	data, contentType, err := s.get(html.UnescapeString(string(m[1])))
+	if err != nil {
+		return nil, fmt.Errorf("image: %s", err)
+	} else if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("unexpected content type: %s", contentType)
+	}
+	return data, nil
+}
+
+// get returns the body & content type of a URL.
+func (s *PreviewSource) get(u string) ([]byte, string, error) {
+	resp, err := s.HTTPClient.Get(u)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxPreviewSize+1))
+	if err != nil {
+		return nil, "", err
+	} else if len(data) > MaxPreviewSize {
+		return nil, "", ErrPreviewTooLarge
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
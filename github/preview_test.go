@@ -0,0 +1,53 @@
+package github_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/github"
+)
+
+// Ensure the preview source downloads the Open Graph image for a repository.
+func TestPreviewSource_Image(t *testing.T) {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user/repo":
+			w.Write([]byte(`<html><head><meta property="og:image" content="` + s.URL + `/card.png?a=1&amp;b=2" /></head></html>`))
+		case "/card.png":
+			if r.URL.RawQuery != "a=1&b=2" {
+				t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("PNG"))
+		case "/user/plain":
+			w.Write([]byte(`<html></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	src := github.NewPreviewSource()
+	src.BaseURL = s.URL
+
+	if data, err := src.Image(&scuttlebutt.Repository{ID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if string(data) != "PNG" {
+		t.Fatalf("unexpected image: %q", data)
+	}
+
+	// Pages without an image return no data.
+	if data, err := src.Image(&scuttlebutt.Repository{ID: "github.com/user/plain"}); err != nil {
+		t.Fatal(err)
+	} else if data != nil {
+		t.Fatalf("unexpected image: %q", data)
+	}
+
+	// Missing repositories return an error.
+	if _, err := src.Image(&scuttlebutt.Repository{ID: "github.com/user/nope"}); err == nil || err.Error() != "page: unexpected status: 404" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// ErrInvalidRepositoryID is returned when the repository ID does not conform
+// to a 3-segment host/namespace/project path.
+var ErrInvalidRepositoryID = errors.New("invalid repository id")
+
+// DefaultURL is the base URL of GitLab.com.
+const DefaultURL = "https://gitlab.com"
+
+// Store represents a GitLab instance as a data store.
+type Store struct {
+	token string
+
+	// Base URL of the GitLab instance, such as "https://gitlab.com".
+	URL string
+
+	// Client used for requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewStore returns a new instance of Store for a GitLab instance.
+// Public projects can be retrieved without a token.
+func NewStore(baseURL, token string) *Store {
+	return &Store{
+		token:      token,
+		URL:        strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Repository returns a project by ID. Returns nil if it does not exist.
+func (s *Store) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
+	// Parse repository ID.
+	segments := strings.Split(id, "/")
+	if len(segments) != 3 {
+		return nil, ErrInvalidRepositoryID
+	}
+	path := url.PathEscape(segments[1] + "/" + segments[2])
+
+	// Retrieve project data.
+	var project struct {
+		Description       string          `json:"description"`
+		StarCount         int             `json:"star_count"`
+		ForksCount        int             `json:"forks_count"`
+		Topics            []string        `json:"topics"`
+		TagList           []string        `json:"tag_list"`
+		Archived          bool            `json:"archived"`
+		ForkedFromProject json.RawMessage `json:"forked_from_project"`
+		License           *struct {
+			Key string `json:"key"`
+		} `json:"license"`
+	}
+	if ok, err := s.get(ctx, "projects/"+path+"?license=true", &project); err != nil {
+		return nil, fmt.Errorf("get project: %s", err)
+	} else if !ok {
+		return nil, nil
+	}
+
+	r := &scuttlebutt.Repository{
+		ID:          id,
+		Description: project.Description,
+		Stars:       project.StarCount,
+		Forks:       project.ForksCount,
+		Topics:      project.Topics,
+		Archived:    project.Archived,
+		Fork:        len(project.ForkedFromProject) > 0 && string(project.ForkedFromProject) != "null",
+	}
+	if r.Topics == nil {
+		r.Topics = project.TagList
+	}
+	if project.License != nil {
+		r.License = project.License.Key
+	}
+
+	// Use the language with the highest percentage of the project.
+	var languages map[string]float64
+	if _, err := s.get(ctx, "projects/"+path+"/languages", &languages); err != nil {
+		return nil, fmt.Errorf("get languages: %s", err)
+	}
+	var max float64
+	for name, pct := range languages {
+		if pct > max || (pct == max && name < r.Language) {
+			r.Language, max = name, pct
+		}
+	}
+
+	return r, nil
+}
+
+// get retrieves an API path and decodes the response into v.
+// Returns false if the resource does not exist.
+func (s *Store) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", s.URL+"/api/v4/"+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	if s.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", s.token)
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package gitlab_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/gitlab"
+)
+
+// Ensure a project can be retrieved from a GitLab instance.
+func TestStore_Repository(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "TOKEN" {
+			t.Fatalf("unexpected token: %s", r.Header.Get("PRIVATE-TOKEN"))
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/user%2Fproj":
+			w.Write([]byte(`{"description":"lorem","star_count":10,"forks_count":2,"topics":["cli"],"archived":true,"forked_from_project":{"id":1},"license":{"key":"mit","name":"MIT License"}}`))
+		case "/api/v4/projects/user%2Fproj/languages":
+			w.Write([]byte(`{"Shell":10.5,"Go":89.5}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	store := gitlab.NewStore(s.URL+"/", "TOKEN")
+	if r, err := store.Repository(context.Background(), "gitlab.com/user/proj"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r, &scuttlebutt.Repository{
+		ID:          "gitlab.com/user/proj",
+		Description: "lorem",
+		Language:    "Go",
+		Stars:       10,
+		Forks:       2,
+		Topics:      []string{"cli"},
+		License:     "mit",
+		Fork:        true,
+		Archived:    true,
+	}) {
+		t.Fatalf("unexpected repository: %#v", r)
+	}
+
+	// Missing projects return nil.
+	if r, err := store.Repository(context.Background(), "gitlab.com/user/nope"); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Fatalf("unexpected repository: %#v", r)
+	}
+}
@@ -0,0 +1,288 @@
+package scuttlebutt
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt/internal"
+	"github.com/gogo/protobuf/proto"
+)
+
+// MaxGRPCMessageSize is the maximum size of a gRPC request message. Pages
+// of repositories are also kept within it since it is the default limit of
+// most gRPC clients.
+const MaxGRPCMessageSize = 4 << 20
+
+// DefaultGRPCRepositoriesN is the number of repositories returned by the
+// Repositories call if no limit is requested.
+const DefaultGRPCRepositoriesN = 100
+
+// gRPC status codes returned by the server.
+const (
+	grpcOK               = 0
+	grpcInvalidArgument  = 3
+	grpcNotFound         = 5
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnauthenticated  = 16
+)
+
+// grpcError is an error with a gRPC status code.
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string { return e.message }
+
+// GRPCServer serves the Scuttlebutt service defined in internal.proto. Only
+// unary calls with uncompressed messages are supported. Requests must use
+// HTTP/2, such as through Daemon.GRPCAddr.
+type GRPCServer struct {
+	Store *Store
+
+	// If true, calls that write to the store are rejected.
+	ReadOnly bool
+
+	// Tokens that calls writing to the store must authenticate with as a
+	// bearer token in the "authorization" metadata. Either token is accepted.
+	// Writes are rejected if neither is set.
+	IngestToken string
+	AdminToken  string
+}
+
+// NewGRPCServer returns a new instance of GRPCServer for s.
+func NewGRPCServer(s *Store) *GRPCServer {
+	return &GRPCServer{Store: s}
+}
+
+// ServeHTTP decodes the request message, calls the method, and writes the
+// response message followed by the status in the trailers.
+func (s *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	resp, err := s.call(r.Context(), r.URL.Path, token, r.Body)
+	if err == nil {
+		err = writeGRPCMessage(w, resp)
+	}
+
+	code, message := grpcOK, ""
+	if err, ok := err.(*grpcError); ok {
+		code, message = err.code, err.message
+	} else if err != nil {
+		code, message = grpcInternal, err.Error()
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+}
+
+// call decodes the request message from r and executes the method at path.
+// The token is the bearer token sent in the request metadata, if any.
+func (s *GRPCServer) call(ctx context.Context, path, token string, r io.Reader) (proto.Message, error) {
+	switch path {
+	case "/internal.Scuttlebutt/Repositories":
+		var req internal.RepositoriesRequest
+		if err := readGRPCMessage(r, &req); err != nil {
+			return nil, err
+		}
+		return s.repositories(&req)
+
+	case "/internal.Scuttlebutt/TopRepositories":
+		var req internal.TopRepositoriesRequest
+		if err := readGRPCMessage(r, &req); err != nil {
+			return nil, err
+		}
+		return s.topRepositories()
+
+	case "/internal.Scuttlebutt/AddMessage":
+		var req internal.AddMessageRequest
+		if err := readGRPCMessage(r, &req); err != nil {
+			return nil, err
+		}
+		return s.addMessage(ctx, token, &req)
+
+	case "/internal.Scuttlebutt/Blacklist":
+		var req internal.BlacklistRequest
+		if err := readGRPCMessage(r, &req); err != nil {
+			return nil, err
+		}
+		a, err := s.Store.Blacklist()
+		if err != nil {
+			return nil, err
+		}
+		return &internal.BlacklistResponse{Patterns: a}, nil
+
+	case "/internal.Scuttlebutt/NotificationHistory":
+		var req internal.NotificationHistoryRequest
+		if err := readGRPCMessage(r, &req); err != nil {
+			return nil, err
+		}
+		return s.notificationHistory(int(req.GetN()))
+
+	default:
+		return nil, &grpcError{code: grpcUnimplemented, message: "unknown method: " + path}
+	}
+}
+
+// repositories returns a page of repositories, with their messages, in ID
+// order after the requested ID. Clients request the next page with the ID of
+// the last repository returned until an empty page is returned. Pages are
+// cut short to stay within MaxGRPCMessageSize.
+func (s *GRPCServer) repositories(req *internal.RepositoriesRequest) (*internal.RepositoriesResponse, error) {
+	limit := int(req.GetLimit())
+	if limit < 0 || limit > MaxRepositoriesPageN {
+		return nil, &grpcError{code: grpcInvalidArgument, message: "invalid limit"}
+	} else if limit == 0 {
+		limit = DefaultGRPCRepositoriesN
+	}
+
+	a, err := s.Store.RepositoriesPage(req.GetAfter(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count each repository's size plus its field header. At least one
+	// repository is returned so that clients can make progress.
+	var resp internal.RepositoriesResponse
+	var size int
+	for _, r := range a {
+		pb := encodeRepository(r)
+		if size += proto.Size(pb) + 8; size > MaxGRPCMessageSize && len(resp.Repositories) > 0 {
+			break
+		}
+		resp.Repositories = append(resp.Repositories, pb)
+	}
+	return &resp, nil
+}
+
+// topRepositories returns the top repository for each language & topic,
+// sorted by key.
+func (s *GRPCServer) topRepositories() (*internal.TopRepositoriesResponse, error) {
+	m, err := s.Store.TopRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var resp internal.TopRepositoriesResponse
+	for _, k := range keys {
+		resp.Repositories = append(resp.Repositories, &internal.TopRepository{
+			Key:        proto.String(k),
+			Repository: encodeRepository(m[k]),
+		})
+	}
+	return &resp, nil
+}
+
+// addMessage adds a message to its repository.
+func (s *GRPCServer) addMessage(ctx context.Context, token string, req *internal.AddMessageRequest) (*internal.AddMessageResponse, error) {
+	if !s.authorizeWrite(token) {
+		return nil, &grpcError{code: grpcUnauthenticated, message: "unauthenticated"}
+	} else if s.ReadOnly {
+		return nil, &grpcError{code: grpcPermissionDenied, message: "read only"}
+	}
+
+	m := decodeMessage(req.GetMessage())
+	m.RepositoryID = req.GetRepositoryID()
+	if err := s.Store.AddMessage(ctx, m); err == ErrRepositoryNotFound {
+		return nil, &grpcError{code: grpcNotFound, message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	return &internal.AddMessageResponse{}, nil
+}
+
+// authorizeWrite returns true if token matches the ingest or admin token.
+func (s *GRPCServer) authorizeWrite(token string) bool {
+	for _, v := range []string{s.IngestToken, s.AdminToken} {
+		if v != "" && subtle.ConstantTimeCompare([]byte(token), []byte(v)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationHistory returns up to n of the most recent notification
+// attempts, newest first.
+func (s *GRPCServer) notificationHistory(n int) (*internal.NotificationHistoryResponse, error) {
+	if n <= 0 {
+		n = DefaultNotificationN
+	} else if n > MaxNotificationN {
+		n = MaxNotificationN
+	}
+
+	a, err := s.Store.Notifications(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp internal.NotificationHistoryResponse
+	for _, n := range a {
+		resp.Notifications = append(resp.Notifications, encodeNotification(n))
+	}
+	return &resp, nil
+}
+
+// readGRPCMessage reads a length-prefixed message from r into pb.
+func readGRPCMessage(r io.Reader, pb proto.Message) error {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return &grpcError{code: grpcInvalidArgument, message: "read message header: " + err.Error()}
+	} else if hdr[0] != 0 {
+		return &grpcError{code: grpcUnimplemented, message: "compressed messages not supported"}
+	}
+
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > MaxGRPCMessageSize {
+		return &grpcError{code: grpcInvalidArgument, message: "message too large"}
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return &grpcError{code: grpcInvalidArgument, message: "read message: " + err.Error()}
+	} else if len(buf) != int(n) {
+		return &grpcError{code: grpcInvalidArgument, message: "short message"}
+	}
+
+	if err := proto.Unmarshal(buf, pb); err != nil {
+		return &grpcError{code: grpcInvalidArgument, message: "decode message: " + err.Error()}
+	}
+	return nil
+}
+
+// writeGRPCMessage writes pb to w with a length prefix.
+func writeGRPCMessage(w io.Writer, pb proto.Message) error {
+	buf, err := proto.Marshal(pb)
+	if err != nil {
+		return err
+	}
+
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(buf)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
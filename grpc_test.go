@@ -0,0 +1,203 @@
+package scuttlebutt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/internal"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Ensure a message can be added and the top repositories retrieved.
+func TestGRPCServer_AddMessage(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	srv := scuttlebutt.NewGRPCServer(s.Store)
+	srv.IngestToken = "secret"
+
+	if status := GRPCCallWithToken(srv, "secret", "AddMessage", &internal.AddMessageRequest{
+		RepositoryID: proto.String("github.com/user/repo"),
+		Message:      &internal.Message{ID: proto.Uint64(1), Text: proto.String("foo")},
+	}, &internal.AddMessageResponse{}); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+
+	var resp internal.TopRepositoriesResponse
+	if status := GRPCCall(srv, "TopRepositories", &internal.TopRepositoriesRequest{}, &resp); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if len(resp.Repositories) != 1 {
+		t.Fatalf("unexpected repository count: %d", len(resp.Repositories))
+	} else if r := resp.Repositories[0]; r.GetKey() != "Go" || r.GetRepository().GetID() != "github.com/user/repo" || len(r.GetRepository().GetMessages()) != 1 {
+		t.Fatalf("unexpected repository: %s", r)
+	}
+}
+
+// Ensure writes are rejected while read-only.
+func TestGRPCServer_AddMessage_ReadOnly(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	srv := scuttlebutt.NewGRPCServer(s.Store)
+	srv.AdminToken = "admin"
+	srv.ReadOnly = true
+
+	if status := GRPCCallWithToken(srv, "admin", "AddMessage", &internal.AddMessageRequest{
+		RepositoryID: proto.String("github.com/user/repo"),
+		Message:      &internal.Message{ID: proto.Uint64(1), Text: proto.String("foo")},
+	}, &internal.AddMessageResponse{}); status != "7" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+}
+
+// Ensure writes require the ingest or admin token.
+func TestGRPCServer_AddMessage_Unauthenticated(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	srv := scuttlebutt.NewGRPCServer(s.Store)
+	req := &internal.AddMessageRequest{
+		RepositoryID: proto.String("github.com/user/repo"),
+		Message:      &internal.Message{ID: proto.Uint64(1), Text: proto.String("foo")},
+	}
+
+	// Writes are rejected if no token is configured.
+	if status := GRPCCall(srv, "AddMessage", req, &internal.AddMessageResponse{}); status != "16" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+
+	srv.IngestToken, srv.AdminToken = "secret", "admin"
+	if status := GRPCCall(srv, "AddMessage", req, &internal.AddMessageResponse{}); status != "16" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if status := GRPCCallWithToken(srv, "nope", "AddMessage", req, &internal.AddMessageResponse{}); status != "16" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if n, err := s.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected repository count: %d", n)
+	} else if status := GRPCCallWithToken(srv, "admin", "AddMessage", req, &internal.AddMessageResponse{}); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+}
+
+// Ensure repositories are returned in pages.
+func TestGRPCServer_Repositories(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	for i, id := range []string{"github.com/user/a", "github.com/user/b", "github.com/user/c"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := scuttlebutt.NewGRPCServer(s.Store)
+
+	// Read pages of two repositories until an empty page is returned.
+	var ids []string
+	var after string
+	for i := 0; ; i++ {
+		var resp internal.RepositoriesResponse
+		if status := GRPCCall(srv, "Repositories", &internal.RepositoriesRequest{Limit: proto.Int64(2), After: proto.String(after)}, &resp); status != "0" {
+			t.Fatalf("unexpected status: %s", status)
+		} else if len(resp.Repositories) == 0 {
+			break
+		} else if i > 2 {
+			t.Fatal("too many pages")
+		}
+		for _, r := range resp.Repositories {
+			if len(r.GetMessages()) != 1 {
+				t.Fatalf("unexpected repository: %s", r)
+			}
+			ids = append(ids, r.GetID())
+		}
+		after = ids[len(ids)-1]
+	}
+	if !reflect.DeepEqual(ids, []string{"github.com/user/a", "github.com/user/b", "github.com/user/c"}) {
+		t.Fatalf("unexpected repositories: %v", ids)
+	}
+
+	// Limits over the maximum are rejected.
+	if status := GRPCCall(srv, "Repositories", &internal.RepositoriesRequest{Limit: proto.Int64(scuttlebutt.MaxRepositoriesPageN + 1)}, nil); status != "3" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+}
+
+// Ensure the blacklist & notification history can be retrieved.
+func TestGRPCServer_Blacklist(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	if err := s.AddBlacklist("github.com/spam/*"); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddNotification(&scuttlebutt.Notification{Username: "gobot", RepositoryID: "github.com/user/repo", Success: true}); err != nil {
+		t.Fatal(err)
+	}
+	srv := scuttlebutt.NewGRPCServer(s.Store)
+
+	var resp internal.BlacklistResponse
+	if status := GRPCCall(srv, "Blacklist", &internal.BlacklistRequest{}, &resp); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if !reflect.DeepEqual(resp.Patterns, []string{"github.com/spam/*"}) {
+		t.Fatalf("unexpected patterns: %v", resp.Patterns)
+	}
+
+	var history internal.NotificationHistoryResponse
+	if status := GRPCCall(srv, "NotificationHistory", &internal.NotificationHistoryRequest{}, &history); status != "0" {
+		t.Fatalf("unexpected status: %s", status)
+	} else if len(history.Notifications) != 1 || history.Notifications[0].GetUsername() != "gobot" {
+		t.Fatalf("unexpected notifications: %v", history.Notifications)
+	}
+}
+
+// Ensure unknown methods return an unimplemented status.
+func TestGRPCServer_UnknownMethod(t *testing.T) {
+	srv := scuttlebutt.NewGRPCServer(nil)
+	if status := GRPCCall(srv, "Nope", &internal.BlacklistRequest{}, nil); status != "12" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+}
+
+// GRPCCall sends a unary call to h and decodes the response message into
+// resp. Returns the gRPC status code.
+func GRPCCall(h http.Handler, method string, req, resp proto.Message) string {
+	return GRPCCallWithToken(h, "", method, req, resp)
+}
+
+// GRPCCallWithToken sends a unary call to h authenticated with a bearer
+// token, if set. Returns the gRPC status code.
+func GRPCCallWithToken(h http.Handler, token, method string, req, resp proto.Message) string {
+	buf, err := proto.Marshal(req)
+	if err != nil {
+		panic(err)
+	}
+	body := make([]byte, 5, 5+len(buf))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(buf)))
+	body = append(body, buf...)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/internal.Scuttlebutt/"+method, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/grpc")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	h.ServeHTTP(w, r)
+
+	result := w.Result()
+	if b, _ := ioutil.ReadAll(result.Body); len(b) >= 5 && resp != nil {
+		if err := proto.Unmarshal(b[5:], resp); err != nil {
+			panic(err)
+		}
+	}
+	return result.Trailer.Get("Grpc-Status")
+}
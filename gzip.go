@@ -0,0 +1,133 @@
+package scuttlebutt
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GzipHandler wraps a handler and gzip encodes responses for clients that
+// advertise support with the Accept-Encoding header.
+type GzipHandler struct {
+	pool sync.Pool
+
+	Handler http.Handler
+
+	// Compression level. Defaults to gzip.DefaultCompression.
+	Level int
+}
+
+// NewGzipHandler returns a new instance of GzipHandler.
+func NewGzipHandler(h http.Handler) *GzipHandler {
+	return &GzipHandler{Handler: h, Level: gzip.DefaultCompression}
+}
+
+func (h *GzipHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Profiles are already compressed and HEAD requests have no body.
+	if r.Method == "HEAD" || strings.HasPrefix(r.URL.Path, "/debug/pprof") || !AcceptsGzip(r) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	gw := &gzipResponseWriter{ResponseWriter: w, handler: h}
+	defer gw.Close()
+	h.Handler.ServeHTTP(gw, r)
+}
+
+// writer returns a gzip writer from the pool that writes to w.
+func (h *GzipHandler) writer(w http.ResponseWriter) *gzip.Writer {
+	if zw, ok := h.pool.Get().(*gzip.Writer); ok {
+		zw.Reset(w)
+		return zw
+	}
+	zw, err := gzip.NewWriterLevel(w, h.Level)
+	if err != nil {
+		zw = gzip.NewWriter(w)
+	}
+	return zw
+}
+
+// AcceptsGzip returns true if the request's Accept-Encoding header allows a
+// gzip encoded response.
+func AcceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params := v, ""
+		if i := strings.Index(v, ";"); i >= 0 {
+			coding, params = v[:i], v[i+1:]
+		}
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		// A zero quality value rejects the encoding.
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if q, err := strconv.ParseFloat(params[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the response body unless the response has no
+// body or is already encoded.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	handler     *GzipHandler
+	zw          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	hdr := w.Header()
+	if status != http.StatusNoContent && status != http.StatusNotModified && hdr.Get("Content-Encoding") == "" {
+		hdr.Set("Content-Encoding", "gzip")
+		hdr.Del("Content-Length")
+		w.zw = w.handler.writer(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.zw == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.zw.Write(p)
+}
+
+// Flush sends compressed data to the client, if supported by the underlying writer.
+func (w *gzipResponseWriter) Flush() {
+	if w.zw != nil {
+		w.zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close completes the gzip stream and returns the writer to the pool.
+func (w *gzipResponseWriter) Close() error {
+	if w.zw == nil {
+		return nil
+	}
+	err := w.zw.Close()
+	w.handler.pool.Put(w.zw)
+	w.zw = nil
+	return err
+}
@@ -0,0 +1,59 @@
+package scuttlebutt_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure responses are compressed when the client accepts gzip.
+func TestGzipHandler_ServeHTTP(t *testing.T) {
+	h := scuttlebutt.NewGzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("id,language\ngithub.com/user/repo,go\n"))
+	}))
+
+	r := httptest.NewRequest("GET", "/repositories", nil)
+	r.Header.Set("Accept-Encoding", "deflate, gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if v := w.Header().Get("Content-Encoding"); v != "gzip" {
+		t.Fatalf("unexpected encoding: %q", v)
+	} else if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+		t.Fatalf("unexpected vary: %q", v)
+	}
+
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	} else if buf, err := ioutil.ReadAll(zr); err != nil {
+		t.Fatal(err)
+	} else if string(buf) != "id,language\ngithub.com/user/repo,go\n" {
+		t.Fatalf("unexpected body: %q", buf)
+	}
+}
+
+// Ensure responses are not compressed when the client does not accept gzip.
+func TestGzipHandler_ServeHTTP_Identity(t *testing.T) {
+	h := scuttlebutt.NewGzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	for _, enc := range []string{"", "identity", "gzip;q=0"} {
+		r := httptest.NewRequest("GET", "/top", nil)
+		r.Header.Set("Accept-Encoding", enc)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if v := w.Header().Get("Content-Encoding"); v != "" {
+			t.Fatalf("%q: unexpected encoding: %q", enc, v)
+		} else if w.Body.String() != "ok" {
+			t.Fatalf("%q: unexpected body: %q", enc, w.Body.String())
+		}
+	}
+}
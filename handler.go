@@ -2,24 +2,127 @@ package scuttlebutt
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/csv"
 	"encoding/json"
 	"expvar"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/benbjohnson/scuttlebutt/events"
+)
+
+const (
+	// DefaultTopOverallN is the default number of overall top repositories returned.
+	DefaultTopOverallN = 25
+
+	// MaxTopOverallN is the maximum number of overall top repositories returned.
+	MaxTopOverallN = 100
+
+	// DefaultRecentWindow is the default time over which mentions are
+	// counted for the recent top repositories.
+	DefaultRecentWindow = 24 * time.Hour
+
+	// DefaultRepositoryMessageN is the default number of messages returned
+	// with a repository.
+	DefaultRepositoryMessageN = 20
+
+	// MaxRepositoryMessageN is the maximum number of messages returned with
+	// a repository.
+	MaxRepositoryMessageN = 1000
+
+	// DefaultNotificationN is the default number of audit log entries returned.
+	DefaultNotificationN = 100
+
+	// MaxNotificationN is the maximum number of audit log entries returned.
+	MaxNotificationN = 10000
+
+	// DefaultHistoryDays is the default number of daily snapshots returned.
+	DefaultHistoryDays = 30
+
+	// MaxHistoryDays is the maximum number of daily snapshots returned.
+	MaxHistoryDays = 366
+
+	// DefaultTimeSeriesDays is the default number of days in a repository's
+	// mention time series.
+	DefaultTimeSeriesDays = 30
+
+	// MaxTimeSeriesDays is the maximum number of days in a repository's
+	// mention time series.
+	MaxTimeSeriesDays = 366
+
+	// DefaultSearchN is the default number of search results returned.
+	DefaultSearchN = 20
+
+	// MaxSearchN is the maximum number of search results returned.
+	MaxSearchN = 100
+
+	// MaxRepositoriesPageN is the maximum number of repositories returned
+	// in a page of the repository listing.
+	MaxRepositoriesPageN = 10000
+
+	// MaxIngestN is the maximum number of messages pushed in one request.
+	MaxIngestN = 1000
+
+	// MaxIngestBodySize is the maximum size of an ingestion request body.
+	MaxIngestBodySize = 4 << 20
 )
 
 // Handler represents an HTTP interface to the store.
 type Handler struct {
 	Store *Store
+
+	// Returns ingestion diagnostics, if available.
+	PollerStatus func() *PollerStatus
+
+	// Returns the health of each message source, if available.
+	SourceStatus func() []*SourceStatus
+
+	// Returns notification diagnostics, if available.
+	NotifierStatus func() *NotifierStatus
+
+	// Returns the outcome of each account's most recent pick, if available.
+	Explain func() []*Explanation
+
+	// If true, requests that modify the store are rejected.
+	ReadOnly bool
+
+	// Immediately sends a repository from an account, if available.
+	ForceNotify func(ctx context.Context, username, repositoryID string) (*Message, error)
+
+	// Token that requests to the admin endpoints must authenticate with as
+	// a bearer token. The admin endpoints are disabled if unset.
+	AdminToken string
+
+	// Saves messages pushed to the ingestion endpoint. Requests must
+	// authenticate with IngestToken as a bearer token. The endpoint is
+	// disabled if either is unset.
+	Ingest      func(ctx context.Context, source string, a []*Message) (int, error)
+	IngestToken string
+
+	// Converts repository URLs of pushed messages to IDs. Returns a blank ID
+	// if the URL does not link to a repository. Uses ExtractRepositoryID if
+	// not set.
+	IngestRepositoryID func(rawurl string) string
+
+	// Bus that newly saved messages are streamed from. The message
+	// stream is disabled if nil.
+	Events *events.Bus
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.ReadOnly && r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "read only", http.StatusForbidden)
+		return
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/debug/pprof") {
 		switch r.URL.Path {
 		case "/debug/pprof/cmdline":
@@ -34,6 +137,39 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/admin/") && !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/v1/repositories/") {
+		h.serveRepository(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/repositories/") {
+		if r.Method == "DELETE" {
+			h.serveTakedown(w, r)
+			return
+		}
+		h.serveRepositoryTimeSeries(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/pending/") {
+		h.servePendingAction(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/flagged/") {
+		h.serveFlaggedAction(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/authors/") {
+		h.serveAuthorMessages(w, r)
+		return
+	}
+
 	switch r.URL.Path {
 	case "/":
 		h.serveRoot(w, r)
@@ -43,12 +179,64 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.serveTop(w, r)
 	case "/top/stats":
 		h.serveTopStats(w, r)
+	case "/api/v1/top":
+		h.serveTopJSON(w, r)
+	case "/api/v1/top/overall":
+		h.serveTopOverall(w, r)
+	case "/api/v1/top/recent":
+		h.serveTopRecent(w, r)
+	case "/openapi.json":
+		h.serveOpenAPI(w, r)
 	case "/repositories":
 		h.serveRepositories(w, r)
+	case "/pending":
+		h.servePending(w, r)
+	case "/flagged":
+		h.serveFlagged(w, r)
+	case "/opt_outs":
+		h.serveOptOuts(w, r)
+	case "/languages":
+		h.serveLanguages(w, r)
+	case "/search":
+		h.serveSearch(w, r)
+	case "/history":
+		h.serveHistory(w, r)
+	case "/notifications":
+		h.serveNotifications(w, r)
+	case "/notifications/templates":
+		h.serveTemplateStats(w, r)
+	case "/api/v1/short_urls":
+		h.serveShortURLs(w, r)
 	case "/backup":
 		h.serveBackup(w, r)
 	case "/debug/vars":
 		h.serveExpvars(w, r)
+	case "/debug/poller":
+		h.servePollerStatus(w, r)
+	case "/sources":
+		h.serveSources(w, r)
+	case "/notifier":
+		h.serveNotifier(w, r)
+	case "/debug/notifier":
+		h.serveNotifierStatus(w, r)
+	case "/explain":
+		h.serveExplain(w, r)
+	case "/ingest":
+		h.serveIngest(w, r)
+	case "/messages/stream":
+		h.serveMessageStream(w, r)
+	case "/admin/repositories":
+		h.serveAdminRepositories(w, r)
+	case "/admin/boost":
+		h.serveAdminBoost(w, r)
+	case "/admin/blacklist":
+		h.serveAdminBlacklist(w, r)
+	case "/admin/labels":
+		h.serveAdminLabels(w, r)
+	case "/admin/notify":
+		h.serveAdminNotify(w, r)
+	case "/admin/reset_notified":
+		h.serveAdminResetNotified(w, r)
 	default:
 		http.NotFound(w, r)
 	}
@@ -58,7 +246,19 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) serveRoot(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, `<h1>scuttlebutt</h1>`)
 	fmt.Fprintln(w, `<p><a href="/top">Top Repositories by Language</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/api/v1/top/overall">Top Repositories Overall</a></p>`)
 	fmt.Fprintln(w, `<p><a href="/repositories">All Repositories</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/notifier">Notifier Status</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/sources">Source Health</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/pending">Pending Notifications</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/flagged">Flagged Repositories</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/opt_outs">Opt-Outs</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/notifications">Notification Log</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/history">Ranking History</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/languages">Languages</a></p>`)
+	fmt.Fprintln(w, `<form action="/search"><input name="q" placeholder="Search"> <button>Search</button></form>`)
+	fmt.Fprintln(w, `<p><a href="/explain">Explain Recent Picks</a></p>`)
+	fmt.Fprintln(w, `<p><a href="/openapi.json">API Specification</a></p>`)
 }
 
 // servePing verifies that the server is working correctly.
@@ -72,8 +272,32 @@ func (h *Handler) servePing(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "ok")
 }
 
+// notModified sets the ETag header from the store's version and returns true
+// if the client's If-None-Match header matches it. A 304 response is written
+// when it matches. Errors are ignored so the response is served in full.
+func (h *Handler) notModified(w http.ResponseWriter, r *http.Request) bool {
+	version, err := h.Store.Version()
+	if err != nil {
+		return false
+	}
+	etag := `"` + version + `"`
+	w.Header().Set("ETag", etag)
+
+	for _, v := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if v = strings.TrimPrefix(strings.TrimSpace(v), "W/"); v == etag || v == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
 // serveTop prints a list of the top repository for each language.
 func (h *Handler) serveTop(w http.ResponseWriter, r *http.Request) {
+	if h.notModified(w, r) {
+		return
+	}
+
 	// Retrieve the top repositories.
 	m, err := h.Store.TopRepositories()
 	if err != nil {
@@ -93,10 +317,246 @@ func (h *Handler) serveTop(w http.ResponseWriter, r *http.Request) {
 	// Print results.
 	for _, k := range keys {
 		r := m[k]
+		if len(r.Labels) > 0 {
+			fmt.Fprintf(w, "%s: %s [%s] - %s\n", k, r.Name(), strings.Join(r.Labels, ", "), r.Description)
+			continue
+		}
 		fmt.Fprintf(w, "%s: %s - %s\n", k, r.Name(), r.Description)
 	}
 }
 
+// serveTopJSON writes the top repository for each language & topic as JSON,
+// sorted by key.
+func (h *Handler) serveTopJSON(w http.ResponseWriter, r *http.Request) {
+	if h.notModified(w, r) {
+		return
+	}
+
+	// Retrieve the top repositories.
+	m, err := h.Store.TopRepositories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Sort keys.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Convert to JSON representation.
+	output := make([]*topRepositoryJSON, len(keys))
+	for i, k := range keys {
+		r := m[k]
+		output[i] = &topRepositoryJSON{
+			Key:         k,
+			ID:          r.ID,
+			Name:        r.Name(),
+			URL:         r.URL(),
+			Description: r.Description,
+			Language:    r.Language,
+			Topics:      r.Topics,
+			Labels:      r.Labels,
+			Stars:       r.Stars,
+			Forks:       r.Forks,
+			Mentions:    len(r.Messages),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(output)
+}
+
+// serveOpenAPI writes the OpenAPI document describing the JSON API.
+func (h *Handler) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, OpenAPISpec)
+}
+
+// serveTopOverall writes the top repositories across all languages as JSON.
+func (h *Handler) serveTopOverall(w http.ResponseWriter, r *http.Request) {
+	// Parse the number of results.
+	n := DefaultTopOverallN
+	if s := r.FormValue("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxTopOverallN {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		n = v
+	}
+
+	if h.notModified(w, r) {
+		return
+	}
+
+	// Retrieve the top repositories.
+	a, err := h.Store.TopRepositoriesOverall(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert to JSON representation.
+	output := make([]*rankedRepositoryJSON, len(a))
+	for i, r := range a {
+		output[i] = &rankedRepositoryJSON{
+			Rank:        i + 1,
+			ID:          r.ID,
+			Name:        r.Name(),
+			URL:         r.URL(),
+			Description: r.Description,
+			Language:    r.Language,
+			Topics:      r.Topics,
+			Labels:      r.Labels,
+			Stars:       r.Stars,
+			Forks:       r.Forks,
+			Mentions:    len(r.Messages),
+			Score:       r.Score,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(output)
+}
+
+// serveTopRecent writes the top unnotified repositories by their mentions
+// within a window, optionally filtered by language, as JSON.
+func (h *Handler) serveTopRecent(w http.ResponseWriter, r *http.Request) {
+	// Parse the number of results & window.
+	n := DefaultTopOverallN
+	if s := r.FormValue("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxTopOverallN {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		n = v
+	}
+	window := DefaultRecentWindow
+	if s := r.FormValue("window"); s != "" {
+		v, err := time.ParseDuration(s)
+		if err != nil || v <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		window = v
+	}
+
+	// Retrieve the top repositories.
+	a, err := h.Store.RecentTopRepositories(r.FormValue("language"), time.Now().Add(-window), n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert to JSON representation.
+	output := make([]*recentRepositoryJSON, len(a))
+	for i, r := range a {
+		output[i] = &recentRepositoryJSON{
+			Rank:        i + 1,
+			ID:          r.ID,
+			Name:        r.Name(),
+			URL:         r.URL(),
+			Description: r.Description,
+			Language:    h.Store.LanguageAliases.Normalize(r.Language),
+			Topics:      r.Topics,
+			Labels:      r.Labels,
+			Stars:       r.Stars,
+			Forks:       r.Forks,
+			Mentions:    len(r.Messages),
+			Boost:       r.Boost,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(output)
+}
+
+// serveIngest saves a JSON array of messages pushed by an external source.
+// Messages whose repository URL does not link to a repository are ignored.
+func (h *Handler) serveIngest(w http.ResponseWriter, r *http.Request) {
+	if h.Ingest == nil || h.IngestToken == "" {
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Verify bearer token.
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.IngestToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Decode messages.
+	var input []*ingestMessageJSON
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxIngestBodySize)).Decode(&input); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if len(input) > MaxIngestN {
+		http.Error(w, fmt.Sprintf("too many messages: %d > %d", len(input), MaxIngestN), http.StatusBadRequest)
+		return
+	}
+
+	// Group messages by source.
+	extract := h.IngestRepositoryID
+	if extract == nil {
+		extract = func(rawurl string) string {
+			u, err := url.Parse(rawurl)
+			if err != nil {
+				return ""
+			}
+			id, _ := ExtractRepositoryID(u)
+			return id
+		}
+	}
+	var sources []string
+	bySource := make(map[string][]*Message)
+	for i, m := range input {
+		if m == nil || m.ID == 0 {
+			http.Error(w, fmt.Sprintf("message %d: id required", i), http.StatusBadRequest)
+			return
+		}
+
+		id := extract(m.RepositoryURL)
+		if id == "" {
+			continue
+		}
+		if _, ok := bySource[m.Source]; !ok {
+			sources = append(sources, m.Source)
+		}
+		bySource[m.Source] = append(bySource[m.Source], &Message{
+			ID:           m.ID,
+			Text:         m.Text,
+			RepositoryID: id,
+			URL:          m.URL,
+			Author:       m.Author,
+			Time:         m.Timestamp,
+		})
+	}
+
+	output := &ingestResultJSON{Ignored: len(input)}
+	for _, source := range sources {
+		n, err := h.Ingest(r.Context(), source, bySource[source])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		output.Accepted += n
+		output.Ignored -= len(bySource[source])
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(output)
+}
+
 // serveTopStats prints timing stats for calculating top repos.
 func (h *Handler) serveTopStats(w http.ResponseWriter, r *http.Request) {
 	// Retrieve the top repositories.
@@ -117,88 +577,1226 @@ func (h *Handler) serveTopStats(w http.ResponseWriter, r *http.Request) {
 	}
 	nDuration := time.Since(nStartTime)
 
+	// Calculate average time per repository.
+	var perRepo time.Duration
+	if repositoryN > 0 {
+		perRepo = topDuration / time.Duration(repositoryN)
+	}
+
 	w.Header().Set("content-type", "text/plain")
 	fmt.Fprintf(w, "repositories: %d\n", repositoryN)
-	fmt.Fprintf(w, "top time: %s (%s per repo)\n", topDuration, topDuration/time.Duration(repositoryN))
+	fmt.Fprintf(w, "top time: %s (%s per repo)\n", topDuration, perRepo)
 	fmt.Fprintf(w, "count time: %s\n", nDuration)
 }
 
-// serveRepositories prints a list of all repositories.
+// serveRepositories prints a list of all repositories. Repositories are
+// streamed in ID order unless a page is requested with the "limit" and
+// "after" parameters, in which case a Link header refers to the next page.
 func (h *Handler) serveRepositories(w http.ResponseWriter, r *http.Request) {
-	// Retrieve all repositories.
-	repos, err := h.Store.Repositories()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Parse the page size, if any.
+	var limit int
+	if s := r.FormValue("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxRepositoriesPageN {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+
+	if h.notModified(w, r) {
 		return
 	}
 
-	// Sort by ID.
-	sort.Sort(Repositories(repos))
+	// Retrieve a single page, if requested.
+	var page []*Repository
+	if limit > 0 {
+		a, err := h.Store.RepositoriesPage(r.FormValue("after"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if len(a) == limit {
+			q := url.Values{"after": {a[len(a)-1].ID}, "limit": {strconv.Itoa(limit)}}
+			w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+		}
+		page = a
+	}
 
 	// Initialize CSV writer.
 	w.Header().Set("Content-Type", "text/plain")
 	cw := csv.NewWriter(w)
-	if err := cw.Write([]string{"id", "description", "language", "notified", "messages"}); err != nil {
+	if err := cw.Write([]string{"id", "description", "language", "notified", "messages", "last_message_url"}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Write each row.
-	for _, r := range repos {
-		notified := strconv.FormatBool(r.Notified)
-		messageN := strconv.Itoa(len(r.Messages))
+	writeRow := func(repo *Repository) error {
+		notified := strconv.FormatBool(repo.Notified)
+		messageN := strconv.Itoa(len(repo.Messages))
 
-		if err := cw.Write([]string{r.ID, r.Description, r.Language, notified, messageN}); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		// Link to the most recent message.
+		var last *Message
+		for _, m := range repo.Messages {
+			if last == nil || m.ID > last.ID {
+				last = m
+			}
+		}
+		var lastURL string
+		if last != nil {
+			lastURL = last.URL
+		}
+
+		return cw.Write([]string{repo.ID, repo.Description, repo.Language, notified, messageN, lastURL})
+	}
+
+	var err error
+	if limit > 0 {
+		for _, repo := range page {
+			if err = writeRow(repo); err != nil {
+				break
+			}
 		}
+	} else {
+		err = h.Store.ForEachRepository(r.Context(), writeRow)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// Flush the writer out.
 	cw.Flush()
 }
 
+// serveRepository writes a single repository and a slice of its messages as JSON.
+func (h *Handler) serveRepository(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/repositories/")
+
+	// Parse the number of messages.
+	n := DefaultRepositoryMessageN
+	if s := r.FormValue("messages"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 || v > MaxRepositoryMessageN {
+			http.Error(w, "invalid messages", http.StatusBadRequest)
+			return
+		}
+		n = v
+	}
+
+	// Parse message order.
+	var desc bool
+	switch r.FormValue("order") {
+	case "", "desc":
+		desc = true
+	case "asc":
+	default:
+		http.Error(w, "invalid order", http.StatusBadRequest)
+		return
+	}
+
+	// Retrieve the repository.
+	repo, total, err := h.Store.RepositoryMessages(id, n, desc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if repo == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Count all messages by source, not just those returned.
+	sources, err := h.Store.RepositorySources(repo.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convert to JSON representation.
+	output := &repositoryJSON{
+		ID:          repo.ID,
+		Name:        repo.Name(),
+		URL:         repo.URL(),
+		Description: repo.Description,
+		Language:    repo.Language,
+		Topics:      repo.Topics,
+		Labels:      repo.Labels,
+		Stars:       repo.Stars,
+		Forks:       repo.Forks,
+		Notified:    repo.Notified,
+		Fork:        repo.Fork,
+		Archived:    repo.Archived,
+		Disabled:    repo.Disabled,
+		License:     repo.License,
+		Boost:       repo.Boost,
+		Mentions:    total,
+		Sources:     sources,
+		Messages:    make([]*messageJSON, len(repo.Messages)),
+	}
+	for i, m := range repo.Messages {
+		output.Messages[i] = &messageJSON{ID: m.ID, Text: m.Text, URL: m.URL}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(output)
+}
+
 // serveBackup writes the store to the response writer.
+// Pass "file=messages" to write the separate message file, if configured.
 func (h *Handler) serveBackup(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "binary/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename=db")
-	if _, err := h.Store.WriteTo(w); err != nil {
+	switch r.FormValue("file") {
+	case "":
+		w.Header().Set("Content-Type", "binary/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename=db")
+		if _, err := h.Store.WriteTo(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "messages":
+		if h.Store.MessagePath == "" {
+			http.Error(w, ErrNoMessageFile.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "binary/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename=messages")
+		if _, err := h.Store.WriteMessagesTo(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "invalid file", http.StatusBadRequest)
+	}
+}
+
+// servePollerStatus writes ingestion diagnostics as JSON.
+func (h *Handler) servePollerStatus(w http.ResponseWriter, r *http.Request) {
+	if h.PollerStatus == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	buf, err := json.MarshalIndent(h.PollerStatus(), "", "  ")
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-}
 
-// serveExpvars handles /debug/vars requests.
-func (h *Handler) serveExpvars(w http.ResponseWriter, r *http.Request) {
-	// Copied from $GOROOT/src/expvar/expvar.go
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
 
-	// Generate JSON.
-	data := expvarJSON()
+// serveSources writes the health of each message source as JSON.
+func (h *Handler) serveSources(w http.ResponseWriter, r *http.Request) {
+	if h.SourceStatus == nil {
+		http.NotFound(w, r)
+		return
+	}
 
-	// Pretty print JSON.
-	var buf bytes.Buffer
-	if err := json.Indent(&buf, data, "", "  "); err != nil {
+	buf, err := json.MarshalIndent(h.SourceStatus(), "", "  ")
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Write to response.
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	buf.WriteTo(w)
+	w.Write(buf)
 }
 
-// expvarJSON returns expvars as a JSON-formatted byte slice.
-func expvarJSON() []byte {
-	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "{\n")
-	first := true
-	expvar.Do(func(kv expvar.KeyValue) {
-		if !first {
-			fmt.Fprintf(&buf, ",\n")
+// serveNotifications writes the most recent notification attempts as JSON.
+func (h *Handler) serveNotifications(w http.ResponseWriter, r *http.Request) {
+	n := DefaultNotificationN
+	if s := r.FormValue("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxNotificationN {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
 		}
-		first = false
-		fmt.Fprintf(&buf, "%q: %s", kv.Key, kv.Value)
-	})
-	fmt.Fprintf(&buf, "\n}\n")
-	return buf.Bytes()
+		n = v
+	}
+
+	a, err := h.Store.Notifications(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*Notification{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveTemplateStats writes the engagement with each account's template
+// variants as JSON. Averages are over notifications with fetched engagement.
+func (h *Handler) serveTemplateStats(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Store.TemplateStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	output := make([]*templateStatsJSON, len(a))
+	for i, stats := range a {
+		output[i] = &templateStatsJSON{
+			Username:      stats.Username,
+			Template:      stats.Template,
+			Notifications: stats.Notifications,
+			Measured:      stats.Measured,
+			Likes:         stats.Likes,
+			Retweets:      stats.Retweets,
+			Impressions:   stats.Impressions,
+		}
+		if stats.Measured > 0 {
+			output[i].AvgLikes = float64(stats.Likes) / float64(stats.Measured)
+			output[i].AvgRetweets = float64(stats.Retweets) / float64(stats.Measured)
+			output[i].AvgImpressions = float64(stats.Impressions) / float64(stats.Measured)
+		}
+	}
+
+	buf, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveLanguages writes the repository & mention counts for each language
+// as JSON, along with the accounts notifying for the language, if known.
+// Recent mentions are those posted in the last day.
+func (h *Handler) serveLanguages(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Store.LanguageStats(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Find the accounts notifying for each language. Topic & pattern
+	// accounts are not counted.
+	accounts := make(map[string][]string)
+	if h.NotifierStatus != nil {
+		for _, acc := range h.NotifierStatus().Accounts {
+			if acc.Topic == "" && acc.Pattern == "" {
+				lang := strings.ToLower(h.Store.LanguageAliases.Normalize(acc.Language))
+				accounts[lang] = append(accounts[lang], acc.Username)
+			}
+		}
+	}
+
+	output := make([]*languageJSON, len(a))
+	for i, stats := range a {
+		usernames := accounts[strings.ToLower(stats.Language)]
+		output[i] = &languageJSON{
+			Language:       stats.Language,
+			Repositories:   stats.Repositories,
+			Mentions:       stats.Mentions,
+			RecentMentions: stats.RecentMentions,
+			HasAccount:     len(usernames) > 0,
+			Accounts:       usernames,
+		}
+	}
+
+	buf, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveSearch writes up to "n" repositories whose ID, description, or
+// messages contain every term in "q" as JSON.
+func (h *Handler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.FormValue("q")
+	if len(SearchTerms(q)) == 0 {
+		http.Error(w, "query required", http.StatusBadRequest)
+		return
+	}
+
+	n := DefaultSearchN
+	if s := r.FormValue("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxSearchN {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		n = v
+	}
+
+	a, err := h.Store.Search(q, n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	output := make([]*searchResultJSON, len(a))
+	for i, result := range a {
+		repo := result.Repository
+		output[i] = &searchResultJSON{
+			ID:          repo.ID,
+			Name:        repo.Name(),
+			URL:         repo.URL(),
+			Description: repo.Description,
+			Language:    repo.Language,
+			Notified:    repo.Notified,
+			Mentions:    repo.RankedMentions(),
+			Matched:     result.Matched,
+			Messages:    make([]*messageJSON, len(result.Messages)),
+		}
+		for j, m := range result.Messages {
+			output[i].Messages[j] = &messageJSON{ID: m.ID, Text: m.Text, URL: m.URL}
+		}
+	}
+
+	buf, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveRepositoryTimeSeries writes a repository's mentions per day over the
+// last "days" days as JSON, oldest first.
+func (h *Handler) serveRepositoryTimeSeries(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/repositories/")
+	if !strings.HasSuffix(id, "/timeseries") {
+		http.NotFound(w, r)
+		return
+	}
+	id = strings.TrimSuffix(id, "/timeseries")
+
+	days := DefaultTimeSeriesDays
+	if s := r.FormValue("days"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxTimeSeriesDays {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+
+	now := time.Now()
+	ts, err := h.Store.MentionTimeSeries(id, now.AddDate(0, 0, 1-days), now)
+	if err == ErrRepositoryNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	buf, err := json.MarshalIndent(ts, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveTakedown removes all stored data for a repository. If "block" is
+// true then the repository is also blacklisted so it is never stored again.
+// Requests must authenticate with the admin token.
+func (h *Handler) serveTakedown(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/repositories/")
+
+	var block bool
+	if s := r.FormValue("block"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, "invalid block", http.StatusBadRequest)
+			return
+		}
+		block = v
+	}
+
+	if err := h.Store.Takedown(id, block); err == ErrRepositoryNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err == ErrInvalidBlacklistPattern {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveHistory writes the daily snapshots of the last "days" days as JSON,
+// oldest first. Snapshots only include the "language" ranking, if set.
+func (h *Handler) serveHistory(w http.ResponseWriter, r *http.Request) {
+	days := DefaultHistoryDays
+	if s := r.FormValue("days"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > MaxHistoryDays {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+
+	a, err := h.Store.Snapshots(time.Now().AddDate(0, 0, 1-days))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*Snapshot{}
+	}
+
+	// Filter rankings by language, if specified.
+	if lang := r.FormValue("language"); lang != "" {
+		lang = h.Store.LanguageAliases.Normalize(lang)
+		for _, ss := range a {
+			m := make(map[string][]*SnapshotEntry)
+			for k, entries := range ss.Languages {
+				if strings.EqualFold(k, lang) {
+					m[k] = entries
+				}
+			}
+			ss.Languages = m
+		}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// servePending writes the notifications awaiting approval as JSON.
+func (h *Handler) servePending(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Store.PendingNotifications()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*PendingNotification{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// servePendingAction approves or rejects a pending notification.
+// Requests are in the form: POST /pending/<id>/approve|reject
+// Requests must authenticate with the admin token.
+func (h *Handler) servePendingAction(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/pending/"), "/")
+	if len(segments) != 2 {
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseUint(segments[0], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch segments[1] {
+	case "approve":
+		err = h.Store.ApprovePendingNotification(id)
+	case "reject":
+		err = h.Store.RejectPendingNotification(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err == ErrPendingNotificationNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// serveFlagged writes repositories flagged by the content filter as JSON.
+func (h *Handler) serveFlagged(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Store.FlaggedRepositories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*FlaggedRepository{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveFlaggedAction approves or rejects a flagged repository.
+// Requests are in the form: POST /flagged/<repository id>/approve|reject
+// Requests must authenticate with the admin token.
+func (h *Handler) serveFlaggedAction(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/flagged/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, action := path[:i], path[i+1:]
+
+	var err error
+	switch action {
+	case "approve":
+		err = h.Store.ApproveFlaggedRepository(id)
+	case "reject":
+		err = h.Store.RejectFlaggedRepository(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err == ErrFlaggedRepositoryNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// serveOptOuts lists opt-outs on GET, adds an opt-out from the "pattern" &
+// "reason" form values on POST, and removes the "pattern" opt-out on DELETE.
+// Adding an opt-out purges all matching repositories. Changes must
+// authenticate with the admin token.
+func (h *Handler) serveOptOuts(w http.ResponseWriter, r *http.Request) {
+	if (r.Method == "POST" || r.Method == "DELETE") && !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		n, err := h.Store.AddOptOut(&OptOut{Pattern: r.FormValue("pattern"), Reason: r.FormValue("reason")})
+		if err == ErrInvalidOptOutPattern {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "ok, %d repositories purged\n", n)
+		return
+	case "DELETE":
+		if err := h.Store.RemoveOptOut(r.FormValue("pattern")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+		return
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, err := h.Store.OptOuts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*OptOut{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveAuthorMessages deletes all stored messages from an author.
+// Requests are in the form: DELETE /authors/<author>/messages
+// Requests must authenticate with the admin token.
+func (h *Handler) serveAuthorMessages(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/authors/")
+	i := strings.Index(path, "/")
+	if i <= 0 || path[i+1:] != "messages" {
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "DELETE" {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := h.Store.DeleteMessagesByAuthor(path[:i])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "ok, %d messages deleted\n", n)
+}
+
+// authorizeAdmin verifies the request's bearer token against AdminToken.
+// Writes an error & returns false if the request is not authorized.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.AdminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// serveAdminRepositories retrieves the "id" repository from the remote store
+// and saves it, even if it has not been mentioned.
+func (h *Handler) serveAdminRepositories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "id required", http.StatusBadRequest)
+		return
+	}
+
+	repo, err := h.Store.AddRepository(r.Context(), id)
+	switch err {
+	case nil:
+	case ErrRepositoryNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case ErrBlacklisted, ErrOptedOut:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "ok, %s saved\n", repo.ID)
+}
+
+// serveAdminBoost sets the number of mentions, "n", added to the "id"
+// repository when ranking. A negative boost demotes the repository.
+func (h *Handler) serveAdminBoost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := strconv.Atoi(r.FormValue("n"))
+	if err != nil {
+		http.Error(w, "invalid n", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.SetBoost(r.FormValue("id"), n); err == ErrRepositoryNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveAdminBlacklist writes the blacklisted repository IDs & patterns as
+// JSON. A "pattern" is added with POST and removed with DELETE.
+func (h *Handler) serveAdminBlacklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if err := h.Store.AddBlacklist(r.FormValue("pattern")); err == ErrInvalidBlacklistPattern {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+		return
+	case "DELETE":
+		if err := h.Store.RemoveBlacklist(r.FormValue("pattern")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+		return
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, err := h.Store.Blacklist()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []string{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveAdminLabels adds a curation "label" to the "id" repository with POST
+// and removes it with DELETE.
+func (h *Handler) serveAdminLabels(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "POST":
+		err = h.Store.AddLabel(r.FormValue("id"), r.FormValue("label"))
+	case "DELETE":
+		err = h.Store.RemoveLabel(r.FormValue("id"), r.FormValue("label"))
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err == ErrInvalidLabel {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err == ErrRepositoryNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveAdminNotify immediately sends the "id" repository from the "username"
+// account.
+func (h *Handler) serveAdminNotify(w http.ResponseWriter, r *http.Request) {
+	if h.ForceNotify == nil {
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m, err := h.ForceNotify(r.Context(), r.FormValue("username"), r.FormValue("id"))
+	if err == ErrAccountNotFound || err == ErrRepositoryNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err == ErrBlacklisted || err == ErrOptedOut || err == ErrNotEligible {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err == ErrNotLeader {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if m != nil && m.URL != "" {
+		fmt.Fprintf(w, "ok, sent %s\n", m.URL)
+		return
+	}
+	fmt.Fprintln(w, "ok, sent")
+}
+
+// serveAdminResetNotified clears the notified flag on repositories in the
+// optional "language" that were notified before the optional "before" date.
+func (h *Handler) serveAdminResetNotified(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var before time.Time
+	if s := r.FormValue("before"); s != "" {
+		t, err := ParseDate(s)
+		if err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		before = t
+	}
+
+	n, err := h.Store.ResetNotified(r.FormValue("language"), before)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "ok, %d repositories reset\n", n)
+}
+
+// serveShortURLs writes all shortened repository URLs as JSON.
+func (h *Handler) serveShortURLs(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Store.ShortURLs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if a == nil {
+		a = []*ShortURL{}
+	}
+
+	buf, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveNotifier prints each account's notification & skip counts.
+func (h *Handler) serveNotifier(w http.ResponseWriter, r *http.Request) {
+	if h.NotifierStatus == nil {
+		http.NotFound(w, r)
+		return
+	}
+	status := h.NotifierStatus()
+
+	w.Header().Set("content-type", "text/plain")
+
+	for _, acc := range status.Accounts {
+		label := acc.Language
+		if acc.Pattern != "" {
+			label = "pattern=" + acc.Pattern
+		} else if acc.Topic != "" {
+			label = "topic=" + acc.Topic
+		}
+		fmt.Fprintf(w, "@%s (%s): notified=%d", acc.Username, label, acc.NotifiedN)
+
+		// Print skip counts in a stable order.
+		reasons := make([]string, 0, len(acc.Skips))
+		for reason := range acc.Skips {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(w, " %s=%d", reason, acc.Skips[SkipReason(reason)])
+		}
+
+		if acc.LastSkip != "" {
+			fmt.Fprintf(w, " last_skip=%s", acc.LastSkip)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// serveExplain prints why each account's most recent pick was sent or blocked.
+func (h *Handler) serveExplain(w http.ResponseWriter, r *http.Request) {
+	if h.Explain == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("content-type", "text/plain")
+
+	for _, e := range h.Explain() {
+		reason := e.Reason
+		if e.Blocked {
+			reason = "blocked: " + reason
+		}
+		fmt.Fprintf(w, "@%s %s at %s: %s\n", e.Username, e.RepositoryID, e.Time.Format(time.RFC3339), reason)
+	}
+}
+
+// serveMessageStream streams newly saved messages as server-sent events until
+// the client disconnects. Messages can be filtered by language.
+func (h *Handler) serveMessageStream(w http.ResponseWriter, r *http.Request) {
+	if h.Events == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	language := h.Store.LanguageAliases.Normalize(r.URL.Query().Get("language"))
+
+	sub := h.Events.Subscribe(events.TypeMessageAdded)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-sub.C():
+			m := e.(*events.MessageAdded)
+			if language != "" && !strings.EqualFold(m.Language, language) {
+				continue
+			}
+
+			buf, err := json.Marshal(m)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", e.Type(), m.ID, buf)
+			flusher.Flush()
+		}
+	}
+}
+
+// serveNotifierStatus writes notification diagnostics as JSON.
+func (h *Handler) serveNotifierStatus(w http.ResponseWriter, r *http.Request) {
+	if h.NotifierStatus == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	buf, err := json.MarshalIndent(h.NotifierStatus(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// serveExpvars handles /debug/vars requests.
+func (h *Handler) serveExpvars(w http.ResponseWriter, r *http.Request) {
+	// Copied from $GOROOT/src/expvar/expvar.go
+
+	// Generate JSON.
+	data := expvarJSON()
+
+	// Pretty print JSON.
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Write to response.
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// expvarJSON returns expvars as a JSON-formatted byte slice.
+func expvarJSON() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprintf(&buf, ",\n")
+		}
+		first = false
+		fmt.Fprintf(&buf, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(&buf, "\n}\n")
+	return buf.Bytes()
+}
+
+// ingestMessageJSON is the JSON representation of a pushed message.
+type ingestMessageJSON struct {
+	ID            uint64    `json:"id,string"`
+	Text          string    `json:"text"`
+	RepositoryURL string    `json:"repository_url"`
+	URL           string    `json:"url,omitempty"`
+	Author        string    `json:"author,omitempty"`
+	Timestamp     time.Time `json:"timestamp,omitempty"`
+	Source        string    `json:"source,omitempty"`
+}
+
+// ingestResultJSON is the JSON representation of an ingestion result.
+// Accepted messages were saved or queued. Ignored messages did not link to a
+// repository.
+type ingestResultJSON struct {
+	Accepted int `json:"accepted"`
+	Ignored  int `json:"ignored"`
+}
+
+// topRepositoryJSON is the JSON representation of the top repository for a
+// language or topic key.
+type topRepositoryJSON struct {
+	Key         string   `json:"key"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+}
+
+// rankedRepositoryJSON is the JSON representation of a ranked repository.
+type rankedRepositoryJSON struct {
+	Rank        int      `json:"rank"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+	Score       float64  `json:"score"`
+}
+
+// recentRepositoryJSON is the JSON representation of a repository ranked by
+// its mentions within a window.
+type recentRepositoryJSON struct {
+	Rank        int      `json:"rank"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Stars       int      `json:"stars"`
+	Forks       int      `json:"forks"`
+	Mentions    int      `json:"mentions"`
+	Boost       int      `json:"boost,omitempty"`
+}
+
+// repositoryJSON is the JSON representation of a repository.
+type repositoryJSON struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	URL         string         `json:"url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Topics      []string       `json:"topics,omitempty"`
+	Labels      []string       `json:"labels,omitempty"`
+	Stars       int            `json:"stars"`
+	Forks       int            `json:"forks"`
+	Notified    bool           `json:"notified"`
+	Fork        bool           `json:"fork,omitempty"`
+	Archived    bool           `json:"archived,omitempty"`
+	Disabled    bool           `json:"disabled,omitempty"`
+	License     string         `json:"license,omitempty"`
+	Boost       int            `json:"boost,omitempty"`
+	Mentions    int            `json:"mentions"`
+	Sources     map[string]int `json:"sources,omitempty"`
+	Messages    []*messageJSON `json:"messages"`
+}
+
+// languageJSON is the JSON representation of a language's stats. Recent
+// mentions are those posted in the last day.
+type languageJSON struct {
+	Language       string   `json:"language"`
+	Repositories   int      `json:"repositories"`
+	Mentions       int      `json:"mentions"`
+	RecentMentions int      `json:"recent_mentions"`
+	HasAccount     bool     `json:"has_account"`
+	Accounts       []string `json:"accounts,omitempty"`
+}
+
+// templateStatsJSON is the JSON representation of a template variant's
+// engagement.
+type templateStatsJSON struct {
+	Username       string  `json:"username"`
+	Template       string  `json:"template"`
+	Notifications  int     `json:"notifications"`
+	Measured       int     `json:"measured"`
+	Likes          int     `json:"likes"`
+	Retweets       int     `json:"retweets"`
+	Impressions    int     `json:"impressions"`
+	AvgLikes       float64 `json:"avg_likes"`
+	AvgRetweets    float64 `json:"avg_retweets"`
+	AvgImpressions float64 `json:"avg_impressions"`
+}
+
+// searchResultJSON is the JSON representation of a search result. Matched is
+// true if the repository's ID or description matched the query. Messages are
+// the repository's messages that matched.
+type searchResultJSON struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	URL         string         `json:"url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Notified    bool           `json:"notified"`
+	Mentions    int            `json:"mentions"`
+	Matched     bool           `json:"matched"`
+	Messages    []*messageJSON `json:"messages"`
+}
+
+// messageJSON is the JSON representation of a message.
+type messageJSON struct {
+	ID   uint64 `json:"id,string"`
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
 }
@@ -0,0 +1,981 @@
+package scuttlebutt_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/events"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// update regenerates golden files from the current handler output.
+var update = flag.Bool("update", false, "update golden files")
+
+// Ensure each handler route writes the expected response.
+func TestHandler_Golden(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	for _, tt := range []struct {
+		golden      string
+		url         string
+		contentType string
+	}{
+		{golden: "root.golden", url: "/"},
+		{golden: "ping.golden", url: "/ping"},
+		{golden: "top.golden", url: "/top", contentType: "text/plain"},
+		{golden: "repositories.golden", url: "/repositories", contentType: "text/plain"},
+		{golden: "top_json.golden", url: "/api/v1/top", contentType: "application/json; charset=utf-8"},
+		{golden: "top_overall.golden", url: "/api/v1/top/overall", contentType: "application/json; charset=utf-8"},
+		{golden: "top_overall_n.golden", url: "/api/v1/top/overall?n=1", contentType: "application/json; charset=utf-8"},
+		{golden: "pending.golden", url: "/pending", contentType: "application/json; charset=utf-8"},
+		{golden: "flagged.golden", url: "/flagged", contentType: "application/json; charset=utf-8"},
+		{golden: "opt_outs.golden", url: "/opt_outs", contentType: "application/json; charset=utf-8"},
+		{golden: "notifications.golden", url: "/notifications", contentType: "application/json; charset=utf-8"},
+		{golden: "notification_templates.golden", url: "/notifications/templates", contentType: "application/json; charset=utf-8"},
+		{golden: "notifications_n.golden", url: "/notifications?n=1", contentType: "application/json; charset=utf-8"},
+		{golden: "short_urls.golden", url: "/api/v1/short_urls", contentType: "application/json; charset=utf-8"},
+		{golden: "poller.golden", url: "/debug/poller", contentType: "application/json; charset=utf-8"},
+		{golden: "sources.golden", url: "/sources", contentType: "application/json; charset=utf-8"},
+		{golden: "notifier.golden", url: "/notifier", contentType: "text/plain"},
+		{golden: "notifier_status.golden", url: "/debug/notifier", contentType: "application/json; charset=utf-8"},
+		{golden: "explain.golden", url: "/explain", contentType: "text/plain"},
+		{golden: "languages.golden", url: "/languages", contentType: "application/json; charset=utf-8"},
+		{golden: "search.golden", url: "/search?q=hello", contentType: "application/json; charset=utf-8"},
+		{golden: "repository.golden", url: "/api/v1/repositories/github.com/benbjohnson/go2", contentType: "application/json; charset=utf-8"},
+		{golden: "repository_asc.golden", url: "/api/v1/repositories/github.com/benbjohnson/go2?messages=1&order=asc", contentType: "application/json; charset=utf-8"},
+	} {
+		w := h.Get(tt.url)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status: %d", tt.url, w.Code)
+			continue
+		} else if tt.contentType != "" && w.HeaderMap.Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: unexpected content type: %s", tt.url, w.HeaderMap.Get("Content-Type"))
+		}
+		assertGolden(t, tt.golden, w.Body.String())
+	}
+}
+
+// Ensure the OpenAPI document is valid JSON and each of its GET paths is served.
+func TestHandler_OpenAPI(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	w := h.Get("/openapi.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]*struct {
+			Parameters []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	} else if len(spec.Paths) == 0 {
+		t.Fatal("expected paths")
+	}
+	for path, ops := range spec.Paths {
+		// Streams do not end so they are tested separately.
+		if ops["get"] == nil || path == "/messages/stream" {
+			continue
+		}
+		path = strings.Replace(path, "{id}", "github.com/benbjohnson/go2", 1)
+
+		// Set required query parameters.
+		q := url.Values{}
+		for _, p := range ops["get"].Parameters {
+			if p.In == "query" && p.Required {
+				q.Set(p.Name, "go")
+			}
+		}
+		if len(q) > 0 {
+			path += "?" + q.Encode()
+		}
+
+		if w := h.Get(path); w.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status: %d", path, w.Code)
+		}
+	}
+}
+
+// Ensure the top stats route writes timing information.
+func TestHandler_TopStats(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	// Verify an empty store does not fail.
+	if w := h.Get("/top/stats"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if !strings.HasPrefix(w.Body.String(), "repositories: 0\n") {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+// Ensure pushed messages are grouped by source and ingested.
+func TestHandler_Ingest(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	got := make(map[string][]*scuttlebutt.Message)
+	h.IngestToken = "secret"
+	h.Ingest = func(ctx context.Context, source string, a []*scuttlebutt.Message) (int, error) {
+		got[source] = a
+		return len(a), nil
+	}
+
+	r, _ := http.NewRequest("POST", "/ingest", strings.NewReader(`[
+		{"id":"1","text":"foo","repository_url":"https://github.com/user/repo","author":"alice","timestamp":"2000-01-01T00:00:00Z","source":"hn"},
+		{"id":"2","text":"bar","repository_url":"https://example.com/nope","source":"hn"},
+		{"id":"3","text":"baz","repository_url":"https://github.com/user/other","url":"https://example.com/3"}
+	]`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if s := w.Body.String(); s != `{"accepted":2,"ignored":1}`+"\n" {
+		t.Fatalf("unexpected body: %s", s)
+	} else if !reflect.DeepEqual(got, map[string][]*scuttlebutt.Message{
+		"hn": {{ID: 1, Text: "foo", RepositoryID: "github.com/user/repo", Author: "alice", Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		"":   {{ID: 3, Text: "baz", RepositoryID: "github.com/user/other", URL: "https://example.com/3"}},
+	}) {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(got))
+	}
+}
+
+// Ensure pushed messages require the ingestion token.
+func TestHandler_Ingest_Unauthorized(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.IngestToken = "secret"
+	h.Ingest = func(ctx context.Context, source string, a []*scuttlebutt.Message) (int, error) {
+		t.Fatal("unexpected ingest")
+		return 0, nil
+	}
+
+	r, _ := http.NewRequest("POST", "/ingest", strings.NewReader(`[]`))
+	r.Header.Set("Authorization", "Bearer nope")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure admin endpoints require the admin token and are disabled without one.
+func TestHandler_Admin_Unauthorized(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	for _, path := range []string{"/admin/repositories", "/admin/boost", "/admin/blacklist", "/admin/labels", "/admin/notify", "/admin/reset_notified"} {
+		if w := h.Post(path); w.Code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status: %s: %d", path, w.Code)
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "/admin/boost?id=github.com/benbjohnson/go1&n=5", nil)
+	r.Header.Set("Authorization", "Bearer nope")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("unexpected challenge: %q", w.Header().Get("WWW-Authenticate"))
+	}
+
+	h.AdminToken = ""
+	if w := h.Admin("POST", "/admin/boost?id=github.com/benbjohnson/go1&n=5"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure a repository can be boosted and force notified through the admin API.
+func TestHandler_Admin(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	var notified string
+	h.ForceNotify = func(ctx context.Context, username, repositoryID string) (*scuttlebutt.Message, error) {
+		notified = username + " " + repositoryID
+		return &scuttlebutt.Message{URL: "https://twitter.com/oss_go/status/1"}, nil
+	}
+
+	if w := h.Admin("POST", "/admin/boost?id=github.com/benbjohnson/go1&n=5"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/boost?id=github.com/benbjohnson/nope&n=5"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/boost?id=github.com/benbjohnson/go1&n=x"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/go1"); err != nil {
+		t.Fatal(err)
+	} else if r.Boost != 5 {
+		t.Fatalf("unexpected boost: %d", r.Boost)
+	}
+
+	if w := h.Admin("POST", "/admin/notify?username=oss_go&id=github.com/benbjohnson/go1"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if s := w.Body.String(); s != "ok, sent https://twitter.com/oss_go/status/1\n" {
+		t.Fatalf("unexpected body: %s", s)
+	} else if notified != "oss_go github.com/benbjohnson/go1" {
+		t.Fatalf("unexpected notification: %s", notified)
+	}
+
+	h.ForceNotify = func(ctx context.Context, username, repositoryID string) (*scuttlebutt.Message, error) {
+		return nil, scuttlebutt.ErrNotLeader
+	}
+	if w := h.Admin("POST", "/admin/notify?username=oss_go&id=github.com/benbjohnson/go1"); w.Code != http.StatusConflict {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	if w := h.Admin("POST", "/admin/reset_notified?before=yesterday"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/reset_notified?language=go&before=2000-01-01"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if s := w.Body.String(); s != "ok, 0 repositories reset\n" {
+		t.Fatalf("unexpected body: %s", s)
+	}
+}
+
+// Ensure the languages route lists the accounts notifying for each language.
+func TestHandler_Languages(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+	h.NotifierStatus = func() *scuttlebutt.NotifierStatus {
+		return &scuttlebutt.NotifierStatus{Accounts: []*scuttlebutt.AccountStatus{
+			{Username: "oss_go", Language: "Go"},
+			{Username: "oss_cli", Language: "go", Topic: "cli"},
+		}}
+	}
+
+	w := h.Get("/languages")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var a []struct {
+		Language   string   `json:"language"`
+		HasAccount bool     `json:"has_account"`
+		Accounts   []string `json:"accounts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected language count: %d", len(a))
+	} else if a[0].Language != "go" || !a[0].HasAccount || !reflect.DeepEqual(a[0].Accounts, []string{"oss_go"}) {
+		t.Fatalf("unexpected language(0): %+v", a[0])
+	} else if a[1].Language != "javascript" || a[1].HasAccount {
+		t.Fatalf("unexpected language(1): %+v", a[1])
+	}
+}
+
+// Ensure the time series route returns a count for each day.
+func TestHandler_RepositoryTimeSeries(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	w := h.Get("/repositories/github.com/benbjohnson/go2/timeseries?days=7")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var ts scuttlebutt.MentionTimeSeries
+	if err := json.Unmarshal(w.Body.Bytes(), &ts); err != nil {
+		t.Fatal(err)
+	} else if ts.RepositoryID != "github.com/benbjohnson/go2" || len(ts.Days) != 7 || ts.Undated != 2 {
+		t.Fatalf("unexpected time series: %s", spew.Sdump(ts))
+	}
+
+	if w := h.Get("/repositories/github.com/benbjohnson/nope/timeseries"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Get("/repositories/github.com/benbjohnson/go2/timeseries?days=0"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Get("/repositories/github.com/benbjohnson/go2"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure the history route filters snapshots by day & language.
+func TestHandler_History(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	now := time.Now()
+	if _, err := h.Store.SaveSnapshot(now.AddDate(0, 0, -40), 10); err != nil {
+		t.Fatal(err)
+	} else if _, err := h.Store.SaveSnapshot(now, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	w := h.Get("/history?language=go")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var a []*scuttlebutt.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected snapshot count: %d", len(a))
+	} else if len(a[0].Languages) != 1 {
+		t.Fatalf("unexpected languages: %v", a[0].Languages)
+	} else if e := a[0].Languages["go"]; len(e) != 1 || e[0].RepositoryID != "github.com/benbjohnson/go2" || e[0].Rank != 1 {
+		t.Fatalf("unexpected entries: %s", spew.Sdump(e))
+	}
+
+	if w := h.Get("/history?days=60"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected snapshot count: %d", len(a))
+	}
+
+	if w := h.Get("/history?days=0"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure the backup route writes the database.
+func TestHandler_Backup(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	w := h.Get("/backup")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.HeaderMap.Get("Content-Disposition") != "attachment; filename=db" {
+		t.Fatalf("unexpected content disposition: %s", w.HeaderMap.Get("Content-Disposition"))
+	} else if w.Body.Len() == 0 {
+		t.Fatal("expected body")
+	}
+}
+
+// Ensure the top route returns not modified until the store changes.
+func TestHandler_Top_NotModified(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	w := h.Get("/top")
+	etag := w.HeaderMap.Get("ETag")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if etag == "" {
+		t.Fatal("expected etag")
+	}
+
+	r, _ := http.NewRequest("GET", "/top", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.Len() != 0 {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	// Writing to the store changes the version.
+	if err := h.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1000, RepositoryID: "github.com/benbjohnson/go3"}); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.HeaderMap.Get("ETag") == etag {
+		t.Fatal("expected etag to change")
+	}
+}
+
+// Ensure the repository listing can be read in pages.
+func TestHandler_Repositories_Page(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	w := h.Get("/repositories?limit=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if link := w.HeaderMap.Get("Link"); link != `</repositories?after=github.com%2Fbenbjohnson%2Fgo1&limit=1>; rel="next"` {
+		t.Fatalf("unexpected link: %s", link)
+	} else if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "github.com/benbjohnson/go1,") {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	if w := h.Get("/repositories?limit=0"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure invalid overall top limits are rejected.
+func TestHandler_TopOverall_ErrInvalidN(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	for _, u := range []string{"/api/v1/top/overall?n=0", "/api/v1/top/overall?n=x", "/api/v1/top/overall?n=1000"} {
+		if w := h.Get(u); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: unexpected status: %d", u, w.Code)
+		}
+	}
+}
+
+// Ensure the recent top repositories are ranked by mentions in the window.
+func TestHandler_TopRecent(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	now := time.Now()
+	for i, m := range []*scuttlebutt.Message{
+		{RepositoryID: "github.com/benbjohnson/go1", Time: now.Add(-2 * time.Hour)},
+		{RepositoryID: "github.com/benbjohnson/go2", Time: now},
+		{RepositoryID: "github.com/benbjohnson/js1", Time: now},
+	} {
+		m.ID, m.Text = uint64(1000+i), "hello"
+		if err := h.Store.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := h.Get("/api/v1/top/recent?language=go&window=1h")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `[{"rank":1,"id":"github.com/benbjohnson/go2","name":"go2","url":"https://github.com/benbjohnson/go2","description":"lorem ipsum","language":"go","topics":["cli"],"stars":10,"forks":0,"mentions":1}]` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	for _, u := range []string{"/api/v1/top/recent?n=0", "/api/v1/top/recent?window=x", "/api/v1/top/recent?window=-1h"} {
+		if w := h.Get(u); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: unexpected status: %d", u, w.Code)
+		}
+	}
+}
+
+// Ensure invalid repository message parameters are rejected.
+func TestHandler_Repository_ErrInvalidParams(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	for _, u := range []string{
+		"/api/v1/repositories/github.com/benbjohnson/go2?messages=-1",
+		"/api/v1/repositories/github.com/benbjohnson/go2?messages=100000",
+		"/api/v1/repositories/github.com/benbjohnson/go2?order=sideways",
+	} {
+		if w := h.Get(u); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: unexpected status: %d", u, w.Code)
+		}
+	}
+
+	if w := h.Get("/api/v1/repositories/github.com/no/such"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure unknown routes return not found.
+func TestHandler_NotFound(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	if w := h.Get("/no/such/route"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure saved messages are streamed as server-sent events, filtered by
+// language.
+func TestHandler_MessageStream(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Events = events.NewBus()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/messages/stream?language=go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+
+	h.Events.Publish(&events.MessageAdded{ID: 1, RepositoryID: "github.com/user/rb", Language: "Ruby", Text: "foo"})
+	h.Events.Publish(&events.MessageAdded{ID: 2, RepositoryID: "github.com/user/go", Language: "Go", Text: "bar", Author: "bob"})
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+	if exp := []string{
+		"event: message_added",
+		"id: 2",
+		`data: {"id":"2","repository_id":"github.com/user/go","language":"Go","text":"bar","author":"bob"}`,
+	}; !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("unexpected event: %q", lines)
+	}
+}
+
+// Ensure the message stream is disabled without an event bus.
+func TestHandler_MessageStream_NotFound(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/messages/stream", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Handler represents a test wrapper for scuttlebutt.Handler.
+type Handler struct {
+	*scuttlebutt.Handler
+	Store *Store
+}
+
+// OpenHandler returns a new handler backed by an open store.
+func OpenHandler() *Handler {
+	s := OpenStore()
+	return &Handler{
+		Handler: &scuttlebutt.Handler{
+			Store:      s.Store,
+			AdminToken: "admin",
+			PollerStatus: func() *scuttlebutt.PollerStatus {
+				return &scuttlebutt.PollerStatus{Errors: map[string]int{"remote": 2}, Spam: map[scuttlebutt.SpamReason]int{scuttlebutt.SpamBot: 3}, DeferredN: 1}
+			},
+			SourceStatus: func() []*scuttlebutt.SourceStatus {
+				t := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+				retryAt := t.Add(time.Minute)
+				return []*scuttlebutt.SourceStatus{
+					{Name: "default", PollInterval: "30s", LastPollTime: &t, LastSuccessTime: &t, MessageN: 10},
+					{Name: "mastodon", PollInterval: "1m0s", LastPollTime: &t, FailureN: 2, LastError: "poll: timeout", RetryAt: &retryAt},
+				}
+			},
+			NotifierStatus: func() *scuttlebutt.NotifierStatus {
+				return &scuttlebutt.NotifierStatus{Accounts: []*scuttlebutt.AccountStatus{
+					{Username: "oss_go", Language: "go", NotifiedN: 3, Skips: map[scuttlebutt.SkipReason]int{scuttlebutt.SkipWithinInterval: 5}},
+					{Username: "oss_cli", Topic: "cli", NotifiedN: 1, Skips: map[scuttlebutt.SkipReason]int{}},
+					{Username: "oss_js", Language: "javascript", Skips: map[scuttlebutt.SkipReason]int{scuttlebutt.SkipQuietHours: 2, scuttlebutt.SkipNoRepository: 1}, LastSkip: scuttlebutt.SkipQuietHours},
+				}}
+			},
+			Explain: func() []*scuttlebutt.Explanation {
+				return []*scuttlebutt.Explanation{
+					{Username: "oss_go", RepositoryID: "github.com/benbjohnson/go1", Reason: "sent", Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{Username: "oss_js", RepositoryID: "github.com/benbjohnson/go1", Blocked: true, Reason: "identical text featured by @oss_go for github.com/benbjohnson/go1 1h0m0s ago (window 24h0m0s)", Time: time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)},
+				}
+			},
+		},
+		Store: s,
+	}
+}
+
+// Close closes the underlying store.
+func (h *Handler) Close() error { return h.Store.Close() }
+
+// Seed adds a fixed set of repositories and messages to the store.
+func (h *Handler) Seed() {
+	h.Store.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Description: "lorem ipsum", Language: "go", Stars: 10}
+		switch id {
+		case "github.com/benbjohnson/go2":
+			r.Topics = []string{"cli"}
+		case "github.com/benbjohnson/js1":
+			r.Language, r.Description = "javascript", "dolor, sit \"amet\""
+		}
+		return r, nil
+	}
+
+	for i, id := range []string{
+		"github.com/benbjohnson/go1",
+		"github.com/benbjohnson/go2",
+		"github.com/benbjohnson/go2",
+		"github.com/benbjohnson/js1",
+	} {
+		if err := h.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), Text: "hello", RepositoryID: id, URL: fmt.Sprintf("https://twitter.com/user/status/%d", i+1)}); err != nil {
+			panic(err)
+		}
+	}
+	if err := h.Store.MarkNotified("github.com/benbjohnson/go1"); err != nil {
+		panic(err)
+	}
+	if err := h.Store.AddPendingNotification(&scuttlebutt.PendingNotification{
+		Username:     "oss_js",
+		RepositoryID: "github.com/benbjohnson/js1",
+		Text:         "js1 - dolor https://github.com/benbjohnson/js1",
+		CreatedAt:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}); err != nil {
+		panic(err)
+	}
+	if err := h.Store.FlagRepository(&scuttlebutt.FlaggedRepository{
+		RepositoryID: "github.com/benbjohnson/bad1",
+		Reason:       "blocked word in name: bad",
+		CreatedAt:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}); err != nil {
+		panic(err)
+	}
+	if _, err := h.Store.AddOptOut(&scuttlebutt.OptOut{
+		Pattern:   "private-owner",
+		Reason:    "owner request",
+		CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}); err != nil {
+		panic(err)
+	}
+	if err := h.Store.SaveShortURL("https://github.com/benbjohnson/go1", "https://sho.rt/abc"); err != nil {
+		panic(err)
+	}
+	for _, n := range []*scuttlebutt.Notification{
+		{Username: "oss_go", RepositoryID: "github.com/benbjohnson/go1", Text: "go1 - lorem ipsum", MessageID: 100, URL: "https://twitter.com/oss_go/status/100", Success: true, Template: "short", Engagement: &scuttlebutt.Engagement{Likes: 3, Retweets: 1, Impressions: 200, Time: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}, Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Username: "oss_js", RepositoryID: "github.com/benbjohnson/js1", Text: "js1 - dolor", Error: "send request: timeout", Time: time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)},
+	} {
+		if err := h.Store.AddNotification(n); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Ensure pending notifications can be approved and rejected.
+func TestHandler_PendingAction(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	// Notifications cannot be approved without the admin token.
+	if w := h.Post("/pending/1/approve"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, err := h.Store.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Approved {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	}
+
+	// Only POST requests are allowed.
+	if w := h.Admin("GET", "/pending/1/approve"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// Approve the seeded notification.
+	if w := h.Admin("POST", "/pending/1/approve"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, err := h.Store.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || !a[0].Approved {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	}
+
+	// Reject it and verify the repository will not be proposed again.
+	if w := h.Admin("POST", "/pending/1/reject"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, err := h.Store.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/js1"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified {
+		t.Fatal("expected notified")
+	}
+
+	// Unknown notifications return not found.
+	if w := h.Admin("POST", "/pending/1/reject"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure flagged repositories can be approved and rejected.
+func TestHandler_FlaggedAction(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	// Repositories cannot be approved without the admin token.
+	if w := h.Post("/flagged/github.com/benbjohnson/bad1/approve"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if f, err := h.Store.FlaggedRepository("github.com/benbjohnson/bad1"); err != nil {
+		t.Fatal(err)
+	} else if f == nil || f.Approved {
+		t.Fatalf("unexpected flag: %s", spew.Sdump(f))
+	}
+
+	// Only POST requests are allowed.
+	if w := h.Admin("GET", "/flagged/github.com/benbjohnson/bad1/approve"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// Approve the seeded repository.
+	if w := h.Admin("POST", "/flagged/github.com/benbjohnson/bad1/approve"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if f, err := h.Store.FlaggedRepository("github.com/benbjohnson/bad1"); err != nil {
+		t.Fatal(err)
+	} else if f == nil || !f.Approved {
+		t.Fatalf("unexpected flag: %s", spew.Sdump(f))
+	}
+
+	// Reject a flagged repository and verify it is marked as notified.
+	if err := h.Store.FlagRepository(&scuttlebutt.FlaggedRepository{RepositoryID: "github.com/benbjohnson/js1"}); err != nil {
+		t.Fatal(err)
+	} else if w := h.Admin("POST", "/flagged/github.com/benbjohnson/js1/reject"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if f, err := h.Store.FlaggedRepository("github.com/benbjohnson/js1"); err != nil {
+		t.Fatal(err)
+	} else if f != nil {
+		t.Fatalf("unexpected flag: %s", spew.Sdump(f))
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/js1"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified {
+		t.Fatal("expected notified")
+	}
+
+	// Unflagged repositories return not found.
+	if w := h.Admin("POST", "/flagged/github.com/benbjohnson/js1/reject"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure opt-outs can be added and removed.
+func TestHandler_OptOuts(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	// Opt-outs cannot be changed without the admin token.
+	if w := h.Post("/opt_outs?pattern=benbjohnson/js1&reason=request"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/js1"); err != nil {
+		t.Fatal(err)
+	} else if r == nil {
+		t.Fatal("expected repository")
+	}
+
+	// Add an opt-out and verify the repository is purged.
+	if w := h.Admin("POST", "/opt_outs?pattern=benbjohnson/js1&reason=request"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != "ok, 1 repositories purged\n" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/js1"); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+
+	// Invalid patterns are rejected.
+	if w := h.Admin("POST", "/opt_outs?pattern=owner/["); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// Remove the opt-out.
+	r, _ := http.NewRequest("DELETE", "/opt_outs?pattern=benbjohnson/js1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("DELETE", "/opt_outs?pattern=benbjohnson/js1"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, err := h.Store.OptOuts(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Pattern != "private-owner" {
+		t.Fatalf("unexpected opt-outs: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure an author's messages can only be deleted with the admin token.
+func TestHandler_AuthorMessages(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	if err := h.Store.AddMessage(context.Background(), &scuttlebutt.Message{ID: 10, Text: "spam", Author: "spammer", RepositoryID: "github.com/benbjohnson/go1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := h.Delete("/authors/spammer/messages"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if r, err := h.Store.Repository("github.com/benbjohnson/go1"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 2 {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	}
+
+	if w := h.Admin("DELETE", "/authors/spammer/messages"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != "ok, 1 messages deleted\n" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	} else if w := h.Admin("POST", "/authors/spammer/messages"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure blacklist patterns can be added, listed, and removed.
+func TestHandler_AdminBlacklist(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+
+	if w := h.Admin("POST", "/admin/blacklist?pattern=github.com/*/awesome-*"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/blacklist?pattern=/[/"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if v, err := h.Store.Blacklisted("github.com/user/awesome-go"); err != nil {
+		t.Fatal(err)
+	} else if !v {
+		t.Fatal("expected repository to be blacklisted")
+	}
+
+	if w := h.Admin("GET", "/admin/blacklist"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != "[\n  \"github.com/*/awesome-*\"\n]" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	}
+
+	if w := h.Admin("DELETE", "/admin/blacklist?pattern=github.com/*/awesome-*"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, err := h.Store.Blacklist(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected blacklist: %v", a)
+	}
+}
+
+// Ensure curation labels can be added & removed and are returned with
+// repositories.
+func TestHandler_AdminLabels(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	// Labels cannot be changed without the admin token.
+	if w := h.Post("/admin/labels?id=github.com/benbjohnson/go1&label=never"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if repo, err := h.Store.Repository("github.com/benbjohnson/go1"); err != nil {
+		t.Fatal(err)
+	} else if len(repo.Labels) != 0 {
+		t.Fatalf("unexpected labels: %v", repo.Labels)
+	}
+
+	if w := h.Admin("POST", "/admin/labels?id=github.com/benbjohnson/go1&label=needs-review"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/labels?id=github.com/benbjohnson/go1&label=NOPE!"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Admin("POST", "/admin/labels?id=github.com/benbjohnson/nope&label=featured"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Get("/api/v1/repositories/github.com/benbjohnson/go1"); !strings.Contains(w.Body.String(), `"labels":["needs-review"]`) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	if w := h.Admin("DELETE", "/admin/labels?id=github.com/benbjohnson/go1&label=needs-review"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if repo, err := h.Store.Repository("github.com/benbjohnson/go1"); err != nil {
+		t.Fatal(err)
+	} else if len(repo.Labels) != 0 {
+		t.Fatalf("unexpected labels: %v", repo.Labels)
+	}
+}
+
+// Ensure a repository can be taken down & blocked.
+func TestHandler_Takedown(t *testing.T) {
+	h := OpenHandler()
+	defer h.Close()
+	h.Seed()
+
+	del := func(path string) *httptest.ResponseRecorder { return h.Admin("DELETE", path) }
+
+	r, _ := http.NewRequest("DELETE", "/repositories/github.com/benbjohnson/go1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	if w := del("/repositories/github.com/benbjohnson/go1?block=true"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.Get("/api/v1/repositories/github.com/benbjohnson/go1"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if ok, err := h.Store.Blacklisted("github.com/benbjohnson/go1"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected blacklisted")
+	}
+
+	if w := del("/repositories/github.com/benbjohnson/nope"); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := del("/repositories/github.com/benbjohnson/go2?block=maybe"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Get executes a GET request against the handler.
+func (h *Handler) Get(url string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// Post executes a POST request against the handler.
+func (h *Handler) Post(url string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", url, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// Delete executes a DELETE request against the handler.
+func (h *Handler) Delete(url string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("DELETE", url, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// Admin executes a request against the handler authenticated with the
+// admin token.
+func (h *Handler) Admin(method, url string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, url, nil)
+	r.Header.Set("Authorization", "Bearer "+h.AdminToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// assertGolden compares s against the contents of a golden file in testdata.
+// The golden file is rewritten if the -update flag is set.
+func assertGolden(t *testing.T, name, s string) {
+	path := filepath.Join("testdata", "handler", name)
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(buf) != s {
+		t.Errorf("%s: unexpected output:\n\ngot:\n%s\n\nexpected:\n%s", name, s, buf)
+	}
+}
@@ -0,0 +1,129 @@
+package scuttlebutt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultHTTPDialTimeout is the default time allowed to establish outbound
+// connections.
+const DefaultHTTPDialTimeout = 30 * time.Second
+
+// HTTPOptions represents the settings shared by outbound HTTP clients, such
+// as the Twitter, GitHub, GitLab, and Gitea clients.
+type HTTPOptions struct {
+	// Proxy URL with an "http", "https", "socks5", or "socks5h" scheme.
+	// Proxies are read from the environment if blank.
+	Proxy string
+
+	// Replaces the User-Agent header of each request, if set.
+	UserAgent string
+
+	// Maximum time for a request, including reading the response body.
+	// Requests are not limited if zero.
+	Timeout time.Duration
+
+	// Connection pooling limits. The net/http defaults are used if zero.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+}
+
+// NewHTTPTransport returns a transport that applies the options. The
+// transport should be shared so connections are pooled between clients.
+func NewHTTPTransport(opt HTTPOptions) (http.RoundTripper, error) {
+	proxy := http.ProxyFromEnvironment
+	if opt.Proxy != "" {
+		u, err := url.Parse(opt.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %s", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid proxy scheme: %s", u.Scheme)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	base := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: DefaultHTTPDialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opt.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opt.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if opt.MaxIdleConns > 0 {
+		base.MaxIdleConns = opt.MaxIdleConns
+	}
+	if opt.IdleConnTimeout > 0 {
+		base.IdleConnTimeout = opt.IdleConnTimeout
+	}
+
+	if opt.UserAgent == "" && opt.Timeout == 0 {
+		return base, nil
+	}
+	return &httpTransport{userAgent: opt.UserAgent, timeout: opt.Timeout, transport: base}, nil
+}
+
+// httpTransport sets the User-Agent header and limits the duration of
+// requests sent through the underlying transport.
+type httpTransport struct {
+	userAgent string
+	timeout   time.Duration
+	transport http.RoundTripper
+}
+
+// RoundTrip sends req through the underlying transport. The timeout covers
+// reading the response body so it is only released once the body is closed.
+func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" {
+		other := *req
+		other.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			other.Header[k] = v
+		}
+		other.Header.Set("User-Agent", t.userAgent)
+		req = &other
+	}
+
+	return roundTripTimeout(t.transport, req, t.timeout)
+}
+
+// roundTripTimeout sends req through rt and cancels it if it takes longer
+// than timeout, including reading the response body. Not limited if zero.
+func roundTripTimeout(rt http.RoundTripper, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout == 0 {
+		return rt.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelReadCloser cancels a request's context once its body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (rc *cancelReadCloser) Close() error {
+	err := rc.ReadCloser.Close()
+	rc.cancel()
+	return err
+}
@@ -0,0 +1,51 @@
+package scuttlebutt_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the transport sets the user agent and limits request duration.
+func TestNewHTTPTransport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer s.Close()
+
+	transport, err := scuttlebutt.NewHTTPTransport(scuttlebutt.HTTPOptions{UserAgent: "scuttlebutt-test", Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	req.Header.Set("User-Agent", "other")
+	if resp, err := client.Do(req); err != nil {
+		t.Fatal(err)
+	} else if buf, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); string(buf) != "scuttlebutt-test" {
+		t.Fatalf("unexpected user agent: %s", buf)
+	} else if req.Header.Get("User-Agent") != "other" {
+		t.Fatal("expected original request to be unmodified")
+	}
+
+	if _, err := client.Get(s.URL + "/slow"); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+// Ensure proxies with unsupported schemes are rejected.
+func TestNewHTTPTransport_ErrInvalidProxyScheme(t *testing.T) {
+	if _, err := scuttlebutt.NewHTTPTransport(scuttlebutt.HTTPOptions{Proxy: "ftp://localhost:1080"}); err == nil || err.Error() != "invalid proxy scheme: ftp" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
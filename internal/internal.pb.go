@@ -11,6 +11,15 @@ It is generated from these files:
 It has these top-level messages:
 	Repository
 	Message
+	PendingNotification
+	Feature
+	Notification
+	RepositoryMessages
+	RateLimit
+	RateLimits
+	Account
+	FlaggedRepository
+	OptOut
 */
 package internal
 
@@ -27,6 +36,16 @@ type Repository struct {
 	Language         *string    `protobuf:"bytes,3,req" json:"Language,omitempty"`
 	Notified         *bool      `protobuf:"varint,4,req" json:"Notified,omitempty"`
 	Messages         []*Message `protobuf:"bytes,5,rep" json:"Messages,omitempty"`
+	Stars            *int64     `protobuf:"varint,6,opt" json:"Stars,omitempty"`
+	Forks            *int64     `protobuf:"varint,7,opt" json:"Forks,omitempty"`
+	Topics           []string   `protobuf:"bytes,8,rep" json:"Topics,omitempty"`
+	Fork             *bool      `protobuf:"varint,9,opt" json:"Fork,omitempty"`
+	Archived         *bool      `protobuf:"varint,10,opt" json:"Archived,omitempty"`
+	Disabled         *bool      `protobuf:"varint,11,opt" json:"Disabled,omitempty"`
+	Boost            *int64     `protobuf:"varint,12,opt" json:"Boost,omitempty"`
+	NotifiedAt       *int64     `protobuf:"varint,13,opt" json:"NotifiedAt,omitempty"`
+	Labels           []string   `protobuf:"bytes,14,rep" json:"Labels,omitempty"`
+	License          *string    `protobuf:"bytes,15,opt" json:"License,omitempty"`
 	XXX_unrecognized []byte     `json:"-"`
 }
 
@@ -69,9 +88,86 @@ func (m *Repository) GetMessages() []*Message {
 	return nil
 }
 
+func (m *Repository) GetStars() int64 {
+	if m != nil && m.Stars != nil {
+		return *m.Stars
+	}
+	return 0
+}
+
+func (m *Repository) GetForks() int64 {
+	if m != nil && m.Forks != nil {
+		return *m.Forks
+	}
+	return 0
+}
+
+func (m *Repository) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *Repository) GetFork() bool {
+	if m != nil && m.Fork != nil {
+		return *m.Fork
+	}
+	return false
+}
+
+func (m *Repository) GetArchived() bool {
+	if m != nil && m.Archived != nil {
+		return *m.Archived
+	}
+	return false
+}
+
+func (m *Repository) GetDisabled() bool {
+	if m != nil && m.Disabled != nil {
+		return *m.Disabled
+	}
+	return false
+}
+
+func (m *Repository) GetBoost() int64 {
+	if m != nil && m.Boost != nil {
+		return *m.Boost
+	}
+	return 0
+}
+
+func (m *Repository) GetNotifiedAt() int64 {
+	if m != nil && m.NotifiedAt != nil {
+		return *m.NotifiedAt
+	}
+	return 0
+}
+
+func (m *Repository) GetLabels() []string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Repository) GetLicense() string {
+	if m != nil && m.License != nil {
+		return *m.License
+	}
+	return ""
+}
+
 type Message struct {
 	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
 	Text             *string `protobuf:"bytes,2,req" json:"Text,omitempty"`
+	URL              *string `protobuf:"bytes,3,opt" json:"URL,omitempty"`
+	Author           *string `protobuf:"bytes,4,opt" json:"Author,omitempty"`
+	Time             *int64  `protobuf:"varint,5,opt" json:"Time,omitempty"`
+	AuthorFollowers  *int64  `protobuf:"varint,6,opt" json:"AuthorFollowers,omitempty"`
+	AuthorCreatedAt  *int64  `protobuf:"varint,7,opt" json:"AuthorCreatedAt,omitempty"`
+	Client           *string `protobuf:"bytes,8,opt" json:"Client,omitempty"`
+	Source           *string `protobuf:"bytes,9,opt" json:"Source,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -93,5 +189,758 @@ func (m *Message) GetText() string {
 	return ""
 }
 
+func (m *Message) GetURL() string {
+	if m != nil && m.URL != nil {
+		return *m.URL
+	}
+	return ""
+}
+
+func (m *Message) GetAuthor() string {
+	if m != nil && m.Author != nil {
+		return *m.Author
+	}
+	return ""
+}
+
+func (m *Message) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *Message) GetAuthorFollowers() int64 {
+	if m != nil && m.AuthorFollowers != nil {
+		return *m.AuthorFollowers
+	}
+	return 0
+}
+
+func (m *Message) GetAuthorCreatedAt() int64 {
+	if m != nil && m.AuthorCreatedAt != nil {
+		return *m.AuthorCreatedAt
+	}
+	return 0
+}
+
+func (m *Message) GetClient() string {
+	if m != nil && m.Client != nil {
+		return *m.Client
+	}
+	return ""
+}
+
+func (m *Message) GetSource() string {
+	if m != nil && m.Source != nil {
+		return *m.Source
+	}
+	return ""
+}
+
+type PendingNotification struct {
+	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
+	Username         *string `protobuf:"bytes,2,req" json:"Username,omitempty"`
+	RepositoryID     *string `protobuf:"bytes,3,req" json:"RepositoryID,omitempty"`
+	Text             *string `protobuf:"bytes,4,req" json:"Text,omitempty"`
+	Approved         *bool   `protobuf:"varint,5,req" json:"Approved,omitempty"`
+	CreatedAt        *int64  `protobuf:"varint,6,req" json:"CreatedAt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *PendingNotification) Reset()         { *m = PendingNotification{} }
+func (m *PendingNotification) String() string { return proto.CompactTextString(m) }
+func (*PendingNotification) ProtoMessage()    {}
+
+func (m *PendingNotification) GetID() uint64 {
+	if m != nil && m.ID != nil {
+		return *m.ID
+	}
+	return 0
+}
+
+func (m *PendingNotification) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *PendingNotification) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *PendingNotification) GetText() string {
+	if m != nil && m.Text != nil {
+		return *m.Text
+	}
+	return ""
+}
+
+func (m *PendingNotification) GetApproved() bool {
+	if m != nil && m.Approved != nil {
+		return *m.Approved
+	}
+	return false
+}
+
+func (m *PendingNotification) GetCreatedAt() int64 {
+	if m != nil && m.CreatedAt != nil {
+		return *m.CreatedAt
+	}
+	return 0
+}
+
+type Feature struct {
+	RepositoryID     *string `protobuf:"bytes,1,req" json:"RepositoryID,omitempty"`
+	Username         *string `protobuf:"bytes,2,req" json:"Username,omitempty"`
+	Text             *string `protobuf:"bytes,3,req" json:"Text,omitempty"`
+	Time             *int64  `protobuf:"varint,4,req" json:"Time,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Feature) Reset()         { *m = Feature{} }
+func (m *Feature) String() string { return proto.CompactTextString(m) }
+func (*Feature) ProtoMessage()    {}
+
+func (m *Feature) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *Feature) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Feature) GetText() string {
+	if m != nil && m.Text != nil {
+		return *m.Text
+	}
+	return ""
+}
+
+func (m *Feature) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+type Notification struct {
+	ID               *uint64 `protobuf:"varint,1,req" json:"ID,omitempty"`
+	Username         *string `protobuf:"bytes,2,req" json:"Username,omitempty"`
+	RepositoryID     *string `protobuf:"bytes,3,req" json:"RepositoryID,omitempty"`
+	Text             *string `protobuf:"bytes,4,req" json:"Text,omitempty"`
+	MessageID        *uint64 `protobuf:"varint,5,req" json:"MessageID,omitempty"`
+	URL              *string `protobuf:"bytes,6,req" json:"URL,omitempty"`
+	Time             *int64  `protobuf:"varint,7,req" json:"Time,omitempty"`
+	Success          *bool   `protobuf:"varint,8,req" json:"Success,omitempty"`
+	Error            *string `protobuf:"bytes,9,req" json:"Error,omitempty"`
+	Likes            *int64  `protobuf:"varint,10,opt" json:"Likes,omitempty"`
+	Retweets         *int64  `protobuf:"varint,11,opt" json:"Retweets,omitempty"`
+	Impressions      *int64  `protobuf:"varint,12,opt" json:"Impressions,omitempty"`
+	EngagementTime   *int64  `protobuf:"varint,13,opt" json:"EngagementTime,omitempty"`
+	Template         *string `protobuf:"bytes,14,opt" json:"Template,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Notification) Reset()         { *m = Notification{} }
+func (m *Notification) String() string { return proto.CompactTextString(m) }
+func (*Notification) ProtoMessage()    {}
+
+func (m *Notification) GetID() uint64 {
+	if m != nil && m.ID != nil {
+		return *m.ID
+	}
+	return 0
+}
+
+func (m *Notification) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Notification) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *Notification) GetText() string {
+	if m != nil && m.Text != nil {
+		return *m.Text
+	}
+	return ""
+}
+
+func (m *Notification) GetMessageID() uint64 {
+	if m != nil && m.MessageID != nil {
+		return *m.MessageID
+	}
+	return 0
+}
+
+func (m *Notification) GetURL() string {
+	if m != nil && m.URL != nil {
+		return *m.URL
+	}
+	return ""
+}
+
+func (m *Notification) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *Notification) GetSuccess() bool {
+	if m != nil && m.Success != nil {
+		return *m.Success
+	}
+	return false
+}
+
+func (m *Notification) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *Notification) GetLikes() int64 {
+	if m != nil && m.Likes != nil {
+		return *m.Likes
+	}
+	return 0
+}
+
+func (m *Notification) GetRetweets() int64 {
+	if m != nil && m.Retweets != nil {
+		return *m.Retweets
+	}
+	return 0
+}
+
+func (m *Notification) GetImpressions() int64 {
+	if m != nil && m.Impressions != nil {
+		return *m.Impressions
+	}
+	return 0
+}
+
+func (m *Notification) GetEngagementTime() int64 {
+	if m != nil && m.EngagementTime != nil {
+		return *m.EngagementTime
+	}
+	return 0
+}
+
+func (m *Notification) GetTemplate() string {
+	if m != nil && m.Template != nil {
+		return *m.Template
+	}
+	return ""
+}
+
+type RepositoryMessages struct {
+	RepositoryID     *string    `protobuf:"bytes,1,req" json:"RepositoryID,omitempty"`
+	Messages         []*Message `protobuf:"bytes,2,rep" json:"Messages,omitempty"`
+	XXX_unrecognized []byte     `json:"-"`
+}
+
+func (m *RepositoryMessages) Reset()         { *m = RepositoryMessages{} }
+func (m *RepositoryMessages) String() string { return proto.CompactTextString(m) }
+func (*RepositoryMessages) ProtoMessage()    {}
+
+func (m *RepositoryMessages) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *RepositoryMessages) GetMessages() []*Message {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
+type RateLimit struct {
+	Resource         *string `protobuf:"bytes,1,req" json:"Resource,omitempty"`
+	Limit            *int64  `protobuf:"varint,2,req" json:"Limit,omitempty"`
+	Remaining        *int64  `protobuf:"varint,3,req" json:"Remaining,omitempty"`
+	Reset_           *int64  `protobuf:"varint,4,req,name=Reset" json:"Reset,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RateLimit) Reset()         { *m = RateLimit{} }
+func (m *RateLimit) String() string { return proto.CompactTextString(m) }
+func (*RateLimit) ProtoMessage()    {}
+
+func (m *RateLimit) GetResource() string {
+	if m != nil && m.Resource != nil {
+		return *m.Resource
+	}
+	return ""
+}
+
+func (m *RateLimit) GetLimit() int64 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+func (m *RateLimit) GetRemaining() int64 {
+	if m != nil && m.Remaining != nil {
+		return *m.Remaining
+	}
+	return 0
+}
+
+func (m *RateLimit) GetReset_() int64 {
+	if m != nil && m.Reset_ != nil {
+		return *m.Reset_
+	}
+	return 0
+}
+
+type RateLimits struct {
+	RateLimits       []*RateLimit `protobuf:"bytes,1,rep" json:"RateLimits,omitempty"`
+	XXX_unrecognized []byte       `json:"-"`
+}
+
+func (m *RateLimits) Reset()         { *m = RateLimits{} }
+func (m *RateLimits) String() string { return proto.CompactTextString(m) }
+func (*RateLimits) ProtoMessage()    {}
+
+func (m *RateLimits) GetRateLimits() []*RateLimit {
+	if m != nil {
+		return m.RateLimits
+	}
+	return nil
+}
+
+type Account struct {
+	Username         *string `protobuf:"bytes,1,req" json:"Username,omitempty"`
+	LastNotifyTime   *int64  `protobuf:"varint,2,opt" json:"LastNotifyTime,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Account) Reset()         { *m = Account{} }
+func (m *Account) String() string { return proto.CompactTextString(m) }
+func (*Account) ProtoMessage()    {}
+
+func (m *Account) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Account) GetLastNotifyTime() int64 {
+	if m != nil && m.LastNotifyTime != nil {
+		return *m.LastNotifyTime
+	}
+	return 0
+}
+
+type OAuth2Token struct {
+	AccessToken      *string `protobuf:"bytes,1,req" json:"AccessToken,omitempty"`
+	RefreshToken     *string `protobuf:"bytes,2,req" json:"RefreshToken,omitempty"`
+	Expiry           *int64  `protobuf:"varint,3,req" json:"Expiry,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *OAuth2Token) Reset()         { *m = OAuth2Token{} }
+func (m *OAuth2Token) String() string { return proto.CompactTextString(m) }
+func (*OAuth2Token) ProtoMessage()    {}
+
+func (m *OAuth2Token) GetAccessToken() string {
+	if m != nil && m.AccessToken != nil {
+		return *m.AccessToken
+	}
+	return ""
+}
+
+func (m *OAuth2Token) GetRefreshToken() string {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return ""
+}
+
+func (m *OAuth2Token) GetExpiry() int64 {
+	if m != nil && m.Expiry != nil {
+		return *m.Expiry
+	}
+	return 0
+}
+
+type FlaggedRepository struct {
+	RepositoryID     *string `protobuf:"bytes,1,req" json:"RepositoryID,omitempty"`
+	Reason           *string `protobuf:"bytes,2,req" json:"Reason,omitempty"`
+	Approved         *bool   `protobuf:"varint,3,req" json:"Approved,omitempty"`
+	CreatedAt        *int64  `protobuf:"varint,4,req" json:"CreatedAt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *FlaggedRepository) Reset()         { *m = FlaggedRepository{} }
+func (m *FlaggedRepository) String() string { return proto.CompactTextString(m) }
+func (*FlaggedRepository) ProtoMessage()    {}
+
+func (m *FlaggedRepository) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *FlaggedRepository) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func (m *FlaggedRepository) GetApproved() bool {
+	if m != nil && m.Approved != nil {
+		return *m.Approved
+	}
+	return false
+}
+
+func (m *FlaggedRepository) GetCreatedAt() int64 {
+	if m != nil && m.CreatedAt != nil {
+		return *m.CreatedAt
+	}
+	return 0
+}
+
+type OptOut struct {
+	Pattern          *string `protobuf:"bytes,1,req" json:"Pattern,omitempty"`
+	Reason           *string `protobuf:"bytes,2,req" json:"Reason,omitempty"`
+	CreatedAt        *int64  `protobuf:"varint,3,req" json:"CreatedAt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *OptOut) Reset()         { *m = OptOut{} }
+func (m *OptOut) String() string { return proto.CompactTextString(m) }
+func (*OptOut) ProtoMessage()    {}
+
+func (m *OptOut) GetPattern() string {
+	if m != nil && m.Pattern != nil {
+		return *m.Pattern
+	}
+	return ""
+}
+
+func (m *OptOut) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func (m *OptOut) GetCreatedAt() int64 {
+	if m != nil && m.CreatedAt != nil {
+		return *m.CreatedAt
+	}
+	return 0
+}
+
+type RetryMessage struct {
+	RepositoryID     *string  `protobuf:"bytes,1,req" json:"RepositoryID,omitempty"`
+	Message          *Message `protobuf:"bytes,2,req" json:"Message,omitempty"`
+	Attempts         *int64   `protobuf:"varint,3,req" json:"Attempts,omitempty"`
+	NextAttemptAt    *int64   `protobuf:"varint,4,req" json:"NextAttemptAt,omitempty"`
+	Error            *string  `protobuf:"bytes,5,req" json:"Error,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *RetryMessage) Reset()         { *m = RetryMessage{} }
+func (m *RetryMessage) String() string { return proto.CompactTextString(m) }
+func (*RetryMessage) ProtoMessage()    {}
+
+func (m *RetryMessage) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *RetryMessage) GetMessage() *Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *RetryMessage) GetAttempts() int64 {
+	if m != nil && m.Attempts != nil {
+		return *m.Attempts
+	}
+	return 0
+}
+
+func (m *RetryMessage) GetNextAttemptAt() int64 {
+	if m != nil && m.NextAttemptAt != nil {
+		return *m.NextAttemptAt
+	}
+	return 0
+}
+
+func (m *RetryMessage) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+type Snapshot struct {
+	Date             *int64           `protobuf:"varint,1,req" json:"Date,omitempty"`
+	Entries          []*SnapshotEntry `protobuf:"bytes,2,rep" json:"Entries,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+func (m *Snapshot) GetDate() int64 {
+	if m != nil && m.Date != nil {
+		return *m.Date
+	}
+	return 0
+}
+
+func (m *Snapshot) GetEntries() []*SnapshotEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type SnapshotEntry struct {
+	Language         *string `protobuf:"bytes,1,req" json:"Language,omitempty"`
+	RepositoryID     *string `protobuf:"bytes,2,req" json:"RepositoryID,omitempty"`
+	Mentions         *int64  `protobuf:"varint,3,req" json:"Mentions,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SnapshotEntry) Reset()         { *m = SnapshotEntry{} }
+func (m *SnapshotEntry) String() string { return proto.CompactTextString(m) }
+func (*SnapshotEntry) ProtoMessage()    {}
+
+func (m *SnapshotEntry) GetLanguage() string {
+	if m != nil && m.Language != nil {
+		return *m.Language
+	}
+	return ""
+}
+
+func (m *SnapshotEntry) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *SnapshotEntry) GetMentions() int64 {
+	if m != nil && m.Mentions != nil {
+		return *m.Mentions
+	}
+	return 0
+}
+
 func init() {
 }
+
+type RepositoriesRequest struct {
+	Limit            *int64  `protobuf:"varint,1,opt" json:"Limit,omitempty"`
+	After            *string `protobuf:"bytes,2,opt" json:"After,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RepositoriesRequest) Reset()         { *m = RepositoriesRequest{} }
+func (m *RepositoriesRequest) String() string { return proto.CompactTextString(m) }
+func (*RepositoriesRequest) ProtoMessage()    {}
+
+func (m *RepositoriesRequest) GetLimit() int64 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+func (m *RepositoriesRequest) GetAfter() string {
+	if m != nil && m.After != nil {
+		return *m.After
+	}
+	return ""
+}
+
+type RepositoriesResponse struct {
+	Repositories     []*Repository `protobuf:"bytes,1,rep" json:"Repositories,omitempty"`
+	XXX_unrecognized []byte        `json:"-"`
+}
+
+func (m *RepositoriesResponse) Reset()         { *m = RepositoriesResponse{} }
+func (m *RepositoriesResponse) String() string { return proto.CompactTextString(m) }
+func (*RepositoriesResponse) ProtoMessage()    {}
+
+func (m *RepositoriesResponse) GetRepositories() []*Repository {
+	if m != nil {
+		return m.Repositories
+	}
+	return nil
+}
+
+type TopRepositoriesRequest struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *TopRepositoriesRequest) Reset()         { *m = TopRepositoriesRequest{} }
+func (m *TopRepositoriesRequest) String() string { return proto.CompactTextString(m) }
+func (*TopRepositoriesRequest) ProtoMessage()    {}
+
+type TopRepository struct {
+	Key              *string     `protobuf:"bytes,1,req" json:"Key,omitempty"`
+	Repository       *Repository `protobuf:"bytes,2,req" json:"Repository,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *TopRepository) Reset()         { *m = TopRepository{} }
+func (m *TopRepository) String() string { return proto.CompactTextString(m) }
+func (*TopRepository) ProtoMessage()    {}
+
+func (m *TopRepository) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *TopRepository) GetRepository() *Repository {
+	if m != nil {
+		return m.Repository
+	}
+	return nil
+}
+
+type TopRepositoriesResponse struct {
+	Repositories     []*TopRepository `protobuf:"bytes,1,rep" json:"Repositories,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *TopRepositoriesResponse) Reset()         { *m = TopRepositoriesResponse{} }
+func (m *TopRepositoriesResponse) String() string { return proto.CompactTextString(m) }
+func (*TopRepositoriesResponse) ProtoMessage()    {}
+
+func (m *TopRepositoriesResponse) GetRepositories() []*TopRepository {
+	if m != nil {
+		return m.Repositories
+	}
+	return nil
+}
+
+type AddMessageRequest struct {
+	RepositoryID     *string  `protobuf:"bytes,1,req" json:"RepositoryID,omitempty"`
+	Message          *Message `protobuf:"bytes,2,req" json:"Message,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *AddMessageRequest) Reset()         { *m = AddMessageRequest{} }
+func (m *AddMessageRequest) String() string { return proto.CompactTextString(m) }
+func (*AddMessageRequest) ProtoMessage()    {}
+
+func (m *AddMessageRequest) GetRepositoryID() string {
+	if m != nil && m.RepositoryID != nil {
+		return *m.RepositoryID
+	}
+	return ""
+}
+
+func (m *AddMessageRequest) GetMessage() *Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+type AddMessageResponse struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *AddMessageResponse) Reset()         { *m = AddMessageResponse{} }
+func (m *AddMessageResponse) String() string { return proto.CompactTextString(m) }
+func (*AddMessageResponse) ProtoMessage()    {}
+
+type BlacklistRequest struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *BlacklistRequest) Reset()         { *m = BlacklistRequest{} }
+func (m *BlacklistRequest) String() string { return proto.CompactTextString(m) }
+func (*BlacklistRequest) ProtoMessage()    {}
+
+type BlacklistResponse struct {
+	Patterns         []string `protobuf:"bytes,1,rep" json:"Patterns,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *BlacklistResponse) Reset()         { *m = BlacklistResponse{} }
+func (m *BlacklistResponse) String() string { return proto.CompactTextString(m) }
+func (*BlacklistResponse) ProtoMessage()    {}
+
+func (m *BlacklistResponse) GetPatterns() []string {
+	if m != nil {
+		return m.Patterns
+	}
+	return nil
+}
+
+type NotificationHistoryRequest struct {
+	N                *int64 `protobuf:"varint,1,opt" json:"N,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *NotificationHistoryRequest) Reset()         { *m = NotificationHistoryRequest{} }
+func (m *NotificationHistoryRequest) String() string { return proto.CompactTextString(m) }
+func (*NotificationHistoryRequest) ProtoMessage()    {}
+
+func (m *NotificationHistoryRequest) GetN() int64 {
+	if m != nil && m.N != nil {
+		return *m.N
+	}
+	return 0
+}
+
+type NotificationHistoryResponse struct {
+	Notifications    []*Notification `protobuf:"bytes,1,rep" json:"Notifications,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
+}
+
+func (m *NotificationHistoryResponse) Reset()         { *m = NotificationHistoryResponse{} }
+func (m *NotificationHistoryResponse) String() string { return proto.CompactTextString(m) }
+func (*NotificationHistoryResponse) ProtoMessage()    {}
+
+func (m *NotificationHistoryResponse) GetNotifications() []*Notification {
+	if m != nil {
+		return m.Notifications
+	}
+	return nil
+}
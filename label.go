@@ -0,0 +1,57 @@
+package scuttlebutt
+
+// Curation labels that change how repositories are ranked. Other labels
+// are only informational.
+const (
+	// LabelFeatured pins a repository above unpinned repositories.
+	LabelFeatured = "featured"
+
+	// LabelNever excludes a repository from rankings.
+	LabelNever = "never"
+
+	// LabelNeedsReview excludes a repository from rankings until the
+	// label is removed.
+	LabelNeedsReview = "needs-review"
+)
+
+// MaxLabelLen is the maximum length of a curation label.
+const MaxLabelLen = 32
+
+// ValidLabel returns true if label only contains lowercase letters, digits,
+// and hyphens and is no longer than MaxLabelLen.
+func ValidLabel(label string) bool {
+	if label == "" || len(label) > MaxLabelLen {
+		return false
+	}
+	for _, ch := range label {
+		if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') && ch != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// HasLabel returns true if the repository has the curation label.
+func (r *Repository) HasLabel(label string) bool { return hasLabel(r.Labels, label) }
+
+// Pinned returns true if the repository ranks above unpinned repositories.
+func (r *Repository) Pinned() bool { return r.HasLabel(LabelFeatured) }
+
+// hasLabel returns true if labels contains label.
+func hasLabel(labels []string, label string) bool {
+	for _, other := range labels {
+		if other == label {
+			return true
+		}
+	}
+	return false
+}
+
+// rankedAbove returns true if other ranks above r when mentions are weighted
+// by source.
+func rankedAbove(other, r *Repository, weights map[string]float64) bool {
+	if other.Pinned() != r.Pinned() {
+		return other.Pinned()
+	}
+	return other.RankedScore(weights) > r.RankedScore(weights)
+}
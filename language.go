@@ -0,0 +1,53 @@
+package scuttlebutt
+
+import (
+	"strings"
+)
+
+// DefaultLanguageAliases maps community names & variant labels for languages
+// to the names reported by GitHub.
+var DefaultLanguageAliases = map[string]string{
+	"golang":           "Go",
+	"js":               "JavaScript",
+	"node":             "JavaScript",
+	"nodejs":           "JavaScript",
+	"ts":               "TypeScript",
+	"py":               "Python",
+	"python3":          "Python",
+	"jupyter notebook": "Python",
+	"rb":               "Ruby",
+	"rs":               "Rust",
+	"cpp":              "C++",
+	"csharp":           "C#",
+	"objc":             "Objective-C",
+}
+
+// LanguageAliases represents a normalization table from lowercase language
+// names to canonical names. Languages without an entry are unchanged.
+type LanguageAliases map[string]string
+
+// NewLanguageAliases returns a normalization table from one or more alias
+// maps. Later maps override earlier ones. Canonical names also normalize
+// case insensitively to themselves so "go" and "Go" are grouped together.
+func NewLanguageAliases(tables ...map[string]string) LanguageAliases {
+	m := make(LanguageAliases)
+	for _, table := range tables {
+		for _, name := range table {
+			m[strings.ToLower(name)] = name
+		}
+	}
+	for _, table := range tables {
+		for alias, name := range table {
+			m[strings.ToLower(alias)] = name
+		}
+	}
+	return m
+}
+
+// Normalize returns the canonical name for a language.
+func (m LanguageAliases) Normalize(lang string) string {
+	if name, ok := m[strings.ToLower(strings.TrimSpace(lang))]; ok {
+		return name
+	}
+	return lang
+}
@@ -0,0 +1,33 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure languages are normalized using aliases and canonical names.
+func TestLanguageAliases_Normalize(t *testing.T) {
+	m := scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases, map[string]string{"TS": "TS Override"})
+	for _, tt := range []struct {
+		lang string
+		name string
+	}{
+		{lang: "golang", name: "Go"},
+		{lang: "go", name: "Go"},
+		{lang: " GO ", name: "Go"},
+		{lang: "Jupyter Notebook", name: "Python"},
+		{lang: "ts", name: "TS Override"},
+		{lang: "Elixir", name: "Elixir"},
+		{lang: "", name: ""},
+	} {
+		if name := m.Normalize(tt.lang); name != tt.name {
+			t.Errorf("%q: unexpected name: %q", tt.lang, name)
+		}
+	}
+
+	// A nil table leaves languages unchanged.
+	if name := scuttlebutt.LanguageAliases(nil).Normalize("golang"); name != "golang" {
+		t.Fatalf("unexpected name: %q", name)
+	}
+}
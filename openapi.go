@@ -0,0 +1,482 @@
+package scuttlebutt
+
+// OpenAPISpec is the OpenAPI 3 document describing the JSON API. It is served
+// at /openapi.json and mirrored by the client package.
+const OpenAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "scuttlebutt",
+    "description": "Repositories mentioned on social media, ranked by mentions.",
+    "version": "1"
+  },
+  "paths": {
+    "/ping": {
+      "get": {
+        "operationId": "ping",
+        "summary": "Verifies that the server & store are working.",
+        "responses": {
+          "200": {"description": "OK", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/top": {
+      "get": {
+        "operationId": "topRepositories",
+        "summary": "Returns the top unnotified repository for each language & topic, sorted by key.",
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/TopRepository"}}}}
+          },
+          "304": {"description": "Not modified since the ETag in If-None-Match."},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/top/overall": {
+      "get": {
+        "operationId": "topRepositoriesOverall",
+        "summary": "Returns the top repositories across all languages, ranked by score.",
+        "parameters": [
+          {"name": "n", "in": "query", "description": "Number of repositories.", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 25}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/RankedRepository"}}}}
+          },
+          "304": {"description": "Not modified since the ETag in If-None-Match."},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/top/recent": {
+      "get": {
+        "operationId": "recentTopRepositories",
+        "summary": "Returns the top unnotified repositories ranked by their mentions within a window.",
+        "parameters": [
+          {"name": "language", "in": "query", "description": "Only include repositories in this language.", "schema": {"type": "string"}},
+          {"name": "n", "in": "query", "description": "Number of repositories.", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 25}},
+          {"name": "window", "in": "query", "description": "Duration over which mentions are counted, such as 24h.", "schema": {"type": "string", "default": "24h"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/RecentRepository"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/repositories/{id}": {
+      "get": {
+        "operationId": "repository",
+        "summary": "Returns a repository & its most recent messages.",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "description": "Repository ID including its host, such as github.com/user/repo. Slashes are not escaped.", "schema": {"type": "string"}},
+          {"name": "messages", "in": "query", "description": "Number of messages.", "schema": {"type": "integer", "minimum": 0, "maximum": 1000, "default": 20}},
+          {"name": "order", "in": "query", "description": "Message order by ID.", "schema": {"type": "string", "enum": ["desc", "asc"], "default": "desc"}}
+        ],
+        "responses": {
+          "200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Repository"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/repositories/{id}/timeseries": {
+      "get": {
+        "operationId": "mentionTimeSeries",
+        "summary": "Returns a repository's mentions per UTC day, oldest first.",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "description": "Repository ID including its host, such as github.com/user/repo. Slashes are not escaped.", "schema": {"type": "string"}},
+          {"name": "days", "in": "query", "description": "Number of days, including today.", "schema": {"type": "integer", "minimum": 1, "maximum": 366, "default": 30}}
+        ],
+        "responses": {
+          "200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MentionTimeSeries"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/notifications": {
+      "get": {
+        "operationId": "notifications",
+        "summary": "Returns the most recent notification attempts, newest first.",
+        "parameters": [
+          {"name": "n", "in": "query", "description": "Number of attempts.", "schema": {"type": "integer", "minimum": 1, "maximum": 10000, "default": 100}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Notification"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/notifications/templates": {
+      "get": {
+        "operationId": "templateStats",
+        "summary": "Returns the engagement with each account's template variants.",
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/TemplateStats"}}}}
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/languages": {
+      "get": {
+        "operationId": "languages",
+        "summary": "Returns repository & mention counts for each language seen, most mentioned first.",
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Language"}}}}
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/search": {
+      "get": {
+        "operationId": "search",
+        "summary": "Returns repositories whose ID, description, or messages contain every search term.",
+        "parameters": [
+          {"name": "q", "in": "query", "required": true, "description": "Search terms.", "schema": {"type": "string"}},
+          {"name": "n", "in": "query", "description": "Number of results.", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/SearchResult"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/history": {
+      "get": {
+        "operationId": "history",
+        "summary": "Returns daily snapshots of the top repositories per language, oldest first.",
+        "parameters": [
+          {"name": "language", "in": "query", "description": "Only include rankings for this language.", "schema": {"type": "string"}},
+          {"name": "days", "in": "query", "description": "Number of days, including today.", "schema": {"type": "integer", "minimum": 1, "maximum": 366, "default": 30}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Snapshot"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/ingest": {
+      "post": {
+        "operationId": "ingest",
+        "summary": "Saves messages pushed by an external source. Messages that do not link to a repository are ignored.",
+        "security": [{"bearer": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "array", "maxItems": 1000, "items": {"$ref": "#/components/schemas/IngestMessage"}}}}
+        },
+        "responses": {
+          "200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IngestResult"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"description": "Ingestion is disabled."},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/messages/stream": {
+      "get": {
+        "operationId": "messageStream",
+        "summary": "Streams newly saved messages as server-sent \"message_added\" events.",
+        "parameters": [
+          {"name": "language", "in": "query", "description": "Only stream messages for repositories in this language.", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/MessageAdded"}}}
+          },
+          "404": {"description": "Streaming is disabled."}
+        }
+      }
+    },
+    "/api/v1/short_urls": {
+      "get": {
+        "operationId": "shortURLs",
+        "summary": "Returns all shortened repository URLs.",
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/ShortURL"}}}}
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"}
+    },
+    "responses": {
+      "Error": {"description": "Error message.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+    },
+    "schemas": {
+      "TopRepository": {
+        "type": "object",
+        "required": ["key", "id", "name", "url", "description", "language", "stars", "forks", "mentions"],
+        "properties": {
+          "key": {"type": "string", "description": "Normalized language or topic key."},
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "url": {"type": "string"},
+          "description": {"type": "string"},
+          "language": {"type": "string"},
+          "topics": {"type": "array", "items": {"type": "string"}},
+          "labels": {"type": "array", "items": {"type": "string"}, "description": "Curation labels, such as \"featured\"."},
+          "stars": {"type": "integer"},
+          "forks": {"type": "integer"},
+          "mentions": {"type": "integer"}
+        }
+      },
+      "RankedRepository": {
+        "type": "object",
+        "required": ["rank", "id", "name", "url", "description", "language", "stars", "forks", "mentions", "score"],
+        "properties": {
+          "rank": {"type": "integer"},
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "url": {"type": "string"},
+          "description": {"type": "string"},
+          "language": {"type": "string"},
+          "topics": {"type": "array", "items": {"type": "string"}},
+          "labels": {"type": "array", "items": {"type": "string"}, "description": "Curation labels, such as \"featured\"."},
+          "stars": {"type": "integer"},
+          "forks": {"type": "integer"},
+          "mentions": {"type": "integer"},
+          "score": {"type": "number"}
+        }
+      },
+      "RecentRepository": {
+        "type": "object",
+        "required": ["rank", "id", "name", "url", "description", "language", "stars", "forks", "mentions"],
+        "properties": {
+          "rank": {"type": "integer"},
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "url": {"type": "string"},
+          "description": {"type": "string"},
+          "language": {"type": "string"},
+          "topics": {"type": "array", "items": {"type": "string"}},
+          "labels": {"type": "array", "items": {"type": "string"}, "description": "Curation labels, such as \"featured\"."},
+          "stars": {"type": "integer"},
+          "forks": {"type": "integer"},
+          "mentions": {"type": "integer", "description": "Mentions within the window."},
+          "boost": {"type": "integer"}
+        }
+      },
+      "Repository": {
+        "type": "object",
+        "required": ["id", "name", "url", "description", "language", "stars", "forks", "notified", "mentions", "messages"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "url": {"type": "string"},
+          "description": {"type": "string"},
+          "language": {"type": "string"},
+          "topics": {"type": "array", "items": {"type": "string"}},
+          "labels": {"type": "array", "items": {"type": "string"}, "description": "Curation labels, such as \"featured\"."},
+          "stars": {"type": "integer"},
+          "forks": {"type": "integer"},
+          "notified": {"type": "boolean"},
+          "fork": {"type": "boolean"},
+          "archived": {"type": "boolean"},
+          "disabled": {"type": "boolean"},
+          "license": {"type": "string", "description": "License key, such as \"mit\". Omitted if unknown."},
+          "boost": {"type": "integer", "description": "Mentions added when ranking."},
+          "mentions": {"type": "integer", "description": "Total number of messages."},
+          "sources": {"type": "object", "additionalProperties": {"type": "integer"}, "description": "Total number of messages by source, such as \"twitter\"."},
+          "messages": {"type": "array", "items": {"$ref": "#/components/schemas/Message"}}
+        }
+      },
+      "Message": {
+        "type": "object",
+        "required": ["id", "text"],
+        "properties": {
+          "id": {"type": "string", "description": "Decimal message ID."},
+          "text": {"type": "string"},
+          "url": {"type": "string"}
+        }
+      },
+      "MessageAdded": {
+        "type": "object",
+        "required": ["id", "repository_id", "text"],
+        "properties": {
+          "id": {"type": "string", "description": "Decimal message ID."},
+          "repository_id": {"type": "string"},
+          "language": {"type": "string"},
+          "text": {"type": "string"},
+          "url": {"type": "string"},
+          "author": {"type": "string"}
+        }
+      },
+      "Notification": {
+        "type": "object",
+        "required": ["id", "username", "text", "time", "success"],
+        "properties": {
+          "id": {"type": "string", "description": "Decimal attempt ID."},
+          "username": {"type": "string"},
+          "repository_id": {"type": "string"},
+          "text": {"type": "string"},
+          "message_id": {"type": "string", "description": "Decimal ID of the sent message."},
+          "url": {"type": "string"},
+          "time": {"type": "string", "format": "date-time"},
+          "success": {"type": "boolean"},
+          "error": {"type": "string"},
+          "template": {"type": "string", "description": "Name of the template variant used."},
+          "engagement": {"$ref": "#/components/schemas/Engagement"}
+        }
+      },
+      "Engagement": {
+        "type": "object",
+        "required": ["likes", "retweets", "impressions", "time"],
+        "properties": {
+          "likes": {"type": "integer"},
+          "retweets": {"type": "integer"},
+          "impressions": {"type": "integer"},
+          "time": {"type": "string", "format": "date-time", "description": "Time the counts were fetched."}
+        }
+      },
+      "TemplateStats": {
+        "type": "object",
+        "required": ["username", "template", "notifications", "measured", "likes", "retweets", "impressions", "avg_likes", "avg_retweets", "avg_impressions"],
+        "properties": {
+          "username": {"type": "string"},
+          "template": {"type": "string"},
+          "notifications": {"type": "integer", "description": "Successful notifications sent with the template."},
+          "measured": {"type": "integer", "description": "Notifications with fetched engagement."},
+          "likes": {"type": "integer"},
+          "retweets": {"type": "integer"},
+          "impressions": {"type": "integer"},
+          "avg_likes": {"type": "number"},
+          "avg_retweets": {"type": "number"},
+          "avg_impressions": {"type": "number"}
+        }
+      },
+      "Language": {
+        "type": "object",
+        "required": ["language", "repositories", "mentions", "recent_mentions", "has_account"],
+        "properties": {
+          "language": {"type": "string"},
+          "repositories": {"type": "integer"},
+          "mentions": {"type": "integer"},
+          "recent_mentions": {"type": "integer", "description": "Mentions posted in the last 24 hours."},
+          "has_account": {"type": "boolean", "description": "True if an account notifies for the language."},
+          "accounts": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "SearchResult": {
+        "type": "object",
+        "required": ["id", "name", "url", "description", "language", "notified", "mentions", "matched", "messages"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "url": {"type": "string"},
+          "description": {"type": "string"},
+          "language": {"type": "string"},
+          "notified": {"type": "boolean"},
+          "mentions": {"type": "integer"},
+          "matched": {"type": "boolean", "description": "True if the repository's ID or description matched."},
+          "messages": {"type": "array", "description": "Messages that matched.", "items": {"$ref": "#/components/schemas/Message"}}
+        }
+      },
+      "MentionTimeSeries": {
+        "type": "object",
+        "required": ["repository_id", "days"],
+        "properties": {
+          "repository_id": {"type": "string"},
+          "days": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["date", "mentions"],
+              "properties": {
+                "date": {"type": "string", "format": "date-time", "description": "Start of the UTC day."},
+                "mentions": {"type": "integer"}
+              }
+            }
+          },
+          "undated": {"type": "integer", "description": "Number of mentions stored without a posted time."}
+        }
+      },
+      "Snapshot": {
+        "type": "object",
+        "required": ["date", "languages"],
+        "properties": {
+          "date": {"type": "string", "format": "date-time", "description": "Start of the UTC day."},
+          "languages": {
+            "type": "object",
+            "description": "Ranked repositories keyed by language.",
+            "additionalProperties": {"type": "array", "items": {"$ref": "#/components/schemas/SnapshotEntry"}}
+          }
+        }
+      },
+      "SnapshotEntry": {
+        "type": "object",
+        "required": ["rank", "repository_id", "mentions"],
+        "properties": {
+          "rank": {"type": "integer", "description": "One-based rank within the language."},
+          "repository_id": {"type": "string"},
+          "mentions": {"type": "integer"}
+        }
+      },
+      "IngestMessage": {
+        "type": "object",
+        "required": ["id", "text", "repository_url"],
+        "properties": {
+          "id": {"type": "string", "description": "Decimal message ID. Messages are deduplicated by ID."},
+          "text": {"type": "string"},
+          "repository_url": {"type": "string", "description": "Link to the mentioned repository."},
+          "url": {"type": "string", "description": "Link to the message."},
+          "author": {"type": "string"},
+          "timestamp": {"type": "string", "format": "date-time", "description": "Accepted but not stored."},
+          "source": {"type": "string", "description": "Name of the source. Health is reported per source."}
+        }
+      },
+      "IngestResult": {
+        "type": "object",
+        "required": ["accepted", "ignored"],
+        "properties": {
+          "accepted": {"type": "integer", "description": "Number of messages saved or queued."},
+          "ignored": {"type": "integer", "description": "Number of messages that did not link to a repository."}
+        }
+      },
+      "ShortURL": {
+        "type": "object",
+        "required": ["short", "long"],
+        "properties": {
+          "short": {"type": "string"},
+          "long": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,47 @@
+package scuttlebutt
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// OptOut represents a request from a repository owner not to be featured.
+//
+// Patterns are matched case insensitively against the "owner/name" portion
+// of repository IDs using path.Match syntax, such as "owner/*" or
+// "owner/repo". A pattern without a slash matches all of an owner's
+// repositories.
+type OptOut struct {
+	Pattern   string    `json:"pattern"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidOptOutPattern returns true if pattern is a valid opt-out pattern.
+func ValidOptOutPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	_, err := path.Match(optOutPattern(pattern), "")
+	return err == nil
+}
+
+// MatchOptOut returns true if the repository ID matches an opt-out pattern.
+func MatchOptOut(pattern, repositoryID string) bool {
+	segments := strings.SplitN(strings.ToLower(repositoryID), "/", 2)
+	if len(segments) != 2 {
+		return false
+	}
+	ok, _ := path.Match(optOutPattern(pattern), segments[1])
+	return ok
+}
+
+// optOutPattern returns the normalized form of an opt-out pattern.
+func optOutPattern(pattern string) string {
+	pattern = strings.ToLower(strings.Trim(pattern, "/"))
+	if !strings.Contains(pattern, "/") {
+		pattern += "/*"
+	}
+	return pattern
+}
@@ -0,0 +1,32 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure opt-out patterns match repository owners and names.
+func TestMatchOptOut(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		id      string
+		match   bool
+	}{
+		{pattern: "owner", id: "github.com/owner/repo", match: true},
+		{pattern: "Owner", id: "github.com/OWNER/Repo", match: true},
+		{pattern: "owner/repo", id: "github.com/owner/repo", match: true},
+		{pattern: "owner/repo", id: "github.com/owner/repo2", match: false},
+		{pattern: "owner/go-*", id: "github.com/owner/go-foo", match: true},
+		{pattern: "owner", id: "github.com/other/repo", match: false},
+		{pattern: "owner", id: "owner/repo", match: false},
+	} {
+		if match := scuttlebutt.MatchOptOut(tt.pattern, tt.id); match != tt.match {
+			t.Errorf("%s/%s: unexpected match: %v", tt.pattern, tt.id, match)
+		}
+	}
+
+	if scuttlebutt.ValidOptOutPattern("") || scuttlebutt.ValidOptOutPattern("owner/[") {
+		t.Fatal("expected invalid pattern")
+	}
+}
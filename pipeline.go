@@ -0,0 +1,329 @@
+package scuttlebutt
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/statsd"
+)
+
+const (
+	// DefaultPipelineQueueSize is the default number of messages that can
+	// wait to be resolved before sources are blocked.
+	DefaultPipelineQueueSize = 1000
+
+	// DefaultPipelineBatchSize is the default maximum number of messages
+	// resolved & written together.
+	DefaultPipelineBatchSize = 100
+
+	// DefaultPipelineBatchDelay is the default time the resolver waits for
+	// more messages before resolving a partial batch.
+	DefaultPipelineBatchDelay = 100 * time.Millisecond
+
+	// DefaultStageRestartDelay is the default time before a stage that
+	// panicked is restarted.
+	DefaultStageRestartDelay = time.Second
+)
+
+// Pipeline stage names.
+const (
+	StageResolver = "resolver"
+	StageWriter   = "writer"
+)
+
+// ErrPipelineClosed is returned when messages are enqueued on a pipeline
+// that is not open.
+var ErrPipelineClosed = errors.New("pipeline closed")
+
+// queueVars publishes the depth of each pipeline queue as expvars.
+var (
+	queueVars     = expvar.NewMap("queue")
+	queuedDepth   expvar.Int
+	resolvedDepth expvar.Int
+)
+
+func init() {
+	queueVars.Set("messages", &queuedDepth)
+	queueVars.Set("resolved", &resolvedDepth)
+}
+
+// Pipeline ingests messages in stages. Sources enqueue messages into a
+// bounded queue and are blocked while it is full. The resolver stage
+// retrieves unknown repositories in batches and the writer stage commits
+// resolved batches to the store.
+type Pipeline struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing chan struct{}
+	cancel  context.CancelFunc
+
+	// Queues & stage state. The resolver's partial batch is kept so it
+	// survives restarts.
+	qmu      sync.Mutex
+	queue    chan *Message
+	resolved chan *Resolution
+	pending  []*Message
+	restarts map[string]int
+
+	Store *Store
+
+	// Number of messages waiting to be resolved before sources block.
+	// Changes take effect the first time the pipeline is opened.
+	QueueSize int
+
+	// Maximum number of messages in a batch & the time to wait for a
+	// batch to fill before it is resolved.
+	BatchSize  int
+	BatchDelay time.Duration
+
+	// Time before a stage that panicked is restarted.
+	RestartDelay time.Duration
+
+	// Called by the writer stage with each written batch & the error for
+	// each message, in order.
+	Handler func(a []*Message, errs []error)
+
+	// Optional statsd client for queue depths.
+	Statsd *statsd.Client
+
+	// Destination for log output.
+	LogOutput io.Writer
+}
+
+// NewPipeline returns a new instance of Pipeline that writes to s.
+func NewPipeline(s *Store) *Pipeline {
+	return &Pipeline{
+		Store:        s,
+		QueueSize:    DefaultPipelineQueueSize,
+		BatchSize:    DefaultPipelineBatchSize,
+		BatchDelay:   DefaultPipelineBatchDelay,
+		RestartDelay: DefaultStageRestartDelay,
+		LogOutput:    ioutil.Discard,
+	}
+}
+
+// Open starts the resolver & writer stages. Messages left in the queue when
+// the pipeline was last closed are processed once it is reopened.
+func (p *Pipeline) Open() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closing != nil {
+		return nil
+	}
+	p.qmu.Lock()
+	if p.queue == nil {
+		p.queue = make(chan *Message, p.QueueSize)
+		p.resolved = make(chan *Resolution, 1)
+	}
+	p.qmu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.closing, p.cancel = make(chan struct{}), cancel
+	p.wg.Add(2)
+	go p.run(ctx, StageResolver, p.resolve)
+	go p.run(ctx, StageWriter, p.write)
+	return nil
+}
+
+// Close stops the stages. Resolved batches are written before Close returns
+// but queued messages remain queued until the pipeline is reopened.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closing == nil {
+		return nil
+	}
+	close(p.closing)
+	p.cancel()
+	p.wg.Wait()
+	p.closing, p.cancel = nil, nil
+	return nil
+}
+
+// Opened returns true if the pipeline's stages are running.
+func (p *Pipeline) Opened() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closing != nil
+}
+
+// Enqueue adds messages to the queue. Blocks while the queue is full until
+// there is room, ctx is done, or the pipeline is closed. Returns the number
+// of messages enqueued.
+func (p *Pipeline) Enqueue(ctx context.Context, a []*Message) (int, error) {
+	p.mu.Lock()
+	closing := p.closing
+	p.mu.Unlock()
+	if closing == nil {
+		return 0, ErrPipelineClosed
+	}
+
+	p.qmu.Lock()
+	queue := p.queue
+	p.qmu.Unlock()
+
+	defer p.gauge()
+	for i, m := range a {
+		select {
+		case queue <- m:
+		case <-ctx.Done():
+			return i, ctx.Err()
+		case <-closing:
+			return i, ErrPipelineClosed
+		}
+	}
+	return len(a), nil
+}
+
+// Depth returns the number of messages waiting to be resolved and the
+// number of resolved batches waiting to be written.
+func (p *Pipeline) Depth() (queued, resolved int) {
+	p.qmu.Lock()
+	defer p.qmu.Unlock()
+	return len(p.queue) + len(p.pending), len(p.resolved)
+}
+
+// Restarts returns the number of times each stage has been restarted.
+func (p *Pipeline) Restarts() map[string]int {
+	p.qmu.Lock()
+	defer p.qmu.Unlock()
+	other := make(map[string]int, len(p.restarts))
+	for k, v := range p.restarts {
+		other[k] = v
+	}
+	return other
+}
+
+// run executes a stage until the pipeline is closed. The stage is restarted
+// after a delay if it panics.
+func (p *Pipeline) run(ctx context.Context, name string, fn func(context.Context)) {
+	defer p.wg.Done()
+
+	logger := log.New(p.LogOutput, "[pipeline] ", log.LstdFlags)
+	for {
+		if ok := p.runStage(ctx, logger, name, fn); ok {
+			return
+		}
+
+		p.qmu.Lock()
+		if p.restarts == nil {
+			p.restarts = make(map[string]int)
+		}
+		p.restarts[name]++
+		p.qmu.Unlock()
+
+		select {
+		case <-time.After(p.RestartDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runStage executes fn and returns false if it panicked.
+func (p *Pipeline) runStage(ctx context.Context, logger *log.Logger, name string, fn func(context.Context)) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Printf("%s: stage panic: %v, restarting in %s", name, r, p.RestartDelay)
+		}
+	}()
+	fn(ctx)
+	return true
+}
+
+// resolve reads batches of messages from the queue and retrieves their
+// unknown repositories. Resolved batches are sent to the writer.
+func (p *Pipeline) resolve(ctx context.Context) {
+	// Start with any partial batch from before the stage was stopped.
+	p.qmu.Lock()
+	queue, resolved, batch := p.queue, p.resolved, p.pending
+	p.qmu.Unlock()
+
+	var timeout <-chan time.Time
+	for {
+		// Resolve the batch once it is full or the delay has passed.
+		if len(batch) < p.BatchSize {
+			if len(batch) > 0 && timeout == nil {
+				timeout = time.After(p.BatchDelay)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case m := <-queue:
+				batch = append(batch, m)
+				p.setPending(batch)
+				continue
+			case <-timeout:
+			}
+		}
+
+		// Lookups cancelled by closing leave the batch pending so it is
+		// resolved again once the stage restarts.
+		res := p.Store.ResolveMessages(ctx, batch)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case resolved <- res:
+		case <-ctx.Done():
+			return
+		}
+		batch, timeout = nil, nil
+		p.setPending(nil)
+		p.gauge()
+	}
+}
+
+// setPending records the resolver's partial batch so it survives restarts.
+func (p *Pipeline) setPending(a []*Message) {
+	p.qmu.Lock()
+	defer p.qmu.Unlock()
+	p.pending = a
+}
+
+// write commits resolved batches to the store. Remaining resolved batches
+// are written when the pipeline is closed.
+func (p *Pipeline) write(ctx context.Context) {
+	for {
+		select {
+		case res := <-p.resolved:
+			p.commit(res)
+		case <-ctx.Done():
+			for {
+				select {
+				case res := <-p.resolved:
+					p.commit(res)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// commit writes a resolved batch and passes the results to the handler.
+func (p *Pipeline) commit(res *Resolution) {
+	errs := p.Store.WriteMessages(res)
+	if p.Handler != nil {
+		p.Handler(res.Messages, errs)
+	}
+	p.gauge()
+}
+
+// gauge reports the depth of each queue.
+func (p *Pipeline) gauge() {
+	queued, resolved := p.Depth()
+	queuedDepth.Set(int64(queued))
+	resolvedDepth.Set(int64(resolved))
+	p.Statsd.Gauge("pipeline.queue_depth", int64(queued))
+	p.Statsd.Gauge("pipeline.resolved_depth", int64(resolved))
+}
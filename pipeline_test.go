@@ -0,0 +1,151 @@
+package scuttlebutt_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure enqueued messages are resolved & written in batches.
+func TestPipeline_Enqueue(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/nope" {
+			return nil, nil
+		}
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	var mu sync.Mutex
+	var batches [][]error
+	done := make(chan struct{})
+	p := scuttlebutt.NewPipeline(s.Store)
+	p.BatchSize = 3
+	p.BatchDelay = time.Hour
+	p.Handler = func(a []*scuttlebutt.Message, errs []error) {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, errs)
+		close(done)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if n, err := p.Enqueue(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo"},
+		{ID: 2, RepositoryID: "github.com/user/nope"},
+		{ID: 3, RepositoryID: "github.com/user/repo"},
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("unexpected enqueued count: %d", n)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(batches, [][]error{{nil, scuttlebutt.ErrRepositoryNotFound, nil}}) {
+		t.Fatalf("unexpected batches: %v", batches)
+	} else if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 2 {
+		t.Fatalf("unexpected message count: %d", len(r.Messages))
+	}
+}
+
+// Ensure sources are blocked while the queue is full.
+func TestPipeline_Enqueue_Backpressure(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Block lookups so the resolver stops reading from the queue.
+	release := make(chan struct{})
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		<-release
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	p := scuttlebutt.NewPipeline(s.Store)
+	p.QueueSize, p.BatchSize = 1, 1
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	n, err := p.Enqueue(ctx, []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo1"},
+		{ID: 2, RepositoryID: "github.com/user/repo2"},
+		{ID: 3, RepositoryID: "github.com/user/repo3"},
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n != 2 {
+		t.Fatalf("unexpected enqueued count: %d", n)
+	} else if queued, _ := p.Depth(); queued != 2 {
+		t.Fatalf("unexpected queue depth: %d", queued)
+	}
+}
+
+// Ensure a stage is restarted after it panics.
+func TestPipeline_Restart(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	var once sync.Once
+	done := make(chan struct{})
+	p := scuttlebutt.NewPipeline(s.Store)
+	p.BatchSize = 1
+	p.RestartDelay = time.Millisecond
+	p.Handler = func(a []*scuttlebutt.Message, errs []error) {
+		if a[0].ID == 1 {
+			once.Do(func() { panic("marker") })
+		}
+		close(done)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.Enqueue(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo"},
+		{ID: 2, RepositoryID: "github.com/user/repo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	if m := p.Restarts(); m[scuttlebutt.StageWriter] != 1 {
+		t.Fatalf("unexpected restarts: %v", m)
+	}
+}
+
+// Ensure enqueueing on a closed pipeline returns an error.
+func TestPipeline_Enqueue_ErrPipelineClosed(t *testing.T) {
+	p := scuttlebutt.NewPipeline(nil)
+	if _, err := p.Enqueue(context.Background(), []*scuttlebutt.Message{{ID: 1}}); err != scuttlebutt.ErrPipelineClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package scuttlebutt
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// RemoteStore represents a source of repository metadata, such as GitHub.
+// Returns a nil repository if it does not exist. Lookups are abandoned once
+// ctx is done.
+type RemoteStore interface {
+	Repository(ctx context.Context, id string) (*Repository, error)
+}
+
+// BatchRemoteStore represents a remote store that can retrieve multiple
+// repositories in a single request. Missing repositories are not included
+// in the returned map.
+type BatchRemoteStore interface {
+	Repositories(ctx context.Context, ids []string) (map[string]*Repository, error)
+}
+
+// RemoteStoreMux routes repository lookups to remote stores by the host
+// portion of the repository ID, such as "gitlab.com". Repositories on hosts
+// without a remote store are not found.
+type RemoteStoreMux struct {
+	stores map[string]RemoteStore
+}
+
+// NewRemoteStoreMux returns a new instance of RemoteStoreMux.
+func NewRemoteStoreMux() *RemoteStoreMux {
+	return &RemoteStoreMux{stores: make(map[string]RemoteStore)}
+}
+
+// Handle registers the remote store for a host.
+func (m *RemoteStoreMux) Handle(host string, s RemoteStore) {
+	m.stores[strings.ToLower(host)] = s
+}
+
+// Hosts returns the registered hosts in sorted order.
+func (m *RemoteStoreMux) Hosts() []string {
+	a := make([]string, 0, len(m.stores))
+	for host := range m.stores {
+		a = append(a, host)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Repository returns a repository by ID from the remote store for its host.
+func (m *RemoteStoreMux) Repository(ctx context.Context, id string) (*Repository, error) {
+	s := m.stores[RepositoryHost(id)]
+	if s == nil {
+		return nil, nil
+	}
+	return s.Repository(ctx, id)
+}
+
+// Repositories returns repositories by ID. IDs are grouped by host and are
+// retrieved in a single request from remote stores that support batching.
+func (m *RemoteStoreMux) Repositories(ctx context.Context, ids []string) (map[string]*Repository, error) {
+	// Group IDs by host, preserving order.
+	var hosts []string
+	groups := make(map[string][]string)
+	for _, id := range ids {
+		host := RepositoryHost(id)
+		if _, ok := groups[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		groups[host] = append(groups[host], id)
+	}
+
+	repos := make(map[string]*Repository, len(ids))
+	for _, host := range hosts {
+		switch s := m.stores[host].(type) {
+		case nil:
+			continue
+		case BatchRemoteStore:
+			a, err := s.Repositories(ctx, groups[host])
+			if err != nil {
+				return nil, err
+			}
+			for id, r := range a {
+				repos[id] = r
+			}
+		default:
+			for _, id := range groups[host] {
+				r, err := s.Repository(ctx, id)
+				if err != nil {
+					return nil, err
+				} else if r != nil {
+					repos[id] = r
+				}
+			}
+		}
+	}
+	return repos, nil
+}
+
+// RepositoryHost returns the lowercase host of a repository ID.
+func RepositoryHost(id string) string {
+	if i := strings.Index(id, "/"); i >= 0 {
+		id = id[:i]
+	}
+	return strings.ToLower(id)
+}
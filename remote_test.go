@@ -0,0 +1,47 @@
+package scuttlebutt_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure lookups are routed to the remote store for the repository's host.
+func TestRemoteStoreMux_Repositories(t *testing.T) {
+	var batches [][]string
+	github := &BatchRemoteStore{
+		RepositoriesFn: func(ids []string) (map[string]*scuttlebutt.Repository, error) {
+			batches = append(batches, ids)
+			return map[string]*scuttlebutt.Repository{ids[0]: {ID: ids[0]}}, nil
+		},
+	}
+	gitlab := &RemoteStore{
+		RepositoryFn: func(id string) (*scuttlebutt.Repository, error) {
+			return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+		},
+	}
+
+	m := scuttlebutt.NewRemoteStoreMux()
+	m.Handle("github.com", github)
+	m.Handle("GitLab.com", gitlab)
+
+	if a, err := m.Repositories(context.Background(), []string{"github.com/a/b", "gitlab.com/c/d", "github.com/e/f", "example.com/g/h"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, map[string]*scuttlebutt.Repository{
+		"github.com/a/b": {ID: "github.com/a/b"},
+		"gitlab.com/c/d": {ID: "gitlab.com/c/d", Language: "Go"},
+	}) {
+		t.Fatalf("unexpected repositories: %#v", a)
+	} else if !reflect.DeepEqual(batches, [][]string{{"github.com/a/b", "github.com/e/f"}}) {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+
+	// Unknown hosts are not found.
+	if r, err := m.Repository(context.Background(), "example.com/g/h"); err != nil || r != nil {
+		t.Fatalf("unexpected result: %#v, %v", r, err)
+	} else if !reflect.DeepEqual(m.Hosts(), []string{"github.com", "gitlab.com"}) {
+		t.Fatalf("unexpected hosts: %v", m.Hosts())
+	}
+}
@@ -0,0 +1,115 @@
+package scuttlebutt
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rule represents a routing rule that matches repositories to notification
+// targets. Empty criteria match all repositories.
+type Rule struct {
+	Name     string
+	Priority int
+
+	// Match criteria. Languages and tags are case insensitive and match if
+	// any value matches. Tags are hashtags used in messages.
+	Languages   []string
+	Tags        []string
+	MinStars    int
+	MinMentions int
+	MinScore    float64
+
+	// Names of the targets that matching repositories are routed to.
+	Targets []string
+}
+
+// Match returns true if the repository matches all of the rule's criteria.
+func (r *Rule) Match(repo *RankedRepository) bool {
+	if len(r.Languages) > 0 && !containsFold(r.Languages, repo.Language) {
+		return false
+	}
+	if len(r.Tags) > 0 {
+		var ok bool
+		for _, tag := range repo.Tags() {
+			if containsFold(r.Tags, tag) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return repo.Stars >= r.MinStars &&
+		len(repo.Messages) >= r.MinMentions &&
+		repo.Score >= r.MinScore
+}
+
+// Router routes repositories to targets using a set of rules.
+type Router struct {
+	Rules []*Rule
+}
+
+// Route returns the best repository for each target. Rules are evaluated in
+// priority order and the first rule with a matching repository for a target
+// wins. The highest scoring match is used. Notified repositories are ignored.
+func (rt *Router) Route(repos []*RankedRepository) map[string]*Repository {
+	// Sort rules by priority, highest first.
+	rules := make([]*Rule, len(rt.Rules))
+	copy(rules, rt.Rules)
+	sort.Stable(rulesByPriority(rules))
+
+	// Sort repositories by score, highest first.
+	a := make(RankedRepositories, 0, len(repos))
+	for _, repo := range repos {
+		if !repo.Notified {
+			a = append(a, repo)
+		}
+	}
+	sort.Sort(a)
+
+	m := make(map[string]*Repository)
+	for _, rule := range rules {
+		// Skip rule if all of its targets have already been routed.
+		var pending bool
+		for _, target := range rule.Targets {
+			if m[target] == nil {
+				pending = true
+			}
+		}
+		if !pending {
+			continue
+		}
+
+		// Find the best matching repository and assign to unrouted targets.
+		for _, repo := range a {
+			if !rule.Match(repo) {
+				continue
+			}
+			for _, target := range rule.Targets {
+				if m[target] == nil {
+					m[target] = repo.Repository
+				}
+			}
+			break
+		}
+	}
+	return m
+}
+
+// rulesByPriority sorts rules by descending priority.
+type rulesByPriority []*Rule
+
+func (p rulesByPriority) Len() int           { return len(p) }
+func (p rulesByPriority) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p rulesByPriority) Less(i, j int) bool { return p[i].Priority > p[j].Priority }
+
+// containsFold returns true if a contains s, ignoring case.
+func containsFold(a []string, s string) bool {
+	for _, v := range a {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the router assigns the best matching repository by rule priority.
+func TestRouter_Route(t *testing.T) {
+	rt := &scuttlebutt.Router{
+		Rules: []*scuttlebutt.Rule{
+			{Name: "go", Priority: 1, Languages: []string{"Go"}, Targets: []string{"oss_go"}},
+			{Name: "popular", Priority: 10, MinStars: 1000, Targets: []string{"oss_go", "oss_popular"}},
+			{Name: "cli", Priority: 5, Tags: []string{"CLI"}, Targets: []string{"oss_cli"}},
+		},
+	}
+
+	m := rt.Route([]*scuttlebutt.RankedRepository{
+		{Repository: &scuttlebutt.Repository{ID: "github.com/a/go1", Language: "go", Stars: 10}, Score: 3},
+		{Repository: &scuttlebutt.Repository{ID: "github.com/a/go2", Language: "go", Stars: 5000}, Score: 1},
+		{Repository: &scuttlebutt.Repository{ID: "github.com/a/js1", Language: "javascript", Stars: 2000, Notified: true}, Score: 5},
+		{Repository: &scuttlebutt.Repository{ID: "github.com/a/cli", Language: "rust", Messages: []*scuttlebutt.Message{{Text: "nice #cli tool!"}}}, Score: 0.5},
+	})
+
+	if r := m["oss_go"]; r == nil || r.ID != "github.com/a/go2" {
+		t.Fatalf("unexpected oss_go repository: %#v", r)
+	} else if r := m["oss_popular"]; r == nil || r.ID != "github.com/a/go2" {
+		t.Fatalf("unexpected oss_popular repository: %#v", r)
+	} else if r := m["oss_cli"]; r == nil || r.ID != "github.com/a/cli" {
+		t.Fatalf("unexpected oss_cli repository: %#v", r)
+	}
+}
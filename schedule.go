@@ -0,0 +1,231 @@
+package scuttlebutt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule represents the times when an account is allowed to notify.
+type Schedule struct {
+	// Times when notifications are due. If nil, notifications are always due.
+	Cron *Cron
+
+	// Time-of-day windows that notifications must fall within.
+	// If empty, notifications can be sent at any time of day.
+	Windows []TimeWindow
+
+	// Timezone used for evaluating the cron expression and windows.
+	Location *time.Location
+}
+
+// Due returns true if a notification can be sent at now given the time of
+// the previous notification. A zero last time is always due under the cron.
+func (s *Schedule) Due(now, last time.Time) bool {
+	loc := s.location()
+	now = now.In(loc)
+
+	// Ensure the current time is inside a window, if any are specified.
+	if !s.InWindow(now) {
+		return false
+	}
+
+	// Ensure a scheduled time has passed since the last notification.
+	if s.Cron != nil && !last.IsZero() {
+		next := s.Cron.Next(last.In(loc))
+		if next.IsZero() || next.After(now) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TimeWindow represents a daily time range. Ranges that end before they
+// start wrap around midnight. Times are stored as minutes after midnight.
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+// InWindow returns true if t is inside one of the schedule's windows.
+// Always returns true if no windows are specified.
+func (s *Schedule) InWindow(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+
+	t = t.In(s.location())
+	for _, w := range s.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// location returns the schedule's timezone. Defaults to UTC.
+func (s *Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// ParseTimeWindow parses a window in the form "HH:MM-HH:MM".
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %q", s)
+	}
+
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %q", s)
+	}
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %q", s)
+	}
+	return TimeWindow{Start: start, End: end}, nil
+}
+
+// Contains returns true if the time of day of t is within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	min := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return min >= w.Start && min < w.End
+	}
+	return min >= w.Start || min < w.End
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes after midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Cron represents a parsed 5-field cron expression:
+// minute, hour, day of month, month, and day of week.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// Set if the day fields were restricted ("*" was not used).
+	domRestricted, dowRestricted bool
+}
+
+// ParseCron parses a standard 5-field cron expression. Fields support "*",
+// lists ("1,2"), ranges ("1-5"), and steps ("*/15", "0-30/10").
+func ParseCron(s string) (*Cron, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression: expected 5 fields: %q", s)
+	}
+
+	var c Cron
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid cron minute: %s", err)
+	} else if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid cron hour: %s", err)
+	} else if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid cron day of month: %s", err)
+	} else if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid cron month: %s", err)
+	} else if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid cron day of week: %s", err)
+	}
+
+	// Sunday can be specified as 0 or 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	c.domRestricted = fields[2] != "*"
+	c.dowRestricted = fields[4] != "*"
+	return &c, nil
+}
+
+// Next returns the first scheduled time after t.
+// Returns a zero time if no time matches within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay returns true if t matches the day of month & day of week fields.
+// If both fields are restricted then matching either is sufficient.
+func (c *Cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// parseCronField parses a single cron field into a bitset.
+func parseCronField(s string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		// Split off step, if specified.
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", part)
+			}
+			step, part = n, part[:i]
+		}
+
+		// Parse range.
+		lo, hi := min, max
+		if part != "*" {
+			var err error
+			if i := strings.Index(part, "-"); i != -1 {
+				if lo, err = strconv.Atoi(part[:i]); err != nil {
+					return 0, fmt.Errorf("invalid value: %q", part)
+				} else if hi, err = strconv.Atoi(part[i+1:]); err != nil {
+					return 0, fmt.Errorf("invalid value: %q", part)
+				}
+			} else if lo, err = strconv.Atoi(part); err != nil {
+				return 0, fmt.Errorf("invalid value: %q", part)
+			} else if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("out of range: %q", s)
+		}
+
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
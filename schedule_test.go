@@ -0,0 +1,82 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure a cron expression can compute its next scheduled time.
+func TestCron_Next(t *testing.T) {
+	for i, tt := range []struct {
+		expr string
+		t    string
+		next string
+	}{
+		{expr: "0 9 * * *", t: "2015-01-01T08:30:00Z", next: "2015-01-01T09:00:00Z"},
+		{expr: "0 9 * * *", t: "2015-01-01T09:00:00Z", next: "2015-01-02T09:00:00Z"},
+		{expr: "*/15 * * * *", t: "2015-01-01T09:01:00Z", next: "2015-01-01T09:15:00Z"},
+		{expr: "30 17 * * 1-5", t: "2015-01-02T18:00:00Z", next: "2015-01-05T17:30:00Z"},
+		{expr: "0 0 1 2 *", t: "2015-03-01T00:00:00Z", next: "2016-02-01T00:00:00Z"},
+	} {
+		c, err := scuttlebutt.ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%d. %s", i, err)
+		}
+
+		now, _ := time.Parse(time.RFC3339, tt.t)
+		if next := c.Next(now).Format(time.RFC3339); next != tt.next {
+			t.Errorf("%d. %s: unexpected next time: %s", i, tt.expr, next)
+		}
+	}
+}
+
+// Ensure invalid cron expressions return an error.
+func TestParseCron_Err(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 1-x * * *", "*/0 * * * *"} {
+		if _, err := scuttlebutt.ParseCron(expr); err == nil {
+			t.Errorf("expected error: %q", expr)
+		}
+	}
+}
+
+// Ensure a schedule only allows notifications inside its windows.
+func TestSchedule_Due_Windows(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone data unavailable")
+	}
+
+	w0, _ := scuttlebutt.ParseTimeWindow("08:00-11:00")
+	w1, _ := scuttlebutt.ParseTimeWindow("22:00-01:00")
+	s := &scuttlebutt.Schedule{Windows: []scuttlebutt.TimeWindow{w0, w1}, Location: loc}
+
+	for i, tt := range []struct {
+		now string
+		due bool
+	}{
+		{now: "2015-01-01T13:30:00Z", due: true},  // 08:30 EST
+		{now: "2015-01-01T16:00:00Z", due: false}, // 11:00 EST
+		{now: "2015-01-02T05:30:00Z", due: true},  // 00:30 EST
+		{now: "2015-01-01T20:00:00Z", due: false}, // 15:00 EST
+	} {
+		now, _ := time.Parse(time.RFC3339, tt.now)
+		if due := s.Due(now, time.Time{}); due != tt.due {
+			t.Errorf("%d. unexpected due: %v", i, due)
+		}
+	}
+}
+
+// Ensure a schedule with a cron expression is only due once per scheduled time.
+func TestSchedule_Due_Cron(t *testing.T) {
+	c, _ := scuttlebutt.ParseCron("0 9 * * *")
+	s := &scuttlebutt.Schedule{Cron: c}
+
+	last, _ := time.Parse(time.RFC3339, "2015-01-01T09:00:00Z")
+	if now, _ := time.Parse(time.RFC3339, "2015-01-01T15:00:00Z"); s.Due(now, last) {
+		t.Fatal("expected not due")
+	} else if now, _ := time.Parse(time.RFC3339, "2015-01-02T09:10:00Z"); !s.Due(now, last) {
+		t.Fatal("expected due")
+	}
+}
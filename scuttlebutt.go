@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 )
 
 // Repository represents a code repository.
@@ -12,16 +13,146 @@ type Repository struct {
 	ID          string
 	Description string
 	Language    string
+	Stars       int
+	Forks       int
+	Topics      []string
 	Notified    bool
 	Messages    []*Message
+
+	// Repository state on the remote store.
+	Fork     bool
+	Archived bool
+	Disabled bool
+
+	// License key reported by the remote store, such as "mit" or
+	// "apache-2.0". Blank if the repository has no detected license.
+	License string
+
+	// Mentions added when ranking, such as to promote a curated pick. May
+	// be negative.
+	Boost int
+
+	// Curation labels set by operators, such as LabelFeatured.
+	Labels []string
 }
 
 // Name returns the name of the repository.
 func (r *Repository) Name() string { return path.Base(r.ID) }
 
+// RankedMentions returns the mention count, including the boost.
+func (r *Repository) RankedMentions() int { return len(r.Messages) + r.Boost }
+
+// RankedScore returns the score used for ranking, which is the mention count
+// weighted by source plus the boost. Equal to RankedMentions() if weights is nil.
+func (r *Repository) RankedScore(weights map[string]float64) float64 {
+	return r.WeightedMentions(weights) + float64(r.Boost)
+}
+
 // URL returns the URL for the repository.
 func (r *Repository) URL() string { return "https://" + r.ID }
 
+// Clone returns a deep copy of the repository.
+func (r *Repository) Clone() *Repository {
+	other := *r
+	other.Topics = append([]string(nil), r.Topics...)
+	other.Labels = append([]string(nil), r.Labels...)
+	if r.Messages != nil {
+		other.Messages = make([]*Message, len(r.Messages))
+		for i, m := range r.Messages {
+			tmp := *m
+			other.Messages[i] = &tmp
+		}
+	}
+	return &other
+}
+
+// HasTopic returns true if the repository is tagged with topic.
+func (r *Repository) HasTopic(topic string) bool {
+	for _, t := range r.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownSource is the source name used for messages without a source.
+const UnknownSource = "unknown"
+
+// SourceMentions returns the number of messages from each source. Messages
+// without a source are counted under UnknownSource.
+func (r *Repository) SourceMentions() map[string]int {
+	m := make(map[string]int)
+	for _, msg := range r.Messages {
+		m[messageSource(msg.Source)]++
+	}
+	return m
+}
+
+// WeightedMentions returns the sum of each message's source weight. Sources
+// without a weight count as one mention.
+func (r *Repository) WeightedMentions(weights map[string]float64) float64 {
+	var n float64
+	for _, msg := range r.Messages {
+		if w, ok := weights[messageSource(msg.Source)]; ok {
+			n += w
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// messageSource returns source or, if blank, UnknownSource.
+func messageSource(source string) string {
+	if source == "" {
+		return UnknownSource
+	}
+	return source
+}
+
+// TopicKey returns the key used to group repositories by topic. Topic keys
+// are prefixed so they cannot collide with language names.
+func TopicKey(topic string) string { return "topic:" + strings.ToLower(topic) }
+
+// AuthorN returns the number of unique authors of the repository's messages.
+// Messages without a known author are each counted as a separate author.
+func (r *Repository) AuthorN() int {
+	var n int
+	m := make(map[string]bool)
+	for _, msg := range r.Messages {
+		author := strings.ToLower(msg.Author)
+		if author != "" && m[author] {
+			continue
+		}
+		m[author] = true
+		n++
+	}
+	return n
+}
+
+// Tags returns the unique, lowercase hashtags used in the repository's messages.
+func (r *Repository) Tags() []string {
+	var a []string
+	m := make(map[string]bool)
+	for _, msg := range r.Messages {
+		for _, word := range strings.Fields(msg.Text) {
+			if !strings.HasPrefix(word, "#") {
+				continue
+			}
+
+			// Normalize tag and strip trailing punctuation.
+			tag := strings.ToLower(strings.TrimRight(word[1:], ".,:;!?)"))
+			if tag == "" || m[tag] {
+				continue
+			}
+			m[tag] = true
+			a = append(a, tag)
+		}
+	}
+	return a
+}
+
 // Repositories represents a sortable list of repositories.
 type Repositories []*Repository
 
@@ -29,11 +160,361 @@ func (p Repositories) Len() int           { return len(p) }
 func (p Repositories) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p Repositories) Less(i, j int) bool { return p[i].ID < p[j].ID }
 
+// RankedRepository represents a repository with a normalized score.
+type RankedRepository struct {
+	*Repository
+	Score float64
+}
+
+// RankedRepositories represents a list of repositories sortable by score.
+type RankedRepositories []*RankedRepository
+
+func (p RankedRepositories) Len() int      { return len(p) }
+func (p RankedRepositories) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p RankedRepositories) Less(i, j int) bool {
+	if pi, pj := p[i].Pinned(), p[j].Pinned(); pi != pj {
+		return pi
+	} else if p[i].Score != p[j].Score {
+		return p[i].Score > p[j].Score
+	}
+	return p[i].ID < p[j].ID
+}
+
 // Message represents a message associated with a project and language.
 type Message struct {
 	ID           uint64
 	Text         string
 	RepositoryID string
+
+	// Canonical link to the message, if known.
+	URL string
+
+	// Screen name of the message's author, if known.
+	Author string
+
+	// Time the message was posted, if known.
+	Time time.Time
+
+	// Follower count & creation time of the author's account, if reported
+	// by the source. AuthorCreatedAt is zero if the account is unknown.
+	AuthorFollowers int
+	AuthorCreatedAt time.Time
+
+	// Name of the app the message was posted with, such as
+	// "Twitter for iPhone", if known.
+	Client string
+
+	// Name of the source the message was collected from, such as "twitter"
+	// or "hn". Blank for messages saved before sources were recorded.
+	Source string
+}
+
+// PollerStatus represents diagnostic information about message ingestion.
+type PollerStatus struct {
+	// Number of ingestion errors by class.
+	Errors map[string]int `json:"errors"`
+
+	// Number of tweets skipped because they could not be decoded.
+	MalformedN uint64 `json:"malformed"`
+
+	// Number of messages dropped by the spam filter by reason.
+	Spam map[SpamReason]int `json:"spam"`
+
+	// Number of messages waiting in memory to be retried on the next poll
+	// & number dropped because too many were waiting.
+	DeferredN int `json:"deferred"`
+	DroppedN  int `json:"dropped,omitempty"`
+
+	// Number of messages in the store's retry queue.
+	RetryN int `json:"retrying,omitempty"`
+
+	// Number of messages waiting in the pipeline to be resolved, number of
+	// resolved batches waiting to be written, & restarts of each stage.
+	QueuedN   int            `json:"queued,omitempty"`
+	ResolvedN int            `json:"resolved,omitempty"`
+	Restarts  map[string]int `json:"restarts,omitempty"`
+
+	// Messages that repeatedly failed and are no longer retried.
+	Quarantined []*QuarantinedMessage `json:"quarantined"`
+
+	// Highest number of consecutive failed polls of any source & the time
+	// of that source's next poll. The retry time is nil if no source is
+	// backing off.
+	FailureN int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// SourceStatus represents the health of a message source.
+type SourceStatus struct {
+	Name         string `json:"name"`
+	PollInterval string `json:"poll_interval"`
+
+	// Time of the last poll & the last successful poll.
+	LastPollTime    *time.Time `json:"last_poll_time,omitempty"`
+	LastSuccessTime *time.Time `json:"last_success_time,omitempty"`
+
+	// Number of consecutive failed polls, the most recent error, & the time
+	// of the next poll while backing off.
+	FailureN  int        `json:"failures"`
+	LastError string     `json:"last_error,omitempty"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+
+	// Total number of messages saved from the source.
+	MessageN int `json:"messages"`
+}
+
+// QuarantinedMessage represents a message that repeatedly failed ingestion.
+type QuarantinedMessage struct {
+	Message  *Message `json:"message"`
+	Class    string   `json:"class"`
+	Err      string   `json:"error"`
+	Failures int      `json:"failures"`
+}
+
+// RetryMessage represents a message waiting to be ingested again after a
+// transient failure, such as a network error or an exhausted API quota.
+type RetryMessage struct {
+	Message       *Message  `json:"message"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	Err           string    `json:"error"`
+}
+
+// PendingNotification represents a notification awaiting moderator approval.
+type PendingNotification struct {
+	ID           uint64    `json:"id,string"`
+	Username     string    `json:"username"`
+	RepositoryID string    `json:"repository_id"`
+	Text         string    `json:"text"`
+	Approved     bool      `json:"approved"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FlaggedRepository represents a repository blocked by the content filter.
+// Flagged repositories are not ranked until approved by a moderator.
+type FlaggedRepository struct {
+	RepositoryID string    `json:"repository_id"`
+	Reason       string    `json:"reason"`
+	Approved     bool      `json:"approved"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Notification represents an attempt to send a notification from an account.
+// Failed attempts include the error and have no message ID.
+type Notification struct {
+	ID           uint64    `json:"id,string"`
+	Username     string    `json:"username"`
+	RepositoryID string    `json:"repository_id,omitempty"`
+	Text         string    `json:"text"`
+	MessageID    uint64    `json:"message_id,string,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Time         time.Time `json:"time"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+
+	// Name of the template variant used for the text, if the account has
+	// more than one template.
+	Template string `json:"template,omitempty"`
+
+	// Most recently fetched engagement with the sent message, if any.
+	Engagement *Engagement `json:"engagement,omitempty"`
+}
+
+// Engagement represents the engagement counts of a sent message at the time
+// they were fetched.
+type Engagement struct {
+	Likes       int       `json:"likes"`
+	Retweets    int       `json:"retweets"`
+	Impressions int       `json:"impressions"`
+	Time        time.Time `json:"time"`
+}
+
+// TemplateStats represents the engagement with an account's notifications
+// sent using a template variant. Measured is the number of notifications
+// with fetched engagement, which the counts are totalled over.
+type TemplateStats struct {
+	Username      string
+	Template      string
+	Notifications int
+	Measured      int
+	Likes         int
+	Retweets      int
+	Impressions   int
+}
+
+// Snapshot represents the top repositories for each language on a day.
+type Snapshot struct {
+	Date      time.Time                   `json:"date"`
+	Languages map[string][]*SnapshotEntry `json:"languages"`
+}
+
+// SnapshotEntry represents a ranked repository within a snapshot.
+type SnapshotEntry struct {
+	Rank         int    `json:"rank"`
+	RepositoryID string `json:"repository_id"`
+	Mentions     int    `json:"mentions"`
+}
+
+// LanguageStats represents the repositories & mentions seen for a language.
+// Recent mentions only include messages stored with a posted time.
+type LanguageStats struct {
+	Language       string
+	Repositories   int
+	Mentions       int
+	RecentMentions int
+}
+
+// MentionTimeSeries represents a repository's mentions counted per UTC day.
+// Messages stored without a posted time are counted as undated.
+type MentionTimeSeries struct {
+	RepositoryID string           `json:"repository_id"`
+	Days         []*DailyMentions `json:"days"`
+	Undated      int              `json:"undated,omitempty"`
+}
+
+// DailyMentions represents the number of mentions posted on a UTC day.
+type DailyMentions struct {
+	Date     time.Time `json:"date"`
+	Mentions int       `json:"mentions"`
+}
+
+// OAuth2Token represents an account's OAuth 2.0 user-context token. The
+// refresh token is replaced each time the access token is refreshed.
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// Expired returns true if the access token has expired, or will within d.
+func (t *OAuth2Token) Expired(now time.Time, d time.Duration) bool {
+	return t.AccessToken == "" || !now.Add(d).Before(t.Expiry)
+}
+
+// RateLimit represents an account's remaining API quota for a resource.
+type RateLimit struct {
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Throttled returns true if the quota is exhausted and has not reset by now.
+func (rl *RateLimit) Throttled(now time.Time) bool {
+	return rl.Remaining <= 0 && now.Before(rl.Reset)
+}
+
+// RateLimitError is returned when a remote API quota is exhausted.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limited until " + e.Reset.UTC().Format(time.RFC3339)
+}
+
+// Feature represents a repository & notification text featured by an account.
+type Feature struct {
+	RepositoryID string    `json:"repository_id"`
+	Username     string    `json:"username"`
+	Text         string    `json:"text,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// NormalizeText returns text with URLs removed, whitespace collapsed, and
+// letters lowercased so that otherwise identical notifications compare equal.
+func NormalizeText(text string) string {
+	fields := strings.Fields(strings.ToLower(text))
+	a := fields[:0]
+	for _, f := range fields {
+		if strings.HasPrefix(f, "http://") || strings.HasPrefix(f, "https://") {
+			continue
+		}
+		a = append(a, f)
+	}
+	return strings.Join(a, " ")
+}
+
+// Explanation describes an account's most recent pick and its outcome.
+type Explanation struct {
+	Username     string    `json:"username"`
+	RepositoryID string    `json:"repository_id"`
+	Blocked      bool      `json:"blocked"`
+	Reason       string    `json:"reason"`
+	Time         time.Time `json:"time"`
+}
+
+// ShortURL represents a shortened repository URL.
+type ShortURL struct {
+	Short string `json:"short"`
+	Long  string `json:"long"`
+}
+
+// SkipReason describes why an account did not notify during a cycle.
+type SkipReason string
+
+const (
+	// SkipWithinInterval is used when the account notified too recently.
+	SkipWithinInterval SkipReason = "within_interval"
+
+	// SkipQuietHours is used when the current time is outside the
+	// account's notification windows.
+	SkipQuietHours SkipReason = "quiet_hours"
+
+	// SkipNoRepository is used when no unnotified repository was found for
+	// the account's language or routing rules.
+	SkipNoRepository SkipReason = "no_repository"
+
+	// SkipPendingApproval is used when a moderated account is waiting on
+	// a queued notification to be approved.
+	SkipPendingApproval SkipReason = "pending_approval"
+
+	// SkipRecentlyFeatured is used when the picked repository or an
+	// identical notification text was featured within the featured window.
+	SkipRecentlyFeatured SkipReason = "recently_featured"
+
+	// SkipBelowThreshold is used when the picked repository does not have
+	// enough mentions, authors, or stars to be notified.
+	SkipBelowThreshold SkipReason = "below_threshold"
+
+	// SkipExcluded is used when the picked repository is a fork, archived,
+	// or disabled and such repositories are not included.
+	SkipExcluded SkipReason = "excluded"
+
+	// SkipFlagged is used when the picked repository is blocked by the
+	// content filter and is awaiting review.
+	SkipFlagged SkipReason = "flagged"
+
+	// SkipRateLimited is used when the account's API quota is exhausted
+	// and has not yet been reset.
+	SkipRateLimited SkipReason = "rate_limited"
+
+	// SkipError is used when the last notification time could not be
+	// retrieved or the notification failed to send.
+	SkipError SkipReason = "error"
+)
+
+// NotifierStatus represents diagnostic information about notifications.
+type NotifierStatus struct {
+	Accounts []*AccountStatus `json:"accounts"`
+}
+
+// AccountStatus represents notification counts for a single account.
+type AccountStatus struct {
+	Username string `json:"username"`
+	Language string `json:"language"`
+	Topic    string `json:"topic,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+
+	// Number of notifications sent.
+	NotifiedN int `json:"notified"`
+
+	// Number of skipped cycles by reason.
+	Skips map[SkipReason]int `json:"skips"`
+
+	// Reason & time of the most recent skip. Cleared after a notification.
+	LastSkip     SkipReason `json:"last_skip,omitempty"`
+	LastSkipTime *time.Time `json:"last_skip_time,omitempty"`
 }
 
 // Extracts the repository identifier from a given URL.
@@ -58,3 +539,11 @@ func ExtractRepositoryID(u *url.URL) (string, error) {
 	// Rejoin sections and return.
 	return path.Join(host, username, repositoryName), nil
 }
+
+// ParseDate parses s as a date (YYYY-MM-DD) or an RFC 3339 timestamp.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,68 @@
+package scuttlebutt
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// MinSearchTermLength is the minimum length of an indexed term.
+	MinSearchTermLength = 2
+
+	// MaxSearchTermLength is the maximum length of an indexed term. Longer
+	// terms, such as hashes, are not indexed.
+	MaxSearchTermLength = 64
+)
+
+// SearchResult represents a repository matching a search query. Matched is
+// true if the repository's ID or description contains every term. Messages
+// are the repository's messages that contain every term.
+type SearchResult struct {
+	Repository *Repository
+	Matched    bool
+	Messages   []*Message
+}
+
+// SearchTerms splits text into unique lowercase terms of letters & digits.
+// Terms outside the minimum & maximum term lengths are dropped.
+func SearchTerms(text string) []string {
+	var a []string
+	seen := make(map[string]bool)
+	for _, term := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(term) < MinSearchTermLength || len(term) > MaxSearchTermLength || seen[term] {
+			continue
+		}
+		seen[term] = true
+		a = append(a, term)
+	}
+	return a
+}
+
+// containsTerms returns true if text contains every term.
+func containsTerms(text string, terms []string) bool {
+	m := make(map[string]bool)
+	for _, term := range SearchTerms(text) {
+		m[term] = true
+	}
+	for _, term := range terms {
+		if !m[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// searchResultsByRelevance sorts results by matching message count, then by
+// ranked mention count, highest first.
+type searchResultsByRelevance []*SearchResult
+
+func (p searchResultsByRelevance) Len() int      { return len(p) }
+func (p searchResultsByRelevance) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p searchResultsByRelevance) Less(i, j int) bool {
+	if len(p[i].Messages) != len(p[j].Messages) {
+		return len(p[i].Messages) > len(p[j].Messages)
+	}
+	return p[i].Repository.RankedMentions() > p[j].Repository.RankedMentions()
+}
@@ -0,0 +1,91 @@
+package scuttlebutt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultMinSentiment is the default score below which mentions are dropped
+// when sentiment filtering is enabled.
+const DefaultMinSentiment = -0.5
+
+// SentimentScorer scores the sentiment of message text from -1, entirely
+// negative, to 1, entirely positive. Text without any sentiment scores 0.
+type SentimentScorer interface {
+	Score(text string) float64
+}
+
+// DefaultPositiveWords are words that indicate a positive mention.
+var DefaultPositiveWords = []string{
+	"amazing", "awesome", "beautiful", "best", "brilliant", "clean", "cool",
+	"elegant", "excellent", "fantastic", "fast", "great", "handy", "impressive",
+	"love", "loving", "neat", "nice", "recommend", "slick", "solid", "useful",
+	"wonderful",
+}
+
+// DefaultNegativeWords are words that indicate a negative mention.
+var DefaultNegativeWords = []string{
+	"abandoned", "avoid", "awful", "backdoor", "broken", "buggy", "crash",
+	"crashes", "garbage", "hate", "horrible", "insecure", "malware", "scam",
+	"terrible", "trash", "useless", "vulnerable", "worst",
+}
+
+// negators are words that flip the sentiment of the word that follows.
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "don't": true, "doesn't": true,
+	"isn't": true, "wasn't": true, "aren't": true, "can't": true, "won't": true,
+}
+
+// Lexicon is a SentimentScorer that counts positive & negative words. Words
+// following a negation, such as "not", count toward the opposite sentiment.
+type Lexicon struct {
+	Positive map[string]bool
+	Negative map[string]bool
+}
+
+// NewLexicon returns a lexicon of positive & negative words.
+func NewLexicon(positive, negative []string) *Lexicon {
+	l := &Lexicon{Positive: make(map[string]bool), Negative: make(map[string]bool)}
+	for _, word := range positive {
+		l.Positive[strings.ToLower(word)] = true
+	}
+	for _, word := range negative {
+		l.Negative[strings.ToLower(word)] = true
+	}
+	return l
+}
+
+// NewDefaultLexicon returns a lexicon of the default words.
+func NewDefaultLexicon() *Lexicon {
+	return NewLexicon(DefaultPositiveWords, DefaultNegativeWords)
+}
+
+// Score returns the difference between positive & negative words as a
+// fraction of all sentiment words in text.
+func (l *Lexicon) Score(text string) float64 {
+	var pos, neg int
+	var negated bool
+	for _, word := range sentimentWords(text) {
+		switch {
+		case l.Positive[word] && !negated, l.Negative[word] && negated:
+			pos++
+		case l.Negative[word], l.Positive[word]:
+			neg++
+		}
+		negated = negators[word]
+	}
+
+	if pos+neg == 0 {
+		return 0
+	}
+	return float64(pos-neg) / float64(pos+neg)
+}
+
+// sentimentWords returns the lowercase words in text. Apostrophes are kept
+// so that contractions match negators.
+func sentimentWords(text string) []string {
+	text = strings.Replace(strings.ToLower(text), "’", "'", -1)
+	return strings.FieldsFunc(text, func(ch rune) bool {
+		return !unicode.IsLetter(ch) && ch != '\''
+	})
+}
@@ -0,0 +1,27 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the lexicon scores text by its positive & negative words.
+func TestLexicon_Score(t *testing.T) {
+	l := scuttlebutt.NewLexicon([]string{"great", "Love"}, []string{"broken", "avoid"})
+	for i, tt := range []struct {
+		text  string
+		score float64
+	}{
+		{text: "github.com/user/repo", score: 0},
+		{text: "I love github.com/user/repo", score: 1},
+		{text: "github.com/user/repo is BROKEN, avoid!", score: -1},
+		{text: "github.com/user/repo is great but broken", score: 0},
+		{text: "github.com/user/repo is not great. Love the idea, though.", score: 0},
+		{text: "github.com/user/repo isn’t broken, it's great", score: 1},
+	} {
+		if score := l.Score(tt.text); score != tt.score {
+			t.Errorf("%d. unexpected score: %v", i, score)
+		}
+	}
+}
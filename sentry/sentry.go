@@ -0,0 +1,215 @@
+// Package sentry reports errors to Sentry using its HTTP store API.
+package sentry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the default time allowed for a report request.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultQueueSize is the default number of errors held for sending.
+	// Errors reported while the queue is full are dropped.
+	DefaultQueueSize = 100
+)
+
+// ErrInvalidDSN is returned when a DSN is missing its key or project.
+var ErrInvalidDSN = errors.New("invalid sentry dsn")
+
+// Event represents the JSON body of a reported error.
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Platform    string            `json:"platform"`
+	Message     string            `json:"message"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   *Exception        `json:"exception,omitempty"`
+}
+
+// Exception represents the error values attached to an event.
+type Exception struct {
+	Values []*ExceptionValue `json:"values"`
+}
+
+// ExceptionValue represents a single error in an exception.
+type ExceptionValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Reporter sends errors to a Sentry project in the background.
+type Reporter struct {
+	wg     sync.WaitGroup
+	queue  chan *Event
+	key    string
+	secret string
+	url    string
+
+	// Optional environment & release attached to each event.
+	Environment string
+	Release     string
+
+	// Name of the host reporting errors. Defaults to the hostname.
+	ServerName string
+
+	QueueSize  int
+	HTTPClient *http.Client
+
+	// Destination for send errors.
+	LogOutput io.Writer
+}
+
+// NewReporter returns a new instance of Reporter for a DSN, such as
+// "https://<key>@sentry.io/<project>".
+func NewReporter(dsn string) (*Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	} else if u.User == nil || u.User.Username() == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	// The project ID is the last path segment of the DSN.
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	project := path[i+1:]
+	if project == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	r := &Reporter{
+		key:        u.User.Username(),
+		QueueSize:  DefaultQueueSize,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		LogOutput:  ioutil.Discard,
+	}
+	r.secret, _ = u.User.Password()
+	r.url = (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: path[:i] + "/api/" + project + "/store/"}).String()
+	r.ServerName, _ = os.Hostname()
+	return r, nil
+}
+
+// URL returns the store endpoint that events are posted to.
+func (r *Reporter) URL() string { return r.url }
+
+// Open starts sending reported errors in the background.
+func (r *Reporter) Open() error {
+	r.queue = make(chan *Event, r.QueueSize)
+	r.wg.Add(1)
+	go r.run()
+	return nil
+}
+
+// Close stops accepting errors and waits for queued errors to be sent.
+func (r *Reporter) Close() error {
+	if r.queue != nil {
+		close(r.queue)
+		r.wg.Wait()
+		r.queue = nil
+	}
+	return nil
+}
+
+// run sends queued events until the queue is closed.
+func (r *Reporter) run() {
+	defer r.wg.Done()
+
+	logger := log.New(r.LogOutput, "[sentry] ", log.LstdFlags)
+	for e := range r.queue {
+		if err := r.Send(e); err != nil {
+			logger.Printf("send error: event=%s, err=%s", e.EventID, err)
+		}
+	}
+}
+
+// ReportError queues an error to be sent. Does not block the caller.
+func (r *Reporter) ReportError(err error, tags map[string]string) {
+	if r.queue == nil {
+		return
+	}
+
+	select {
+	case r.queue <- r.NewEvent(err, tags, time.Now()):
+	default:
+	}
+}
+
+// NewEvent returns an event for an error.
+func (r *Reporter) NewEvent(err error, tags map[string]string, now time.Time) *Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return &Event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   now.UTC().Format("2006-01-02T15:04:05"),
+		Level:       "error",
+		Logger:      "scuttlebutt",
+		Platform:    "go",
+		Message:     err.Error(),
+		ServerName:  r.ServerName,
+		Environment: r.Environment,
+		Release:     r.Release,
+		Tags:        tags,
+		Exception: &Exception{
+			Values: []*ExceptionValue{{Type: reflect.TypeOf(err).String(), Value: err.Error()}},
+		},
+	}
+}
+
+// Send posts an event to the Sentry project.
+func (r *Reporter) Send(e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// Construct request.
+	req, err := http.NewRequest("POST", r.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth())
+
+	// Send request.
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// auth returns the X-Sentry-Auth header value.
+func (r *Reporter) auth() string {
+	s := "Sentry sentry_version=7, sentry_client=scuttlebutt/1.0, sentry_key=" + r.key
+	if r.secret != "" {
+		s += ", sentry_secret=" + r.secret
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package sentry_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/sentry"
+)
+
+// Ensure the store endpoint is derived from the DSN.
+func TestNewReporter(t *testing.T) {
+	r, err := sentry.NewReporter("https://abc@o1.ingest.sentry.io/42")
+	if err != nil {
+		t.Fatal(err)
+	} else if u := r.URL(); u != "https://o1.ingest.sentry.io/api/42/store/" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+
+	if _, err := sentry.NewReporter("https://sentry.io/42"); err != sentry.ErrInvalidDSN {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := sentry.NewReporter("https://abc@sentry.io/"); err != sentry.ErrInvalidDSN {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure reported errors are posted with their tags & authentication.
+func TestReporter_ReportError(t *testing.T) {
+	var events []*sentry.Event
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/store/" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		} else if auth := r.Header.Get("X-Sentry-Auth"); !strings.Contains(auth, "sentry_key=abc") {
+			t.Fatalf("unexpected auth: %s", auth)
+		}
+
+		var e sentry.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, &e)
+	}))
+	defer s.Close()
+
+	r, err := sentry.NewReporter(strings.Replace(s.URL, "http://", "http://abc@", 1) + "/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Environment = "test"
+	if err := r.Open(); err != nil {
+		t.Fatal(err)
+	}
+	r.ReportError(errors.New("marker"), map[string]string{"op": "poll"})
+	r.Close()
+
+	if len(events) != 1 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if e := events[0]; e.Message != "marker" || e.Tags["op"] != "poll" || e.Environment != "test" || len(e.EventID) != 32 {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if v := e.Exception.Values[0]; v.Type != "*errors.errorString" || v.Value != "marker" {
+		t.Fatalf("unexpected exception: %#v", v)
+	}
+}
+
+// Ensure non-2xx responses are returned as errors.
+func TestReporter_Send_ErrUnexpectedStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+
+	r, err := sentry.NewReporter(strings.Replace(s.URL, "http://", "http://abc@", 1) + "/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := r.NewEvent(errors.New("marker"), nil, time.Now())
+	if err := r.Send(e); err == nil || err.Error() != "unexpected status: 429" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
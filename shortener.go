@@ -0,0 +1,36 @@
+package scuttlebutt
+
+import (
+	"fmt"
+)
+
+// Shortener represents a service that shortens URLs.
+type Shortener interface {
+	Shorten(longURL string) (string, error)
+}
+
+// StoreShortener shortens URLs and saves each mapping in the store so that a
+// URL is only shortened once and clicks can be attributed to repositories.
+type StoreShortener struct {
+	Store     *Store
+	Shortener Shortener
+}
+
+// Shorten returns the saved short URL or shortens & saves a new one.
+func (s *StoreShortener) Shorten(longURL string) (string, error) {
+	// Return saved URL, if available.
+	if shortURL, err := s.Store.ShortURL(longURL); err != nil {
+		return "", err
+	} else if shortURL != "" {
+		return shortURL, nil
+	}
+
+	// Shorten with the underlying service and save the mapping.
+	shortURL, err := s.Shortener.Shorten(longURL)
+	if err != nil {
+		return "", fmt.Errorf("shorten: %s", err)
+	} else if err := s.Store.SaveShortURL(longURL, shortURL); err != nil {
+		return "", fmt.Errorf("save short url: %s", err)
+	}
+	return shortURL, nil
+}
@@ -0,0 +1,127 @@
+// Package shortener implements URL shortening using external providers.
+package shortener
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultBitlyURL is the Bitly API endpoint for shortening links.
+const DefaultBitlyURL = "https://api-ssl.bitly.com/v4/shorten"
+
+// ErrShortURLMissing is returned when a provider response has no short URL.
+var ErrShortURLMissing = errors.New("short url missing from response")
+
+// Bitly shortens URLs using the Bitly API.
+type Bitly struct {
+	URL   string
+	Token string
+
+	HTTPClient *http.Client
+}
+
+// NewBitly returns a new instance of Bitly authorized with token.
+func NewBitly(token string) *Bitly {
+	return &Bitly{
+		URL:        DefaultBitlyURL,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Shorten returns a bit.ly link for longURL.
+func (s *Bitly) Shorten(longURL string) (string, error) {
+	body, err := json.Marshal(map[string]string{"long_url": longURL})
+	if err != nil {
+		return "", err
+	}
+
+	// Construct request.
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request and parse the link.
+	var resp struct {
+		Link string `json:"link"`
+	}
+	if err := do(s.HTTPClient, req, &resp); err != nil {
+		return "", err
+	} else if resp.Link == "" {
+		return "", ErrShortURLMissing
+	}
+	return resp.Link, nil
+}
+
+// YOURLS shortens URLs using a self-hosted YOURLS instance.
+type YOURLS struct {
+	// API endpoint, e.g. https://example.com/yourls-api.php
+	URL string
+
+	// Secret signature token.
+	Token string
+
+	HTTPClient *http.Client
+}
+
+// NewYOURLS returns a new instance of YOURLS for an API endpoint.
+func NewYOURLS(u, token string) *YOURLS {
+	return &YOURLS{
+		URL:        u,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Shorten returns a short URL for longURL.
+func (s *YOURLS) Shorten(longURL string) (string, error) {
+	// Construct request.
+	params := url.Values{
+		"action":    {"shorturl"},
+		"format":    {"json"},
+		"signature": {s.Token},
+		"url":       {longURL},
+	}
+	req, err := http.NewRequest("POST", s.URL, bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Send request and parse the short URL. YOURLS returns an error status
+	// when the URL already exists but still includes the short URL.
+	var resp struct {
+		ShortURL string `json:"shorturl"`
+	}
+	if err := do(s.HTTPClient, req, &resp); err != nil && resp.ShortURL == "" {
+		return "", err
+	} else if resp.ShortURL == "" {
+		return "", ErrShortURLMissing
+	}
+	return resp.ShortURL, nil
+}
+
+// do sends req and decodes the JSON response into v. Returns an error for
+// non-2xx responses after decoding.
+func do(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(v)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	} else if decodeErr != nil {
+		return fmt.Errorf("decode: %s", decodeErr)
+	}
+	return nil
+}
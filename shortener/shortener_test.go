@@ -0,0 +1,52 @@
+package shortener_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/shortener"
+)
+
+// Ensure Bitly sends an authorized request and returns the link.
+func TestBitly_Shorten(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if r.Header.Get("Authorization") != "Bearer XXX" {
+			t.Fatalf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if body["long_url"] != "https://github.com/user/repo" {
+			t.Fatalf("unexpected long url: %s", body["long_url"])
+		}
+		w.Write([]byte(`{"link":"https://bit.ly/abc"}`))
+	}))
+	defer s.Close()
+
+	sh := shortener.NewBitly("XXX")
+	sh.URL = s.URL
+	if u, err := sh.Shorten("https://github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if u != "https://bit.ly/abc" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
+
+// Ensure YOURLS returns the short URL even if the URL was already shortened.
+func TestYOURLS_Shorten(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("signature") != "XXX" || r.FormValue("url") != "https://github.com/user/repo" {
+			t.Fatalf("unexpected form: %v", r.Form)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"fail","code":"error:url","shorturl":"https://sho.rt/abc"}`))
+	}))
+	defer s.Close()
+
+	if u, err := shortener.NewYOURLS(s.URL, "XXX").Shorten("https://github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if u != "https://sho.rt/abc" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
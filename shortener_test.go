@@ -0,0 +1,46 @@
+package scuttlebutt_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure URLs are only shortened once and the mapping is saved.
+func TestStoreShortener_Shorten(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	var n int
+	sh := &scuttlebutt.StoreShortener{
+		Store: s.Store,
+		Shortener: ShortenerFn(func(longURL string) (string, error) {
+			n++
+			return "https://sho.rt/abc", nil
+		}),
+	}
+
+	for i := 0; i < 2; i++ {
+		if u, err := sh.Shorten("https://github.com/user/repo"); err != nil {
+			t.Fatal(err)
+		} else if u != "https://sho.rt/abc" {
+			t.Fatalf("unexpected url: %s", u)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("unexpected shorten count: %d", n)
+	}
+
+	// Verify mapping is saved.
+	if a, err := s.ShortURLs(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []*scuttlebutt.ShortURL{{Short: "https://sho.rt/abc", Long: "https://github.com/user/repo"}}) {
+		t.Fatalf("unexpected short urls: %v", a)
+	}
+}
+
+// ShortenerFn represents a mock implementing scuttlebutt.Shortener.
+type ShortenerFn func(longURL string) (string, error)
+
+func (fn ShortenerFn) Shorten(longURL string) (string, error) { return fn(longURL) }
@@ -0,0 +1,153 @@
+package scuttlebutt
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SpamReason describes why a message was dropped as spam.
+type SpamReason string
+
+const (
+	// SpamSelfPromotion is used when the author owns the mentioned repository.
+	SpamSelfPromotion SpamReason = "self_promotion"
+
+	// SpamRepeated is used when the author has already mentioned the
+	// repository the maximum number of times.
+	SpamRepeated SpamReason = "repeated"
+
+	// SpamBot is used when the author matches a known bot pattern.
+	SpamBot SpamReason = "bot"
+
+	// SpamNegative is used when the text scores below the minimum sentiment.
+	SpamNegative SpamReason = "negative"
+
+	// SpamLowQuality is used when the author's account is too new or has
+	// too few followers.
+	SpamLowQuality SpamReason = "low_quality"
+
+	// SpamDuplicate is used when the same author or near-identical text
+	// already mentioned the repository within the duplicate window.
+	SpamDuplicate SpamReason = "duplicate"
+)
+
+// SpamError is returned when a message is dropped by the spam filter.
+type SpamError struct {
+	Reason SpamReason
+}
+
+func (e *SpamError) Error() string { return "spam: " + string(e.Reason) }
+
+// SpamFilter represents heuristics for dropping mentions that do not reflect
+// genuine interest in a repository. Author heuristics are skipped for
+// messages without a known author.
+type SpamFilter struct {
+	// If true, mentions by the repository's owner are dropped.
+	DropSelfPromotion bool
+
+	// Maximum number of mentions of a repository counted per author.
+	// Unlimited if zero.
+	MaxAuthorMentions int
+
+	// Patterns matched against author screen names to detect bots.
+	BotPatterns []*regexp.Regexp
+
+	// Optional scorer for dropping overwhelmingly negative mentions, such
+	// as complaints, that score below MinSentiment.
+	Sentiment    SentimentScorer
+	MinSentiment float64
+
+	// Minimum age & follower count of the author's account, such as to
+	// drop link-spam botnets. Only checked if the source reports the
+	// account. Disabled if zero.
+	MinAuthorAge       time.Duration
+	MinAuthorFollowers int
+
+	// Window in which repeated mentions of a repository by the same author
+	// or with near-identical text are counted once, such as posts from
+	// scheduled cross-posting tools. Disabled if zero.
+	DuplicateWindow time.Duration
+}
+
+// Check returns the reason m is spam given the number of prior mentions of
+// its repository by the same author. Returns a blank reason if m is not spam.
+func (f *SpamFilter) Check(m *Message, prior int) SpamReason {
+	if f.Sentiment != nil && f.Sentiment.Score(m.Text) < f.MinSentiment {
+		return SpamNegative
+	} else if m.Author == "" {
+		return ""
+	}
+
+	for _, re := range f.BotPatterns {
+		if re.MatchString(m.Author) {
+			return SpamBot
+		}
+	}
+
+	if f.lowQuality(m) {
+		return SpamLowQuality
+	}
+
+	if f.DropSelfPromotion {
+		if segments := strings.Split(m.RepositoryID, "/"); len(segments) == 3 && strings.EqualFold(segments[1], m.Author) {
+			return SpamSelfPromotion
+		}
+	}
+
+	if f.MaxAuthorMentions > 0 && prior >= f.MaxAuthorMentions {
+		return SpamRepeated
+	}
+	return ""
+}
+
+// Duplicate returns true if m repeats an earlier mention of the same
+// repository within the duplicate window. Messages repeat if they have the
+// same author or the same text, ignoring links, case, and punctuation.
+func (f *SpamFilter) Duplicate(m, prior *Message) bool {
+	if f.DuplicateWindow <= 0 || m.Time.IsZero() || prior.Time.IsZero() {
+		return false
+	} else if d := m.Time.Sub(prior.Time); d >= f.DuplicateWindow || d <= -f.DuplicateWindow {
+		return false
+	}
+
+	if m.Author != "" && strings.EqualFold(m.Author, prior.Author) {
+		return true
+	}
+	text := normalizeText(m.Text)
+	return text != "" && text == normalizeText(prior.Text)
+}
+
+// normalizeText returns the lowercase words in text without links, mentions,
+// or a retweet prefix so that cross-posted copies of a message match.
+func normalizeText(text string) string {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if word == "rt" || strings.HasPrefix(word, "@") || strings.Contains(word, "://") {
+			continue
+		}
+		word = strings.TrimFunc(word, func(ch rune) bool { return !unicode.IsLetter(ch) && !unicode.IsDigit(ch) })
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// lowQuality returns true if the author's account was younger than the
+// minimum age when m was posted or has fewer than the minimum followers.
+func (f *SpamFilter) lowQuality(m *Message) bool {
+	if m.AuthorCreatedAt.IsZero() {
+		return false
+	}
+
+	postedAt := m.Time
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+	if f.MinAuthorAge > 0 && postedAt.Sub(m.AuthorCreatedAt) < f.MinAuthorAge {
+		return true
+	}
+	return m.AuthorFollowers < f.MinAuthorFollowers
+}
@@ -0,0 +1,101 @@
+package scuttlebutt_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure the spam filter detects self-promotion, repeated mentions, and bots.
+func TestSpamFilter_Check(t *testing.T) {
+	f := &scuttlebutt.SpamFilter{
+		DropSelfPromotion: true,
+		MaxAuthorMentions: 2,
+		BotPatterns:       []*regexp.Regexp{regexp.MustCompile(`(?i)bot$`)},
+	}
+	for i, tt := range []struct {
+		author string
+		prior  int
+		reason scuttlebutt.SpamReason
+	}{
+		{author: "fan", prior: 1},
+		{author: "", prior: 10},
+		{author: "OWNER", reason: scuttlebutt.SpamSelfPromotion},
+		{author: "fan", prior: 2, reason: scuttlebutt.SpamRepeated},
+		{author: "GitHubTrendBot", reason: scuttlebutt.SpamBot},
+	} {
+		m := &scuttlebutt.Message{RepositoryID: "github.com/owner/repo", Author: tt.author}
+		if reason := f.Check(m, tt.prior); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+}
+
+// Ensure the spam filter drops mentions by new or unfollowed accounts.
+func TestSpamFilter_Check_LowQuality(t *testing.T) {
+	f := &scuttlebutt.SpamFilter{MinAuthorAge: 30 * 24 * time.Hour, MinAuthorFollowers: 10}
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, tt := range []struct {
+		followers int
+		createdAt time.Time
+		reason    scuttlebutt.SpamReason
+	}{
+		{followers: 10, createdAt: now.AddDate(0, -2, 0)},
+		{followers: 0},
+		{followers: 10, createdAt: now.AddDate(0, 0, -7), reason: scuttlebutt.SpamLowQuality},
+		{followers: 9, createdAt: now.AddDate(-5, 0, 0), reason: scuttlebutt.SpamLowQuality},
+	} {
+		m := &scuttlebutt.Message{RepositoryID: "github.com/owner/repo", Author: "fan", Time: now, AuthorFollowers: tt.followers, AuthorCreatedAt: tt.createdAt}
+		if reason := f.Check(m, 0); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+}
+
+// Ensure repeated mentions by an author or with the same text are detected
+// within the duplicate window.
+func TestSpamFilter_Duplicate(t *testing.T) {
+	f := &scuttlebutt.SpamFilter{DuplicateWindow: time.Hour}
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	prior := &scuttlebutt.Message{Author: "a", Text: "Check out my new CLI! https://t.co/abc", Time: now}
+	for i, tt := range []struct {
+		m   *scuttlebutt.Message
+		dup bool
+	}{
+		{m: &scuttlebutt.Message{Author: "A", Text: "something else", Time: now.Add(30 * time.Minute)}, dup: true},
+		{m: &scuttlebutt.Message{Author: "b", Text: "RT @a: check out my new CLI https://t.co/xyz", Time: now.Add(-30 * time.Minute)}, dup: true},
+		{m: &scuttlebutt.Message{Author: "b", Text: "check out my new CLI", Time: now.Add(time.Hour)}},
+		{m: &scuttlebutt.Message{Author: "b", Text: "check out their new CLI", Time: now}},
+		{m: &scuttlebutt.Message{Author: "a", Text: "check out my new CLI"}},
+	} {
+		if dup := f.Duplicate(tt.m, prior); dup != tt.dup {
+			t.Errorf("%d. unexpected duplicate: %v", i, dup)
+		}
+	}
+
+	// Disabled if no window is set.
+	if (&scuttlebutt.SpamFilter{}).Duplicate(prior, prior) {
+		t.Fatal("expected no duplicate")
+	}
+}
+
+// Ensure the spam filter drops negative mentions, even without an author.
+func TestSpamFilter_Check_Sentiment(t *testing.T) {
+	f := &scuttlebutt.SpamFilter{Sentiment: scuttlebutt.NewDefaultLexicon(), MinSentiment: scuttlebutt.DefaultMinSentiment}
+	for i, tt := range []struct {
+		text   string
+		reason scuttlebutt.SpamReason
+	}{
+		{text: "github.com/owner/repo is broken, avoid", reason: scuttlebutt.SpamNegative},
+		{text: "github.com/owner/repo is awesome"},
+		{text: "github.com/owner/repo is great but a bit buggy"},
+		{text: "github.com/owner/repo"},
+	} {
+		m := &scuttlebutt.Message{RepositoryID: "github.com/owner/repo", Text: tt.text}
+		if reason := f.Check(m, 0); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package scuttlebutt
+
+import "expvar"
+
+// Pipeline throughput counters, published with expvar under "pipeline".
+const (
+	StatMessagesFetched     = "messages_fetched"
+	StatMessagesAdded       = "messages_added"
+	StatMessagesDuplicate   = "messages_duplicate"
+	StatRepositoriesCreated = "repositories_created"
+	StatRemoteNotFound      = "remote_not_found"
+	StatNotificationsSent   = "notifications_sent"
+	StatNotificationsFailed = "notifications_failed"
+)
+
+// stats holds the pipeline throughput counters.
+var stats = expvar.NewMap("pipeline")
+
+func init() {
+	for _, key := range []string{
+		StatMessagesFetched, StatMessagesAdded, StatMessagesDuplicate,
+		StatRepositoriesCreated, StatRemoteNotFound,
+		StatNotificationsSent, StatNotificationsFailed,
+	} {
+		stats.Add(key, 0)
+	}
+}
+
+// Stat returns the current value of a pipeline counter.
+func Stat(key string) int64 {
+	if v, ok := stats.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
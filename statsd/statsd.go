@@ -0,0 +1,196 @@
+// Package statsd emits metrics to a statsd or DogStatsD agent over UDP.
+package statsd
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPrefix is the default prefix prepended to metric names.
+	DefaultPrefix = "scuttlebutt."
+
+	// DefaultFlushInterval is the default time between reports of expvar
+	// counters.
+	DefaultFlushInterval = 10 * time.Second
+)
+
+// DefaultVars are the expvar maps reported as counters by default.
+var DefaultVars = []string{"pipeline", "twitter"}
+
+// Client sends metrics to a statsd agent. A nil client sends nothing.
+type Client struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing chan struct{}
+	conn    net.Conn
+	prev    map[string]int64
+
+	// Address of the agent, such as "localhost:8125".
+	Addr string
+
+	// Prefix prepended to each metric name.
+	Prefix string
+
+	// Tags attached to every metric, such as "env:prod". Tags are sent
+	// using the DogStatsD extension and are ignored by plain statsd.
+	Tags []string
+
+	// Names of expvar maps whose integer values are reported as counters
+	// on each flush interval.
+	Vars          []string
+	FlushInterval time.Duration
+
+	// Destination for send errors.
+	LogOutput io.Writer
+}
+
+// NewClient returns a new instance of Client that sends to addr.
+func NewClient(addr string) *Client {
+	return &Client{
+		Addr:          addr,
+		Prefix:        DefaultPrefix,
+		Vars:          DefaultVars,
+		FlushInterval: DefaultFlushInterval,
+		LogOutput:     ioutil.Discard,
+	}
+}
+
+// Open connects to the agent and starts reporting expvar counters in the
+// background.
+func (c *Client) Open() error {
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return fmt.Errorf("dial: %s", err)
+	}
+	c.conn = conn
+
+	c.closing = make(chan struct{})
+	c.wg.Add(1)
+	go c.run()
+	return nil
+}
+
+// Close stops the background reporting and closes the connection.
+func (c *Client) Close() error {
+	if c.closing != nil {
+		close(c.closing)
+		c.wg.Wait()
+		c.closing = nil
+	}
+	if c.conn != nil {
+		c.Flush()
+		c.conn.Close()
+		c.conn = nil
+	}
+	return nil
+}
+
+// run reports expvar counters on each flush interval until closed.
+func (c *Client) run() {
+	defer c.wg.Done()
+
+	logger := log.New(c.LogOutput, "[statsd] ", log.LstdFlags)
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closing:
+			return
+		case <-ticker.C:
+			if err := c.Flush(); err != nil {
+				logger.Printf("flush error: %s", err)
+			}
+		}
+	}
+}
+
+// Flush reports the change in each expvar counter since the last flush.
+func (c *Client) Flush() error {
+	if c == nil || c.conn == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prev == nil {
+		c.prev = make(map[string]int64)
+	}
+
+	var err error
+	for _, name := range c.Vars {
+		m, ok := expvar.Get(name).(*expvar.Map)
+		if !ok {
+			continue
+		}
+
+		m.Do(func(kv expvar.KeyValue) {
+			v, ok := kv.Value.(*expvar.Int)
+			if !ok {
+				return
+			}
+
+			key := name + "." + kv.Key
+			n := v.Value()
+			if delta := n - c.prev[key]; delta != 0 {
+				if e := c.send(key, fmt.Sprintf("%d|c", delta), nil); e != nil && err == nil {
+					err = e
+				}
+			}
+			c.prev[key] = n
+		})
+	}
+	return err
+}
+
+// Count adds n to a counter.
+func (c *Client) Count(name string, n int64, tags ...string) {
+	if c == nil || c.conn == nil {
+		return
+	}
+	c.send(name, fmt.Sprintf("%d|c", n), tags)
+}
+
+// Gauge sets a gauge to v.
+func (c *Client) Gauge(name string, v int64, tags ...string) {
+	if c == nil || c.conn == nil {
+		return
+	}
+	c.send(name, fmt.Sprintf("%d|g", v), tags)
+}
+
+// Timing records a duration in milliseconds.
+func (c *Client) Timing(name string, d time.Duration, tags ...string) {
+	if c == nil || c.conn == nil {
+		return
+	}
+	c.send(name, fmt.Sprintf("%d|ms", d/time.Millisecond), tags)
+}
+
+// send writes a single metric packet to the agent.
+func (c *Client) send(name, value string, tags []string) error {
+	_, err := io.WriteString(c.conn, c.Format(name, value, tags))
+	return err
+}
+
+// Format returns the packet for a metric with its client & metric tags.
+func (c *Client) Format(name, value string, tags []string) string {
+	s := c.Prefix + name + ":" + value
+
+	a := make([]string, 0, len(c.Tags)+len(tags))
+	a = append(a, c.Tags...)
+	a = append(a, tags...)
+	if len(a) > 0 {
+		sort.Strings(a)
+		s += "|#" + strings.Join(a, ",")
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package statsd_test
+
+import (
+	"expvar"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/statsd"
+)
+
+// Ensure metrics are sent to the agent with client & metric tags.
+func TestClient_Count(t *testing.T) {
+	conn := MustListen()
+	defer conn.Close()
+
+	c := statsd.NewClient(conn.LocalAddr().String())
+	c.Tags = []string{"env:test"}
+	c.Vars = nil
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Count("api.calls", 2, "api:search")
+	if s := MustRead(conn); s != "scuttlebutt.api.calls:2|c|#api:search,env:test" {
+		t.Fatalf("unexpected packet: %s", s)
+	}
+
+	c.Timing("poll.duration", 1500*time.Millisecond)
+	if s := MustRead(conn); s != "scuttlebutt.poll.duration:1500|ms|#env:test" {
+		t.Fatalf("unexpected packet: %s", s)
+	}
+}
+
+// Ensure expvar counters are reported as the change since the last flush.
+func TestClient_Flush(t *testing.T) {
+	conn := MustListen()
+	defer conn.Close()
+
+	m := expvar.NewMap("statsd_test")
+	m.Add("n", 3)
+
+	c := statsd.NewClient(conn.LocalAddr().String())
+	c.Prefix = ""
+	c.Vars = []string{"statsd_test"}
+	c.FlushInterval = time.Hour
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	} else if s := MustRead(conn); s != "statsd_test.n:3|c" {
+		t.Fatalf("unexpected packet: %s", s)
+	}
+
+	m.Add("n", 2)
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	} else if s := MustRead(conn); s != "statsd_test.n:2|c" {
+		t.Fatalf("unexpected packet: %s", s)
+	}
+}
+
+// Ensure a nil client does not panic.
+func TestClient_Nil(t *testing.T) {
+	var c *statsd.Client
+	c.Count("x", 1)
+	c.Gauge("x", 1)
+	c.Timing("x", time.Second)
+}
+
+// MustListen returns a UDP listener on a random local port.
+func MustListen() net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	return conn
+}
+
+// MustRead reads a single packet from conn.
+func MustRead(conn net.PacketConn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		panic(err)
+	}
+	return string(buf[:n])
+}
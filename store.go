@@ -1,14 +1,23 @@
 package scuttlebutt
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/benbjohnson/scuttlebutt/events"
 	"github.com/benbjohnson/scuttlebutt/internal"
+	"github.com/benbjohnson/scuttlebutt/tracing"
 	"github.com/boltdb/bolt"
 	"github.com/gogo/protobuf/proto"
 )
@@ -18,24 +27,201 @@ import (
 var (
 	// ErrRepositoryNotFound is returned when operating on a non-existent repo.
 	ErrRepositoryNotFound = errors.New("repository not found")
+
+	// ErrPendingNotificationNotFound is returned when operating on a
+	// non-existent pending notification.
+	ErrPendingNotificationNotFound = errors.New("pending notification not found")
+
+	// ErrNotificationNotFound is returned when operating on a non-existent
+	// notification attempt.
+	ErrNotificationNotFound = errors.New("notification not found")
+
+	// ErrFlaggedRepositoryNotFound is returned when operating on a
+	// repository that has not been flagged.
+	ErrFlaggedRepositoryNotFound = errors.New("flagged repository not found")
+
+	// ErrOptedOut is returned when adding messages for a repository whose
+	// owner has opted out of being featured.
+	ErrOptedOut = errors.New("repository opted out")
+
+	// ErrBlacklisted is returned when adding messages for a blacklisted repository.
+	ErrBlacklisted = errors.New("repository blacklisted")
+
+	// ErrInvalidBlacklistPattern is returned when blacklisting a malformed pattern.
+	ErrInvalidBlacklistPattern = errors.New("invalid blacklist pattern")
+
+	// ErrInvalidLabel is returned when adding a malformed curation label.
+	ErrInvalidLabel = errors.New("invalid label")
+
+	// ErrAuthorRequired is returned when deleting messages without an author.
+	ErrAuthorRequired = errors.New("author required")
+
+	// ErrInvalidOptOutPattern is returned when adding a malformed opt-out.
+	ErrInvalidOptOutPattern = errors.New("invalid opt-out pattern")
+
+	// ErrNoMessageFile is returned when requesting the message file but
+	// messages are stored with repository metadata.
+	ErrNoMessageFile = errors.New("messages not stored separately")
+)
+
+// Error classes returned by ErrorClass.
+const (
+	ErrorClassRemote = "remote"
+	ErrorClassDecode = "decode"
+	ErrorClassStore  = "store"
+)
+
+// RemoteError is returned when the remote store fails to return a repository.
+type RemoteError struct {
+	Err error
+}
+
+func (e *RemoteError) Error() string { return "remote: " + e.Err.Error() }
+
+// DecodeError is returned when a stored repository cannot be decoded.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string { return "decode: " + e.Err.Error() }
+
+// ErrorClass returns the class of an error returned by the store.
+func ErrorClass(err error) string {
+	switch err.(type) {
+	case *RemoteError:
+		return ErrorClassRemote
+	case *DecodeError:
+		return ErrorClassDecode
+	default:
+		return ErrorClassStore
+	}
+}
+
+// RemoteRateLimitKey is the key that the remote store's API quota is saved
+// under alongside account quotas. It cannot collide with a Twitter username.
+const RemoteRateLimitKey = ":remote"
+
+// DefaultNotFoundTTL is the default time that missing repositories are cached.
+const DefaultNotFoundTTL = 24 * time.Hour
+
+// DefaultRetryBackoff is the time before the first retry of a failed message.
+// The time doubles with each failed attempt up to DefaultMaxRetryBackoff.
+const (
+	DefaultRetryBackoff    = time.Minute
+	DefaultMaxRetryBackoff = time.Hour
 )
 
+// DefaultOpenTimeout is the default time to wait for the database file lock.
+const DefaultOpenTimeout = 1 * time.Second
+
+// DefaultLookupConcurrency is the default number of remote lookups that run
+// at the same time.
+const DefaultLookupConcurrency = 4
+
 // Store represents the data storage for storing messages received and sent.
 // The store acts as a cache to the backing remote store for repository info.
+//
+// Messages can be kept in a separate file from repository metadata & notify
+// state by setting MessagePath. Writes that touch both files commit the
+// message file first. If the metadata commit then fails, the message file may
+// hold messages for a repository whose metadata is older or missing. These
+// are ignored until the repository is saved again and re-adding a message is
+// a no-op, so retrying the write is safe. Reads may likewise see messages
+// that are newer than the metadata read alongside them.
 type Store struct {
-	path string
-	db   *bolt.DB
+	path     string
+	db       *bolt.DB
+	openedAt time.Time
+
+	// Cached TopRepositories() result and the transaction & settings it
+	// was read with.
+	topMu    sync.Mutex
+	topKey   topCacheKey
+	topCache map[string]*Repository
+
+	// Optional path to a separate file for message data. Messages are stored
+	// with repository metadata if blank. Messages stored with metadata before
+	// the split are moved when their repository is next saved.
+	MessagePath string
+	messageDB   *bolt.DB
 
 	// The remote backing store.
-	RemoteStore interface {
-		Repository(id string) (*Repository, error)
-	}
+	RemoteStore RemoteStore
+
+	// Time to wait for the database file lock when opening. Waits
+	// indefinitely if zero.
+	OpenTimeout time.Duration
+
+	// If true, writes are not fsync'd, or the file is not fsync'd when it
+	// grows. Both trade durability after a crash for write throughput.
+	NoSync     bool
+	NoGrowSync bool
+
+	// If true, the database is opened read-only with a shared lock so
+	// another process can hold it open. Writes return an error. The
+	// database must already be initialized.
+	ReadOnly bool
+
+	// If true, concurrent message writes are coalesced into fewer
+	// transactions using bolt's Batch(). Batch size & delay use bolt's
+	// defaults unless set. Ignored if MessagePath is set.
+	Batch         bool
+	MaxBatchSize  int
+	MaxBatchDelay time.Duration
+
+	// If true, forks, archived, or disabled repositories are included in
+	// rankings. They are excluded by default.
+	IncludeForks    bool
+	IncludeArchived bool
+	IncludeDisabled bool
+
+	// Optional activity thresholds. Unnotified repositories below them are
+	// not ranked by TopRepositories() or the Top*Repositories() queries so
+	// the next eligible repository is picked instead.
+	Thresholds *Thresholds
+
+	// Optional table used to normalize repository languages when they are
+	// stored and ranked. Languages are unchanged if nil.
+	LanguageAliases LanguageAliases
+
+	// Opt-out patterns from configuration. These are checked in addition
+	// to opt-outs saved with AddOptOut().
+	OptOutPatterns []string
+
+	// Time that repositories missing from the remote store are cached so
+	// repeated mentions of dead links are not looked up again. Disabled
+	// if not positive.
+	NotFoundTTL time.Duration
+
+	// Maximum number of repositories fetched from the remote store at the
+	// same time when it does not support batching. Lookups are sequential
+	// if not positive.
+	LookupConcurrency int
+
+	// Optional filter for dropping spam messages. Dropped messages return
+	// a *SpamError from AddMessages().
+	SpamFilter *SpamFilter
+
+	// Weight of each mention by message source when ranking repositories,
+	// such as to count a mention on "hn" more than one on "twitter".
+	// Sources without a weight count as one mention.
+	SourceWeights map[string]float64
+
+	// Optional bus that added messages & created repositories are
+	// published to.
+	Events *events.Bus
+
+	// Optional tracer for remote lookups & transactions.
+	Tracer *tracing.Tracer
 }
 
 // NewStore returns a new instance of Store.
 func NewStore(path string) *Store {
 	return &Store{
-		path: path,
+		path:              path,
+		OpenTimeout:       DefaultOpenTimeout,
+		NotFoundTTL:       DefaultNotFoundTTL,
+		LookupConcurrency: DefaultLookupConcurrency,
 	}
 }
 
@@ -45,191 +231,3117 @@ func (s *Store) Path() string { return s.path }
 // Open opens and initializes the database.
 func (s *Store) Open() error {
 	// Open underlying data store.
-	db, err := bolt.Open(s.path, 0666, &bolt.Options{Timeout: 1 * time.Second})
+	db, err := bolt.Open(s.path, 0666, s.boltOptions())
 	if err != nil {
 		return err
 	}
+	db.NoSync = s.NoSync
 	s.db = db
+	s.openedAt = time.Now()
+
+	// Apply batch settings, if specified.
+	if s.MaxBatchSize > 0 {
+		db.MaxBatchSize = s.MaxBatchSize
+	}
+	if s.MaxBatchDelay > 0 {
+		db.MaxBatchDelay = s.MaxBatchDelay
+	}
+
+	// Open separate message file, if specified.
+	if s.MessagePath != "" {
+		mdb, err := bolt.Open(s.MessagePath, 0666, s.boltOptions())
+		if err != nil {
+			s.Close()
+			return err
+		}
+		mdb.NoSync = s.NoSync
+		s.messageDB = mdb
+
+		if err := initBuckets(mdb, []string{"messages"}, s.ReadOnly); err != nil {
+			s.Close()
+			return err
+		}
+	}
 
 	// Initialize all the required buckets.
-	if err := s.db.Update(func(tx *bolt.Tx) error {
-		tx.CreateBucketIfNotExists([]byte("repositories"))
-		tx.CreateBucketIfNotExists([]byte("meta"))
-		return nil
-	}); err != nil {
+	if err := initBuckets(s.db, buckets, s.ReadOnly); err != nil {
 		s.Close()
 		return err
 	}
 
+	// Index databases created before the search index existed and
+	// repositories saved before IDs preserved case.
+	if !s.ReadOnly {
+		if err := s.migrateRepositoryIDs(); err != nil {
+			s.Close()
+			return err
+		} else if err := s.buildSearchIndex(); err != nil {
+			s.Close()
+			return err
+		}
+	}
+
 	return nil
 }
 
+// buckets are the top-level buckets required in the main database file.
+var buckets = []string{
+	"repositories", "repository_ids", "meta", "short_urls", "pending",
+	"featured", "notifications", "rate_limits", "accounts", "flagged",
+	"opt_outs", "blacklist", "not_found", "fetched", "retries",
+	"snapshots", "search", "search_docs",
+}
+
+// initBuckets creates any missing buckets. Read-only databases cannot be
+// initialized so an error is returned if a bucket is missing.
+func initBuckets(db *bolt.DB, names []string, readOnly bool) error {
+	if readOnly {
+		return db.View(func(tx *bolt.Tx) error {
+			for _, name := range names {
+				if tx.Bucket([]byte(name)) == nil {
+					return errors.New("read-only database missing bucket: " + name)
+				}
+			}
+			return nil
+		})
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range names {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// boltOptions returns the options used to open the database files.
+func (s *Store) boltOptions() *bolt.Options {
+	return &bolt.Options{
+		Timeout:    s.OpenTimeout,
+		NoGrowSync: s.NoGrowSync,
+		ReadOnly:   s.ReadOnly,
+	}
+}
+
 // Close closes the store.
 func (s *Store) Close() error {
 	if s.db != nil {
 		s.db.Close()
 	}
+	if s.messageDB != nil {
+		s.messageDB.Close()
+	}
 	return nil
 }
 
 // Ping connects to the database. Returns nil if successful.
 func (s *Store) Ping() error {
-	return s.db.View(func(tx *bolt.Tx) error { return nil })
+	return s.view(func(tx *storeTx) error {
+		_, err := tx.messageBucket()
+		return err
+	})
+}
+
+// Version returns an opaque version of the store's data. The version changes
+// after every write and whenever the store is reopened, so it can be used
+// to detect changes without reading the data.
+func (s *Store) Version() (string, error) {
+	var txID int
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		txID = tx.ID()
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(s.openedAt.UnixNano(), 16) + "-" + strconv.Itoa(txID), nil
 }
 
 // AddMessage adds a message related to a repository.
 // Retrieves repository data from the remote store, if needed.
-func (s *Store) AddMessage(m *Message) error {
-	if err := s.db.Update(func(tx *bolt.Tx) error {
-		// Retrieve repository.
-		r, err := s.repository(tx, m.RepositoryID)
+func (s *Store) AddMessage(ctx context.Context, m *Message) error {
+	return s.AddMessages(ctx, []*Message{m})[0]
+}
+
+// AddMessages adds multiple messages in a single write transaction.
+// Returns an error for each message, in order. Repositories missing from the
+// local store are retrieved from the remote store outside of the transaction.
+// Remote lookups that have not completed once ctx is done return an error.
+func (s *Store) AddMessages(ctx context.Context, a []*Message) []error {
+	return s.WriteMessages(s.ResolveMessages(ctx, a))
+}
+
+// Resolution represents a set of messages whose missing repositories have
+// been retrieved from the remote store. It is written by WriteMessages().
+type Resolution struct {
+	Messages []*Message
+
+	errs       []error
+	remote     map[string]*Repository
+	remoteErrs map[string]error
+	now        time.Time
+	err        error
+}
+
+// ResolveMessages retrieves the repositories for a set of messages that are
+// missing from the local store. No messages are written.
+func (s *Store) ResolveMessages(ctx context.Context, a []*Message) *Resolution {
+	errs := make([]error, len(a))
+	res := &Resolution{Messages: a, errs: errs}
+
+	// Find repositories that are not in the local store. Opted out
+	// repositories are never fetched and recently missing repositories
+	// are not fetched again until their cache entry expires.
+	now := time.Now()
+	missing := make(map[string]struct{})
+	var throttle *RateLimitError
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		// Lookups are queued if the remote quota was exhausted.
+		limits, err := rateLimits(tx, RemoteRateLimitKey)
 		if err != nil {
 			return err
 		}
+		for _, rl := range limits {
+			if rl.Throttled(now) {
+				throttle = &RateLimitError{Reset: rl.Reset}
+			}
+		}
 
-		// If repository is not in local store then fetch it remotely.
-		if r == nil {
-			repo, err := s.RemoteStore.Repository(m.RepositoryID)
-			if err != nil {
-				return fmt.Errorf("remote: %s", err)
-			} else if repo == nil {
-				return ErrRepositoryNotFound
+		bkt := tx.Bucket([]byte("repositories"))
+		for i, m := range a {
+			// Use the stored casing of the repository ID, if known.
+			m.RepositoryID = canonicalRepositoryID(tx, m.RepositoryID)
+
+			if bkt.Get([]byte(m.RepositoryID)) == nil && s.notFound(tx, m.RepositoryID, now) {
+				errs[i] = ErrRepositoryNotFound
+				continue
+			}
+
+			if optedOut, err := s.optedOut(tx, m.RepositoryID); err != nil {
+				return err
+			} else if optedOut {
+				errs[i] = ErrOptedOut
+			} else if blacklisted(tx, m.RepositoryID) {
+				errs[i] = ErrBlacklisted
+			} else if bkt.Get([]byte(m.RepositoryID)) == nil {
+				missing[m.RepositoryID] = struct{}{}
+			}
+		}
+		return nil
+	}); err != nil {
+		res.err = err
+		return res
+	}
+
+	// Fetch missing repositories remotely, in a single request if the remote
+	// store supports batching or concurrently otherwise. Once the quota is
+	// exhausted the remaining lookups return a *RateLimitError so they can
+	// be retried.
+	remote := make(map[string]*Repository, len(missing))
+	remoteErrs := make(map[string]error)
+	throttled := throttle != nil
+	if throttle != nil {
+		for id := range missing {
+			remoteErrs[id] = throttle
+		}
+	} else if r, ok := s.RemoteStore.(BatchRemoteStore); ok && len(missing) > 0 {
+		throttle = s.fetchBatch(ctx, r, missing, remote, remoteErrs)
+	} else if len(missing) > 0 {
+		throttle = s.fetchAll(ctx, missing, remote, remoteErrs)
+	}
+	for i, m := range a {
+		if errs[i] == nil {
+			errs[i] = remoteErrs[m.RepositoryID]
+		}
+	}
+	for _, err := range remoteErrs {
+		if err == ErrRepositoryNotFound {
+			stats.Add(StatRemoteNotFound, 1)
+		}
+	}
+
+	// Use the remote casing of new repository IDs so case variants are
+	// merged into a single repository.
+	for _, m := range a {
+		if r := remote[m.RepositoryID]; r != nil && r.ID != m.RepositoryID && strings.EqualFold(r.ID, m.RepositoryID) {
+			m.RepositoryID = r.ID
+			remote[r.ID] = r
+		}
+	}
+
+	// Persist the remote quota so lookups resume at the reset time, even
+	// across restarts.
+	if !throttled && len(missing) > 0 {
+		if err := s.saveRemoteRateLimits(throttle); err != nil {
+			res.err = err
+			return res
+		}
+	}
+
+	res.remote, res.remoteErrs, res.now = remote, remoteErrs, now
+	return res
+}
+
+// WriteMessages appends resolved messages to their repositories in a single
+// write transaction. Returns an error for each message, in order.
+func (s *Store) WriteMessages(res *Resolution) []error {
+	a, errs := res.Messages, res.errs
+	if res.err != nil {
+		return fillErrors(errs, res.err)
+	}
+	remote, remoteErrs, now := res.remote, res.remoteErrs, res.now
+
+	// Append messages to their repositories. The function may be retried
+	// when batching so all state is rebuilt on each call.
+	txErrs := make([]error, len(a))
+	var added []*Message
+	var created []*internal.Repository
+	var languages map[string]string
+	var duplicateN int
+	if err := s.batch(func(tx *storeTx) error {
+		added, created, duplicateN = nil, nil, 0
+		languages = make(map[string]string)
+
+		// Cache repositories missing from the remote store.
+		if err := s.cacheNotFound(tx.Tx, remote, remoteErrs, now); err != nil {
+			return err
+		}
+
+		repos := make(map[string]*internal.Repository)
+		changed := make(map[string]bool)
+		for i, m := range a {
+			txErrs[i] = errs[i]
+			if txErrs[i] != nil {
+				continue
+			}
+
+			// Check again in case the repository was blacklisted, taken down,
+			// or opted out while it was being fetched.
+			if optedOut, err := s.optedOut(tx.Tx, m.RepositoryID); err != nil {
+				return err
+			} else if optedOut {
+				txErrs[i] = ErrOptedOut
+				continue
+			} else if blacklisted(tx.Tx, m.RepositoryID) {
+				txErrs[i] = ErrBlacklisted
+				continue
+			}
+
+			// Retrieve repository from the transaction or local cache.
+			r := repos[m.RepositoryID]
+			if r == nil {
+				pb, err := s.repository(tx, m.RepositoryID)
+				if err != nil {
+					txErrs[i] = err
+					continue
+				} else if pb == nil && remote[m.RepositoryID] != nil {
+					pb = encodeRepository(remote[m.RepositoryID])
+					created = append(created, pb)
+					if err := setFetchedAt(tx.Tx, m.RepositoryID, now); err != nil {
+						return err
+					}
+				} else if pb == nil {
+					txErrs[i] = ErrRepositoryNotFound
+					continue
+				}
+				r = pb
+				repos[m.RepositoryID] = r
+			}
+
+			// Ignore duplicate messages and drop spam.
+			if hasMessage(r, m.ID) {
+				duplicateN++
+				continue
+			} else if s.SpamFilter != nil {
+				if reason := s.SpamFilter.Check(m, authorMessageN(r, m.Author)); reason != "" {
+					txErrs[i] = &SpamError{Reason: reason}
+					continue
+				} else if hasDuplicate(s.SpamFilter, r, m) {
+					txErrs[i] = &SpamError{Reason: SpamDuplicate}
+					continue
+				}
+			}
+			pb := encodeMessage(m)
+			r.Messages = append(r.Messages, pb)
+			if err := indexMessage(tx.Tx, r.GetID(), pb); err != nil {
+				return err
+			}
+			changed[m.RepositoryID] = true
+			added = append(added, m)
+			languages[m.RepositoryID] = s.LanguageAliases.Normalize(r.GetLanguage())
+		}
+
+		// Save updated repositories. New repositories that only received
+		// spam are not saved so they cannot be ranked without mentions.
+		for id := range changed {
+			if err := s.saveRepository(tx, repos[id]); err != nil {
+				return err
 			}
+		}
+		return nil
+	}); err != nil {
+		return fillErrors(errs, err)
+	}
 
-			// Convert to internal format.
-			r = encodeRepository(repo)
+	// Publish new repositories, followed by their messages. Repositories
+	// that only received spam were not saved.
+	for _, r := range created {
+		if len(r.Messages) > 0 {
+			stats.Add(StatRepositoriesCreated, 1)
+			s.Events.Publish(repositoryCreatedEvent(r))
 		}
+	}
+	stats.Add(StatMessagesAdded, int64(len(added)))
+	stats.Add(StatMessagesDuplicate, int64(duplicateN))
+	for _, m := range added {
+		s.Events.Publish(&events.MessageAdded{ID: m.ID, RepositoryID: m.RepositoryID, Language: languages[m.RepositoryID], Text: m.Text, URL: m.URL, Author: m.Author})
+	}
+	return txErrs
+}
+
+// fetch retrieves a repository from the remote store.
+func (s *Store) fetch(ctx context.Context, id string) (*Repository, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	span := s.Tracer.Start("remote.lookup")
+	span.SetAttribute("repository_id", id)
+	repo, err := s.RemoteStore.Repository(ctx, id)
+	span.SetAttribute("found", repo != nil)
+	span.SetError(err)
+	span.End()
+	return repo, err
+}
+
+// fetchAll retrieves missing repositories using up to LookupConcurrency
+// lookups at a time and sets the results in remote & remoteErrs. Lookups
+// that have not started once the quota is exhausted are not made. Returns
+// the error if the quota is exhausted.
+func (s *Store) fetchAll(ctx context.Context, missing map[string]struct{}, remote map[string]*Repository, remoteErrs map[string]error) *RateLimitError {
+	ids := make([]string, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	n := s.LookupConcurrency
+	if n < 1 {
+		n = 1
+	} else if n > len(ids) {
+		n = len(ids)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var throttle *RateLimitError
+	ch := make(chan string)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ch {
+				mu.Lock()
+				e := throttle
+				mu.Unlock()
+				if e != nil {
+					mu.Lock()
+					remoteErrs[id] = e
+					mu.Unlock()
+					continue
+				}
 
-		// Ensure message doesn't already exist.
-		for _, msg := range r.GetMessages() {
-			if msg.GetID() == m.ID {
-				return errDuplicateMessage
+				repo, err := s.fetch(ctx, id)
+				if repo != nil {
+					repo = s.normalize(repo)
+				}
+
+				mu.Lock()
+				if e, ok := err.(*RateLimitError); ok {
+					if throttle == nil {
+						throttle = e
+					}
+					remoteErrs[id] = e
+				} else if err != nil {
+					remoteErrs[id] = &RemoteError{Err: err}
+				} else if repo == nil {
+					remoteErrs[id] = ErrRepositoryNotFound
+				} else {
+					remote[id] = repo
+				}
+				mu.Unlock()
 			}
+		}()
+	}
+	for _, id := range ids {
+		ch <- id
+	}
+	close(ch)
+	wg.Wait()
+
+	return throttle
+}
+
+// fetchBatch retrieves missing repositories in a single batch and sets the
+// results in remote & remoteErrs. Returns the error if the quota is exhausted.
+func (s *Store) fetchBatch(ctx context.Context, r BatchRemoteStore, missing map[string]struct{}, remote map[string]*Repository, remoteErrs map[string]error) *RateLimitError {
+	ids := make([]string, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	span := s.Tracer.Start("remote.batch_lookup")
+	span.SetAttribute("repositories", len(ids))
+	m, err := r.Repositories(ctx, ids)
+	span.SetAttribute("found", len(m))
+	span.SetError(err)
+	span.End()
+	if e, ok := err.(*RateLimitError); ok {
+		for _, id := range ids {
+			remoteErrs[id] = e
+		}
+		return e
+	} else if err != nil {
+		for _, id := range ids {
+			remoteErrs[id] = &RemoteError{Err: err}
+		}
+		return nil
+	}
+
+	for _, id := range ids {
+		if repo := m[id]; repo != nil {
+			remote[id] = s.normalize(repo)
+		} else {
+			remoteErrs[id] = ErrRepositoryNotFound
+		}
+	}
+	return nil
+}
+
+// saveRemoteRateLimits persists the remote store's quota. If the remote store
+// does not report its quota then an exhausted quota is saved from throttle.
+func (s *Store) saveRemoteRateLimits(throttle *RateLimitError) error {
+	var limits []*RateLimit
+	if r, ok := s.RemoteStore.(interface {
+		RateLimits() []*RateLimit
+	}); ok {
+		limits = r.RateLimits()
+	}
+	if limits == nil && throttle != nil {
+		limits = []*RateLimit{{Resource: "remote", Reset: throttle.Reset}}
+	}
+	if limits == nil {
+		return nil
+	}
+	return s.SaveRateLimits(RemoteRateLimitKey, limits)
+}
+
+// notFound returns true if a repository was missing from the remote store
+// within the not found TTL.
+func (s *Store) notFound(tx *bolt.Tx, id string, now time.Time) bool {
+	if s.NotFoundTTL <= 0 {
+		return false
+	}
+	v := tx.Bucket([]byte("not_found")).Get([]byte(id))
+	if len(v) != 8 {
+		return false
+	}
+	return now.Sub(time.Unix(0, int64(btou64(v)))) < s.NotFoundTTL
+}
+
+// cacheNotFound records the time repositories were missing from the remote
+// store and clears entries for repositories that were found.
+func (s *Store) cacheNotFound(tx *bolt.Tx, remote map[string]*Repository, remoteErrs map[string]error, now time.Time) error {
+	bkt := tx.Bucket([]byte("not_found"))
+	for id := range remote {
+		if err := bkt.Delete([]byte(id)); err != nil {
+			return err
+		}
+	}
+
+	if s.NotFoundTTL <= 0 {
+		return nil
+	}
+	for id, err := range remoteErrs {
+		if err != ErrRepositoryNotFound {
+			continue
+		} else if err := bkt.Put([]byte(id), u64tob(uint64(now.UnixNano()))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeTx represents a transaction on the metadata file and, if messages are
+// stored separately, on the message file. The message transaction is only
+// started once messages are accessed.
+type storeTx struct {
+	*bolt.Tx
+	store    *Store
+	messages *bolt.Tx
+}
+
+// messageBucket returns the bucket in the message file.
+// Returns nil if messages are stored with repository metadata.
+func (tx *storeTx) messageBucket() (*bolt.Bucket, error) {
+	if tx.store.messageDB == nil {
+		return nil, nil
+	}
+	if tx.messages == nil {
+		mtx, err := tx.store.messageDB.Begin(tx.Writable())
+		if err != nil {
+			return nil, err
+		}
+		tx.messages = mtx
+	}
+	return tx.messages.Bucket([]byte("messages")), nil
+}
+
+// commit commits the message transaction, if one was started for writing.
+func (tx *storeTx) commit() error {
+	if tx.messages == nil || !tx.messages.Writable() {
+		return tx.rollback()
+	}
+	err := tx.messages.Commit()
+	tx.messages = nil
+	return err
+}
+
+// rollback discards the message transaction, if one was started.
+func (tx *storeTx) rollback() error {
+	if tx.messages == nil {
+		return nil
+	}
+	err := tx.messages.Rollback()
+	tx.messages = nil
+	return err
+}
+
+// view executes fn in a read-only transaction.
+func (s *Store) view(fn func(*storeTx) error) error {
+	span := s.Tracer.Start("bolt.view")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stx := &storeTx{Tx: tx, store: s}
+		defer stx.rollback()
+		return fn(stx)
+	})
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// update executes fn in a write transaction.
+func (s *Store) update(fn func(*storeTx) error) error {
+	span := s.Tracer.Start("bolt.update")
+	err := s.write(fn)
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// write executes fn in a write transaction. The message file is committed
+// only once the metadata transaction has committed so messages are never
+// saved for a write that was rolled back. Messages whose file commit fails
+// are not loaded and are written again if they are added again.
+func (s *Store) write(fn func(*storeTx) error) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stx := &storeTx{Tx: tx, store: s}
+	defer stx.rollback()
+	if err := fn(stx); err != nil {
+		return err
+	} else if err := tx.Commit(); err != nil {
+		return err
+	}
+	return stx.commit()
+}
+
+// batch executes fn in a write transaction. Uses bolt's batching if enabled
+// so fn may be retried. Batching is not used with a separate message file
+// since bolt retries the rest of a batch after one call fails, which would
+// find the messages they already wrote to the file.
+func (s *Store) batch(fn func(*storeTx) error) error {
+	if !s.Batch || s.messageDB != nil {
+		return s.update(fn)
+	}
+
+	span := s.Tracer.Start("bolt.batch")
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		return fn(&storeTx{Tx: tx, store: s})
+	})
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// hasMessage returns true if r contains a message with the given id.
+func hasMessage(r *internal.Repository, id uint64) bool {
+	for _, msg := range r.GetMessages() {
+		if msg.GetID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// authorMessageN returns the number of messages in r by author.
+func authorMessageN(r *internal.Repository, author string) (n int) {
+	for _, msg := range r.GetMessages() {
+		if author != "" && strings.EqualFold(msg.GetAuthor(), author) {
+			n++
 		}
+	}
+	return n
+}
+
+// hasDuplicate returns true if m repeats one of r's messages within the
+// spam filter's duplicate window.
+func hasDuplicate(f *SpamFilter, r *internal.Repository, m *Message) bool {
+	if f.DuplicateWindow <= 0 {
+		return false
+	}
+	for _, msg := range r.GetMessages() {
+		if f.Duplicate(m, decodeMessage(msg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillErrors sets err on every element of errs that does not have an error.
+func fillErrors(errs []error, err error) []error {
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// ImportRepositories saves repository metadata into the local store so that
+// later messages referencing them do not require a remote lookup. Existing
+// repositories keep their messages and notified flag but have their metadata
+// replaced. Returns the number of repositories that were newly created.
+func (s *Store) ImportRepositories(a []*Repository) (n int, err error) {
+	var created []*internal.Repository
+	err = s.update(func(tx *storeTx) error {
+		for _, repo := range a {
+			// Use the stored casing of the repository ID, if known.
+			repo = s.normalize(repo)
+			repo.ID = canonicalRepositoryID(tx.Tx, repo.ID)
+
+			// Skip repositories whose owners have opted out or that have
+			// been blacklisted, such as by a takedown.
+			if optedOut, err := s.optedOut(tx.Tx, repo.ID); err != nil {
+				return err
+			} else if optedOut || blacklisted(tx.Tx, repo.ID) {
+				continue
+			}
+
+			// Retrieve existing repository, if available.
+			r, err := s.repository(tx, repo.ID)
+			if err != nil {
+				return err
+			}
+
+			// Create a new repository or overwrite metadata on existing one.
+			if r == nil {
+				r = encodeRepository(repo)
+				r.Messages, r.Notified = nil, proto.Bool(false)
+				created = append(created, r)
+				n++
+			} else {
+				updateRepositoryMetadata(r, repo)
+			}
+
+			if err := s.saveRepository(tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	stats.Add(StatRepositoriesCreated, int64(len(created)))
+	for _, r := range created {
+		s.Events.Publish(repositoryCreatedEvent(r))
+	}
+	return n, nil
+}
+
+// AddRepository retrieves a repository from the remote store and saves it,
+// such as for a curated pick that has not been mentioned. Existing
+// repositories keep their messages but have their metadata replaced.
+func (s *Store) AddRepository(ctx context.Context, id string) (*Repository, error) {
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		if blacklisted(tx, id) {
+			return ErrBlacklisted
+		} else if optedOut, err := s.optedOut(tx, id); err != nil {
+			return err
+		} else if optedOut {
+			return ErrOptedOut
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Fetch remotely outside of the write transaction.
+	repo, err := s.fetch(ctx, id)
+	if e, ok := err.(*RateLimitError); ok {
+		return nil, e
+	} else if err != nil {
+		return nil, &RemoteError{Err: err}
+	} else if repo == nil {
+		return nil, ErrRepositoryNotFound
+	}
+	repo = s.normalize(repo)
+
+	if _, err := s.ImportRepositories([]*Repository{repo}); err != nil {
+		return nil, err
+	}
+	return s.Repository(repo.ID)
+}
+
+// SetBoost sets the number of mentions added to a repository when ranking.
+func (s *Store) SetBoost(repositoryID string, n int) error {
+	return s.update(func(tx *storeTx) error {
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return ErrRepositoryNotFound
+		}
+
+		r.Boost = nil
+		if n != 0 {
+			r.Boost = proto.Int64(int64(n))
+		}
+		return s.saveRepository(tx, r)
+	})
+}
+
+// AddLabel adds a curation label to a repository.
+func (s *Store) AddLabel(repositoryID, label string) error {
+	if !ValidLabel(label) {
+		return ErrInvalidLabel
+	}
+	return s.update(func(tx *storeTx) error {
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return ErrRepositoryNotFound
+		} else if hasLabel(r.Labels, label) {
+			return nil
+		}
+
+		r.Labels = append(r.Labels, label)
+		sort.Strings(r.Labels)
+		return s.saveRepository(tx, r)
+	})
+}
+
+// RemoveLabel removes a curation label from a repository.
+func (s *Store) RemoveLabel(repositoryID, label string) error {
+	return s.update(func(tx *storeTx) error {
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return ErrRepositoryNotFound
+		}
+
+		labels := r.Labels[:0]
+		for _, other := range r.Labels {
+			if other != label {
+				labels = append(labels, other)
+			}
+		}
+		r.Labels = labels
+		return s.saveRepository(tx, r)
+	})
+}
+
+// repositoryCreatedEvent returns the event published for a new repository.
+func repositoryCreatedEvent(r *internal.Repository) *events.RepositoryCreated {
+	return &events.RepositoryCreated{RepositoryID: r.GetID(), Description: r.GetDescription(), Language: r.GetLanguage()}
+}
+
+// RefreshRepository retrieves the latest metadata for a repository from the
+// remote store and saves it. Messages and the notified flag are retained.
+func (s *Store) RefreshRepository(ctx context.Context, id string) (*Repository, error) {
+	// Never fetch repositories whose owners have opted out.
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		if optedOut, err := s.optedOut(tx, id); err != nil {
+			return err
+		} else if optedOut {
+			return ErrOptedOut
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Fetch remotely outside of the write transaction.
+	repo, err := s.fetch(ctx, id)
+	if e, ok := err.(*RateLimitError); ok {
+		return nil, e
+	} else if err != nil {
+		return nil, &RemoteError{Err: err}
+	} else if repo == nil {
+		// Record the attempt so repositories deleted remotely are not
+		// continually refreshed.
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			id := canonicalRepositoryID(tx, id)
+			if tx.Bucket([]byte("repositories")).Get([]byte(id)) == nil {
+				return nil
+			}
+			return setFetchedAt(tx, id, time.Now())
+		}); err != nil {
+			return nil, err
+		}
+		return nil, ErrRepositoryNotFound
+	}
+	repo = s.normalize(repo)
+
+	var r *Repository
+	if err := s.update(func(tx *storeTx) error {
+		pb, err := s.repository(tx, id)
+		if err != nil {
+			return err
+		} else if pb == nil {
+			return ErrRepositoryNotFound
+		}
+
+		updateRepositoryMetadata(pb, repo)
+		if err := s.saveRepository(tx, pb); err != nil {
+			return err
+		} else if err := setFetchedAt(tx.Tx, pb.GetID(), time.Now()); err != nil {
+			return err
+		}
+		r = decodeRepository(pb)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// StaleRepositories returns the IDs of up to n repositories whose metadata
+// was last fetched before the given time, oldest first. Repositories that
+// have never been refreshed, such as those imported, are returned first.
+// Blacklisted repositories are not returned. Returns all stale IDs if n is zero.
+func (s *Store) StaleRepositories(before time.Time, n int) ([]string, error) {
+	var a []*staleRepository
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		fetched := tx.Bucket([]byte("fetched"))
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			var t time.Time
+			if v := fetched.Get(k); len(v) == 8 {
+				t = time.Unix(0, int64(btou64(v)))
+			}
+			if !t.Before(before) || blacklisted(tx, string(k)) {
+				continue
+			}
+			a = append(a, &staleRepository{id: string(k), fetchedAt: t})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Stable(staleRepositoriesByFetchedAt(a))
+	if n > 0 && len(a) > n {
+		a = a[:n]
+	}
+
+	ids := make([]string, len(a))
+	for i := range a {
+		ids[i] = a[i].id
+	}
+	return ids, nil
+}
+
+// staleRepository represents a repository ID and when it was last fetched.
+type staleRepository struct {
+	id        string
+	fetchedAt time.Time
+}
+
+// setFetchedAt records the time a repository's metadata was fetched.
+func setFetchedAt(tx *bolt.Tx, id string, t time.Time) error {
+	return tx.Bucket([]byte("fetched")).Put([]byte(id), u64tob(uint64(t.UnixNano())))
+}
+
+// normalize returns a copy of r with its language normalized.
+func (s *Store) normalize(r *Repository) *Repository {
+	other := *r
+	other.Language = s.LanguageAliases.Normalize(r.Language)
+	return &other
+}
+
+// HasRepository returns true if the repository exists in the local store.
+func (s *Store) HasRepository(id string) (exists bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket([]byte("repositories")).Get([]byte(canonicalRepositoryID(tx, id))) != nil
+		return nil
+	})
+	return
+}
+
+// Repository returns a repository by id.
+func (s *Store) Repository(id string) (r *Repository, err error) {
+	err = s.view(func(tx *storeTx) error {
+		pb, err := s.repository(tx, id)
+		if err != nil || pb == nil {
+			return err
+		}
+		r = decodeRepository(pb)
+		return nil
+	})
+	return
+}
+
+// RepositorySources returns the number of a repository's messages from each
+// source. Returns ErrRepositoryNotFound if the repository does not exist.
+func (s *Store) RepositorySources(id string) (m map[string]int, err error) {
+	err = s.view(func(tx *storeTx) error {
+		pb, err := s.repository(tx, id)
+		if err != nil {
+			return err
+		} else if pb == nil {
+			return ErrRepositoryNotFound
+		}
+
+		m = make(map[string]int)
+		for _, msg := range pb.GetMessages() {
+			m[messageSource(msg.GetSource())]++
+		}
+		return nil
+	})
+	return
+}
+
+// RepositoryMessages returns a repository with at most n of its messages.
+// Messages are ordered by ID, newest first if desc is true. Also returns the
+// total number of messages for the repository. Returns a nil repository if
+// it does not exist.
+func (s *Store) RepositoryMessages(id string, n int, desc bool) (r *Repository, total int, err error) {
+	err = s.view(func(tx *storeTx) error {
+		pb, err := s.repository(tx, id)
+		if err != nil || pb == nil {
+			return err
+		}
+
+		// Order messages by ID.
+		messages := pb.GetMessages()
+		sort.Sort(messagesByID(messages))
+		if desc {
+			for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+				messages[i], messages[j] = messages[j], messages[i]
+			}
+		}
+
+		// Limit messages before decoding.
+		total = len(messages)
+		if n >= 0 && len(messages) > n {
+			messages = messages[:n]
+		}
+		pb.Messages = messages
+
+		r = decodeRepository(pb)
+		return nil
+	})
+	return
+}
+
+// Repositories returns all repositories.
+func (s *Store) Repositories() (a []*Repository, err error) {
+	err = s.ForEachRepository(context.Background(), func(r *Repository) error {
+		a = append(a, r)
+		return nil
+	})
+	return
+}
+
+// ForEachRepository calls fn for each repository, with its messages, in ID
+// order. Only one repository is decoded at a time so memory use is bounded
+// regardless of the size of the store. Iteration stops if fn returns an
+// error or once ctx is done. A read transaction is held open until iteration
+// completes.
+func (s *Store) ForEachRepository(ctx context.Context, fn func(r *Repository) error) error {
+	return s.view(func(tx *storeTx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			r, err := decodeRepositoryWithMessages(tx, v)
+			if err != nil {
+				return err
+			} else if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RepositoriesPage returns up to limit repositories, with their messages, in
+// ID order starting after the given ID. Pass a blank ID for the first page
+// and the ID of the last repository returned for each following page. An
+// empty page is returned once all repositories have been read.
+func (s *Store) RepositoriesPage(after string, limit int) (a []*Repository, err error) {
+	err = s.view(func(tx *storeTx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+
+		k, v := c.First()
+		if after != "" {
+			if k, v = c.Seek([]byte(after)); k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+		}
+
+		for ; k != nil && len(a) < limit; k, v = c.Next() {
+			r, err := decodeRepositoryWithMessages(tx, v)
+			if err != nil {
+				return err
+			}
+			a = append(a, r)
+		}
+		return nil
+	})
+	return
+}
+
+// decodeRepositoryWithMessages decodes an encoded repository and loads its messages.
+func decodeRepositoryWithMessages(tx *storeTx, v []byte) (*Repository, error) {
+	var pb internal.Repository
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	} else if err := loadMessages(tx, &pb); err != nil {
+		return nil, err
+	}
+	return decodeRepository(&pb), nil
+}
+
+// MentionTimeSeries returns the number of mentions of a repository posted on
+// each UTC day from since through until, inclusive. Days without mentions
+// are included with a zero count. Returns ErrRepositoryNotFound if the
+// repository does not exist.
+func (s *Store) MentionTimeSeries(id string, since, until time.Time) (ts *MentionTimeSeries, err error) {
+	err = s.view(func(tx *storeTx) error {
+		pb, err := s.repository(tx, id)
+		if err != nil {
+			return err
+		} else if pb == nil {
+			return ErrRepositoryNotFound
+		}
+
+		// Create a zero count for each day in the range.
+		ts = &MentionTimeSeries{RepositoryID: pb.GetID()}
+		index := make(map[time.Time]*DailyMentions)
+		for t := utcDay(since); !t.After(until); t = t.AddDate(0, 0, 1) {
+			d := &DailyMentions{Date: t}
+			ts.Days = append(ts.Days, d)
+			index[t] = d
+		}
+
+		for _, m := range pb.GetMessages() {
+			if m.Time == nil {
+				ts.Undated++
+			} else if d := index[utcDay(time.Unix(0, m.GetTime()))]; d != nil {
+				d.Mentions++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// RepositoryN returns the number of repositories in the store.
+func (s *Store) RepositoryN() (n int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			n++
+		}
+		return nil
+	})
+	return
+}
+
+// TopRepositories returns the most mentioned repositories by normalized
+// language. Repositories are also grouped under the TopicKey() of each of their topics.
+func (s *Store) TopRepositories() (map[string]*Repository, error) {
+	var m map[string]*Repository
+	err := s.view(func(tx *storeTx) error {
+		// Reuse the cached result if nothing has been written since.
+		key := topCacheKey{tx.ID(), s.IncludeForks, s.IncludeArchived, s.IncludeDisabled, s.Thresholds}
+		s.topMu.Lock()
+		if s.topCache != nil && s.topKey == key {
+			m = s.topCache
+		}
+		s.topMu.Unlock()
+		if m != nil {
+			return nil
+		}
+
+		m = make(map[string]*Repository)
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			// Decode repository.
+			var r internal.Repository
+			if err := proto.Unmarshal(v, &r); err != nil {
+				return &DecodeError{Err: err}
+			}
+
+			// Ignore marked, excluded, & below threshold repositories.
+			if r.GetNotified() || s.excluded(tx, &r) {
+				continue
+			} else if err := loadMessages(tx, &r); err != nil {
+				return err
+			} else if s.belowThresholds(&r) {
+				continue
+			}
+
+			// Group by language & topics.
+			keys := []string{s.LanguageAliases.Normalize(r.GetLanguage())}
+			for _, topic := range r.GetTopics() {
+				keys = append(keys, TopicKey(topic))
+			}
+
+			// Override repos that are ranked lower. Pinned repos rank
+			// above all others and mentions are weighted by source.
+			repo := decodeRepository(&r)
+			for _, key := range keys {
+				if m[key] != nil && !rankedAbove(repo, m[key], s.SourceWeights) {
+					continue
+				}
+				m[key] = repo
+			}
+		}
+
+		s.topMu.Lock()
+		s.topKey, s.topCache = key, m
+		s.topMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the cached result so callers cannot modify it. Repositories
+	// grouped under several keys remain shared between them.
+	other := make(map[string]*Repository, len(m))
+	clones := make(map[*Repository]*Repository, len(m))
+	for k, r := range m {
+		if clones[r] == nil {
+			clones[r] = r.Clone()
+		}
+		other[k] = clones[r]
+	}
+	return other, nil
+}
+
+// topCacheKey identifies the transaction & exclusion settings that a cached
+// TopRepositories() result was read with.
+type topCacheKey struct {
+	txID                                           int
+	includeForks, includeArchived, includeDisabled bool
+	thresholds                                     *Thresholds
+}
+
+// LanguageStats returns the number of repositories & mentions for each
+// normalized language, ordered by mention count. Mentions posted after since
+// are also counted as recent. Repositories without a language are ignored.
+func (s *Store) LanguageStats(since time.Time) (a []*LanguageStats, err error) {
+	err = s.view(func(tx *storeTx) error {
+		m := make(map[string]*LanguageStats)
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if pb.GetLanguage() == "" {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			}
+
+			lang := s.LanguageAliases.Normalize(pb.GetLanguage())
+			stats := m[lang]
+			if stats == nil {
+				stats = &LanguageStats{Language: lang}
+				m[lang] = stats
+				a = append(a, stats)
+			}
+			stats.Repositories++
+			stats.Mentions += len(pb.GetMessages())
+			for _, msg := range pb.GetMessages() {
+				if msg.Time != nil && time.Unix(0, msg.GetTime()).After(since) {
+					stats.RecentMentions++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(languageStatsByMentions(a))
+	return a, nil
+}
+
+// TopLanguageRepositories returns up to n unnotified repositories for a
+// language, ordered by mention count. Languages are compared after
+// normalization.
+func (s *Store) TopLanguageRepositories(lang string, n int) ([]*Repository, error) {
+	lang = s.LanguageAliases.Normalize(lang)
+	return s.topRepositories(n, func(pb *internal.Repository) bool {
+		return s.LanguageAliases.Normalize(pb.GetLanguage()) == lang
+	})
+}
+
+// TopTopicRepositories returns up to n unnotified repositories tagged with
+// a topic, ordered by mention count.
+func (s *Store) TopTopicRepositories(topic string, n int) ([]*Repository, error) {
+	return s.topRepositories(n, func(pb *internal.Repository) bool {
+		for _, t := range pb.GetTopics() {
+			if strings.EqualFold(t, topic) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// TopMatchingRepositories returns up to n unnotified repositories whose name
+// or description matches re, ordered by mention count.
+func (s *Store) TopMatchingRepositories(re *regexp.Regexp, n int) ([]*Repository, error) {
+	return s.topRepositories(n, func(pb *internal.Repository) bool {
+		return re.MatchString(path.Base(pb.GetID())) || re.MatchString(pb.GetDescription())
+	})
+}
+
+// RecentTopRepositories returns up to n unnotified repositories ordered by
+// their mentions posted after since. Each repository only includes those
+// mentions and mentions without a time are not counted. Only repositories in
+// lang are returned if it is set. Languages are compared after normalization.
+func (s *Store) RecentTopRepositories(lang string, since time.Time, n int) (a []*Repository, err error) {
+	lang = s.LanguageAliases.Normalize(lang)
+	err = s.view(func(tx *storeTx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if pb.GetNotified() || s.excluded(tx, &pb) {
+				continue
+			} else if lang != "" && s.LanguageAliases.Normalize(pb.GetLanguage()) != lang {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			}
+
+			r := decodeRepository(&pb)
+			messages := r.Messages[:0]
+			for _, m := range r.Messages {
+				if m.Time.After(since) {
+					messages = append(messages, m)
+				}
+			}
+			if r.Messages = messages; len(r.Messages) > 0 {
+				a = append(a, r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by weighted mentions. Cursor order breaks ties by ID.
+	sort.Stable(repositoriesByScore{a, s.SourceWeights})
+	if len(a) > n {
+		a = a[:n]
+	}
+	return a, nil
+}
+
+// topRepositories returns up to n unnotified repositories matching fn,
+// ordered by mention count.
+func (s *Store) topRepositories(n int, fn func(*internal.Repository) bool) (a []*Repository, err error) {
+	err = s.view(func(tx *storeTx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if pb.GetNotified() || s.excluded(tx, &pb) || !fn(&pb) {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			} else if s.belowThresholds(&pb) {
+				continue
+			}
+			a = append(a, decodeRepository(&pb))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by weighted mentions. Cursor order breaks ties by ID.
+	sort.Stable(repositoriesByScore{a, s.SourceWeights})
+	if len(a) > n {
+		a = a[:n]
+	}
+	return a, nil
+}
+
+// TopRepositoriesOverall returns the top n repositories across all languages.
+//
+// Scores are normalized by language so that a repository's mention count is
+// compared to the average mention count of other repositories in its language.
+// This prevents high-volume languages from dominating the ranking. Mentions are
+// weighted by their source's weight in SourceWeights.
+func (s *Store) TopRepositoriesOverall(n int) (a []*RankedRepository, err error) {
+	err = s.view(func(tx *storeTx) error {
+		c := tx.Bucket([]byte("repositories")).Cursor()
+
+		// Calculate the average mentions per repository for each language.
+		var repos []*Repository
+		totals, counts := make(map[string]float64), make(map[string]int)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if s.excluded(tx, &pb) {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			}
+			r := decodeRepository(&pb)
+			r.Language = s.LanguageAliases.Normalize(r.Language)
+			repos = append(repos, r)
+
+			totals[r.Language] += r.WeightedMentions(s.SourceWeights)
+			counts[r.Language]++
+		}
+
+		// Score each repository against its language average. Mentions
+		// are weighted by source.
+		for _, r := range repos {
+			var score float64
+			if total := totals[r.Language]; total > 0 {
+				score = (r.WeightedMentions(s.SourceWeights) + float64(r.Boost)) * float64(counts[r.Language]) / total
+			}
+			a = append(a, &RankedRepository{Repository: r, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by score and limit results.
+	sort.Sort(RankedRepositories(a))
+	if n >= 0 && len(a) > n {
+		a = a[:n]
+	}
+	return a, nil
+}
+
+// Exclusion returns the reason a repository is excluded from rankings.
+// Returns a blank string if the repository is included.
+func (s *Store) Exclusion(r *Repository) string {
+	switch {
+	case r.Fork && !s.IncludeForks:
+		return "fork"
+	case r.Archived && !s.IncludeArchived:
+		return "archived"
+	case r.Disabled && !s.IncludeDisabled:
+		return "disabled"
+	case r.HasLabel(LabelNever):
+		return "labeled " + LabelNever
+	case r.HasLabel(LabelNeedsReview):
+		return "labeled " + LabelNeedsReview
+	}
+	return ""
+}
+
+// excluded returns true if an encoded repository is excluded from rankings,
+// is blacklisted, or is flagged and has not been approved.
+func (s *Store) excluded(tx *storeTx, pb *internal.Repository) bool {
+	if s.Exclusion(&Repository{Fork: pb.GetFork(), Archived: pb.GetArchived(), Disabled: pb.GetDisabled(), Labels: pb.GetLabels()}) != "" {
+		return true
+	}
+	if blacklisted(tx.Tx, pb.GetID()) {
+		return true
+	}
+	f, err := flaggedRepository(tx.Tx, pb.GetID())
+	return err == nil && !f.Approved
+}
+
+// belowThresholds returns true if an encoded repository, with its messages
+// loaded, does not meet the store's thresholds.
+func (s *Store) belowThresholds(pb *internal.Repository) bool {
+	return s.Thresholds != nil && s.Thresholds.Check(decodeRepository(pb)) != ""
+}
+
+// MarkNotified flags a repository as notified.
+func (s *Store) MarkNotified(repositoryID string) error {
+	return s.update(func(tx *storeTx) error {
+		// Retrieve repository.
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return ErrRepositoryNotFound
+		}
+
+		// Update the notified flag & time.
+		r.Notified = proto.Bool(true)
+		r.NotifiedAt = proto.Int64(time.Now().UnixNano())
+
+		// Perist repository.
+		if err := s.saveRepository(tx, r); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ResetNotified clears the notified flag on repositories so they can be
+// notified again, such as after a run that marked repositories without
+// sending. Only repositories in the language are reset, if set, and only
+// those notified before the given time, if set. Repositories notified before
+// notification times were recorded are treated as notified at the zero time.
+// Returns the number of repositories reset.
+func (s *Store) ResetNotified(language string, before time.Time) (n int, err error) {
+	language = s.LanguageAliases.Normalize(language)
+	err = s.update(func(tx *storeTx) error {
+		// Find matching repositories before updating them.
+		var a []*internal.Repository
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if !pb.GetNotified() {
+				continue
+			} else if language != "" && !strings.EqualFold(s.LanguageAliases.Normalize(pb.GetLanguage()), language) {
+				continue
+			} else if !before.IsZero() && !time.Unix(0, pb.GetNotifiedAt()).Before(before) {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			}
+			a = append(a, &pb)
+		}
+
+		for _, pb := range a {
+			pb.Notified, pb.NotifiedAt = proto.Bool(false), nil
+			if err := s.saveRepository(tx, pb); err != nil {
+				return err
+			}
+		}
+		n = len(a)
+		return nil
+	})
+	return
+}
+
+// DeleteMessagesByAuthor removes all stored messages written by an author,
+// along with their search index entries and queued retries. Authors are
+// matched case insensitively. Returns the number of stored messages deleted.
+func (s *Store) DeleteMessagesByAuthor(author string) (n int, err error) {
+	if author == "" {
+		return 0, ErrAuthorRequired
+	}
+
+	err = s.update(func(tx *storeTx) error {
+		// Collect IDs before updating so the cursor is not invalidated.
+		var ids []string
+		if err := tx.Bucket([]byte("repositories")).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			r, err := s.repository(tx, id)
+			if err != nil {
+				return err
+			}
+
+			messages := r.Messages[:0]
+			for _, m := range r.Messages {
+				if strings.EqualFold(m.GetAuthor(), author) {
+					if err := unindexPrefix(tx.Tx, r.GetID(), searchDocKey(r.GetID(), u64tob(m.GetID()), "")); err != nil {
+						return err
+					}
+					n++
+					continue
+				}
+				messages = append(messages, m)
+			}
+			if len(messages) == len(r.Messages) {
+				continue
+			}
+
+			r.Messages = messages
+			if err := s.saveRepository(tx, r); err != nil {
+				return err
+			}
+		}
+
+		// Remove the author's messages waiting to be retried.
+		return deleteMatching(tx.Tx, "retries", func(v []byte) (bool, error) {
+			var pb internal.RetryMessage
+			err := proto.Unmarshal(v, &pb)
+			return strings.EqualFold(pb.GetMessage().GetAuthor(), author), err
+		})
+	})
+	return
+}
+
+// AddPendingNotification adds a notification to the approval queue.
+// Assigns an ID and sets the creation time, if not set.
+func (s *Store) AddPendingNotification(n *PendingNotification) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("pending"))
+		id, err := bkt.NextSequence()
+		if err != nil {
+			return err
+		}
+		n.ID = id
+		if n.CreatedAt.IsZero() {
+			n.CreatedAt = time.Now().UTC()
+		}
+		return savePendingNotification(tx, n)
+	})
+}
+
+// PendingNotifications returns all notifications in the approval queue,
+// ordered by ID.
+func (s *Store) PendingNotifications() (a []*PendingNotification, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("pending")).ForEach(func(k, v []byte) error {
+			var pb internal.PendingNotification
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			}
+			a = append(a, decodePendingNotification(&pb))
+			return nil
+		})
+	})
+	return
+}
+
+// ApprovePendingNotification marks a pending notification as approved so
+// that it is sent during the account's next notification.
+func (s *Store) ApprovePendingNotification(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		n, err := pendingNotification(tx, id)
+		if err != nil {
+			return err
+		}
+		n.Approved = true
+		return savePendingNotification(tx, n)
+	})
+}
+
+// RejectPendingNotification removes a notification from the approval queue
+// and marks its repository as notified so that it is not proposed again.
+func (s *Store) RejectPendingNotification(id uint64) error {
+	return s.update(func(tx *storeTx) error {
+		n, err := pendingNotification(tx.Tx, id)
+		if err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("pending")).Delete(u64tob(id)); err != nil {
+			return err
+		}
+
+		// Mark repository as notified, if it still exists.
+		r, err := s.repository(tx, n.RepositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return nil
+		}
+		r.Notified = proto.Bool(true)
+		return s.saveRepository(tx, r)
+	})
+}
+
+// DeletePendingNotification removes a notification from the approval queue.
+func (s *Store) DeletePendingNotification(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("pending")).Delete(u64tob(id))
+	})
+}
+
+// pendingNotification returns a pending notification by ID.
+func pendingNotification(tx *bolt.Tx, id uint64) (*PendingNotification, error) {
+	v := tx.Bucket([]byte("pending")).Get(u64tob(id))
+	if v == nil {
+		return nil, ErrPendingNotificationNotFound
+	}
+
+	var pb internal.PendingNotification
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return decodePendingNotification(&pb), nil
+}
+
+// savePendingNotification saves a pending notification in the store.
+func savePendingNotification(tx *bolt.Tx, n *PendingNotification) error {
+	buf, err := proto.Marshal(&internal.PendingNotification{
+		ID:           proto.Uint64(n.ID),
+		Username:     proto.String(n.Username),
+		RepositoryID: proto.String(n.RepositoryID),
+		Text:         proto.String(n.Text),
+		Approved:     proto.Bool(n.Approved),
+		CreatedAt:    proto.Int64(n.CreatedAt.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("pending")).Put(u64tob(n.ID), buf)
+}
+
+// decodePendingNotification decodes pb into an application type.
+func decodePendingNotification(pb *internal.PendingNotification) *PendingNotification {
+	return &PendingNotification{
+		ID:           pb.GetID(),
+		Username:     pb.GetUsername(),
+		RepositoryID: pb.GetRepositoryID(),
+		Text:         pb.GetText(),
+		Approved:     pb.GetApproved(),
+		CreatedAt:    time.Unix(0, pb.GetCreatedAt()).UTC(),
+	}
+}
+
+// AddRetry saves a message that failed to be ingested so it can be retried.
+// The attempt count of a message that is already waiting is incremented and
+// the next attempt is backed off exponentially. Messages waiting on an
+// exhausted quota are retried once it resets and are not counted as attempts.
+// Messages deferred by the daemon's lookup limit are due immediately and are
+// not counted either.
+func (s *Store) AddRetry(m *Message, err error, now time.Time) (rm *RetryMessage, e error) {
+	e = s.db.Update(func(tx *bolt.Tx) error {
+		if rm, e = retryMessage(tx, m.ID); e != nil {
+			return e
+		} else if rm == nil {
+			rm = &RetryMessage{}
+		}
+		rm.Message, rm.Err = m, err.Error()
+
+		if re, ok := err.(*RateLimitError); ok {
+			rm.NextAttemptAt = re.Reset
+		} else if err == ErrLookupLimit {
+			rm.NextAttemptAt = now.UTC()
+		} else {
+			rm.Attempts++
+			rm.NextAttemptAt = now.Add(RetryBackoff(rm.Attempts)).UTC()
+		}
+		return saveRetryMessage(tx, rm)
+	})
+	return
+}
+
+// RetryBackoff returns the time to wait before retrying a message that has
+// failed n times.
+func RetryBackoff(n int) time.Duration {
+	d := DefaultRetryBackoff
+	for i := 1; i < n && d < DefaultMaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > DefaultMaxRetryBackoff {
+		d = DefaultMaxRetryBackoff
+	}
+	return d
+}
+
+// Retries returns all messages waiting to be retried ordered by message ID.
+func (s *Store) Retries() ([]*RetryMessage, error) {
+	return s.DueRetries(time.Time{}, 0)
+}
+
+// DueRetries returns up to n messages whose next attempt is at or before now,
+// ordered by message ID. All waiting messages are returned if now is zero and
+// there is no limit if n is zero.
+func (s *Store) DueRetries(now time.Time, n int) (a []*RetryMessage, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("retries")).Cursor()
+		for k, v := c.First(); k != nil && (n <= 0 || len(a) < n); k, v = c.Next() {
+			rm, err := decodeRetryMessage(v)
+			if err != nil {
+				return err
+			} else if !now.IsZero() && rm.NextAttemptAt.After(now) {
+				continue
+			}
+			a = append(a, rm)
+		}
+		return nil
+	})
+	return
+}
+
+// RetryN returns the number of messages waiting to be retried.
+func (s *Store) RetryN() (n int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket([]byte("retries")).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+// DeleteRetry removes a message from the retry queue.
+func (s *Store) DeleteRetry(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("retries")).Delete(u64tob(id))
+	})
+}
+
+// retryMessage returns a message in the retry queue by ID.
+// Returns nil if the message is not waiting to be retried.
+func retryMessage(tx *bolt.Tx, id uint64) (*RetryMessage, error) {
+	v := tx.Bucket([]byte("retries")).Get(u64tob(id))
+	if v == nil {
+		return nil, nil
+	}
+	return decodeRetryMessage(v)
+}
+
+// saveRetryMessage saves a message to the retry queue.
+func saveRetryMessage(tx *bolt.Tx, rm *RetryMessage) error {
+	buf, err := proto.Marshal(&internal.RetryMessage{
+		RepositoryID:  proto.String(rm.Message.RepositoryID),
+		Message:       encodeMessage(rm.Message),
+		Attempts:      proto.Int64(int64(rm.Attempts)),
+		NextAttemptAt: proto.Int64(rm.NextAttemptAt.UnixNano()),
+		Error:         proto.String(rm.Err),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("retries")).Put(u64tob(rm.Message.ID), buf)
+}
+
+// decodeRetryMessage decodes an encoded retry queue entry.
+func decodeRetryMessage(v []byte) (*RetryMessage, error) {
+	var pb internal.RetryMessage
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	m := decodeMessage(pb.GetMessage())
+	m.RepositoryID = pb.GetRepositoryID()
+	return &RetryMessage{
+		Message:       m,
+		Attempts:      int(pb.GetAttempts()),
+		NextAttemptAt: time.Unix(0, pb.GetNextAttemptAt()).UTC(),
+		Err:           pb.GetError(),
+	}, nil
+}
+
+// FlagRepository flags a repository for manual review. An existing flag,
+// including its approval, is left unchanged.
+func (s *Store) FlagRepository(f *FlaggedRepository) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := flaggedRepository(tx, f.RepositoryID); err == nil {
+			return nil
+		} else if err != ErrFlaggedRepositoryNotFound {
+			return err
+		}
+
+		if f.CreatedAt.IsZero() {
+			f.CreatedAt = time.Now().UTC()
+		}
+		return saveFlaggedRepository(tx, f)
+	})
+}
+
+// FlaggedRepository returns the flag for a repository.
+// Returns nil if the repository is not flagged.
+func (s *Store) FlaggedRepository(repositoryID string) (f *FlaggedRepository, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if f, err = flaggedRepository(tx, repositoryID); err == ErrFlaggedRepositoryNotFound {
+			f, err = nil, nil
+		}
+		return err
+	})
+	return
+}
+
+// FlaggedRepositories returns all flagged repositories ordered by ID.
+func (s *Store) FlaggedRepositories() (a []*FlaggedRepository, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("flagged")).ForEach(func(k, v []byte) error {
+			var pb internal.FlaggedRepository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			}
+			a = append(a, decodeFlaggedRepository(&pb))
+			return nil
+		})
+	})
+	return
+}
+
+// ApproveFlaggedRepository allows a flagged repository to be ranked and notified.
+func (s *Store) ApproveFlaggedRepository(repositoryID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		f, err := flaggedRepository(tx, repositoryID)
+		if err != nil {
+			return err
+		}
+		f.Approved = true
+		return saveFlaggedRepository(tx, f)
+	})
+}
+
+// RejectFlaggedRepository removes a flag and marks its repository as
+// notified so that it is not proposed again.
+func (s *Store) RejectFlaggedRepository(repositoryID string) error {
+	return s.update(func(tx *storeTx) error {
+		if _, err := flaggedRepository(tx.Tx, repositoryID); err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("flagged")).Delete([]byte(repositoryID)); err != nil {
+			return err
+		}
+
+		// Mark repository as notified, if it still exists.
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil {
+			return nil
+		}
+		r.Notified = proto.Bool(true)
+		return s.saveRepository(tx, r)
+	})
+}
+
+// flaggedRepository returns the flag for a repository.
+func flaggedRepository(tx *bolt.Tx, repositoryID string) (*FlaggedRepository, error) {
+	v := tx.Bucket([]byte("flagged")).Get([]byte(repositoryID))
+	if v == nil {
+		return nil, ErrFlaggedRepositoryNotFound
+	}
+
+	var pb internal.FlaggedRepository
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return decodeFlaggedRepository(&pb), nil
+}
+
+// saveFlaggedRepository saves a flag in the store.
+func saveFlaggedRepository(tx *bolt.Tx, f *FlaggedRepository) error {
+	buf, err := proto.Marshal(&internal.FlaggedRepository{
+		RepositoryID: proto.String(f.RepositoryID),
+		Reason:       proto.String(f.Reason),
+		Approved:     proto.Bool(f.Approved),
+		CreatedAt:    proto.Int64(f.CreatedAt.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("flagged")).Put([]byte(f.RepositoryID), buf)
+}
+
+// decodeFlaggedRepository decodes pb into an application type.
+func decodeFlaggedRepository(pb *internal.FlaggedRepository) *FlaggedRepository {
+	return &FlaggedRepository{
+		RepositoryID: pb.GetRepositoryID(),
+		Reason:       pb.GetReason(),
+		Approved:     pb.GetApproved(),
+		CreatedAt:    time.Unix(0, pb.GetCreatedAt()).UTC(),
+	}
+}
+
+// AddOptOut saves an opt-out and purges all matching repositories along with
+// their messages and every other record referencing them. Returns the number
+// of repositories purged.
+func (s *Store) AddOptOut(o *OptOut) (n int, err error) {
+	if !ValidOptOutPattern(o.Pattern) {
+		return 0, ErrInvalidOptOutPattern
+	} else if o.CreatedAt.IsZero() {
+		o.CreatedAt = time.Now().UTC()
+	}
+
+	err = s.update(func(tx *storeTx) error {
+		buf, err := proto.Marshal(&internal.OptOut{
+			Pattern:   proto.String(o.Pattern),
+			Reason:    proto.String(o.Reason),
+			CreatedAt: proto.Int64(o.CreatedAt.UnixNano()),
+		})
+		if err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("opt_outs")).Put([]byte(o.Pattern), buf); err != nil {
+			return err
+		}
+
+		n, err = s.purgeOptedOut(tx)
+		return err
+	})
+	return
+}
+
+// RemoveOptOut removes a saved opt-out. Purged repositories are not restored.
+func (s *Store) RemoveOptOut(pattern string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("opt_outs")).Delete([]byte(pattern))
+	})
+}
+
+// OptOuts returns all saved opt-outs ordered by pattern.
+func (s *Store) OptOuts() (a []*OptOut, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("opt_outs")).ForEach(func(k, v []byte) error {
+			var pb internal.OptOut
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			}
+			a = append(a, &OptOut{
+				Pattern:   pb.GetPattern(),
+				Reason:    pb.GetReason(),
+				CreatedAt: time.Unix(0, pb.GetCreatedAt()).UTC(),
+			})
+			return nil
+		})
+	})
+	return
+}
+
+// PurgeOptedOut removes all repositories matching a configured or saved
+// opt-out, along with their messages, search index entries, and every other
+// record referencing them. Returns the number of repositories purged.
+func (s *Store) PurgeOptedOut() (n int, err error) {
+	err = s.update(func(tx *storeTx) error {
+		n, err = s.purgeOptedOut(tx)
+		return err
+	})
+	return
+}
+
+// purgeOptedOut removes opted out repositories within a transaction.
+func (s *Store) purgeOptedOut(tx *storeTx) (int, error) {
+	// Find matching repositories before deleting them.
+	var ids [][]byte
+	c := tx.Bucket([]byte("repositories")).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if optedOut, err := s.optedOut(tx.Tx, string(k)); err != nil {
+			return 0, err
+		} else if optedOut {
+			ids = append(ids, append([]byte(nil), k...))
+		}
+	}
+
+	for _, id := range ids {
+		if err := deleteRepository(tx, id); err != nil {
+			return 0, err
+		} else if err := unindexRepository(tx.Tx, string(id)); err != nil {
+			return 0, err
+		} else if err := purgeRepository(tx.Tx, string(id)); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// deleteRepository removes a repository, its separately stored messages,
+// and its flags within a transaction.
+func deleteRepository(tx *storeTx, id []byte) error {
+	msgs, err := tx.messageBucket()
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket([]byte("repositories")).Delete(id); err != nil {
+		return err
+	} else if err := tx.Bucket([]byte("flagged")).Delete(id); err != nil {
+		return err
+	} else if err := tx.Bucket([]byte("fetched")).Delete(id); err != nil {
+		return err
+	} else if err := tx.Bucket([]byte("repository_ids")).Delete(bytes.ToLower(id)); err != nil {
+		return err
+	}
+	if msgs != nil {
+		if err := msgs.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Takedown removes all stored data for a repository, such as for a DMCA or
+// abuse request. This includes its messages, search index entries, flags,
+// pending & queued retry messages, notification history, featured records,
+// short URLs, and snapshot entries. If block is true then the repository is
+// also blacklisted in the same transaction so that it is never stored again.
+// Returns ErrRepositoryNotFound if the repository is not stored and is not
+// being blocked.
+func (s *Store) Takedown(repositoryID string, block bool) error {
+	if block && !ValidBlacklistPattern(repositoryID) {
+		return ErrInvalidBlacklistPattern
+	}
+
+	return s.update(func(tx *storeTx) error {
+		r, err := s.repository(tx, repositoryID)
+		if err != nil {
+			return err
+		} else if r == nil && !block {
+			return ErrRepositoryNotFound
+		}
+
+		if r != nil {
+			repositoryID = r.GetID()
+			if err := deleteRepository(tx, []byte(repositoryID)); err != nil {
+				return err
+			}
+		}
+		if err := unindexRepository(tx.Tx, repositoryID); err != nil {
+			return err
+		} else if err := purgeRepository(tx.Tx, repositoryID); err != nil {
+			return err
+		}
+
+		if !block {
+			return nil
+		}
+		return tx.Bucket([]byte("blacklist")).Put([]byte(repositoryID), nil)
+	})
+}
+
+// purgeRepository removes the records referencing a repository outside of
+// the repositories bucket. Matching ignores case.
+func purgeRepository(tx *bolt.Tx, repositoryID string) error {
+	match := func(id string) bool { return strings.EqualFold(id, repositoryID) }
+
+	// Remove pending notifications, queued retries, notification history,
+	// and featured records.
+	if err := deleteMatching(tx, "pending", func(v []byte) (bool, error) {
+		var pb internal.PendingNotification
+		err := proto.Unmarshal(v, &pb)
+		return match(pb.GetRepositoryID()), err
+	}); err != nil {
+		return err
+	} else if err := deleteMatching(tx, "retries", func(v []byte) (bool, error) {
+		var pb internal.RetryMessage
+		err := proto.Unmarshal(v, &pb)
+		return match(pb.GetRepositoryID()), err
+	}); err != nil {
+		return err
+	} else if err := deleteMatching(tx, "notifications", func(v []byte) (bool, error) {
+		var pb internal.Notification
+		err := proto.Unmarshal(v, &pb)
+		return match(pb.GetRepositoryID()), err
+	}); err != nil {
+		return err
+	} else if err := deleteMatching(tx, "featured", func(v []byte) (bool, error) {
+		var pb internal.Feature
+		err := proto.Unmarshal(v, &pb)
+		return match(pb.GetRepositoryID()), err
+	}); err != nil {
+		return err
+	}
+
+	// Remove the not found cache entry & short URLs for the repository.
+	if err := tx.Bucket([]byte("not_found")).Delete([]byte(repositoryID)); err != nil {
+		return err
+	}
+	var shortURLs [][]byte
+	if err := tx.Bucket([]byte("short_urls")).ForEach(func(k, v []byte) error {
+		if match(strings.TrimPrefix(string(k), "https://")) {
+			shortURLs = append(shortURLs, k)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range shortURLs {
+		if err := tx.Bucket([]byte("short_urls")).Delete(k); err != nil {
+			return err
+		}
+	}
+
+	// Remove the repository's entries from snapshots.
+	bkt := tx.Bucket([]byte("snapshots"))
+	snapshots := make(map[string][]byte)
+	if err := bkt.ForEach(func(k, v []byte) error {
+		var pb internal.Snapshot
+		if err := proto.Unmarshal(v, &pb); err != nil {
+			return &DecodeError{Err: err}
+		}
+
+		entries := pb.Entries[:0]
+		for _, e := range pb.GetEntries() {
+			if !match(e.GetRepositoryID()) {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == len(pb.Entries) {
+			return nil
+		}
+		pb.Entries = entries
+
+		buf, err := proto.Marshal(&pb)
+		if err != nil {
+			return err
+		}
+		snapshots[string(k)] = buf
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, buf := range snapshots {
+		if err := bkt.Put([]byte(k), buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMatching deletes the values in the named bucket that fn matches.
+func deleteMatching(tx *bolt.Tx, name string, fn func(v []byte) (bool, error)) error {
+	var keys [][]byte
+	if err := tx.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+		if ok, err := fn(v); err != nil {
+			return &DecodeError{Err: err}
+		} else if ok {
+			keys = append(keys, k)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := tx.Bucket([]byte(name)).Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optedOut returns true if a repository matches a configured or saved opt-out.
+func (s *Store) optedOut(tx *bolt.Tx, repositoryID string) (bool, error) {
+	for _, pattern := range s.OptOutPatterns {
+		if MatchOptOut(pattern, repositoryID) {
+			return true, nil
+		}
+	}
+
+	c := tx.Bucket([]byte("opt_outs")).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if MatchOptOut(string(k), repositoryID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// u64tob encodes v as an 8-byte big endian slice.
+func u64tob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// btou64 decodes an 8-byte big endian slice.
+func btou64(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+// Search returns up to n repositories whose ID, description, or messages
+// contain every term in q, ordered by the number of matching messages.
+func (s *Store) Search(q string, n int) (a []*SearchResult, err error) {
+	terms := SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	err = s.view(func(tx *storeTx) error {
+		// Find documents indexed under every term. The index may contain
+		// stale entries so matches are verified against stored data.
+		counts := make(map[searchDoc]int)
+		c := tx.Bucket([]byte("search")).Cursor()
+		for _, term := range terms {
+			prefix := []byte(term + "\x00")
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				counts[decodeSearchKey(k[len(prefix):])]++
+			}
+		}
+
+		// Group documents by repository.
+		byRepository := make(map[string][]searchDoc)
+		var ids []string
+		for doc, count := range counts {
+			if count < len(terms) {
+				continue
+			} else if byRepository[doc.repositoryID] == nil {
+				ids = append(ids, doc.repositoryID)
+			}
+			byRepository[doc.repositoryID] = append(byRepository[doc.repositoryID], doc)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			pb, err := s.repository(tx, id)
+			if err != nil {
+				return err
+			} else if pb == nil {
+				continue
+			}
+
+			result := &SearchResult{Repository: decodeRepository(pb)}
+			messageIDs := make(map[uint64]bool)
+			for _, doc := range byRepository[id] {
+				if !doc.message {
+					result.Matched = containsTerms(pb.GetID()+" "+pb.GetDescription(), terms)
+				} else {
+					messageIDs[doc.messageID] = true
+				}
+			}
+			for _, m := range result.Repository.Messages {
+				if messageIDs[m.ID] && containsTerms(m.Text, terms) {
+					result.Messages = append(result.Messages, m)
+				}
+			}
+
+			if result.Matched || len(result.Messages) > 0 {
+				a = append(a, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by relevance. Results are found in ID order which breaks ties.
+	sort.Stable(searchResultsByRelevance(a))
+	if len(a) > n {
+		a = a[:n]
+	}
+	return a, nil
+}
+
+// searchIndexVersion is the version of the search index format. The index
+// is rebuilt when opening a database indexed with an older version.
+const searchIndexVersion = 2
+
+// buildSearchIndex indexes every repository & message if the index has not
+// been built yet or was built with an older format.
+func (s *Store) buildSearchIndex() error {
+	return s.update(func(tx *storeTx) error {
+		meta := tx.Bucket([]byte("meta"))
+		if v := meta.Get([]byte("search_indexed")); len(v) == 1 && v[0] >= searchIndexVersion {
+			return nil
+		}
+
+		// Clear any index built with an older format.
+		for _, name := range []string{"search", "search_docs"} {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			} else if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			} else if err := indexRepository(tx.Tx, &pb); err != nil {
+				return err
+			}
+			for _, m := range pb.GetMessages() {
+				if err := indexMessage(tx.Tx, pb.GetID(), m); err != nil {
+					return err
+				}
+			}
+		}
+		return meta.Put([]byte("search_indexed"), []byte{searchIndexVersion})
+	})
+}
+
+// indexRepository replaces the terms indexed for a repository's ID &
+// description in the search index. Terms from a previous description are
+// removed so that edited repositories do not match stale queries.
+func indexRepository(tx *bolt.Tx, r *internal.Repository) error {
+	if err := unindexPrefix(tx, r.GetID(), searchDocKey(r.GetID(), nil, "")); err != nil {
+		return err
+	}
+	for _, term := range SearchTerms(r.GetID() + " " + r.GetDescription()) {
+		if err := indexTerm(tx, term, r.GetID(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexMessage adds the terms in a message's text to the search index.
+func indexMessage(tx *bolt.Tx, repositoryID string, m *internal.Message) error {
+	for _, term := range SearchTerms(m.GetText()) {
+		if err := indexTerm(tx, term, repositoryID, u64tob(m.GetID())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexTerm adds a term to the search index. The term is also recorded under
+// the repository in the search_docs bucket so that it can be removed without
+// knowing the text it was indexed from.
+func indexTerm(tx *bolt.Tx, term, repositoryID string, messageID []byte) error {
+	if err := tx.Bucket([]byte("search")).Put(searchKey(term, repositoryID, messageID), nil); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("search_docs")).Put(searchDocKey(repositoryID, messageID, term), nil)
+}
+
+// unindexRepository removes every term indexed for a repository and its
+// messages from the search index.
+func unindexRepository(tx *bolt.Tx, repositoryID string) error {
+	return unindexPrefix(tx, repositoryID, []byte(repositoryID+"\x00"))
+}
+
+// unindexPrefix removes the terms recorded under search_docs keys beginning
+// with prefix from the search index.
+func unindexPrefix(tx *bolt.Tx, repositoryID string, prefix []byte) error {
+	var keys [][]byte
+	c := tx.Bucket([]byte("search_docs")).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	for _, k := range keys {
+		messageID, term := decodeSearchDocKey(k[len(repositoryID)+1:])
+		if err := tx.Bucket([]byte("search")).Delete(searchKey(term, repositoryID, messageID)); err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("search_docs")).Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchDocKey returns the search_docs key recording a term indexed for a
+// repository or, if messageID is set, one of its messages. Keys are prefixed
+// by repository ID so that all of a repository's terms can be scanned.
+func searchDocKey(repositoryID string, messageID []byte, term string) []byte {
+	k := make([]byte, 0, len(repositoryID)+2+len(messageID)+len(term))
+	k = append(k, repositoryID...)
+	k = append(k, 0)
+	if messageID == nil {
+		k = append(k, 'r')
+	} else {
+		k = append(k, 'm')
+		k = append(k, messageID...)
+	}
+	return append(k, term...)
+}
+
+// decodeSearchDocKey decodes the message ID & term from a search_docs key
+// without its repository ID prefix. The message ID is nil for repository terms.
+func decodeSearchDocKey(k []byte) (messageID []byte, term string) {
+	if len(k) >= 9 && k[0] == 'm' {
+		return k[1:9], string(k[9:])
+	}
+	return nil, string(k[1:])
+}
+
+// searchDoc identifies a repository or one of its messages in the index.
+type searchDoc struct {
+	repositoryID string
+	message      bool
+	messageID    uint64
+}
+
+// searchKey returns the index key for a term in a repository or, if
+// messageID is set, one of its messages.
+func searchKey(term, repositoryID string, messageID []byte) []byte {
+	k := make([]byte, 0, len(term)+len(repositoryID)+2+len(messageID))
+	k = append(k, term...)
+	k = append(k, 0)
+	k = append(k, repositoryID...)
+	k = append(k, 0)
+	return append(k, messageID...)
+}
+
+// decodeSearchKey decodes the document from an index key without its term.
+func decodeSearchKey(k []byte) searchDoc {
+	i := bytes.IndexByte(k, 0)
+	if i == -1 {
+		return searchDoc{repositoryID: string(k)}
+	}
+
+	doc := searchDoc{repositoryID: string(k[:i])}
+	if v := k[i+1:]; len(v) == 8 {
+		doc.message, doc.messageID = true, binary.BigEndian.Uint64(v)
+	}
+	return doc
+}
+
+// AddBlacklist adds a repository ID or pattern to the blacklist. Blacklisted
+// repositories do not receive new messages and are excluded from rankings.
+func (s *Store) AddBlacklist(pattern string) error {
+	if !ValidBlacklistPattern(pattern) {
+		return ErrInvalidBlacklistPattern
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("blacklist")).Put([]byte(pattern), nil)
+	})
+}
+
+// RemoveBlacklist removes a repository ID or pattern from the blacklist.
+func (s *Store) RemoveBlacklist(pattern string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("blacklist")).Delete([]byte(pattern))
+	}); err != nil {
+		return err
+	}
+	blacklistRegexps.Delete(pattern)
+	return nil
+}
+
+// Blacklist returns all blacklisted repository IDs and patterns.
+func (s *Store) Blacklist() (a []string, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("blacklist")).ForEach(func(k, _ []byte) error {
+			a = append(a, string(k))
+			return nil
+		})
+	})
+	return
+}
+
+// Blacklisted returns true if a repository matches the blacklist.
+func (s *Store) Blacklisted(repositoryID string) (v bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v = blacklisted(tx, repositoryID)
+		return nil
+	})
+	return
+}
+
+// blacklisted returns true if a repository matches the blacklist. Exact IDs
+// are looked up directly before the patterns are scanned.
+func blacklisted(tx *bolt.Tx, repositoryID string) bool {
+	c := tx.Bucket([]byte("blacklist")).Cursor()
+	if k, _ := c.Seek([]byte(repositoryID)); k != nil && string(k) == repositoryID {
+		return true
+	}
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if MatchBlacklist(string(k), repositoryID) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddNotification records a notification attempt in the audit log.
+// Assigns an ID and sets the time, if not set.
+func (s *Store) AddNotification(n *Notification) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("notifications"))
+		id, err := bkt.NextSequence()
+		if err != nil {
+			return err
+		}
+		n.ID = id
+		if n.Time.IsZero() {
+			n.Time = time.Now().UTC()
+		}
+
+		buf, err := proto.Marshal(encodeNotification(n))
+		if err != nil {
+			return err
+		}
+		return bkt.Put(u64tob(n.ID), buf)
+	})
+}
+
+// Notifications returns up to n of the most recent notification attempts,
+// newest first. Returns all attempts if n is zero.
+func (s *Store) Notifications(n int) (a []*Notification, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("notifications")).Cursor()
+		for k, v := c.Last(); k != nil && (n == 0 || len(a) < n); k, v = c.Prev() {
+			var pb internal.Notification
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			}
+			a = append(a, decodeNotification(&pb))
+		}
+		return nil
+	})
+	return
+}
+
+// RecentNotifications returns the notification attempts made after since,
+// newest first.
+func (s *Store) RecentNotifications(since time.Time) (a []*Notification, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("notifications")).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var pb internal.Notification
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if !time.Unix(0, pb.GetTime()).After(since) {
+				break
+			}
+			a = append(a, decodeNotification(&pb))
+		}
+		return nil
+	})
+	return
+}
+
+// SetNotificationEngagement replaces the engagement counts recorded for a
+// notification attempt. Returns ErrNotificationNotFound if it does not exist.
+func (s *Store) SetNotificationEngagement(id uint64, e *Engagement) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("notifications"))
+		v := bkt.Get(u64tob(id))
+		if v == nil {
+			return ErrNotificationNotFound
+		}
+
+		var pb internal.Notification
+		if err := proto.Unmarshal(v, &pb); err != nil {
+			return &DecodeError{Err: err}
+		}
+		pb.Likes = proto.Int64(int64(e.Likes))
+		pb.Retweets = proto.Int64(int64(e.Retweets))
+		pb.Impressions = proto.Int64(int64(e.Impressions))
+		pb.EngagementTime = proto.Int64(e.Time.UnixNano())
+
+		buf, err := proto.Marshal(&pb)
+		if err != nil {
+			return err
+		}
+		return bkt.Put(u64tob(id), buf)
+	})
+}
+
+// TemplateStats returns the engagement totals for each account's template
+// variants, ordered by username & template. Only successful notifications
+// sent with a named template are counted.
+func (s *Store) TemplateStats() (a []*TemplateStats, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		m := make(map[[2]string]*TemplateStats)
+		c := tx.Bucket([]byte("notifications")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Notification
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if !pb.GetSuccess() || pb.GetTemplate() == "" {
+				continue
+			}
+
+			key := [2]string{pb.GetUsername(), pb.GetTemplate()}
+			stats := m[key]
+			if stats == nil {
+				stats = &TemplateStats{Username: key[0], Template: key[1]}
+				m[key] = stats
+				a = append(a, stats)
+			}
+			stats.Notifications++
+			if pb.EngagementTime != nil {
+				stats.Measured++
+				stats.Likes += int(pb.GetLikes())
+				stats.Retweets += int(pb.GetRetweets())
+				stats.Impressions += int(pb.GetImpressions())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(templateStatsByName(a))
+	return a, nil
+}
+
+// encodeNotification encodes n into the internal format.
+func encodeNotification(n *Notification) *internal.Notification {
+	pb := &internal.Notification{
+		ID:           proto.Uint64(n.ID),
+		Username:     proto.String(n.Username),
+		RepositoryID: proto.String(n.RepositoryID),
+		Text:         proto.String(n.Text),
+		MessageID:    proto.Uint64(n.MessageID),
+		URL:          proto.String(n.URL),
+		Time:         proto.Int64(n.Time.UnixNano()),
+		Success:      proto.Bool(n.Success),
+		Error:        proto.String(n.Error),
+	}
+	if n.Template != "" {
+		pb.Template = proto.String(n.Template)
+	}
+	if e := n.Engagement; e != nil {
+		pb.Likes = proto.Int64(int64(e.Likes))
+		pb.Retweets = proto.Int64(int64(e.Retweets))
+		pb.Impressions = proto.Int64(int64(e.Impressions))
+		pb.EngagementTime = proto.Int64(e.Time.UnixNano())
+	}
+	return pb
+}
+
+// decodeNotification decodes pb into an application type.
+func decodeNotification(pb *internal.Notification) *Notification {
+	n := &Notification{
+		ID:           pb.GetID(),
+		Username:     pb.GetUsername(),
+		RepositoryID: pb.GetRepositoryID(),
+		Text:         pb.GetText(),
+		MessageID:    pb.GetMessageID(),
+		URL:          pb.GetURL(),
+		Time:         time.Unix(0, pb.GetTime()).UTC(),
+		Success:      pb.GetSuccess(),
+		Error:        pb.GetError(),
+		Template:     pb.GetTemplate(),
+	}
+	if pb.EngagementTime != nil {
+		n.Engagement = &Engagement{
+			Likes:       int(pb.GetLikes()),
+			Retweets:    int(pb.GetRetweets()),
+			Impressions: int(pb.GetImpressions()),
+			Time:        time.Unix(0, pb.GetEngagementTime()).UTC(),
+		}
+	}
+	return n
+}
+
+// SaveSnapshot records the top n unnotified repositories for each language,
+// ranked by mention count, as the snapshot for the UTC day of t. Replaces any
+// existing snapshot for that day. Repositories without a language are not
+// recorded.
+func (s *Store) SaveSnapshot(t time.Time, n int) (ss *Snapshot, err error) {
+	ss = &Snapshot{Date: utcDay(t), Languages: make(map[string][]*SnapshotEntry)}
+	err = s.update(func(tx *storeTx) error {
+		// Group repositories by language.
+		m := make(map[string][]*Repository)
+		c := tx.Bucket([]byte("repositories")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var pb internal.Repository
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			} else if pb.GetNotified() || pb.GetLanguage() == "" || s.excluded(tx, &pb) {
+				continue
+			} else if err := loadMessages(tx, &pb); err != nil {
+				return err
+			}
+			lang := s.LanguageAliases.Normalize(pb.GetLanguage())
+			m[lang] = append(m[lang], decodeRepository(&pb))
+		}
+
+		// Rank each language and keep the top n.
+		langs := make([]string, 0, len(m))
+		for lang := range m {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+
+		pb := &internal.Snapshot{Date: proto.Int64(ss.Date.UnixNano())}
+		for _, lang := range langs {
+			a := m[lang]
+			sort.Stable(repositoriesByScore{a, s.SourceWeights})
+			if len(a) > n {
+				a = a[:n]
+			}
+			for _, r := range a {
+				pb.Entries = append(pb.Entries, &internal.SnapshotEntry{
+					Language:     proto.String(lang),
+					RepositoryID: proto.String(r.ID),
+					Mentions:     proto.Int64(int64(r.RankedMentions())),
+				})
+			}
+		}
+
+		buf, err := proto.Marshal(pb)
+		if err != nil {
+			return err
+		}
+		*ss = *decodeSnapshot(pb)
+		return tx.Bucket([]byte("snapshots")).Put([]byte(ss.Date.Format("2006-01-02")), buf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// Snapshot returns the snapshot for the UTC day of t. Returns nil if no
+// snapshot was recorded that day.
+func (s *Store) Snapshot(t time.Time) (ss *Snapshot, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte("snapshots")).Get([]byte(utcDay(t).Format("2006-01-02")))
+		if v == nil {
+			return nil
+		}
+
+		var pb internal.Snapshot
+		if err := proto.Unmarshal(v, &pb); err != nil {
+			return &DecodeError{Err: err}
+		}
+		ss = decodeSnapshot(&pb)
+		return nil
+	})
+	return
+}
+
+// Snapshots returns snapshots recorded on or after the UTC day of since,
+// oldest first.
+func (s *Store) Snapshots(since time.Time) (a []*Snapshot, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("snapshots")).Cursor()
+		for k, v := c.Seek([]byte(utcDay(since).Format("2006-01-02"))); k != nil; k, v = c.Next() {
+			var pb internal.Snapshot
+			if err := proto.Unmarshal(v, &pb); err != nil {
+				return &DecodeError{Err: err}
+			}
+			a = append(a, decodeSnapshot(&pb))
+		}
+		return nil
+	})
+	return
+}
+
+// utcDay returns the start of the UTC day of t.
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// decodeSnapshot decodes pb into an application type. Entries are ranked in
+// the order they were stored within each language.
+func decodeSnapshot(pb *internal.Snapshot) *Snapshot {
+	ss := &Snapshot{
+		Date:      time.Unix(0, pb.GetDate()).UTC(),
+		Languages: make(map[string][]*SnapshotEntry),
+	}
+	for _, e := range pb.GetEntries() {
+		lang := e.GetLanguage()
+		ss.Languages[lang] = append(ss.Languages[lang], &SnapshotEntry{
+			Rank:         len(ss.Languages[lang]) + 1,
+			RepositoryID: e.GetRepositoryID(),
+			Mentions:     int(e.GetMentions()),
+		})
+	}
+	return ss
+}
+
+// LastNotifyTime returns the time an account last sent a notification.
+// Returns a zero time if the account has not notified.
+func (s *Store) LastNotifyTime(username string) (t time.Time, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		pb, err := account(tx, username)
+		if err != nil || pb == nil || pb.LastNotifyTime == nil {
+			return err
+		}
+		t = time.Unix(0, pb.GetLastNotifyTime()).UTC()
+		return nil
+	})
+	return
+}
+
+// SetLastNotifyTime sets the time an account last sent a notification.
+func (s *Store) SetLastNotifyTime(username string, t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		pb, err := account(tx, username)
+		if err != nil {
+			return err
+		} else if pb == nil {
+			pb = &internal.Account{Username: proto.String(username)}
+		}
+		pb.LastNotifyTime = proto.Int64(t.UnixNano())
+		return saveAccount(tx, pb)
+	})
+}
+
+// account returns the stored state for an account by username.
+func account(tx *bolt.Tx, username string) (*internal.Account, error) {
+	v := tx.Bucket([]byte("accounts")).Get([]byte(username))
+	if v == nil {
+		return nil, nil
+	}
 
-		// Append message.
-		r.Messages = append(r.Messages, encodeMessage(m))
+	pb := &internal.Account{}
+	if err := proto.Unmarshal(v, pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return pb, nil
+}
 
-		// Update repository.
-		if err := s.saveRepository(tx, r); err != nil {
-			return err
-		}
-		return nil
-	}); err == errDuplicateMessage {
-		return nil // ignore duplicates
-	} else if err != nil {
+// saveAccount saves the state for an account.
+func saveAccount(tx *bolt.Tx, pb *internal.Account) error {
+	buf, err := proto.Marshal(pb)
+	if err != nil {
 		return err
 	}
-	return nil
+	return tx.Bucket([]byte("accounts")).Put([]byte(pb.GetUsername()), buf)
 }
 
-// Repository returns a repository by id.
-func (s *Store) Repository(id string) (r *Repository, err error) {
+// OAuth2Token returns the stored OAuth 2.0 token for an account. Returns nil
+// if no token has been stored.
+func (s *Store) OAuth2Token(username string) (t *OAuth2Token, err error) {
 	err = s.db.View(func(tx *bolt.Tx) error {
-		// Retrieve encoded entry.
-		buf := tx.Bucket([]byte("repositories")).Get([]byte(id))
-		if buf == nil {
+		v := tx.Bucket([]byte("meta")).Get(oauth2TokenKey(username))
+		if v == nil {
 			return nil
 		}
 
-		// Decode repository.
-		var pb internal.Repository
-		if err := proto.Unmarshal(buf, &pb); err != nil {
-			return err
+		var pb internal.OAuth2Token
+		if err := proto.Unmarshal(v, &pb); err != nil {
+			return &DecodeError{Err: err}
+		}
+		t = &OAuth2Token{
+			AccessToken:  pb.GetAccessToken(),
+			RefreshToken: pb.GetRefreshToken(),
+			Expiry:       time.Unix(0, pb.GetExpiry()).UTC(),
 		}
-		r = decodeRepository(&pb)
-
 		return nil
 	})
 	return
 }
 
-// Repositories returns all repositories.
-func (s *Store) Repositories() (a []*Repository, err error) {
+// SaveOAuth2Token stores the OAuth 2.0 token for an account, replacing any
+// previous token.
+func (s *Store) SaveOAuth2Token(username string, t *OAuth2Token) error {
+	buf, err := proto.Marshal(&internal.OAuth2Token{
+		AccessToken:  proto.String(t.AccessToken),
+		RefreshToken: proto.String(t.RefreshToken),
+		Expiry:       proto.Int64(t.Expiry.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("meta")).Put(oauth2TokenKey(username), buf)
+	})
+}
+
+// oauth2TokenKey returns the meta key for an account's OAuth 2.0 token.
+// Usernames are case insensitive.
+func oauth2TokenKey(username string) []byte {
+	return []byte("oauth2_token:" + strings.ToLower(username))
+}
+
+// RateLimits returns the last known API quotas for an account. The remote
+// store's quota is saved under RemoteRateLimitKey.
+func (s *Store) RateLimits(username string) (a []*RateLimit, err error) {
 	err = s.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("repositories")).Cursor()
+		a, err = rateLimits(tx, username)
+		return err
+	})
+	return
+}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var pb internal.Repository
-			if err := proto.Unmarshal(v, &pb); err != nil {
+// rateLimits returns the API quotas saved under a key.
+func rateLimits(tx *bolt.Tx, username string) (a []*RateLimit, err error) {
+	v := tx.Bucket([]byte("rate_limits")).Get([]byte(username))
+	if v == nil {
+		return nil, nil
+	}
+
+	var pb internal.RateLimits
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	for _, rl := range pb.GetRateLimits() {
+		a = append(a, &RateLimit{
+			Resource:  rl.GetResource(),
+			Limit:     int(rl.GetLimit()),
+			Remaining: int(rl.GetRemaining()),
+			Reset:     time.Unix(0, rl.GetReset_()).UTC(),
+		})
+	}
+	return a, nil
+}
+
+// SaveRateLimits replaces the API quotas for an account.
+func (s *Store) SaveRateLimits(username string, a []*RateLimit) error {
+	var pb internal.RateLimits
+	for _, rl := range a {
+		pb.RateLimits = append(pb.RateLimits, &internal.RateLimit{
+			Resource:  proto.String(rl.Resource),
+			Limit:     proto.Int64(int64(rl.Limit)),
+			Remaining: proto.Int64(int64(rl.Remaining)),
+			Reset_:    proto.Int64(rl.Reset.UnixNano()),
+		})
+	}
+	buf, err := proto.Marshal(&pb)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("rate_limits")).Put([]byte(username), buf)
+	})
+}
+
+// ReserveFeature records that f is about to be featured unless its repository
+// or normalized text was featured since the given time. Returns the conflicting
+// feature if the reservation is blocked.
+func (s *Store) ReserveFeature(f *Feature, since time.Time) (conflict *Feature, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		// Check for recent features of the repository or text.
+		for _, key := range featureKeys(f) {
+			other, err := feature(tx, key)
+			if err != nil {
 				return err
+			} else if other != nil && other.Time.After(since) {
+				conflict = other
+				return nil
 			}
-			a = append(a, decodeRepository(&pb))
 		}
 
+		// Record the reservation under each key.
+		for _, key := range featureKeys(f) {
+			if err := saveFeature(tx, key, f); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	return
 }
 
-// RepositoryN returns the number of repositories in the store.
-func (s *Store) RepositoryN() (n int, err error) {
-	err = s.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("repositories")).Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			n++
+// ReleaseFeature removes a reservation made by ReserveFeature, such as when
+// the notification fails to send. Keys since reserved by others are kept.
+func (s *Store) ReleaseFeature(f *Feature) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range featureKeys(f) {
+			other, err := feature(tx, key)
+			if err != nil {
+				return err
+			} else if other == nil || !other.Time.Equal(f.Time) || other.Username != f.Username {
+				continue
+			}
+			if err := tx.Bucket([]byte("featured")).Delete(key); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
-	return
 }
 
-// TopRepositories returns the most mentioned repositories by language.
-func (s *Store) TopRepositories() (m map[string]*Repository, err error) {
-	m = make(map[string]*Repository)
+// featureKeys returns the keys a feature is recorded under.
+func featureKeys(f *Feature) [][]byte {
+	keys := [][]byte{[]byte("repo:" + f.RepositoryID)}
+	if text := NormalizeText(f.Text); text != "" {
+		keys = append(keys, []byte("text:"+text))
+	}
+	return keys
+}
 
-	err = s.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("repositories")).Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			// Decode repository.
-			var r internal.Repository
-			if err := proto.Unmarshal(v, &r); err != nil {
-				return err
-			}
+// feature returns the feature recorded under key.
+func feature(tx *bolt.Tx, key []byte) (*Feature, error) {
+	v := tx.Bucket([]byte("featured")).Get(key)
+	if v == nil {
+		return nil, nil
+	}
 
-			// Retrieve repository language.
-			lang := r.GetLanguage()
+	var pb internal.Feature
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return &Feature{
+		RepositoryID: pb.GetRepositoryID(),
+		Username:     pb.GetUsername(),
+		Text:         pb.GetText(),
+		Time:         time.Unix(0, pb.GetTime()).UTC(),
+	}, nil
+}
 
-			// Ignore marked repositories or repositories that have a lower message count.
-			if r.GetNotified() {
-				continue
-			} else if m[lang] != nil && len(r.GetMessages()) <= len(m[lang].Messages) {
-				continue
-			}
+// saveFeature saves f under key.
+func saveFeature(tx *bolt.Tx, key []byte, f *Feature) error {
+	buf, err := proto.Marshal(&internal.Feature{
+		RepositoryID: proto.String(f.RepositoryID),
+		Username:     proto.String(f.Username),
+		Text:         proto.String(f.Text),
+		Time:         proto.Int64(f.Time.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("featured")).Put(key, buf)
+}
 
-			// Override repo.
-			m[lang] = decodeRepository(&r)
-		}
+// ShortURL returns the saved short URL for a long URL.
+// Returns a blank string if the URL has not been shortened.
+func (s *Store) ShortURL(longURL string) (shortURL string, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		shortURL = string(tx.Bucket([]byte("short_urls")).Get([]byte(longURL)))
 		return nil
 	})
 	return
 }
 
-// MarkNotified flags a repository as notified.
-func (s *Store) MarkNotified(repositoryID string) error {
+// SaveShortURL saves the short URL for a long URL.
+func (s *Store) SaveShortURL(longURL, shortURL string) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
-		// Retrieve repository.
-		r, err := s.repository(tx, repositoryID)
-		if err != nil {
-			return err
-		} else if r == nil {
-			return ErrRepositoryNotFound
-		}
-
-		// Update the notified flag.
-		r.Notified = proto.Bool(true)
+		return tx.Bucket([]byte("short_urls")).Put([]byte(longURL), []byte(shortURL))
+	})
+}
 
-		// Perist repository.
-		if err := s.saveRepository(tx, r); err != nil {
-			return err
-		}
-		return nil
+// ShortURLs returns all saved short URLs, ordered by long URL.
+func (s *Store) ShortURLs() (a []*ShortURL, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("short_urls")).ForEach(func(k, v []byte) error {
+			a = append(a, &ShortURL{Long: string(k), Short: string(v)})
+			return nil
+		})
 	})
+	return
 }
 
-// WriteTo writes the length and contents of the engine to w.
+// WriteTo writes the length and contents of the engine to w. If messages are
+// stored separately then only the metadata file is written.
 func (s *Store) WriteTo(w io.Writer) (n int64, err error) {
-	tx, err := s.db.Begin(false)
+	return writeDB(s.db, w)
+}
+
+// WriteMessagesTo writes the length and contents of the message file to w.
+// Returns ErrNoMessageFile if messages are stored with repository metadata.
+func (s *Store) WriteMessagesTo(w io.Writer) (n int64, err error) {
+	if s.messageDB == nil {
+		return 0, ErrNoMessageFile
+	}
+	return writeDB(s.messageDB, w)
+}
+
+// writeDB writes the length and contents of db to w.
+func writeDB(db *bolt.DB, w io.Writer) (n int64, err error) {
+	tx, err := db.Begin(false)
 	if err != nil {
 		return 0, err
 	}
@@ -245,7 +3357,8 @@ func (s *Store) WriteTo(w io.Writer) (n int64, err error) {
 }
 
 // repository returns a repository by ID.
-func (s *Store) repository(tx *bolt.Tx, id string) (*internal.Repository, error) {
+func (s *Store) repository(tx *storeTx, id string) (*internal.Repository, error) {
+	id = canonicalRepositoryID(tx.Tx, id)
 	v := tx.Bucket([]byte("repositories")).Get([]byte(id))
 	if v == nil {
 		return nil, nil
@@ -253,29 +3366,303 @@ func (s *Store) repository(tx *bolt.Tx, id string) (*internal.Repository, error)
 
 	r := &internal.Repository{}
 	if err := proto.Unmarshal(v, r); err != nil {
+		return nil, &DecodeError{Err: err}
+	} else if err := loadMessages(tx, r); err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
-// saveRepository saves a repository in the store.
-func (s *Store) saveRepository(tx *bolt.Tx, r *internal.Repository) error {
+// loadMessages sets r's messages from the message file, if messages are
+// stored separately. Messages stored with the metadata are kept if the
+// message file has no entry for the repository.
+func loadMessages(tx *storeTx, r *internal.Repository) error {
+	bkt, err := tx.messageBucket()
+	if err != nil || bkt == nil {
+		return err
+	}
+
+	v := bkt.Get([]byte(r.GetID()))
+	if v == nil {
+		return nil
+	}
+
+	var pb internal.RepositoryMessages
+	if err := proto.Unmarshal(v, &pb); err != nil {
+		return &DecodeError{Err: err}
+	}
+	r.Messages = pb.Messages
+	return nil
+}
+
+// saveRepository saves a repository in the store. If messages are stored
+// separately then they are saved to the message file and removed from the
+// metadata.
+func (s *Store) saveRepository(tx *storeTx, r *internal.Repository) error {
+	bkt, err := tx.messageBucket()
+	if err != nil {
+		return err
+	} else if bkt != nil {
+		buf, err := proto.Marshal(&internal.RepositoryMessages{
+			RepositoryID: r.ID,
+			Messages:     r.Messages,
+		})
+		if err != nil {
+			return err
+		} else if err := bkt.Put([]byte(r.GetID()), buf); err != nil {
+			return err
+		}
+
+		other := *r
+		other.Messages = nil
+		r = &other
+	}
+
 	buf, err := proto.Marshal(r)
 	if err != nil {
 		return err
+	} else if err := tx.Bucket([]byte("repository_ids")).Put([]byte(strings.ToLower(r.GetID())), []byte(r.GetID())); err != nil {
+		return err
+	} else if err := indexRepository(tx.Tx, r); err != nil {
+		return err
 	}
 	return tx.Bucket([]byte("repositories")).Put([]byte(r.GetID()), buf)
 }
 
+// migrateRepositoryIDs records the case-insensitive ID of every repository
+// saved before IDs preserved case. Repositories stored under more than one
+// case variant are merged into the variant chosen by canonicalVariant(). The
+// migration runs once.
+func (s *Store) migrateRepositoryIDs() error {
+	return s.update(func(tx *storeTx) error {
+		meta := tx.Bucket([]byte("meta"))
+		if meta.Get([]byte("repository_ids_migrated")) != nil {
+			return nil
+		}
+
+		// Group stored IDs by their lowercase form. IDs are in key order.
+		variants := make(map[string][]string)
+		var keys []string
+		if err := tx.Bucket([]byte("repositories")).ForEach(func(k, _ []byte) error {
+			lower := strings.ToLower(string(k))
+			if variants[lower] == nil {
+				keys = append(keys, lower)
+			}
+			variants[lower] = append(variants[lower], string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, lower := range keys {
+			ids := variants[lower]
+			canonical := ids[0]
+			if len(ids) > 1 {
+				var err error
+				if canonical, err = canonicalVariant(tx, lower, ids); err != nil {
+					return err
+				} else if err := s.mergeRepositories(tx, canonical, ids); err != nil {
+					return err
+				}
+			}
+			if err := tx.Bucket([]byte("repository_ids")).Put([]byte(lower), []byte(canonical)); err != nil {
+				return err
+			}
+		}
+		return meta.Put([]byte("repository_ids_migrated"), []byte{1})
+	})
+}
+
+// canonicalVariant returns the case variant in ids that the others are merged
+// into. Variants that are not all lowercase have the casing returned by the
+// remote store so the one with the most messages is chosen, with ties going
+// to the first in key order. The lowercase variant is only chosen if there
+// is no other.
+func canonicalVariant(tx *storeTx, lower string, ids []string) (string, error) {
+	canonical, max := lower, -1
+	for _, id := range ids {
+		if id == lower {
+			continue
+		}
+		r, err := repositoryVariant(tx, id)
+		if err != nil {
+			return "", err
+		} else if n := len(r.GetMessages()); n > max {
+			canonical, max = id, n
+		}
+	}
+	return canonical, nil
+}
+
+// repositoryVariant returns the repository stored under the exact key id.
+// Other lookups resolve case variants to the canonical ID.
+func repositoryVariant(tx *storeTx, id string) (*internal.Repository, error) {
+	var r internal.Repository
+	if err := proto.Unmarshal(tx.Bucket([]byte("repositories")).Get([]byte(id)), &r); err != nil {
+		return nil, &DecodeError{Err: err}
+	} else if err := loadMessages(tx, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// mergeRepositories moves the messages & curation of the case variants in ids
+// onto the canonical repository and deletes the variants. Labels are combined,
+// boosts are added, and the license & topics are kept from a variant if the
+// canonical repository has none. The merged repository is notified if any
+// variant was notified. Flags, fetch times, & feature reservations are moved
+// to the canonical ID unless it has its own.
+func (s *Store) mergeRepositories(tx *storeTx, canonical string, ids []string) error {
+	r, err := s.repository(tx, canonical)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == canonical {
+			continue
+		}
+
+		other, err := repositoryVariant(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range other.GetMessages() {
+			if hasMessage(r, m.GetID()) {
+				continue
+			}
+			r.Messages = append(r.Messages, m)
+			if err := indexMessage(tx.Tx, r.GetID(), m); err != nil {
+				return err
+			}
+		}
+		if other.GetNotified() && !r.GetNotified() {
+			r.Notified, r.NotifiedAt = other.Notified, other.NotifiedAt
+		}
+
+		// Merge operator curation & metadata missing from the canonical repository.
+		for _, label := range other.GetLabels() {
+			if !hasLabel(r.GetLabels(), label) {
+				r.Labels = append(r.Labels, label)
+			}
+		}
+		if other.GetBoost() != 0 {
+			r.Boost = proto.Int64(r.GetBoost() + other.GetBoost())
+		}
+		if r.GetLicense() == "" && other.GetLicense() != "" {
+			r.License = other.License
+		}
+		if len(r.GetTopics()) == 0 {
+			r.Topics = other.GetTopics()
+		}
+
+		if err := moveRepositoryRecords(tx.Tx, id, canonical); err != nil {
+			return err
+		} else if err := deleteRepository(tx, []byte(id)); err != nil {
+			return err
+		} else if err := unindexRepository(tx.Tx, id); err != nil {
+			return err
+		}
+	}
+
+	return s.saveRepository(tx, r)
+}
+
+// moveRepositoryRecords moves the flag & feature reservation keyed by the
+// repository ID from to the ID to, unless to has its own. The most recent
+// fetch time of the two is kept.
+func moveRepositoryRecords(tx *bolt.Tx, from, to string) error {
+	if f, err := flaggedRepository(tx, from); err == nil {
+		if _, err := flaggedRepository(tx, to); err == ErrFlaggedRepositoryNotFound {
+			f.RepositoryID = to
+			if err := saveFlaggedRepository(tx, f); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	} else if err != ErrFlaggedRepositoryNotFound {
+		return err
+	}
+
+	if f, err := feature(tx, []byte("repo:"+from)); err != nil {
+		return err
+	} else if f != nil {
+		if other, err := feature(tx, []byte("repo:"+to)); err != nil {
+			return err
+		} else if other == nil {
+			f.RepositoryID = to
+			if err := saveFeature(tx, []byte("repo:"+to), f); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket([]byte("featured")).Delete([]byte("repo:" + from)); err != nil {
+			return err
+		}
+	}
+
+	fetched := tx.Bucket([]byte("fetched"))
+	if v := fetched.Get([]byte(from)); len(v) == 8 {
+		if other := fetched.Get([]byte(to)); len(other) != 8 || btou64(v) > btou64(other) {
+			if err := fetched.Put([]byte(to), append([]byte(nil), v...)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// canonicalRepositoryID returns the ID a repository is stored under, ignoring
+// case. Returns id if the repository does not exist.
+func canonicalRepositoryID(tx *bolt.Tx, id string) string {
+	bkt := tx.Bucket([]byte("repositories"))
+	if bkt.Get([]byte(id)) != nil {
+		return id
+	} else if v := tx.Bucket([]byte("repository_ids")).Get([]byte(strings.ToLower(id))); v != nil {
+		return string(v)
+	}
+
+	// Repositories saved before IDs preserved case are stored lowercase.
+	if lower := strings.ToLower(id); bkt.Get([]byte(lower)) != nil {
+		return lower
+	}
+	return id
+}
+
+// updateRepositoryMetadata copies remote metadata from r onto pb.
+func updateRepositoryMetadata(pb *internal.Repository, r *Repository) {
+	pb.Description = proto.String(r.Description)
+	pb.Language = proto.String(r.Language)
+	pb.Stars = proto.Int64(int64(r.Stars))
+	pb.Forks = proto.Int64(int64(r.Forks))
+	pb.Topics = r.Topics
+	pb.Fork = proto.Bool(r.Fork)
+	pb.Archived = proto.Bool(r.Archived)
+	pb.Disabled = proto.Bool(r.Disabled)
+	pb.License = proto.String(r.License)
+}
+
 // encodeRepository encodes r into the internal format.
 func encodeRepository(r *Repository) *internal.Repository {
 	pb := &internal.Repository{
 		ID:          proto.String(r.ID),
 		Description: proto.String(r.Description),
 		Language:    proto.String(r.Language),
+		Stars:       proto.Int64(int64(r.Stars)),
+		Forks:       proto.Int64(int64(r.Forks)),
+		Topics:      r.Topics,
+		Fork:        proto.Bool(r.Fork),
+		Archived:    proto.Bool(r.Archived),
+		Disabled:    proto.Bool(r.Disabled),
+		License:     proto.String(r.License),
 		Notified:    proto.Bool(r.Notified),
+		Labels:      r.Labels,
 		Messages:    make([]*internal.Message, len(r.Messages)),
 	}
+	if r.Boost != 0 {
+		pb.Boost = proto.Int64(int64(r.Boost))
+	}
 
 	for i, m := range r.Messages {
 		pb.Messages[i] = encodeMessage(m)
@@ -290,7 +3677,16 @@ func decodeRepository(pb *internal.Repository) *Repository {
 		ID:          pb.GetID(),
 		Description: pb.GetDescription(),
 		Language:    pb.GetLanguage(),
+		Stars:       int(pb.GetStars()),
+		Forks:       int(pb.GetForks()),
+		Topics:      pb.GetTopics(),
+		Fork:        pb.GetFork(),
+		Archived:    pb.GetArchived(),
+		Disabled:    pb.GetDisabled(),
+		License:     pb.GetLicense(),
 		Notified:    pb.GetNotified(),
+		Boost:       int(pb.GetBoost()),
+		Labels:      pb.GetLabels(),
 		Messages:    make([]*Message, len(pb.Messages)),
 	}
 
@@ -303,19 +3699,95 @@ func decodeRepository(pb *internal.Repository) *Repository {
 
 // encodeMessage encodes m into the internal format.
 func encodeMessage(m *Message) *internal.Message {
-	return &internal.Message{
-		ID:   proto.Uint64(m.ID),
-		Text: proto.String(m.Text),
+	pb := &internal.Message{
+		ID:     proto.Uint64(m.ID),
+		Text:   proto.String(m.Text),
+		URL:    proto.String(m.URL),
+		Author: proto.String(m.Author),
+	}
+	if !m.Time.IsZero() {
+		pb.Time = proto.Int64(m.Time.UnixNano())
+	}
+	if !m.AuthorCreatedAt.IsZero() {
+		pb.AuthorFollowers = proto.Int64(int64(m.AuthorFollowers))
+		pb.AuthorCreatedAt = proto.Int64(m.AuthorCreatedAt.UnixNano())
 	}
+	if m.Client != "" {
+		pb.Client = proto.String(m.Client)
+	}
+	if m.Source != "" {
+		pb.Source = proto.String(m.Source)
+	}
+	return pb
 }
 
 // decodeMessage decodes pb into an application type.
 func decodeMessage(pb *internal.Message) *Message {
-	return &Message{
-		ID:   pb.GetID(),
-		Text: pb.GetText(),
+	m := &Message{
+		ID:     pb.GetID(),
+		Text:   pb.GetText(),
+		URL:    pb.GetURL(),
+		Author: pb.GetAuthor(),
+	}
+	if pb.Time != nil {
+		m.Time = time.Unix(0, pb.GetTime()).UTC()
 	}
+	if pb.AuthorCreatedAt != nil {
+		m.AuthorFollowers = int(pb.GetAuthorFollowers())
+		m.AuthorCreatedAt = time.Unix(0, pb.GetAuthorCreatedAt()).UTC()
+	}
+	m.Client, m.Source = pb.GetClient(), pb.GetSource()
+	return m
+}
+
+// messagesByID sorts encoded messages by ID.
+type messagesByID []*internal.Message
+
+func (p messagesByID) Len() int           { return len(p) }
+func (p messagesByID) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p messagesByID) Less(i, j int) bool { return p[i].GetID() < p[j].GetID() }
+
+// templateStatsByName sorts template stats by username, then template.
+type templateStatsByName []*TemplateStats
+
+func (p templateStatsByName) Len() int      { return len(p) }
+func (p templateStatsByName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p templateStatsByName) Less(i, j int) bool {
+	if p[i].Username != p[j].Username {
+		return p[i].Username < p[j].Username
+	}
+	return p[i].Template < p[j].Template
+}
+
+// languageStatsByMentions sorts language stats by mention count, highest
+// first, then by language.
+type languageStatsByMentions []*LanguageStats
+
+func (p languageStatsByMentions) Len() int      { return len(p) }
+func (p languageStatsByMentions) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p languageStatsByMentions) Less(i, j int) bool {
+	if p[i].Mentions != p[j].Mentions {
+		return p[i].Mentions > p[j].Mentions
+	}
+	return p[i].Language < p[j].Language
+}
+
+// repositoriesByScore sorts pinned repositories first and then by ranked
+// score, highest first. Mentions are weighted by source.
+type repositoriesByScore struct {
+	a       []*Repository
+	weights map[string]float64
 }
 
-// errDuplicateMessage is a marker error.
-var errDuplicateMessage = errors.New("duplicate message")
+func (p repositoriesByScore) Len() int           { return len(p.a) }
+func (p repositoriesByScore) Swap(i, j int)      { p.a[i], p.a[j] = p.a[j], p.a[i] }
+func (p repositoriesByScore) Less(i, j int) bool { return rankedAbove(p.a[i], p.a[j], p.weights) }
+
+// staleRepositoriesByFetchedAt sorts repositories by fetch time, oldest first.
+type staleRepositoriesByFetchedAt []*staleRepository
+
+func (p staleRepositoriesByFetchedAt) Len() int      { return len(p) }
+func (p staleRepositoriesByFetchedAt) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p staleRepositoriesByFetchedAt) Less(i, j int) bool {
+	return p[i].fetchedAt.Before(p[j].fetchedAt)
+}
@@ -1,14 +1,24 @@
 package scuttlebutt_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/events"
+	"github.com/benbjohnson/scuttlebutt/internal"
+	"github.com/boltdb/bolt"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/gogo/protobuf/proto"
 )
 
 // Ensure that duplicate messages are only recorded once.
@@ -22,9 +32,9 @@ func TestStore_AddMessage_Duplicate(t *testing.T) {
 	}
 
 	// Add duplicate messages.
-	if err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
 		t.Fatal(err)
-	} else if err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -39,128 +49,2307 @@ func TestStore_AddMessage_Duplicate(t *testing.T) {
 	}
 }
 
+// Ensure that multiple messages can be added in one call with per-message errors.
+func TestStore_AddMessages(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store with one missing repository.
+	var lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		if id == "github.com/user/nope" {
+			return nil, nil
+		}
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"},
+		{ID: 2, Text: "B", RepositoryID: "github.com/user/nope"},
+		{ID: 3, Text: "C", RepositoryID: "github.com/user/repo", AuthorFollowers: 5, AuthorCreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Client: "Web"},
+		{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"},
+	})
+	if !reflect.DeepEqual(errs, []error{nil, scuttlebutt.ErrRepositoryNotFound, nil, nil}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if lookupN != 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// Verify that messages were appended once.
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r.Messages, []*scuttlebutt.Message{
+		{ID: 1, Text: "A"},
+		{ID: 3, Text: "C", AuthorFollowers: 5, AuthorCreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Client: "Web"},
+	}) {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	}
+}
+
+// Ensure cross-posted mentions within the duplicate window are counted once.
+func TestStore_AddMessages_Duplicate(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.SpamFilter = &scuttlebutt.SpamFilter{DuplicateWindow: time.Hour}
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "New release! https://t.co/a", Author: "a", Time: now, RepositoryID: "github.com/user/repo"},
+		{ID: 2, Text: "New release! https://t.co/b", Author: "b", Time: now.Add(time.Minute), RepositoryID: "github.com/user/repo"},
+		{ID: 3, Text: "Finally shipped", Author: "a", Time: now.Add(2 * time.Minute), RepositoryID: "github.com/user/repo"},
+		{ID: 4, Text: "Nice", Author: "c", Time: now.Add(3 * time.Minute), RepositoryID: "github.com/user/repo"},
+		{ID: 5, Text: "New release! https://t.co/c", Author: "d", Time: now.Add(2 * time.Hour), RepositoryID: "github.com/user/repo"},
+	})
+	dup := &scuttlebutt.SpamError{Reason: scuttlebutt.SpamDuplicate}
+	if !reflect.DeepEqual(errs, []error{nil, dup, dup, nil, nil}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 3 {
+		t.Fatalf("unexpected message count: %d", len(r.Messages))
+	}
+}
+
+// Ensure an initialized store can be reopened read-only and rejects writes.
+func TestStore_Open_ReadOnly(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the same file read-only.
+	s.Store.Close()
+	s.Store.ReadOnly = true
+	if err := s.Store.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if r == nil || len(r.Messages) != 1 {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if err := s.DeleteRetry(1); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// Ensure failed messages are queued with backoff until they are deleted.
+func TestStore_AddRetry(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	m := &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo", Author: "alice"}
+
+	// Failed attempts back off exponentially.
+	if rm, err := s.AddRetry(m, errors.New("marker"), now); err != nil {
+		t.Fatal(err)
+	} else if rm.Attempts != 1 || !rm.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected retry: %s", spew.Sdump(rm))
+	} else if rm, err := s.AddRetry(m, errors.New("marker"), now); err != nil {
+		t.Fatal(err)
+	} else if rm.Attempts != 2 || !rm.NextAttemptAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("unexpected retry: %s", spew.Sdump(rm))
+	}
+
+	// Exhausted quotas wait until the reset and are not counted.
+	reset := now.Add(time.Hour)
+	if rm, err := s.AddRetry(m, &scuttlebutt.RateLimitError{Reset: reset}, now); err != nil {
+		t.Fatal(err)
+	} else if rm.Attempts != 2 || !rm.NextAttemptAt.Equal(reset) {
+		t.Fatalf("unexpected retry: %s", spew.Sdump(rm))
+	}
+
+	// Only messages due for another attempt are returned.
+	if a, err := s.DueRetries(now, 0); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected due retries: %s", spew.Sdump(a))
+	} else if a, err := s.DueRetries(reset, 0); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || !reflect.DeepEqual(a[0].Message, m) || a[0].Err == "" {
+		t.Fatalf("unexpected due retries: %s", spew.Sdump(a))
+	}
+
+	if err := s.DeleteRetry(1); err != nil {
+		t.Fatal(err)
+	} else if n, err := s.RetryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected retry count: %d", n)
+	}
+}
+
+// Ensure retry backoff doubles up to the maximum.
+func TestRetryBackoff(t *testing.T) {
+	for i, tt := range []struct {
+		n int
+		d time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{100, scuttlebutt.DefaultMaxRetryBackoff},
+	} {
+		if d := scuttlebutt.RetryBackoff(tt.n); d != tt.d {
+			t.Errorf("%d. unexpected backoff: %s", i, d)
+		}
+	}
+}
+
+// Ensure a takedown purges a repository and blocks it from being stored again.
+func TestStore_Takedown(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Description: "infringing", Language: "go"}, nil
+	}
+
+	if errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "leaked sources", RepositoryID: "github.com/user/leak"},
+		{ID: 2, Text: "other sources", RepositoryID: "github.com/user/other"},
+	}); !reflect.DeepEqual(errs, []error{nil, nil}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if err := s.AddPendingNotification(&scuttlebutt.PendingNotification{Username: "go", RepositoryID: "github.com/user/leak"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddNotification(&scuttlebutt.Notification{Username: "go", RepositoryID: "github.com/user/leak", Success: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ReserveFeature(&scuttlebutt.Feature{Username: "go", RepositoryID: "github.com/user/leak", Text: "leak", Time: time.Now()}, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if err := s.SaveShortURL("https://github.com/user/leak", "https://bit.ly/leak"); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.SaveSnapshot(time.Now(), 10); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.AddRetry(&scuttlebutt.Message{ID: 4, RepositoryID: "github.com/user/leak"}, errors.New("timeout"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Terms from a previous description are also removed.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Description: "renamed", Language: "go"}, nil
+	}
+	if _, err := s.RefreshRepository(context.Background(), "github.com/user/leak"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Takedown("github.com/USER/leak", true); err != nil {
+		t.Fatal(err)
+	} else if r, err := s.Repository("github.com/user/leak"); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if a, err := s.Search("leaked", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("renamed", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("infringing", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Repository.ID != "github.com/user/other" {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	} else if a, err := s.Blacklist(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []string{"github.com/user/leak"}) {
+		t.Fatalf("unexpected blacklist: %v", a)
+	} else if a, err := s.Notifications(0); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected notifications: %s", spew.Sdump(a))
+	} else if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{Username: "go", RepositoryID: "github.com/user/other", Text: "leak", Time: time.Now()}, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if conflict != nil {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	} else if u, err := s.ShortURL("https://github.com/user/leak"); err != nil {
+		t.Fatal(err)
+	} else if u != "" {
+		t.Fatalf("unexpected short url: %s", u)
+	} else if ss, err := s.Snapshot(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if e := ss.Languages["go"]; len(e) != 1 || e[0].RepositoryID != "github.com/user/other" {
+		t.Fatalf("unexpected snapshot: %s", spew.Sdump(ss))
+	} else if n, err := s.RetryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected retry count: %d", n)
+	}
+
+	// The repository can no longer be stored.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, RepositoryID: "github.com/user/leak"}); err != scuttlebutt.ErrBlacklisted {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/leak"}}); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected import count: %d", n)
+	}
+
+	// Missing repositories can only be taken down if they are being blocked.
+	if err := s.Takedown("github.com/user/nope", false); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := s.Takedown("github.com/user/nope", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure repositories blacklisted or opted out while being fetched are not
+// saved.
+func TestStore_AddMessages_BlockedDuringFetch(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		switch id {
+		case "github.com/user/blacklisted":
+			if err := s.AddBlacklist(id); err != nil {
+				t.Fatal(err)
+			}
+		case "github.com/owner/repo":
+			if _, err := s.AddOptOut(&scuttlebutt.OptOut{Pattern: "owner"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/blacklisted"}); err != scuttlebutt.ErrBlacklisted {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, RepositoryID: "github.com/owner/repo"}); err != scuttlebutt.ErrOptedOut {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n, err := s.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected repository count: %d", n)
+	}
+}
+
+// Ensure opted out repositories are purged and never fetched again.
+func TestStore_AddOptOut(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.OptOutPatterns = []string{"configured"}
+
+	var lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	if errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "A", RepositoryID: "github.com/owner/repo"},
+		{ID: 2, Text: "B", RepositoryID: "github.com/other/repo"},
+		{ID: 3, Text: "C", RepositoryID: "github.com/configured/repo"},
+	}); !reflect.DeepEqual(errs, []error{nil, nil, scuttlebutt.ErrOptedOut}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if lookupN != 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// Opt out and verify the existing repository is purged.
+	if n, err := s.AddOptOut(&scuttlebutt.OptOut{Pattern: "Owner", Reason: "requested"}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected purge count: %d", n)
+	} else if r, err := s.Repository("github.com/owner/repo"); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if r, err := s.Repository("github.com/other/repo"); err != nil {
+		t.Fatal(err)
+	} else if r == nil {
+		t.Fatal("expected repository")
+	}
+
+	// New messages are rejected without a remote lookup.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 4, RepositoryID: "github.com/owner/repo2"}); err != scuttlebutt.ErrOptedOut {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := s.RefreshRepository(context.Background(), "github.com/owner/repo"); err != scuttlebutt.ErrOptedOut {
+		t.Fatalf("unexpected error: %v", err)
+	} else if lookupN != 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// Removing the opt-out allows the repository again.
+	if a, err := s.OptOuts(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Pattern != "Owner" || a[0].Reason != "requested" {
+		t.Fatalf("unexpected opt-outs: %s", spew.Sdump(a))
+	} else if err := s.RemoveOptOut("Owner"); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 4, RepositoryID: "github.com/owner/repo2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid patterns are rejected.
+	if _, err := s.AddOptOut(&scuttlebutt.OptOut{Pattern: "owner/["}); err != scuttlebutt.ErrInvalidOptOutPattern {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure opting out purges every record referencing the repository.
+func TestStore_AddOptOut_Purge(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Description: "private", Language: "go"}, nil
+	}
+
+	if errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "secret sources", RepositoryID: "github.com/owner/repo"},
+		{ID: 2, Text: "other sources", RepositoryID: "github.com/other/repo"},
+	}); !reflect.DeepEqual(errs, []error{nil, nil}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if err := s.AddPendingNotification(&scuttlebutt.PendingNotification{Username: "go", RepositoryID: "github.com/owner/repo"}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ReserveFeature(&scuttlebutt.Feature{Username: "go", RepositoryID: "github.com/owner/repo", Text: "secret", Time: time.Now()}, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.SaveSnapshot(time.Now(), 10); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.AddRetry(&scuttlebutt.Message{ID: 3, RepositoryID: "github.com/owner/repo"}, errors.New("timeout"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.AddOptOut(&scuttlebutt.OptOut{Pattern: "owner"}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected purge count: %d", n)
+	} else if a, err := s.Search("secret", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("private", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Repository.ID != "github.com/other/repo" {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.PendingNotifications(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected pending notifications: %s", spew.Sdump(a))
+	} else if n, err := s.RetryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected retry count: %d", n)
+	} else if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{Username: "go", RepositoryID: "github.com/other/repo", Text: "secret", Time: time.Now()}, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if conflict != nil {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	} else if ss, err := s.Snapshot(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if e := ss.Languages["go"]; len(e) != 1 || e[0].RepositoryID != "github.com/other/repo" {
+		t.Fatalf("unexpected snapshot: %s", spew.Sdump(ss))
+	}
+}
+
+// Ensure all messages from an author can be deleted.
+func TestStore_DeleteMessagesByAuthor(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+	if errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, Text: "apple", Author: "alice", RepositoryID: "github.com/user/repo1"},
+		{ID: 2, Text: "banana", Author: "bob", RepositoryID: "github.com/user/repo1"},
+		{ID: 3, Text: "cherry", Author: "Alice", RepositoryID: "github.com/user/repo2"},
+	}); !reflect.DeepEqual(errs, []error{nil, nil, nil}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if _, err := s.AddRetry(&scuttlebutt.Message{ID: 4, Author: "alice", RepositoryID: "github.com/user/repo3"}, errors.New("timeout"), time.Now()); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.AddRetry(&scuttlebutt.Message{ID: 5, Author: "bob", RepositoryID: "github.com/user/repo3"}, errors.New("timeout"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.DeleteMessagesByAuthor("ALICE"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	// Verify only the author's messages were removed.
+	if r, err := s.Repository("github.com/user/repo1"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r.Messages, []*scuttlebutt.Message{{ID: 2, Text: "banana", Author: "bob"}}) {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	} else if r, err := s.Repository("github.com/user/repo2"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 0 {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	}
+
+	// Verify the messages were removed from the search index & retry queue.
+	if a, err := s.Search("apple", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("banana", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || len(a[0].Messages) != 1 {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	} else if a, err := s.Retries(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Message.ID != 5 {
+		t.Fatalf("unexpected retries: %s", spew.Sdump(a))
+	}
+
+	if _, err := s.DeleteMessagesByAuthor(""); err != scuttlebutt.ErrAuthorRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure blacklisted repositories are rejected and excluded from rankings.
+func TestStore_Blacklist(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/awesome-go"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, RepositoryID: "github.com/user/awesome-go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddBlacklist("github.com/*/awesome-*"); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddBlacklist("github.com/["); err != scuttlebutt.ErrInvalidBlacklistPattern {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// New messages are rejected.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 4, RepositoryID: "github.com/other/awesome-js"}); err != scuttlebutt.ErrBlacklisted {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Existing repositories are excluded from rankings.
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if r := m["Go"]; r == nil || r.ID != "github.com/user/repo" {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+
+	// Removing the pattern restores the repository.
+	if err := s.RemoveBlacklist("github.com/*/awesome-*"); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.Blacklist(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected blacklist: %v", a)
+	} else if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if r := m["Go"]; r == nil || r.ID != "github.com/user/awesome-go" {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+
+	// Exact IDs, in any case, & cached regular expressions match until removed.
+	for _, pattern := range []string{"github.com/user/repo", "/spam[0-9]+/"} {
+		if err := s.AddBlacklist(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		id    string
+		match bool
+	}{
+		{id: "github.com/user/repo", match: true},
+		{id: "github.com/User/Repo", match: true},
+		{id: "github.com/user/spam42", match: true},
+		{id: "github.com/user/spam42", match: true},
+		{id: "github.com/user/awesome-go", match: false},
+	} {
+		if v, err := s.Blacklisted(tt.id); err != nil {
+			t.Fatal(err)
+		} else if v != tt.match {
+			t.Fatalf("%s: unexpected match: %v", tt.id, v)
+		}
+	}
+	if err := s.RemoveBlacklist("/spam[0-9]+/"); err != nil {
+		t.Fatal(err)
+	} else if v, err := s.Blacklisted("github.com/user/spam42"); err != nil {
+		t.Fatal(err)
+	} else if v {
+		t.Fatal("expected removed pattern to not match")
+	}
+}
+
+// Ensure repositories missing from the remote store are not looked up again
+// until the cache entry expires.
+func TestStore_AddMessages_NotFoundTTL(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.NotFoundTTL = time.Hour
+
+	var lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		return nil, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), RepositoryID: "github.com/user/nope"}); err != scuttlebutt.ErrRepositoryNotFound {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if lookupN != 1 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// Expired entries are looked up again.
+	s.NotFoundTTL = time.Nanosecond
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, RepositoryID: "github.com/user/nope"}); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if lookupN != 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+}
+
+// Ensure added messages & created repositories are published.
+func TestStore_AddMessages_Events(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.Events = events.NewBus()
+	sub := s.Events.Subscribe()
+	defer sub.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/repo", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := <-sub.C(); !reflect.DeepEqual(e, &events.RepositoryCreated{RepositoryID: "github.com/user/repo", Language: "Go"}) {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if e := <-sub.C(); !reflect.DeepEqual(e, &events.MessageAdded{ID: 1, RepositoryID: "github.com/user/repo", Language: "Go", Text: "hello"}) {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	// Duplicate messages are not published.
+	select {
+	case e := <-sub.C():
+		t.Fatalf("unexpected event: %#v", e)
+	default:
+	}
+}
+
+// Ensure pipeline counters are updated as messages are added.
+func TestStore_AddMessages_Stats(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/nope" {
+			return nil, nil
+		}
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	added := scuttlebutt.Stat(scuttlebutt.StatMessagesAdded)
+	duplicate := scuttlebutt.Stat(scuttlebutt.StatMessagesDuplicate)
+	created := scuttlebutt.Stat(scuttlebutt.StatRepositoriesCreated)
+	notFound := scuttlebutt.Stat(scuttlebutt.StatRemoteNotFound)
+
+	s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo"},
+		{ID: 1, RepositoryID: "github.com/user/repo"},
+		{ID: 2, RepositoryID: "github.com/user/repo"},
+		{ID: 3, RepositoryID: "github.com/user/nope"},
+	})
+
+	if n := scuttlebutt.Stat(scuttlebutt.StatMessagesAdded) - added; n != 2 {
+		t.Fatalf("unexpected added count: %d", n)
+	} else if n := scuttlebutt.Stat(scuttlebutt.StatMessagesDuplicate) - duplicate; n != 1 {
+		t.Fatalf("unexpected duplicate count: %d", n)
+	} else if n := scuttlebutt.Stat(scuttlebutt.StatRepositoriesCreated) - created; n != 1 {
+		t.Fatalf("unexpected created count: %d", n)
+	} else if n := scuttlebutt.Stat(scuttlebutt.StatRemoteNotFound) - notFound; n != 1 {
+		t.Fatalf("unexpected not found count: %d", n)
+	}
+}
+
+// Ensure case variants of a repository ID are merged using the remote casing.
+func TestStore_AddMessages_CaseInsensitive(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	var lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		lookupN++
+		return &scuttlebutt.Repository{ID: "github.com/BurntSushi/toml"}, nil
+	}
+
+	if errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/burntsushi/toml"},
+		{ID: 2, RepositoryID: "github.com/BURNTSUSHI/TOML"},
+	}); errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, RepositoryID: "github.com/Burntsushi/Toml"}); err != nil {
+		t.Fatal(err)
+	} else if lookupN != 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+
+	// All messages are stored on the canonical repository.
+	if r, err := s.Repository("github.com/burntsushi/toml"); err != nil {
+		t.Fatal(err)
+	} else if r == nil || r.ID != "github.com/BurntSushi/toml" || len(r.Messages) != 3 {
+		t.Fatalf("unexpected repository: %#v", r)
+	}
+}
+
+// Ensure repositories saved before IDs preserved case are found by any case
+// and merged with case variants created since.
+func TestStore_Open_MigrateRepositoryIDs(t *testing.T) {
+	s := NewStore()
+	defer s.Close()
+
+	// Write repositories in the legacy format directly.
+	db, err := bolt.Open(s.Path(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucket([]byte("repositories"))
+		if err != nil {
+			return err
+		}
+		for _, r := range []*internal.Repository{
+			{ID: proto.String("github.com/user/repo"), Description: proto.String(""), Language: proto.String("go"), Notified: proto.Bool(true), Messages: []*internal.Message{
+				{ID: proto.Uint64(1), Text: proto.String("legacy mention")},
+				{ID: proto.Uint64(2), Text: proto.String("shared")},
+			}, Labels: []string{scuttlebutt.LabelFeatured}, Boost: proto.Int64(2), License: proto.String("mit"), Topics: []string{"cli"}},
+			{ID: proto.String("github.com/User/Repo"), Description: proto.String(""), Language: proto.String("go"), Notified: proto.Bool(false), Messages: []*internal.Message{
+				{ID: proto.Uint64(2), Text: proto.String("shared")},
+				{ID: proto.Uint64(3), Text: proto.String("new mention")},
+			}, Labels: []string{"curated"}, Boost: proto.Int64(1)},
+			{ID: proto.String("github.com/USER/REPO"), Description: proto.String(""), Language: proto.String("go"), Notified: proto.Bool(false), Messages: []*internal.Message{
+				{ID: proto.Uint64(4), Text: proto.String("shouted mention")},
+			}},
+			{ID: proto.String("github.com/other/legacy"), Description: proto.String(""), Language: proto.String("go"), Notified: proto.Bool(false)},
+		} {
+			buf, err := proto.Marshal(r)
+			if err != nil {
+				return err
+			} else if err := bkt.Put([]byte(r.GetID()), buf); err != nil {
+				return err
+			}
+		}
+
+		// Flag the lowercase variant.
+		flagged, err := tx.CreateBucket([]byte("flagged"))
+		if err != nil {
+			return err
+		}
+		buf, err := proto.Marshal(&internal.FlaggedRepository{RepositoryID: proto.String("github.com/user/repo"), Reason: proto.String("spam"), Approved: proto.Bool(true), CreatedAt: proto.Int64(0)})
+		if err != nil {
+			return err
+		}
+		return flagged.Put([]byte("github.com/user/repo"), buf)
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Case variants are merged into the remote casing with the most mentions
+	// and keep their curation.
+	if n, err := s.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected repository count: %d", n)
+	} else if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if r == nil || r.ID != "github.com/User/Repo" || !r.Notified || len(r.Messages) != 4 {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if !reflect.DeepEqual(r.Labels, []string{"curated", scuttlebutt.LabelFeatured}) || r.Boost != 3 || r.License != "mit" || !reflect.DeepEqual(r.Topics, []string{"cli"}) {
+		t.Fatalf("unexpected curation: %s", spew.Sdump(r))
+	} else if f, err := s.FlaggedRepository("github.com/User/Repo"); err != nil {
+		t.Fatal(err)
+	} else if f == nil || f.RepositoryID != "github.com/User/Repo" || !f.Approved {
+		t.Fatalf("unexpected flag: %s", spew.Sdump(f))
+	} else if a, err := s.Search("legacy mention", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Repository.ID != "github.com/User/Repo" {
+		t.Fatalf("unexpected search results: %s", spew.Sdump(a))
+	}
+
+	// Legacy repositories are found by any case.
+	if ok, err := s.HasRepository("github.com/Other/Legacy"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected repository")
+	}
+}
+
+// Ensure missing repositories are looked up concurrently, up to the limit.
+func TestStore_AddMessages_LookupConcurrency(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.LookupConcurrency = 3
+
+	var mu sync.Mutex
+	var active, maxActive, lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		mu.Lock()
+		active++
+		lookupN++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	a := make([]*scuttlebutt.Message, 10)
+	for i := range a {
+		a[i] = &scuttlebutt.Message{ID: uint64(i), RepositoryID: fmt.Sprintf("github.com/user/repo%d", i)}
+	}
+	for i, err := range s.AddMessages(context.Background(), a) {
+		if err != nil {
+			t.Fatalf("%d. unexpected error: %s", i, err)
+		}
+	}
+
+	if lookupN != 10 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	} else if maxActive != 3 {
+		t.Fatalf("unexpected max concurrent lookups: %d", maxActive)
+	} else if n, err := s.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatalf("unexpected repository count: %d", n)
+	}
+}
+
+// Ensure lookups stop once the remote quota is exhausted.
+func TestStore_AddMessages_LookupConcurrency_RateLimit(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.LookupConcurrency = 2
+
+	reset := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var mu sync.Mutex
+	var lookupN int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lookupN++
+		return nil, &scuttlebutt.RateLimitError{Reset: reset}
+	}
+
+	a := make([]*scuttlebutt.Message, 10)
+	for i := range a {
+		a[i] = &scuttlebutt.Message{ID: uint64(i), RepositoryID: fmt.Sprintf("github.com/user/repo%d", i)}
+	}
+	for i, err := range s.AddMessages(context.Background(), a) {
+		if e, ok := err.(*scuttlebutt.RateLimitError); !ok || !e.Reset.Equal(reset) {
+			t.Fatalf("%d. unexpected error: %v", i, err)
+		}
+	}
+	if lookupN > 2 {
+		t.Fatalf("unexpected lookup count: %d", lookupN)
+	}
+}
+
+// Ensure missing repositories are retrieved in one request from batch remotes.
+func TestStore_AddMessages_BatchRemoteStore(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	var requests [][]string
+	s.Store.RemoteStore = &BatchRemoteStore{
+		RepositoriesFn: func(ids []string) (map[string]*scuttlebutt.Repository, error) {
+			requests = append(requests, ids)
+			return map[string]*scuttlebutt.Repository{
+				"github.com/user/repo1": {ID: "github.com/user/repo1"},
+				"github.com/user/repo2": {ID: "github.com/user/repo2"},
+			}, nil
+		},
+	}
+
+	errs := s.AddMessages(context.Background(), []*scuttlebutt.Message{
+		{ID: 1, RepositoryID: "github.com/user/repo2"},
+		{ID: 2, RepositoryID: "github.com/user/repo1"},
+		{ID: 3, RepositoryID: "github.com/user/nope"},
+	})
+	if !reflect.DeepEqual(errs, []error{nil, nil, scuttlebutt.ErrRepositoryNotFound}) {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if !reflect.DeepEqual(requests, [][]string{{"github.com/user/nope", "github.com/user/repo1", "github.com/user/repo2"}}) {
+		t.Fatalf("unexpected requests: %v", requests)
+	} else if n, err := s.RepositoryN(); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected repository count: %d", n)
+	}
+}
+
+// Ensure that concurrent messages can be written with batching enabled.
+func TestStore_AddMessage_Batch(t *testing.T) {
+	s := NewStore()
+	s.Batch = true
+	s.MaxBatchDelay = 5 * time.Millisecond
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	// Write messages from multiple goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), RepositoryID: fmt.Sprintf("github.com/user/repo%d", i%5)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Verify all messages were saved.
+	var n int
+	for i := 0; i < 5; i++ {
+		r, err := s.Repository(fmt.Sprintf("github.com/user/repo%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += len(r.Messages)
+	}
+	if n != 50 {
+		t.Fatalf("unexpected message count: %d", n)
+	}
+}
+
+// Ensure that messages written to a separate message file are indexed when
+// another write in the same batch fails.
+func TestStore_AddMessage_Batch_MessagePath(t *testing.T) {
+	s := NewStore()
+	s.Batch = true
+	s.MaxBatchDelay = 50 * time.Millisecond
+	s.MessagePath = s.Path() + ".messages"
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+	for i := 1; i < 5; i++ {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(100 + i), Text: "apple", RepositoryID: fmt.Sprintf("github.com/user/repo%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Write messages from multiple goroutines. The oversized repository ID
+	// fails its write transaction after the others have been written.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i == 0 {
+				time.Sleep(10 * time.Millisecond)
+				id := "github.com/user/" + strings.Repeat("x", 40000)
+				if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), Text: "banana", RepositoryID: id}); err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), Text: "banana", RepositoryID: fmt.Sprintf("github.com/user/repo%d", i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Verify the other messages were saved & indexed.
+	for i := 1; i < 5; i++ {
+		if r, err := s.Repository(fmt.Sprintf("github.com/user/repo%d", i)); err != nil {
+			t.Fatal(err)
+		} else if r == nil || len(r.Messages) != 2 {
+			t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+		}
+	}
+	if a, err := s.Search("banana", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 4 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
 // Ensure that an error on the remote store is passed back.
 func TestStore_AddMessage_ErrRemoteStore(t *testing.T) {
 	s := OpenStore()
 	defer s.Close()
 
-	// Mock remote store.
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return nil, errors.New("marker")
+	}
+
+	// Add messages.
+	err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/go1"})
+	if err == nil || err.Error() != `remote: marker` {
+		t.Fatalf("unexpected error: %s", err)
+	} else if class := scuttlebutt.ErrorClass(err); class != scuttlebutt.ErrorClassRemote {
+		t.Fatalf("unexpected error class: %s", class)
+	}
+}
+
+// Ensure that a non-existent repository is ignored.
+func TestStore_AddMessage_ErrRepositoryNotFound(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return nil, nil
+	}
+
+	// Add message to
+	err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/no-such-repo"})
+	if err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// Ensure that a repository can be marked as notified.
+func TestStore_MarkNotified(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	// Add message to pull in repository from remote store.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark repository as notified.
+	if err := s.MarkNotified("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that repository has been updated.
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified {
+		t.Fatal("expected notified")
+	}
+
+}
+
+// Ensure notified flags can be cleared in bulk by language & time.
+func TestStore_ResetNotified(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/ruby" {
+			return &scuttlebutt.Repository{ID: id, Language: "Ruby"}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+
+	for i, id := range []string{"github.com/user/go", "github.com/user/ruby"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), Text: "A", RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		} else if err := s.MarkNotified(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Repositories notified after the cutoff are left alone.
+	if n, err := s.ResetNotified("", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected reset count: %d", n)
+	}
+
+	// Only repositories in the language are reset.
+	if n, err := s.ResetNotified("go", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected reset count: %d", n)
+	}
+
+	if r, err := s.Repository("github.com/user/go"); err != nil {
+		t.Fatal(err)
+	} else if r.Notified {
+		t.Fatal("expected not notified")
+	} else if len(r.Messages) != 1 {
+		t.Fatalf("unexpected message count: %d", len(r.Messages))
+	} else if r, err := s.Repository("github.com/user/ruby"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified {
+		t.Fatal("expected notified")
+	}
+}
+
+// Ensure engagement counts can be recorded with a notification attempt.
+func TestStore_SetNotificationEngagement(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	n := &scuttlebutt.Notification{Username: "oss_go", MessageID: 100, Success: true}
+	if err := s.AddNotification(n); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &scuttlebutt.Engagement{Likes: 1, Retweets: 2, Impressions: 3, Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := s.SetNotificationEngagement(n.ID, e); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.RecentNotifications(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || !reflect.DeepEqual(a[0].Engagement, e) {
+		t.Fatalf("unexpected notifications: %s", spew.Sdump(a))
+	} else if a, err := s.RecentNotifications(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected notification count: %d", len(a))
+	}
+
+	if err := s.SetNotificationEngagement(1000, e); err != scuttlebutt.ErrNotificationNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure OAuth 2.0 tokens can be saved & retrieved by username.
+func TestStore_OAuth2Token(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	if tok, err := s.OAuth2Token("oss_go"); err != nil {
+		t.Fatal(err)
+	} else if tok != nil {
+		t.Fatalf("unexpected token: %#v", tok)
+	}
+
+	tok := &scuttlebutt.OAuth2Token{AccessToken: "ACCESS", RefreshToken: "REFRESH", Expiry: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := s.SaveOAuth2Token("oss_go", tok); err != nil {
+		t.Fatal(err)
+	} else if other, err := s.OAuth2Token("OSS_Go"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(other, tok) {
+		t.Fatalf("unexpected token: %#v", other)
+	}
+}
+
+// Ensure repositories & mentions are counted by normalized language.
+func TestStore_LanguageStats(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.LanguageAliases = scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases)
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		switch id {
+		case "github.com/user/golang":
+			return &scuttlebutt.Repository{ID: id, Language: "golang"}, nil
+		case "github.com/user/ruby":
+			return &scuttlebutt.Repository{ID: id, Language: "Ruby"}, nil
+		case "github.com/user/none":
+			return &scuttlebutt.Repository{ID: id}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+
+	now := time.Now()
+	for i, m := range []*scuttlebutt.Message{
+		{RepositoryID: "github.com/user/go", Time: now},
+		{RepositoryID: "github.com/user/go", Time: now.Add(-48 * time.Hour)},
+		{RepositoryID: "github.com/user/golang"},
+		{RepositoryID: "github.com/user/ruby", Time: now},
+		{RepositoryID: "github.com/user/none", Time: now},
+	} {
+		m.ID, m.Text = uint64(i+1), "A"
+		if err := s.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a, err := s.LanguageStats(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []*scuttlebutt.LanguageStats{
+		{Language: "Go", Repositories: 2, Mentions: 3, RecentMentions: 1},
+		{Language: "Ruby", Repositories: 1, Mentions: 1, RecentMentions: 1},
+	}) {
+		t.Fatalf("unexpected stats: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure featured repositories are pinned and excluding labels remove
+// repositories from rankings.
+func TestStore_AddLabel(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+	for i, id := range []string{
+		"github.com/user/a", "github.com/user/a", "github.com/user/a",
+		"github.com/user/b", "github.com/user/b",
+		"github.com/user/c",
+	} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: id, Text: "A"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func() (ids []string) {
+		a, err := s.TopLanguageRepositories("Go", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range a {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	if err := s.AddLabel("github.com/user/c", scuttlebutt.LabelFeatured); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddLabel("github.com/user/b", scuttlebutt.LabelNever); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddLabel("github.com/user/c", "good-first-pick"); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"github.com/user/c", "github.com/user/a"}; !reflect.DeepEqual(ids(), exp) {
+		t.Fatalf("unexpected ranking: %v", ids())
+	} else if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if r := m["Go"]; r.ID != "github.com/user/c" || !reflect.DeepEqual(r.Labels, []string{"featured", "good-first-pick"}) {
+		t.Fatalf("unexpected top repository: %s", spew.Sdump(r))
+	}
+
+	// Removing labels restores the mention ranking.
+	if err := s.RemoveLabel("github.com/user/c", scuttlebutt.LabelFeatured); err != nil {
+		t.Fatal(err)
+	} else if err := s.RemoveLabel("github.com/user/b", scuttlebutt.LabelNever); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"github.com/user/a", "github.com/user/b", "github.com/user/c"}; !reflect.DeepEqual(ids(), exp) {
+		t.Fatalf("unexpected ranking: %v", ids())
+	}
+
+	// Invalid labels & missing repositories are rejected.
+	if err := s.AddLabel("github.com/user/a", "Not Valid"); err != scuttlebutt.ErrInvalidLabel {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := s.AddLabel("github.com/user/nope", "featured"); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure repositories are ranked by mentions within a window.
+func TestStore_RecentTopRepositories(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.LanguageAliases = scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases)
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/ruby" {
+			return &scuttlebutt.Repository{ID: id, Language: "Ruby"}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "golang"}, nil
+	}
+
+	now := time.Now()
+	for i, m := range []*scuttlebutt.Message{
+		{RepositoryID: "github.com/user/old", Time: now.Add(-48 * time.Hour)},
+		{RepositoryID: "github.com/user/old", Time: now.Add(-48 * time.Hour)},
+		{RepositoryID: "github.com/user/old", Time: now},
+		{RepositoryID: "github.com/user/new", Time: now},
+		{RepositoryID: "github.com/user/new", Time: now},
+		{RepositoryID: "github.com/user/ruby", Time: now},
+		{RepositoryID: "github.com/user/undated"},
+	} {
+		m.ID, m.Text = uint64(i+1), "A"
+		if err := s.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(a []*scuttlebutt.Repository) (ids []string) {
+		for _, r := range a {
+			ids = append(ids, fmt.Sprintf("%s=%d", r.ID, len(r.Messages)))
+		}
+		return ids
+	}
+
+	since := now.Add(-24 * time.Hour)
+	if a, err := s.RecentTopRepositories("go", since, 10); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"github.com/user/new=2", "github.com/user/old=1"}; !reflect.DeepEqual(ids(a), exp) {
+		t.Fatalf("unexpected repositories: %v", ids(a))
+	} else if a, err := s.RecentTopRepositories("", since, 2); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"github.com/user/new=2", "github.com/user/old=1"}; !reflect.DeepEqual(ids(a), exp) {
+		t.Fatalf("unexpected repositories: %v", ids(a))
+	} else if a, err := s.RecentTopRepositories("go", time.Time{}, 1); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"github.com/user/old=3"}; !reflect.DeepEqual(ids(a), exp) {
+		t.Fatalf("unexpected repositories: %v", ids(a))
+	}
+}
+
+// Ensure repositories can be found by their ID, description, or messages.
+func TestStore_Search(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/parser" {
+			return &scuttlebutt.Repository{ID: id, Description: "A fast JSON parser"}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Description: "tools"}, nil
+	}
+
+	for i, m := range []*scuttlebutt.Message{
+		{Text: "check out this parser", RepositoryID: "github.com/user/parser"},
+		{Text: "json is great", RepositoryID: "github.com/user/parser"},
+		{Text: "a JSON parser alternative!", RepositoryID: "github.com/user/other"},
+		{Text: "unrelated", RepositoryID: "github.com/user/other"},
+	} {
+		m.ID = uint64(i + 1)
+		if err := s.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify results are ordered by matching message count.
+	if a, err := s.Search("json PARSER", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected result count: %d", len(a))
+	} else if a[0].Repository.ID != "github.com/user/other" || a[0].Matched || len(a[0].Messages) != 1 || a[0].Messages[0].ID != 3 {
+		t.Fatalf("unexpected result(0): %s", spew.Sdump(a[0]))
+	} else if a[1].Repository.ID != "github.com/user/parser" || !a[1].Matched || len(a[1].Messages) != 0 {
+		t.Fatalf("unexpected result(1): %s", spew.Sdump(a[1]))
+	}
+
+	// Verify results are limited.
+	if a, err := s.Search("user", 1); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected result count: %d", len(a))
+	}
+
+	// Verify stale terms from a previous description do not match.
+	if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/parser", Description: "tools"}}); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.Search("fast", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure editing a description removes its previous terms from the index.
+func TestStore_Search_EditedDescription(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	desc := "legacy widgets"
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Description: desc}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "hello", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.Search("legacy", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+
+	// Refresh with a new description.
+	desc = "modern gadgets"
+	if _, err := s.RefreshRepository(context.Background(), "github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.Search("legacy", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("gadgets", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || !a[0].Matched {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	} else if a, err := s.Search("hello", 10); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || len(a[0].Messages) != 1 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a repository's mentions can be counted per day.
+func TestStore_MentionTimeSeries(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id}, nil
+	}
+
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, tm := range []time.Time{
+		date.Add(1 * time.Hour),
+		date.Add(2 * time.Hour),
+		date.AddDate(0, 0, 2),
+		date.AddDate(0, 0, -1),
+		{},
+	} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), Text: "A", RepositoryID: "github.com/user/repo", Time: tm}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ts, err := s.MentionTimeSeries("github.com/user/repo", date.Add(time.Hour), date.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ts, &scuttlebutt.MentionTimeSeries{
+		RepositoryID: "github.com/user/repo",
+		Days: []*scuttlebutt.DailyMentions{
+			{Date: date, Mentions: 2},
+			{Date: date.AddDate(0, 0, 1), Mentions: 0},
+			{Date: date.AddDate(0, 0, 2), Mentions: 1},
+		},
+		Undated: 1,
+	}) {
+		t.Fatalf("unexpected time series: %s", spew.Sdump(ts))
+	}
+
+	if _, err := s.MentionTimeSeries("github.com/user/nope", date, date); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the top repositories per language can be recorded as a daily snapshot.
+func TestStore_SaveSnapshot(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		switch id {
+		case "github.com/user/ruby":
+			return &scuttlebutt.Repository{ID: id, Language: "Ruby"}, nil
+		case "github.com/user/none":
+			return &scuttlebutt.Repository{ID: id}, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+	}
+
+	for i, id := range []string{
+		"github.com/user/go1",
+		"github.com/user/go2",
+		"github.com/user/go2",
+		"github.com/user/go3",
+		"github.com/user/go3",
+		"github.com/user/go3",
+		"github.com/user/ruby",
+		"github.com/user/none",
+	} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), Text: "A", RepositoryID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.MarkNotified("github.com/user/go3"); err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := s.SaveSnapshot(date.Add(12*time.Hour), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the snapshot is stored by day.
+	if ss, err := s.Snapshot(date.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ss, &scuttlebutt.Snapshot{
+		Date: date,
+		Languages: map[string][]*scuttlebutt.SnapshotEntry{
+			"Go":   {{Rank: 1, RepositoryID: "github.com/user/go2", Mentions: 2}},
+			"Ruby": {{Rank: 1, RepositoryID: "github.com/user/ruby", Mentions: 1}},
+		},
+	}) {
+		t.Fatalf("unexpected snapshot: %s", spew.Sdump(ss))
+	}
+
+	// Verify snapshots can be listed by day.
+	if a, err := s.Snapshots(date); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected snapshot count: %d", len(a))
+	} else if a, err := s.Snapshots(date.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected snapshot count: %d", len(a))
+	} else if ss, err := s.Snapshot(date.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	} else if ss != nil {
+		t.Fatal("expected no snapshot")
+	}
+}
+
+// Ensure that messages can be added and then top repositories computed.
+func TestStore_TopRepositories(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{
+			ID:          id,
+			Description: "lorem ipsum",
+		}
+
+		switch id {
+		case "github.com/benbjohnson/go1":
+			r.Language = "go"
+		case "github.com/benbjohnson/go2":
+			r.Language = "go"
+		case "github.com/benbjohnson/js1":
+			r.Language = "javascript"
+		}
+
+		return r, nil
+	}
+
+	// Add messages.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/go1"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, Text: "B", RepositoryID: "github.com/benbjohnson/go2"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 3, Text: "C", RepositoryID: "github.com/benbjohnson/go2"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 4, Text: "D", RepositoryID: "github.com/benbjohnson/js1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compute top repositories by language.
+	m, err := s.TopRepositories()
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, map[string]*scuttlebutt.Repository{
+		"go": &scuttlebutt.Repository{
+			ID:          "github.com/benbjohnson/go2",
+			Description: "lorem ipsum",
+			Language:    "go",
+			Messages: []*scuttlebutt.Message{
+				{ID: 2, Text: "B"},
+				{ID: 3, Text: "C"},
+			},
+		},
+		"javascript": &scuttlebutt.Repository{
+			ID:          "github.com/benbjohnson/js1",
+			Description: "lorem ipsum",
+			Language:    "javascript",
+			Messages: []*scuttlebutt.Message{
+				{ID: 4, Text: "D"},
+			},
+		},
+	}) {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	}
+}
+
+// Ensure that repositories can be read in pages and iterated in ID order.
+func TestStore_RepositoriesPage(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	for i, id := range []string{"c", "a", "b", "a"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pages [][]string
+	for after := ""; ; {
+		a, err := s.RepositoriesPage(after, 2)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(a) == 0 {
+			break
+		}
+
+		var ids []string
+		for _, r := range a {
+			ids = append(ids, r.ID)
+		}
+		pages = append(pages, ids)
+		after = a[len(a)-1].ID
+	}
+	if !reflect.DeepEqual(pages, [][]string{{"github.com/user/a", "github.com/user/b"}, {"github.com/user/c"}}) {
+		t.Fatalf("unexpected pages: %v", pages)
+	}
+
+	// Iteration stops at the first error.
+	var ids []string
+	err := s.ForEachRepository(context.Background(), func(r *scuttlebutt.Repository) error {
+		if ids = append(ids, r.ID); len(r.Messages) != 2 {
+			return errors.New("marker")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "marker" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(ids, []string{"github.com/user/a", "github.com/user/b"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure that cached top repositories are invalidated by writes and cannot be
+// modified by callers.
+func TestStore_TopRepositories_Cache(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/benbjohnson/go1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying a result does not affect the next call.
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/benbjohnson/go1" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	} else {
+		m["go"].Description = "changed"
+		delete(m, "go")
+	}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if r := m["go"]; r == nil || r.Description != "" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	}
+
+	// Adding messages & marking repositories as notified invalidate the cache.
+	for _, id := range []uint64{2, 3} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: id, RepositoryID: "github.com/benbjohnson/go2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/benbjohnson/go2" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	}
+
+	if err := s.MarkNotified("github.com/benbjohnson/go2"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/benbjohnson/go1" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	}
+}
+
+// Ensure that top repositories are grouped by topic as well as language.
+func TestStore_TopRepositories_Topics(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Language: "python"}
+		switch id {
+		case "github.com/user/ml1":
+			r.Topics = []string{"machine-learning"}
+		case "github.com/user/ml2":
+			r.Topics = []string{"machine-learning", "cli"}
+		}
+		return r, nil
+	}
+
+	// Add messages.
+	for i, id := range []string{"ml1", "ml2", "ml2", "other", "other", "other"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify the top repository for each key.
+	m, err := s.TopRepositories()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(m) != 3 {
+		t.Fatalf("unexpected keys: %s", spew.Sdump(m))
+	} else if r := m["python"]; r.ID != "github.com/user/other" {
+		t.Fatalf("unexpected language repository: %s", r.ID)
+	} else if r := m[scuttlebutt.TopicKey("machine-learning")]; r.ID != "github.com/user/ml2" {
+		t.Fatalf("unexpected topic repository: %s", r.ID)
+	} else if r := m[scuttlebutt.TopicKey("cli")]; r.ID != "github.com/user/ml2" {
+		t.Fatalf("unexpected topic repository: %s", r.ID)
+	} else if !reflect.DeepEqual(r.Topics, []string{"machine-learning", "cli"}) {
+		t.Fatalf("unexpected topics: %v", r.Topics)
+	}
+
+	// Verify topic queries are ordered by mentions.
+	if a, err := s.TopTopicRepositories("Machine-Learning", 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].ID != "github.com/user/ml2" || a[1].ID != "github.com/user/ml1" {
+		t.Fatalf("unexpected topic repositories: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure repositories can be queried by a pattern on name or description.
+func TestStore_TopMatchingRepositories(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Language: "go"}
+		if id == "github.com/user/operator" {
+			r.Description = "A Kubernetes operator"
+		}
+		return r, nil
+	}
+
+	// Add messages.
+	for i, id := range []string{"k8s-tools", "operator", "operator", "other"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify matches on name & description are ordered by mentions.
+	if a, err := s.TopMatchingRepositories(regexp.MustCompile(`(?i)kubernetes|k8s`), 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].ID != "github.com/user/operator" || a[1].ID != "github.com/user/k8s-tools" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(a))
+	}
+
+	// Verify the owner is not matched.
+	if a, err := s.TopMatchingRepositories(regexp.MustCompile(`user`), 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure forks, archived, and disabled repositories are excluded from rankings.
+func TestStore_TopRepositories_Excluded(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		r := &scuttlebutt.Repository{ID: id, Language: "go"}
+		switch id {
+		case "github.com/user/fork":
+			r.Fork = true
+		case "github.com/user/archived":
+			r.Archived = true
+		case "github.com/user/disabled":
+			r.Disabled = true
+		}
+		return r, nil
+	}
+
+	// Add messages. Excluded repositories have the most mentions.
+	for i, id := range []string{"repo", "fork", "fork", "archived", "archived", "disabled", "disabled"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify excluded repositories are skipped by each ranking.
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/user/repo" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	} else if a, err := s.TopLanguageRepositories("go", 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected language repositories: %s", spew.Sdump(a))
+	} else if a, err := s.TopRepositoriesOverall(5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected overall repositories: %s", spew.Sdump(a))
+	}
+
+	// Verify flags are persisted and forks can be included.
+	s.IncludeForks = true
+	if r, err := s.Repository("github.com/user/archived"); err != nil {
+		t.Fatal(err)
+	} else if !r.Archived || s.Exclusion(r) != "archived" {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/user/fork" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	}
+}
+
+// Ensure repositories below the store's thresholds are passed over for the
+// next ranked repository.
+func TestStore_TopRepositories_Thresholds(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Only the least mentioned repository has a description & allowed license.
 	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
-		return nil, errors.New("marker")
+		r := &scuttlebutt.Repository{ID: id, Language: "go"}
+		switch id {
+		case "github.com/user/described":
+			r.Description, r.License = "lorem", "mit"
+		case "github.com/user/unlicensed":
+			r.Description = "lorem"
+		}
+		return r, nil
+	}
+	for i, id := range []string{"blank", "blank", "blank", "unlicensed", "unlicensed", "described"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 1), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	// Add messages.
-	err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/go1"})
-	if err == nil || err.Error() != `remote: marker` {
-		t.Fatalf("unexpected error: %s", err)
+	// Verify the blank repository ranks first without thresholds.
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/user/blank" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	}
+
+	// Verify the next eligible repository ranks first with thresholds.
+	s.Thresholds = &scuttlebutt.Thresholds{RequireDescription: true}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/user/unlicensed" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	}
+
+	s.Thresholds = &scuttlebutt.Thresholds{RequireDescription: true, AllowedLicenses: []string{"MIT"}}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if m["go"].ID != "github.com/user/described" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	} else if a, err := s.TopLanguageRepositories("go", 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].ID != "github.com/user/described" {
+		t.Fatalf("unexpected language repositories: %s", spew.Sdump(a))
 	}
 }
 
-// Ensure that a non-existent repository is ignored.
-func TestStore_AddMessage_ErrRepositoryNotFound(t *testing.T) {
+// Ensure variant language labels are grouped under a normalized language.
+func TestStore_TopRepositories_LanguageAliases(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Store a repository before normalization is enabled.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		switch id {
+		case "github.com/user/legacy":
+			return &scuttlebutt.Repository{ID: id, Language: "golang"}, nil
+		default:
+			return &scuttlebutt.Repository{ID: id, Language: "Go"}, nil
+		}
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/legacy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enable normalization and store more repositories.
+	s.LanguageAliases = scuttlebutt.NewLanguageAliases(scuttlebutt.DefaultLanguageAliases)
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "golang"}, nil
+	}
+	for i, id := range []string{"new", "new"} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i + 2), RepositoryID: "github.com/user/" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify new repositories are stored with the canonical language.
+	if r, err := s.Repository("github.com/user/new"); err != nil {
+		t.Fatal(err)
+	} else if r.Language != "Go" {
+		t.Fatalf("unexpected language: %s", r.Language)
+	}
+
+	// Verify all variants are grouped together.
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if len(m) != 1 || m["Go"].ID != "github.com/user/new" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	} else if a, err := s.TopLanguageRepositories("go", 5); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected language repositories: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure that imported repositories are used instead of the remote store.
+func TestStore_ImportRepositories(t *testing.T) {
 	s := OpenStore()
 	defer s.Close()
 
 	// Mock remote store.
 	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		t.Fatalf("unexpected remote lookup: %s", id)
 		return nil, nil
 	}
 
-	// Add message to
-	err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/no-such-repo"})
-	if err != scuttlebutt.ErrRepositoryNotFound {
+	// Import repository metadata.
+	if n, err := s.ImportRepositories([]*scuttlebutt.Repository{
+		{ID: "github.com/user/repo", Description: "lorem ipsum", Language: "go", Stars: 100},
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected created count: %d", n)
+	}
+
+	// Add message to imported repository.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-import to update metadata.
+	if n, err := s.ImportRepositories([]*scuttlebutt.Repository{
+		{ID: "github.com/user/repo", Description: "dolor", Language: "go", Stars: 200},
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected created count: %d", n)
+	}
+
+	// Imports under a different case update the stored repository.
+	if n, err := s.ImportRepositories([]*scuttlebutt.Repository{
+		{ID: "github.com/USER/repo", Description: "dolor", Language: "go", Stars: 200},
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected created count: %d", n)
+	}
+
+	// Verify that metadata was updated and messages were retained.
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r, &scuttlebutt.Repository{
+		ID:          "github.com/user/repo",
+		Description: "dolor",
+		Language:    "go",
+		Stars:       200,
+		Messages:    []*scuttlebutt.Message{{ID: 1, Text: "A"}},
+	}) {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	}
+}
+
+// Ensure that repository metadata can be refreshed from the remote store.
+func TestStore_RefreshRepository(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	// Mock remote store to return increasing star counts.
+	var stars int
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		stars += 100
+		return &scuttlebutt.Repository{ID: id, Language: "go", Stars: stars, Forks: 5, License: "mit"}, nil
+	}
+
+	// Add message to pull in repository from remote store.
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.MarkNotified("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Refresh and verify counts are updated and state is retained.
+	exp := &scuttlebutt.Repository{
+		ID:       "github.com/user/repo",
+		Language: "go",
+		Stars:    200,
+		Forks:    5,
+		License:  "mit",
+		Notified: true,
+		Messages: []*scuttlebutt.Message{{ID: 1, Text: "A"}},
+	}
+	if r, err := s.RefreshRepository(context.Background(), "github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r, exp) {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r, exp) {
+		t.Fatalf("unexpected stored repository: %s", spew.Sdump(r))
+	}
+
+	// Refreshing an unknown repository returns an error.
+	if _, err := s.RefreshRepository(context.Background(), "github.com/user/nope"); err != scuttlebutt.ErrRepositoryNotFound {
 		t.Fatalf("unexpected error: %s", err)
 	}
 }
 
-// Ensure that a repository can be marked as notified.
-func TestStore_MarkNotified(t *testing.T) {
+// Ensure repositories are returned by when they were last fetched.
+func TestStore_StaleRepositories(t *testing.T) {
 	s := OpenStore()
 	defer s.Close()
 
-	// Mock remote store.
 	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
 		return &scuttlebutt.Repository{ID: id}, nil
 	}
+	if _, err := s.ImportRepositories([]*scuttlebutt.Repository{{ID: "github.com/user/imported"}}); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/fetched"}); err != nil {
+		t.Fatal(err)
+	}
 
-	// Add message to pull in repository from remote store.
-	if err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+	// Imported repositories have never been fetched.
+	if ids, err := s.StaleRepositories(time.Now().Add(-time.Hour), 0); err != nil {
 		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []string{"github.com/user/imported"}) {
+		t.Fatalf("unexpected ids: %v", ids)
 	}
 
-	// Mark repository as notified.
-	if err := s.MarkNotified("github.com/user/repo"); err != nil {
+	// All repositories are stale as of a later time, oldest first.
+	if ids, err := s.StaleRepositories(time.Now().Add(time.Hour), 0); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []string{"github.com/user/imported", "github.com/user/fetched"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	} else if ids, err := s.StaleRepositories(time.Now().Add(time.Hour), 1); err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 1 {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure that messages can be stored in a separate file from metadata.
+func TestStore_MessagePath(t *testing.T) {
+	s := NewStore()
+	s.MessagePath = s.Path() + ".messages"
+	if err := s.Open(); err != nil {
 		t.Fatal(err)
 	}
+	defer s.Close()
 
-	// Verify that repository has been updated.
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.MarkNotified("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, Text: "B", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify messages & metadata are combined.
+	exp := &scuttlebutt.Repository{
+		ID:       "github.com/user/repo",
+		Language: "go",
+		Notified: true,
+		Messages: []*scuttlebutt.Message{{ID: 1, Text: "A"}, {ID: 2, Text: "B"}},
+	}
 	if r, err := s.Repository("github.com/user/repo"); err != nil {
 		t.Fatal(err)
-	} else if !r.Notified {
-		t.Fatal("expected notified")
+	} else if !reflect.DeepEqual(r, exp) {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if a, err := s.Repositories(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, []*scuttlebutt.Repository{exp}) {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(a))
 	}
 
+	// Verify the metadata file does not contain messages.
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	other := scuttlebutt.NewStore(s.Path())
+	if err := other.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if r, err := other.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !r.Notified || len(r.Messages) != 0 {
+		t.Fatalf("unexpected metadata: %s", spew.Sdump(r))
+	}
 }
 
-// Ensure that messages can be added and then top repositories computed.
-func TestStore_TopRepositories(t *testing.T) {
+// Ensure that messages stored with metadata are moved once the store is split.
+func TestStore_MessagePath_Migrate(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen with a separate message file.
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.MessagePath = s.Path() + ".messages"
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing messages are still visible and are retained after adding more.
+	if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if len(r.Messages) != 1 {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	} else if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 2, Text: "B", RepositoryID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if r, err := s.Repository("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(r.Messages, []*scuttlebutt.Message{{ID: 1, Text: "A"}, {ID: 2, Text: "B"}}) {
+		t.Fatalf("unexpected messages: %s", spew.Sdump(r.Messages))
+	}
+}
+
+// Ensure that a repository or normalized text cannot be featured twice within a window.
+func TestStore_ReserveFeature(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &scuttlebutt.Feature{RepositoryID: "github.com/user/repo", Username: "oss_go", Text: "Repo: lorem https://t.co/abc", Time: now}
+	if conflict, err := s.ReserveFeature(f, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if conflict != nil {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	}
+
+	// Same repository is blocked.
+	if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{RepositoryID: "github.com/user/repo", Username: "oss_js", Time: now.Add(time.Minute)}, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(conflict, f) {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	}
+
+	// Same text with different case, whitespace & links is blocked.
+	if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{RepositoryID: "github.com/user/other", Username: "oss_js", Text: "repo:  LOREM http://t.co/xyz", Time: now.Add(time.Minute)}, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(conflict, f) {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	}
+
+	// Features before the window do not conflict.
+	if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{RepositoryID: "github.com/user/repo", Username: "oss_js", Time: now.Add(2 * time.Hour)}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if conflict != nil {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	}
+
+	// Releasing a reservation frees the text but not the newer repository feature.
+	if err := s.ReleaseFeature(f); err != nil {
+		t.Fatal(err)
+	} else if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{RepositoryID: "github.com/user/other", Text: f.Text, Time: now}, now); err != nil {
+		t.Fatal(err)
+	} else if conflict != nil {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	} else if conflict, err := s.ReserveFeature(&scuttlebutt.Feature{RepositoryID: "github.com/user/repo", Time: now.Add(3 * time.Hour)}, now); err != nil {
+		t.Fatal(err)
+	} else if conflict == nil || conflict.Username != "oss_js" {
+		t.Fatalf("unexpected conflict: %s", spew.Sdump(conflict))
+	}
+}
+
+// Ensure that repositories are ranked across languages by normalized score.
+func TestStore_TopRepositoriesOverall(t *testing.T) {
 	s := OpenStore()
 	defer s.Close()
 
 	// Mock remote store.
 	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
-		r := &scuttlebutt.Repository{
-			ID:          id,
-			Description: "lorem ipsum",
+		r := &scuttlebutt.Repository{ID: id, Language: "go"}
+		if id == "github.com/benbjohnson/js1" {
+			r.Language = "javascript"
 		}
+		return r, nil
+	}
 
-		switch id {
-		case "github.com/benbjohnson/go1":
-			r.Language = "go"
-		case "github.com/benbjohnson/go2":
-			r.Language = "go"
-		case "github.com/benbjohnson/js1":
-			r.Language = "javascript"
+	// Add messages. Go has more mentions overall but JS has a single repo.
+	for i, id := range []string{
+		"github.com/benbjohnson/go1",
+		"github.com/benbjohnson/go1",
+		"github.com/benbjohnson/go1",
+		"github.com/benbjohnson/go2",
+		"github.com/benbjohnson/js1",
+	} {
+		if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: uint64(i), RepositoryID: id}); err != nil {
+			t.Fatal(err)
 		}
+	}
 
-		return r, nil
+	// Verify ranking: go1 is 1.5x the go average, js1 is 1x, go2 is 0.5x.
+	a, err := s.TopRepositoriesOverall(2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected count: %d", len(a))
+	} else if a[0].ID != "github.com/benbjohnson/go1" || a[0].Score != 1.5 {
+		t.Fatalf("unexpected repository(0): %s %f", a[0].ID, a[0].Score)
+	} else if a[1].ID != "github.com/benbjohnson/js1" || a[1].Score != 1 {
+		t.Fatalf("unexpected repository(1): %s %f", a[1].ID, a[1].Score)
+	}
+}
+
+// Ensure mentions are weighted by source when ranking overall.
+func TestStore_TopRepositoriesOverall_SourceWeights(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.SourceWeights = map[string]float64{"hn": 3, "twitter": 0.5}
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
 	}
 
-	// Add messages.
-	if err := s.AddMessage(&scuttlebutt.Message{ID: 1, Text: "A", RepositoryID: "github.com/benbjohnson/go1"}); err != nil {
+	// go1 has more mentions but go2 was mentioned on HN.
+	for i, m := range []*scuttlebutt.Message{
+		{RepositoryID: "github.com/user/go1", Source: "twitter"},
+		{RepositoryID: "github.com/user/go1", Source: "twitter"},
+		{RepositoryID: "github.com/user/go1", Source: "twitter"},
+		{RepositoryID: "github.com/user/go2", Source: "hn"},
+		{RepositoryID: "github.com/user/go2"},
+	} {
+		m.ID = uint64(i + 1)
+		if err := s.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Weighted mentions are 1.5 for go1 & 4 for go2, averaging 2.75.
+	if a, err := s.TopRepositoriesOverall(-1); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].ID != "github.com/user/go2" || a[1].ID != "github.com/user/go1" {
+		t.Fatalf("unexpected ranking: %s", spew.Sdump(a))
+	} else if a[0].Score != 4/2.75 {
+		t.Fatalf("unexpected score: %f", a[0].Score)
+	}
+
+	// Unweighted counts are broken down by source.
+	if m, err := s.RepositorySources("github.com/user/go2"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, map[string]int{"hn": 1, scuttlebutt.UnknownSource: 1}) {
+		t.Fatalf("unexpected sources: %v", m)
+	} else if _, err := s.RepositorySources("github.com/user/nope"); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure mentions are weighted by source when ranking each language.
+func TestStore_TopRepositories_SourceWeights(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+
+	// go1 has more mentions but go2 was mentioned on HN.
+	for i, m := range []*scuttlebutt.Message{
+		{RepositoryID: "github.com/user/go1", Source: "twitter"},
+		{RepositoryID: "github.com/user/go1", Source: "twitter"},
+		{RepositoryID: "github.com/user/go2", Source: "hn"},
+	} {
+		m.ID = uint64(i + 1)
+		if err := s.AddMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify the most mentioned repository wins without weights.
+	if m, err := s.TopRepositories(); err != nil {
 		t.Fatal(err)
-	} else if err := s.AddMessage(&scuttlebutt.Message{ID: 2, Text: "B", RepositoryID: "github.com/benbjohnson/go2"}); err != nil {
+	} else if m["go"].ID != "github.com/user/go1" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	}
+
+	// Verify the weighted source changes the winner. Another mention is
+	// added so the cached ranking is not reused.
+	s.SourceWeights = map[string]float64{"hn": 3}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 4, RepositoryID: "github.com/user/go3"}); err != nil {
 		t.Fatal(err)
-	} else if err := s.AddMessage(&scuttlebutt.Message{ID: 3, Text: "C", RepositoryID: "github.com/benbjohnson/go2"}); err != nil {
+	} else if m, err := s.TopRepositories(); err != nil {
 		t.Fatal(err)
-	} else if err := s.AddMessage(&scuttlebutt.Message{ID: 4, Text: "D", RepositoryID: "github.com/benbjohnson/js1"}); err != nil {
+	} else if m["go"].ID != "github.com/user/go2" {
+		t.Fatalf("unexpected top repository: %s", m["go"].ID)
+	} else if a, err := s.TopLanguageRepositories("go", 5); err != nil {
 		t.Fatal(err)
+	} else if len(a) != 3 || a[0].ID != "github.com/user/go2" || a[1].ID != "github.com/user/go1" {
+		t.Fatalf("unexpected language repositories: %s", spew.Sdump(a))
 	}
+}
 
-	// Compute top repositories by language.
-	m, err := s.TopRepositories()
-	if err != nil {
+// Ensure a repository can be added without mentions and boosted above others.
+func TestStore_AddRepository_Boost(t *testing.T) {
+	s := OpenStore()
+	defer s.Close()
+	s.RemoteStore.RepositoryFn = func(id string) (*scuttlebutt.Repository, error) {
+		if id == "github.com/user/nope" {
+			return nil, nil
+		}
+		return &scuttlebutt.Repository{ID: id, Language: "go"}, nil
+	}
+	if err := s.AddMessage(context.Background(), &scuttlebutt.Message{ID: 1, RepositoryID: "github.com/user/mentioned"}); err != nil {
 		t.Fatal(err)
-	} else if !reflect.DeepEqual(m, map[string]*scuttlebutt.Repository{
-		"go": &scuttlebutt.Repository{
-			ID:          "github.com/benbjohnson/go2",
-			Description: "lorem ipsum",
-			Language:    "go",
-			Messages: []*scuttlebutt.Message{
-				{ID: 2, Text: "B"},
-				{ID: 3, Text: "C"},
-			},
-		},
-		"javascript": &scuttlebutt.Repository{
-			ID:          "github.com/benbjohnson/js1",
-			Description: "lorem ipsum",
-			Language:    "javascript",
-			Messages: []*scuttlebutt.Message{
-				{ID: 4, Text: "D"},
-			},
-		},
-	}) {
-		t.Fatalf("unexpected repositories: %s", spew.Sdump(m))
+	}
+
+	// Add curated pick.
+	if r, err := s.AddRepository(context.Background(), "github.com/user/pick"); err != nil {
+		t.Fatal(err)
+	} else if r.ID != "github.com/user/pick" || len(r.Messages) != 0 {
+		t.Fatalf("unexpected repository: %s", spew.Sdump(r))
+	} else if _, err := s.AddRepository(context.Background(), "github.com/user/nope"); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Boost the pick above the mentioned repository.
+	if err := s.SetBoost("github.com/user/pick", 2); err != nil {
+		t.Fatal(err)
+	} else if err := s.SetBoost("github.com/user/nope", 2); err != scuttlebutt.ErrRepositoryNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m, err := s.TopRepositories(); err != nil {
+		t.Fatal(err)
+	} else if r := m["go"]; r.ID != "github.com/user/pick" || r.Boost != 2 {
+		t.Fatalf("unexpected top repository: %s", spew.Sdump(r))
+	}
+	if a, err := s.TopLanguageRepositories("go", 2); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].ID != "github.com/user/pick" || a[1].ID != "github.com/user/mentioned" {
+		t.Fatalf("unexpected repositories: %s", spew.Sdump(a))
 	}
 }
 
@@ -182,6 +2371,9 @@ func NewStore() *Store {
 		Store: scuttlebutt.NewStore(f.Name()),
 	}
 	s.Store.RemoteStore = &s.RemoteStore
+
+	// Look up repositories one at a time so mocks can count calls.
+	s.Store.LookupConcurrency = 1
 	return s
 }
 
@@ -197,6 +2389,9 @@ func OpenStore() *Store {
 // Close closes the store and removes the underlying data.
 func (s *Store) Close() error {
 	defer os.RemoveAll(s.Store.Path())
+	if s.Store.MessagePath != "" {
+		defer os.RemoveAll(s.Store.MessagePath)
+	}
 	return s.Store.Close()
 }
 
@@ -205,6 +2400,16 @@ type RemoteStore struct {
 	RepositoryFn func(id string) (*scuttlebutt.Repository, error)
 }
 
-func (s *RemoteStore) Repository(id string) (*scuttlebutt.Repository, error) {
+func (s *RemoteStore) Repository(ctx context.Context, id string) (*scuttlebutt.Repository, error) {
 	return s.RepositoryFn(id)
 }
+
+// BatchRemoteStore is a mock remote store that retrieves repositories in batches.
+type BatchRemoteStore struct {
+	RemoteStore
+	RepositoriesFn func(ids []string) (map[string]*scuttlebutt.Repository, error)
+}
+
+func (s *BatchRemoteStore) Repositories(ctx context.Context, ids []string) (map[string]*scuttlebutt.Repository, error) {
+	return s.RepositoriesFn(ids)
+}
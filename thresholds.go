@@ -0,0 +1,52 @@
+package scuttlebutt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Thresholds represents the minimum activity a repository needs before it
+// is eligible to be notified. Zero values disable a threshold.
+type Thresholds struct {
+	MinMentions int
+	MinAuthors  int
+	MinStars    int
+
+	// Requires a non-blank description.
+	RequireDescription bool
+
+	// License keys, such as "mit", that a repository must be licensed under.
+	// Any license, or none, is allowed if empty. Remote stores that do not
+	// report a license, such as Gitea before 1.23, never meet this.
+	AllowedLicenses []string
+}
+
+// Check returns the reason a repository does not meet the thresholds.
+// Returns a blank string if the repository is eligible.
+func (t *Thresholds) Check(r *Repository) string {
+	if n := len(r.Messages); n < t.MinMentions {
+		return fmt.Sprintf("%d mentions below minimum of %d", n, t.MinMentions)
+	} else if n := r.AuthorN(); n < t.MinAuthors {
+		return fmt.Sprintf("%d authors below minimum of %d", n, t.MinAuthors)
+	} else if r.Stars < t.MinStars {
+		return fmt.Sprintf("%d stars below minimum of %d", r.Stars, t.MinStars)
+	} else if t.RequireDescription && strings.TrimSpace(r.Description) == "" {
+		return "missing description"
+	} else if len(t.AllowedLicenses) > 0 && !t.allowedLicense(r.License) {
+		if r.License == "" {
+			return "missing license"
+		}
+		return fmt.Sprintf("license %q not allowed", r.License)
+	}
+	return ""
+}
+
+// allowedLicense returns true if license matches an allowed license key.
+func (t *Thresholds) allowedLicense(license string) bool {
+	for _, other := range t.AllowedLicenses {
+		if license != "" && strings.EqualFold(license, other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package scuttlebutt_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Ensure thresholds report why a repository is not eligible.
+func TestThresholds_Check(t *testing.T) {
+	th := &scuttlebutt.Thresholds{MinMentions: 2, MinAuthors: 2, MinStars: 10}
+	for i, tt := range []struct {
+		repo   *scuttlebutt.Repository
+		reason string
+	}{
+		{
+			repo:   &scuttlebutt.Repository{Stars: 10, Messages: []*scuttlebutt.Message{{Author: "a"}}},
+			reason: "1 mentions below minimum of 2",
+		},
+		{
+			repo:   &scuttlebutt.Repository{Stars: 10, Messages: []*scuttlebutt.Message{{Author: "a"}, {Author: "A"}}},
+			reason: "1 authors below minimum of 2",
+		},
+		{
+			repo:   &scuttlebutt.Repository{Stars: 9, Messages: []*scuttlebutt.Message{{Author: "a"}, {Author: "b"}}},
+			reason: "9 stars below minimum of 10",
+		},
+		{
+			repo: &scuttlebutt.Repository{Stars: 10, Messages: []*scuttlebutt.Message{{Author: "a"}, {Author: "b"}}},
+		},
+		{
+			repo: &scuttlebutt.Repository{Stars: 10, Messages: []*scuttlebutt.Message{{}, {}}},
+		},
+	} {
+		if reason := th.Check(tt.repo); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+
+	// Descriptions & licenses can be required.
+	th = &scuttlebutt.Thresholds{RequireDescription: true, AllowedLicenses: []string{"mit", "apache-2.0"}}
+	for i, tt := range []struct {
+		repo   *scuttlebutt.Repository
+		reason string
+	}{
+		{repo: &scuttlebutt.Repository{Description: " ", License: "mit"}, reason: "missing description"},
+		{repo: &scuttlebutt.Repository{Description: "lorem"}, reason: "missing license"},
+		{repo: &scuttlebutt.Repository{Description: "lorem", License: "gpl-3.0"}, reason: `license "gpl-3.0" not allowed`},
+		{repo: &scuttlebutt.Repository{Description: "lorem", License: "Apache-2.0"}},
+	} {
+		if reason := th.Check(tt.repo); reason != tt.reason {
+			t.Errorf("%d. unexpected reason: %q", i, reason)
+		}
+	}
+
+	// Zero thresholds allow any repository.
+	if reason := (&scuttlebutt.Thresholds{}).Check(&scuttlebutt.Repository{}); reason != "" {
+		t.Fatalf("unexpected reason: %q", reason)
+	}
+}
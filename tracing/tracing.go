@@ -0,0 +1,331 @@
+// Package tracing records timed spans and exports them to an OpenTelemetry
+// collector using the OTLP/HTTP JSON encoding.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultServiceName is the default service name attached to spans.
+	DefaultServiceName = "scuttlebutt"
+
+	// DefaultFlushInterval is the default time between span exports.
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultMaxQueueSize is the default number of spans held for export.
+	// Spans ended while the queue is full are dropped.
+	DefaultMaxQueueSize = 2048
+)
+
+// Tracer records spans and periodically exports them to an OTLP endpoint.
+// A nil tracer records nothing.
+type Tracer struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing chan struct{}
+	queue   []*Span
+
+	// OTLP/HTTP traces endpoint, such as "http://localhost:4318/v1/traces".
+	Endpoint string
+
+	// Name of the service reported with each span.
+	ServiceName string
+
+	FlushInterval time.Duration
+	MaxQueueSize  int
+
+	HTTPClient *http.Client
+
+	// Destination for export errors.
+	LogOutput io.Writer
+}
+
+// NewTracer returns a new instance of Tracer that exports to endpoint.
+func NewTracer(endpoint string) *Tracer {
+	return &Tracer{
+		Endpoint:      endpoint,
+		ServiceName:   DefaultServiceName,
+		FlushInterval: DefaultFlushInterval,
+		MaxQueueSize:  DefaultMaxQueueSize,
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		LogOutput:     ioutil.Discard,
+	}
+}
+
+// Open starts exporting spans in the background.
+func (t *Tracer) Open() error {
+	t.closing = make(chan struct{})
+	t.wg.Add(1)
+	go t.run()
+	return nil
+}
+
+// Close stops the background export and exports any remaining spans.
+func (t *Tracer) Close() error {
+	if t.closing != nil {
+		close(t.closing)
+		t.wg.Wait()
+		t.closing = nil
+	}
+	return t.Flush()
+}
+
+// run exports queued spans on each flush interval until closed.
+func (t *Tracer) run() {
+	defer t.wg.Done()
+
+	logger := log.New(t.LogOutput, "[tracing] ", log.LstdFlags)
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.closing:
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				logger.Printf("export error: %s", err)
+			}
+		}
+	}
+}
+
+// Start begins a new root span.
+func (t *Tracer) Start(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{tracer: t, name: name, start: time.Now()}
+	rand.Read(span.traceID[:])
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// enqueue adds an ended span to the export queue.
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.MaxQueueSize > 0 && len(t.queue) >= t.MaxQueueSize {
+		return
+	}
+	t.queue = append(t.queue, span)
+}
+
+// Flush exports all queued spans.
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	// Construct request.
+	req, err := http.NewRequest("POST", t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request.
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// encode returns the OTLP JSON representation of spans.
+func (t *Tracer) encode(spans []*Span) *exportRequest {
+	a := make([]*otlpSpan, len(spans))
+	for i, span := range spans {
+		a[i] = span.encode()
+	}
+	return &exportRequest{
+		ResourceSpans: []*resourceSpans{{
+			Resource: resource{Attributes: []*attribute{stringAttribute("service.name", t.ServiceName)}},
+			ScopeSpans: []*scopeSpans{{
+				Scope: scope{Name: "github.com/benbjohnson/scuttlebutt"},
+				Spans: a,
+			}},
+		}},
+	}
+}
+
+// Span represents a timed operation. A nil span records nothing.
+type Span struct {
+	mu       sync.Mutex
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []*attribute
+	err      string
+}
+
+// Child begins a new span within s.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := &Span{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID, name: name, start: time.Now()}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// SetAttribute attaches a string, integer, or boolean attribute to the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	var attr *attribute
+	switch v := value.(type) {
+	case string:
+		attr = stringAttribute(key, v)
+	case int:
+		attr = &attribute{Key: key, Value: attributeValue{IntValue: strconv.Itoa(v)}}
+	case int64:
+		attr = &attribute{Key: key, Value: attributeValue{IntValue: strconv.FormatInt(v, 10)}}
+	case bool:
+		attr = &attribute{Key: key, Value: attributeValue{BoolValue: &v}}
+	default:
+		attr = stringAttribute(key, fmt.Sprint(v))
+	}
+
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attr)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End completes the span and queues it for export.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// encode returns the OTLP JSON representation of the span.
+func (s *Span) encode() *otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := &otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != "" {
+		span.Status = &status{Code: statusCodeError, Message: s.err}
+	}
+	return span
+}
+
+// OTLP span kind & status codes.
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// exportRequest is the OTLP JSON body for exporting spans.
+type exportRequest struct {
+	ResourceSpans []*resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource      `json:"resource"`
+	ScopeSpans []*scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []*attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope       `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string       `json:"traceId"`
+	SpanID            string       `json:"spanId"`
+	ParentSpanID      string       `json:"parentSpanId,omitempty"`
+	Name              string       `json:"name"`
+	Kind              int          `json:"kind"`
+	StartTimeUnixNano string       `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string       `json:"endTimeUnixNano"`
+	Attributes        []*attribute `json:"attributes,omitempty"`
+	Status            *status      `json:"status,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// stringAttribute returns a string-valued attribute.
+func stringAttribute(key, value string) *attribute {
+	return &attribute{Key: key, Value: attributeValue{StringValue: &value}}
+}
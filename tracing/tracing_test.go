@@ -0,0 +1,86 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/tracing"
+)
+
+// Ensure ended spans are exported in the OTLP JSON encoding.
+func TestTracer_Flush(t *testing.T) {
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string `json:"key"`
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key string `json:"key"`
+					} `json:"attributes"`
+					Status *struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer s.Close()
+
+	tracer := tracing.NewTracer(s.URL + "/v1/traces")
+	parent := tracer.Start("poll")
+	parent.SetAttribute("source", "twitter")
+	child := parent.Child("store.batch")
+	child.SetError(errors.New("marker"))
+	child.End()
+	parent.End()
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := req.ResourceSpans[0]
+	if attr := rs.Resource.Attributes[0]; attr.Key != "service.name" || attr.Value.StringValue != "scuttlebutt" {
+		t.Fatalf("unexpected resource attribute: %#v", attr)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("unexpected span count: %d", len(spans))
+	} else if spans[0].Name != "store.batch" || spans[0].Status == nil || spans[0].Status.Message != "marker" {
+		t.Fatalf("unexpected child span: %#v", spans[0])
+	} else if spans[1].Name != "poll" || len(spans[1].Attributes) != 1 || spans[1].Attributes[0].Key != "source" {
+		t.Fatalf("unexpected parent span: %#v", spans[1])
+	} else if spans[0].TraceID != spans[1].TraceID || spans[0].ParentSpanID != spans[1].SpanID {
+		t.Fatalf("child not linked to parent: %#v", spans)
+	}
+}
+
+// Ensure a nil tracer records nothing.
+func TestTracer_Nil(t *testing.T) {
+	var tracer *tracing.Tracer
+	span := tracer.Start("poll")
+	span.SetAttribute("source", "twitter")
+	span.Child("store.batch").End()
+	span.End()
+}
@@ -0,0 +1,77 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// MaxDigestN is the maximum number of repositories in a digest.
+const MaxDigestN = 5
+
+// NotifyDigest posts several repositories together. The digest is posted as
+// a single tweet if it fits. Otherwise a header tweet is posted followed by a
+// reply for each repository. Returns a message for each tweet posted.
+func (n *Notifier) NotifyDigest(ctx context.Context, a []*scuttlebutt.Repository) ([]*scuttlebutt.Message, error) {
+	// Post a single tweet listing all repositories, if possible.
+	if text := n.DigestText(a); TextLength(text) <= MaxNotifyTextLength {
+		tweet, err := n.update(ctx, url.Values{"status": {text}})
+		if err != nil {
+			return nil, err
+		}
+		return []*scuttlebutt.Message{tweetMessage(tweet, text, "")}, nil
+	}
+
+	// Otherwise post a thread starting with a header.
+	header := DigestHeader(n.subject())
+	tweet, err := n.update(ctx, url.Values{"status": {header}})
+	if err != nil {
+		return nil, fmt.Errorf("header: %s", err)
+	}
+	messages := []*scuttlebutt.Message{tweetMessage(tweet, header, "")}
+
+	// Reply to the previous tweet with each repository.
+	for _, r := range a {
+		text, err := n.Text(r)
+		if err != nil {
+			return messages, fmt.Errorf("text: repo=%s, err=%s", r.ID, err)
+		}
+
+		tweet, err := n.update(ctx, url.Values{
+			"status":                {text},
+			"in_reply_to_status_id": {strconv.FormatUint(messages[len(messages)-1].ID, 10)},
+		})
+		if err != nil {
+			return messages, fmt.Errorf("reply: repo=%s, err=%s", r.ID, err)
+		}
+		messages = append(messages, tweetMessage(tweet, text, r.ID))
+	}
+	return messages, nil
+}
+
+// DigestHeader returns the first line of a digest for a language or topic.
+func DigestHeader(lang string) string {
+	return fmt.Sprintf("Top %s repositories:", lang)
+}
+
+// subject returns the notifier's label or topic, if set, or its language.
+func (n *Notifier) subject() string {
+	if n.Label != "" {
+		return n.Label
+	} else if n.Topic != "" {
+		return n.Topic
+	}
+	return n.Language
+}
+
+// DigestText returns a single tweet listing each repository's name & URL.
+func (n *Notifier) DigestText(a []*scuttlebutt.Repository) string {
+	text := DigestHeader(n.subject())
+	for i, r := range a {
+		text += fmt.Sprintf("\n%d. %s %s", i+1, r.Name(), n.URL(r))
+	}
+	return text
+}
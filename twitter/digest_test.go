@@ -0,0 +1,77 @@
+package twitter_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+	"github.com/kurrik/twittergo"
+)
+
+// Ensure a short digest is posted as a single tweet.
+func TestNotifier_NotifyDigest_Single(t *testing.T) {
+	n := NewNotifier()
+	n.Language = "go"
+
+	var statuses []string
+	n.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		r.ParseForm()
+		statuses = append(statuses, r.PostForm.Get("status"))
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id_str":"100","created_at": "Wed Aug 29 17:12:58 +0000 2012"}`)),
+		}, nil
+	}
+
+	if messages, err := n.NotifyDigest(context.Background(), []*scuttlebutt.Repository{
+		{ID: "github.com/user/foo"},
+		{ID: "github.com/user/bar"},
+	}); err != nil {
+		t.Fatal(err)
+	} else if len(messages) != 1 {
+		t.Fatalf("unexpected message count: %d", len(messages))
+	} else if len(statuses) != 1 || statuses[0] != "Top go repositories:\n1. foo https://github.com/user/foo\n2. bar https://github.com/user/bar" {
+		t.Fatalf("unexpected statuses: %q", statuses)
+	}
+}
+
+// Ensure a long digest is posted as a thread of replies.
+func TestNotifier_NotifyDigest_Thread(t *testing.T) {
+	n := NewNotifier()
+	n.Language = "go"
+
+	var id int
+	var replies []string
+	n.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		r.ParseForm()
+		replies = append(replies, r.PostForm.Get("in_reply_to_status_id"))
+		id++
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"id_str":"%d","user":{"screen_name":"oss_go"},"created_at": "Wed Aug 29 17:12:58 +0000 2012"}`, id))),
+		}, nil
+	}
+
+	// Generate repositories with names too long to fit in one tweet.
+	var a []*scuttlebutt.Repository
+	for i := 0; i < twitter.MaxDigestN; i++ {
+		a = append(a, &scuttlebutt.Repository{ID: fmt.Sprintf("github.com/user/%s%d", strings.Repeat("x", 40), i)})
+	}
+
+	if messages, err := n.NotifyDigest(context.Background(), a); err != nil {
+		t.Fatal(err)
+	} else if len(messages) != 6 {
+		t.Fatalf("unexpected message count: %d", len(messages))
+	} else if messages[1].RepositoryID != a[0].ID {
+		t.Fatalf("unexpected repository: %s", messages[1].RepositoryID)
+	} else if messages[1].URL != "https://twitter.com/oss_go/status/2" {
+		t.Fatalf("unexpected url: %s", messages[1].URL)
+	} else if strings.Join(replies, ",") != ",1,2,3,4,5" {
+		t.Fatalf("unexpected replies: %q", replies)
+	}
+}
@@ -0,0 +1,95 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// MaxEngagementLookupN is the maximum number of tweets looked up per request.
+const MaxEngagementLookupN = 100
+
+// Engagement returns the like, retweet, & impression counts of tweets, keyed
+// by tweet ID. Deleted tweets are omitted. Counts retrieved before an error
+// are returned with the error.
+func (n *Notifier) Engagement(ctx context.Context, ids []uint64) (map[uint64]*scuttlebutt.Engagement, error) {
+	m := make(map[uint64]*scuttlebutt.Engagement, len(ids))
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > MaxEngagementLookupN {
+			batch = batch[:MaxEngagementLookupN]
+		}
+		ids = ids[len(batch):]
+
+		if err := n.lookupEngagement(ctx, batch, m); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// lookupEngagement retrieves the public metrics of tweets and adds them to m.
+func (n *Notifier) lookupEngagement(ctx context.Context, ids []uint64, m map[uint64]*scuttlebutt.Engagement) error {
+	a := make([]string, len(ids))
+	for i, id := range ids {
+		a[i] = strconv.FormatUint(id, 10)
+	}
+	q := url.Values{"ids": {strings.Join(a, ",")}, "tweet.fields": {"public_metrics"}}
+
+	// Construct request.
+	req, err := http.NewRequest("GET", "/2/tweets?"+q.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("engagement request: %s", err)
+	}
+	req = req.WithContext(ctx)
+
+	// Send request.
+	resp, err := n.Client.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse the response. The twittergo parser only decodes into maps.
+	var body map[string]interface{}
+	err = resp.Parse(&body)
+	n.recordRateLimit(ResourceTweetLookup, resp, err)
+	if err != nil {
+		return fmt.Errorf("parse: %s", err)
+	}
+
+	data, _ := body["data"].([]interface{})
+	for _, v := range data {
+		tweet, _ := v.(map[string]interface{})
+		s, _ := tweet["id"].(string)
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		metrics, _ := tweet["public_metrics"].(map[string]interface{})
+		m[id] = &scuttlebutt.Engagement{
+			Likes:       metricCount(metrics, "like_count"),
+			Retweets:    metricCount(metrics, "retweet_count"),
+			Impressions: metricCount(metrics, "impression_count"),
+		}
+	}
+	return nil
+}
+
+// metricCount returns the integer value of a public metric.
+func metricCount(metrics map[string]interface{}, key string) int {
+	switch v := metrics[key].(type) {
+	case float64:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
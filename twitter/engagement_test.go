@@ -0,0 +1,44 @@
+package twitter_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/kurrik/twittergo"
+)
+
+// Ensure engagement counts are looked up in batches & parsed.
+func TestNotifier_Engagement(t *testing.T) {
+	n := NewNotifier()
+
+	var queries []string
+	n.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		if r.URL.Path != "/2/tweets" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		queries = append(queries, r.URL.Query().Get("ids"))
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"data":[{"id":"100","public_metrics":{"retweet_count":2,"reply_count":0,"like_count":5,"quote_count":0,"impression_count":40}}]}`)),
+		}, nil
+	}
+
+	ids := make([]uint64, 101)
+	for i := range ids {
+		ids[i] = uint64(i + 100)
+	}
+
+	if m, err := n.Engagement(context.Background(), ids); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, map[uint64]*scuttlebutt.Engagement{100: {Likes: 5, Retweets: 2, Impressions: 40}}) {
+		t.Fatalf("unexpected engagement: %s", spew.Sdump(m))
+	} else if len(queries) != 2 || queries[1] != "200" {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
@@ -0,0 +1,70 @@
+package twitter
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// Hashtags maps repository languages to hashtags appended to notifications.
+// Languages without a mapping use a hashtag derived from the language name.
+type Hashtags map[string][]string
+
+// For returns the hashtags for a repository, without the leading "#".
+func (h Hashtags) For(r *scuttlebutt.Repository) []string {
+	for lang, tags := range h {
+		if strings.EqualFold(lang, r.Language) {
+			return tags
+		}
+	}
+
+	if tag := LanguageHashtag(r.Language); tag != "" {
+		return []string{tag}
+	}
+	return nil
+}
+
+// Append adds hashtags for r to the end of text while they fit within
+// MaxNotifyTextLength. Hashtags already in the text are not added again.
+func (h Hashtags) Append(text string, r *scuttlebutt.Repository) string {
+	for _, tag := range h.For(r) {
+		tag = "#" + strings.TrimPrefix(tag, "#")
+		if strings.Contains(strings.ToLower(text), strings.ToLower(tag)) {
+			continue
+		} else if TextLength(text)+1+TextLength(tag) > MaxNotifyTextLength {
+			continue
+		}
+		text += " " + tag
+	}
+	return text
+}
+
+// languageHashtags holds hashtags for languages whose names don't
+// convert cleanly into a hashtag.
+var languageHashtags = map[string]string{
+	"c":           "clang",
+	"c++":         "cpp",
+	"c#":          "csharp",
+	"f#":          "fsharp",
+	"objective-c": "objc",
+	"go":          "golang",
+	"r":           "rstats",
+	"viml":        "vim",
+}
+
+// LanguageHashtag returns a hashtag for a language, without the leading "#".
+// Returns a blank string if the language is blank.
+func LanguageHashtag(lang string) string {
+	if tag, ok := languageHashtags[strings.ToLower(lang)]; ok {
+		return tag
+	}
+
+	// Strip characters that aren't allowed in hashtags.
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, lang)
+}
@@ -0,0 +1,38 @@
+package twitter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// Ensure hashtags are appended based on the repository language.
+func TestHashtags_Append(t *testing.T) {
+	h := twitter.Hashtags{"JavaScript": {"javascript", "#nodejs"}}
+
+	for i, tt := range []struct {
+		text     string
+		language string
+		exp      string
+	}{
+		{text: "proj", language: "JavaScript", exp: "proj #javascript #nodejs"},
+		{text: "proj #NodeJS", language: "JavaScript", exp: "proj #NodeJS #javascript"},
+		{text: "proj", language: "C++", exp: "proj #cpp"},
+		{text: "proj", language: "Emacs Lisp", exp: "proj #emacslisp"},
+		{text: "proj", language: "", exp: "proj"},
+	} {
+		if s := h.Append(tt.text, &scuttlebutt.Repository{Language: tt.language}); s != tt.exp {
+			t.Errorf("%d. unexpected text: %s", i, s)
+		}
+	}
+}
+
+// Ensure hashtags are skipped when they don't fit.
+func TestHashtags_Append_TooLong(t *testing.T) {
+	text := strings.Repeat("x", twitter.MaxNotifyTextLength-5)
+	if s := (twitter.Hashtags{}).Append(text, &scuttlebutt.Repository{Language: "go"}); s != text {
+		t.Fatalf("unexpected text: %s", s)
+	}
+}
@@ -0,0 +1,55 @@
+package twitter
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTweetLength is the maximum weighted length of a tweet.
+	MaxTweetLength = 280
+
+	// URLLength is the weighted length of any URL after t.co wrapping.
+	URLLength = 23
+)
+
+// urlRegex matches URLs that Twitter wraps with t.co links.
+var urlRegex = regexp.MustCompile(`https?://[^\s]+`)
+
+// TextLength returns the weighted length of s as counted by Twitter.
+//
+// URLs count as URLLength regardless of their actual length. Code points in
+// the Latin-1, general punctuation and similar ranges count as one while all
+// others, such as CJK characters and emoji, count as two.
+func TextLength(s string) int {
+	var n, pos int
+	for _, loc := range urlRegex.FindAllStringIndex(s, -1) {
+		n += stringWeight(s[pos:loc[0]]) + URLLength
+		pos = loc[1]
+	}
+	return n + stringWeight(s[pos:])
+}
+
+// stringWeight returns the weighted length of s without URL handling.
+func stringWeight(s string) int {
+	var n int
+	for _, r := range s {
+		n += runeWeight(r)
+	}
+	return n
+}
+
+// runeWeight returns the weighted length of a single code point.
+func runeWeight(r rune) int {
+	switch {
+	case r == utf8.RuneError:
+		return 2
+	case r <= 0x10FF,
+		r >= 0x2000 && r <= 0x200D,
+		r >= 0x2010 && r <= 0x201F,
+		r >= 0x2032 && r <= 0x2037:
+		return 1
+	default:
+		return 2
+	}
+}
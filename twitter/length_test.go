@@ -0,0 +1,83 @@
+package twitter_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// Ensure text length is weighted like Twitter's counter.
+func TestTextLength(t *testing.T) {
+	for i, tt := range []struct {
+		s string
+		n int
+	}{
+		{s: "", n: 0},
+		{s: "hello", n: 5},
+		{s: "café", n: 4},
+		{s: "日本語", n: 6},
+		{s: "⭐", n: 2},
+		{s: "see https://github.com/benbjohnson/scuttlebutt now", n: 4 + 23 + 4},
+		{s: "http://a.com http://b.com", n: 23 + 1 + 23},
+	} {
+		if n := twitter.TextLength(tt.s); n != tt.n {
+			t.Errorf("%d. %q: unexpected length: %d", i, tt.s, n)
+		}
+	}
+}
+
+// Ensure long URLs do not cause descriptions to be truncated.
+func TestNotifyText_LongURL(t *testing.T) {
+	desc := strings.Repeat("x", 200)
+	s := twitter.NotifyText(&scuttlebutt.Repository{
+		ID:          "github.com/" + strings.Repeat("u", 39) + "/proj-" + strings.Repeat("r", 20),
+		Description: desc,
+	})
+	if !strings.Contains(s, desc) {
+		t.Fatalf("unexpected truncation: %s", s)
+	}
+}
+
+// Ensure wide characters are truncated by weight without splitting runes.
+func TestNotifyText_Wide(t *testing.T) {
+	s := twitter.NotifyText(&scuttlebutt.Repository{
+		ID:          "github.com/benbjohnson/proj",
+		Description: strings.Repeat("日", 200),
+	})
+	if n := twitter.TextLength(s); n > twitter.MaxNotifyTextLength {
+		t.Fatalf("unexpected length: %d", n)
+	} else if !strings.HasSuffix(s, "... https://github.com/benbjohnson/proj") {
+		t.Fatalf("unexpected text: %s", s)
+	}
+}
+
+// Ensure descriptions are truncated at sentence and word boundaries
+// without splitting combining characters.
+func TestNotifyText_Truncate(t *testing.T) {
+	const url = "https://github.com/benbjohnson/proj"
+	for i, tt := range []struct {
+		desc   string
+		suffix string
+	}{
+		// Cut at the end of a sentence without an ellipsis.
+		{desc: strings.Repeat("word ", 40) + "end. " + strings.Repeat("more ", 20), suffix: "word end. " + url},
+
+		// Cut at a word boundary.
+		{desc: strings.Repeat("abcdefghi ", 40), suffix: "abcdefghi... " + url},
+
+		// Never separate a combining accent from its base character.
+		{desc: strings.Repeat("é", 300), suffix: "é... " + url},
+	} {
+		s := twitter.NotifyText(&scuttlebutt.Repository{ID: "github.com/benbjohnson/proj", Description: tt.desc})
+		if n := twitter.TextLength(s); n > twitter.MaxNotifyTextLength {
+			t.Errorf("%d. unexpected length: %d", i, n)
+		} else if !strings.HasSuffix(s, tt.suffix) {
+			t.Errorf("%d. unexpected text: %q", i, s)
+		} else if !utf8.ValidString(s) {
+			t.Errorf("%d. invalid utf8: %q", i, s)
+		}
+	}
+}
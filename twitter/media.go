@@ -0,0 +1,72 @@
+package twitter
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// MediaUploadURL is the endpoint for uploading images to attach to tweets.
+const MediaUploadURL = "https://upload.twitter.com/1.1/media/upload.json"
+
+// uploadImage uploads the image for r from the notifier's image source.
+// Returns a blank media ID if there is no image or if the upload fails.
+func (n *Notifier) uploadImage(r *scuttlebutt.Repository) string {
+	if n.Images == nil {
+		return ""
+	}
+
+	data, err := n.Images.Image(r)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	id, err := n.UploadMedia(data)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// UploadMedia uploads an image to Twitter and returns its media ID.
+func (n *Notifier) UploadMedia(data []byte) (string, error) {
+	// Encode image as a multipart form.
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	w, err := mw.CreateFormFile("media", "media")
+	if err != nil {
+		return "", fmt.Errorf("create form file: %s", err)
+	} else if _, err := w.Write(data); err != nil {
+		return "", fmt.Errorf("write form file: %s", err)
+	} else if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("close multipart: %s", err)
+	}
+
+	// Construct request.
+	req, err := http.NewRequest("POST", MediaUploadURL, &buf)
+	if err != nil {
+		return "", fmt.Errorf("upload request: %s", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// Send request.
+	resp, err := n.Client.SendRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse the response.
+	var media map[string]interface{}
+	if err := resp.Parse(&media); err != nil {
+		return "", fmt.Errorf("parse: %s", err)
+	}
+	id, _ := media["media_id_string"].(string)
+	if id == "" {
+		return "", fmt.Errorf("media id not returned")
+	}
+	return id, nil
+}
@@ -1,28 +1,67 @@
 package twitter
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/benbjohnson/scuttlebutt"
 	"github.com/kurrik/twittergo"
 )
 
-// ErrTweetTooLong is returned when a tweet has over 140 characters.
-var ErrTweetTooLong = errors.New("tweet too long")
+// ErrTweetTooLong is returned when a tweet exceeds the maximum tweet length.
+var ErrTweetTooLong = scuttlebutt.ErrNotificationTooLong
 
 // Notifier represents a client to post messages to the Twitter API.
 type Notifier struct {
 	lastTweetTime time.Time
+	rateLimits    map[string]*scuttlebutt.RateLimit
 
 	Username string
 	Language string
 
+	// Optional GitHub topic the account posts about instead of a language.
+	Topic string
+
+	// Optional name of what the account posts about, such as "Kubernetes".
+	// Overrides the topic & language in digest headers.
+	Label string
+
+	// Minimum time between notifications for this account.
+	Interval time.Duration
+
+	// Optional schedule restricting when notifications are sent.
+	// If the schedule has a cron expression then it replaces the interval.
+	Schedule *scuttlebutt.Schedule
+
+	// Optional template for the notification text.
+	// Uses NotifyText() if not specified.
+	Template *Template
+
+	// Optional weighted templates. If set, each repository is notified using
+	// one variant, chosen by ChooseVariant(), instead of Template.
+	Variants []*TemplateVariant
+
+	// Hashtags appended to notifications when space allows.
+	// No hashtags are appended if nil.
+	Hashtags Hashtags
+
+	// Optional shortener applied to repository URLs. The full URL is used
+	// if shortening fails.
+	Shortener scuttlebutt.Shortener
+
+	// Optional source of an image to attach to notifications. If the image
+	// cannot be retrieved or uploaded then the notification is sent as text.
+	Images interface {
+		Image(r *scuttlebutt.Repository) ([]byte, error)
+	}
+
 	Client interface {
 		SendRequest(*http.Request) (*twittergo.APIResponse, error)
 	}
@@ -34,14 +73,43 @@ func NewNotifier() *Notifier {
 }
 
 // Notify updates the authorized user's status. Returns the tweet ID on success.
-func (n *Notifier) Notify(r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
-	text := NotifyText(r)
+func (n *Notifier) Notify(ctx context.Context, r *scuttlebutt.Repository) (*scuttlebutt.Message, error) {
+	text, err := n.Text(r)
+	if err != nil {
+		return nil, fmt.Errorf("text: %s", err)
+	}
+	return n.Post(ctx, r, text)
+}
+
+// Post updates the authorized user's status with text about a repository.
+func (n *Notifier) Post(ctx context.Context, r *scuttlebutt.Repository, text string) (*scuttlebutt.Message, error) {
+	// Attach an image, if available.
+	params := url.Values{"status": {text}}
+	if mediaID := n.uploadImage(r); mediaID != "" {
+		params.Set("media_ids", mediaID)
+	}
+
+	tweet, err := n.update(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return tweetMessage(tweet, text, r.ID), nil
+}
+
+// tweetMessage returns a message for a posted tweet.
+func tweetMessage(tweet twittergo.Tweet, text, repositoryID string) *scuttlebutt.Message {
+	id := tweet.Id()
+	return &scuttlebutt.Message{ID: id, Text: text, RepositoryID: repositoryID, URL: tweetURL(tweet, id)}
+}
 
+// update posts a status update with the given parameters.
+func (n *Notifier) update(ctx context.Context, params url.Values) (twittergo.Tweet, error) {
 	// Construct request.
-	req, err := http.NewRequest("POST", "/1.1/statuses/update.json", strings.NewReader((url.Values{"status": {text}}).Encode()))
+	req, err := http.NewRequest("POST", "/1.1/statuses/update.json", strings.NewReader(params.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("notify request: %s", err)
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// Send request.
@@ -53,7 +121,9 @@ func (n *Notifier) Notify(r *scuttlebutt.Repository) (*scuttlebutt.Message, erro
 
 	// Parse the response.
 	var tweet twittergo.Tweet
-	if err := resp.Parse(&tweet); err != nil && strings.Contains(err.Error(), "Status is over 140 characters") {
+	err = resp.Parse(&tweet)
+	n.recordRateLimit(ResourceUpdate, resp, err)
+	if err != nil && isTweetTooLongError(err) {
 		return nil, ErrTweetTooLong
 	} else if err != nil {
 		return nil, fmt.Errorf("parse: %s", err)
@@ -62,7 +132,78 @@ func (n *Notifier) Notify(r *scuttlebutt.Repository) (*scuttlebutt.Message, erro
 	// Update last tweet time cache.
 	n.lastTweetTime = tweet.CreatedAt()
 
-	return &scuttlebutt.Message{ID: tweet.Id(), Text: text, RepositoryID: r.ID}, nil
+	return tweet, nil
+}
+
+// Text returns the notification text for a repository.
+func (n *Notifier) Text(r *scuttlebutt.Repository) (string, error) {
+	u := n.URL(r)
+	text := notifyText(r.Name(), r.Description, u)
+	if tmpl := n.template(r.ID); tmpl != nil {
+		data := NewTemplateData(r)
+		data.URL = u
+
+		s, err := tmpl.Render(data)
+		if err != nil {
+			return "", err
+		}
+		text = s
+	}
+
+	if n.Hashtags != nil {
+		text = n.Hashtags.Append(text, r)
+	}
+	return text, nil
+}
+
+// template returns the template used for a repository, if any.
+func (n *Notifier) template(repositoryID string) *Template {
+	if v := ChooseVariant(n.Variants, repositoryID); v != nil {
+		return v.Template
+	}
+	return n.Template
+}
+
+// TemplateName returns the name of the template variant used for a
+// repository. Returns a blank name if the notifier has no variants.
+func (n *Notifier) TemplateName(repositoryID string) string {
+	if v := ChooseVariant(n.Variants, repositoryID); v != nil {
+		return v.Name
+	}
+	return ""
+}
+
+// URL returns the URL used in notifications for a repository.
+func (n *Notifier) URL(r *scuttlebutt.Repository) string {
+	if n.Shortener != nil {
+		if u, err := n.Shortener.Shorten(r.URL()); err == nil {
+			return u
+		}
+	}
+	return r.URL()
+}
+
+// Due returns true if enough time has passed since the last tweet to notify.
+func (n *Notifier) Due(now, lastTweetTime time.Time) bool {
+	if n.Schedule != nil {
+		if !n.Schedule.Due(now, lastTweetTime) {
+			return false
+		} else if n.Schedule.Cron != nil {
+			return true
+		}
+	}
+	return lastTweetTime.IsZero() || now.Sub(lastTweetTime) >= n.Interval
+}
+
+// SkipReason returns the reason the notifier is not due. Returns a blank
+// reason if a notification is due.
+func (n *Notifier) SkipReason(now, lastTweetTime time.Time) scuttlebutt.SkipReason {
+	if n.Due(now, lastTweetTime) {
+		return ""
+	} else if n.Schedule != nil && !n.Schedule.InWindow(now) {
+		return scuttlebutt.SkipQuietHours
+	}
+	return scuttlebutt.SkipWithinInterval
 }
 
 // LastTweetTime returns the timestamp of the last tweet.
@@ -92,7 +233,9 @@ func (n *Notifier) LastTweetTime() (time.Time, error) {
 
 	// Parse the response.
 	var tweets twittergo.Timeline
-	if err := resp.Parse(&tweets); err != nil {
+	err = resp.Parse(&tweets)
+	n.recordRateLimit(ResourceUserTimeline, resp, err)
+	if err != nil {
 		return time.Time{}, fmt.Errorf("parse: %s", err)
 	}
 
@@ -104,23 +247,120 @@ func (n *Notifier) LastTweetTime() (time.Time, error) {
 	return tweets[0].CreatedAt(), nil
 }
 
+// VerifyCredentials checks that the client is authorized as the notifier's user.
+func (n *Notifier) VerifyCredentials() error {
+	req, err := http.NewRequest("GET", "/1.1/account/verify_credentials.json", nil)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+
+	// Send request.
+	resp, err := n.Client.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse the response.
+	var user twittergo.User
+	if err := resp.Parse(&user); err != nil {
+		return fmt.Errorf("parse: %s", err)
+	}
+
+	// Ensure the credentials belong to the configured user.
+	if !strings.EqualFold(user.ScreenName(), n.Username) {
+		return fmt.Errorf("credentials belong to @%s", user.ScreenName())
+	}
+	return nil
+}
+
+// MaxNotifyTextLength is the maximum weighted length of a notification's text.
+// A small margin is left below the tweet limit.
+const MaxNotifyTextLength = MaxTweetLength - 2
+
 // NotifyText returns a tweet sized message for a repository.
 func NotifyText(r *scuttlebutt.Repository) string {
-	const maxLength = 138
-	const format = "%s - %s %s"
+	return notifyText(r.Name(), r.Description, r.URL())
+}
 
-	name, url := r.Name(), r.URL()
+// notifyText returns a tweet sized message with a shortened description.
+func notifyText(name, description, url string) string {
+	const format = "%s - %s %s"
 
 	// Calculate the remaining characters without the description.
-	remaining := maxLength - len(fmt.Sprintf(format, name, "", url))
+	remaining := MaxNotifyTextLength - TextLength(fmt.Sprintf(format, name, "", url))
+
+	return fmt.Sprintf(format, name, shortenDescription(description, remaining), url)
+}
+
+// shortenDescription truncates a description to fit within a weighted length of n.
+//
+// Truncation never splits a code point or separates a character from its
+// combining marks. If possible, the description is cut at the end of a
+// sentence, without an ellipsis, or at a word boundary.
+func shortenDescription(description string, n int) string {
+	description = strings.TrimSpace(strings.ToValidUTF8(description, ""))
+	if n < 3 {
+		return ""
+	} else if TextLength(description) <= n {
+		return description
+	}
+
+	// Find the longest prefix that fits within the limit with the ellipsis.
+	prefix := description[:graphemePrefix(description, n-3)]
 
-	// Shorten the description, if necessary.
-	var description = strings.TrimSpace(r.Description)
-	if remaining < 3 {
-		description = ""
-	} else if len(description) > remaining {
-		description = strings.TrimSpace(description[:remaining-3]) + "..."
+	// Prefer cutting at a sentence boundary if one is in the latter half.
+	if i := strings.LastIndexAny(prefix, ".!?"); i >= len(prefix)/2 && (i+1 == len(description) || unicode.IsSpace(runeAt(description, i+1))) {
+		return prefix[:i+1]
 	}
 
-	return fmt.Sprintf(format, name, description, url)
+	// Otherwise cut at a word boundary if one is in the latter half.
+	if i := strings.LastIndexFunc(prefix, unicode.IsSpace); i >= len(prefix)/2 && !unicode.IsSpace(runeAt(description, len(prefix))) {
+		prefix = prefix[:i]
+	}
+
+	return strings.TrimRightFunc(prefix, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "..."
+}
+
+// graphemePrefix returns the byte length of the longest prefix of s with a
+// weighted length of at most n that does not end inside a grapheme cluster.
+func graphemePrefix(s string, n int) int {
+	var weight, end int
+	var prev rune
+	for i, r := range s {
+		// Clusters continue over combining marks and after zero-width joiners.
+		if i == 0 || (!isGraphemeExtend(r) && prev != '\u200d') {
+			if weight > n {
+				return end
+			}
+			end = i
+		}
+		weight += runeWeight(r)
+		prev = r
+	}
+	if weight > n {
+		return end
+	}
+	return len(s)
+}
+
+// runeAt returns the rune starting at byte index i of s.
+func runeAt(s string, i int) rune {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return r
+}
+
+// isGraphemeExtend returns true if r extends the previous character.
+func isGraphemeExtend(r rune) bool {
+	return unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) ||
+		r == '\u200d' || (r >= '\ufe00' && r <= '\ufe0f')
+}
+
+// isTweetTooLongError returns true if err is Twitter's status length error.
+func isTweetTooLongError(err error) bool {
+	s := err.Error()
+	return strings.Contains(s, "Status is over 140 characters") ||
+		strings.Contains(s, "Tweet needs to be a bit shorter")
 }
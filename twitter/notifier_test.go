@@ -1,11 +1,14 @@
 package twitter_test
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/scuttlebutt"
 	"github.com/benbjohnson/scuttlebutt/twitter"
@@ -29,7 +32,7 @@ func TestNotifier_UpdateStatus(t *testing.T) {
 		case "/1.1/statuses/update.json":
 			return &twittergo.APIResponse{
 				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(strings.NewReader(`{"id_str":"123","text":"hello!","created_at": "Wed Aug 29 17:12:58 +0000 2012"}`)),
+				Body:       ioutil.NopCloser(strings.NewReader(`{"id_str":"123","text":"hello!","user":{"screen_name":"oss_go"},"created_at": "Wed Aug 29 17:12:58 +0000 2012"}`)),
 			}, nil
 		default:
 			t.Fatalf("unexpected path: %s", r.URL.Path)
@@ -38,7 +41,7 @@ func TestNotifier_UpdateStatus(t *testing.T) {
 	}
 
 	// Update account's status and check the response.
-	if m, err := n.Notify(&scuttlebutt.Repository{
+	if m, err := n.Notify(context.Background(), &scuttlebutt.Repository{
 		ID:          "github.com/benbjohnson/proj",
 		Description: "my awesome project",
 	}); err != nil {
@@ -47,11 +50,107 @@ func TestNotifier_UpdateStatus(t *testing.T) {
 		ID:           123,
 		Text:         "proj - my awesome project https://github.com/benbjohnson/proj",
 		RepositoryID: "github.com/benbjohnson/proj",
+		URL:          "https://twitter.com/oss_go/status/123",
 	}) {
 		t.Fatalf("unexpected message: %s", spew.Sdump(m))
 	}
 }
 
+// Ensure the notifier attaches an uploaded image to the status.
+func TestNotifier_Notify_Image(t *testing.T) {
+	n := NewNotifier()
+	n.Images = ImageSourceFn(func(r *scuttlebutt.Repository) ([]byte, error) {
+		return []byte("PNG"), nil
+	})
+
+	// Mock transport to accept the upload and verify the status update.
+	n.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		switch r.URL.Path {
+		case "/1.1/media/upload.json":
+			if r.URL.Host != "upload.twitter.com" {
+				t.Fatalf("unexpected host: %s", r.URL.Host)
+			} else if f, _, err := r.FormFile("media"); err != nil {
+				t.Fatal(err)
+			} else if b, _ := ioutil.ReadAll(f); string(b) != "PNG" {
+				t.Fatalf("unexpected media: %q", b)
+			}
+			return &twittergo.APIResponse{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"media_id_string":"456"}`)),
+			}, nil
+
+		case "/1.1/statuses/update.json":
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			} else if v := r.PostForm.Get("media_ids"); v != "456" {
+				t.Fatalf("unexpected media ids: %s", v)
+			}
+			return &twittergo.APIResponse{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"id_str":"123","created_at": "Wed Aug 29 17:12:58 +0000 2012"}`)),
+			}, nil
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+			return nil, nil
+		}
+	}
+
+	if m, err := n.Notify(context.Background(), &scuttlebutt.Repository{ID: "github.com/benbjohnson/proj"}); err != nil {
+		t.Fatal(err)
+	} else if m.ID != 123 {
+		t.Fatalf("unexpected id: %d", m.ID)
+	}
+}
+
+// Ensure the notifier uses shortened URLs and falls back on error.
+func TestNotifier_Text_Shortener(t *testing.T) {
+	n := twitter.NewNotifier()
+	n.Template, _ = twitter.ParseTemplate(`{{.Name}} {{.URL}}`)
+	n.Shortener = ShortenerFn(func(longURL string) (string, error) {
+		if longURL == "https://github.com/user/fail" {
+			return "", errors.New("marker")
+		}
+		return "https://sho.rt/abc", nil
+	})
+
+	if s, err := n.Text(&scuttlebutt.Repository{ID: "github.com/user/repo"}); err != nil {
+		t.Fatal(err)
+	} else if s != "repo https://sho.rt/abc" {
+		t.Fatalf("unexpected text: %s", s)
+	}
+	if s, err := n.Text(&scuttlebutt.Repository{ID: "github.com/user/fail"}); err != nil {
+		t.Fatal(err)
+	} else if s != "fail https://github.com/user/fail" {
+		t.Fatalf("unexpected text: %s", s)
+	}
+}
+
+// Ensure the notifier respects its interval and schedule.
+func TestNotifier_Due(t *testing.T) {
+	n := twitter.NewNotifier()
+	n.Interval = 4 * time.Hour
+
+	now := time.Date(2015, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !n.Due(now, time.Time{}) {
+		t.Fatal("expected due without previous tweet")
+	} else if n.Due(now, now.Add(-1*time.Hour)) {
+		t.Fatal("expected not due within interval")
+	} else if !n.Due(now, now.Add(-5*time.Hour)) {
+		t.Fatal("expected due after interval")
+	}
+
+	// Restrict to a morning window.
+	w, _ := scuttlebutt.ParseTimeWindow("08:00-11:00")
+	n.Schedule = &scuttlebutt.Schedule{Windows: []scuttlebutt.TimeWindow{w}}
+	if n.Due(now, now.Add(-5*time.Hour)) {
+		t.Fatal("expected not due outside window")
+	} else if reason := n.SkipReason(now, now.Add(-5*time.Hour)); reason != scuttlebutt.SkipQuietHours {
+		t.Fatalf("unexpected skip reason: %s", reason)
+	} else if reason := n.SkipReason(now.Add(-2*time.Hour), now.Add(-3*time.Hour)); reason != scuttlebutt.SkipWithinInterval {
+		t.Fatalf("unexpected skip reason: %s", reason)
+	}
+}
+
 // Notifier represents a test wrapper for twitter.Notifier.
 type Notifier struct {
 	*twitter.Notifier
@@ -73,3 +172,13 @@ type NotifierClient struct {
 func (c *NotifierClient) SendRequest(r *http.Request) (*twittergo.APIResponse, error) {
 	return c.SendRequestFn(r)
 }
+
+// ImageSourceFn represents a mock implementing Notifier.Images.
+type ImageSourceFn func(r *scuttlebutt.Repository) ([]byte, error)
+
+func (fn ImageSourceFn) Image(r *scuttlebutt.Repository) ([]byte, error) { return fn(r) }
+
+// ShortenerFn represents a mock implementing Notifier.Shortener.
+type ShortenerFn func(longURL string) (string, error)
+
+func (fn ShortenerFn) Shorten(longURL string) (string, error) { return fn(longURL) }
@@ -0,0 +1,202 @@
+package twitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/kurrik/twittergo"
+)
+
+const (
+	// DefaultOAuth2TokenURL is the endpoint used to refresh OAuth 2.0 tokens.
+	DefaultOAuth2TokenURL = "https://api.twitter.com/2/oauth2/token"
+
+	// OAuth2RefreshMargin is the time before expiry that an access token
+	// is refreshed.
+	OAuth2RefreshMargin = time.Minute
+)
+
+// ErrRefreshTokenRequired is returned when an OAuth 2.0 client has no stored
+// token and no initial refresh token.
+var ErrRefreshTokenRequired = errors.New("refresh token required")
+
+// OAuth2Client sends requests on behalf of an account using an OAuth 2.0
+// user-context token. Expired access tokens are refreshed automatically and,
+// since refreshing replaces the refresh token, each new token is saved to
+// the store before it is used.
+type OAuth2Client struct {
+	mu    sync.Mutex
+	token *scuttlebutt.OAuth2Token
+
+	Username string
+
+	// Application client ID & secret. The secret is only required for
+	// confidential clients.
+	ClientID     string
+	ClientSecret string
+
+	// Refresh token used if the store has no token for the account.
+	RefreshToken string
+
+	// Persistent storage for tokens. Tokens are only kept in memory if nil.
+	Store interface {
+		OAuth2Token(username string) (*scuttlebutt.OAuth2Token, error)
+		SaveOAuth2Token(username string, t *scuttlebutt.OAuth2Token) error
+	}
+
+	// API host for relative request URLs & the token refresh endpoint.
+	Host     string
+	TokenURL string
+
+	HTTPClient *http.Client
+
+	// Returns the current time. Overridable for testing.
+	Now func() time.Time
+}
+
+// NewOAuth2Client returns a new instance of OAuth2Client.
+func NewOAuth2Client() *OAuth2Client {
+	return &OAuth2Client{
+		Host:       "api.twitter.com",
+		TokenURL:   DefaultOAuth2TokenURL,
+		HTTPClient: http.DefaultClient,
+		Now:        time.Now,
+	}
+}
+
+// SendRequest authorizes req with the account's access token and sends it.
+// A rejected access token is refreshed before the next request.
+func (c *OAuth2Client) SendRequest(req *http.Request) (*twittergo.APIResponse, error) {
+	if !strings.HasPrefix(req.URL.String(), "http") {
+		u, err := url.Parse("https://" + c.Host + req.URL.String())
+		if err != nil {
+			return nil, err
+		}
+		req.URL = u
+	}
+
+	t, err := c.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+t.AccessToken)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode == http.StatusUnauthorized {
+		c.expire(t)
+	}
+	return (*twittergo.APIResponse)(resp), nil
+}
+
+// Token returns a valid token for the account, refreshing it if necessary.
+func (c *OAuth2Client) Token() (*scuttlebutt.OAuth2Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Load the stored token the first time, falling back to the initial
+	// refresh token.
+	if c.token == nil {
+		if c.Store != nil {
+			t, err := c.Store.OAuth2Token(c.Username)
+			if err != nil {
+				return nil, fmt.Errorf("load: %s", err)
+			}
+			c.token = t
+		}
+		if c.token == nil {
+			if c.RefreshToken == "" {
+				return nil, ErrRefreshTokenRequired
+			}
+			c.token = &scuttlebutt.OAuth2Token{RefreshToken: c.RefreshToken}
+		}
+	}
+
+	if !c.token.Expired(c.Now(), OAuth2RefreshMargin) {
+		return c.token, nil
+	}
+
+	t, err := c.refresh(c.token.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: %s", err)
+	} else if c.Store != nil {
+		if err := c.Store.SaveOAuth2Token(c.Username, t); err != nil {
+			return nil, fmt.Errorf("save: %s", err)
+		}
+	}
+	c.token = t
+	return t, nil
+}
+
+// expire marks t as expired so it is refreshed on the next request.
+func (c *OAuth2Client) expire(t *scuttlebutt.OAuth2Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == t {
+		other := *t
+		other.Expiry = time.Time{}
+		c.token = &other
+	}
+}
+
+// refresh exchanges a refresh token for a new access & refresh token.
+func (c *OAuth2Client) refresh(refreshToken string) (*scuttlebutt.OAuth2Token, error) {
+	params := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {c.ClientID},
+	}
+	req, err := http.NewRequest("POST", c.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.ClientSecret != "" {
+		req.SetBasicAuth(c.ClientID, c.ClientSecret)
+	}
+
+	now := c.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: code=%d, body=%s", resp.StatusCode, buf)
+	}
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(buf, &body); err != nil {
+		return nil, err
+	} else if body.AccessToken == "" {
+		return nil, errors.New("access token missing from response")
+	}
+
+	// Keep the previous refresh token if a new one was not issued.
+	t := &scuttlebutt.OAuth2Token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		Expiry:       now.Add(time.Duration(body.ExpiresIn) * time.Second).UTC(),
+	}
+	if t.RefreshToken == "" {
+		t.RefreshToken = refreshToken
+	}
+	return t, nil
+}
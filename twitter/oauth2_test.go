@@ -0,0 +1,97 @@
+package twitter_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// Ensure access tokens are refreshed when expired and saved to the store.
+func TestOAuth2Client_SendRequest(t *testing.T) {
+	var refreshes int
+	var authorizations []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/oauth2/token":
+			if id, secret, _ := r.BasicAuth(); id != "CLIENT" || secret != "SECRET" {
+				t.Fatalf("unexpected client: %s/%s", id, secret)
+			} else if s := r.FormValue("refresh_token"); s != fmt.Sprintf("REFRESH%d", refreshes) {
+				t.Fatalf("unexpected refresh token: %s", s)
+			}
+			refreshes++
+			fmt.Fprintf(w, `{"token_type":"bearer","expires_in":7200,"access_token":"ACCESS%d","refresh_token":"REFRESH%d"}`, refreshes, refreshes)
+		case "/2/tweets":
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			if r.Header.Get("Authorization") == "Bearer ACCESS2" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &TokenStore{m: make(map[string]*scuttlebutt.OAuth2Token)}
+	c := twitter.NewOAuth2Client()
+	c.Username, c.ClientID, c.ClientSecret, c.RefreshToken = "oss_go", "CLIENT", "SECRET", "REFRESH0"
+	c.Store, c.TokenURL = store, srv.URL+"/2/oauth2/token"
+	c.Now = func() time.Time { return now }
+
+	send := func() {
+		req, _ := http.NewRequest("GET", srv.URL+"/2/tweets", nil)
+		resp, err := c.SendRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The initial refresh token is exchanged and the cached token reused.
+	send()
+	send()
+	if refreshes != 1 {
+		t.Fatalf("unexpected refreshes: %d", refreshes)
+	} else if tok := store.m["oss_go"]; tok == nil || tok.RefreshToken != "REFRESH1" || !tok.Expiry.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("unexpected stored token: %#v", tok)
+	}
+
+	// Expired tokens are refreshed using the rotated refresh token and
+	// rejected tokens are refreshed before the next request.
+	now = now.Add(2 * time.Hour)
+	send()
+	send()
+	if refreshes != 3 {
+		t.Fatalf("unexpected refreshes: %d", refreshes)
+	} else if exp := []string{"Bearer ACCESS1", "Bearer ACCESS1", "Bearer ACCESS2", "Bearer ACCESS3"}; fmt.Sprint(authorizations) != fmt.Sprint(exp) {
+		t.Fatalf("unexpected authorizations: %q", authorizations)
+	}
+}
+
+// Ensure a client without a stored or initial refresh token returns an error.
+func TestOAuth2Client_Token_ErrRefreshTokenRequired(t *testing.T) {
+	c := twitter.NewOAuth2Client()
+	c.Store = &TokenStore{m: make(map[string]*scuttlebutt.OAuth2Token)}
+	if _, err := c.Token(); err != twitter.ErrRefreshTokenRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TokenStore is an in-memory store of OAuth 2.0 tokens.
+type TokenStore struct {
+	m map[string]*scuttlebutt.OAuth2Token
+}
+
+func (s *TokenStore) OAuth2Token(username string) (*scuttlebutt.OAuth2Token, error) {
+	return s.m[username], nil
+}
+
+func (s *TokenStore) SaveOAuth2Token(username string, t *scuttlebutt.OAuth2Token) error {
+	s.m[username] = t
+	return nil
+}
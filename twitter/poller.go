@@ -1,18 +1,68 @@
 package twitter
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/benbjohnson/scuttlebutt"
 	"github.com/kurrik/twittergo"
 )
 
+// stats holds Twitter throughput counters, published with expvar.
+var stats = expvar.NewMap("twitter")
+
+// SourceName is the source name used for messages polled from Twitter.
+const SourceName = "twitter"
+
+// DefaultMaxPages is the default number of search result pages read for
+// each query per poll.
+const DefaultMaxPages = 10
+
+// DefaultHosts are the hosts whose repository links are tracked by default.
+var DefaultHosts = []string{"github.com"}
+
 // Poller represents polling client for the Twitter API.
 type Poller struct {
+	malformedN uint64
+
+	// Hosts whose repository links are searched for and tracked, such as
+	// "github.com" or "gitlab.com".
+	Hosts []string
+
+	// Search queries, such as "github.com -filter:retweets". Results are
+	// merged and duplicate tweets removed. Searches for links to the hosts
+	// if no queries are specified.
+	Queries []string
+
+	// If true, retweets are skipped.
+	ExcludeRetweets bool
+
+	// If true, retweets are converted to their original tweet so a tweet
+	// and its retweets count as a single mention. Retweets are dropped once
+	// collapsed and add no weight to the original.
+	CollapseRetweets bool
+
+	// If true, quote tweets without a repository link count toward the
+	// repository linked by the quoted tweet.
+	IncludeQuotes bool
+
+	// Maximum number of search result pages read for each query per poll.
+	// Older results are dropped once the limit is reached.
+	MaxPages int
+
+	// Optional resolver applied to links before extracting repositories,
+	// such as to follow shortened links.
+	Resolver Resolver
+
 	Client interface {
 		SendRequest(*http.Request) (*twittergo.APIResponse, error)
 	}
@@ -20,80 +70,337 @@ type Poller struct {
 
 // NewPoller creates a new instance of Poller.
 func NewPoller() *Poller {
-	return &Poller{}
+	return &Poller{Hosts: DefaultHosts, MaxPages: DefaultMaxPages}
+}
+
+// Poll returns new messages since a given message ID from all queries.
+func (p *Poller) Poll(ctx context.Context, sinceID uint64) ([]*scuttlebutt.Message, error) {
+	queries := p.Queries
+	if len(queries) == 0 {
+		queries = []string{SearchQuery(p.Hosts...)}
+	}
+
+	var messages []*scuttlebutt.Message
+	seen := make(map[uint64]struct{})
+	for _, query := range queries {
+		a, err := p.search(ctx, sinceID, query)
+		if err != nil {
+			return nil, err
+		}
+
+		// Remove tweets matched by multiple queries.
+		for _, m := range a {
+			if _, ok := seen[m.ID]; ok {
+				continue
+			}
+			seen[m.ID] = struct{}{}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
 }
 
-// Poll returns new messages since a given message ID.
-func (p *Poller) Poll(sinceID uint64) ([]*scuttlebutt.Message, error) {
+// search returns new messages since a given message ID for a single query.
+// Pages are read until the since ID is reached or the page limit is hit.
+func (p *Poller) search(ctx context.Context, sinceID uint64, query string) ([]*scuttlebutt.Message, error) {
+	var messages []*scuttlebutt.Message
+	var maxID uint64
+	for i := 0; i == 0 || i < p.MaxPages; i++ {
+		a, next, err := p.searchPage(ctx, sinceID, maxID, query)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, a...)
+
+		// Stop once there are no older results.
+		if next == 0 {
+			break
+		}
+		maxID = next
+	}
+	return messages, nil
+}
+
+// searchPage returns messages for a single page of search results. Also
+// returns the max ID of the next page or zero if this is the last page.
+func (p *Poller) searchPage(ctx context.Context, sinceID, maxID uint64, query string) ([]*scuttlebutt.Message, uint64, error) {
 	// Send request.
-	resp, err := p.Client.SendRequest(NewSearchRequest(sinceID))
+	resp, err := p.Client.SendRequest(NewSearchRequest(sinceID, maxID, query).WithContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("send request: %s", err)
+		return nil, 0, fmt.Errorf("send request: %s", err)
 	}
 	defer resp.Body.Close()
 
 	// Convert to search results.
 	var res twittergo.SearchResults
 	if err := resp.Parse(&res); err != nil {
-		return nil, fmt.Errorf("twitter search results error: %s", err)
+		if e, ok := err.(twittergo.RateLimitError); ok {
+			return nil, 0, &scuttlebutt.RateLimitError{Reset: e.Reset.UTC()}
+		}
+		return nil, 0, fmt.Errorf("twitter search results error: %s", err)
 	}
 
 	// Convert search results to messages.
 	var messages []*scuttlebutt.Message
-	for _, tweet := range res.Statuses() {
-		m := encodeTweet(tweet)
+	statuses := res.Statuses()
+	stats.Add("tweets_fetched", int64(len(statuses)))
+	for _, tweet := range statuses {
+		if original, ok := tweet["retweeted_status"].(map[string]interface{}); ok {
+			if p.ExcludeRetweets {
+				continue
+			} else if p.CollapseRetweets {
+				tweet = twittergo.Tweet(original)
+			}
+		}
+
+		m, err := encodeTweet(ctx, tweet, p.Hosts, p.Resolver)
+		if err != nil {
+			atomic.AddUint64(&p.malformedN, 1)
+			stats.Add("tweets_malformed", 1)
+			continue
+		}
+
+		// Attribute quote tweets to the quoted tweet's repository.
+		if quoted, ok := tweet["quoted_status"].(map[string]interface{}); ok && p.IncludeQuotes && m.RepositoryID == "" {
+			m.RepositoryID = tweetRepositoryID(ctx, twittergo.Tweet(quoted), p.Hosts, p.Resolver)
+		}
+
 		if m.RepositoryID == "" {
 			continue
 		}
 		messages = append(messages, m)
 	}
 
-	return messages, nil
+	return messages, nextMaxID(res), nil
+}
+
+// nextMaxID returns the max ID from the search results' next page link.
+// Returns zero if there is no next page.
+func nextMaxID(res twittergo.SearchResults) uint64 {
+	metadata, _ := res["search_metadata"].(map[string]interface{})
+	next, _ := metadata["next_results"].(string)
+	q, err := url.ParseQuery(strings.TrimPrefix(next, "?"))
+	if err != nil {
+		return 0
+	}
+	maxID, _ := strconv.ParseUint(q.Get("max_id"), 10, 64)
+	return maxID
+}
+
+// VerifyCredentials checks that the client can access the search API.
+func (p *Poller) VerifyCredentials() error {
+	u := &url.URL{Path: "/1.1/application/rate_limit_status.json", RawQuery: "resources=search"}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+
+	// Send request.
+	resp, err := p.Client.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse the response to check for API errors.
+	var status map[string]interface{}
+	if err := resp.Parse(&status); err != nil {
+		return fmt.Errorf("parse: %s", err)
+	}
+	return nil
 }
 
-func encodeTweet(tweet twittergo.Tweet) *scuttlebutt.Message {
+// MalformedN returns the number of tweets skipped because they could not be decoded.
+func (p *Poller) MalformedN() uint64 { return atomic.LoadUint64(&p.malformedN) }
+
+// encodeTweet converts a tweet into a message. The repository is set from
+// the first link to a repository on one of the hosts. Links are resolved
+// first if a resolver is set.
+// Returns an error if the tweet is missing its ID or text.
+func encodeTweet(ctx context.Context, tweet twittergo.Tweet, hosts []string, resolver Resolver) (*scuttlebutt.Message, error) {
+	id, ok := tweet["id"].(int64)
+	if !ok {
+		return nil, errors.New("invalid tweet id")
+	}
+	text, ok := tweet["text"].(string)
+	if !ok {
+		return nil, errors.New("invalid tweet text")
+	}
 	m := &scuttlebutt.Message{
-		ID:   uint64(tweet["id"].(int64)),
-		Text: tweet["text"].(string),
+		ID:           uint64(id),
+		Text:         text,
+		RepositoryID: tweetRepositoryID(ctx, tweet, hosts, resolver),
+		URL:          tweetURL(tweet, uint64(id)),
+		Author:       tweetAuthor(tweet),
+		Time:         tweetTime(tweet),
 	}
+	m.AuthorFollowers, m.AuthorCreatedAt = tweetAuthorAccount(tweet)
+	m.Client = tweetClient(tweet)
+	return m, nil
+}
 
-	// Extract entities.
+// tweetRepositoryID returns the ID of the first repository linked by a tweet.
+// Links are resolved first if a resolver is set.
+func tweetRepositoryID(ctx context.Context, tweet twittergo.Tweet, hosts []string, resolver Resolver) string {
 	if entities, ok := tweet["entities"].(map[string]interface{}); ok {
 		if urls, ok := entities["urls"].([]interface{}); ok {
-		loop:
 			for _, u := range urls {
 				if u, ok := u.(map[string]interface{}); ok {
 					expandedURL, _ := u["expanded_url"].(string)
 
-					// Convert to URL.
-					u, err := url.Parse(strings.ToLower(expandedURL))
-					if err != nil {
-						continue
+					// Follow shortened links, if possible.
+					if resolver != nil {
+						if resolved, err := resolver.Resolve(ctx, expandedURL); err == nil {
+							expandedURL = resolved
+						}
 					}
 
-					// Only keep the first two parts of the path.
-					segments := strings.Split(u.Path, "/")
-					if len(segments) != 3 {
-						continue
+					if id := ExtractRepositoryID(expandedURL, hosts); id != "" {
+						return id
 					}
-
-					m.RepositoryID = "github.com/" + segments[1] + "/" + segments[2]
-					break loop
 				}
 			}
 		}
 	}
+	return ""
+}
+
+// ExtractRepositoryID returns the repository ID for a link to a repository
+// on one of the hosts. Deep links, such as to a file, issue, or release, are
+// mapped to their owning repository. Raw file links on
+// raw.githubusercontent.com are mapped to github.com if it is a host.
+// Returns a blank ID if the link is not to a repository.
+func ExtractRepositoryID(rawurl string, hosts []string) string {
+	// Convert to URL. The path keeps its case as repository names on some
+	// hosts are case sensitive.
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+
+	// Map raw file links to their repository host.
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	if host == "raw.githubusercontent.com" {
+		host = "github.com"
+	}
 
-	return m
+	// Only track links to known hosts.
+	if !hasHost(hosts, host) {
+		return ""
+	}
+
+	// Only keep the first two parts of the path.
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	owner, name := segments[0], strings.TrimSuffix(segments[1], ".git")
+	if owner == "" || name == "" || reservedOwners[strings.ToLower(owner)] {
+		return ""
+	}
+	return host + "/" + owner + "/" + name
+}
+
+// reservedOwners are top-level paths that are site pages instead of owners.
+var reservedOwners = map[string]bool{
+	"about": true, "apps": true, "collections": true, "enterprise": true,
+	"events": true, "explore": true, "features": true, "issues": true,
+	"login": true, "marketplace": true, "notifications": true, "orgs": true,
+	"pricing": true, "pulls": true, "search": true, "settings": true,
+	"site": true, "sponsors": true, "topics": true, "trending": true,
+	"users": true,
+}
+
+// StatusURL returns the canonical link to a tweet. Twitter redirects to the
+// author's link if the screen name is unknown.
+func StatusURL(screenName string, id uint64) string {
+	if screenName == "" {
+		return "https://twitter.com/i/web/status/" + strconv.FormatUint(id, 10)
+	}
+	return "https://twitter.com/" + screenName + "/status/" + strconv.FormatUint(id, 10)
 }
 
-// NewSearchRequest returns a new HTTP request.
-func NewSearchRequest(sinceID uint64) *http.Request {
+// tweetURL returns the canonical link to a tweet from its author & ID.
+func tweetURL(tweet twittergo.Tweet, id uint64) string {
+	return StatusURL(tweetAuthor(tweet), id)
+}
+
+// tweetAuthor returns the screen name of the tweet's author, if available.
+func tweetAuthor(tweet twittergo.Tweet) string {
+	if user, ok := tweet["user"].(map[string]interface{}); ok {
+		screenName, _ := user["screen_name"].(string)
+		return screenName
+	}
+	return ""
+}
+
+// tweetAuthorAccount returns the follower count & creation time of the
+// author's account. Returns a zero time if the account is unknown.
+func tweetAuthorAccount(tweet twittergo.Tweet) (followers int, createdAt time.Time) {
+	user, ok := tweet["user"].(map[string]interface{})
+	if !ok {
+		return 0, time.Time{}
+	}
+	s, _ := user["created_at"].(string)
+	t, err := time.Parse(time.RubyDate, s)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	n, _ := user["followers_count"].(int64)
+	return int(n), t.UTC()
+}
+
+// tweetClient returns the name of the app a tweet was posted with. The
+// source is reported as a link so only its text is returned.
+func tweetClient(tweet twittergo.Tweet) string {
+	s, _ := tweet["source"].(string)
+	if i := strings.Index(s, ">"); strings.HasPrefix(s, "<a ") && i != -1 {
+		s = strings.TrimSuffix(s[i+1:], "</a>")
+	}
+	return html.UnescapeString(s)
+}
+
+// tweetTime returns the time the tweet was posted. Returns a zero time if the
+// tweet's creation time is missing or malformed.
+func tweetTime(tweet twittergo.Tweet) time.Time {
+	s, _ := tweet["created_at"].(string)
+	t, err := time.Parse(time.RubyDate, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// hasHost returns true if host is in hosts.
+func hasHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery returns a query searching for links to hosts.
+// Searches for links to DefaultHosts if none are specified.
+func SearchQuery(hosts ...string) string {
+	if len(hosts) == 0 {
+		hosts = DefaultHosts
+	}
+	return strings.Join(hosts, " OR ")
+}
+
+// NewSearchRequest returns a new HTTP request searching for tweets. Results
+// are limited to tweets up to maxID, if specified.
+func NewSearchRequest(sinceID, maxID uint64, query string) *http.Request {
 	// Build query string.
-	q := url.Values{"q": {"github.com"}}
+	q := url.Values{"q": {query}}
 	if sinceID > 0 {
 		q.Set("since_id", strconv.FormatUint(sinceID, 10))
 	}
+	if maxID > 0 {
+		q.Set("max_id", strconv.FormatUint(maxID, 10))
+	}
 
 	// Build URL object.
 	u := &url.URL{Path: "/1.1/search/tweets.json", RawQuery: q.Encode()}
@@ -1,14 +1,17 @@
 package twitter_test
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/benbjohnson/scuttlebutt/twitter"
 	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kurrik/twittergo"
 )
@@ -21,20 +24,250 @@ func TestPoller_Poll(t *testing.T) {
 	p.Client.SendRequestFn = func(*http.Request) (*twittergo.APIResponse, error) {
 		return &twittergo.APIResponse{
 			StatusCode: http.StatusOK,
-			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"id":123,"text":"hello!","entities":{"urls":[{"expanded_url":"https://github.com/benbjohnson/proj"}]}}]}`)),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"id":123,"text":"hello!","created_at":"Sat Jan 01 00:00:00 +0000 2000","source":"<a href=\"https://mobile.twitter.com\" rel=\"nofollow\">Twitter Web App</a>","user":{"screen_name":"benbjohnson","followers_count":42,"created_at":"Mon Jan 01 00:00:00 +0000 1996"},"entities":{"urls":[{"expanded_url":"https://github.com/benbjohnson/proj"}]}}]}`)),
 		}, nil
 	}
 
 	// Search for statuses and check the response.
-	if messages, err := p.Poll(0); err != nil {
+	if messages, err := p.Poll(context.Background(), 0); err != nil {
 		t.Fatal(err)
 	} else if !reflect.DeepEqual(messages, []*scuttlebutt.Message{
-		{ID: 123, Text: "hello!", RepositoryID: "github.com/benbjohnson/proj"},
+		{ID: 123, Text: "hello!", RepositoryID: "github.com/benbjohnson/proj", URL: "https://twitter.com/benbjohnson/status/123", Author: "benbjohnson", Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			AuthorFollowers: 42, AuthorCreatedAt: time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC), Client: "Twitter Web App"},
 	}) {
 		t.Fatalf("unexpected statues: %s", spew.Sdump(messages))
 	}
 }
 
+// Ensure the poller only tracks links to its hosts.
+func TestPoller_Poll_Hosts(t *testing.T) {
+	p := NewPoller()
+	p.Hosts = []string{"github.com", "gitlab.com"}
+
+	p.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		if q := r.URL.Query().Get("q"); q != "github.com OR gitlab.com" {
+			t.Fatalf("unexpected query: %s", q)
+		}
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"id":1,"text":"a","entities":{"urls":[{"expanded_url":"https://example.com/user/proj"},{"expanded_url":"https://gitlab.com/user/proj"}]}},{"id":2,"text":"b","entities":{"urls":[{"expanded_url":"https://bitbucket.org/user/proj"}]}}]}`)),
+		}, nil
+	}
+
+	if messages, err := p.Poll(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if len(messages) != 1 || messages[0].RepositoryID != "gitlab.com/user/proj" {
+		t.Fatalf("unexpected statuses: %s", spew.Sdump(messages))
+	}
+}
+
+// Ensure the poller reads search result pages until there are no more.
+func TestPoller_Poll_Pagination(t *testing.T) {
+	p := NewPoller()
+
+	var maxIDs []string
+	p.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		if sinceID := r.URL.Query().Get("since_id"); sinceID != "10" {
+			t.Fatalf("unexpected since id: %s", sinceID)
+		}
+		maxID := r.URL.Query().Get("max_id")
+		maxIDs = append(maxIDs, maxID)
+
+		body := `{"statuses":[{"id":30,"text":"a","entities":{"urls":[{"expanded_url":"https://github.com/user/a"}]}}],"search_metadata":{"next_results":"?max_id=29&q=github.com"}}`
+		if maxID == "29" {
+			body = `{"statuses":[{"id":20,"text":"b","entities":{"urls":[{"expanded_url":"https://github.com/user/b"}]}}],"search_metadata":{}}`
+		}
+		return &twittergo.APIResponse{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	if messages, err := p.Poll(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(maxIDs, []string{"", "29"}) {
+		t.Fatalf("unexpected max ids: %v", maxIDs)
+	} else if len(messages) != 2 || messages[0].ID != 30 || messages[1].ID != 20 {
+		t.Fatalf("unexpected statuses: %s", spew.Sdump(messages))
+	}
+}
+
+// Ensure the poller stops reading pages at the page limit.
+func TestPoller_Poll_MaxPages(t *testing.T) {
+	p := NewPoller()
+	p.MaxPages = 3
+
+	var n int
+	p.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		n++
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[],"search_metadata":{"next_results":"?max_id=100"}}`)),
+		}, nil
+	}
+
+	if _, err := p.Poll(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("unexpected request count: %d", n)
+	}
+}
+
+// Ensure the poller returns the reset time when rate limited.
+func TestPoller_Poll_RateLimited(t *testing.T) {
+	p := NewPoller()
+	p.Client.SendRequestFn = func(*http.Request) (*twittergo.APIResponse, error) {
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"X-Rate-Limit-Reset": {"1500000000"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+		}, nil
+	}
+
+	if _, err := p.Poll(context.Background(), 0); !reflect.DeepEqual(err, &scuttlebutt.RateLimitError{Reset: time.Unix(1500000000, 0).UTC()}) {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
+
+// Ensure the poller merges results from multiple queries.
+func TestPoller_Poll_Queries(t *testing.T) {
+	p := NewPoller()
+	p.Queries = []string{"github.com -filter:retweets", "github.com lang:ja"}
+
+	var queries []string
+	p.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		queries = append(queries, r.URL.Query().Get("q"))
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"id":` + strconv.Itoa(len(queries)) + `,"text":"a","entities":{"urls":[{"expanded_url":"https://github.com/user/a"}]}},{"id":100,"text":"b","entities":{"urls":[{"expanded_url":"https://github.com/user/b"}]}}]}`)),
+		}, nil
+	}
+
+	if messages, err := p.Poll(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(queries, p.Queries) {
+		t.Fatalf("unexpected queries: %v", queries)
+	} else if len(messages) != 3 || messages[0].ID != 1 || messages[1].ID != 100 || messages[2].ID != 2 {
+		t.Fatalf("unexpected statuses: %s", spew.Sdump(messages))
+	}
+}
+
+// Ensure the poller applies its retweet & quote tweet options.
+func TestPoller_Poll_Retweets(t *testing.T) {
+	const body = `{"statuses":[` +
+		`{"id":1,"text":"orig","user":{"screen_name":"a"},"entities":{"urls":[{"expanded_url":"https://github.com/user/proj"}]}},` +
+		`{"id":2,"text":"RT @a: orig","user":{"screen_name":"b"},"entities":{"urls":[{"expanded_url":"https://github.com/user/proj"}]},"retweeted_status":{"id":1,"text":"orig","user":{"screen_name":"a"},"entities":{"urls":[{"expanded_url":"https://github.com/user/proj"}]}}},` +
+		`{"id":3,"text":"RT @a: orig","user":{"screen_name":"c"},"entities":{"urls":[{"expanded_url":"https://github.com/user/proj"}]},"retweeted_status":{"id":1,"text":"orig","user":{"screen_name":"a"},"entities":{"urls":[{"expanded_url":"https://github.com/user/proj"}]}}},` +
+		`{"id":4,"text":"so good","user":{"screen_name":"d"},"quoted_status":{"id":5,"text":"x","entities":{"urls":[{"expanded_url":"https://github.com/user/other"}]}}}` +
+		`]}`
+
+	for _, tt := range []struct {
+		name    string
+		setup   func(p *Poller)
+		ids     []uint64
+		repoIDs []string
+	}{
+		{"Default", func(p *Poller) {}, []uint64{1, 2, 3}, nil},
+		{"ExcludeRetweets", func(p *Poller) { p.ExcludeRetweets = true }, []uint64{1}, nil},
+		{"CollapseRetweets", func(p *Poller) { p.CollapseRetweets = true }, []uint64{1}, nil},
+		{"IncludeQuotes", func(p *Poller) { p.IncludeQuotes = true }, []uint64{1, 2, 3, 4}, []string{"github.com/user/proj", "github.com/user/proj", "github.com/user/proj", "github.com/user/other"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPoller()
+			tt.setup(p)
+			p.Client.SendRequestFn = func(*http.Request) (*twittergo.APIResponse, error) {
+				return &twittergo.APIResponse{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+			}
+
+			messages, err := p.Poll(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []uint64
+			var repoIDs []string
+			for _, m := range messages {
+				ids = append(ids, m.ID)
+				repoIDs = append(repoIDs, m.RepositoryID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Fatalf("unexpected ids: %v", ids)
+			} else if tt.repoIDs != nil && !reflect.DeepEqual(repoIDs, tt.repoIDs) {
+				t.Fatalf("unexpected repository ids: %v", repoIDs)
+			}
+		})
+	}
+}
+
+// Ensure the poller resolves links before extracting repositories.
+func TestPoller_Poll_Resolver(t *testing.T) {
+	p := NewPoller()
+	p.Poller.Resolver = ResolverFunc(func(u string) (string, error) {
+		if u != "https://bit.ly/abc" {
+			t.Fatalf("unexpected url: %s", u)
+		}
+		return "https://github.com/benbjohnson/proj", nil
+	})
+
+	p.Client.SendRequestFn = func(*http.Request) (*twittergo.APIResponse, error) {
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"id":1,"text":"a","entities":{"urls":[{"expanded_url":"https://bit.ly/abc"}]}}]}`)),
+		}, nil
+	}
+
+	if messages, err := p.Poll(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if len(messages) != 1 || messages[0].RepositoryID != "github.com/benbjohnson/proj" {
+		t.Fatalf("unexpected statuses: %s", spew.Sdump(messages))
+	}
+}
+
+// Ensure the poller skips malformed tweets instead of failing.
+func TestPoller_Poll_Malformed(t *testing.T) {
+	p := NewPoller()
+
+	// Mock transport to return a tweet without an ID.
+	p.Client.SendRequestFn = func(*http.Request) (*twittergo.APIResponse, error) {
+		return &twittergo.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"statuses":[{"text":"bad"},{"id":123,"text":"hello!","entities":{"urls":[{"expanded_url":"https://github.com/benbjohnson/proj"}]}}]}`)),
+		}, nil
+	}
+
+	// Search for statuses and check the response.
+	if messages, err := p.Poll(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if len(messages) != 1 || messages[0].ID != 123 {
+		t.Fatalf("unexpected statues: %s", spew.Sdump(messages))
+	} else if n := p.MalformedN(); n != 1 {
+		t.Fatalf("unexpected malformed count: %d", n)
+	}
+}
+
+// Ensure deep links are mapped to their owning repository.
+func TestExtractRepositoryID(t *testing.T) {
+	hosts := []string{"github.com"}
+	for _, tt := range []struct {
+		url string
+		id  string
+	}{
+		{"https://github.com/user/proj", "github.com/user/proj"},
+		{"https://www.GitHub.com/BurntSushi/toml/", "github.com/BurntSushi/toml"},
+		{"https://github.com/user/proj.git", "github.com/user/proj"},
+		{"https://github.com/user/proj/blob/master/README.md", "github.com/user/proj"},
+		{"https://github.com/user/proj/issues/123", "github.com/user/proj"},
+		{"https://github.com/user/proj/releases/tag/v1", "github.com/user/proj"},
+		{"https://github.com/user/proj#readme", "github.com/user/proj"},
+		{"https://raw.githubusercontent.com/user/proj/master/install.sh", "github.com/user/proj"},
+		{"https://gist.github.com/user/0123456789abcdef", ""},
+		{"https://github.com/user", ""},
+		{"https://github.com/topics/go", ""},
+		{"https://github.com/orgs/org/people", ""},
+		{"https://example.com/user/proj", ""},
+	} {
+		if id := twitter.ExtractRepositoryID(tt.url, hosts); id != tt.id {
+			t.Errorf("%s: unexpected id: %q", tt.url, id)
+		}
+	}
+}
+
 // Poller represents a test wrapper for twitter.Poller.
 type Poller struct {
 	*twitter.Poller
@@ -56,3 +289,8 @@ type PollerClient struct {
 func (c *PollerClient) SendRequest(r *http.Request) (*twittergo.APIResponse, error) {
 	return c.SendRequestFn(r)
 }
+
+// ResolverFunc implements twitter.Resolver with a function.
+type ResolverFunc func(string) (string, error)
+
+func (fn ResolverFunc) Resolve(ctx context.Context, u string) (string, error) { return fn(u) }
@@ -0,0 +1,71 @@
+package twitter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/kurrik/twittergo"
+)
+
+// Resources tracked for rate limiting.
+const (
+	ResourceUpdate       = "statuses/update"
+	ResourceUserTimeline = "statuses/user_timeline"
+	ResourceTweetLookup  = "tweets/lookup"
+)
+
+// DefaultRateLimitWindow is the time an account is throttled for when Twitter
+// rejects a request without specifying when the quota resets.
+const DefaultRateLimitWindow = 15 * time.Minute
+
+// RateLimits returns the last known quotas for the account, ordered by resource.
+func (n *Notifier) RateLimits() []*scuttlebutt.RateLimit {
+	a := make([]*scuttlebutt.RateLimit, 0, len(n.rateLimits))
+	for _, rl := range n.rateLimits {
+		other := *rl
+		a = append(a, &other)
+	}
+	sort.Sort(rateLimitsByResource(a))
+	return a
+}
+
+// SetRateLimits replaces the known quotas for the account.
+func (n *Notifier) SetRateLimits(a []*scuttlebutt.RateLimit) {
+	n.rateLimits = make(map[string]*scuttlebutt.RateLimit, len(a))
+	for _, rl := range a {
+		other := *rl
+		n.rateLimits[rl.Resource] = &other
+	}
+}
+
+// recordRateLimit updates the quota for a resource from a rate limit error
+// or, otherwise, from the response headers. Responses without rate limit
+// headers are ignored.
+func (n *Notifier) recordRateLimit(resource string, resp *twittergo.APIResponse, err error) {
+	rl := &scuttlebutt.RateLimit{Resource: resource}
+	if e, ok := err.(twittergo.RateLimitError); ok {
+		rl.Limit, rl.Reset = int(e.Limit), e.Reset.UTC()
+		if now := time.Now().UTC(); !rl.Reset.After(now) {
+			rl.Reset = now.Add(DefaultRateLimitWindow)
+		}
+	} else if resp.HasRateLimit() {
+		rl.Limit = int(resp.RateLimit())
+		rl.Remaining = int(resp.RateLimitRemaining())
+		rl.Reset = resp.RateLimitReset().UTC()
+	} else {
+		return
+	}
+
+	if n.rateLimits == nil {
+		n.rateLimits = make(map[string]*scuttlebutt.RateLimit)
+	}
+	n.rateLimits[resource] = rl
+}
+
+// rateLimitsByResource sorts rate limits by resource name.
+type rateLimitsByResource []*scuttlebutt.RateLimit
+
+func (p rateLimitsByResource) Len() int           { return len(p) }
+func (p rateLimitsByResource) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p rateLimitsByResource) Less(i, j int) bool { return p[i].Resource < p[j].Resource }
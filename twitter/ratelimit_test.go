@@ -0,0 +1,71 @@
+package twitter_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/kurrik/twittergo"
+)
+
+// Ensure the notifier records quotas from response headers and rate limit errors.
+func TestNotifier_RateLimits(t *testing.T) {
+	n := NewNotifier()
+	n.Username = "oss_go"
+
+	reset := time.Now().Add(10 * time.Minute).Truncate(time.Second).UTC()
+	n.Client.SendRequestFn = func(r *http.Request) (*twittergo.APIResponse, error) {
+		switch r.URL.Path {
+		case "/1.1/statuses/user_timeline.json":
+			return &twittergo.APIResponse{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"X-Rate-Limit-Limit":     {"900"},
+					"X-Rate-Limit-Remaining": {"899"},
+					"X-Rate-Limit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+				},
+				Body: ioutil.NopCloser(strings.NewReader(`[]`)),
+			}, nil
+		case "/1.1/statuses/update.json":
+			return &twittergo.APIResponse{
+				StatusCode: 429,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+			}, nil
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+			return nil, nil
+		}
+	}
+
+	if _, err := n.LastTweetTime(); err != nil {
+		t.Fatal(err)
+	} else if _, err := n.Notify(context.Background(), &scuttlebutt.Repository{ID: "github.com/user/repo"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// The timeline quota is available but updates are throttled.
+	now := time.Now()
+	a := n.RateLimits()
+	if len(a) != 2 {
+		t.Fatalf("unexpected rate limits: %s", spew.Sdump(a))
+	} else if a[0].Resource != twitter.ResourceUpdate || !a[0].Throttled(now) || a[0].Reset.Before(now.Add(twitter.DefaultRateLimitWindow-time.Minute)) {
+		t.Fatalf("unexpected update rate limit: %s", spew.Sdump(a[0]))
+	} else if a[1].Resource != twitter.ResourceUserTimeline || a[1].Throttled(now) || a[1].Limit != 900 || a[1].Remaining != 899 || !a[1].Reset.Equal(reset) {
+		t.Fatalf("unexpected timeline rate limit: %s", spew.Sdump(a[1]))
+	}
+
+	// Quotas can be restored.
+	other := twitter.NewNotifier()
+	other.SetRateLimits(a)
+	if b := other.RateLimits(); len(b) != 2 || !b[0].Throttled(now) {
+		t.Fatalf("unexpected restored rate limits: %s", spew.Sdump(b))
+	}
+}
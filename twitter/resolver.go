@@ -0,0 +1,134 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultMaxRedirects is the default number of redirects followed when
+	// resolving a link.
+	DefaultMaxRedirects = 5
+
+	// DefaultResolverCacheSize is the default number of resolved links cached.
+	DefaultResolverCacheSize = 10000
+)
+
+// DefaultShortenerHosts are the link shortening hosts resolved by default.
+var DefaultShortenerHosts = []string{
+	"bit.ly", "buff.ly", "dlvr.it", "git.io", "goo.gl", "ift.tt",
+	"is.gd", "lnkd.in", "ow.ly", "t.co", "tinyurl.com", "trib.al",
+}
+
+// Resolver represents a service for resolving links to their destination.
+type Resolver interface {
+	Resolve(ctx context.Context, rawurl string) (string, error)
+}
+
+// URLResolver resolves shortened links by following redirects with HEAD
+// requests. Only links on shortener hosts are resolved. Resolved links and
+// client errors (4xx) are cached. Transient failures are retried next time.
+type URLResolver struct {
+	mu    sync.Mutex
+	cache map[string]string
+
+	// Hosts whose links are resolved. Other links are returned unchanged.
+	Hosts []string
+
+	// Maximum number of redirects followed per link.
+	MaxRedirects int
+
+	// Maximum number of cached links. The cache is cleared once full.
+	CacheSize int
+
+	// Client used for requests. Redirects are followed by the resolver.
+	Client *http.Client
+}
+
+// NewURLResolver returns a new instance of URLResolver with default settings.
+func NewURLResolver() *URLResolver {
+	return &URLResolver{
+		Hosts:        DefaultShortenerHosts,
+		MaxRedirects: DefaultMaxRedirects,
+		CacheSize:    DefaultResolverCacheSize,
+		Client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Resolve returns the destination of a shortened link. Returns the last link
+// reached if a request fails or the redirect limit is reached. Returns an
+// error if ctx is canceled.
+func (r *URLResolver) Resolve(ctx context.Context, rawurl string) (string, error) {
+	if !r.shortened(rawurl) {
+		return rawurl, nil
+	}
+
+	// Return cached link, if available.
+	r.mu.Lock()
+	resolved, ok := r.cache[rawurl]
+	r.mu.Unlock()
+	if ok {
+		return resolved, nil
+	}
+
+	resolved, final := r.resolve(ctx, rawurl)
+	if err := ctx.Err(); err != nil {
+		return rawurl, err
+	} else if !final {
+		return resolved, nil
+	}
+
+	// Add to cache. Clear the cache once it reaches its size limit.
+	r.mu.Lock()
+	if r.cache == nil || len(r.cache) >= r.CacheSize {
+		r.cache = make(map[string]string)
+	}
+	r.cache[rawurl] = resolved
+	r.mu.Unlock()
+
+	return resolved, nil
+}
+
+// resolve follows redirects from rawurl. Also returns false if resolution
+// stopped on a transient failure, such as a network error or a 5xx response.
+func (r *URLResolver) resolve(ctx context.Context, rawurl string) (string, bool) {
+	for i := 0; i < r.MaxRedirects && r.shortened(rawurl); i++ {
+		req, err := http.NewRequest("HEAD", rawurl, nil)
+		if err != nil {
+			return rawurl, true
+		}
+		resp, err := r.Client.Do(req.WithContext(ctx))
+		if err != nil {
+			return rawurl, false
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return rawurl, false
+		}
+
+		// Stop once the destination is reached.
+		loc, err := resp.Location()
+		if err != nil {
+			break
+		}
+		rawurl = loc.String()
+	}
+	return rawurl, true
+}
+
+// shortened returns true if rawurl is on a shortener host.
+func (r *URLResolver) shortened(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	return hasHost(r.Hosts, strings.TrimPrefix(u.Host, "www."))
+}
@@ -0,0 +1,144 @@
+package twitter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// Ensure the resolver follows redirects and caches the destination.
+func TestURLResolver_Resolve(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if r.Method != "HEAD" {
+			t.Fatalf("unexpected method: %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "https://github.com/user/proj", http.StatusFound)
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer s.Close()
+
+	r := NewURLResolver(s.URL)
+	for i := 0; i < 2; i++ {
+		if u, err := r.Resolve(context.Background(), s.URL+"/a"); err != nil {
+			t.Fatal(err)
+		} else if u != "https://github.com/user/proj" {
+			t.Fatalf("unexpected url: %s", u)
+		}
+	}
+	if n != 2 {
+		t.Fatalf("unexpected request count: %d", n)
+	}
+}
+
+// Ensure the resolver stops following redirects at the limit.
+func TestURLResolver_Resolve_MaxRedirects(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+	defer s.Close()
+
+	r := NewURLResolver(s.URL)
+	r.MaxRedirects = 3
+	if u, err := r.Resolve(context.Background(), s.URL+"/"); err != nil {
+		t.Fatal(err)
+	} else if u != s.URL+"/xxx" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
+
+// Ensure transient failures are not cached so the link is retried.
+func TestURLResolver_Resolve_ServerError(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n++; n == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, "https://github.com/user/proj", http.StatusFound)
+	}))
+	defer s.Close()
+
+	r := NewURLResolver(s.URL)
+	if u, err := r.Resolve(context.Background(), s.URL+"/a"); err != nil {
+		t.Fatal(err)
+	} else if u != s.URL+"/a" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+	if u, err := r.Resolve(context.Background(), s.URL+"/a"); err != nil {
+		t.Fatal(err)
+	} else if u != "https://github.com/user/proj" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected request count: %d", n)
+	}
+}
+
+// Ensure client errors are cached as the final destination.
+func TestURLResolver_Resolve_NotFound(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		http.NotFound(w, r)
+	}))
+	defer s.Close()
+
+	r := NewURLResolver(s.URL)
+	for i := 0; i < 2; i++ {
+		if u, err := r.Resolve(context.Background(), s.URL+"/a"); err != nil {
+			t.Fatal(err)
+		} else if u != s.URL+"/a" {
+			t.Fatalf("unexpected url: %s", u)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("unexpected request count: %d", n)
+	}
+}
+
+// Ensure resolution stops once the context is canceled.
+func TestURLResolver_Resolve_Canceled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("unexpected request")
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := NewURLResolver(s.URL)
+	if _, err := r.Resolve(ctx, s.URL+"/a"); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure links to other hosts are returned unchanged.
+func TestURLResolver_Resolve_OtherHost(t *testing.T) {
+	r := twitter.NewURLResolver()
+	if u, err := r.Resolve(context.Background(), "https://github.com/user/proj"); err != nil {
+		t.Fatal(err)
+	} else if u != "https://github.com/user/proj" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
+
+// NewURLResolver returns a resolver that treats the test server as a shortener.
+func NewURLResolver(rawurl string) *twitter.URLResolver {
+	u, _ := url.Parse(rawurl)
+	r := twitter.NewURLResolver()
+	r.Hosts = []string{strings.TrimPrefix(u.Host, "www.")}
+	return r
+}
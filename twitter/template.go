@@ -0,0 +1,211 @@
+package twitter
+
+import (
+	"bytes"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+)
+
+// ErrTemplateTooLong is returned when a template exceeds the maximum length
+// even without a description.
+var ErrTemplateTooLong = errors.New("template too long")
+
+// Template represents a text template for notifications.
+//
+// Templates are executed against TemplateData, for example:
+//
+//	{{.Name}} - {{.Description}} {{.URL}} {{.Stars}}⭐ #{{.Language}}
+//
+// The "short" function abbreviates large counts, for example:
+//
+//	⭐ {{short .Stars}}, {{.MentionsToday}} mentions today
+//
+// The description is shortened so the text fits within MaxNotifyTextLength.
+type Template struct {
+	tmpl *template.Template
+}
+
+// ParseTemplate parses and validates a notification template.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("notify").Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{tmpl: tmpl}
+
+	// Execute against a sample repository to catch invalid fields.
+	if _, err := t.execute(&TemplateData{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Text returns the notification text for a repository.
+func (t *Template) Text(r *scuttlebutt.Repository) (string, error) {
+	return t.Render(NewTemplateData(r))
+}
+
+// Render returns the notification text for template data.
+// The description is shortened to fit, if necessary.
+func (t *Template) Render(data *TemplateData) (string, error) {
+	other := *data
+	data = &other
+
+	// Calculate the remaining characters without the description.
+	description := data.Description
+	data.Description = ""
+	s, err := t.execute(data)
+	if err != nil {
+		return "", err
+	}
+	remaining := MaxNotifyTextLength - TextLength(s)
+	if remaining < 0 {
+		return "", ErrTemplateTooLong
+	}
+
+	// Shorten the description, if necessary, and execute again.
+	data.Description = shortenDescription(description, remaining)
+	return t.execute(data)
+}
+
+// execute executes the template against data.
+func (t *Template) execute(data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// TemplateVariant represents a named, weighted alternative template used to
+// compare wording between notifications.
+type TemplateVariant struct {
+	Name     string
+	Weight   int
+	Template *Template
+}
+
+// ChooseVariant returns the variant used for a repository. Variants are
+// chosen in proportion to their weights by hashing the repository ID so the
+// same text is rendered each time a repository is considered. Returns nil if
+// there are no variants with a positive weight.
+func ChooseVariant(a []*TemplateVariant, repositoryID string) *TemplateVariant {
+	var total uint32
+	for _, v := range a {
+		if v.Weight > 0 {
+			total += uint32(v.Weight)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(repositoryID))
+	n := h.Sum32() % total
+	for _, v := range a {
+		if v.Weight <= 0 {
+			continue
+		} else if n < uint32(v.Weight) {
+			return v
+		}
+		n -= uint32(v.Weight)
+	}
+	return nil
+}
+
+// templateFuncs are the functions available to notification templates.
+var templateFuncs = template.FuncMap{
+	"short": ShortCount,
+}
+
+// ShortCount abbreviates a count using k & m suffixes (e.g. 1.2k).
+func ShortCount(n int) string {
+	switch {
+	case n < 1000:
+		return strconv.Itoa(n)
+	case n < 1000000:
+		return shortCount(n, 1000) + "k"
+	default:
+		return shortCount(n, 1000000) + "m"
+	}
+}
+
+// shortCount returns n divided by unit with at most one decimal place.
+func shortCount(n, unit int) string {
+	if n >= 100*unit {
+		return strconv.Itoa(n / unit)
+	}
+	s := strconv.FormatFloat(float64(n/(unit/10))/10, 'f', 1, 64)
+	if s[len(s)-2:] == ".0" {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// TemplateData represents the fields available to a notification template.
+type TemplateData struct {
+	ID          string
+	Name        string
+	URL         string
+	Description string
+	Language    string
+	Stars       int
+	Forks       int
+
+	// Total mentions and mentions within the last 24 hours.
+	Mentions      int
+	MentionsToday int
+}
+
+// NewTemplateData returns template data for a repository.
+func NewTemplateData(r *scuttlebutt.Repository) *TemplateData {
+	return &TemplateData{
+		ID:          r.ID,
+		Name:        r.Name(),
+		URL:         r.URL(),
+		Description: r.Description,
+		Language:    r.Language,
+		Stars:       r.Stars,
+		Forks:       r.Forks,
+		Mentions:    len(r.Messages),
+
+		MentionsToday: mentionsSince(r, time.Now().Add(-24*time.Hour)),
+	}
+}
+
+// mentionsSince returns the number of messages for r posted after t.
+func mentionsSince(r *scuttlebutt.Repository, t time.Time) int {
+	var n int
+	for _, m := range r.Messages {
+		if messageTime(m).After(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// messageTime returns the time m was posted. Falls back to the time encoded
+// in the ID for tweets saved without a time. Returns a zero time otherwise.
+func messageTime(m *scuttlebutt.Message) time.Time {
+	if !m.Time.IsZero() {
+		return m.Time
+	} else if m.Source == "" || m.Source == SourceName {
+		return TweetTime(m.ID)
+	}
+	return time.Time{}
+}
+
+// twitterEpoch is the Twitter snowflake epoch, in milliseconds.
+const twitterEpoch = 1288834974657
+
+// TweetTime returns the creation time encoded in a tweet's snowflake ID.
+func TweetTime(id uint64) time.Time {
+	ms := int64(id>>22) + twitterEpoch
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
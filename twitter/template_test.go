@@ -0,0 +1,135 @@
+package twitter_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt"
+	"github.com/benbjohnson/scuttlebutt/twitter"
+)
+
+// Ensure a template can generate notification text.
+func TestTemplate_Text(t *testing.T) {
+	tmpl, err := twitter.ParseTemplate(`{{.Name}}: {{.Description}} {{.URL}} {{.Stars}}⭐ #{{.Language}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := tmpl.Text(&scuttlebutt.Repository{
+		ID:          "github.com/benbjohnson/proj",
+		Description: "my awesome project",
+		Language:    "go",
+		Stars:       10,
+	}); err != nil {
+		t.Fatal(err)
+	} else if s != "proj: my awesome project https://github.com/benbjohnson/proj 10⭐ #go" {
+		t.Fatalf("unexpected text: %s", s)
+	}
+}
+
+// Ensure a template can include abbreviated counts and recent mentions.
+func TestTemplate_Text_Counts(t *testing.T) {
+	tmpl, err := twitter.ParseTemplate(`{{.Name}} ⭐ {{short .Stars}}, {{.Forks}} forks, {{.MentionsToday}} mentions today`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generate a snowflake ID for a given time.
+	snowflake := func(t time.Time) uint64 {
+		return uint64(t.UnixNano()/int64(time.Millisecond)-1288834974657) << 22
+	}
+
+	if s, err := tmpl.Text(&scuttlebutt.Repository{
+		ID:    "github.com/benbjohnson/proj",
+		Stars: 1234,
+		Forks: 37,
+		Messages: []*scuttlebutt.Message{
+			{ID: snowflake(time.Now().Add(-48 * time.Hour))},
+			{ID: snowflake(time.Now().Add(-1 * time.Hour))},
+			{ID: snowflake(time.Now().Add(-2 * time.Hour))},
+			{ID: 1, Source: "hn", Time: time.Now().Add(-3 * time.Hour)},
+			{ID: 2, Source: "hn", Time: time.Now().Add(-72 * time.Hour)},
+			{ID: snowflake(time.Now()), Source: "hn"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	} else if s != "proj ⭐ 1.2k, 37 forks, 3 mentions today" {
+		t.Fatalf("unexpected text: %s", s)
+	}
+}
+
+// Ensure counts are abbreviated.
+func TestShortCount(t *testing.T) {
+	for _, tt := range []struct {
+		n   int
+		exp string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1k"},
+		{1234, "1.2k"},
+		{12345, "12.3k"},
+		{123456, "123k"},
+		{2500000, "2.5m"},
+	} {
+		if s := twitter.ShortCount(tt.n); s != tt.exp {
+			t.Errorf("ShortCount(%d)=%s, expected %s", tt.n, s, tt.exp)
+		}
+	}
+}
+
+// Ensure a template shortens long descriptions.
+func TestTemplate_Text_Shorten(t *testing.T) {
+	tmpl, err := twitter.ParseTemplate(`{{.Name}} - {{.Description}} {{.URL}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := tmpl.Text(&scuttlebutt.Repository{
+		ID:          "github.com/benbjohnson/proj",
+		Description: strings.Repeat("x", 400),
+	}); err != nil {
+		t.Fatal(err)
+	} else if n := twitter.TextLength(s); n != twitter.MaxNotifyTextLength {
+		t.Fatalf("unexpected length: %d", n)
+	}
+}
+
+// Ensure templates referencing unknown fields are rejected.
+func TestParseTemplate_ErrUnknownField(t *testing.T) {
+	if _, err := twitter.ParseTemplate(`{{.Nope}}`); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// Ensure variants are chosen consistently and in proportion to their weights.
+func TestChooseVariant(t *testing.T) {
+	a := []*twitter.TemplateVariant{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+		{Name: "off", Weight: 0},
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("github.com/user/repo%d", i)
+		v := twitter.ChooseVariant(a, id)
+		if v == nil {
+			t.Fatal("expected variant")
+		} else if other := twitter.ChooseVariant(a, id); other != v {
+			t.Fatalf("inconsistent variant: %s != %s", other.Name, v.Name)
+		}
+		counts[v.Name]++
+	}
+	if counts["off"] != 0 {
+		t.Fatalf("unexpected zero weight count: %d", counts["off"])
+	} else if counts["a"] < 650 || counts["a"] > 850 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+
+	if v := twitter.ChooseVariant(nil, "github.com/user/repo"); v != nil {
+		t.Fatalf("unexpected variant: %s", v.Name)
+	}
+}
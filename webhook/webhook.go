@@ -0,0 +1,117 @@
+// Package webhook posts notification events to external HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/events"
+)
+
+// DefaultTimeout is the default time allowed for a webhook request.
+const DefaultTimeout = 10 * time.Second
+
+// SignatureHeader is the header containing the HMAC-SHA256 signature of the
+// request body, if a secret is set.
+const SignatureHeader = "X-Scuttlebutt-Signature"
+
+// Payload represents the JSON body posted for a sent notification.
+type Payload struct {
+	Event        string    `json:"event"`
+	Username     string    `json:"username"`
+	RepositoryID string    `json:"repository_id,omitempty"`
+	Text         string    `json:"text"`
+	TweetID      uint64    `json:"tweet_id,string,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// Webhook posts sent notifications to a URL as JSON.
+type Webhook struct {
+	URL string
+
+	// Optional secret used to sign request bodies. The signature is set
+	// in SignatureHeader as "sha256=<hex>".
+	Secret string
+
+	HTTPClient *http.Client
+
+	// Destination for log output.
+	LogOutput io.Writer
+}
+
+// New returns a new instance of Webhook that posts to url.
+func New(url string) *Webhook {
+	return &Webhook{
+		URL:        url,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		LogOutput:  ioutil.Discard,
+	}
+}
+
+// Run posts each notification received on sub until it is closed. Failed
+// posts are logged and are not retried.
+func (w *Webhook) Run(sub *events.Subscription) {
+	logger := log.New(w.LogOutput, "[webhook] ", log.LstdFlags)
+	for e := range sub.C() {
+		if e, ok := e.(*events.NotificationSent); ok {
+			if err := w.Send(e, time.Now()); err != nil {
+				logger.Printf("send error: username=%s, repo=%s, err=%s", e.Username, e.RepositoryID, err)
+			}
+		}
+	}
+}
+
+// Send posts a sent notification to the webhook URL.
+func (w *Webhook) Send(e *events.NotificationSent, now time.Time) error {
+	body, err := json.Marshal(&Payload{
+		Event:        e.Type(),
+		Username:     e.Username,
+		RepositoryID: e.RepositoryID,
+		Text:         e.Text,
+		TweetID:      e.MessageID,
+		URL:          e.URL,
+		Time:         now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Construct request.
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+
+	// Send request.
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of body using secret.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
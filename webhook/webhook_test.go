@@ -0,0 +1,53 @@
+package webhook_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/scuttlebutt/events"
+	"github.com/benbjohnson/scuttlebutt/webhook"
+)
+
+// Ensure sent notifications are posted as signed JSON.
+func TestWebhook_Send(t *testing.T) {
+	const body = `{"event":"notification_sent","username":"oss_go","repository_id":"github.com/user/repo","text":"repo - lorem","tweet_id":"123","url":"https://twitter.com/oss_go/status/123","time":"2000-01-01T00:00:00Z"}`
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" {
+			t.Fatalf("unexpected method: %s", r.Method)
+		} else if string(buf) != body {
+			t.Fatalf("unexpected body: %s", buf)
+		} else if sig := r.Header.Get(webhook.SignatureHeader); sig != webhook.Sign("SECRET", buf) {
+			t.Fatalf("unexpected signature: %s", sig)
+		}
+	}))
+	defer s.Close()
+
+	w := webhook.New(s.URL)
+	w.Secret = "SECRET"
+	if err := w.Send(&events.NotificationSent{
+		Username:     "oss_go",
+		RepositoryID: "github.com/user/repo",
+		Text:         "repo - lorem",
+		MessageID:    123,
+		URL:          "https://twitter.com/oss_go/status/123",
+	}, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure non-2xx responses are returned as errors.
+func TestWebhook_Send_ErrUnexpectedStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	if err := webhook.New(s.URL).Send(&events.NotificationSent{}, time.Now()); err == nil || err.Error() != "unexpected status: 500" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}